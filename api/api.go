@@ -2,14 +2,18 @@ package api
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/aragon/ovote-node/census"
 	"github.com/aragon/ovote-node/censusbuilder"
+	"github.com/aragon/ovote-node/db"
 	"github.com/aragon/ovote-node/types"
 	"github.com/aragon/ovote-node/votesaggregator"
 	"github.com/gin-gonic/gin"
+	"github.com/vocdoni/arbo"
 	"go.vocdoni.io/dvote/log"
 )
 
@@ -34,12 +38,13 @@ func New(censusBuilder *censusbuilder.CensusBuilder,
 
 	if censusBuilder != nil {
 		a.cb = censusBuilder
-		// r.GET("/census", a.getCensuses) // TODO
+		r.GET("/census", a.getCensuses)
 		r.POST("/census", a.postNewCensus)
 		r.GET("/census/:censusid", a.getCensus)
 		r.POST("/census/:censusid", a.postAddKeys)
 		r.POST("/census/:censusid/close", a.postCloseCensus)
 		r.GET("/census/:censusid/merkleproof/:pubkey", a.getMerkleProofHandler)
+		r.GET("/externalid/:externalid", a.getCensusByExternalID)
 	}
 
 	if votesAggregator != nil {
@@ -66,11 +71,26 @@ type errorMsg struct {
 
 func returnErr(c *gin.Context, err error) {
 	log.Warnw("HTTP API Bad request error", "err", err)
-	c.JSON(http.StatusBadRequest, errorMsg{
+	c.JSON(errStatusCode(err), errorMsg{
 		Message: err.Error(),
 	})
 }
 
+// errStatusCode returns the HTTP status code for the given error, using the
+// db errors to distinguish the requests of missing or duplicated data
+func errStatusCode(err error) int {
+	switch {
+	case errors.Is(err, db.ErrProcessNotFound), errors.Is(err, db.ErrVoteNotFound),
+		errors.Is(err, census.ErrPublicKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrVoteAlreadyExists), errors.Is(err, db.ErrNullifierUsed),
+		errors.Is(err, census.ErrHashFunctionMismatch):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
 func (a *API) postNewCensus(c *gin.Context) {
 	var d newCensusReq
 	err := c.ShouldBindJSON(&d)
@@ -79,7 +99,16 @@ func (a *API) postNewCensus(c *gin.Context) {
 		return
 	}
 
-	censusID, err := a.cb.NewCensus()
+	opts := censusbuilder.CensusOptions{MaxLevels: d.MaxLevels,
+		ExternalID: d.ExternalID, ExpiresAt: d.ExpiresAt}
+	if d.HashFunction != "" {
+		opts.HashFunction, err = types.HashFunctionFromType(d.HashFunction)
+		if err != nil {
+			returnErr(c, err)
+			return
+		}
+	}
+	censusID, err := a.cb.NewCensusWithOptions(opts)
 	if err != nil {
 		returnErr(c, err)
 		return
@@ -87,19 +116,20 @@ func (a *API) postNewCensus(c *gin.Context) {
 
 	// TODO maybe remove the key addition, to force usage of separated
 	// endpoints (newCensus, and then addKeys)
-	go a.cb.AddPublicKeysAndStoreError(censusID, d.PublicKeys, d.Weights)
+	if _, err := a.cb.EnqueueAddPublicKeys(censusID, d.PublicKeys, d.Weights); err != nil {
+		returnErr(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, censusID)
 }
 
 func (a *API) postAddKeys(c *gin.Context) {
-	censusIDStr := c.Param("censusid")
-	censusIDInt, err := strconv.Atoi(censusIDStr)
+	censusID, err := types.ParseCensusID(c.Param("censusid"))
 	if err != nil {
 		returnErr(c, err)
 		return
 	}
-	censusID := uint64(censusIDInt)
 
 	var d newCensusReq
 	err = c.ShouldBindJSON(&d)
@@ -108,19 +138,20 @@ func (a *API) postAddKeys(c *gin.Context) {
 		return
 	}
 
-	go a.cb.AddPublicKeysAndStoreError(censusID, d.PublicKeys, d.Weights)
+	if _, err := a.cb.EnqueueAddPublicKeys(censusID, d.PublicKeys, d.Weights); err != nil {
+		returnErr(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, censusID)
 }
 
 func (a *API) postCloseCensus(c *gin.Context) {
-	censusIDStr := c.Param("censusid")
-	censusIDInt, err := strconv.Atoi(censusIDStr)
+	censusID, err := types.ParseCensusID(c.Param("censusid"))
 	if err != nil {
 		returnErr(c, err)
 		return
 	}
-	censusID := uint64(censusIDInt)
 
 	if err = a.cb.CloseCensus(censusID); err != nil {
 		returnErr(c, err)
@@ -135,14 +166,31 @@ func (a *API) postCloseCensus(c *gin.Context) {
 	c.JSON(http.StatusOK, hex.EncodeToString(root))
 }
 
+func (a *API) getCensuses(c *gin.Context) {
+	status, err := censusbuilder.ParseCensusStatus(c.Query("status"))
+	if err != nil {
+		returnErr(c, err)
+		return
+	}
+	censuses, err := a.cb.ListCensuses(status)
+	if err != nil {
+		returnErr(c, err)
+		return
+	}
+	if censuses == nil {
+		// return an empty list instead of null
+		censuses = []censusbuilder.CensusSummary{}
+	}
+	c.JSON(http.StatusOK, censuses)
+}
+
 func (a *API) getCensus(c *gin.Context) {
-	censusIDStr := c.Param("censusid")
-	censusID, err := strconv.Atoi(censusIDStr)
+	censusID, err := types.ParseCensusID(c.Param("censusid"))
 	if err != nil {
 		returnErr(c, err)
 		return
 	}
-	censusInfo, err := a.cb.CensusInfo(uint64(censusID))
+	censusInfo, err := a.cb.CensusInfo(censusID)
 	if err != nil {
 		returnErr(c, err)
 		return
@@ -150,14 +198,21 @@ func (a *API) getCensus(c *gin.Context) {
 	c.JSON(http.StatusOK, censusInfo)
 }
 
+func (a *API) getCensusByExternalID(c *gin.Context) {
+	censusID, err := a.cb.CensusByExternalID(c.Param("externalid"))
+	if err != nil {
+		returnErr(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, censusID)
+}
+
 func (a *API) getMerkleProofHandler(c *gin.Context) {
-	censusIDStr := c.Param("censusid")
-	censusIDInt, err := strconv.Atoi(censusIDStr)
+	censusID, err := types.ParseCensusID(c.Param("censusid"))
 	if err != nil {
 		returnErr(c, err)
 		return
 	}
-	censusID := uint64(censusIDInt)
 
 	pubK, err := types.HexToPublicKey(c.Param("pubkey"))
 	if err != nil {
@@ -171,15 +226,29 @@ func (a *API) getMerkleProofHandler(c *gin.Context) {
 		return
 	}
 
-	// get MerkleProof
-	index, proof, err := a.cb.GetProof(censusID, pubK)
+	// get the CensusProof, with the Weight of the PublicKey, which is
+	// needed to verify it. If the hashFunction query parameter is set,
+	// the CensusProof is only returned if the Census uses the
+	// HashFunction expected by the circuit.
+	var proof *types.CensusProof
+	if hashFuncType := c.Query("hashFunction"); hashFuncType != "" {
+		var hashFunc arbo.HashFunction
+		hashFunc, err = types.HashFunctionFromType(hashFuncType)
+		if err != nil {
+			returnErr(c, err)
+			return
+		}
+		proof, err = a.cb.GetCensusProofForHashFunction(censusID, pubK, hashFunc)
+	} else {
+		proof, err = a.cb.GetCensusProof(censusID, pubK)
+	}
 	if err != nil {
 		returnErr(c, err)
 		return
 	}
 	// PublicKey not returned, as is already known by the user
-	c.JSON(http.StatusOK,
-		types.CensusProof{Index: index, MerkleProof: proof})
+	proof.PublicKey = nil
+	c.JSON(http.StatusOK, proof)
 }
 
 func (a *API) postVote(c *gin.Context) {
@@ -198,6 +267,20 @@ func (a *API) postVote(c *gin.Context) {
 		return
 	}
 
+	if a.cb != nil {
+		// reject the vote if the VotingDeadline of the Census of the
+		// process has passed
+		process, err := a.va.ProcessInfo(processID)
+		if err != nil {
+			returnErr(c, err)
+			return
+		}
+		if err := a.cb.CheckVotingOpenByRoot(process.CensusRoot); err != nil {
+			returnErr(c, err)
+			return
+		}
+	}
+
 	err = a.va.AddVote(processID, vote)
 	if err != nil {
 		returnErr(c, err)
@@ -161,7 +161,7 @@ func prepareVotes(c *qt.C, chainID, processID uint64, keys test.Keys,
 		msgToSign, err := types.HashVote(chainID, processID, voteBytes)
 		c.Assert(err, qt.IsNil)
 		sigUncomp := keys.PrivateKeys[i].SignPoseidon(msgToSign)
-		sig := sigUncomp.Compress()
+		sig := types.CompressSignature(sigUncomp)
 
 		vote := types.VotePackage{
 			Signature: sig,
@@ -190,6 +190,73 @@ func TestPostNewCensusHandler(t *testing.T) {
 	keys := test.GenUserKeys(nKeys)
 	// create a new census with the keys
 	doPostNewCensus(c, a, keys.PublicKeys, keys.Weights)
+
+	// create a new census with the given MerkleTree parameters
+	reqData := newCensusReq{MaxLevels: 8, HashFunction: "mimc7"}
+	jsonReqData, err := json.Marshal(reqData)
+	c.Assert(err, qt.IsNil)
+	req, err := http.NewRequest("POST", "/census", bytes.NewBuffer(jsonReqData))
+	c.Assert(err, qt.IsNil)
+	w := httptest.NewRecorder()
+	a.r.ServeHTTP(w, req)
+	c.Assert(w.Code, qt.Equals, http.StatusOK)
+	var censusID types.CensusID
+	c.Assert(json.Unmarshal(w.Body.Bytes(), &censusID), qt.IsNil)
+	params, err := a.cb.Parameters(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(params.MaxLevels, qt.Equals, 8)
+	c.Assert(params.HashFunction, qt.Equals, "mimc7")
+
+	// unsupported HashFunction
+	reqData = newCensusReq{HashFunction: "sha256"}
+	jsonReqData, err = json.Marshal(reqData)
+	c.Assert(err, qt.IsNil)
+	req, err = http.NewRequest("POST", "/census", bytes.NewBuffer(jsonReqData))
+	c.Assert(err, qt.IsNil)
+	w = httptest.NewRecorder()
+	a.r.ServeHTTP(w, req)
+	c.Assert(w.Code, qt.Not(qt.Equals), http.StatusOK)
+}
+
+func TestGetCensusByExternalIDHandler(t *testing.T) {
+	c := qt.New(t)
+
+	chainID := uint64(3)
+	a, _ := newTestAPI(c, chainID)
+	a.r.POST("/census", a.postNewCensus)
+	a.r.GET("/externalid/:externalid", a.getCensusByExternalID)
+
+	postNewCensus := func(externalID string) *httptest.ResponseRecorder {
+		jsonReqData, err := json.Marshal(newCensusReq{ExternalID: externalID})
+		c.Assert(err, qt.IsNil)
+		req, err := http.NewRequest("POST", "/census", bytes.NewBuffer(jsonReqData))
+		c.Assert(err, qt.IsNil)
+		w := httptest.NewRecorder()
+		a.r.ServeHTTP(w, req)
+		return w
+	}
+	w := postNewCensus("process-1")
+	c.Assert(w.Code, qt.Equals, http.StatusOK)
+	var censusID types.CensusID
+	c.Assert(json.Unmarshal(w.Body.Bytes(), &censusID), qt.IsNil)
+	// the ExternalID can not be reused
+	w = postNewCensus("process-1")
+	c.Assert(w.Code, qt.Not(qt.Equals), http.StatusOK)
+
+	req, err := http.NewRequest("GET", "/externalid/process-1", nil)
+	c.Assert(err, qt.IsNil)
+	w = httptest.NewRecorder()
+	a.r.ServeHTTP(w, req)
+	c.Assert(w.Code, qt.Equals, http.StatusOK)
+	var gotCensusID types.CensusID
+	c.Assert(json.Unmarshal(w.Body.Bytes(), &gotCensusID), qt.IsNil)
+	c.Assert(gotCensusID, qt.Equals, censusID)
+
+	req, err = http.NewRequest("GET", "/externalid/process-2", nil)
+	c.Assert(err, qt.IsNil)
+	w = httptest.NewRecorder()
+	a.r.ServeHTTP(w, req)
+	c.Assert(w.Code, qt.Not(qt.Equals), http.StatusOK)
 }
 
 func TestPostAddKeysHandler(t *testing.T) {
@@ -234,6 +301,58 @@ func TestPostCloseCensusHandler(t *testing.T) {
 	_ = doPostCloseCensus(c, a, censusID)
 }
 
+func TestGetCensusesHandler(t *testing.T) {
+	c := qt.New(t)
+
+	chainID := uint64(3)
+	a, _ := newTestAPI(c, chainID)
+	a.r.GET("/census", a.getCensuses)
+
+	doGetCensuses := func(query string, expectedCode int) []censusbuilder.CensusSummary {
+		req, err := http.NewRequest("GET", "/census"+query, nil)
+		c.Assert(err, qt.IsNil)
+		w := httptest.NewRecorder()
+		a.r.ServeHTTP(w, req)
+		c.Assert(w.Code, qt.Equals, expectedCode)
+		if expectedCode != http.StatusOK {
+			return nil
+		}
+		var censuses []censusbuilder.CensusSummary
+		err = json.Unmarshal(w.Body.Bytes(), &censuses)
+		c.Assert(err, qt.IsNil)
+		return censuses
+	}
+
+	// without Censuses, expect an empty list
+	c.Assert(doGetCensuses("", http.StatusOK), qt.DeepEquals,
+		[]censusbuilder.CensusSummary{})
+
+	keys := test.GenUserKeys(5)
+	openID, err := a.cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = a.cb.AddPublicKeys(openID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	closedID, err := a.cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = a.cb.CloseCensus(closedID)
+	c.Assert(err, qt.IsNil)
+
+	censuses := doGetCensuses("", http.StatusOK)
+	c.Assert(len(censuses), qt.Equals, 2)
+	c.Assert(censuses[0].Size, qt.Equals, uint64(5))
+	c.Assert(censuses[0].Closed, qt.IsFalse)
+	c.Assert(censuses[1].Closed, qt.IsTrue)
+
+	censuses = doGetCensuses("?status=open", http.StatusOK)
+	c.Assert(len(censuses), qt.Equals, 1)
+	c.Assert(censuses[0].ID, qt.Equals, openID)
+	censuses = doGetCensuses("?status=closed", http.StatusOK)
+	c.Assert(len(censuses), qt.Equals, 1)
+	c.Assert(censuses[0].ID, qt.Equals, closedID)
+
+	doGetCensuses("?status=unknown", http.StatusBadRequest)
+}
+
 func TestGetProofHandler(t *testing.T) {
 	c := qt.New(t)
 
@@ -247,6 +366,10 @@ func TestGetProofHandler(t *testing.T) {
 	// generate the publicKeys
 	log.Debugf("Generating %d PublicKeys", nKeys)
 	keys := test.GenUserKeys(nKeys)
+	// weighted PublicKeys, the weight is returned in the CensusProof
+	for i := 0; i < nKeys; i++ {
+		keys.Weights[i] = big.NewInt(int64(i + 1))
+	}
 
 	// create a new census with the first 100 keys
 	censusID := doPostNewCensus(c, a, keys.PublicKeys, keys.Weights)
@@ -258,12 +381,36 @@ func TestGetProofHandler(t *testing.T) {
 	for i := 0; i < nKeys; i++ {
 		cp := doGetProof(c, a, censusID, keys.PublicKeys[i])
 		// fmt.Printf("Index: %d, MerkleProof: %x\n", cp.Index, cp.MerkleProof)
+		c.Assert(cp.Weight.Cmp(keys.Weights[i]), qt.Equals, 0)
 
 		v, err := census.CheckProof(censusRoot, cp.MerkleProof, cp.Index,
 			&keys.PublicKeys[i], keys.Weights[i])
 		c.Assert(err, qt.IsNil)
 		c.Assert(v, qt.IsTrue)
 	}
+
+	// a PublicKey that is not in the Census is not found
+	pubKComp := test.GenUserKeys(1).PublicKeys[0].Compress()
+	req, err := http.NewRequest("GET", "/census/"+strconv.Itoa(int(censusID))+
+		"/merkleproof/"+hex.EncodeToString(pubKComp[:]), nil)
+	c.Assert(err, qt.IsNil)
+	w := httptest.NewRecorder()
+	a.r.ServeHTTP(w, req)
+	c.Assert(w.Code, qt.Equals, http.StatusNotFound)
+
+	// the CensusProof is only returned for a circuit that expects the
+	// HashFunction of the Census
+	pubKComp = keys.PublicKeys[0].Compress()
+	proofPath := "/census/" + strconv.Itoa(int(censusID)) + "/merkleproof/" +
+		hex.EncodeToString(pubKComp[:])
+	for hashFunc, code := range map[string]int{"poseidon": http.StatusOK,
+		"mimc7": http.StatusConflict, "sha256": http.StatusBadRequest} {
+		req, err = http.NewRequest("GET", proofPath+"?hashFunction="+hashFunc, nil)
+		c.Assert(err, qt.IsNil)
+		w = httptest.NewRecorder()
+		a.r.ServeHTTP(w, req)
+		c.Assert(w.Code, qt.Equals, code, qt.Commentf("hashFunction %s", hashFunc))
+	}
 }
 
 func TestGetProcessInfo(t *testing.T) {
@@ -276,7 +423,8 @@ func TestGetProcessInfo(t *testing.T) {
 	// simulate SmartContract Process creation, by adding the CensusRoot in
 	// the votesaggregator db
 	processID := uint64(123)
-	censusRoot := []byte("testroot")
+	censusRoot := make([]byte, types.RootLen)
+	copy(censusRoot, "testroot")
 	censusSize := uint64(100)
 	ethBlockNum := uint64(10)
 	ethEndBlockNum := uint64(20)
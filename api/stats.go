@@ -0,0 +1,45 @@
+package api
+
+// NodeStats contains aggregated metrics of the Node. The metrics of the
+// CensusBuilder or the VotesAggregator are zero when they are not active.
+type NodeStats struct {
+	// Censuses is the number of Censuses created
+	Censuses uint64 `json:"censuses"`
+	// ClosedCensuses is the number of closed Censuses
+	ClosedCensuses uint64 `json:"closedCensuses"`
+	// ArchivedCensuses is the number of archived Censuses
+	ArchivedCensuses uint64 `json:"archivedCensuses"`
+	// PublicKeys is the total number of PublicKeys across all the
+	// Censuses
+	PublicKeys uint64 `json:"publicKeys"`
+	// Processes is the number of Processes stored
+	Processes uint64 `json:"processes"`
+	// Votes is the total number of VotePackages stored across all the
+	// Processes
+	Votes uint64 `json:"votes"`
+}
+
+// Stats returns the NodeStats, aggregating the Censuses metrics of the
+// CensusBuilder and the counts of the VotesAggregator db
+func (a *API) Stats() (NodeStats, error) {
+	var stats NodeStats
+	if a.cb != nil {
+		cbStats, err := a.cb.Stats()
+		if err != nil {
+			return NodeStats{}, err
+		}
+		stats.Censuses = cbStats.Censuses
+		stats.ClosedCensuses = cbStats.ClosedCensuses
+		stats.ArchivedCensuses = cbStats.ArchivedCensuses
+		stats.PublicKeys = cbStats.PublicKeys
+	}
+	if a.va != nil {
+		counts, err := a.va.Counts()
+		if err != nil {
+			return NodeStats{}, err
+		}
+		stats.Processes = counts.Processes
+		stats.Votes = counts.VotePackages
+	}
+	return stats, nil
+}
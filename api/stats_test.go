@@ -0,0 +1,69 @@
+package api
+
+import (
+	"math/big"
+	"strconv"
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestStats(t *testing.T) {
+	c := qt.New(t)
+
+	a, sqlite := newTestAPI(c, 3)
+
+	stats, err := a.Stats()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stats, qt.Equals, NodeStats{})
+
+	nKeys := 5
+	keys := test.GenUserKeys(nKeys)
+	censusID, err := a.cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = a.cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = a.cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	censusRoot, err := a.cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, censusRoot, uint64(nKeys), 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+	nVotes := 3
+	for i := 0; i < nVotes; i++ {
+		vote := types.VotePackage{
+			Signature: types.CompressSignature(keys.PrivateKeys[i].SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   &keys.PublicKeys[i],
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: []byte("test"),
+		}
+		err = sqlite.StoreVotePackage(processID, vote)
+		c.Assert(err, qt.IsNil)
+	}
+
+	stats, err = a.Stats()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stats, qt.Equals, NodeStats{
+		Censuses:       1,
+		ClosedCensuses: 1,
+		PublicKeys:     uint64(nKeys),
+		Processes:      1,
+		Votes:          uint64(nVotes),
+	})
+
+	// without VotesAggregator, only the CensusBuilder metrics are returned
+	a.va = nil
+	stats, err = a.Stats()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stats.Votes, qt.Equals, uint64(0))
+	c.Assert(stats.PublicKeys, qt.Equals, uint64(nKeys))
+}
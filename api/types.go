@@ -2,6 +2,7 @@ package api
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/iden3/go-iden3-crypto/babyjub"
 )
@@ -11,4 +12,14 @@ type newCensusReq struct {
 	// representation of compressed PublicKeys
 	PublicKeys []babyjub.PublicKey `json:"publicKeys"`
 	Weights    []*big.Int          `json:"weights"`
+	// MaxLevels and HashFunction define the parameters of the MerkleTree
+	// of a new Census, if not set the defaults are used
+	MaxLevels    int    `json:"maxLevels,omitempty"`
+	HashFunction string `json:"hashFunction,omitempty"`
+	// ExternalID is the identifier supplied by the integrator attached to
+	// a new Census, which can be used to find its censusID
+	ExternalID string `json:"externalID,omitempty"`
+	// ExpiresAt is the time after which a new Census is deleted, if not
+	// set the Census does not expire
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
 }
@@ -0,0 +1,434 @@
+package census
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+var (
+	// dbKeyKeyType is used to store the KeyType of the leafs of the Census
+	dbKeyKeyType = []byte("keyType")
+	// dbPrefixAddress is used to store the mapping Address->Index,Weight
+	dbPrefixAddress = []byte("address")
+	// dbPrefixIndexAddress is used to store the mapping Index->Address,
+	// where each entry key is dbPrefixIndexAddress | index in big-endian
+	dbPrefixIndexAddress = []byte("indexAddress")
+)
+
+// ErrKeyTypeMismatch is used when trying to add to the Census, or to get the
+// CensusProof of, a key of a different KeyType than the one of the Census
+var ErrKeyTypeMismatch = errors.New("KeyType mismatch")
+
+func dbKeyAddress(addr common.Address) []byte {
+	return append(append([]byte{}, dbPrefixAddress...), addr.Bytes()...)
+}
+
+func dbKeyIndexAddress(index uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, index)
+	return append(append([]byte{}, dbPrefixIndexAddress...), b...)
+}
+
+// initKeyType stores the KeyType for a Census that has no keys yet, and
+// returns the stored KeyType, which is KeyTypeBabyJubJub for the Censuses
+// created before the KeyType was stored
+func (c *Census) initKeyType(wTx db.WriteTx, keyType types.KeyType) (types.KeyType, error) {
+	b, err := wTx.Get(dbKeyKeyType)
+	if err == nil {
+		stored := types.KeyType(binary.LittleEndian.Uint64(b))
+		if keyType != types.KeyTypeBabyJubJub && keyType != stored {
+			return 0, fmt.Errorf("%s, can not change the KeyType of an"+
+				" existing Census, KeyType: %s", ErrKeyTypeMismatch, stored)
+		}
+		return stored, nil
+	} else if err != db.ErrKeyNotFound {
+		return 0, err
+	}
+	if keyType == types.KeyTypeBabyJubJub {
+		return keyType, nil
+	}
+	if keyType != types.KeyTypeEthAddress {
+		return 0, fmt.Errorf("%s: %s", types.ErrUnsupportedKeyType, keyType)
+	}
+	if c.sortKeys {
+		return 0, fmt.Errorf("SortKeys is not supported in a Census of"+
+			" KeyType %s", keyType)
+	}
+	nLeafs, err := c.tree.GetNLeafsWithTx(treeTx(wTx))
+	if err != nil {
+		return 0, err
+	}
+	if nLeafs > 0 {
+		return 0, fmt.Errorf("can not set the KeyType %s in a Census that"+
+			" already contains PublicKeys", keyType)
+	}
+	b = make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(keyType))
+	if err := wTx.Set(dbKeyKeyType, b); err != nil {
+		return 0, err
+	}
+	return keyType, nil
+}
+
+// KeyType returns the KeyType of the leafs of the Census
+func (c *Census) KeyType() types.KeyType {
+	return c.keyType
+}
+
+// checkKeyType returns ErrKeyTypeMismatch if the Census is not of the given
+// KeyType
+func (c *Census) checkKeyType(keyType types.KeyType) error {
+	if c.keyType != keyType {
+		return fmt.Errorf("%s, the Census is of KeyType %s, not %s",
+			ErrKeyTypeMismatch, c.keyType, keyType)
+	}
+	return nil
+}
+
+// AddAddresses adds the given batch of Ethereum addresses to a Census of
+// KeyTypeEthAddress, assigning incremental indexes to each one, as
+// AddPublicKeys does for the PublicKeys. The leaf value of each address is
+// computed with types.HashAddressBytes, so the ECDSA signed votes can be
+// proven by a companion circuit. If any address appears more than once in the
+// batch or is already in the Census, no address is added, and the InvalidKeys
+// are returned with their InvalidReason.
+func (c *Census) AddAddresses(addrs []common.Address, weights []*big.Int) (
+	[]InvalidKey, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.checkKeyType(types.KeyTypeEthAddress); err != nil {
+		return nil, err
+	}
+	if err := c.checkCanAddKeys(len(addrs), weights); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkMaxNLeafs(len(addrs)); err != nil {
+		return nil, err
+	}
+
+	invalids, err := c.checkAddresses(addrs)
+	if err != nil {
+		return nil, err
+	}
+	if len(invalids) != 0 {
+		return invalids, fmt.Errorf("Can not add %d addresses", len(invalids))
+	}
+
+	nAdded := 0
+	var batchErr error
+	for from := 0; from < len(addrs); from += c.chunkSize {
+		to := from + c.chunkSize
+		if to > len(addrs) {
+			to = len(addrs)
+		}
+		chunkInvalids, err := c.addAddressesChunk(addrs[from:to], weights[from:to])
+		for i := 0; i < len(chunkInvalids); i++ {
+			chunkInvalids[i].Index += from
+		}
+		invalids = append(invalids, chunkInvalids...)
+		if err != nil && len(chunkInvalids) == 0 {
+			batchErr = partialBatchError(err, nAdded, from)
+			break
+		}
+		if err == nil {
+			nAdded += to - from
+		}
+	}
+	// the committed chunks are kept even if a later chunk fails
+	if c.nChunksSinceCheckpoint != 0 {
+		if err := c.checkpoint(); err != nil {
+			return invalids, err
+		}
+	}
+	if nAdded != 0 {
+		if err := c.recordRoot(nAdded); err != nil {
+			return invalids, err
+		}
+	}
+	if batchErr != nil {
+		return invalids, batchErr
+	}
+	if len(invalids) != 0 {
+		return invalids, fmt.Errorf("Can not add %d addresses", len(invalids))
+	}
+	return nil, nil
+}
+
+// checkAddresses returns the InvalidKeys of the given batch of addresses that
+// appear more than once in the batch (all except the first occurrence), or
+// that are already in the Census
+func (c *Census) checkAddresses(addrs []common.Address) ([]InvalidKey, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	var invalids []InvalidKey
+	seen := make(map[common.Address]int, len(addrs))
+	for i := 0; i < len(addrs); i++ {
+		if j, ok := seen[addrs[i]]; ok {
+			invalids = append(invalids, InvalidKey{Index: i,
+				Reason: InvalidDuplicateInBatch,
+				Error:  fmt.Errorf("address already in the batch at %d", j)})
+			continue
+		}
+		seen[addrs[i]] = i
+		_, err := rTx.Get(dbKeyAddress(addrs[i]))
+		if err == nil {
+			invalids = append(invalids, InvalidKey{Index: i,
+				Reason: InvalidAlreadyPresent,
+				Error:  fmt.Errorf("address already added")})
+		} else if err != db.ErrKeyNotFound {
+			return nil, err
+		}
+	}
+	return invalids, nil
+}
+
+// addAddressesChunk adds the given addresses in a single db.WriteTx, which is
+// only committed if all the addresses are added. Every checkpointInterval
+// chunks, the Checkpoint is stored in the same db.WriteTx.
+func (c *Census) addAddressesChunk(addrs []common.Address, weights []*big.Int) (
+	[]InvalidKey, error) {
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+
+	nextIndex, err := c.getNextIndex(wTx)
+	if err != nil {
+		return nil, err
+	}
+	var indexes [][]byte
+	index := nextIndex
+	for i := 0; i < len(addrs); i++ {
+		// skip the indexes explicitly assigned by AddPublicKeysAtIndices
+		for {
+			reserved, err := c.isIndexReserved(wTx, index)
+			if err != nil {
+				return nil, err
+			}
+			if !reserved {
+				break
+			}
+			index++
+		}
+		if index >= c.maxNLeafs() {
+			return nil, fmt.Errorf("%s (%d), index: %d", ErrMaxNLeafsReached,
+				c.maxNLeafs(), index)
+		}
+
+		// store the mappings Address->Index,Weight and Index->Address
+		indexAndWeight := types.IndexAndWeightToBytes(index, weights[i])
+		if err := wTx.Set(dbKeyAddress(addrs[i]), indexAndWeight[:]); err != nil {
+			return nil, err
+		}
+		if err := wTx.Set(dbKeyIndexAddress(index), addrs[i].Bytes()); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, c.indexKey(index))
+		index++
+	}
+
+	leafValues := make([][]byte, len(addrs))
+	err = c.parallelize(len(addrs), func(i int) error {
+		leafValue, err := types.HashAddressBytes(addrs[i], weights[i])
+		if err != nil {
+			return err
+		}
+		leafValues[i] = leafValue
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	arboInvalids, err := c.tree.AddBatchWithTx(treeTx(wTx), indexes, leafValues)
+	invalids := invalidsFromArbo(arboInvalids)
+	if err != nil {
+		return invalids, err
+	}
+	if len(invalids) != 0 {
+		return invalids, fmt.Errorf("Can not add %d addresses", len(invalids))
+	}
+	if err = c.setNextIndex(wTx, index); err != nil {
+		return nil, err
+	}
+	if err := c.markBuilding(wTx); err != nil {
+		return nil, err
+	}
+
+	checkpoint := c.nChunksSinceCheckpoint+1 >= c.checkpointInterval
+	if checkpoint {
+		if err := c.storeCheckpoint(wTx); err != nil {
+			return nil, err
+		}
+	}
+	if err := wTx.Commit(); err != nil {
+		return nil, err
+	}
+	if checkpoint {
+		c.nChunksSinceCheckpoint = 0
+	} else {
+		c.nChunksSinceCheckpoint++
+	}
+	return nil, nil
+}
+
+// IndexedAddress contains an Ethereum address of a Census of
+// KeyTypeEthAddress, with its index and weight
+type IndexedAddress struct {
+	Index   uint64
+	Address common.Address
+	Weight  *big.Int
+}
+
+// Addresses returns all the Ethereum addresses of a Census of
+// KeyTypeEthAddress, with their index and weight, sorted by index
+func (c *Census) Addresses() ([]IndexedAddress, error) {
+	if err := c.checkKeyType(types.KeyTypeEthAddress); err != nil {
+		return nil, err
+	}
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	var addrs []IndexedAddress
+	var iterErr error
+	err := c.IterateLeafKeys(func(index uint64, key []byte) bool {
+		addr := common.BytesToAddress(key)
+		indexAndWeight, err := rTx.Get(dbKeyAddress(addr))
+		if err != nil {
+			iterErr = fmt.Errorf("can not get the weight of the address %s"+
+				" of index %d: %s", addr.Hex(), index, err)
+			return false
+		}
+		_, weight, err := types.BytesToIndexAndWeight(indexAndWeight)
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		addrs = append(addrs, IndexedAddress{Index: index, Address: addr,
+			Weight: weight})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if iterErr != nil {
+		return nil, iterErr
+	}
+	return addrs, nil
+}
+
+// HasAddress returns true if the given address has been added to the Census
+func (c *Census) HasAddress(addr common.Address) (bool, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	_, err := rTx.Get(dbKeyAddress(addr))
+	if err == db.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetAddressProof returns the CensusProof of the given address in the closed
+// Census, of KeyTypeEthAddress
+func (c *Census) GetAddressProof(addr common.Address) (*types.CensusProof, error) {
+	if err := c.checkKeyType(types.KeyTypeEthAddress); err != nil {
+		return nil, err
+	}
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return nil, err
+	}
+	if !isClosed {
+		return nil, ErrCensusNotClosed
+	}
+
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	indexAndWeight, err := rTx.Get(dbKeyAddress(addr))
+	if err == db.ErrKeyNotFound {
+		return nil, fmt.Errorf("address does not exist in the census (%s)",
+			addr.Hex())
+	} else if err != nil {
+		return nil, err
+	}
+	index, weight, err := types.BytesToIndexAndWeight(indexAndWeight)
+	if err != nil {
+		return nil, err
+	}
+	_, _, proof, existence, err := c.tree.GenProofWithTx(treeRTx(rTx), c.indexKey(index))
+	if err != nil {
+		return nil, err
+	}
+	if !existence {
+		return nil, fmt.Errorf("address does not exist in the census (%s)",
+			addr.Hex())
+	}
+	return &types.CensusProof{
+		Index:       index,
+		KeyType:     types.KeyTypeEthAddress,
+		Address:     &addr,
+		Weight:      weight,
+		MerkleProof: proof,
+	}, nil
+}
+
+// addressRootLeafs returns the leafs of the addresses of the Census computed
+// from the Index->Address and the Address->Index,Weight mappings, as
+// rootLeafs does for the PublicKeys
+func (c *Census) addressRootLeafs(rTx db.ReadTx) ([]rootLeaf, error) {
+	hashFunc := c.tree.HashFunction()
+	var leafs []rootLeaf
+	var iterErr error
+	err := c.db.Iterate(dbPrefixIndexAddress, func(k, v []byte) bool {
+		index := binary.BigEndian.Uint64(k)
+		addr := common.BytesToAddress(v)
+		indexAndWeight, err := rTx.Get(dbKeyAddress(addr))
+		if err == db.ErrKeyNotFound {
+			iterErr = fmt.Errorf("%s, address of index %d without weight",
+				errLeafsCorrupted, index)
+			return false
+		} else if err != nil {
+			iterErr = err
+			return false
+		}
+		addrIndex, weight, err := types.BytesToIndexAndWeight(indexAndWeight)
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		if addrIndex != index {
+			iterErr = fmt.Errorf("%s, address of index %d mapped to index"+
+				" %d", errLeafsCorrupted, index, addrIndex)
+			return false
+		}
+		value, err := types.HashAddressBytes(addr, weight)
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		hash, err := hashFunc.Hash(c.indexKey(index), value,
+			[]byte{arbo.PrefixValueLeaf})
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		leafs = append(leafs, rootLeaf{index: index, hash: hash})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if iterErr != nil {
+		return nil, iterErr
+	}
+	return leafs, nil
+}
@@ -0,0 +1,115 @@
+package census
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+)
+
+func genAddresses(nAddrs int) ([]common.Address, []*big.Int) {
+	var addrs []common.Address
+	var weights []*big.Int
+	for i := 0; i < nAddrs; i++ {
+		addrs = append(addrs, common.BigToAddress(big.NewInt(int64(1000+i))))
+		weights = append(weights, big.NewInt(int64(i+1)))
+	}
+	return addrs, weights
+}
+
+func TestAddAddresses(t *testing.T) {
+	c := qt.New(t)
+
+	database := newTestDB(c)
+	census, err := New(Options{DB: database, ChunkSize: 3,
+		KeyType: types.KeyTypeEthAddress})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.KeyType(), qt.Equals, types.KeyTypeEthAddress)
+
+	// the PublicKeys can not be added to a Census of addresses
+	pubKs, pubKWeights := genPublicKeys(2)
+	_, err = census.AddPublicKeys(pubKs, pubKWeights)
+	c.Assert(err, qt.ErrorMatches, ErrKeyTypeMismatch.Error()+".*")
+
+	addrs, weights := genAddresses(10)
+	invalids, err := census.AddAddresses(addrs[:8], weights[:8])
+	c.Assert(err, qt.IsNil)
+	c.Assert(invalids, qt.IsNil)
+
+	// duplicated addresses are rejected, without adding any of the batch
+	invalids, err = census.AddAddresses([]common.Address{addrs[8], addrs[8],
+		addrs[0]}, weights[:3])
+	c.Assert(err, qt.Not(qt.IsNil))
+	c.Assert(invalids, qt.HasLen, 2)
+	c.Assert(invalids[0].Reason, qt.Equals, InvalidDuplicateInBatch)
+	c.Assert(invalids[1].Reason, qt.Equals, InvalidAlreadyPresent)
+	has, err := census.HasAddress(addrs[8])
+	c.Assert(err, qt.IsNil)
+	c.Assert(has, qt.IsFalse)
+
+	_, err = census.AddAddresses(addrs[8:], weights[8:])
+	c.Assert(err, qt.IsNil)
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(10))
+	indexedAddrs, err := census.Addresses()
+	c.Assert(err, qt.IsNil)
+	c.Assert(indexedAddrs, qt.HasLen, 10)
+	for i := 0; i < len(indexedAddrs); i++ {
+		c.Assert(indexedAddrs[i].Index, qt.Equals, uint64(i))
+		c.Assert(indexedAddrs[i].Address, qt.Equals, addrs[i])
+		c.Assert(indexedAddrs[i].Weight.Cmp(weights[i]), qt.Equals, 0)
+	}
+
+	_, err = census.GetAddressProof(addrs[0])
+	c.Assert(err, qt.Equals, ErrCensusNotClosed)
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	for i := 0; i < len(addrs); i++ {
+		proof, err := census.GetAddressProof(addrs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(proof.Index, qt.Equals, uint64(i))
+		c.Assert(proof.KeyType, qt.Equals, types.KeyTypeEthAddress)
+		c.Assert(proof.Weight.Cmp(weights[i]), qt.Equals, 0)
+		c.Assert(proof.Verify(root), qt.IsNil)
+	}
+	_, err = census.GetAddressProof(common.HexToAddress("0x01"))
+	c.Assert(err, qt.ErrorMatches, "address does not exist.*")
+
+	valid, err := census.VerifyRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(valid, qt.IsTrue)
+
+	// the KeyType is kept when loading the Census again
+	census, err = New(Options{DB: database})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.KeyType(), qt.Equals, types.KeyTypeEthAddress)
+	info, err := census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.KeyType, qt.Equals, types.KeyTypeEthAddress)
+}
+
+func TestAddAddressesToPublicKeysCensus(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+	c.Assert(census.KeyType(), qt.Equals, types.KeyTypeBabyJubJub)
+
+	addrs, weights := genAddresses(2)
+	_, err := census.AddAddresses(addrs, weights)
+	c.Assert(err, qt.ErrorMatches, ErrKeyTypeMismatch.Error()+".*")
+	_, err = census.Addresses()
+	c.Assert(err, qt.ErrorMatches, ErrKeyTypeMismatch.Error()+".*")
+	_, err = census.GetAddressProof(addrs[0])
+	c.Assert(err, qt.ErrorMatches, ErrKeyTypeMismatch.Error()+".*")
+
+	// the KeyType can not be changed once the Census has keys
+	pubKs, pubKWeights := genPublicKeys(2)
+	_, err = census.AddPublicKeys(pubKs, pubKWeights)
+	c.Assert(err, qt.IsNil)
+	_, err = New(Options{DB: census.db, KeyType: types.KeyTypeEthAddress})
+	c.Assert(err, qt.ErrorMatches, "can not set the KeyType.*")
+}
@@ -0,0 +1,288 @@
+package census
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+// CorruptionReason is used to define why a leaf of the Census is reported as
+// corrupted by Audit
+type CorruptionReason int
+
+var (
+	// CorruptionMapping indicates that the mappings of the key of the leaf
+	// are not consistent, so its leaf value can not be computed
+	CorruptionMapping CorruptionReason = 0
+	// CorruptionLeafMissing indicates that the key of the leaf is in the
+	// Census, but its leaf is not in the MerkleTree
+	CorruptionLeafMissing CorruptionReason = 1
+	// CorruptionLeafValue indicates that the value of the leaf in the
+	// MerkleTree is not the hash of its key and weight
+	CorruptionLeafValue CorruptionReason = 2
+	// CorruptionLeafHash indicates that the node of the leaf in the
+	// MerkleTree is not stored under its hash
+	CorruptionLeafHash CorruptionReason = 3
+	// CorruptionLeafOrphan indicates that the leaf is in the MerkleTree,
+	// but there is no key of the Census with its index
+	CorruptionLeafOrphan CorruptionReason = 4
+)
+
+// String returns the description of the CorruptionReason
+func (r CorruptionReason) String() string {
+	switch r {
+	case CorruptionMapping:
+		return "inconsistent mapping"
+	case CorruptionLeafMissing:
+		return "missing leaf"
+	case CorruptionLeafValue:
+		return "wrong leaf value"
+	case CorruptionLeafHash:
+		return "wrong leaf hash"
+	case CorruptionLeafOrphan:
+		return "orphan leaf"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(r))
+	}
+}
+
+// CorruptedLeaf contains the index of a corrupted leaf of the Census,
+// together with the reason
+type CorruptedLeaf struct {
+	Index  uint64           `json:"index"`
+	Reason CorruptionReason `json:"reason"`
+	// Detail describes the corruption, when the Reason needs it
+	Detail string `json:"detail,omitempty"`
+}
+
+// AuditReport contains the result of Audit
+type AuditReport struct {
+	// Root is the root stored in the MerkleTree, and ComputedRoot the one
+	// recomputed from the keys of the Census, which is not set if the
+	// keys can not be placed in the MerkleTree
+	Root         types.ByteArray `json:"root"`
+	ComputedRoot types.ByteArray `json:"computedRoot,omitempty"`
+	// NLeafs is the number of keys of the Census, and NStoredLeafs the
+	// number of leafs found walking the MerkleTree from its root
+	NLeafs       uint64 `json:"nLeafs"`
+	NStoredLeafs uint64 `json:"nStoredLeafs"`
+	// CorruptedLeafs contains the corrupted leafs, sorted by index
+	CorruptedLeafs []CorruptedLeaf `json:"corruptedLeafs,omitempty"`
+	// CorruptedNodes is the number of intermediate nodes of the
+	// MerkleTree that are not stored under their hash
+	CorruptedNodes int `json:"corruptedNodes,omitempty"`
+	// Incomplete is set when a node of the MerkleTree is missing or not
+	// well formed, so its subtree could not be walked
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// Valid returns true if the Audit has not found any corruption
+func (r *AuditReport) Valid() bool {
+	return len(r.CorruptedLeafs) == 0 && r.CorruptedNodes == 0 &&
+		!r.Incomplete && bytes.Equal(r.Root, r.ComputedRoot)
+}
+
+func (r *AuditReport) addCorrupted(index uint64, reason CorruptionReason,
+	detail string) {
+	r.CorruptedLeafs = append(r.CorruptedLeafs,
+		CorruptedLeaf{Index: index, Reason: reason, Detail: detail})
+}
+
+// isCorrupted returns true if the leaf of the given index is already reported
+func (r *AuditReport) isCorrupted(index uint64) bool {
+	for i := 0; i < len(r.CorruptedLeafs); i++ {
+		if r.CorruptedLeafs[i].Index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// Audit checks the integrity of the Census db, open or closed, reporting each
+// corrupted leaf instead of stopping at the first one as VerifyRoot does. It
+// re-hashes the leaf of every key of the Census from its mappings, compares
+// them with the leafs of the stored MerkleTree, checking that each node is
+// stored under its hash, and recomputes the root bottom-up, to compare it
+// with the stored root. The PublicKeys buffered until the Census is closed
+// when SortKeys is enabled are not in the MerkleTree yet, so they are not
+// audited. Returns an error only if the Census can not be read.
+func (c *Census) Audit() (*AuditReport, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	root, err := c.tree.RootWithTx(treeRTx(rTx))
+	if err != nil {
+		return nil, err
+	}
+	report := &AuditReport{Root: root}
+
+	values, err := c.auditLeafValues(rTx, report)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := c.auditNodes(rTx, root, report)
+	if err != nil {
+		return nil, err
+	}
+
+	hashFunc := c.tree.HashFunction()
+	leafs := make([]rootLeaf, 0, len(values))
+	for index, value := range values {
+		hash, err := hashFunc.Hash(c.indexKey(index), value,
+			[]byte{arbo.PrefixValueLeaf})
+		if err != nil {
+			return nil, err
+		}
+		leafs = append(leafs, rootLeaf{index: index, hash: hash})
+		storedValue, ok := stored[index]
+		if !ok {
+			if !report.Incomplete {
+				report.addCorrupted(index, CorruptionLeafMissing, "")
+			}
+			continue
+		}
+		if !bytes.Equal(storedValue, value) {
+			report.addCorrupted(index, CorruptionLeafValue, "")
+		}
+	}
+	for index := range stored {
+		if _, ok := values[index]; !ok && !report.isCorrupted(index) {
+			report.addCorrupted(index, CorruptionLeafOrphan, "")
+		}
+	}
+	sort.Slice(report.CorruptedLeafs, func(i, j int) bool {
+		return report.CorruptedLeafs[i].Index < report.CorruptedLeafs[j].Index
+	})
+
+	report.ComputedRoot, err = c.computeRoot(leafs, 0)
+	if errors.Is(err, errLeafsCorrupted) {
+		report.ComputedRoot = nil
+	} else if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// auditLeafValues returns the leaf value of each key of the Census by its
+// index, computed from the Index->Key and the Key->Index,Weight mappings. The
+// keys whose mappings are not consistent are added to the report.
+func (c *Census) auditLeafValues(rTx db.ReadTx, report *AuditReport) (
+	map[uint64][]byte, error) {
+	values := make(map[uint64][]byte)
+	var iterErr error
+	err := c.IterateLeafKeys(func(index uint64, key []byte) bool {
+		report.NLeafs++
+		var mappingKey []byte
+		var pubK *babyjub.PublicKey
+		var addr common.Address
+		if c.keyType == types.KeyTypeEthAddress {
+			addr = common.BytesToAddress(key)
+			mappingKey = dbKeyAddress(addr)
+		} else {
+			var pubKComp babyjub.PublicKeyComp
+			copy(pubKComp[:], key)
+			var err error
+			pubK, err = pubKComp.Decompress()
+			if err != nil {
+				report.addCorrupted(index, CorruptionMapping,
+					fmt.Sprintf("can not decompress the PublicKey: %s", err))
+				return true
+			}
+			mappingKey = pubKComp[:]
+		}
+
+		indexAndWeight, err := rTx.Get(mappingKey)
+		if err == db.ErrKeyNotFound {
+			report.addCorrupted(index, CorruptionMapping, "key without weight")
+			return true
+		} else if err != nil {
+			iterErr = err
+			return false
+		}
+		keyIndex, weight, err := types.BytesToIndexAndWeight(indexAndWeight)
+		if err != nil {
+			report.addCorrupted(index, CorruptionMapping, err.Error())
+			return true
+		}
+		if keyIndex != index {
+			report.addCorrupted(index, CorruptionMapping,
+				fmt.Sprintf("key mapped to index %d", keyIndex))
+			return true
+		}
+
+		var value []byte
+		if c.keyType == types.KeyTypeEthAddress {
+			value, err = types.HashAddressBytes(addr, weight)
+		} else {
+			value, _, err = leafValue(rTx, pubK, weight)
+		}
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		values[index] = value
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if iterErr != nil {
+		return nil, iterErr
+	}
+	return values, nil
+}
+
+// auditNodes walks the stored MerkleTree from the given root, returning the
+// value of each leaf by its index. The nodes that are not stored under their
+// hash are added to the report, and their subtrees are still walked, so the
+// leafs below a corrupted intermediate node are also audited.
+func (c *Census) auditNodes(rTx db.ReadTx, root []byte, report *AuditReport) (
+	map[uint64][]byte, error) {
+	hashFunc := c.tree.HashFunction()
+	stored := make(map[uint64][]byte)
+	err := c.tree.IterateWithStopWithTx(treeRTx(rTx), root,
+		func(_ int, k, v []byte) bool {
+			switch v[0] {
+			case arbo.PrefixValueEmpty:
+				return false
+			case arbo.PrefixValueLeaf:
+				leafK, leafV := arbo.ReadLeafValue(v)
+				index := arbo.BytesToBigInt(leafK).Uint64()
+				report.NStoredLeafs++
+				stored[index] = append([]byte{}, leafV...)
+				h, err := hashFunc.Hash(leafK, leafV, []byte{arbo.PrefixValueLeaf})
+				if err != nil || !bytes.Equal(h, k) {
+					report.addCorrupted(index, CorruptionLeafHash, "")
+				}
+				return false
+			case arbo.PrefixValueIntermediate:
+				if len(v) != arbo.PrefixValueLen+2*hashFunc.Len() {
+					report.Incomplete = true
+					return true
+				}
+				l, r := arbo.ReadIntermediateChilds(v)
+				h, err := hashFunc.Hash(l, r)
+				if err != nil || !bytes.Equal(h, k) {
+					report.CorruptedNodes++
+				}
+			}
+			return false
+		})
+	if errors.Is(err, db.ErrKeyNotFound) || errors.Is(err, arbo.ErrInvalidValuePrefix) {
+		// a node referenced by its parent is missing, or its content is
+		// not a valid node, which stops the walk
+		report.Incomplete = true
+	} else if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
@@ -0,0 +1,172 @@
+package census
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/vocdoni/arbo"
+)
+
+func TestAudit(t *testing.T) {
+	c := qt.New(t)
+
+	census := newTestCensus(c)
+	report, err := census.Audit()
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Valid(), qt.IsTrue)
+	c.Assert(report.NLeafs, qt.Equals, uint64(0))
+
+	// an open Census can be audited
+	pubKs, weights := genPublicKeys(20)
+	_, err = census.AddPublicKeys(pubKs[:10], weights[:10])
+	c.Assert(err, qt.IsNil)
+	report, err = census.Audit()
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Valid(), qt.IsTrue)
+	c.Assert(report.NLeafs, qt.Equals, uint64(10))
+	c.Assert(report.NStoredLeafs, qt.Equals, uint64(10))
+
+	_, err = census.AddPublicKeys(pubKs[10:], weights[10:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	report, err = census.Audit()
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Valid(), qt.IsTrue)
+	c.Assert([]byte(report.Root), qt.DeepEquals, root)
+	c.Assert([]byte(report.ComputedRoot), qt.DeepEquals, root)
+	c.Assert(report.NLeafs, qt.Equals, uint64(20))
+	c.Assert(report.CorruptedLeafs, qt.HasLen, 0)
+}
+
+func TestAuditCorrupted(t *testing.T) {
+	c := qt.New(t)
+
+	census := newTestCensus(c)
+	pubKs, weights := genPublicKeys(8)
+	_, err := census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	// get a leaf node and an intermediate node of the MerkleTree
+	var leafK, leafV, interK, interV []byte
+	err = census.tree.IterateWithStop(root, func(_ int, k, v []byte) bool {
+		if v[0] == arbo.PrefixValueLeaf && leafK == nil {
+			leafK = append([]byte{}, k...)
+			leafV = append([]byte{}, v...)
+		}
+		if v[0] == arbo.PrefixValueIntermediate && interK == nil &&
+			!bytes.Equal(k, root) {
+			interK = append([]byte{}, k...)
+			interV = append([]byte{}, v...)
+		}
+		return false
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(leafK, qt.Not(qt.IsNil))
+	c.Assert(interK, qt.Not(qt.IsNil))
+	k, _ := arbo.ReadLeafValue(leafV)
+	leafIndex := arbo.BytesToBigInt(k).Uint64()
+
+	setNode := func(k, v []byte) {
+		wTx := census.db.WriteTx()
+		defer wTx.Discard()
+		c.Assert(wTx.Set(k, v), qt.IsNil)
+		c.Assert(wTx.Commit(), qt.IsNil)
+	}
+	audit := func() *AuditReport {
+		report, err := census.Audit()
+		c.Assert(err, qt.IsNil)
+		return report
+	}
+
+	// corrupt the value of the leaf, keeping its key
+	corrupted := append([]byte{}, leafV...)
+	corrupted[len(corrupted)-1] ^= 1
+	setNode(leafK, corrupted)
+	report := audit()
+	c.Assert(report.Valid(), qt.IsFalse)
+	c.Assert(report.CorruptedLeafs, qt.DeepEquals, []CorruptedLeaf{
+		{Index: leafIndex, Reason: CorruptionLeafHash},
+		{Index: leafIndex, Reason: CorruptionLeafValue},
+	})
+	// the root recomputed from the PublicKeys is still the stored one
+	c.Assert([]byte(report.ComputedRoot), qt.DeepEquals, root)
+	setNode(leafK, leafV)
+	c.Assert(audit().Valid(), qt.IsTrue)
+
+	// corrupt an intermediate node to point to a missing child
+	corrupted = append([]byte{}, interV...)
+	corrupted[len(corrupted)-1] ^= 1
+	setNode(interK, corrupted)
+	report = audit()
+	c.Assert(report.Valid(), qt.IsFalse)
+	c.Assert(report.CorruptedNodes, qt.Equals, 1)
+	c.Assert(report.Incomplete, qt.IsTrue)
+	setNode(interK, interV)
+	c.Assert(audit().Valid(), qt.IsTrue)
+
+	// corrupt the weight of a PublicKey, which is not part of the
+	// MerkleTree nodes
+	pubKComp := pubKs[3].Compress()
+	rTx := census.db.ReadTx()
+	indexAndWeight, err := rTx.Get(pubKComp[:])
+	rTx.Discard()
+	c.Assert(err, qt.IsNil)
+	setNode(pubKComp[:], types.IndexAndWeightToBytes(3, big.NewInt(42)))
+	report = audit()
+	c.Assert(report.Valid(), qt.IsFalse)
+	c.Assert(report.CorruptedLeafs, qt.DeepEquals, []CorruptedLeaf{
+		{Index: 3, Reason: CorruptionLeafValue},
+	})
+	c.Assert(report.ComputedRoot, qt.Not(qt.DeepEquals), report.Root)
+
+	// map the PublicKey to another index, leaving its leaf orphan
+	setNode(pubKComp[:], types.IndexAndWeightToBytes(5, weights[3]))
+	report = audit()
+	c.Assert(report.CorruptedLeafs, qt.DeepEquals, []CorruptedLeaf{
+		{Index: 3, Reason: CorruptionMapping, Detail: "key mapped to index 5"},
+	})
+	setNode(pubKComp[:], indexAndWeight)
+	c.Assert(audit().Valid(), qt.IsTrue)
+
+	// a PublicKey without its leaf
+	wTx := census.db.WriteTx()
+	c.Assert(wTx.Set(dbKeyIndexPubK(8), pubKComp[:]), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	report = audit()
+	c.Assert(report.CorruptedLeafs, qt.DeepEquals, []CorruptedLeaf{
+		{Index: 8, Reason: CorruptionMapping, Detail: "key mapped to index 3"},
+	})
+}
+
+func TestAuditAddresses(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c), KeyType: types.KeyTypeEthAddress})
+	c.Assert(err, qt.IsNil)
+	addrs, weights := genAddresses(10)
+	_, err = census.AddAddresses(addrs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	report, err := census.Audit()
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Valid(), qt.IsTrue)
+	c.Assert(report.NLeafs, qt.Equals, uint64(10))
+
+	wTx := census.db.WriteTx()
+	c.Assert(wTx.Set(dbKeyAddress(addrs[2]),
+		types.IndexAndWeightToBytes(2, big.NewInt(42))), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	report, err = census.Audit()
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.CorruptedLeafs, qt.DeepEquals, []CorruptedLeaf{
+		{Index: 2, Reason: CorruptionLeafValue},
+	})
+}
@@ -2,10 +2,16 @@ package census
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/aragon/ovote-node/types"
 	"github.com/iden3/go-iden3-crypto/babyjub"
@@ -16,6 +22,13 @@ import (
 var (
 	dbKeyNextIndex    = []byte("nextIndex")
 	dbKeyCensusClosed = []byte("censusClosed")
+	dbKeyClosedAt     = []byte("closedAt")
+	dbKeyCreatedAt    = []byte("createdAt")
+	dbKeyDigest       = []byte("digest")
+	// dbPrefixReservedIndex is used to mark the indexes that have been
+	// explicitly assigned through AddPublicKeysAtIndices, so the
+	// incremental assignment of indexes skips them
+	dbPrefixReservedIndex = []byte("reservedIndex")
 )
 
 var (
@@ -25,11 +38,43 @@ var (
 	// ErrCensusClosed is used when trying to add keys to a census and the census
 	// is already closed
 	ErrCensusClosed = errors.New("Census closed, can not add more keys")
+	// ErrCensusAlreadyClosed is used when trying to close a Census that is
+	// already closed
+	ErrCensusAlreadyClosed = errors.New("Census already closed")
 	// ErrMaxNLeafsReached is used when trying to add a number of new publicKeys
-	// which would exceed the maximum number of keys in the census.
-	ErrMaxNLeafsReached = fmt.Errorf("MaxNLeafs (%d) reached", types.MaxNLeafs)
+	// which would exceed the maximum number of keys in the census, given by
+	// its MaxLevels.
+	ErrMaxNLeafsReached = errors.New("Census MaxNLeafs reached")
+	// ErrPubKsWeightsLen is used when the number of given publicKeys does
+	// not match the number of given weights
+	ErrPubKsWeightsLen = errors.New("number of PublicKeys and weights mismatch")
 )
 
+// PartialBatchError is returned when adding a batch of keys in chunks fails
+// after some chunks of the batch have been committed, which are kept in the
+// Census
+type PartialBatchError struct {
+	// NAdded is the number of keys of the batch added to the Census
+	NAdded int
+	// Position is the position in the batch of the first key of the
+	// chunk that failed, none of the keys from Position onwards has been
+	// added
+	Position int
+	// Err is the error of the chunk that failed
+	Err error
+}
+
+// Error implements the error interface
+func (e *PartialBatchError) Error() string {
+	return fmt.Sprintf("%s, %d keys of the batch added, stopped at position %d",
+		e.Err, e.NAdded, e.Position)
+}
+
+// Unwrap returns the error of the chunk that failed
+func (e *PartialBatchError) Unwrap() error {
+	return e.Err
+}
+
 // Info contains metadata about a Census
 type Info struct {
 	// ErrMsg contains the stored error message stored for the last
@@ -38,57 +83,252 @@ type Info struct {
 	Size   uint64 `json:"size"`
 	Closed bool   `json:"closed"`
 	Root   []byte `json:"root,omitempty"`
+	// KeyType contains the type of the keys of the leafs of the Census
+	KeyType types.KeyType `json:"keyType,omitempty"`
+	// State contains the current lifecycle State of the Census
+	State State `json:"state"`
+	// Anchor contains the external transaction where the CensusRoot has
+	// been published, if any
+	Anchor *Anchor `json:"anchor,omitempty"`
+	// VotingDeadline contains the time after which the votes are not
+	// accepted, if any
+	VotingDeadline *time.Time `json:"votingDeadline,omitempty"`
+	// VotingOpen indicates if the votes are currently accepted, which is
+	// the case until the VotingDeadline is reached
+	VotingOpen bool `json:"votingOpen"`
+	// KeySetHash contains the hash of the set of PublicKeys committed when
+	// sealing the Census, if it has been sealed
+	KeySetHash []byte `json:"keySetHash,omitempty"`
+	// Archived is set to true when the Census has been archived by the
+	// CensusBuilder
+	Archived bool `json:"archived,omitempty"`
+	// Label contains the human-readable label set for the Census in the
+	// CensusBuilder, if any
+	Label string `json:"label,omitempty"`
+	// ExternalID contains the identifier supplied by the integrator when
+	// creating the Census in the CensusBuilder, if any
+	ExternalID string `json:"externalID,omitempty"`
+	// CreatedAt contains the time when the Census was created, nil for
+	// the Censuses created before the creation time was stored
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	// ClosedAt contains the time when the Census was closed, nil if it is
+	// not closed or it was closed before the closing time was stored
+	ClosedAt *time.Time `json:"closedAt,omitempty"`
+	// ExpiresAt contains the expiration time of the Census set in the
+	// CensusBuilder, nil if the Census does not expire
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Progress contains the progress of the PublicKeys added
+	// asynchronously, if any
+	Progress *Progress `json:"progress,omitempty"`
+	// Stats contains the Stats of the Census, set by the CensusBuilder
+	// for the Censuses that are not archived
+	Stats *Stats `json:"stats,omitempty"`
 }
 
+// DefaultChunkSize defines the default number of PublicKeys that are added to
+// the Census in a single db.WriteTx
+const DefaultChunkSize = 10000
+
 // Census contains the MerkleTree with the PublicKeys
 type Census struct {
-	tree *arbo.Tree
-	db   db.Database
+	tree      *arbo.Tree
+	db        db.Database
+	chunkSize int
+	maxLevels int
+	// treeConfig is the arbo.Config of the MerkleTree, used to build it
+	// again in a fresh arbo tree
+	treeConfig arbo.Config
+	// sortKeys determines if the indexes of the PublicKeys are assigned
+	// sorting the PublicKeys when closing the Census
+	sortKeys bool
+	// keyType defines the type of the keys of the leafs of the Census
+	keyType types.KeyType
+	// writeMu serializes the operations that modify the Census, as the
+	// indexes are assigned reading and updating the nextIndex
+	writeMu sync.Mutex
+	// progressMu serializes the updates of the Progress, which are done
+	// concurrently with the additions of PublicKeys
+	progressMu sync.Mutex
+	// now is used to get the current time when checking the
+	// VotingDeadline and when creating and closing the Census
+	now func() time.Time
+	// checkpointInterval is the number of chunks added by AddPublicKeys
+	// between two Checkpoints, and nChunksSinceCheckpoint the number of
+	// chunks added since the last one
+	checkpointInterval     int
+	nChunksSinceCheckpoint int
+	// hashWorkers is the number of goroutines used to check and hash the
+	// keys of each chunk before adding them to the MerkleTree
+	hashWorkers int
 }
 
+// Parameters contains the parameters of the Census MerkleTree, needed by the
+// clients to build and verify the MerkleProofs
+type Parameters = types.CensusParameters
+
 // Options is used to pass the parameters to load a new Census
 type Options struct {
 	// DB defines the database that will be used for the census
 	DB db.Database
+	// InMemory defines if the Census is kept in memory instead of in the
+	// DB, which must not be set. The Census is lost when its db is
+	// closed, so it is intended for short-lived Censuses and tests.
+	InMemory bool
+	// ChunkSize defines the maximum number of PublicKeys added in a
+	// single db.WriteTx by AddPublicKeys. If not set, DefaultChunkSize is
+	// used.
+	ChunkSize int
+	// SortKeys defines if the PublicKeys added to the Census are buffered
+	// until the Census is closed, when their indexes are assigned
+	// following the order of their compressed bytes, so the CensusRoot
+	// only depends on the set of PublicKeys. It can only be enabled for a
+	// Census without PublicKeys, and once enabled it is stored in the db.
+	SortKeys bool
+	// Now defines the clock used to check the VotingDeadline of the
+	// Census and to timestamp its creation and closing. If not set,
+	// time.Now is used.
+	Now func() time.Time
+	// CheckpointInterval defines the number of chunks added by
+	// AddPublicKeys between two Checkpoints, which are also stored when
+	// each AddPublicKeys call ends. If not set, DefaultCheckpointInterval
+	// is used.
+	CheckpointInterval int
+	// MaxLevels defines the number of levels of the MerkleTree, which
+	// limits the number of PublicKeys of the Census to 2^MaxLevels. If not
+	// set, DefaultMaxLevels is used. It is stored in the db when creating
+	// the Census, and can not be changed later.
+	MaxLevels int
+	// HashFunction defines the hash function of the MerkleTree, which can
+	// be arbo.HashFunctionPoseidon, types.HashFunctionMiMC7 or
+	// arbo.HashFunctionBlake2b, depending on the circuit that verifies
+	// the CensusProofs (see CheckHashFunction). If not set,
+	// DefaultHashFunction is used. As MaxLevels, it is stored in the db
+	// when creating the Census.
+	HashFunction arbo.HashFunction
+	// KeyType defines the type of the keys of the leafs of the Census,
+	// the PublicKeys added by AddPublicKeys for KeyTypeBabyJubJub (the
+	// default), or the Ethereum addresses added by AddAddresses for
+	// KeyTypeEthAddress. It can only be set for a Census without keys,
+	// and once set it is stored in the db.
+	KeyType types.KeyType
+	// HashWorkers defines the number of goroutines used to check and to
+	// compute the leaf values of the keys of each chunk added by
+	// AddPublicKeys and AddAddresses, before adding them to the
+	// MerkleTree, whose nodes are hashed in parallel by arbo AddBatch. If
+	// not set, runtime.NumCPU() is used.
+	HashWorkers int
+	// ThresholdNLeafs defines the number of leafs of the MerkleTree up to
+	// which arbo AddBatch builds the added chunk in memory, hashing its
+	// subtrees in parallel, instead of adding the keys to the tree in
+	// disk. Raising it speeds up the imports of large Censuses at the
+	// cost of memory. If not set, arbo.DefaultThresholdNLeafs is used.
+	ThresholdNLeafs int
 }
 
 // New loads the census
 func New(opts Options) (*Census, error) {
-	arboConfig := arbo.Config{
-		Database:     opts.DB,
-		MaxLevels:    types.MaxLevels,
-		HashFunction: arbo.HashFunctionPoseidon,
-		// ThresholdNLeafs: not specified, use the default
-	}
-
 	// TODO benchmark concurrent usage to determine wether to do the
 	// approach of creating a new db dir for each Census, or to use the
 	// same db for all the Censuses using a different db prefix for each
 	// Census.
+	if opts.InMemory {
+		if opts.DB != nil {
+			return nil, fmt.Errorf("the InMemory option can not be used" +
+				" together with a DB")
+		}
+		opts.DB = newMemDB()
+	}
 	wTx := opts.DB.WriteTx()
 	defer wTx.Discard()
 
-	tree, err := arbo.NewTreeWithTx(wTx, arboConfig)
+	maxLevels, hashFunc, err := initTreeParams(wTx, opts.MaxLevels, opts.HashFunction)
 	if err != nil {
 		return nil, err
 	}
+	arboConfig := arbo.Config{
+		Database:     &treeDB{db: opts.DB},
+		MaxLevels:    maxLevels,
+		HashFunction: hashFunc,
+		// if not set, arbo uses its default
+		ThresholdNLeafs: opts.ThresholdNLeafs,
+	}
+
+	tree, err := arbo.NewTreeWithTx(treeTx(wTx), arboConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	checkpointInterval := opts.CheckpointInterval
+	if checkpointInterval <= 0 {
+		checkpointInterval = DefaultCheckpointInterval
+	}
+
+	hashWorkers := opts.HashWorkers
+	if hashWorkers <= 0 {
+		hashWorkers = runtime.NumCPU()
+	}
 
 	c := &Census{
-		tree: tree,
-		db:   opts.DB,
+		tree:      tree,
+		db:        opts.DB,
+		chunkSize: chunkSize,
+		maxLevels: arboConfig.MaxLevels,
+		now:       now,
+
+		treeConfig:         arboConfig,
+		checkpointInterval: checkpointInterval,
+		hashWorkers:        hashWorkers,
 	}
 
-	// if nextIndex is not set in the db, initialize it to 0
+	// if nextIndex is not set in the db, initialize it to 0, together
+	// with the Checkpoint of the empty MerkleTree
 	_, err = c.getNextIndex(wTx)
 	if err != nil {
 		err = c.setNextIndex(wTx, 0)
 		if err != nil {
 			return nil, err
 		}
+		if err := c.storeCheckpoint(wTx); err != nil {
+			return nil, err
+		}
+		if err := setTime(wTx, dbKeyCreatedAt, now()); err != nil {
+			return nil, err
+		}
+	}
+
+	// if censusClosed is not set in the db, initialize it to false, so
+	// an already closed Census remains closed when loaded again
+	_, err = wTx.Get(dbKeyCensusClosed)
+	if err == db.ErrKeyNotFound {
+		if err := wTx.Set(dbKeyCensusClosed, []byte{0}); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
 	}
 
-	// store editable=true if
-	if err := wTx.Set(dbKeyCensusClosed, []byte{0}); err != nil {
+	c.sortKeys, err = c.initSortKeys(wTx, opts.SortKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	c.keyType, err = c.initKeyType(wTx, opts.KeyType)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, err := c.initIndexPubK(wTx)
+	if err != nil {
 		return nil, err
 	}
 
@@ -97,6 +337,14 @@ func New(opts Options) (*Census, error) {
 		return nil, err
 	}
 
+	if !migrated {
+		// the Census was created before the Index->PublicKey mapping
+		// existed
+		if err := c.migrateIndexPubK(); err != nil {
+			return nil, err
+		}
+	}
+
 	return c, nil
 }
 
@@ -118,11 +366,118 @@ func (c *Census) getNextIndex(rTx db.ReadTx) (uint64, error) {
 	return nextIndex, nil
 }
 
-// Size returns the number of PublicKeys added to the Census.
+// Size returns the number of PublicKeys added to the Census, including the
+// PublicKeys buffered until the Census is closed when SortKeys is enabled.
 func (c *Census) Size() (uint64, error) {
 	rTx := c.db.ReadTx()
 	defer rTx.Discard()
-	return c.getNextIndex(rTx)
+	nLeafs, err := c.tree.GetNLeafsWithTx(treeRTx(rTx))
+	if err != nil {
+		return 0, err
+	}
+	nPending, err := c.getNPendingKeys(rTx)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(nLeafs) + nPending, nil
+}
+
+func dbKeyReservedIndex(index uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, index)
+	return append(append([]byte{}, dbPrefixReservedIndex...), b...)
+}
+
+// isIndexReserved returns true if the given index has been explicitly
+// assigned through AddPublicKeysAtIndices
+func (c *Census) isIndexReserved(rTx db.ReadTx, index uint64) (bool, error) {
+	_, err := rTx.Get(dbKeyReservedIndex(index))
+	if err == db.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NextLeafIndex returns the index that would be assigned to the next
+// PublicKey added with AddPublicKeys, skipping the indexes explicitly assigned
+// by AddPublicKeysAtIndices. For a Census with SortKeys, the buffered
+// PublicKeys are counted, as they get their indexes when closing the Census.
+// Returns ErrCensusClosed if the Census is closed.
+func (c *Census) NextLeafIndex() (uint64, error) {
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return 0, err
+	}
+	if isClosed {
+		return 0, ErrCensusClosed
+	}
+
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	index, err := c.getNextIndex(rTx)
+	if err != nil {
+		return 0, err
+	}
+	nPending, err := c.getNPendingKeys(rTx)
+	if err != nil {
+		return 0, err
+	}
+	index += nPending
+	for {
+		reserved, err := c.isIndexReserved(rTx, index)
+		if err != nil {
+			return 0, err
+		}
+		if !reserved {
+			return index, nil
+		}
+		index++
+	}
+}
+
+// checkMaxNLeafs returns ErrMaxNLeafsReached if assigning incremental indexes
+// to nKeys new keys, skipping the indexes explicitly assigned by
+// AddPublicKeysAtIndices, would assign an index out of the MerkleTree. The
+// indexes are assigned from the next index, which can be higher than the Size
+// of the Census after removing PublicKeys or adding them at explicit indexes.
+func (c *Census) checkMaxNLeafs(nKeys int) error {
+	if nKeys == 0 {
+		return nil
+	}
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	nextIndex, err := c.getNextIndex(rTx)
+	if err != nil {
+		return err
+	}
+	nPending, err := c.getNPendingKeys(rTx)
+	if err != nil {
+		return err
+	}
+	nextIndex += nPending
+
+	var reserved []uint64
+	err = c.db.Iterate(dbPrefixReservedIndex, func(k, _ []byte) bool {
+		if index := binary.LittleEndian.Uint64(k); index >= nextIndex {
+			reserved = append(reserved, index)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(reserved, func(i, j int) bool { return reserved[i] < reserved[j] })
+	last := nextIndex + uint64(nKeys) - 1
+	for i := 0; i < len(reserved) && reserved[i] <= last; i++ {
+		last++
+	}
+	if last >= c.maxNLeafs() {
+		return fmt.Errorf("%s (%d), next index: %d, trying to add %d keys",
+			ErrMaxNLeafsReached, c.maxNLeafs(), nextIndex, nKeys)
+	}
+	return nil
 }
 
 var dbKeyErrMsg = []byte("errmsg")
@@ -156,20 +511,120 @@ func (c *Census) GetErrMsg() (string, error) {
 	return string(b), nil
 }
 
+var dbKeyAnchor = []byte("anchor")
+
+// ErrAnchorExists is used when trying to set the Anchor of a Census that
+// already has one, without overwriting it
+var ErrAnchorExists = errors.New("Census already anchored")
+
+// Anchor contains the data of the external transaction where the CensusRoot
+// has been published
+type Anchor struct {
+	TxHash  []byte `json:"txHash"`
+	ChainID uint64 `json:"chainID"`
+}
+
+// SetAnchor stores the given Anchor of the closed Census. If the Census
+// already has an Anchor, ErrAnchorExists is returned, unless overwrite is set.
+func (c *Census) SetAnchor(anchor Anchor, overwrite bool) error {
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return err
+	}
+	if !isClosed {
+		return ErrCensusNotClosed
+	}
+	if len(anchor.TxHash) == 0 {
+		return fmt.Errorf("Anchor TxHash can not be empty")
+	}
+	// hold the writeMu, so two concurrent calls can not both see that
+	// there is no Anchor yet
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	_, err = wTx.Get(dbKeyAnchor)
+	if err == nil && !overwrite {
+		return ErrAnchorExists
+	} else if err != nil && err != db.ErrKeyNotFound {
+		return err
+	}
+	b, err := json.Marshal(anchor)
+	if err != nil {
+		return err
+	}
+	if err := wTx.Set(dbKeyAnchor, b); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
+
+// GetAnchor returns the Anchor of the Census, or nil if the Census has no
+// Anchor
+func (c *Census) GetAnchor() (*Anchor, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	b, err := rTx.Get(dbKeyAnchor)
+	if err == db.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var anchor Anchor
+	if err := json.Unmarshal(b, &anchor); err != nil {
+		return nil, err
+	}
+	return &anchor, nil
+}
+
 // Close closes the census
 func (c *Census) Close() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
 	isClosed, err := c.IsClosed()
 	if err != nil {
 		return err
 	}
 	if isClosed {
-		return fmt.Errorf("Census already closed")
+		return ErrCensusAlreadyClosed
+	}
+	if c.sortKeys {
+		rTx := c.db.ReadTx()
+		nPending, err := c.getNPendingKeys(rTx)
+		rTx.Discard()
+		if err != nil {
+			return err
+		}
+		// assign the indexes of the buffered PublicKeys
+		if err := c.flushPendingPublicKeys(); err != nil {
+			return err
+		}
+		if nPending != 0 {
+			if err := c.recordRoot(int(nPending)); err != nil {
+				return err
+			}
+		}
 	}
 	wTx := c.db.WriteTx()
 	defer wTx.Discard()
+	state, err := c.getState(wTx)
+	if err != nil {
+		return err
+	}
+	if err := checkStateTransition(state, StateClosed); err != nil {
+		return err
+	}
 	if err := wTx.Set(dbKeyCensusClosed, []byte{1}); err != nil {
 		return err
 	}
+	if err := setTime(wTx, dbKeyClosedAt, c.now()); err != nil {
+		return err
+	}
+	if err := c.setState(wTx, StateClosed); err != nil {
+		return err
+	}
 	// commit the db.WriteTx
 	if err := wTx.Commit(); err != nil {
 		return err
@@ -178,6 +633,18 @@ func (c *Census) Close() error {
 	return nil
 }
 
+// CloseDB closes the database of the Census. The Census can not be used after
+// calling this method.
+func (c *Census) CloseDB() error {
+	return c.db.Close()
+}
+
+// SortKeys returns true if the indexes of the PublicKeys of the Census are
+// assigned sorting the PublicKeys when closing the Census
+func (c *Census) SortKeys() bool {
+	return c.sortKeys
+}
+
 // IsClosed returns true if the census is closed, and false if the census is
 // still open
 func (c *Census) IsClosed() (bool, error) {
@@ -192,6 +659,47 @@ func (c *Census) IsClosed() (bool, error) {
 	return bytes.Equal(b, []byte{1}), nil
 }
 
+// ClosedAt returns the time when the Census was closed, or a zero time if the
+// Census is not closed or it was closed before the time was stored
+func (c *Census) ClosedAt() (time.Time, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	return getTime(rTx, dbKeyClosedAt)
+}
+
+// CreatedAt returns the time when the Census was created, or a zero time if
+// the Census was created before the time was stored
+func (c *Census) CreatedAt() (time.Time, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	return getTime(rTx, dbKeyCreatedAt)
+}
+
+// setTime stores the given time in UTC under the given db key
+func setTime(wTx db.WriteTx, key []byte, t time.Time) error {
+	b, err := t.UTC().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return wTx.Set(key, b)
+}
+
+// getTime returns the time stored under the given db key, or a zero time if
+// the key is not stored
+func getTime(rTx db.ReadTx, key []byte) (time.Time, error) {
+	var t time.Time
+	b, err := rTx.Get(key)
+	if err == db.ErrKeyNotFound {
+		return t, nil
+	} else if err != nil {
+		return t, err
+	}
+	if err := t.UnmarshalBinary(b); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
 // Root returns the CensusRoot if the Census is closed.
 func (c *Census) Root() ([]byte, error) {
 	isClosed, err := c.IsClosed()
@@ -236,52 +744,461 @@ func (c *Census) Info() (*Info, error) {
 		}
 	}
 
+	state, err := c.State()
+	if err != nil {
+		return nil, err
+	}
+
+	anchor, err := c.GetAnchor()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, err := c.VotingDeadline()
+	if err != nil {
+		return nil, err
+	}
+
+	keySetHash, err := c.KeySetHash()
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := c.CreatedAt()
+	if err != nil {
+		return nil, err
+	}
+
+	closedAt, err := c.ClosedAt()
+	if err != nil {
+		return nil, err
+	}
+
+	progress, err := c.Progress()
+	if err != nil {
+		return nil, err
+	}
+
 	ci := &Info{
-		ErrMsg: errMsg,
-		Size:   size,
-		Closed: isClosed,
-		Root:   root,
+		ErrMsg:     errMsg,
+		Size:       size,
+		Closed:     isClosed,
+		Root:       root,
+		KeyType:    c.keyType,
+		State:      state,
+		Anchor:     anchor,
+		KeySetHash: keySetHash,
+		Progress:   progress,
+		// votes are accepted until the deadline, included
+		VotingOpen: deadline.IsZero() || !c.now().After(deadline),
+	}
+	if !deadline.IsZero() {
+		ci.VotingDeadline = &deadline
+	}
+	if !createdAt.IsZero() {
+		ci.CreatedAt = &createdAt
+	}
+	if !closedAt.IsZero() {
+		ci.ClosedAt = &closedAt
 	}
 
 	return ci, nil
 }
 
+// Parameters returns the parameters of the Census MerkleTree
+func (c *Census) Parameters() Parameters {
+	return Parameters{
+		Arity:        2, //nolint:gomnd // arbo is a binary tree
+		HashFunction: string(c.tree.HashFunction().Type()),
+		MaxLevels:    c.maxLevels,
+		MaxNLeafs:    c.maxNLeafs(),
+	}
+}
+
+// leafs returns the key-values of all the leafs of the Census MerkleTree,
+// sorted by key
+func (c *Census) leafs() ([][]byte, [][]byte, error) {
+	var keys, values [][]byte
+	err := c.tree.Iterate(nil, func(_, v []byte) {
+		if v[0] != arbo.PrefixValueLeaf {
+			return
+		}
+		leafK, leafV := arbo.ReadLeafValue(v)
+		keys = append(keys, append([]byte{}, leafK...))
+		values = append(values, append([]byte{}, leafV...))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Sort(byKey{keys, values})
+	return keys, values, nil
+}
+
+// PublicKeys returns all the PublicKeys of the Census, with their index and
+// weight, sorted by index. The PublicKeys are read from the
+// PublicKey->Index,Weight mapping, and each one is checked against its leaf in
+// the Census MerkleTree.
+func (c *Census) PublicKeys() ([]IndexedPublicKey, error) {
+	keys, err := c.indexedPublicKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.sortKeys {
+		// the PublicKeys buffered until the Census is closed, with
+		// the indexes that they will get once the Census is closed
+		pendingPubKs, pendingWeights, err := c.pendingPublicKeys()
+		if err != nil {
+			return nil, err
+		}
+		nextIndex := uint64(len(keys))
+		for i := 0; i < len(pendingPubKs); i++ {
+			keys = append(keys, IndexedPublicKey{
+				Index:     nextIndex + uint64(i),
+				PublicKey: pendingPubKs[i],
+				Weight:    pendingWeights[i],
+			})
+		}
+	}
+	return keys, nil
+}
+
+// indexedPublicKeys returns the PublicKeys of the Census MerkleTree, with
+// their index and weight, sorted by index
+func (c *Census) indexedPublicKeys() ([]IndexedPublicKey, error) {
+	// the mapping is stored in the same db than the MerkleTree nodes,
+	// where the MerkleTree keys are also of 32 bytes, but the MerkleTree
+	// values have a different length than the Index,Weight bytes
+	var pubKsComp [][]byte
+	var indexesAndWeights [][]byte
+	err := c.db.Iterate(nil, func(k, v []byte) bool {
+		if len(k) != len(babyjub.PublicKeyComp{}) || len(v) != 8+32 { //nolint:gomnd
+			return true
+		}
+		pubKsComp = append(pubKsComp, append([]byte{}, k...))
+		indexesAndWeights = append(indexesAndWeights, append([]byte{}, v...))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	var keys []IndexedPublicKey
+	for i := 0; i < len(pubKsComp); i++ {
+		var pubKComp babyjub.PublicKeyComp
+		copy(pubKComp[:], pubKsComp[i])
+		pubK, err := pubKComp.Decompress()
+		if err != nil {
+			// not a PublicKey
+			continue
+		}
+		index, weight, err := types.BytesToIndexAndWeight(indexesAndWeights[i])
+		if err != nil {
+			return nil, err
+		}
+		_, leafV, err := c.tree.GetWithTx(treeRTx(rTx), c.indexKey(index))
+		if err == arbo.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		hashPubKBytes, data, err := leafValue(rTx, pubK, weight)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(leafV, hashPubKBytes) {
+			continue
+		}
+		keys = append(keys, IndexedPublicKey{
+			Index:     index,
+			PublicKey: *pubK,
+			Weight:    weight,
+			Data:      data,
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Index < keys[j].Index })
+	return keys, nil
+}
+
+// byKey implements sort.Interface to sort the leafs key-values by key
+type byKey struct {
+	keys, values [][]byte
+}
+
+func (l byKey) Len() int { return len(l.keys) }
+func (l byKey) Less(i, j int) bool {
+	return bytes.Compare(l.keys[i], l.keys[j]) < 0
+}
+func (l byKey) Swap(i, j int) {
+	l.keys[i], l.keys[j] = l.keys[j], l.keys[i]
+	l.values[i], l.values[j] = l.values[j], l.values[i]
+}
+
+// Digest returns a deterministic hash of the closed Census, computed over the
+// Census MerkleTree parameters, the CensusRoot and all the leafs sorted by
+// key. Two Censuses built from the same inputs will have the same Digest. Once
+// computed, the Digest is stored in the db, so next calls do not need to
+// compute it again.
+func (c *Census) Digest() ([]byte, error) {
+	root, err := c.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	rTx := c.db.ReadTx()
+	digest, err := rTx.Get(dbKeyDigest)
+	rTx.Discard()
+	if err == nil {
+		return digest, nil
+	} else if err != db.ErrKeyNotFound {
+		return nil, err
+	}
+
+	keys, values, err := c.leafs()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	maxLevels := make([]byte, 8)
+	binary.LittleEndian.PutUint64(maxLevels, uint64(c.maxLevels))
+	_, _ = h.Write(maxLevels)
+	_, _ = h.Write(c.tree.HashFunction().Type())
+	_, _ = h.Write(root)
+	for i := 0; i < len(keys); i++ {
+		_, _ = h.Write(keys[i])
+		_, _ = h.Write(values[i])
+	}
+	digest = h.Sum(nil)
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	if err := wTx.Set(dbKeyDigest, digest); err != nil {
+		return nil, err
+	}
+	if err := wTx.Commit(); err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
 // AddPublicKeys adds the batch of given PublicKeys, assigning incremental
-// indexes to each one.
+// indexes to each one. The keys are added in chunks of Options.ChunkSize keys,
+// each chunk is added atomically: all the changes of the chunk are done in a
+// single db.WriteTx which is only committed if all the keys of the chunk have
+// been added, otherwise it is discarded and the Census remains unchanged for
+// that chunk. Chunks containing invalid keys are skipped, and the rest of
+// chunks keep being processed, so all the invalid keys are returned, with
+// their Index being the position in the given pubKs array. Before adding any
+// chunk, the whole batch is checked, and if any PublicKey is not on the curve,
+// appears more than once in the batch, or is already in the Census, no
+// PublicKey is added, and the InvalidKeys are returned with their
+// InvalidReason. If a chunk fails for other reasons after previous chunks have
+// been committed, the committed chunks are kept and a PartialBatchError is
+// returned.
 func (c *Census) AddPublicKeys(pubKs []babyjub.PublicKey,
-	weights []*big.Int) ([]arbo.Invalid, error) {
-	isClosed, err := c.IsClosed()
+	weights []*big.Int) ([]InvalidKey, error) {
+	return c.addPublicKeys(pubKs, weights, nil)
+}
+
+// addPublicKeys implements AddPublicKeys and AddMembers, datas contains the
+// leaf data of each PublicKey, or is nil when the PublicKeys have no leaf
+// data
+func (c *Census) addPublicKeys(pubKs []babyjub.PublicKey, weights []*big.Int,
+	datas [][]byte) ([]InvalidKey, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.checkCanAddPublicKeys(pubKs, weights); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkMaxNLeafs(len(pubKs)); err != nil {
+		return nil, err
+	}
+
+	// check the whole batch before adding any PublicKey
+	invalids, err := c.checkPublicKeys(pubKs)
 	if err != nil {
 		return nil, err
 	}
+	if len(invalids) != 0 {
+		return invalids, fmt.Errorf("Can not add %d PublicKeys", len(invalids))
+	}
+
+	nAdded := 0
+	var batchErr error
+	for from := 0; from < len(pubKs); from += c.chunkSize {
+		to := from + c.chunkSize
+		if to > len(pubKs) {
+			to = len(pubKs)
+		}
+		var chunkInvalids []InvalidKey
+		var err error
+		if c.sortKeys {
+			chunkInvalids, err = c.bufferPublicKeysChunk(pubKs[from:to],
+				weights[from:to])
+		} else {
+			var chunkDatas [][]byte
+			if datas != nil {
+				chunkDatas = datas[from:to]
+			}
+			chunkInvalids, err = c.addPublicKeysChunk(pubKs[from:to],
+				weights[from:to], chunkDatas)
+		}
+		for i := 0; i < len(chunkInvalids); i++ {
+			chunkInvalids[i].Index += from
+		}
+		invalids = append(invalids, chunkInvalids...)
+		if err != nil && len(chunkInvalids) == 0 {
+			batchErr = partialBatchError(err, nAdded, from)
+			break
+		}
+		if err == nil {
+			nAdded += to - from
+		}
+	}
+	// the committed chunks are kept even if a later chunk fails
+	if c.nChunksSinceCheckpoint != 0 {
+		if err := c.checkpoint(); err != nil {
+			return invalids, err
+		}
+	}
+	// the buffered PublicKeys are recorded when closing the Census
+	if !c.sortKeys && nAdded != 0 {
+		if err := c.recordRoot(nAdded); err != nil {
+			return invalids, err
+		}
+	}
+	if batchErr != nil {
+		return invalids, batchErr
+	}
+	if len(invalids) != 0 {
+		return invalids, fmt.Errorf("Can not add %d PublicKeys", len(invalids))
+	}
+	return nil, nil
+}
+
+// partialBatchError returns the given error of the chunk starting at the given
+// position of a batch as a PartialBatchError, unless it is the first chunk,
+// in which case the batch has not changed the Census
+func partialBatchError(err error, nAdded, position int) error {
+	if position == 0 {
+		return err
+	}
+	return &PartialBatchError{NAdded: nAdded, Position: position, Err: err}
+}
+
+// checkCanAddPublicKeys checks that the Census accepts new PublicKeys, and
+// that there is a non-nil weight for each one of the given PublicKeys
+func (c *Census) checkCanAddPublicKeys(pubKs []babyjub.PublicKey,
+	weights []*big.Int) error {
+	if err := c.checkKeyType(types.KeyTypeBabyJubJub); err != nil {
+		return err
+	}
+	return c.checkCanAddKeys(len(pubKs), weights)
+}
+
+// checkCanAddKeys checks that the Census accepts nKeys new keys, and that
+// there is a non-nil weight for each one of them
+func (c *Census) checkCanAddKeys(nKeys int, weights []*big.Int) error {
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return err
+	}
 	if isClosed {
-		return nil, ErrCensusClosed
+		return ErrCensusClosed
+	}
+	isSealed, err := c.IsSealed()
+	if err != nil {
+		return err
+	}
+	if isSealed {
+		return ErrCensusSealed
+	}
+	if nKeys != len(weights) {
+		return fmt.Errorf("%s, len(pubKs): %d, len(weights): %d",
+			ErrPubKsWeightsLen, nKeys, len(weights))
+	}
+	for i := 0; i < len(weights); i++ {
+		if weights[i] == nil {
+			return fmt.Errorf("weight for key %d is nil", i)
+		}
 	}
+	return nil
+}
+
+// addPublicKeysChunk adds the given PublicKeys in a single db.WriteTx, which
+// is only committed if all the keys are added. Every checkpointInterval
+// chunks, the Checkpoint is stored in the same db.WriteTx.
+func (c *Census) addPublicKeysChunk(pubKs []babyjub.PublicKey,
+	weights []*big.Int, datas [][]byte) ([]InvalidKey, error) {
 	wTx := c.db.WriteTx()
 	defer wTx.Discard()
 
-	nextIndex, err := c.getNextIndex(wTx)
+	invalids, err := c.addPublicKeysWithTx(wTx, pubKs, weights, datas)
 	if err != nil {
+		return invalids, err
+	}
+	checkpoint := c.nChunksSinceCheckpoint+1 >= c.checkpointInterval
+	if checkpoint {
+		if err := c.storeCheckpoint(wTx); err != nil {
+			return nil, err
+		}
+	}
+
+	// commit the db.WriteTx, only reached if all the keys have been
+	// successfully added
+	if err := wTx.Commit(); err != nil {
 		return nil, err
 	}
+	if checkpoint {
+		c.nChunksSinceCheckpoint = 0
+	} else {
+		c.nChunksSinceCheckpoint++
+	}
+
+	return nil, nil
+}
 
-	if nextIndex+uint64(len(pubKs)) > types.MaxNLeafs {
-		return nil, fmt.Errorf("%s, current index: %d, trying to add %d keys",
-			ErrMaxNLeafsReached, nextIndex, len(pubKs))
+// addPublicKeysWithTx adds the given PublicKeys using the given db.WriteTx,
+// assigning them incremental indexes. If datas is not nil, it contains the
+// leaf data of each PublicKey.
+func (c *Census) addPublicKeysWithTx(wTx db.WriteTx, pubKs []babyjub.PublicKey,
+	weights []*big.Int, datas [][]byte) ([]InvalidKey, error) {
+	nextIndex, err := c.getNextIndex(wTx)
+	if err != nil {
+		return nil, err
 	}
+
 	var indexes [][]byte
-	var pubKHashes [][]byte
+	index := nextIndex
 	for i := 0; i < len(pubKs); i++ {
 		// overflow in index should not be possible, as previously the
 		// number of keys being added is already checked
 
-		index := nextIndex + uint64(i)
+		// skip the indexes explicitly assigned by AddPublicKeysAtIndices
+		for {
+			reserved, err := c.isIndexReserved(wTx, index)
+			if err != nil {
+				return nil, err
+			}
+			if !reserved {
+				break
+			}
+			index++
+		}
+		// the skipped reserved indexes may move the index out of the
+		// MerkleTree
+		if index >= c.maxNLeafs() {
+			return nil, fmt.Errorf("%s (%d), index: %d", ErrMaxNLeafsReached,
+				c.maxNLeafs(), index)
+		}
+
 		// TODO ensure that the weights[i] does not overflow the field
-		indexAndWeight := types.IndexAndWeightToBytes(
-			nextIndex+uint64(i),
-			weights[i],
-		)
-		indexBytes := types.Uint64ToIndex(index)
+		indexAndWeight := types.IndexAndWeightToBytes(index, weights[i])
+		indexBytes := c.indexKey(index)
 		indexes = append(indexes[:], indexBytes)
 
 		// store the mapping between PublicKey->Index,Weight
@@ -289,15 +1206,40 @@ func (c *Census) AddPublicKeys(pubKs []babyjub.PublicKey,
 		if err := wTx.Set(pubKComp[:], indexAndWeight[:]); err != nil {
 			return nil, err
 		}
+		// store the mapping between Index->PublicKey
+		if err := wTx.Set(dbKeyIndexPubK(index), pubKComp[:]); err != nil {
+			return nil, err
+		}
+		index++
 
-		pubKHashBytes, err := types.HashPubKBytes(&pubKs[i], weights[i])
-		if err != nil {
+		var data []byte
+		if datas != nil {
+			data = datas[i]
+		}
+		if err := setLeafData(wTx, pubKComp, data); err != nil {
 			return nil, err
 		}
-		pubKHashes = append(pubKHashes, pubKHashBytes)
 	}
 
-	invalids, err := c.tree.AddBatchWithTx(wTx, indexes, pubKHashes)
+	pubKHashes := make([][]byte, len(pubKs))
+	err = c.parallelize(len(pubKs), func(i int) error {
+		var data []byte
+		if datas != nil {
+			data = datas[i]
+		}
+		pubKHashBytes, err := types.HashLeafBytes(&pubKs[i], weights[i], data)
+		if err != nil {
+			return err
+		}
+		pubKHashes[i] = pubKHashBytes
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	arboInvalids, err := c.tree.AddBatchWithTx(treeTx(wTx), indexes, pubKHashes)
+	invalids := invalidsFromArbo(arboInvalids)
 	if err != nil {
 		return invalids, err
 	}
@@ -306,20 +1248,182 @@ func (c *Census) AddPublicKeys(pubKs []babyjub.PublicKey,
 	}
 
 	// TODO check overflow
-	if err = c.setNextIndex(wTx, (nextIndex)+uint64(len(pubKs))); err != nil {
+	if err = c.setNextIndex(wTx, index); err != nil {
 		return nil, err
 	}
-
-	// commit the db.WriteTx
-	if err := wTx.Commit(); err != nil {
+	if err := c.markBuilding(wTx); err != nil {
 		return nil, err
 	}
 
 	return nil, nil
 }
 
-// GetProof returns the leaf Value and the MerkleProof compressed for the given
-// PublicKey
+// IndexedPublicKey contains a PublicKey with its Weight, to be placed at the
+// given Index of the Census MerkleTree
+type IndexedPublicKey struct {
+	Index     uint64
+	PublicKey babyjub.PublicKey
+	// Weight of the PublicKey, if nil a weight of 1 is used
+	Weight *big.Int
+	// Data is the optional leaf data of the PublicKey, see AddMembers
+	Data []byte
+}
+
+// AddPublicKeysAtIndices adds the given PublicKeys at their specified indexes.
+// Returns error if any of the indexes is already used by another PublicKey,
+// in which case none of the given PublicKeys is added. The indexes used are
+// skipped by the incremental assignment of indexes of AddPublicKeys.
+func (c *Census) AddPublicKeysAtIndices(keys []IndexedPublicKey) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return err
+	}
+	if isClosed {
+		return ErrCensusClosed
+	}
+	isSealed, err := c.IsSealed()
+	if err != nil {
+		return err
+	}
+	if isSealed {
+		return ErrCensusSealed
+	}
+	if c.sortKeys {
+		return fmt.Errorf("can not add PublicKeys at explicit indexes in a" +
+			" Census with SortKeys")
+	}
+	if err := c.checkKeyType(types.KeyTypeBabyJubJub); err != nil {
+		return err
+	}
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+
+	var indexes [][]byte
+	var pubKHashes [][]byte
+	seen := make(map[uint64]bool)
+	for i := 0; i < len(keys); i++ {
+		index := keys[i].Index
+		if seen[index] {
+			return fmt.Errorf("index %d is used by more than one of the"+
+				" given PublicKeys", index)
+		}
+		seen[index] = true
+		if index >= c.maxNLeafs() {
+			return fmt.Errorf("%s (%d), index: %d", ErrMaxNLeafsReached,
+				c.maxNLeafs(), index)
+		}
+		indexBytes := c.indexKey(index)
+
+		// check that the index is not used yet in the tree
+		_, _, err := c.tree.GetWithTx(treeTx(wTx), indexBytes)
+		if err == nil {
+			return fmt.Errorf("index %d is already used in the Census", index)
+		} else if err != arbo.ErrKeyNotFound {
+			return err
+		}
+
+		weight := keys[i].Weight
+		if weight == nil {
+			weight = big.NewInt(1)
+		}
+		// store the mapping between PublicKey->Index,Weight
+		indexAndWeight := types.IndexAndWeightToBytes(index, weight)
+		pubKComp := keys[i].PublicKey.Compress()
+		if err := wTx.Set(pubKComp[:], indexAndWeight); err != nil {
+			return err
+		}
+		if err := wTx.Set(dbKeyReservedIndex(index), []byte{1}); err != nil {
+			return err
+		}
+		if err := wTx.Set(dbKeyIndexPubK(index), pubKComp[:]); err != nil {
+			return err
+		}
+
+		if err := setLeafData(wTx, pubKComp, keys[i].Data); err != nil {
+			return err
+		}
+		pubKHashBytes, err := types.HashLeafBytes(&keys[i].PublicKey, weight,
+			keys[i].Data)
+		if err != nil {
+			return err
+		}
+		indexes = append(indexes, indexBytes)
+		pubKHashes = append(pubKHashes, pubKHashBytes)
+	}
+
+	invalids, err := c.tree.AddBatchWithTx(treeTx(wTx), indexes, pubKHashes)
+	if err != nil {
+		return err
+	}
+	if len(invalids) != 0 {
+		return fmt.Errorf("Can not add %d PublicKeys, invalid msg for key"+
+			" %d: %s", len(invalids), invalids[0].Index, invalids[0].Error)
+	}
+	if err := c.markBuilding(wTx); err != nil {
+		return err
+	}
+	if err := c.storeRootRecord(wTx, RootRecordAddition, len(keys)); err != nil {
+		return err
+	}
+	if err := c.storeCheckpoint(wTx); err != nil {
+		return err
+	}
+
+	// commit the db.WriteTx, only reached if all the keys have been
+	// successfully added
+	return wTx.Commit()
+}
+
+// HasPublicKey returns true if the given PublicKey has been added to the
+// Census, including the PublicKeys buffered until the Census is closed when
+// SortKeys is enabled
+func (c *Census) HasPublicKey(pubK *babyjub.PublicKey) (bool, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	return c.hasPublicKeyWithTx(rTx, pubK)
+}
+
+func (c *Census) hasPublicKeyWithTx(rTx db.ReadTx, pubK *babyjub.PublicKey) (
+	bool, error) {
+	pubKComp := pubK.Compress()
+	if c.sortKeys {
+		_, err := rTx.Get(dbKeyPendingKey(pubKComp))
+		if err == nil {
+			return true, nil
+		} else if err != db.ErrKeyNotFound {
+			return false, err
+		}
+	}
+
+	indexAndWeight, err := rTx.Get(pubKComp[:])
+	if err == db.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	index, weight, err := types.BytesToIndexAndWeight(indexAndWeight)
+	if err != nil {
+		return false, err
+	}
+	_, leafV, err := c.tree.GetWithTx(treeRTx(rTx), c.indexKey(index))
+	if err == arbo.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	hashPubKBytes, _, err := leafValue(rTx, pubK, weight)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(leafV, hashPubKBytes), nil
+}
+
+// GetProof returns the index and the MerkleProof compressed for the given
+// PublicKey, looking up its index in the Census, so the voters do not need to
+// know it. Returns ErrPublicKeyNotFound if the PublicKey is not in the Census.
 func (c *Census) GetProof(pubK *babyjub.PublicKey) (uint64, []byte, error) {
 	isClosed, err := c.IsClosed()
 	if err != nil {
@@ -335,47 +1439,186 @@ func (c *Census) GetProof(pubK *babyjub.PublicKey) (uint64, []byte, error) {
 	rTx := c.db.ReadTx()
 	defer rTx.Discard()
 
+	index, _, _, proof, err := c.genProofWithTx(rTx, pubK)
+	if err != nil {
+		return 0, nil, err
+	}
+	return index, proof, nil
+}
+
+// GetCensusProof returns the CensusProof of the given PublicKey in the closed
+// Census, which contains its index, its weight and its leaf data together
+// with the MerkleProof, as they are needed to verify it
+func (c *Census) GetCensusProof(pubK *babyjub.PublicKey) (*types.CensusProof, error) {
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return nil, err
+	}
+	if !isClosed {
+		return nil, ErrCensusNotClosed
+	}
+
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	index, weight, data, proof, err := c.genProofWithTx(rTx, pubK)
+	if err != nil {
+		return nil, err
+	}
+	return &types.CensusProof{
+		Index:       index,
+		PublicKey:   pubK,
+		Weight:      weight,
+		MerkleProof: proof,
+		Data:        data,
+	}, nil
+}
+
+// GetProvisionalProof returns the CensusProof of the given PublicKey against
+// the current CensusRoot, together with that CensusRoot, even if the Census
+// is not closed yet. WARNING: while the Census is not closed, the returned
+// CensusRoot is not final, and adding more PublicKeys to the Census changes
+// it, invalidating the returned CensusProof. Use GetProof to get the
+// CensusProof against the final CensusRoot.
+func (c *Census) GetProvisionalProof(pubK *babyjub.PublicKey) (
+	*types.CensusProof, []byte, error) {
+	// hold the writeMu, so the CensusProof and the CensusRoot are
+	// computed from the same leafs
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	index, weight, data, proof, err := c.genProofWithTx(rTx, pubK)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := c.tree.RootWithTx(treeRTx(rTx))
+	if err != nil {
+		return nil, nil, err
+	}
+	censusProof := &types.CensusProof{
+		Index:       index,
+		PublicKey:   pubK,
+		Weight:      weight,
+		MerkleProof: proof,
+		Data:        data,
+	}
+	return censusProof, root, nil
+}
+
+// genProofWithTx returns the index, weight, leaf data and MerkleProof of the
+// given PublicKey against the current CensusRoot
+func (c *Census) genProofWithTx(rTx db.ReadTx, pubK *babyjub.PublicKey) (
+	uint64, *big.Int, []byte, []byte, error) {
 	// get index of pubK
 	pubKComp := pubK.Compress()
 	indexAndWeight, err := rTx.Get(pubKComp[:])
-	if err != nil {
-		return 0, nil, err
+	if err == db.ErrKeyNotFound {
+		return 0, nil, nil, nil, fmt.Errorf("%w (%x)", ErrPublicKeyNotFound,
+			pubKComp[:])
+	} else if err != nil {
+		return 0, nil, nil, nil, err
 	}
 	index, weight, err := types.BytesToIndexAndWeight(indexAndWeight)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, nil, err
 	}
-	index32Bytes := types.Uint64ToIndex(index)
-	_, leafV, s, existence, err := c.tree.GenProof(index32Bytes)
+	index32Bytes := c.indexKey(index)
+	_, leafV, s, existence, err := c.tree.GenProofWithTx(treeRTx(rTx), index32Bytes)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, nil, err
 	}
 	if !existence {
 		// proof of non-existence currently not needed in the current use case
-		return 0, nil,
-			fmt.Errorf("publicKey does not exist in the census (%x)", pubKComp[:])
+		return 0, nil, nil, nil, fmt.Errorf("%w (%x)", ErrPublicKeyNotFound,
+			pubKComp[:])
 	}
-	hashPubKBytes, err := types.HashPubKBytes(pubK, weight)
+	hashPubKBytes, data, err := leafValue(rTx, pubK, weight)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, nil, err
 	}
 	if !bytes.Equal(leafV, hashPubKBytes) {
-		return 0, nil,
+		return 0, nil, nil, nil,
 			fmt.Errorf("leafV!=pubK: %x!=%x", leafV, pubK)
 	}
-	return index, s, nil
+	return index, weight, data, s, nil
 }
 
 // CheckProof checks a given MerkleProof of the given PublicKey (& index)
 // for the given CensusRoot
 func CheckProof(root, proof []byte, index uint64, pubK *babyjub.PublicKey,
 	weight *big.Int) (bool, error) {
-	// indexBytes := arbo.BigIntToBytes(maxKeyLen, big.NewInt(int64(index))) //nolint:gomnd
-	indexBytes := types.Uint64ToIndex(index)
-	hashPubK, err := types.HashPubKBytes(pubK, weight)
+	return CheckProofWithData(root, proof, index, pubK, weight, nil)
+}
+
+// CheckProofWithData checks a given MerkleProof of the given PublicKey (&
+// index) with its leaf data for the given CensusRoot
+func CheckProofWithData(root, proof []byte, index uint64,
+	pubK *babyjub.PublicKey, weight *big.Int, data []byte) (bool, error) {
+	return CheckProofWithHashFunction(DefaultHashFunction, root, proof, index,
+		pubK, weight, data)
+}
+
+// CheckProofWithHashFunction checks a given MerkleProof of the given PublicKey
+// (& index) with its leaf data for the given CensusRoot, of a Census that uses
+// the given HashFunction and the DefaultMaxLevels
+func CheckProofWithHashFunction(hashFunc arbo.HashFunction, root, proof []byte,
+	index uint64, pubK *babyjub.PublicKey, weight *big.Int, data []byte) (bool, error) {
+	return CheckProofWithTreeParams(hashFunc, types.MaxLevels, root, proof,
+		index, pubK, weight, data)
+}
+
+// CheckProofWithTreeParams checks a given MerkleProof of the given PublicKey
+// (& index) with its leaf data for the given CensusRoot, of a Census that uses
+// the given HashFunction and MaxLevels, as CheckCensusProofWithTreeParams does
+func CheckProofWithTreeParams(hashFunc arbo.HashFunction, maxLevels int,
+	root, proof []byte, index uint64, pubK *babyjub.PublicKey, weight *big.Int,
+	data []byte) (bool, error) {
+	return CheckCensusProofWithTreeParams(hashFunc, maxLevels, root,
+		&types.CensusProof{
+			Index:       index,
+			PublicKey:   pubK,
+			Weight:      weight,
+			MerkleProof: proof,
+			Data:        data,
+		})
+}
+
+// CheckCensusProof checks the given CensusProof against the given CensusRoot,
+// without loading any Census, so the CensusProofs can be verified by the
+// clients and before accepting the votes. Returns false if the CensusProof
+// does not match the CensusRoot, and an error if it is not well formed.
+func CheckCensusProof(root []byte, cp *types.CensusProof) (bool, error) {
+	return CheckCensusProofWithHashFunction(DefaultHashFunction, root, cp)
+}
+
+// CheckCensusProofWithHashFunction checks the given CensusProof against the
+// given CensusRoot of a Census that uses the given HashFunction and the
+// DefaultMaxLevels
+func CheckCensusProofWithHashFunction(hashFunc arbo.HashFunction, root []byte,
+	cp *types.CensusProof) (bool, error) {
+	return CheckCensusProofWithTreeParams(hashFunc, types.MaxLevels, root, cp)
+}
+
+// CheckCensusProofWithTreeParams checks the given CensusProof against the
+// given CensusRoot of a Census that uses the given HashFunction and MaxLevels.
+// The MaxLevels determine the length of the leaf keys, which are hashed as
+// bytes by Blake2b, so a CensusProof of a Blake2b Census can only be checked
+// with its MaxLevels.
+func CheckCensusProofWithTreeParams(hashFunc arbo.HashFunction, maxLevels int,
+	root []byte, cp *types.CensusProof) (bool, error) {
+	if cp == nil {
+		return false, fmt.Errorf("nil CensusProof")
+	}
+	if err := types.CheckMerkleProofFormat(cp.MerkleProof); err != nil {
+		return false, err
+	}
+	leafValue, err := cp.LeafValue()
 	if err != nil {
 		return false, err
 	}
-
-	return arbo.CheckProof(arbo.HashFunctionPoseidon, indexBytes, hashPubK, root, proof)
+	return arbo.CheckProof(hashFunc, types.Uint64ToIndexWithLevels(cp.Index,
+		maxLevels), leafValue, root, cp.MerkleProof)
 }
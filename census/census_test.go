@@ -2,9 +2,13 @@ package census
 
 import (
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"math"
 	"math/big"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aragon/ovote-node/types"
 	qt "github.com/frankban/quicktest"
@@ -29,7 +33,7 @@ func newTestDB(c *qt.C) db.Database {
 
 func newTestCensus(c *qt.C) *Census {
 	database := newTestDB(c)
-	opts := Options{database}
+	opts := Options{DB: database}
 	census, err := New(opts)
 	c.Assert(err, qt.IsNil)
 	return census
@@ -178,6 +182,47 @@ func TestGetProofAndCheckMerkleProof(t *testing.T) {
 		c.Assert(err, qt.IsNil)
 		c.Assert(v, qt.IsTrue)
 	}
+
+	// the index of the PublicKey is looked up, so a PublicKey that is not
+	// in the Census returns ErrPublicKeyNotFound
+	sk := babyjub.NewRandPrivKey()
+	_, _, err = census.GetProof(sk.Public())
+	c.Assert(errors.Is(err, ErrPublicKeyNotFound), qt.IsTrue)
+}
+
+func TestGetCensusProofWeighted(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	nKeys := 10
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs = append(pubKs, *sk.Public())
+		weights = append(weights, big.NewInt(int64(1000*(i+1))))
+	}
+	_, err := census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+
+	_, err = census.GetCensusProof(&pubKs[0])
+	c.Assert(err, qt.Equals, ErrCensusNotClosed)
+
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	for i := 0; i < nKeys; i++ {
+		cp, err := census.GetCensusProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(cp.Weight.Cmp(weights[i]), qt.Equals, 0)
+		c.Assert(cp.Verify(root), qt.IsNil)
+
+		// the CensusProof is only valid with its weight
+		cp.Weight = big.NewInt(1)
+		c.Assert(cp.Verify(root), qt.Not(qt.IsNil))
+	}
 }
 
 func TestInfo(t *testing.T) {
@@ -222,3 +267,387 @@ func TestInfo(t *testing.T) {
 	c.Assert(ci.Closed, qt.IsTrue)
 	c.Assert(ci.Root, qt.DeepEquals, root)
 }
+
+func TestAddPublicKeysAtomic(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	nKeys := 100
+	// generate the publicKeys
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubK := sk.Public()
+		pubKs = append(pubKs, *pubK)
+		weights = append(weights, big.NewInt(1))
+	}
+
+	// add a first batch, which should succeed
+	invalids, err := census.AddPublicKeys(pubKs[:nKeys/2], weights[:nKeys/2])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	root, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+
+	// inject a failure in the middle of the second batch, by using a
+	// weight which is outside the field, so the leaf hash can not be
+	// computed
+	weights[nKeys/2+10] = new(big.Int).Lsh(big.NewInt(1), 256) //nolint:gomnd
+	_, err = census.AddPublicKeys(pubKs[nKeys/2:], weights[nKeys/2:])
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	// expect the census to remain unchanged
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(nKeys/2))
+	root2, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root2, qt.DeepEquals, root)
+
+	// expect the keys of the failed batch to not be stored
+	rTx := census.db.ReadTx()
+	for i := nKeys / 2; i < nKeys; i++ {
+		pubKComp := pubKs[i].Compress()
+		_, err = rTx.Get(pubKComp[:])
+		c.Assert(err, qt.Equals, db.ErrKeyNotFound)
+	}
+	rTx.Discard()
+
+	// expect error when the number of weights does not match
+	_, err = census.AddPublicKeys(pubKs[nKeys/2:], weights[nKeys/2+1:])
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	// retry the batch with valid weights, which should succeed
+	weights[nKeys/2+10] = big.NewInt(1)
+	invalids, err = census.AddPublicKeys(pubKs[nKeys/2:], weights[nKeys/2:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	size, err = census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(nKeys))
+}
+
+func TestAddPublicKeysInChunks(t *testing.T) {
+	c := qt.New(t)
+	census, err := New(Options{DB: newTestDB(c), ChunkSize: 10})
+	c.Assert(err, qt.IsNil)
+	// census with the DefaultChunkSize, used to compare the roots
+	census2 := newTestCensus(c)
+
+	nKeys := 35
+	// generate the publicKeys
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubK := sk.Public()
+		pubKs = append(pubKs, *pubK)
+		weights = append(weights, big.NewInt(1))
+	}
+
+	// inject a failure in the 3rd chunk, expect the first two chunks to
+	// be added
+	weights[23] = new(big.Int).Lsh(big.NewInt(1), 256) //nolint:gomnd
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.Not(qt.IsNil))
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(20))
+
+	// add the remaining keys
+	weights[23] = big.NewInt(1)
+	invalids, err := census.AddPublicKeys(pubKs[20:], weights[20:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	size, err = census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(nKeys))
+
+	invalids, err = census2.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+
+	// expect the same root than adding all the keys in a single chunk
+	root, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	root2, err := census2.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, root2)
+}
+
+func TestClosedAfterReload(t *testing.T) {
+	c := qt.New(t)
+	database := newTestDB(c)
+	census, err := New(Options{DB: database})
+	c.Assert(err, qt.IsNil)
+
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+
+	// load again the census from the same db, expect it to be closed
+	census, err = New(Options{DB: database})
+	c.Assert(err, qt.IsNil)
+	isClosed, err := census.IsClosed()
+	c.Assert(err, qt.IsNil)
+	c.Assert(isClosed, qt.IsTrue)
+}
+
+func TestAddPublicKeysAtIndices(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	nKeys := 10
+	// generate the publicKeys
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubK := sk.Public()
+		pubKs = append(pubKs, *pubK)
+		weights = append(weights, big.NewInt(1))
+	}
+
+	// add keys at explicit indexes, leaving gaps
+	err := census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{Index: 1, PublicKey: pubKs[0]},
+		{Index: 3, PublicKey: pubKs[1], Weight: big.NewInt(2)},
+		{Index: 100, PublicKey: pubKs[2]},
+	})
+	c.Assert(err, qt.IsNil)
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(3))
+
+	// expect error on collision with an existing index, and no key added
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{Index: 2, PublicKey: pubKs[3]},
+		{Index: 3, PublicKey: pubKs[4]},
+	})
+	c.Assert(err, qt.ErrorMatches, "index 3 is already used in the Census")
+	// expect error on collision between the given keys
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{Index: 2, PublicKey: pubKs[3]},
+		{Index: 2, PublicKey: pubKs[4]},
+	})
+	c.Assert(err, qt.ErrorMatches, "index 2 is used by more than one.*")
+	size, err = census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(3))
+
+	nextIndex, err := census.NextLeafIndex()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextIndex, qt.Equals, uint64(0))
+
+	// add keys with incremental indexes, expect them to fill the gaps,
+	// skipping the explicitly assigned indexes
+	invalids, err := census.AddPublicKeys(pubKs[3:5], weights[3:5])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	// the next index skips the explicitly assigned index 3
+	nextIndex, err = census.NextLeafIndex()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextIndex, qt.Equals, uint64(4))
+	invalids, err = census.AddPublicKeys(pubKs[5:7], weights[5:7])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	size, err = census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(7))
+	nextIndex, err = census.NextLeafIndex()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextIndex, qt.Equals, uint64(6))
+
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+	_, err = census.NextLeafIndex()
+	c.Assert(err, qt.Equals, ErrCensusClosed)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	expectedIndexes := []uint64{1, 3, 100, 0, 2, 4, 5}
+	expectedWeights := []int64{1, 2, 1, 1, 1, 1, 1}
+	for i := 0; i < 7; i++ {
+		index, proof, err := census.GetProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(index, qt.Equals, expectedIndexes[i])
+		v, err := CheckProof(root, proof, index, &pubKs[i],
+			big.NewInt(expectedWeights[i]))
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+	}
+}
+
+func TestPublicKeys(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	nKeys := 20
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubK := sk.Public()
+		pubKs = append(pubKs, *pubK)
+		weights = append(weights, big.NewInt(int64(i+1)))
+	}
+
+	keys, err := census.PublicKeys()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(keys), qt.Equals, 0)
+
+	invalids, err := census.AddPublicKeys(pubKs[:nKeys-1], weights[:nKeys-1])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{Index: 1000, PublicKey: pubKs[nKeys-1], Weight: weights[nKeys-1]},
+	})
+	c.Assert(err, qt.IsNil)
+
+	// expect the keys sorted by index
+	keys, err = census.PublicKeys()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(keys), qt.Equals, nKeys)
+	for i := 0; i < nKeys-1; i++ {
+		c.Assert(keys[i].Index, qt.Equals, uint64(i))
+		c.Assert(keys[i].PublicKey.Compress(), qt.Equals, pubKs[i].Compress())
+		c.Assert(keys[i].Weight.Cmp(weights[i]), qt.Equals, 0)
+	}
+	c.Assert(keys[nKeys-1].Index, qt.Equals, uint64(1000))
+	c.Assert(keys[nKeys-1].PublicKey.Compress(), qt.Equals,
+		pubKs[nKeys-1].Compress())
+}
+
+func TestClosedAt(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	census, err := New(Options{DB: newTestDB(c), Now: func() time.Time { return now }})
+	c.Assert(err, qt.IsNil)
+
+	// an open Census has no closing time
+	closedAt, err := census.ClosedAt()
+	c.Assert(err, qt.IsNil)
+	c.Assert(closedAt.IsZero(), qt.IsTrue)
+
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+	closedAt, err = census.ClosedAt()
+	c.Assert(err, qt.IsNil)
+	c.Assert(closedAt.Equal(now), qt.IsTrue)
+
+	// closing again does not change the closing time
+	now = now.Add(time.Hour)
+	err = census.Close()
+	c.Assert(err, qt.ErrorMatches, ErrCensusAlreadyClosed.Error())
+	closedAt, err = census.ClosedAt()
+	c.Assert(err, qt.IsNil)
+	c.Assert(closedAt.Equal(now.Add(-time.Hour)), qt.IsTrue)
+}
+
+func TestInfoTimestamps(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	database := newTestDB(c)
+	census, err := New(Options{DB: database, Now: func() time.Time { return now }})
+	c.Assert(err, qt.IsNil)
+	createdAt := now
+
+	ci, err := census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.CreatedAt.Equal(createdAt), qt.IsTrue)
+	c.Assert(ci.ClosedAt, qt.IsNil)
+
+	// loading the Census again does not change the creation time
+	now = now.Add(time.Hour)
+	census, err = New(Options{DB: database, Now: func() time.Time { return now }})
+	c.Assert(err, qt.IsNil)
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+
+	ci, err = census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.CreatedAt.Equal(createdAt), qt.IsTrue)
+	c.Assert(ci.ClosedAt.Equal(now), qt.IsTrue)
+
+	b, err := json.Marshal(ci)
+	c.Assert(err, qt.IsNil)
+	var ci2 Info
+	err = json.Unmarshal(b, &ci2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci2.CreatedAt.Equal(createdAt), qt.IsTrue)
+	c.Assert(ci2.ClosedAt.Equal(now), qt.IsTrue)
+	c.Assert(ci2.Closed, qt.IsTrue)
+}
+
+func TestSetAnchorConcurrent(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	err := census.Close()
+	c.Assert(err, qt.IsNil)
+
+	// only one of the concurrent SetAnchor without overwrite succeeds
+	n := 10
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = census.SetAnchor(Anchor{TxHash: []byte{byte(i + 1)},
+				ChainID: 1}, false)
+		}(i)
+	}
+	wg.Wait()
+	nOk := 0
+	for i := 0; i < n; i++ {
+		if errs[i] == nil {
+			nOk++
+			continue
+		}
+		c.Assert(errs[i], qt.Equals, ErrAnchorExists)
+	}
+	c.Assert(nOk, qt.Equals, 1)
+}
+
+func TestCheckCensusProof(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	pubKs, weights := genPublicKeys(10)
+	_, err := census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	for i := 0; i < len(pubKs); i++ {
+		cp, err := census.GetCensusProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		v, err := CheckCensusProof(root, cp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+	}
+
+	// a CensusProof of a different weight or index does not verify
+	cp, err := census.GetCensusProof(&pubKs[3])
+	c.Assert(err, qt.IsNil)
+	cp.Weight = big.NewInt(100)
+	v, err := CheckCensusProof(root, cp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.IsFalse)
+	cp.Weight = weights[3]
+	cp.Index = 4
+	v, err = CheckCensusProof(root, cp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.IsFalse)
+
+	// malformed CensusProofs return an error
+	cp.PublicKey = nil
+	_, err = CheckCensusProof(root, cp)
+	c.Assert(err, qt.ErrorMatches, "CensusProof without PublicKey")
+	_, err = CheckCensusProof(root, nil)
+	c.Assert(err, qt.ErrorMatches, "nil CensusProof")
+}
@@ -0,0 +1,278 @@
+package census
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+// DefaultCheckpointInterval defines the default number of chunks added by
+// AddPublicKeys between two Checkpoints
+const DefaultCheckpointInterval = 10
+
+var (
+	dbKeyCheckpoint = []byte("checkpoint")
+	// dbKeyArboNLeafs is the key where arbo stores the number of leafs of
+	// the MerkleTree, which has no setter in arbo. It is only written by
+	// restoreCheckpoint, which checks it with arbo GetNLeafs, as building
+	// the restored MerkleTree again would break the Snapshots sharing its
+	// nodes.
+	dbKeyArboNLeafs = []byte("nleafs")
+)
+
+// ErrNoCheckpoint is used when trying to recover a Census that has no
+// Checkpoint stored, which is the case of the Censuses created before the
+// Checkpoints were introduced
+var ErrNoCheckpoint = errors.New("Census without Checkpoint")
+
+// Checkpoint contains a committed state of the Census MerkleTree, to which the
+// Census can be recovered with Recover after a crash
+type Checkpoint struct {
+	// NextIndex is the index that the next PublicKey would get
+	NextIndex uint64 `json:"nextIndex"`
+	// NLeafs is the number of leafs of the MerkleTree
+	NLeafs uint64 `json:"nLeafs"`
+	// Root is the root of the MerkleTree
+	Root []byte `json:"root"`
+}
+
+// currentCheckpoint returns the Checkpoint of the current state of the
+// MerkleTree of the given db.ReadTx
+func (c *Census) currentCheckpoint(rTx db.ReadTx) (*Checkpoint, error) {
+	nextIndex, err := c.getNextIndex(rTx)
+	if err != nil {
+		return nil, err
+	}
+	nLeafs, err := c.tree.GetNLeafsWithTx(treeRTx(rTx))
+	if err != nil {
+		return nil, err
+	}
+	root, err := c.tree.RootWithTx(treeRTx(rTx))
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{
+		NextIndex: nextIndex,
+		NLeafs:    uint64(nLeafs),
+		Root:      root,
+	}, nil
+}
+
+// storeCheckpoint stores the current state of the MerkleTree of the given
+// db.WriteTx as the Checkpoint of the Census
+func (c *Census) storeCheckpoint(wTx db.WriteTx) error {
+	cp, err := c.currentCheckpoint(wTx)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return wTx.Set(dbKeyCheckpoint, b)
+}
+
+// checkpoint stores the current state of the MerkleTree as the Checkpoint of
+// the Census in its own db.WriteTx
+func (c *Census) checkpoint() error {
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	if err := c.storeCheckpoint(wTx); err != nil {
+		return err
+	}
+	if err := wTx.Commit(); err != nil {
+		return err
+	}
+	c.nChunksSinceCheckpoint = 0
+	return nil
+}
+
+func (c *Census) getCheckpoint(rTx db.ReadTx) (*Checkpoint, error) {
+	b, err := rTx.Get(dbKeyCheckpoint)
+	if err == db.ErrKeyNotFound {
+		return nil, ErrNoCheckpoint
+	} else if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// GetCheckpoint returns the last Checkpoint stored for the Census
+func (c *Census) GetCheckpoint() (*Checkpoint, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	return c.getCheckpoint(rTx)
+}
+
+// Recover restores the Census MerkleTree to its last Checkpoint, which is
+// first validated by checking that its root exists and that it contains the
+// Checkpoint NLeafs. The PublicKeys added after the Checkpoint (for example by
+// an AddPublicKeys interrupted by a crash) are discarded, and returned, so
+// they can be added again. Returns error if the Census is closed and its
+// CensusRoot does not match the Checkpoint, as the CensusRoot of a closed
+// Census can not change.
+func (c *Census) Recover() ([]babyjub.PublicKey, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	cp, err := c.getCheckpoint(wTx)
+	if err != nil {
+		return nil, err
+	}
+	root, err := c.tree.RootWithTx(treeTx(wTx))
+	if err != nil {
+		return nil, err
+	}
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return nil, err
+	}
+	if isClosed && !bytes.Equal(root, cp.Root) {
+		return nil, fmt.Errorf("can not recover a closed Census, CensusRoot"+
+			" %x does not match the Checkpoint root %x", root, cp.Root)
+	}
+	discarded, err := c.restoreCheckpoint(wTx, cp)
+	if err != nil {
+		return nil, err
+	}
+	if err := wTx.Commit(); err != nil {
+		return nil, err
+	}
+	c.nChunksSinceCheckpoint = 0
+	return discarded, nil
+}
+
+// restoreCheckpoint restores in the given db.WriteTx the MerkleTree to the
+// given Checkpoint, after validating it, and discards the PublicKeys that are
+// not in the MerkleTree of the Checkpoint, which are returned. All the reads
+// are done through the given db.WriteTx, so they see the state that it
+// modifies.
+func (c *Census) restoreCheckpoint(wTx db.WriteTx, cp *Checkpoint) (
+	[]babyjub.PublicKey, error) {
+	currentRoot, err := c.tree.RootWithTx(treeTx(wTx))
+	if err != nil {
+		return nil, err
+	}
+	// validate the MerkleTree under the Checkpoint root
+	if err := c.tree.SetRootWithTx(treeTx(wTx), cp.Root); err != nil {
+		return nil, err
+	}
+	nLeafs := uint64(0)
+	err = c.tree.IterateWithTx(treeTx(wTx), cp.Root, func(_, v []byte) {
+		if v[0] == arbo.PrefixValueLeaf {
+			nLeafs++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if nLeafs != cp.NLeafs {
+		return nil, fmt.Errorf("invalid Checkpoint, expected %d leafs under"+
+			" the root %x, found %d", cp.NLeafs, cp.Root, nLeafs)
+	}
+
+	// find the PublicKeys that are not in the MerkleTree of the
+	// Checkpoint, from the leafs of the current MerkleTree, as the db.WriteTx
+	// can not be iterated
+	var indexes []uint64
+	err = c.tree.IterateWithTx(treeTx(wTx), currentRoot, func(_, v []byte) {
+		if v[0] == arbo.PrefixValueLeaf {
+			leafK, _ := arbo.ReadLeafValue(v)
+			indexes = append(indexes, arbo.BytesToBigInt(leafK).Uint64())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	var discarded []babyjub.PublicKey
+	for i := 0; i < len(indexes); i++ {
+		_, _, err := c.tree.GetWithTx(treeTx(wTx), c.indexKey(indexes[i]))
+		if err == nil {
+			continue
+		} else if err != arbo.ErrKeyNotFound {
+			return nil, err
+		}
+		b, err := wTx.Get(dbKeyIndexPubK(indexes[i]))
+		if err == db.ErrKeyNotFound {
+			// the leaf is not of a PublicKey
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var pubKComp babyjub.PublicKeyComp
+		copy(pubKComp[:], b)
+		if err := c.discardPublicKey(wTx, indexes[i], pubKComp); err != nil {
+			return nil, err
+		}
+		pubK, err := pubKComp.Decompress()
+		if err != nil {
+			return nil, err
+		}
+		discarded = append(discarded, *pubK)
+	}
+
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, cp.NLeafs)
+	if err := treeTx(wTx).Set(dbKeyArboNLeafs, b); err != nil {
+		return nil, err
+	}
+	treeNLeafs, err := c.tree.GetNLeafsWithTx(treeTx(wTx))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(treeNLeafs) != cp.NLeafs {
+		return nil, fmt.Errorf("can not restore the number of leafs of the"+
+			" MerkleTree, arbo reports %d leafs, expected %d", treeNLeafs,
+			cp.NLeafs)
+	}
+	if err := c.setNextIndex(wTx, cp.NextIndex); err != nil {
+		return nil, err
+	}
+	return discarded, nil
+}
+
+// discardPublicKey removes the mappings of the given PublicKey placed at the
+// given index, which is not in the MerkleTree
+func (c *Census) discardPublicKey(wTx db.WriteTx, index uint64,
+	pubKComp babyjub.PublicKeyComp) error {
+	if err := wTx.Delete(dbKeyIndexPubK(index)); err != nil {
+		return err
+	}
+	if err := wTx.Delete(dbKeyReservedIndex(index)); err != nil {
+		return err
+	}
+	// the PublicKey->Index,Weight mapping is only removed if it points to
+	// the discarded index
+	indexAndWeight, err := wTx.Get(pubKComp[:])
+	if err == db.ErrKeyNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	mappedIndex, _, err := types.BytesToIndexAndWeight(indexAndWeight)
+	if err != nil {
+		return err
+	}
+	if mappedIndex != index {
+		return nil
+	}
+	if err := wTx.Delete(dbKeyLeafData(pubKComp)); err != nil {
+		return err
+	}
+	return wTx.Delete(pubKComp[:])
+}
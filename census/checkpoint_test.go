@@ -0,0 +1,163 @@
+package census
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestCheckpoint(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 8
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs = append(pubKs, *sk.Public())
+		weights = append(weights, big.NewInt(1))
+	}
+
+	census, err := New(Options{DB: newTestDB(c), ChunkSize: 2,
+		CheckpointInterval: 2})
+	c.Assert(err, qt.IsNil)
+	cp, err := census.GetCheckpoint()
+	c.Assert(err, qt.IsNil)
+	c.Assert(cp.NextIndex, qt.Equals, uint64(0))
+	c.Assert(cp.NLeafs, qt.Equals, uint64(0))
+
+	// 3 chunks, the Checkpoint is stored with the 2nd chunk and when
+	// AddPublicKeys ends
+	_, err = census.AddPublicKeys(pubKs[:6], weights[:6])
+	c.Assert(err, qt.IsNil)
+	cp, err = census.GetCheckpoint()
+	c.Assert(err, qt.IsNil)
+	c.Assert(cp.NextIndex, qt.Equals, uint64(6))
+	c.Assert(cp.NLeafs, qt.Equals, uint64(6))
+	root, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(cp.Root, qt.DeepEquals, root)
+
+	// recovering a consistent Census does not discard any PublicKey
+	discarded, err := census.Recover()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(discarded), qt.Equals, 0)
+
+	// simulate a crash during an AddPublicKeys, where a chunk has been
+	// committed but the Checkpoint has not been stored
+	_, err = census.addPublicKeysChunk(pubKs[6:], weights[6:], nil)
+	c.Assert(err, qt.IsNil)
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(nKeys))
+
+	discarded, err = census.Recover()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(discarded), qt.Equals, 2)
+	for i := 0; i < len(discarded); i++ {
+		c.Assert(discarded[i].Compress(), qt.Equals, pubKs[6+i].Compress())
+		ok, err := census.HasPublicKey(&pubKs[6+i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsFalse)
+	}
+	size, err = census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(6))
+	root, err = census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, cp.Root)
+	nextIndex, err := census.NextLeafIndex()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextIndex, qt.Equals, uint64(6))
+	keys, err := census.PublicKeys()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(keys), qt.Equals, 6)
+	// arbo reports the number of leafs of the restored MerkleTree
+	nLeafs, err := census.tree.GetNLeafs()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nLeafs, qt.Equals, 6)
+
+	// the discarded PublicKeys can be added again, getting the same
+	// CensusRoot than a Census built without crashes
+	_, err = census.AddPublicKeys(discarded, weights[6:])
+	c.Assert(err, qt.IsNil)
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+	root, err = census.Root()
+	c.Assert(err, qt.IsNil)
+
+	census2 := newTestCensus(c)
+	_, err = census2.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	err = census2.Close()
+	c.Assert(err, qt.IsNil)
+	root2, err := census2.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, root2)
+
+	// a closed Census matching its Checkpoint can be recovered
+	discarded, err = census.Recover()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(discarded), qt.Equals, 0)
+
+	// a Checkpoint not matching the MerkleTree is rejected
+	census3 := newTestCensus(c)
+	_, err = census3.AddPublicKeys(pubKs[:2], weights[:2])
+	c.Assert(err, qt.IsNil)
+	cp, err = census3.GetCheckpoint()
+	c.Assert(err, qt.IsNil)
+	cp.NLeafs = 3
+	b, err := json.Marshal(cp)
+	c.Assert(err, qt.IsNil)
+	wTx := census3.db.WriteTx()
+	c.Assert(wTx.Set(dbKeyCheckpoint, b), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	_, err = census3.Recover()
+	c.Assert(err, qt.ErrorMatches, "invalid Checkpoint, expected 3 leafs.*")
+
+	// a Census without Checkpoint can not be recovered
+	wTx = census3.db.WriteTx()
+	c.Assert(wTx.Delete(dbKeyCheckpoint), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	_, err = census3.Recover()
+	c.Assert(err, qt.Equals, ErrNoCheckpoint)
+}
+
+// TestArboNLeafsKey pins the layout of the number of leafs stored by arbo,
+// which restoreCheckpoint writes directly, so an update of arbo that changes
+// its key or its encoding is detected
+func TestArboNLeafsKey(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c)})
+	c.Assert(err, qt.IsNil)
+	pubKs, weights := genPublicKeys(13)
+	for _, batch := range [][2]int{{0, 3}, {3, 13}} {
+		_, err = census.AddPublicKeys(pubKs[batch[0]:batch[1]],
+			weights[batch[0]:batch[1]])
+		c.Assert(err, qt.IsNil)
+
+		rTx := census.db.ReadTx()
+		b, err := treeRTx(rTx).Get(dbKeyArboNLeafs)
+		c.Assert(err, qt.IsNil)
+		c.Assert(len(b), qt.Equals, 8)
+		nLeafs, err := census.tree.GetNLeafsWithTx(treeRTx(rTx))
+		c.Assert(err, qt.IsNil)
+		rTx.Discard()
+		c.Assert(binary.LittleEndian.Uint64(b), qt.Equals, uint64(nLeafs))
+	}
+
+	// a value written at the key is the one reported by arbo
+	wTx := census.db.WriteTx()
+	defer wTx.Discard()
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, 42)
+	c.Assert(treeTx(wTx).Set(dbKeyArboNLeafs, b), qt.IsNil)
+	nLeafs, err := census.tree.GetNLeafsWithTx(treeTx(wTx))
+	c.Assert(err, qt.IsNil)
+	c.Assert(nLeafs, qt.Equals, 42)
+}
@@ -0,0 +1,33 @@
+package census
+
+// ResetForClone removes the data of the Census that belongs to the process
+// where it has been used, so a copy of its db can be used as a new Census
+// with the same PublicKeys and MerkleTree: the ErrMsg, the Progress, the
+// Anchor and the VotingDeadline are removed, a published Census goes back to
+// StateClosed, and the creation time is set to the current time.
+func (c *Census) ResetForClone() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	for _, key := range [][]byte{dbKeyErrMsg, dbKeyProgress, dbKeyAnchor,
+		dbKeyVotingDeadline} {
+		if err := wTx.Delete(key); err != nil {
+			return err
+		}
+	}
+	state, err := c.getState(wTx)
+	if err != nil {
+		return err
+	}
+	if state == StatePublished {
+		if err := c.setState(wTx, StateClosed); err != nil {
+			return err
+		}
+	}
+	if err := setTime(wTx, dbKeyCreatedAt, c.now()); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
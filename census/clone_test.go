@@ -0,0 +1,59 @@
+package census
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestResetForClone(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	census, err := New(Options{DB: newTestDB(c), Now: func() time.Time { return now }})
+	c.Assert(err, qt.IsNil)
+
+	sk := babyjub.NewRandPrivKey()
+	pubK := sk.Public()
+	_, err = census.AddPublicKeys([]babyjub.PublicKey{*pubK},
+		[]*big.Int{big.NewInt(1)})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.SetErrMsg("error"), qt.IsNil)
+	c.Assert(census.UpdateProgress(func(p *Progress) { p.Added++ }), qt.IsNil)
+	c.Assert(census.SetVotingDeadline(now.Add(time.Hour)), qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	c.Assert(census.SetAnchor(Anchor{TxHash: []byte{1}, ChainID: 1}, false), qt.IsNil)
+	c.Assert(census.SetState(StatePublished), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	now = now.Add(time.Hour)
+	err = census.ResetForClone()
+	c.Assert(err, qt.IsNil)
+
+	info, err := census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.ErrMsg, qt.Equals, "")
+	c.Assert(info.Progress, qt.IsNil)
+	c.Assert(info.VotingDeadline, qt.IsNil)
+	c.Assert(info.Size, qt.Equals, uint64(1))
+	anchor, err := census.GetAnchor()
+	c.Assert(err, qt.IsNil)
+	c.Assert(anchor, qt.IsNil)
+	state, err := census.State()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state, qt.Equals, StateClosed)
+	createdAt, err := census.CreatedAt()
+	c.Assert(err, qt.IsNil)
+	c.Assert(createdAt.Equal(now), qt.IsTrue)
+
+	// the PublicKeys and the MerkleTree are kept
+	root2, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root2, qt.DeepEquals, root)
+	_, _, err = census.GetProof(pubK)
+	c.Assert(err, qt.IsNil)
+}
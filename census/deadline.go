@@ -0,0 +1,72 @@
+package census
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.vocdoni.io/dvote/db"
+)
+
+var dbKeyVotingDeadline = []byte("votingDeadline")
+
+// ErrVotingClosed is used when a vote is received after the VotingDeadline
+// of the Census
+var ErrVotingClosed = errors.New("Voting closed, the VotingDeadline has passed")
+
+// SetVotingDeadline stores the given time as the VotingDeadline of the
+// Census, after which the votes are not accepted. A zero time removes the
+// VotingDeadline.
+func (c *Census) SetVotingDeadline(deadline time.Time) error {
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+
+	if deadline.IsZero() {
+		if err := wTx.Delete(dbKeyVotingDeadline); err != nil {
+			return err
+		}
+		return wTx.Commit()
+	}
+	b, err := deadline.UTC().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := wTx.Set(dbKeyVotingDeadline, b); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
+
+// VotingDeadline returns the VotingDeadline of the Census, or a zero time if
+// the Census has no VotingDeadline
+func (c *Census) VotingDeadline() (time.Time, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	var deadline time.Time
+	b, err := rTx.Get(dbKeyVotingDeadline)
+	if err == db.ErrKeyNotFound {
+		return deadline, nil
+	} else if err != nil {
+		return deadline, err
+	}
+	if err := deadline.UnmarshalBinary(b); err != nil {
+		return deadline, err
+	}
+	return deadline, nil
+}
+
+// CheckVotingOpen returns ErrVotingClosed if the current time is after the
+// VotingDeadline of the Census
+func (c *Census) CheckVotingOpen() error {
+	deadline, err := c.VotingDeadline()
+	if err != nil {
+		return err
+	}
+	now := c.now()
+	if !deadline.IsZero() && now.After(deadline) {
+		return fmt.Errorf("%w, deadline: %s, now: %s", ErrVotingClosed,
+			deadline.Format(time.RFC3339), now.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
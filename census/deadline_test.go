@@ -0,0 +1,50 @@
+package census
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestVotingDeadline(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	census, err := New(Options{DB: newTestDB(c), Now: func() time.Time { return now }})
+	c.Assert(err, qt.IsNil)
+
+	// without VotingDeadline, the voting is open
+	deadline, err := census.VotingDeadline()
+	c.Assert(err, qt.IsNil)
+	c.Assert(deadline.IsZero(), qt.IsTrue)
+	c.Assert(census.CheckVotingOpen(), qt.IsNil)
+	info, err := census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.VotingDeadline, qt.IsNil)
+	c.Assert(info.VotingOpen, qt.IsTrue)
+
+	err = census.SetVotingDeadline(now.Add(time.Hour))
+	c.Assert(err, qt.IsNil)
+	deadline, err = census.VotingDeadline()
+	c.Assert(err, qt.IsNil)
+	c.Assert(deadline.Equal(now.Add(time.Hour)), qt.IsTrue)
+	c.Assert(census.CheckVotingOpen(), qt.IsNil)
+
+	// the deadline itself is still open
+	now = now.Add(time.Hour)
+	c.Assert(census.CheckVotingOpen(), qt.IsNil)
+
+	now = now.Add(time.Second)
+	err = census.CheckVotingOpen()
+	c.Assert(err, qt.ErrorMatches, ErrVotingClosed.Error()+".*")
+	info, err = census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.VotingDeadline.Equal(deadline), qt.IsTrue)
+	c.Assert(info.VotingOpen, qt.IsFalse)
+
+	// removing the deadline opens the voting again
+	err = census.SetVotingDeadline(time.Time{})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.CheckVotingOpen(), qt.IsNil)
+}
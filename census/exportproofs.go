@@ -0,0 +1,192 @@
+package census
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/db"
+)
+
+var (
+	// dbPrefixStoredProof is used to store the CensusProofs generated by
+	// StoreAllProofs, where each entry key is dbPrefixStoredProof | index
+	// in big-endian, so the entries are sorted by index
+	dbPrefixStoredProof = []byte("storedProof")
+	// dbKeyProofsStored is set once StoreAllProofs has stored the
+	// CensusProofs of all the PublicKeys of the Census
+	dbKeyProofsStored = []byte("proofsStored")
+)
+
+func dbKeyStoredProof(index uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, index)
+	return append(append([]byte{}, dbPrefixStoredProof...), b...)
+}
+
+// StoreAllProofs generates in a single pass the CensusProofs of all the
+// PublicKeys of the closed Census, and stores them in the db, so IterateProofs
+// returns them without generating them again. Storing the CensusProofs of an
+// already stored Census is a no-op.
+func (c *Census) StoreAllProofs() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return err
+	}
+	if !isClosed {
+		return ErrCensusNotClosed
+	}
+	stored, err := c.ProofsStored()
+	if err != nil || stored {
+		return err
+	}
+
+	wTx := c.db.WriteTx()
+	defer func() { wTx.Discard() }()
+	n := 0
+	err = c.generateProofs(func(cp *types.CensusProof) error {
+		cpBytes, err := json.Marshal(cp)
+		if err != nil {
+			return err
+		}
+		if err := wTx.Set(dbKeyStoredProof(cp.Index), cpBytes); err != nil {
+			return err
+		}
+		n++
+		// commit each chunk, so the db.WriteTx does not grow with the
+		// size of the Census
+		if n%c.chunkSize == 0 {
+			if err := wTx.Commit(); err != nil {
+				return err
+			}
+			wTx.Discard()
+			wTx = c.db.WriteTx()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := wTx.Set(dbKeyProofsStored, []byte{1}); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
+
+// ProofsStored returns true if the CensusProofs of all the PublicKeys of the
+// Census have been stored with StoreAllProofs
+func (c *Census) ProofsStored() (bool, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	_, err := rTx.Get(dbKeyProofsStored)
+	if err == db.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IterateProofs calls the given function with the CensusProof of each
+// PublicKey of the closed Census, in index order. The CensusProofs stored
+// with StoreAllProofs are used when available, otherwise they are generated
+// in a single pass over the MerkleTree. If the given function returns an
+// error, the iteration stops and the error is returned.
+func (c *Census) IterateProofs(fn func(cp *types.CensusProof) error) error {
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return err
+	}
+	if !isClosed {
+		return ErrCensusNotClosed
+	}
+	stored, err := c.ProofsStored()
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return c.generateProofs(fn)
+	}
+
+	var fnErr error
+	err = c.db.Iterate(dbPrefixStoredProof, func(k, v []byte) bool {
+		var cp types.CensusProof
+		if err := json.Unmarshal(v, &cp); err != nil {
+			fnErr = fmt.Errorf("can not decode the stored CensusProof"+
+				" of index %d: %s", binary.BigEndian.Uint64(k), err)
+			return false
+		}
+		if err := fn(&cp); err != nil {
+			fnErr = err
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// generateProofs calls the given function with the CensusProof of each
+// PublicKey of the Census, in index order, generating all of them from the
+// same db.ReadTx
+func (c *Census) generateProofs(fn func(cp *types.CensusProof) error) error {
+	var pubKComps []babyjub.PublicKeyComp
+	err := c.db.Iterate(dbPrefixIndexPubK, func(_, v []byte) bool {
+		var pubKComp babyjub.PublicKeyComp
+		copy(pubKComp[:], v)
+		pubKComps = append(pubKComps, pubKComp)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	for i := 0; i < len(pubKComps); i++ {
+		pubK, err := pubKComps[i].Decompress()
+		if err != nil {
+			return fmt.Errorf("can not decompress PublicKey %x: %s",
+				pubKComps[i][:], err)
+		}
+		index, weight, data, proof, err := c.genProofWithTx(rTx, pubK)
+		if err != nil {
+			return err
+		}
+		err = fn(&types.CensusProof{
+			Index:       index,
+			PublicKey:   pubK,
+			Weight:      weight,
+			MerkleProof: proof,
+			Data:        data,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteStoredProofs removes the CensusProofs stored by StoreAllProofs
+func (c *Census) deleteStoredProofs(wTx db.WriteTx) error {
+	var keys [][]byte
+	err := c.db.Iterate(dbPrefixStoredProof, func(k, _ []byte) bool {
+		keys = append(keys, append(append([]byte{}, dbPrefixStoredProof...), k...))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range append(keys, dbKeyProofsStored) {
+		if err := wTx.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
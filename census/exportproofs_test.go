@@ -0,0 +1,79 @@
+package census
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestStoreAllProofs(t *testing.T) {
+	c := qt.New(t)
+	census, err := New(Options{DB: newTestDB(c), ChunkSize: 3})
+	c.Assert(err, qt.IsNil)
+
+	pubKs, weights := genPublicKeys(10)
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+
+	// the CensusProofs of an open Census can not be stored nor iterated
+	c.Assert(census.StoreAllProofs(), qt.Equals, ErrCensusNotClosed)
+	err = census.IterateProofs(func(*types.CensusProof) error { return nil })
+	c.Assert(err, qt.Equals, ErrCensusNotClosed)
+
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	collect := func() []types.CensusProof {
+		var proofs []types.CensusProof
+		err := census.IterateProofs(func(cp *types.CensusProof) error {
+			proofs = append(proofs, *cp)
+			return nil
+		})
+		c.Assert(err, qt.IsNil)
+		return proofs
+	}
+
+	// without storing them, the CensusProofs are generated
+	generated := collect()
+	c.Assert(generated, qt.HasLen, len(pubKs))
+	stored, err := census.ProofsStored()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stored, qt.IsFalse)
+
+	c.Assert(census.StoreAllProofs(), qt.IsNil)
+	c.Assert(census.StoreAllProofs(), qt.IsNil)
+	stored, err = census.ProofsStored()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stored, qt.IsTrue)
+	proofs := collect()
+	// the stored CensusProofs are the same than the generated ones
+	proofsJSON, err := json.Marshal(proofs)
+	c.Assert(err, qt.IsNil)
+	generatedJSON, err := json.Marshal(generated)
+	c.Assert(err, qt.IsNil)
+	c.Assert(proofsJSON, qt.DeepEquals, generatedJSON)
+	for i := 0; i < len(proofs); i++ {
+		c.Assert(proofs[i].Index, qt.Equals, uint64(i))
+		c.Assert(proofs[i].PublicKey.Compress(), qt.Equals, pubKs[i].Compress())
+		v, err := CheckProof(root, proofs[i].MerkleProof, proofs[i].Index,
+			proofs[i].PublicKey, proofs[i].Weight)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+	}
+
+	// reopening the Census removes the stored CensusProofs
+	c.Assert(census.ReopenForVersion(), qt.IsNil)
+	stored, err = census.ProofsStored()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stored, qt.IsFalse)
+	n := 0
+	err = census.db.Iterate(dbPrefixStoredProof, func(_, _ []byte) bool {
+		n++
+		return true
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 0)
+}
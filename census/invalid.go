@@ -0,0 +1,137 @@
+package census
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+)
+
+// InvalidReason is used to define the reason why a PublicKey can not be added
+// to the Census
+type InvalidReason int
+
+var (
+	// InvalidOther indicates that the PublicKey can not be added for a
+	// reason not covered by the other InvalidReasons, described by the
+	// InvalidKey Error
+	InvalidOther InvalidReason = 0
+	// InvalidOffCurve indicates that the PublicKey is not a point of the
+	// BabyJubJub curve
+	InvalidOffCurve InvalidReason = 1
+	// InvalidDuplicateInBatch indicates that the PublicKey appears more
+	// than once in the same batch of PublicKeys
+	InvalidDuplicateInBatch InvalidReason = 2
+	// InvalidAlreadyPresent indicates that the PublicKey was already added
+	// to the Census
+	InvalidAlreadyPresent InvalidReason = 3
+)
+
+// String returns the description of the InvalidReason
+func (r InvalidReason) String() string {
+	switch r {
+	case InvalidOther:
+		return "other"
+	case InvalidOffCurve:
+		return "off-curve"
+	case InvalidDuplicateInBatch:
+		return "duplicate in batch"
+	case InvalidAlreadyPresent:
+		return "already present"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(r))
+	}
+}
+
+// InvalidKey contains the position in the batch of a PublicKey that can not be
+// added to the Census, together with the reason
+type InvalidKey struct {
+	Index  int
+	Reason InvalidReason
+	Error  error
+}
+
+// FormatInvalidKeys returns a message describing the given InvalidKeys, with
+// the number of InvalidKeys of each InvalidReason and the error of the first
+// InvalidKey
+func FormatInvalidKeys(invalids []InvalidKey) string {
+	if len(invalids) == 0 {
+		return "0 invalid keys"
+	}
+	var reasons []InvalidReason
+	count := make(map[InvalidReason]int)
+	for i := 0; i < len(invalids); i++ {
+		if count[invalids[i].Reason] == 0 {
+			reasons = append(reasons, invalids[i].Reason)
+		}
+		count[invalids[i].Reason]++
+	}
+	var counts []string
+	for _, r := range reasons {
+		counts = append(counts, fmt.Sprintf("%d %s", count[r], r))
+	}
+	return fmt.Sprintf("%d invalid keys (%s), invalid msg for key %d (%s): %s",
+		len(invalids), strings.Join(counts, ", "), invalids[0].Index,
+		invalids[0].Reason, invalids[0].Error)
+}
+
+// checkPublicKeys returns the InvalidKeys of the given batch of PublicKeys,
+// classifying the PublicKeys that are not on the curve, that appear more than
+// once in the batch (all except the first occurrence), or that are already
+// in the Census
+func (c *Census) checkPublicKeys(pubKs []babyjub.PublicKey) ([]InvalidKey, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	onCurve := make([]bool, len(pubKs))
+	err := c.parallelize(len(pubKs), func(i int) error {
+		onCurve[i] = pubKs[i].Point().InCurve()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var invalids []InvalidKey
+	seen := make(map[babyjub.PublicKeyComp]int, len(pubKs))
+	for i := 0; i < len(pubKs); i++ {
+		if !onCurve[i] {
+			invalids = append(invalids, InvalidKey{Index: i,
+				Reason: InvalidOffCurve,
+				Error:  fmt.Errorf("PublicKey is not on the curve")})
+			continue
+		}
+		pubKComp := pubKs[i].Compress()
+		if j, ok := seen[pubKComp]; ok {
+			invalids = append(invalids, InvalidKey{Index: i,
+				Reason: InvalidDuplicateInBatch,
+				Error:  fmt.Errorf("PublicKey already in the batch at %d", j)})
+			continue
+		}
+		seen[pubKComp] = i
+		present, err := c.hasPublicKeyWithTx(rTx, &pubKs[i])
+		if err != nil {
+			return nil, err
+		}
+		if present {
+			invalids = append(invalids, InvalidKey{Index: i,
+				Reason: InvalidAlreadyPresent,
+				Error:  fmt.Errorf("PublicKey already added")})
+		}
+	}
+	return invalids, nil
+}
+
+// invalidsFromArbo converts the given arbo.Invalid into InvalidKeys
+func invalidsFromArbo(arboInvalids []arbo.Invalid) []InvalidKey {
+	if len(arboInvalids) == 0 {
+		return nil
+	}
+	invalids := make([]InvalidKey, len(arboInvalids))
+	for i := 0; i < len(arboInvalids); i++ {
+		invalids[i] = InvalidKey{Index: arboInvalids[i].Index,
+			Reason: InvalidOther, Error: arboInvalids[i].Error}
+	}
+	return invalids
+}
@@ -0,0 +1,70 @@
+package census
+
+import (
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestAddPublicKeysInvalidReasons(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < 5; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs = append(pubKs, *sk.Public())
+		weights = append(weights, big.NewInt(1))
+	}
+	invalids, err := census.AddPublicKeys(pubKs[:2], weights[:2])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+
+	offCurve := babyjub.PublicKey{X: big.NewInt(1), Y: big.NewInt(1)}
+	batch := []babyjub.PublicKey{
+		pubKs[2],
+		offCurve, // off-curve
+		pubKs[1], // already present
+		pubKs[3],
+		pubKs[2], // duplicate in batch
+		pubKs[4],
+		pubKs[0], // already present
+	}
+	batchWeights := make([]*big.Int, len(batch))
+	for i := 0; i < len(batch); i++ {
+		batchWeights[i] = big.NewInt(1)
+	}
+	invalids, err = census.AddPublicKeys(batch, batchWeights)
+	c.Assert(err, qt.ErrorMatches, "Can not add 4 PublicKeys")
+	c.Assert(len(invalids), qt.Equals, 4)
+	c.Assert(invalids[0].Index, qt.Equals, 1)
+	c.Assert(invalids[0].Reason, qt.Equals, InvalidOffCurve)
+	c.Assert(invalids[1].Index, qt.Equals, 2)
+	c.Assert(invalids[1].Reason, qt.Equals, InvalidAlreadyPresent)
+	c.Assert(invalids[2].Index, qt.Equals, 4)
+	c.Assert(invalids[2].Reason, qt.Equals, InvalidDuplicateInBatch)
+	c.Assert(invalids[3].Index, qt.Equals, 6)
+	c.Assert(invalids[3].Reason, qt.Equals, InvalidAlreadyPresent)
+	c.Assert(FormatInvalidKeys(invalids), qt.Equals, "4 invalid keys (1 off-curve,"+
+		" 2 already present, 1 duplicate in batch), invalid msg for key 1"+
+		" (off-curve): PublicKey is not on the curve")
+
+	// none of the keys of the batch has been added
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(2))
+
+	// the same classification is done for a Census with SortKeys
+	census, err = New(Options{DB: newTestDB(c), SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	_, err = census.AddPublicKeys(pubKs[:2], weights[:2])
+	c.Assert(err, qt.IsNil)
+	invalids, err = census.AddPublicKeys(batch, batchWeights)
+	c.Assert(err, qt.Not(qt.IsNil))
+	c.Assert(len(invalids), qt.Equals, 4)
+	c.Assert(invalids[1].Reason, qt.Equals, InvalidAlreadyPresent)
+	c.Assert(invalids[2].Reason, qt.Equals, InvalidDuplicateInBatch)
+}
@@ -0,0 +1,151 @@
+package census
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// KeyStatus is used to define the result of adding a PublicKey to the Census
+// with AddPublicKeysWithResults
+type KeyStatus int
+
+var (
+	// KeyAdded indicates that the PublicKey has been added to the Census
+	KeyAdded KeyStatus = 0
+	// KeyDuplicate indicates that the PublicKey was already in the Census
+	// or appears before in the same batch, so it has not been added again
+	KeyDuplicate KeyStatus = 1
+	// KeyInvalid indicates that the PublicKey can not be added to the
+	// Census, for the reason described by the KeyResult
+	KeyInvalid KeyStatus = 2
+)
+
+// String returns the name of the KeyStatus
+func (s KeyStatus) String() string {
+	switch s {
+	case KeyAdded:
+		return "added"
+	case KeyDuplicate:
+		return "duplicate"
+	case KeyInvalid:
+		return "invalid"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// KeyResult contains the result of adding the PublicKey at the position Index
+// of the batch given to AddPublicKeysWithResults
+type KeyResult struct {
+	Index  int
+	Status KeyStatus
+	// Reason and Error describe why the PublicKey has not been added, when
+	// the Status is KeyDuplicate or KeyInvalid
+	Reason InvalidReason
+	Error  error
+}
+
+// keyResultFromInvalid returns the KeyResult of the given InvalidKey
+func keyResultFromInvalid(invalid InvalidKey) KeyResult {
+	status := KeyInvalid
+	if invalid.Reason == InvalidDuplicateInBatch ||
+		invalid.Reason == InvalidAlreadyPresent {
+		status = KeyDuplicate
+	}
+	return KeyResult{Index: invalid.Index, Status: status,
+		Reason: invalid.Reason, Error: invalid.Error}
+}
+
+// AddPublicKeysWithResults adds the given batch of PublicKeys, assigning
+// incremental indexes to each one, and returns a KeyResult for each one of
+// them, in the same order. Unlike AddPublicKeys, the PublicKeys that can not
+// be added do not prevent adding the rest of the batch: the keys not on the
+// curve or rejected by the MerkleTree are reported as KeyInvalid, and the
+// keys already in the Census or repeated in the batch as KeyDuplicate, so the
+// batch can be retried as is, or only with its failed keys. The valid keys are
+// added atomically, in a single db.WriteTx (the ChunkSize is not applied), so
+// if an error is returned without results none of them has been added.
+func (c *Census) AddPublicKeysWithResults(pubKs []babyjub.PublicKey,
+	weights []*big.Int) ([]KeyResult, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.checkCanAddPublicKeys(pubKs, weights); err != nil {
+		return nil, err
+	}
+
+	invalids, err := c.checkPublicKeys(pubKs)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]KeyResult, len(pubKs))
+	for i := 0; i < len(pubKs); i++ {
+		results[i] = KeyResult{Index: i, Status: KeyAdded}
+	}
+	for i := 0; i < len(invalids); i++ {
+		results[invalids[i].Index] = keyResultFromInvalid(invalids[i])
+	}
+
+	// positions contains the position in the batch of each key to add
+	var positions []int
+	for i := 0; i < len(results); i++ {
+		if results[i].Status == KeyAdded {
+			positions = append(positions, i)
+		}
+	}
+	if err := c.checkMaxNLeafs(len(positions)); err != nil {
+		return nil, err
+	}
+
+	// the keys rejected by the MerkleTree discard the db.WriteTx, so the
+	// rest of keys are added again without them
+	nAdded := 0
+	for len(positions) != 0 {
+		validPubKs := make([]babyjub.PublicKey, len(positions))
+		validWeights := make([]*big.Int, len(positions))
+		for i, pos := range positions {
+			validPubKs[i] = pubKs[pos]
+			validWeights[i] = weights[pos]
+		}
+		var chunkInvalids []InvalidKey
+		if c.sortKeys {
+			chunkInvalids, err = c.bufferPublicKeysChunk(validPubKs, validWeights)
+		} else {
+			chunkInvalids, err = c.addPublicKeysChunk(validPubKs, validWeights, nil)
+		}
+		if err == nil {
+			nAdded = len(positions)
+			break
+		}
+		if len(chunkInvalids) == 0 {
+			return nil, err
+		}
+		rejected := make(map[int]bool, len(chunkInvalids))
+		for i := 0; i < len(chunkInvalids); i++ {
+			invalid := chunkInvalids[i]
+			invalid.Index = positions[invalid.Index]
+			results[invalid.Index] = keyResultFromInvalid(invalid)
+			rejected[invalid.Index] = true
+		}
+		var remaining []int
+		for _, pos := range positions {
+			if !rejected[pos] {
+				remaining = append(remaining, pos)
+			}
+		}
+		positions = remaining
+	}
+	if c.nChunksSinceCheckpoint != 0 {
+		// the keys are already added, so the results are returned
+		if err := c.checkpoint(); err != nil {
+			return results, err
+		}
+	}
+	if !c.sortKeys && nAdded != 0 {
+		if err := c.recordRoot(nAdded); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,79 @@
+package census
+
+import (
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestAddPublicKeysWithResults(t *testing.T) {
+	c := qt.New(t)
+	census, err := New(Options{DB: newTestDB(c), ChunkSize: 2})
+	c.Assert(err, qt.IsNil)
+
+	pubKs, weights := genPublicKeys(6)
+	_, err = census.AddPublicKeys(pubKs[:2], weights[:2])
+	c.Assert(err, qt.IsNil)
+
+	offCurve := babyjub.PublicKey{X: big.NewInt(1), Y: big.NewInt(1)}
+	batch := []babyjub.PublicKey{
+		pubKs[2],
+		offCurve, // off-curve
+		pubKs[1], // already present
+		pubKs[3],
+		pubKs[2], // duplicate in batch
+		pubKs[4],
+	}
+	batchWeights := []*big.Int{weights[2], big.NewInt(1), weights[1],
+		weights[3], weights[2], weights[4]}
+	results, err := census.AddPublicKeysWithResults(batch, batchWeights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(results, qt.HasLen, len(batch))
+	expected := []struct {
+		status KeyStatus
+		reason InvalidReason
+	}{
+		{KeyAdded, InvalidOther},
+		{KeyInvalid, InvalidOffCurve},
+		{KeyDuplicate, InvalidAlreadyPresent},
+		{KeyAdded, InvalidOther},
+		{KeyDuplicate, InvalidDuplicateInBatch},
+		{KeyAdded, InvalidOther},
+	}
+	for i := 0; i < len(results); i++ {
+		c.Assert(results[i].Index, qt.Equals, i)
+		c.Assert(results[i].Status, qt.Equals, expected[i].status)
+		c.Assert(results[i].Reason, qt.Equals, expected[i].reason)
+		c.Assert(results[i].Error == nil, qt.Equals, results[i].Status == KeyAdded)
+	}
+
+	// the valid keys are added in a single db.WriteTx, with consecutive
+	// indexes, even if the ChunkSize is smaller
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(5))
+	for i := 0; i < 5; i++ {
+		cp, _, err := census.GetProvisionalProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(cp.Index, qt.Equals, uint64(i))
+	}
+
+	// retrying the same batch only reports duplicates and invalid keys
+	results, err = census.AddPublicKeysWithResults(batch, batchWeights)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < len(results); i++ {
+		c.Assert(results[i].Status, qt.Not(qt.Equals), KeyAdded)
+	}
+	size, err = census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(5))
+
+	// the batch errors do not add any key
+	_, err = census.AddPublicKeysWithResults(pubKs[5:], nil)
+	c.Assert(err, qt.ErrorMatches, ErrPubKsWeightsLen.Error()+".*")
+	c.Assert(census.Close(), qt.IsNil)
+	_, err = census.AddPublicKeysWithResults(pubKs[5:], weights[5:])
+	c.Assert(err, qt.Equals, ErrCensusClosed)
+}
@@ -0,0 +1,98 @@
+package census
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/db"
+)
+
+// dbPrefixLeafData is used to store the leaf data of the PublicKeys added with
+// AddMembers
+var dbPrefixLeafData = []byte("leafData")
+
+func dbKeyLeafData(pubKComp babyjub.PublicKeyComp) []byte {
+	return append(append([]byte{}, dbPrefixLeafData...), pubKComp[:]...)
+}
+
+// Member contains a PublicKey with its Weight and its optional leaf Data, to
+// be added to the Census with AddMembers
+type Member struct {
+	PublicKey babyjub.PublicKey
+	// Weight of the PublicKey, if nil a weight of 1 is used
+	Weight *big.Int
+	// Data is committed in the leaf value together with the PublicKey and
+	// the Weight (eg. a role byte), see types.HashLeafBytes. It can not be
+	// longer than types.MaxLeafDataLen. An empty Data results in the same
+	// leaf value than AddPublicKeys.
+	Data []byte
+}
+
+// AddMembers adds the given Members as AddPublicKeys does, committing the Data
+// of each Member in its leaf value. The CensusProofs of the Members contain
+// their Data, which is needed to verify them. Note that the circuit only
+// supports leafs without Data. Censuses with SortKeys do not support leaf
+// Data.
+func (c *Census) AddMembers(members []Member) ([]InvalidKey, error) {
+	pubKs := make([]babyjub.PublicKey, len(members))
+	weights := make([]*big.Int, len(members))
+	datas := make([][]byte, len(members))
+	withData := false
+	for i := 0; i < len(members); i++ {
+		if len(members[i].Data) > types.MaxLeafDataLen {
+			return nil, fmt.Errorf("leaf data of Member %d too long: %d,"+
+				" max: %d", i, len(members[i].Data), types.MaxLeafDataLen)
+		}
+		pubKs[i] = members[i].PublicKey
+		weights[i] = members[i].Weight
+		if weights[i] == nil {
+			weights[i] = big.NewInt(1)
+		}
+		datas[i] = members[i].Data
+		withData = withData || len(datas[i]) != 0
+	}
+	if withData && c.sortKeys {
+		return nil, fmt.Errorf("can not add Members with leaf data to a" +
+			" Census with SortKeys")
+	}
+	return c.addPublicKeys(pubKs, weights, datas)
+}
+
+// setLeafData stores the given leaf data of the given PublicKey, nothing is
+// stored for an empty leaf data
+func setLeafData(wTx db.WriteTx, pubKComp babyjub.PublicKeyComp,
+	data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return wTx.Set(dbKeyLeafData(pubKComp), data)
+}
+
+// getLeafData returns the stored leaf data of the given PublicKey, which is
+// nil for the PublicKeys without leaf data
+func getLeafData(rTx db.ReadTx, pubKComp babyjub.PublicKeyComp) ([]byte, error) {
+	data, err := rTx.Get(dbKeyLeafData(pubKComp))
+	if err == db.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// leafValue returns the leaf value of the given PublicKey with the given
+// weight, taking into account its stored leaf data, which is also returned
+func leafValue(rTx db.ReadTx, pubK *babyjub.PublicKey, weight *big.Int) (
+	[]byte, []byte, error) {
+	data, err := getLeafData(rTx, pubK.Compress())
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := types.HashLeafBytes(pubK, weight, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, data, nil
+}
@@ -0,0 +1,130 @@
+package census
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func genMembers(nKeys int) []Member {
+	var members []Member
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		m := Member{PublicKey: *sk.Public(), Weight: big.NewInt(int64(i + 1))}
+		// the even Members have a role byte as leaf data
+		if i%2 == 0 {
+			m.Data = []byte{byte(i)}
+		}
+		members = append(members, m)
+	}
+	return members
+}
+
+func TestAddMembers(t *testing.T) {
+	c := qt.New(t)
+	census, err := New(Options{DB: newTestDB(c), ChunkSize: 3})
+	c.Assert(err, qt.IsNil)
+
+	nKeys := 10
+	members := genMembers(nKeys)
+	invalids, err := census.AddMembers(members)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+
+	// the provisional proofs contain the leaf data
+	for i := 0; i < nKeys; i++ {
+		proof, root, err := census.GetProvisionalProof(&members[i].PublicKey)
+		c.Assert(err, qt.IsNil)
+		c.Assert([]byte(proof.Data), qt.DeepEquals, members[i].Data)
+		c.Assert(proof.Verify(root), qt.IsNil)
+	}
+
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	for i := 0; i < nKeys; i++ {
+		pubK := &members[i].PublicKey
+		has, err := census.HasPublicKey(pubK)
+		c.Assert(err, qt.IsNil)
+		c.Assert(has, qt.IsTrue)
+
+		index, proof, err := census.GetProof(pubK)
+		c.Assert(err, qt.IsNil)
+		v, err := CheckProofWithData(root, proof, index, pubK,
+			members[i].Weight, members[i].Data)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+
+		// the proof is not valid without the leaf data, nor with a
+		// different one
+		v, err = CheckProof(root, proof, index, pubK, members[i].Weight)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.Equals, len(members[i].Data) == 0)
+		v, err = CheckProofWithData(root, proof, index, pubK,
+			members[i].Weight, []byte{255})
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsFalse)
+	}
+
+	// the listed PublicKeys contain their leaf data
+	keys, err := census.PublicKeys()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(keys), qt.Equals, nKeys)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(keys[i].Data, qt.DeepEquals, members[i].Data)
+	}
+
+	ok, err := census.VerifyRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+}
+
+func TestAddMembersWithoutData(t *testing.T) {
+	c := qt.New(t)
+
+	// Members without leaf data result in the same CensusRoot than
+	// AddPublicKeys
+	members := genMembers(6)
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < len(members); i++ {
+		members[i].Data = nil
+		pubKs = append(pubKs, members[i].PublicKey)
+		weights = append(weights, members[i].Weight)
+	}
+
+	census0 := newTestCensus(c)
+	_, err := census0.AddMembers(members)
+	c.Assert(err, qt.IsNil)
+	c.Assert(census0.Close(), qt.IsNil)
+	root0, err := census0.Root()
+	c.Assert(err, qt.IsNil)
+
+	census1 := newTestCensus(c)
+	_, err = census1.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(census1.Close(), qt.IsNil)
+	root1, err := census1.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root0, qt.DeepEquals, root1)
+}
+
+func TestAddMembersInvalid(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	members := genMembers(2)
+	members[1].Data = make([]byte, types.MaxLeafDataLen+1)
+	_, err := census.AddMembers(members)
+	c.Assert(err, qt.ErrorMatches, "leaf data of Member 1 too long.*")
+
+	sortedCensus, err := New(Options{DB: newTestDB(c), SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	_, err = sortedCensus.AddMembers(genMembers(2))
+	c.Assert(err, qt.ErrorMatches, ".*Census with SortKeys")
+}
@@ -0,0 +1,160 @@
+package census
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/db"
+)
+
+var (
+	// dbPrefixIndexPubK is used to store the mapping Index->PublicKey,
+	// where the index is encoded in big-endian so the entries are sorted
+	// by index
+	dbPrefixIndexPubK = []byte("indexPubK")
+	// dbKeyIndexPubKMigrated is set once the Index->PublicKey mapping
+	// contains all the PublicKeys of the Census
+	dbKeyIndexPubKMigrated = []byte("migratedIndexPubK")
+)
+
+func dbKeyIndexPubK(index uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, index)
+	return append(append([]byte{}, dbPrefixIndexPubK...), b...)
+}
+
+// initIndexPubK returns true if the Index->PublicKey mapping contains all the
+// PublicKeys of the Census. For a Census without PublicKeys, the mapping is
+// marked as migrated.
+func (c *Census) initIndexPubK(wTx db.WriteTx) (bool, error) {
+	_, err := wTx.Get(dbKeyIndexPubKMigrated)
+	if err == nil {
+		return true, nil
+	} else if err != db.ErrKeyNotFound {
+		return false, err
+	}
+	nLeafs, err := c.tree.GetNLeafsWithTx(treeTx(wTx))
+	if err != nil {
+		return false, err
+	}
+	if nLeafs > 0 {
+		return false, nil
+	}
+	if err := wTx.Set(dbKeyIndexPubKMigrated, []byte{1}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// migrateIndexPubK builds the Index->PublicKey mapping for a Census created
+// before the mapping existed, from its PublicKey->Index,Weight mapping
+func (c *Census) migrateIndexPubK() error {
+	keys, err := c.indexedPublicKeys()
+	if err != nil {
+		return err
+	}
+	for from := 0; from < len(keys); from += c.chunkSize {
+		to := from + c.chunkSize
+		if to > len(keys) {
+			to = len(keys)
+		}
+		if err := c.migrateIndexPubKChunk(keys[from:to]); err != nil {
+			return err
+		}
+	}
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	if err := wTx.Set(dbKeyIndexPubKMigrated, []byte{1}); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
+
+func (c *Census) migrateIndexPubKChunk(keys []IndexedPublicKey) error {
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	for i := 0; i < len(keys); i++ {
+		pubKComp := keys[i].PublicKey.Compress()
+		if err := wTx.Set(dbKeyIndexPubK(keys[i].Index), pubKComp[:]); err != nil {
+			return err
+		}
+	}
+	return wTx.Commit()
+}
+
+// IterateLeaves calls the given function for each PublicKey in the Census
+// MerkleTree, with its index, in index order. The PublicKeys are read one by
+// one from the db, without loading all of them in memory. If the given
+// function returns an error, the iteration stops and the error is returned.
+// The PublicKeys buffered until the Census is closed when SortKeys is enabled
+// are not part of the MerkleTree yet.
+func (c *Census) IterateLeaves(fn func(index uint64, pubK babyjub.PublicKey) error) error {
+	var fnErr error
+	err := c.db.Iterate(dbPrefixIndexPubK, func(k, v []byte) bool {
+		index := binary.BigEndian.Uint64(k)
+		var pubKComp babyjub.PublicKeyComp
+		copy(pubKComp[:], v)
+		pubK, err := pubKComp.Decompress()
+		if err != nil {
+			fnErr = fmt.Errorf("can not decompress PublicKey of index %d: %s",
+				index, err)
+			return false
+		}
+		if err := fn(index, *pubK); err != nil {
+			fnErr = err
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// IterateLeafKeys calls the given function for the key of each leaf of the
+// Census MerkleTree, with its index, in index order, until the given function
+// returns false. The keys are the compressed PublicKeys, or the Ethereum
+// addresses for a Census of KeyTypeEthAddress, and are not decompressed, so
+// the Census can be walked faster than with IterateLeaves, without loading
+// all the keys in memory.
+func (c *Census) IterateLeafKeys(fn func(index uint64, key []byte) bool) error {
+	prefix := dbPrefixIndexPubK
+	if c.keyType == types.KeyTypeEthAddress {
+		prefix = dbPrefixIndexAddress
+	}
+	return c.db.Iterate(prefix, func(k, v []byte) bool {
+		// the db may reuse the value after the call
+		return fn(binary.BigEndian.Uint64(k), append([]byte{}, v...))
+	})
+}
+
+// IteratePublicKeys calls the given function for each PublicKey in the Census
+// MerkleTree, with its index, weight and leaf data, in index order, without
+// loading all of them in memory, as IterateLeaves does. If the given function
+// returns an error, the iteration stops and the error is returned.
+func (c *Census) IteratePublicKeys(fn func(key IndexedPublicKey) error) error {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	return c.IterateLeaves(func(index uint64, pubK babyjub.PublicKey) error {
+		pubKComp := pubK.Compress()
+		indexAndWeight, err := rTx.Get(pubKComp[:])
+		if err != nil {
+			return fmt.Errorf("can not get the weight of the PublicKey of"+
+				" index %d: %s", index, err)
+		}
+		_, weight, err := types.BytesToIndexAndWeight(indexAndWeight)
+		if err != nil {
+			return err
+		}
+		data, err := getLeafData(rTx, pubKComp)
+		if err != nil {
+			return err
+		}
+		return fn(IndexedPublicKey{Index: index, PublicKey: pubK,
+			Weight: weight, Data: data})
+	})
+}
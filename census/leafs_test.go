@@ -0,0 +1,185 @@
+package census
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestIterateLeaves(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	nKeys := 20
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubK := sk.Public()
+		pubKs = append(pubKs, *pubK)
+		weights = append(weights, big.NewInt(1))
+	}
+	_, err := census.AddPublicKeys(pubKs[:nKeys-1], weights[:nKeys-1])
+	c.Assert(err, qt.IsNil)
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{Index: 1000, PublicKey: pubKs[nKeys-1]},
+	})
+	c.Assert(err, qt.IsNil)
+
+	var indexes []uint64
+	err = census.IterateLeaves(func(index uint64, pubK babyjub.PublicKey) error {
+		indexes = append(indexes, index)
+		expected := pubKs[nKeys-1]
+		if index != 1000 {
+			expected = pubKs[index]
+		}
+		c.Assert(pubK.Compress(), qt.Equals, expected.Compress())
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(indexes), qt.Equals, nKeys)
+	for i := 0; i < nKeys-1; i++ {
+		c.Assert(indexes[i], qt.Equals, uint64(i))
+	}
+	c.Assert(indexes[nKeys-1], qt.Equals, uint64(1000))
+
+	// expect the iteration to stop on the first error
+	n := 0
+	err = census.IterateLeaves(func(index uint64, pubK babyjub.PublicKey) error {
+		n++
+		if index == 4 {
+			return fmt.Errorf("stop at %d", index)
+		}
+		return nil
+	})
+	c.Assert(err, qt.ErrorMatches, "stop at 4")
+	c.Assert(n, qt.Equals, 5)
+}
+
+func TestIterateLeafKeys(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	pubKs, weights := genPublicKeys(10)
+	_, err := census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+
+	var keys [][]byte
+	err = census.IterateLeafKeys(func(index uint64, key []byte) bool {
+		c.Assert(index, qt.Equals, uint64(len(keys)))
+		keys = append(keys, key)
+		return true
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(keys, qt.HasLen, len(pubKs))
+	for i := 0; i < len(pubKs); i++ {
+		pubKComp := pubKs[i].Compress()
+		c.Assert(keys[i], qt.DeepEquals, pubKComp[:])
+	}
+
+	// the iteration stops when the function returns false
+	n := 0
+	err = census.IterateLeafKeys(func(index uint64, _ []byte) bool {
+		n++
+		return index < 3
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 4)
+
+	// the keys of a Census of addresses are the addresses
+	census, err = New(Options{DB: newTestDB(c), KeyType: types.KeyTypeEthAddress})
+	c.Assert(err, qt.IsNil)
+	addrs, addrWeights := genAddresses(5)
+	_, err = census.AddAddresses(addrs, addrWeights)
+	c.Assert(err, qt.IsNil)
+	keys = nil
+	err = census.IterateLeafKeys(func(_ uint64, key []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(keys, qt.HasLen, len(addrs))
+	for i := 0; i < len(addrs); i++ {
+		c.Assert(keys[i], qt.DeepEquals, addrs[i].Bytes())
+	}
+}
+
+func TestMigrateIndexPubK(t *testing.T) {
+	c := qt.New(t)
+	database := newTestDB(c)
+	census, err := New(Options{DB: database})
+	c.Assert(err, qt.IsNil)
+
+	nKeys := 10
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubK := sk.Public()
+		pubKs = append(pubKs, *pubK)
+		weights = append(weights, big.NewInt(1))
+	}
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+
+	// remove the Index->PublicKey mapping, as in the Censuses created
+	// before the mapping existed
+	wTx := database.WriteTx()
+	for i := 0; i < nKeys; i++ {
+		c.Assert(wTx.Delete(dbKeyIndexPubK(uint64(i))), qt.IsNil)
+	}
+	c.Assert(wTx.Delete(dbKeyIndexPubKMigrated), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	wTx.Discard()
+
+	countLeaves := func() int {
+		n := 0
+		err := census.IterateLeaves(func(uint64, babyjub.PublicKey) error {
+			n++
+			return nil
+		})
+		c.Assert(err, qt.IsNil)
+		return n
+	}
+	c.Assert(countLeaves(), qt.Equals, 0)
+
+	// expect the mapping to be built when loading the Census
+	census, err = New(Options{DB: database})
+	c.Assert(err, qt.IsNil)
+	c.Assert(countLeaves(), qt.Equals, nKeys)
+}
+
+func TestIteratePublicKeys(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	nKeys := 10
+	members := make([]Member, nKeys)
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		members[i] = Member{PublicKey: *sk.Public(), Weight: big.NewInt(int64(i + 1))}
+		if i%2 == 0 {
+			members[i].Data = []byte{byte(i)}
+		}
+	}
+	_, err := census.AddMembers(members)
+	c.Assert(err, qt.IsNil)
+
+	var keys []IndexedPublicKey
+	err = census.IteratePublicKeys(func(key IndexedPublicKey) error {
+		keys = append(keys, key)
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(keys), qt.Equals, nKeys)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(keys[i].Index, qt.Equals, uint64(i))
+		c.Assert(keys[i].PublicKey.Compress(), qt.Equals, members[i].PublicKey.Compress())
+		c.Assert(keys[i].Weight.Cmp(members[i].Weight), qt.Equals, 0)
+		c.Assert(keys[i].Data, qt.DeepEquals, members[i].Data)
+	}
+}
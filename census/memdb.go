@@ -0,0 +1,133 @@
+package census
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"go.vocdoni.io/dvote/db"
+)
+
+// memDB implements the db.Database interface keeping all the entries in
+// memory, for the Censuses created with the InMemory option. The entries are
+// lost when the memDB is closed.
+type memDB struct {
+	mu sync.RWMutex
+	kv map[string][]byte
+}
+
+// check that memDB implements the db.Database interface
+var _ db.Database = (*memDB)(nil)
+
+func newMemDB() *memDB {
+	return &memDB{kv: make(map[string][]byte)}
+}
+
+// ReadTx implements the db.Database.ReadTx interface method
+func (d *memDB) ReadTx() db.ReadTx {
+	return d.WriteTx()
+}
+
+// WriteTx implements the db.Database.WriteTx interface method
+func (d *memDB) WriteTx() db.WriteTx {
+	return &memWriteTx{db: d, writes: make(map[string][]byte)}
+}
+
+// Iterate implements the db.Database.Iterate interface method. The entries
+// with the given prefix are copied before calling the callback, so the
+// callback can use the memDB.
+func (d *memDB) Iterate(prefix []byte, callback func(k, v []byte) bool) error {
+	d.mu.RLock()
+	var keys []string
+	for k := range d.kv {
+		if bytes.HasPrefix([]byte(k), prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = append([]byte{}, d.kv[k]...)
+	}
+	d.mu.RUnlock()
+
+	for i, k := range keys {
+		if !callback([]byte(k)[len(prefix):], values[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+// Close implements the db.Database.Close interface method, removing all the
+// entries
+func (d *memDB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.kv = make(map[string][]byte)
+	return nil
+}
+
+// memWriteTx implements the db.ReadTx and db.WriteTx interfaces, buffering the
+// writes until it is committed, where a nil value marks a deleted key
+type memWriteTx struct {
+	db     *memDB
+	writes map[string][]byte
+}
+
+// Get implements the db.ReadTx.Get interface method
+func (tx *memWriteTx) Get(k []byte) ([]byte, error) {
+	if v, ok := tx.writes[string(k)]; ok {
+		if v == nil {
+			return nil, db.ErrKeyNotFound
+		}
+		return append([]byte{}, v...), nil
+	}
+	tx.db.mu.RLock()
+	defer tx.db.mu.RUnlock()
+	v, ok := tx.db.kv[string(k)]
+	if !ok {
+		return nil, db.ErrKeyNotFound
+	}
+	return append([]byte{}, v...), nil
+}
+
+// Set implements the db.WriteTx.Set interface method
+func (tx *memWriteTx) Set(k, v []byte) error {
+	tx.writes[string(k)] = append([]byte{}, v...)
+	return nil
+}
+
+// Delete implements the db.WriteTx.Delete interface method
+func (tx *memWriteTx) Delete(k []byte) error {
+	tx.writes[string(k)] = nil
+	return nil
+}
+
+// Apply implements the db.WriteTx.Apply interface method
+func (tx *memWriteTx) Apply(other db.WriteTx) error {
+	for k, v := range other.(*memWriteTx).writes {
+		tx.writes[k] = v
+	}
+	return nil
+}
+
+// Commit implements the db.WriteTx.Commit interface method
+func (tx *memWriteTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	for k, v := range tx.writes {
+		if v == nil {
+			delete(tx.db.kv, k)
+		} else {
+			tx.db.kv[k] = v
+		}
+	}
+	tx.writes = make(map[string][]byte)
+	return nil
+}
+
+// Discard implements the db.ReadTx.Discard interface method
+func (tx *memWriteTx) Discard() {
+	tx.writes = nil
+}
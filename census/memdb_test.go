@@ -0,0 +1,87 @@
+package census
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/db"
+)
+
+func TestMemDB(t *testing.T) {
+	c := qt.New(t)
+	database := newMemDB()
+
+	wTx := database.WriteTx()
+	c.Assert(wTx.Set([]byte("ab"), []byte{1}), qt.IsNil)
+	c.Assert(wTx.Set([]byte("ac"), []byte{2}), qt.IsNil)
+	c.Assert(wTx.Set([]byte("b"), []byte{3}), qt.IsNil)
+	// the writes are visible from the same db.WriteTx before the commit
+	v, err := wTx.Get([]byte("ab"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.DeepEquals, []byte{1})
+	rTx := database.ReadTx()
+	_, err = rTx.Get([]byte("ab"))
+	c.Assert(err, qt.Equals, db.ErrKeyNotFound)
+	rTx.Discard()
+	c.Assert(wTx.Commit(), qt.IsNil)
+	wTx.Discard()
+
+	// a discarded db.WriteTx does not modify the db
+	wTx = database.WriteTx()
+	c.Assert(wTx.Delete([]byte("ab")), qt.IsNil)
+	_, err = wTx.Get([]byte("ab"))
+	c.Assert(err, qt.Equals, db.ErrKeyNotFound)
+	wTx.Discard()
+	rTx = database.ReadTx()
+	v, err = rTx.Get([]byte("ab"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.DeepEquals, []byte{1})
+	rTx.Discard()
+
+	// the entries are iterated in order, without the prefix
+	var keys []string
+	err = database.Iterate([]byte("a"), func(k, _ []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(keys, qt.DeepEquals, []string{"b", "c"})
+
+	c.Assert(database.Close(), qt.IsNil)
+	rTx = database.ReadTx()
+	defer rTx.Discard()
+	_, err = rTx.Get([]byte("b"))
+	c.Assert(err, qt.Equals, db.ErrKeyNotFound)
+}
+
+func TestInMemoryCensus(t *testing.T) {
+	c := qt.New(t)
+
+	_, err := New(Options{DB: newTestDB(c), InMemory: true})
+	c.Assert(err, qt.ErrorMatches, "the InMemory option can not be used.*")
+
+	inMemory, err := New(Options{InMemory: true})
+	c.Assert(err, qt.IsNil)
+	census := newTestCensus(c)
+
+	// the in-memory Census has the same root as a Census in a db
+	pubKs, weights := genPublicKeys(100)
+	for _, cen := range []*Census{inMemory, census} {
+		invalids, err := cen.AddPublicKeys(pubKs, weights)
+		c.Assert(err, qt.IsNil)
+		c.Assert(len(invalids), qt.Equals, 0)
+		c.Assert(cen.Close(), qt.IsNil)
+	}
+	root, err := inMemory.Root()
+	c.Assert(err, qt.IsNil)
+	expectedRoot, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, expectedRoot)
+
+	index, proof, err := inMemory.GetProof(&pubKs[42])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(42))
+	v, err := CheckProof(root, proof, index, &pubKs[42], weights[42])
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.IsTrue)
+}
@@ -0,0 +1,55 @@
+package census
+
+import (
+	"sync"
+)
+
+// minKeysPerHashWorker defines the minimum number of keys hashed by each hash
+// worker, so the small batches are hashed without spawning goroutines
+const minKeysPerHashWorker = 256
+
+// parallelize calls the given function for each i in [0, n), splitting the
+// range in contiguous parts handled by up to hashWorkers goroutines. The given
+// function must only write into the position i of its outputs. Returns the
+// error of the lowest i for which the function failed.
+func (c *Census) parallelize(n int, fn func(i int) error) error {
+	workers := c.hashWorkers
+	if maxWorkers := n / minKeysPerHashWorker; workers > maxWorkers {
+		workers = maxWorkers
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, workers)
+	partSize := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from, to := w*partSize, (w+1)*partSize
+		if to > n {
+			to = n
+		}
+		wg.Add(1)
+		go func(w, from, to int) {
+			defer wg.Done()
+			for i := from; i < to; i++ {
+				if err := fn(i); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}(w, from, to)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package census
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParallelize(t *testing.T) {
+	c := qt.New(t)
+	census := &Census{hashWorkers: 4}
+
+	// each i is handled once, by 4 workers
+	n := 4*minKeysPerHashWorker + 1
+	var calls int64
+	seen := make([]int, n)
+	err := census.parallelize(n, func(i int) error {
+		atomic.AddInt64(&calls, 1)
+		seen[i]++
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(calls, qt.Equals, int64(n))
+	for i := 0; i < n; i++ {
+		c.Assert(seen[i], qt.Equals, 1)
+	}
+
+	// the error of the lowest failed i is returned
+	err = census.parallelize(n, func(i int) error {
+		if i == 10 || i == n-1 {
+			return fmt.Errorf("error at %d", i)
+		}
+		return nil
+	})
+	c.Assert(err, qt.ErrorMatches, "error at 10")
+
+	// the small batches are handled without goroutines
+	err = census.parallelize(minKeysPerHashWorker, func(i int) error {
+		return fmt.Errorf("error at %d", i)
+	})
+	c.Assert(err, qt.ErrorMatches, "error at 0")
+}
+
+func TestHashWorkers(t *testing.T) {
+	c := qt.New(t)
+
+	// the CensusRoot does not depend on the number of HashWorkers
+	pubKs, weights := genPublicKeys(1000)
+	var roots [][]byte
+	for _, hashWorkers := range []int{1, 4} {
+		census, err := New(Options{DB: newTestDB(c), HashWorkers: hashWorkers})
+		c.Assert(err, qt.IsNil)
+		invalids, err := census.AddPublicKeys(pubKs, weights)
+		c.Assert(err, qt.IsNil)
+		c.Assert(len(invalids), qt.Equals, 0)
+		c.Assert(census.Close(), qt.IsNil)
+		root, err := census.Root()
+		c.Assert(err, qt.IsNil)
+		roots = append(roots, root)
+	}
+	c.Assert(roots[1], qt.DeepEquals, roots[0])
+}
+
+func BenchmarkAddPublicKeys(b *testing.B) {
+	c := qt.New(b)
+	pubKs, weights := genPublicKeys(10000)
+	hashWorkersList := []int{1}
+	if runtime.NumCPU() > 1 {
+		hashWorkersList = append(hashWorkersList, runtime.NumCPU())
+	}
+	for _, hashWorkers := range hashWorkersList {
+		b.Run(fmt.Sprintf("HashWorkers=%d", hashWorkers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				census, err := New(Options{DB: newTestDB(c),
+					HashWorkers: hashWorkers})
+				c.Assert(err, qt.IsNil)
+				_, err = census.AddPublicKeys(pubKs, weights)
+				c.Assert(err, qt.IsNil)
+				c.Assert(census.CloseDB(), qt.IsNil)
+			}
+		})
+	}
+}
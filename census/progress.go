@@ -0,0 +1,91 @@
+package census
+
+import (
+	"encoding/json"
+
+	"go.vocdoni.io/dvote/db"
+)
+
+var dbKeyProgress = []byte("progress")
+
+// Progress contains the progress of the batches of PublicKeys added to the
+// Census asynchronously (see CensusBuilder.AddPublicKeysAndStoreError), so the
+// clients can poll the status of the import of large sets of PublicKeys
+type Progress struct {
+	// Added is the number of PublicKeys of the processed batches that have
+	// been added
+	Added uint64 `json:"added"`
+	// Pending is the number of PublicKeys of the batches waiting to be
+	// processed
+	Pending uint64 `json:"pending"`
+	// Failed is the number of PublicKeys of the processed batches that
+	// gave error
+	Failed uint64 `json:"failed"`
+	// Percentage is the percentage of the PublicKeys that have been
+	// processed, computed when the Progress is updated
+	Percentage float64 `json:"percentage"`
+	// LastError contains the error of the last batch that gave error
+	LastError string `json:"lastError,omitempty"`
+}
+
+// computePercentage sets the Percentage from the processed and pending
+// PublicKeys
+func (p *Progress) computePercentage() {
+	processed := p.Added + p.Failed
+	if processed+p.Pending == 0 {
+		p.Percentage = 100 //nolint:gomnd
+		return
+	}
+	p.Percentage = 100 * float64(processed) / float64(processed+p.Pending) //nolint:gomnd
+}
+
+func getProgress(rTx db.ReadTx) (*Progress, error) {
+	b, err := rTx.Get(dbKeyProgress)
+	if err == db.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var p Progress
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Progress returns the stored Progress of the Census, or nil if no batches of
+// PublicKeys have been added asynchronously
+func (c *Census) Progress() (*Progress, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	return getProgress(rTx)
+}
+
+// UpdateProgress calls the given function with the stored Progress of the
+// Census (a zero Progress if there is none), and stores the modified Progress
+// with its Percentage updated. The updates are serialized, so they can be
+// called concurrently.
+func (c *Census) UpdateProgress(fn func(p *Progress)) error {
+	c.progressMu.Lock()
+	defer c.progressMu.Unlock()
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	p, err := getProgress(wTx)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		p = &Progress{}
+	}
+	fn(p)
+	p.computePercentage()
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := wTx.Set(dbKeyProgress, b); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
@@ -0,0 +1,51 @@
+package census
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestProgress(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	// no Progress is stored until it is updated
+	p, err := census.Progress()
+	c.Assert(err, qt.IsNil)
+	c.Assert(p, qt.IsNil)
+	info, err := census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Progress, qt.IsNil)
+
+	err = census.UpdateProgress(func(p *Progress) {
+		p.Pending += 40
+	})
+	c.Assert(err, qt.IsNil)
+	p, err = census.Progress()
+	c.Assert(err, qt.IsNil)
+	c.Assert(*p, qt.DeepEquals, Progress{Pending: 40, Percentage: 0})
+
+	err = census.UpdateProgress(func(p *Progress) {
+		p.Pending -= 10
+		p.Added += 5
+		p.Failed += 5
+		p.LastError = "duplicated PublicKey"
+	})
+	c.Assert(err, qt.IsNil)
+	info, err = census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(*info.Progress, qt.DeepEquals, Progress{Added: 5, Pending: 30,
+		Failed: 5, Percentage: 25, LastError: "duplicated PublicKey"})
+
+	// with no pending keys the Percentage is 100
+	err = census.UpdateProgress(func(p *Progress) {
+		p.Added += p.Pending
+		p.Pending = 0
+	})
+	c.Assert(err, qt.IsNil)
+	p, err = census.Progress()
+	c.Assert(err, qt.IsNil)
+	c.Assert(p.Added, qt.Equals, uint64(35))
+	c.Assert(p.Percentage, qt.Equals, float64(100))
+}
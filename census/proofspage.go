@@ -0,0 +1,79 @@
+package census
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// ProofsPage returns the CensusProofs of the PublicKeys of the Census with an
+// index equal or greater than the given startIndex, in index order, up to the
+// given limit. The indexes without PublicKey are skipped. Together with the
+// CensusProofs, it returns the index from which the next page starts, which
+// is 0 when there are no more PublicKeys. The Census needs to be closed.
+func (c *Census) ProofsPage(startIndex uint64, limit int) ([]types.CensusProof,
+	uint64, error) {
+	if limit <= 0 {
+		return nil, 0, fmt.Errorf("invalid limit %d, must be greater than 0", limit)
+	}
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return nil, 0, err
+	}
+	if !isClosed {
+		return nil, 0, ErrCensusNotClosed
+	}
+
+	// get the PublicKeys of the page, and the index of the first
+	// PublicKey of the next page, if any
+	var pubKs []babyjub.PublicKey
+	var next uint64
+	var decompressErr error
+	err = c.db.Iterate(dbPrefixIndexPubK, func(k, v []byte) bool {
+		index := binary.BigEndian.Uint64(k)
+		if index < startIndex {
+			return true
+		}
+		if len(pubKs) == limit {
+			next = index
+			return false
+		}
+		var pubKComp babyjub.PublicKeyComp
+		copy(pubKComp[:], v)
+		pubK, err := pubKComp.Decompress()
+		if err != nil {
+			decompressErr = fmt.Errorf("can not decompress PublicKey of"+
+				" index %d: %s", index, err)
+			return false
+		}
+		pubKs = append(pubKs, *pubK)
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if decompressErr != nil {
+		return nil, 0, decompressErr
+	}
+
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	proofs := make([]types.CensusProof, len(pubKs))
+	for i := 0; i < len(pubKs); i++ {
+		index, weight, data, proof, err := c.genProofWithTx(rTx, &pubKs[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		proofs[i] = types.CensusProof{
+			Index:       index,
+			PublicKey:   &pubKs[i],
+			Weight:      weight,
+			MerkleProof: proof,
+			Data:        data,
+		}
+	}
+	return proofs, next, nil
+}
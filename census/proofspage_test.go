@@ -0,0 +1,95 @@
+package census
+
+import (
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestProofsPage(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	nKeys := 10
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs = append(pubKs, *sk.Public())
+		weights = append(weights, big.NewInt(int64(i+1)))
+	}
+
+	// a sparse tree: 7 keys at the indexes 0..6, and 3 keys at explicit
+	// indexes leaving gaps
+	_, err := census.AddPublicKeys(pubKs[:7], weights[:7])
+	c.Assert(err, qt.IsNil)
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{Index: 10, PublicKey: pubKs[7], Weight: weights[7]},
+		{Index: 12, PublicKey: pubKs[8], Weight: weights[8]},
+		{Index: 300, PublicKey: pubKs[9], Weight: weights[9]},
+	})
+	c.Assert(err, qt.IsNil)
+	expectedIndexes := []uint64{0, 1, 2, 3, 4, 5, 6, 10, 12, 300}
+
+	// expect error when the census is not closed
+	_, _, err = census.ProofsPage(0, 4)
+	c.Assert(err, qt.ErrorMatches, ErrCensusNotClosed.Error())
+
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	// expect error with a non positive limit
+	_, _, err = census.ProofsPage(0, 0)
+	c.Assert(err, qt.ErrorMatches, "invalid limit 0.*")
+
+	// traverse all the pages
+	var indexes []uint64
+	var cursors []uint64
+	cursor := uint64(0)
+	for {
+		proofs, next, err := census.ProofsPage(cursor, 4)
+		c.Assert(err, qt.IsNil)
+		c.Assert(len(proofs) <= 4, qt.IsTrue)
+		for i := 0; i < len(proofs); i++ {
+			indexes = append(indexes, proofs[i].Index)
+			v, err := CheckProof(root, proofs[i].MerkleProof, proofs[i].Index,
+				proofs[i].PublicKey, proofs[i].Weight)
+			c.Assert(err, qt.IsNil)
+			c.Assert(v, qt.IsTrue)
+		}
+		if next == 0 {
+			break
+		}
+		cursors = append(cursors, next)
+		cursor = next
+	}
+	c.Assert(indexes, qt.DeepEquals, expectedIndexes)
+	// the cursors point to the next existing index, skipping the gaps
+	c.Assert(cursors, qt.DeepEquals, []uint64{4, 12})
+
+	// resume from an index without PublicKey
+	proofs, next, err := census.ProofsPage(7, 2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(proofs), qt.Equals, 2)
+	c.Assert(proofs[0].Index, qt.Equals, uint64(10))
+	c.Assert(proofs[0].PublicKey.Compress(), qt.Equals, pubKs[7].Compress())
+	c.Assert(proofs[0].Weight.Cmp(weights[7]), qt.Equals, 0)
+	c.Assert(proofs[1].Index, qt.Equals, uint64(12))
+	c.Assert(next, qt.Equals, uint64(300))
+
+	// a page that reaches exactly the last PublicKey
+	proofs, next, err = census.ProofsPage(300, 1)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(proofs), qt.Equals, 1)
+	c.Assert(next, qt.Equals, uint64(0))
+
+	// beyond the last PublicKey, expect an empty page
+	proofs, next, err = census.ProofsPage(301, 4)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(proofs), qt.Equals, 0)
+	c.Assert(next, qt.Equals, uint64(0))
+}
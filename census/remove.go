@@ -0,0 +1,314 @@
+package census
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+// ErrPublicKeyNotFound is used when trying to remove or replace, or to get the
+// CensusProof of, a PublicKey that is not in the Census
+var ErrPublicKeyNotFound = errors.New("PublicKey not found in the Census")
+
+// checkEditable returns error if the PublicKeys of the Census can not be
+// modified, which is the case once it is closed or sealed
+func (c *Census) checkEditable() error {
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return err
+	}
+	if isClosed {
+		return ErrCensusClosed
+	}
+	isSealed, err := c.IsSealed()
+	if err != nil {
+		return err
+	}
+	if isSealed {
+		return ErrCensusSealed
+	}
+	return nil
+}
+
+// RemovePublicKeys removes the given PublicKeys from the open Census. If any
+// of the PublicKeys is not in the Census, ErrPublicKeyNotFound is returned
+// and none of them is removed. The indexes of the removed PublicKeys are not
+// assigned again. As the MerkleTree does not support removing leafs, it is
+// built again without the removed PublicKeys, so the cost of each call grows
+// with the Size of the Census. The Snapshots of the Census are removed, and
+// the removal is recorded in the RootHistory.
+func (c *Census) RemovePublicKeys(pubKs []babyjub.PublicKey) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.checkEditable(); err != nil {
+		return err
+	}
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	if c.sortKeys {
+		// the PublicKeys are not in the MerkleTree until the Census
+		// is closed
+		for i := 0; i < len(pubKs); i++ {
+			key := dbKeyPendingKey(pubKs[i].Compress())
+			if _, err := wTx.Get(key); err == db.ErrKeyNotFound {
+				return fmt.Errorf("%s, PublicKey %d of the batch",
+					ErrPublicKeyNotFound, i)
+			} else if err != nil {
+				return err
+			}
+			if err := wTx.Delete(key); err != nil {
+				return err
+			}
+		}
+		nPending, err := c.getNPendingKeys(wTx)
+		if err != nil {
+			return err
+		}
+		if err := c.setNPendingKeys(wTx, nPending-uint64(len(pubKs))); err != nil {
+			return err
+		}
+		return wTx.Commit()
+	}
+
+	removed := make(map[uint64]bool, len(pubKs))
+	for i := 0; i < len(pubKs); i++ {
+		pubKComp := pubKs[i].Compress()
+		indexAndWeight, err := wTx.Get(pubKComp[:])
+		if err == db.ErrKeyNotFound {
+			return fmt.Errorf("%s, PublicKey %d of the batch",
+				ErrPublicKeyNotFound, i)
+		} else if err != nil {
+			return err
+		}
+		index, _, err := types.BytesToIndexAndWeight(indexAndWeight)
+		if err != nil {
+			return err
+		}
+		if err := c.discardPublicKey(wTx, index, pubKComp); err != nil {
+			return err
+		}
+		removed[index] = true
+	}
+	if err := c.rebuildTree(wTx, removed); err != nil {
+		return err
+	}
+	if err := c.storeRootRecord(wTx, RootRecordRemoval, len(pubKs)); err != nil {
+		return err
+	}
+	if err := c.deleteSnapshots(wTx, func(*Snapshot) bool { return true }); err != nil {
+		return err
+	}
+	if err := c.storeCheckpoint(wTx); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
+
+// rebuildTree builds again the MerkleTree in the given db.WriteTx from the
+// Index->PublicKey and PublicKey->Index,Weight mappings, skipping the given
+// removed indexes, which mappings are only removed in the db.WriteTx. As arbo
+// does not support removing leafs, the MerkleTree is built in a fresh arbo
+// tree of the next generation (see treeDB), which is used by the Census once
+// the db.WriteTx is committed, and the nodes of the replaced MerkleTree are
+// deleted.
+func (c *Census) rebuildTree(wTx db.WriteTx, removed map[uint64]bool) error {
+	var indexes, values [][]byte
+	err := c.IterateLeaves(func(index uint64, pubK babyjub.PublicKey) error {
+		if removed[index] {
+			return nil
+		}
+		pubKComp := pubK.Compress()
+		indexAndWeight, err := wTx.Get(pubKComp[:])
+		if err != nil {
+			return fmt.Errorf("can not get the weight of the PublicKey of"+
+				" index %d: %s", index, err)
+		}
+		_, weight, err := types.BytesToIndexAndWeight(indexAndWeight)
+		if err != nil {
+			return err
+		}
+		value, _, err := leafValue(wTx, &pubK, weight)
+		if err != nil {
+			return err
+		}
+		indexes = append(indexes, c.indexKey(index))
+		values = append(values, value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := c.deleteTree(wTx); err != nil {
+		return err
+	}
+	generation, err := getTreeGeneration(wTx)
+	if err != nil {
+		return err
+	}
+	generation++
+	if err := setTreeGeneration(wTx, generation); err != nil {
+		return err
+	}
+	config := c.treeConfig
+	config.Database = &treeDB{db: c.db, generation: &generation}
+	tree, err := arbo.NewTreeWithTx(treeTx(wTx), config)
+	if err != nil {
+		return err
+	}
+	invalids, err := tree.AddBatchWithTx(treeTx(wTx), indexes, values)
+	if err != nil {
+		return err
+	}
+	if len(invalids) != 0 {
+		return fmt.Errorf("can not rebuild the MerkleTree, %d invalid leafs,"+
+			" first error: %s", len(invalids), invalids[0].Error)
+	}
+	return nil
+}
+
+// deleteTree deletes in the given db.WriteTx the nodes of the current
+// MerkleTree, which needs to be unmodified in the db.WriteTx. The MerkleTree
+// of the generation 0 is stored together with the mappings of the Census, so
+// only its nodes under the current root are deleted, and the nodes of its
+// previous roots are kept.
+func (c *Census) deleteTree(wTx db.WriteTx) error {
+	generation, err := getTreeGeneration(wTx)
+	if err != nil {
+		return err
+	}
+	var keys [][]byte
+	if generation > 0 {
+		err = c.db.Iterate(dbPrefixTreeGeneration(generation),
+			func(k, _ []byte) bool {
+				keys = append(keys, append([]byte{}, k...))
+				return true
+			})
+	} else {
+		var root []byte
+		root, err = c.tree.RootWithTx(wTx)
+		if err != nil {
+			return err
+		}
+		err = c.tree.IterateWithTx(wTx, root, func(k, v []byte) {
+			if v[0] != arbo.PrefixValueEmpty {
+				keys = append(keys, append([]byte{}, k...))
+			}
+		})
+	}
+	if err != nil {
+		return err
+	}
+	tx := treeTx(wTx)
+	for i := 0; i < len(keys); i++ {
+		if err := tx.Delete(keys[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplacePublicKey replaces the given oldPubK by the given newPubK in the open
+// Census, keeping its index and its leaf data. If weight is nil, the weight of
+// the oldPubK is kept. Returns ErrPublicKeyNotFound if the oldPubK is not in
+// the Census, and error if the newPubK is not valid or is already in the
+// Census. The Snapshots of the Census are removed, and the replacement is
+// recorded in the RootHistory.
+func (c *Census) ReplacePublicKey(oldPubK, newPubK babyjub.PublicKey,
+	weight *big.Int) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.checkEditable(); err != nil {
+		return err
+	}
+	invalids, err := c.checkPublicKeys([]babyjub.PublicKey{newPubK})
+	if err != nil {
+		return err
+	}
+	if len(invalids) != 0 {
+		return fmt.Errorf("can not replace by the new PublicKey: %s",
+			invalids[0].Error)
+	}
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	oldPubKComp := oldPubK.Compress()
+	newPubKComp := newPubK.Compress()
+	if c.sortKeys {
+		oldKey := dbKeyPendingKey(oldPubKComp)
+		weightBytes, err := wTx.Get(oldKey)
+		if err == db.ErrKeyNotFound {
+			return ErrPublicKeyNotFound
+		} else if err != nil {
+			return err
+		}
+		if weight != nil {
+			weightBytes = arbo.BigIntToBytes(32, weight) //nolint:gomnd
+		}
+		if err := wTx.Delete(oldKey); err != nil {
+			return err
+		}
+		if err := wTx.Set(dbKeyPendingKey(newPubKComp), weightBytes); err != nil {
+			return err
+		}
+		return wTx.Commit()
+	}
+
+	indexAndWeight, err := wTx.Get(oldPubKComp[:])
+	if err == db.ErrKeyNotFound {
+		return ErrPublicKeyNotFound
+	} else if err != nil {
+		return err
+	}
+	index, oldWeight, err := types.BytesToIndexAndWeight(indexAndWeight)
+	if err != nil {
+		return err
+	}
+	if weight == nil {
+		weight = oldWeight
+	}
+	data, err := getLeafData(wTx, oldPubKComp)
+	if err != nil {
+		return err
+	}
+	if err := wTx.Delete(dbKeyLeafData(oldPubKComp)); err != nil {
+		return err
+	}
+	if err := wTx.Delete(oldPubKComp[:]); err != nil {
+		return err
+	}
+
+	if err := wTx.Set(newPubKComp[:],
+		types.IndexAndWeightToBytes(index, weight)); err != nil {
+		return err
+	}
+	if err := wTx.Set(dbKeyIndexPubK(index), newPubKComp[:]); err != nil {
+		return err
+	}
+	if err := setLeafData(wTx, newPubKComp, data); err != nil {
+		return err
+	}
+	value, err := types.HashLeafBytes(&newPubK, weight, data)
+	if err != nil {
+		return err
+	}
+	if err := c.tree.UpdateWithTx(treeTx(wTx), c.indexKey(index), value); err != nil {
+		return err
+	}
+	if err := c.storeRootRecord(wTx, RootRecordReplacement, 1); err != nil {
+		return err
+	}
+	if err := c.deleteSnapshots(wTx, func(*Snapshot) bool { return true }); err != nil {
+		return err
+	}
+	if err := c.storeCheckpoint(wTx); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
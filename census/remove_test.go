@@ -0,0 +1,267 @@
+package census
+
+import (
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+func genPublicKeys(nKeys int) ([]babyjub.PublicKey, []*big.Int) {
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs = append(pubKs, *sk.Public())
+		weights = append(weights, big.NewInt(int64(i+1)))
+	}
+	return pubKs, weights
+}
+
+func TestRemovePublicKeys(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	pubKs, weights := genPublicKeys(10)
+	_, err := census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+
+	// if any of the PublicKeys is not in the Census, none is removed
+	notAdded, _ := genPublicKeys(1)
+	err = census.RemovePublicKeys([]babyjub.PublicKey{pubKs[0], notAdded[0]})
+	c.Assert(err, qt.ErrorMatches, ErrPublicKeyNotFound.Error()+".*")
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(10))
+
+	err = census.RemovePublicKeys([]babyjub.PublicKey{pubKs[2], pubKs[7]})
+	c.Assert(err, qt.IsNil)
+	size, err = census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(8))
+	ok, err := census.HasPublicKey(&pubKs[2])
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+
+	// the indexes of the removed PublicKeys are not assigned again
+	more, moreWeights := genPublicKeys(1)
+	_, err = census.AddPublicKeys(more, moreWeights)
+	c.Assert(err, qt.IsNil)
+	// the Checkpoint contains the rebuilt MerkleTree
+	discarded, err := census.Recover()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(discarded), qt.Equals, 0)
+
+	c.Assert(census.Close(), qt.IsNil)
+	ok, err = census.VerifyRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	index, _, err := census.GetProof(&more[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(10))
+	_, _, err = census.GetProof(&pubKs[7])
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	// the expected CensusRoot is the one of the remaining PublicKeys at
+	// their indexes
+	var keys []IndexedPublicKey
+	for i := 0; i < len(pubKs); i++ {
+		if i == 2 || i == 7 {
+			continue
+		}
+		keys = append(keys, IndexedPublicKey{Index: uint64(i),
+			PublicKey: pubKs[i], Weight: weights[i]})
+	}
+	keys = append(keys, IndexedPublicKey{Index: 10, PublicKey: more[0],
+		Weight: moreWeights[0]})
+	expected := newTestCensus(c)
+	c.Assert(expected.AddPublicKeysAtIndices(keys), qt.IsNil)
+	c.Assert(expected.Close(), qt.IsNil)
+	expectedRoot, err := expected.Root()
+	c.Assert(err, qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, expectedRoot)
+
+	// the PublicKeys of a closed Census can not be removed
+	err = census.RemovePublicKeys(pubKs[:1])
+	c.Assert(err, qt.Equals, ErrCensusClosed)
+}
+
+func TestReplacePublicKey(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	pubKs, weights := genPublicKeys(5)
+	_, err := census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	newPubKs, _ := genPublicKeys(2)
+
+	err = census.ReplacePublicKey(newPubKs[0], newPubKs[1], nil)
+	c.Assert(err, qt.Equals, ErrPublicKeyNotFound)
+	err = census.ReplacePublicKey(pubKs[0], pubKs[1], nil)
+	c.Assert(err, qt.ErrorMatches, "can not replace by the new PublicKey.*")
+
+	// the new PublicKey keeps the index and the weight
+	err = census.ReplacePublicKey(pubKs[3], newPubKs[0], nil)
+	c.Assert(err, qt.IsNil)
+	// the weight is changed
+	err = census.ReplacePublicKey(pubKs[4], newPubKs[1], big.NewInt(42))
+	c.Assert(err, qt.IsNil)
+	ok, err := census.HasPublicKey(&pubKs[3])
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(5))
+
+	c.Assert(census.Close(), qt.IsNil)
+	ok, err = census.VerifyRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	index, _, err := census.GetProof(&newPubKs[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(3))
+
+	expected := newTestCensus(c)
+	_, err = expected.AddPublicKeys([]babyjub.PublicKey{pubKs[0], pubKs[1],
+		pubKs[2], newPubKs[0], newPubKs[1]}, []*big.Int{weights[0],
+		weights[1], weights[2], weights[3], big.NewInt(42)})
+	c.Assert(err, qt.IsNil)
+	c.Assert(expected.Close(), qt.IsNil)
+	expectedRoot, err := expected.Root()
+	c.Assert(err, qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, expectedRoot)
+
+	err = census.ReplacePublicKey(newPubKs[0], pubKs[3], nil)
+	c.Assert(err, qt.Equals, ErrCensusClosed)
+}
+
+func TestRemoveAndReplaceSortKeys(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c), SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	pubKs, weights := genPublicKeys(6)
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	newPubKs, _ := genPublicKeys(1)
+
+	err = census.RemovePublicKeys(pubKs[:2])
+	c.Assert(err, qt.IsNil)
+	err = census.RemovePublicKeys(pubKs[:1])
+	c.Assert(err, qt.ErrorMatches, ErrPublicKeyNotFound.Error()+".*")
+	err = census.ReplacePublicKey(pubKs[2], newPubKs[0], nil)
+	c.Assert(err, qt.IsNil)
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(4))
+	c.Assert(census.Close(), qt.IsNil)
+
+	expected, err := New(Options{DB: newTestDB(c), SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	_, err = expected.AddPublicKeys([]babyjub.PublicKey{newPubKs[0], pubKs[3],
+		pubKs[4], pubKs[5]}, []*big.Int{weights[2], weights[3], weights[4],
+		weights[5]})
+	c.Assert(err, qt.IsNil)
+	c.Assert(expected.Close(), qt.IsNil)
+	expectedRoot, err := expected.Root()
+	c.Assert(err, qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, expectedRoot)
+}
+
+func TestRemoveRebuildsFreshTree(t *testing.T) {
+	c := qt.New(t)
+	database := newTestDB(c)
+	census, err := New(Options{DB: database})
+	c.Assert(err, qt.IsNil)
+
+	pubKs, weights := genPublicKeys(20)
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	treeKeys := func() [][]byte {
+		root, err := census.tree.Root()
+		c.Assert(err, qt.IsNil)
+		var keys [][]byte
+		err = census.tree.Iterate(root, func(k, v []byte) {
+			if v[0] != arbo.PrefixValueEmpty {
+				keys = append(keys, append([]byte{}, k...))
+			}
+		})
+		c.Assert(err, qt.IsNil)
+		return keys
+	}
+	oldKeys := treeKeys()
+	c.Assert(len(oldKeys) > 0, qt.IsTrue)
+
+	// the MerkleTree is built again in the generation 1, and the nodes of
+	// the generation 0 are deleted
+	c.Assert(census.RemovePublicKeys(pubKs[:2]), qt.IsNil)
+	rTx := database.ReadTx()
+	generation, err := getTreeGeneration(rTx)
+	c.Assert(err, qt.IsNil)
+	c.Assert(generation, qt.Equals, uint64(1))
+	for i := 0; i < len(oldKeys); i++ {
+		_, err = rTx.Get(oldKeys[i])
+		c.Assert(err, qt.Equals, db.ErrKeyNotFound)
+	}
+	rTx.Discard()
+	nLeafs, err := census.tree.GetNLeafs()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nLeafs, qt.Equals, 18)
+
+	// the nodes of the generation 1 are deleted when building the
+	// generation 2
+	c.Assert(len(treeKeys()) > 0, qt.IsTrue)
+	c.Assert(census.RemovePublicKeys(pubKs[2:4]), qt.IsNil)
+	nNodes := 0
+	err = database.Iterate(dbPrefixTreeGeneration(1), func(_, _ []byte) bool {
+		nNodes++
+		return true
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(nNodes, qt.Equals, 0)
+	nLeafs, err = census.tree.GetNLeafs()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nLeafs, qt.Equals, 16)
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	var keys []IndexedPublicKey
+	for i := 4; i < len(pubKs); i++ {
+		keys = append(keys, IndexedPublicKey{Index: uint64(i),
+			PublicKey: pubKs[i], Weight: weights[i]})
+	}
+	expected := newTestCensus(c)
+	c.Assert(expected.AddPublicKeysAtIndices(keys), qt.IsNil)
+	c.Assert(expected.Close(), qt.IsNil)
+	expectedRoot, err := expected.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, expectedRoot)
+
+	// the Census opened again from its db uses the last generation
+	reopened, err := New(Options{DB: database})
+	c.Assert(err, qt.IsNil)
+	reopenedRoot, err := reopened.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(reopenedRoot, qt.DeepEquals, root)
+	ok, err := reopened.VerifyRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	for i := 4; i < len(pubKs); i++ {
+		index, proof, err := reopened.GetProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(index, qt.Equals, uint64(i))
+		ok, err = CheckProof(root, proof, index, &pubKs[i], weights[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+	}
+}
@@ -0,0 +1,40 @@
+package census
+
+// ReopenForVersion opens again a closed Census, so more PublicKeys can be
+// added to it: the closing time, the Digest and the seal are removed, and
+// the State goes back to StateBuilding. The indexes of the PublicKeys already
+// added are kept, and the CensusProofs stored with StoreAllProofs are removed,
+// as they are not valid for the new CensusRoot. It needs to be used over a copy of the db of the closed
+// Census (see censusbuilder.ReopenCensus), as the CensusRoot of the original
+// Census may be already used by a process.
+func (c *Census) ReopenForVersion() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return err
+	}
+	if !isClosed {
+		return ErrCensusNotClosed
+	}
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	for _, key := range [][]byte{dbKeyClosedAt, dbKeyDigest, dbKeySealed} {
+		if err := wTx.Delete(key); err != nil {
+			return err
+		}
+	}
+	if err := c.deleteStoredProofs(wTx); err != nil {
+		return err
+	}
+	if err := wTx.Set(dbKeyCensusClosed, []byte{0}); err != nil {
+		return err
+	}
+	// the transition is not part of the stateTransitions, as the
+	// original Census remains closed
+	if err := c.setState(wTx, StateBuilding); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
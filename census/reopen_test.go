@@ -0,0 +1,54 @@
+package census
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReopenForVersion(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	// an open Census can not be reopened
+	c.Assert(census.ReopenForVersion(), qt.Equals, ErrCensusNotClosed)
+
+	pubKs, weights := genPublicKeys(10)
+	_, err := census.AddPublicKeys(pubKs[:5], weights[:5])
+	c.Assert(err, qt.IsNil)
+	_, err = census.Seal()
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	_, err = census.Digest()
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(census.ReopenForVersion(), qt.IsNil)
+	info, err := census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Closed, qt.IsFalse)
+	c.Assert(info.ClosedAt, qt.IsNil)
+	c.Assert(info.KeySetHash, qt.IsNil)
+	c.Assert(info.State, qt.Equals, StateBuilding)
+	c.Assert(info.Size, qt.Equals, uint64(5))
+
+	// more PublicKeys can be added, keeping the indexes of the previous ones
+	_, err = census.AddPublicKeys(pubKs[5:], weights[5:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	for i := 0; i < len(pubKs); i++ {
+		index, _, err := census.GetProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(index, qt.Equals, uint64(i))
+	}
+
+	// the Digest is computed again for the new set of PublicKeys
+	digest, err := census.Digest()
+	c.Assert(err, qt.IsNil)
+	other := newTestCensus(c)
+	_, err = other.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(other.Close(), qt.IsNil)
+	otherDigest, err := other.Digest()
+	c.Assert(err, qt.IsNil)
+	c.Assert(digest, qt.DeepEquals, otherDigest)
+}
@@ -0,0 +1,172 @@
+package census
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.vocdoni.io/dvote/db"
+)
+
+var (
+	// dbPrefixRootRecord is used to store the RootRecords of the Census,
+	// where each entry key is dbPrefixRootRecord | position in big-endian,
+	// so the entries are sorted in the order in which they were stored
+	dbPrefixRootRecord = []byte("rootRecord")
+	// dbKeyNRootRecords is used to store the number of RootRecords
+	dbKeyNRootRecords = []byte("nRootRecords")
+)
+
+func dbKeyRootRecord(pos uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, pos)
+	return append(append([]byte{}, dbPrefixRootRecord...), b...)
+}
+
+// RootRecordKind is used to define the change of the Census MerkleTree
+// recorded by a RootRecord
+type RootRecordKind int
+
+var (
+	// RootRecordAddition indicates that a batch of keys was added to the
+	// Census
+	RootRecordAddition RootRecordKind = 0
+	// RootRecordRemoval indicates that a batch of PublicKeys was removed
+	// from the Census (see RemovePublicKeys)
+	RootRecordRemoval RootRecordKind = 1
+	// RootRecordReplacement indicates that a PublicKey of the Census was
+	// replaced by another one (see ReplacePublicKey)
+	RootRecordReplacement RootRecordKind = 2
+)
+
+// String returns the description of the RootRecordKind
+func (k RootRecordKind) String() string {
+	switch k {
+	case RootRecordAddition:
+		return "addition"
+	case RootRecordRemoval:
+		return "removal"
+	case RootRecordReplacement:
+		return "replacement"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(k))
+	}
+}
+
+// RootRecord contains the root of the Census MerkleTree after adding a batch
+// of keys to the Census, or after removing or replacing some of its
+// PublicKeys, as indicated by its Kind
+type RootRecord struct {
+	Root []byte    `json:"root"`
+	Time time.Time `json:"time"`
+	// Kind is not stored for the additions, so the RootRecords stored
+	// before it was introduced are additions
+	Kind RootRecordKind `json:"kind,omitempty"`
+	// BatchSize is the number of keys added, removed or replaced by the
+	// batch, and Size the number of leafs of the MerkleTree after it
+	BatchSize int    `json:"batchSize"`
+	Size      uint64 `json:"size"`
+}
+
+// storeRootRecord stores the RootRecord of the given kind of the current state
+// of the MerkleTree of the given db.WriteTx, after changing a batch of
+// batchSize keys
+func (c *Census) storeRootRecord(wTx db.WriteTx, kind RootRecordKind,
+	batchSize int) error {
+	root, err := c.tree.RootWithTx(treeTx(wTx))
+	if err != nil {
+		return err
+	}
+	nLeafs, err := c.tree.GetNLeafsWithTx(treeTx(wTx))
+	if err != nil {
+		return err
+	}
+	n, err := getNRootRecords(wTx)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(RootRecord{
+		Root:      root,
+		Time:      c.now().UTC(),
+		Kind:      kind,
+		BatchSize: batchSize,
+		Size:      uint64(nLeafs),
+	})
+	if err != nil {
+		return err
+	}
+	if err := wTx.Set(dbKeyRootRecord(n), b); err != nil {
+		return err
+	}
+	nb := make([]byte, 8)
+	binary.LittleEndian.PutUint64(nb, n+1)
+	return wTx.Set(dbKeyNRootRecords, nb)
+}
+
+// recordRoot stores in its own db.WriteTx the RootRecord of the MerkleTree
+// after adding a batch of batchSize keys
+func (c *Census) recordRoot(batchSize int) error {
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	if err := c.storeRootRecord(wTx, RootRecordAddition, batchSize); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
+
+func getNRootRecords(rTx db.ReadTx) (uint64, error) {
+	b, err := rTx.Get(dbKeyNRootRecords)
+	if err == db.ErrKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// RootHistory returns the RootRecords of the Census, one for each batch of
+// keys added to its MerkleTree, in the order in which the batches were added,
+// so the CensusRoot of the closed Census can be audited as the result of
+// adding the published batches. The removals and replacements of PublicKeys
+// are recorded in the same sequence, marked by the RootRecord Kind. For a Census with SortKeys, the PublicKeys
+// are added to the MerkleTree in a single batch when closing the Census.
+// The batches added before the RootHistory was introduced are not recorded.
+func (c *Census) RootHistory() ([]RootRecord, error) {
+	var records []RootRecord
+	var decodeErr error
+	err := c.db.Iterate(dbPrefixRootRecord, func(k, v []byte) bool {
+		var record RootRecord
+		if err := json.Unmarshal(v, &record); err != nil {
+			decodeErr = fmt.Errorf("can not decode the RootRecord %d: %s",
+				binary.BigEndian.Uint64(k), err)
+			return false
+		}
+		records = append(records, record)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return records, nil
+}
+
+// truncateRootHistory removes in the given db.WriteTx the RootRecords stored
+// after the first n ones
+func truncateRootHistory(wTx db.WriteTx, n uint64) error {
+	nRecords, err := getNRootRecords(wTx)
+	if err != nil {
+		return err
+	}
+	for pos := n; pos < nRecords; pos++ {
+		if err := wTx.Delete(dbKeyRootRecord(pos)); err != nil {
+			return err
+		}
+	}
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, n)
+	return wTx.Set(dbKeyNRootRecords, b)
+}
@@ -0,0 +1,111 @@
+package census
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRootHistory(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	census, err := New(Options{DB: newTestDB(c), ChunkSize: 4,
+		Now: func() time.Time { return now }})
+	c.Assert(err, qt.IsNil)
+
+	history, err := census.RootHistory()
+	c.Assert(err, qt.IsNil)
+	c.Assert(history, qt.HasLen, 0)
+
+	// a batch bigger than the ChunkSize is recorded once
+	pubKs, weights := genPublicKeys(15)
+	_, err = census.AddPublicKeys(pubKs[:10], weights[:10])
+	c.Assert(err, qt.IsNil)
+	root1, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	now = now.Add(time.Hour)
+	_, err = census.AddPublicKeys(pubKs[10:14], weights[10:14])
+	c.Assert(err, qt.IsNil)
+	root2, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{Index: 100, PublicKey: pubKs[14]}})
+	c.Assert(err, qt.IsNil)
+	// a rejected batch is not recorded
+	_, err = census.AddPublicKeys(pubKs[:1], weights[:1])
+	c.Assert(err, qt.Not(qt.IsNil))
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	history, err = census.RootHistory()
+	c.Assert(err, qt.IsNil)
+	c.Assert(history, qt.HasLen, 3)
+	c.Assert(history[0], qt.DeepEquals, RootRecord{Root: root1,
+		Time: now.Add(-time.Hour), BatchSize: 10, Size: 10})
+	c.Assert(history[1], qt.DeepEquals, RootRecord{Root: root2, Time: now,
+		BatchSize: 4, Size: 14})
+	c.Assert(history[2], qt.DeepEquals, RootRecord{Root: root, Time: now,
+		BatchSize: 1, Size: 15})
+}
+
+func TestRootHistorySortKeys(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c), SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	pubKs, weights := genPublicKeys(6)
+	_, err = census.AddPublicKeys(pubKs[:3], weights[:3])
+	c.Assert(err, qt.IsNil)
+	_, err = census.AddPublicKeys(pubKs[3:], weights[3:])
+	c.Assert(err, qt.IsNil)
+
+	// the buffered PublicKeys are recorded in a single batch when closing
+	history, err := census.RootHistory()
+	c.Assert(err, qt.IsNil)
+	c.Assert(history, qt.HasLen, 0)
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	history, err = census.RootHistory()
+	c.Assert(err, qt.IsNil)
+	c.Assert(history, qt.HasLen, 1)
+	c.Assert(history[0].Root, qt.DeepEquals, root)
+	c.Assert(history[0].BatchSize, qt.Equals, 6)
+	c.Assert(history[0].Size, qt.Equals, uint64(6))
+}
+
+func TestRootHistoryRemoveAndReplace(t *testing.T) {
+	c := qt.New(t)
+
+	census := newTestCensus(c)
+	pubKs, weights := genPublicKeys(6)
+	_, err := census.AddPublicKeys(pubKs[:5], weights[:5])
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.RemovePublicKeys(pubKs[:2]), qt.IsNil)
+	removedRoot, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.ReplacePublicKey(pubKs[2], pubKs[5], nil), qt.IsNil)
+	replacedRoot, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	// a rejected removal is not recorded
+	c.Assert(census.RemovePublicKeys(pubKs[:1]), qt.ErrorMatches,
+		ErrPublicKeyNotFound.Error()+".*")
+
+	history, err := census.RootHistory()
+	c.Assert(err, qt.IsNil)
+	c.Assert(history, qt.HasLen, 3)
+	c.Assert(history[0].Kind, qt.Equals, RootRecordAddition)
+	c.Assert(history[0].BatchSize, qt.Equals, 5)
+	c.Assert(history[1].Kind, qt.Equals, RootRecordRemoval)
+	c.Assert(history[1].Root, qt.DeepEquals, removedRoot)
+	c.Assert(history[1].BatchSize, qt.Equals, 2)
+	c.Assert(history[1].Size, qt.Equals, uint64(3))
+	c.Assert(history[2].Kind, qt.Equals, RootRecordReplacement)
+	c.Assert(history[2].Root, qt.DeepEquals, replacedRoot)
+	c.Assert(history[2].BatchSize, qt.Equals, 1)
+	c.Assert(history[2].Size, qt.Equals, uint64(3))
+	c.Assert(history[2].Kind.String(), qt.Equals, "replacement")
+}
@@ -0,0 +1,120 @@
+package census
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+// dbKeySealed is used to store the KeySetHash computed when sealing the Census
+var dbKeySealed = []byte("sealed")
+
+// ErrCensusSealed is used when trying to add keys to a Census that has been
+// sealed
+var ErrCensusSealed = errors.New("Census sealed, can not add more keys")
+
+// keySetHash returns the sha256 hash of the current set of PublicKeys of the
+// Census, including the PublicKeys buffered when SortKeys is enabled. The
+// hash is computed over the compressed PublicKeys sorted by their bytes, each
+// one followed by its Weight as 32 little-endian bytes, so it does not depend
+// on the indexes nor on the order in which the PublicKeys were added.
+func (c *Census) keySetHash() ([]byte, error) {
+	keys, err := c.PublicKeys()
+	if err != nil {
+		return nil, err
+	}
+	pubKsComp := make([]babyjub.PublicKeyComp, len(keys))
+	for i := 0; i < len(keys); i++ {
+		pubKsComp[i] = keys[i].PublicKey.Compress()
+	}
+	sort.Sort(byPubKComp{keys, pubKsComp})
+
+	h := sha256.New()
+	for i := 0; i < len(keys); i++ {
+		_, _ = h.Write(pubKsComp[i][:])
+		_, _ = h.Write(arbo.BigIntToBytes(32, keys[i].Weight)) //nolint:gomnd
+	}
+	return h.Sum(nil), nil
+}
+
+// byPubKComp implements sort.Interface to sort the IndexedPublicKeys by their
+// compressed PublicKey bytes
+type byPubKComp struct {
+	keys      []IndexedPublicKey
+	pubKsComp []babyjub.PublicKeyComp
+}
+
+func (l byPubKComp) Len() int { return len(l.keys) }
+func (l byPubKComp) Less(i, j int) bool {
+	return bytes.Compare(l.pubKsComp[i][:], l.pubKsComp[j][:]) < 0
+}
+func (l byPubKComp) Swap(i, j int) {
+	l.keys[i], l.keys[j] = l.keys[j], l.keys[i]
+	l.pubKsComp[i], l.pubKsComp[j] = l.pubKsComp[j], l.pubKsComp[i]
+}
+
+// Seal commits the Census to its current set of PublicKeys, returning the
+// KeySetHash of that set, which can be announced before closing the Census.
+// Once sealed, no more PublicKeys can be added, and the Census can be closed
+// with Close.
+func (c *Census) Seal() ([]byte, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return nil, err
+	}
+	if isClosed {
+		return nil, ErrCensusClosed
+	}
+	isSealed, err := c.IsSealed()
+	if err != nil {
+		return nil, err
+	}
+	if isSealed {
+		return nil, fmt.Errorf("Census already sealed")
+	}
+
+	hash, err := c.keySetHash()
+	if err != nil {
+		return nil, err
+	}
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	if err := wTx.Set(dbKeySealed, hash); err != nil {
+		return nil, err
+	}
+	if err := wTx.Commit(); err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// IsSealed returns true if the Census has been sealed
+func (c *Census) IsSealed() (bool, error) {
+	hash, err := c.KeySetHash()
+	if err != nil {
+		return false, err
+	}
+	return hash != nil, nil
+}
+
+// KeySetHash returns the hash of the set of PublicKeys computed when the
+// Census was sealed, or nil if the Census has not been sealed
+func (c *Census) KeySetHash() ([]byte, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	hash, err := rTx.Get(dbKeySealed)
+	if err == db.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
@@ -0,0 +1,77 @@
+package census
+
+import (
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestSeal(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 10
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs = append(pubKs, *sk.Public())
+		weights = append(weights, big.NewInt(int64(i+1)))
+	}
+
+	census := newTestCensus(c)
+	_, err := census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	isSealed, err := census.IsSealed()
+	c.Assert(err, qt.IsNil)
+	c.Assert(isSealed, qt.IsFalse)
+
+	hash, err := census.Seal()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(hash), qt.Equals, 32)
+	isSealed, err = census.IsSealed()
+	c.Assert(err, qt.IsNil)
+	c.Assert(isSealed, qt.IsTrue)
+	_, err = census.Seal()
+	c.Assert(err, qt.ErrorMatches, "Census already sealed")
+
+	// no more PublicKeys can be added once sealed
+	sk := babyjub.NewRandPrivKey()
+	_, err = census.AddPublicKeys([]babyjub.PublicKey{*sk.Public()},
+		[]*big.Int{big.NewInt(1)})
+	c.Assert(err, qt.Equals, ErrCensusSealed)
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{Index: 20, PublicKey: *sk.Public()}})
+	c.Assert(err, qt.Equals, ErrCensusSealed)
+
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+	info, err := census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.KeySetHash, qt.DeepEquals, hash)
+	c.Assert(info.Size, qt.Equals, uint64(nKeys))
+	_, err = census.Seal()
+	c.Assert(err, qt.Equals, ErrCensusClosed)
+
+	// the KeySetHash does not depend on the order in which the
+	// PublicKeys are added, and matches between SortKeys and non SortKeys
+	// Censuses
+	census2, err := New(Options{DB: newTestDB(c), ChunkSize: 3, SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	for i := nKeys - 1; i >= 0; i-- {
+		_, err = census2.AddPublicKeys(pubKs[i:i+1], weights[i:i+1])
+		c.Assert(err, qt.IsNil)
+	}
+	hash2, err := census2.Seal()
+	c.Assert(err, qt.IsNil)
+	c.Assert(hash2, qt.DeepEquals, hash)
+
+	// a different set of PublicKeys gives a different KeySetHash
+	census3 := newTestCensus(c)
+	_, err = census3.AddPublicKeys(pubKs[1:], weights[1:])
+	c.Assert(err, qt.IsNil)
+	hash3, err := census3.Seal()
+	c.Assert(err, qt.IsNil)
+	c.Assert(hash3, qt.Not(qt.DeepEquals), hash)
+}
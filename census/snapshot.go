@@ -0,0 +1,203 @@
+package census
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/db"
+)
+
+// dbPrefixSnapshot is used to store the Snapshots of the Census, where each
+// entry key is dbPrefixSnapshot | name
+var dbPrefixSnapshot = []byte("snapshot")
+
+func dbKeySnapshot(name string) []byte {
+	return append(append([]byte{}, dbPrefixSnapshot...), []byte(name)...)
+}
+
+// ErrSnapshotNotFound is used when trying to use a Snapshot that does not
+// exist
+var ErrSnapshotNotFound = errors.New("Snapshot not found")
+
+// Snapshot contains a named Checkpoint of the Census, taken with Snapshot, to
+// which the open Census can be rolled back with RollbackToSnapshot
+type Snapshot struct {
+	Name string `json:"name"`
+	Checkpoint
+	// NRootRecords is the number of RootRecords of the Census when the
+	// Snapshot was taken
+	NRootRecords uint64    `json:"nRootRecords"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// checkCanSnapshot returns error if the Census can not be snapshotted nor
+// rolled back
+func (c *Census) checkCanSnapshot() error {
+	if err := c.checkEditable(); err != nil {
+		return err
+	}
+	if c.sortKeys {
+		return fmt.Errorf("can not snapshot a Census with SortKeys, as its" +
+			" PublicKeys are not in the MerkleTree until it is closed")
+	}
+	return c.checkKeyType(types.KeyTypeBabyJubJub)
+}
+
+// Snapshot stores the current state of the MerkleTree of the open Census
+// under the given name, replacing the Snapshot with the same name if any, so
+// a long import can be rolled back to it with RollbackToSnapshot if a later
+// batch turns out to be wrong. The Snapshots are removed by RemovePublicKeys
+// and ReplacePublicKey, as the PublicKeys they modify can not be restored.
+func (c *Census) Snapshot(name string) (*Snapshot, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if name == "" {
+		return nil, fmt.Errorf("the Snapshot name can not be empty")
+	}
+	if err := c.checkCanSnapshot(); err != nil {
+		return nil, err
+	}
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	cp, err := c.currentCheckpoint(wTx)
+	if err != nil {
+		return nil, err
+	}
+	nRecords, err := getNRootRecords(wTx)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{
+		Name:         name,
+		Checkpoint:   *cp,
+		NRootRecords: nRecords,
+		CreatedAt:    c.now().UTC(),
+	}
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := wTx.Set(dbKeySnapshot(name), b); err != nil {
+		return nil, err
+	}
+	if err := wTx.Commit(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func getSnapshot(rTx db.ReadTx, name string) (*Snapshot, error) {
+	b, err := rTx.Get(dbKeySnapshot(name))
+	if err == db.ErrKeyNotFound {
+		return nil, fmt.Errorf("%s, name %q", ErrSnapshotNotFound, name)
+	} else if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Snapshots returns the Snapshots of the Census, sorted by name
+func (c *Census) Snapshots() ([]Snapshot, error) {
+	var snapshots []Snapshot
+	var decodeErr error
+	err := c.db.Iterate(dbPrefixSnapshot, func(k, v []byte) bool {
+		var snapshot Snapshot
+		if err := json.Unmarshal(v, &snapshot); err != nil {
+			decodeErr = fmt.Errorf("can not decode the Snapshot %q: %s",
+				k, err)
+			return false
+		}
+		snapshots = append(snapshots, snapshot)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return snapshots, nil
+}
+
+// DeleteSnapshot removes the Snapshot of the given name
+func (c *Census) DeleteSnapshot(name string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	if _, err := getSnapshot(wTx, name); err != nil {
+		return err
+	}
+	if err := wTx.Delete(dbKeySnapshot(name)); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
+
+// RollbackToSnapshot restores the MerkleTree of the open Census to the
+// Snapshot of the given name, discarding the PublicKeys added after it, which
+// are returned, and the RootRecords of their batches. The Snapshots taken
+// after the given one are removed, while the given one is kept, so the Census
+// can be rolled back to it again.
+func (c *Census) RollbackToSnapshot(name string) ([]babyjub.PublicKey, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.checkCanSnapshot(); err != nil {
+		return nil, err
+	}
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	snapshot, err := getSnapshot(wTx, name)
+	if err != nil {
+		return nil, err
+	}
+	discarded, err := c.restoreCheckpoint(wTx, &snapshot.Checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.storeCheckpoint(wTx); err != nil {
+		return nil, err
+	}
+	if err := truncateRootHistory(wTx, snapshot.NRootRecords); err != nil {
+		return nil, err
+	}
+	err = c.deleteSnapshots(wTx, func(s *Snapshot) bool {
+		return s.NLeafs > snapshot.NLeafs
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := wTx.Commit(); err != nil {
+		return nil, err
+	}
+	c.nChunksSinceCheckpoint = 0
+	return discarded, nil
+}
+
+// deleteSnapshots removes in the given db.WriteTx the Snapshots for which the
+// given function returns true
+func (c *Census) deleteSnapshots(wTx db.WriteTx, fn func(s *Snapshot) bool) error {
+	snapshots, err := c.Snapshots()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(snapshots); i++ {
+		if !fn(&snapshots[i]) {
+			continue
+		}
+		if err := wTx.Delete(dbKeySnapshot(snapshots[i].Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
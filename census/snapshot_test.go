@@ -0,0 +1,112 @@
+package census
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestSnapshot(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	pubKs, weights := genPublicKeys(30)
+	_, err := census.AddPublicKeys(pubKs[:10], weights[:10])
+	c.Assert(err, qt.IsNil)
+	root10, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+
+	_, err = census.Snapshot("")
+	c.Assert(err, qt.ErrorMatches, "the Snapshot name can not be empty")
+	snapshot, err := census.Snapshot("first")
+	c.Assert(err, qt.IsNil)
+	c.Assert(snapshot.NLeafs, qt.Equals, uint64(10))
+	c.Assert(snapshot.NextIndex, qt.Equals, uint64(10))
+	c.Assert(snapshot.Root, qt.DeepEquals, root10)
+
+	_, err = census.AddPublicKeys(pubKs[10:20], weights[10:20])
+	c.Assert(err, qt.IsNil)
+	_, err = census.Snapshot("second")
+	c.Assert(err, qt.IsNil)
+	_, err = census.AddPublicKeys(pubKs[20:], weights[20:])
+	c.Assert(err, qt.IsNil)
+	snapshots, err := census.Snapshots()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(snapshots), qt.Equals, 2)
+	c.Assert(snapshots[0].Name, qt.Equals, "first")
+	c.Assert(snapshots[1].Name, qt.Equals, "second")
+
+	_, err = census.RollbackToSnapshot("third")
+	c.Assert(err, qt.ErrorMatches, ErrSnapshotNotFound.Error()+".*")
+
+	// rolling back to the first Snapshot discards the keys added after
+	// it, and the Snapshots taken after it
+	discarded, err := census.RollbackToSnapshot("first")
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(discarded), qt.Equals, 20)
+	root, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, root10)
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(10))
+	ok, err := census.HasPublicKey(&pubKs[15])
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+	cp, err := census.GetCheckpoint()
+	c.Assert(err, qt.IsNil)
+	c.Assert(cp.NLeafs, qt.Equals, uint64(10))
+	nLeafs, err := census.tree.GetNLeafs()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nLeafs, qt.Equals, 10)
+	records, err := census.RootHistory()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(records), qt.Equals, 1)
+	snapshots, err = census.Snapshots()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(snapshots), qt.Equals, 1)
+
+	// the discarded keys can be added again, getting the same indexes
+	_, err = census.AddPublicKeys(pubKs[10:], weights[10:])
+	c.Assert(err, qt.IsNil)
+	cProof, _, err := census.GetProvisionalProof(&pubKs[15])
+	c.Assert(err, qt.IsNil)
+	c.Assert(cProof.Index, qt.Equals, uint64(15))
+
+	// removing PublicKeys removes the Snapshots
+	err = census.RemovePublicKeys(pubKs[:1])
+	c.Assert(err, qt.IsNil)
+	snapshots, err = census.Snapshots()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(snapshots), qt.Equals, 0)
+	// the MerkleTree built again by RemovePublicKeys can be rolled back
+	_, err = census.Snapshot("removed")
+	c.Assert(err, qt.IsNil)
+	_, err = census.AddPublicKeys(pubKs[:1], weights[:1])
+	c.Assert(err, qt.IsNil)
+	discarded, err = census.RollbackToSnapshot("removed")
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(discarded), qt.Equals, 1)
+	nLeafs, err = census.tree.GetNLeafs()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nLeafs, qt.Equals, 29)
+	c.Assert(census.DeleteSnapshot("removed"), qt.IsNil)
+
+	err = census.DeleteSnapshot("first")
+	c.Assert(err, qt.ErrorMatches, ErrSnapshotNotFound.Error()+".*")
+
+	// a closed Census can not be rolled back
+	_, err = census.Snapshot("closed")
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	_, err = census.RollbackToSnapshot("closed")
+	c.Assert(err, qt.Equals, ErrCensusClosed)
+}
+
+func TestSnapshotSortKeys(t *testing.T) {
+	c := qt.New(t)
+	census, err := New(Options{DB: newTestDB(c), SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	_, err = census.Snapshot("first")
+	c.Assert(err, qt.ErrorMatches, "can not snapshot a Census with SortKeys.*")
+}
@@ -0,0 +1,207 @@
+package census
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+var (
+	dbKeySortKeys     = []byte("sortKeys")
+	dbKeyNPendingKeys = []byte("nPendingKeys")
+	// dbPrefixPendingKey is used to store the PublicKeys of a Census with
+	// SortKeys, until their indexes are assigned when closing the Census
+	dbPrefixPendingKey = []byte("pendingKey")
+)
+
+func dbKeyPendingKey(pubKComp babyjub.PublicKeyComp) []byte {
+	return append(append([]byte{}, dbPrefixPendingKey...), pubKComp[:]...)
+}
+
+// initSortKeys stores the SortKeys flag for a Census that has no keys yet, and
+// returns the stored flag
+func (c *Census) initSortKeys(wTx db.WriteTx, sortKeys bool) (bool, error) {
+	b, err := wTx.Get(dbKeySortKeys)
+	if err == nil {
+		return b[0] == 1, nil
+	} else if err != db.ErrKeyNotFound {
+		return false, err
+	}
+	if !sortKeys {
+		return false, nil
+	}
+	nLeafs, err := c.tree.GetNLeafsWithTx(treeTx(wTx))
+	if err != nil {
+		return false, err
+	}
+	if nLeafs > 0 {
+		return false, fmt.Errorf("can not enable SortKeys in a Census" +
+			" that already contains PublicKeys")
+	}
+	if err := wTx.Set(dbKeySortKeys, []byte{1}); err != nil {
+		return false, err
+	}
+	if err := c.setNPendingKeys(wTx, 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Census) setNPendingKeys(wTx db.WriteTx, n uint64) error {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, n)
+	return wTx.Set(dbKeyNPendingKeys, b)
+}
+
+func (c *Census) getNPendingKeys(rTx db.ReadTx) (uint64, error) {
+	if !c.sortKeys {
+		return 0, nil
+	}
+	b, err := rTx.Get(dbKeyNPendingKeys)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// bufferPublicKeysChunk stores the given PublicKeys in a single db.WriteTx,
+// without adding them to the MerkleTree, which is done when the Census is
+// closed. The db.WriteTx is only committed if all the keys are stored.
+func (c *Census) bufferPublicKeysChunk(pubKs []babyjub.PublicKey,
+	weights []*big.Int) ([]InvalidKey, error) {
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+
+	var invalids []InvalidKey
+	for i := 0; i < len(pubKs); i++ {
+		key := dbKeyPendingKey(pubKs[i].Compress())
+		// the pending keys of this same db.WriteTx are also checked
+		_, err := wTx.Get(key)
+		if err == nil {
+			invalids = append(invalids, InvalidKey{Index: i,
+				Reason: InvalidAlreadyPresent,
+				Error:  fmt.Errorf("PublicKey already added")})
+			continue
+		} else if err != db.ErrKeyNotFound {
+			return nil, err
+		}
+		// TODO ensure that the weights[i] does not overflow the field
+		weightBytes := arbo.BigIntToBytes(32, weights[i]) //nolint:gomnd
+		if err := wTx.Set(key, weightBytes); err != nil {
+			return nil, err
+		}
+	}
+	if len(invalids) != 0 {
+		return invalids, fmt.Errorf("Can not add %d PublicKeys", len(invalids))
+	}
+
+	nPending, err := c.getNPendingKeys(wTx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setNPendingKeys(wTx, nPending+uint64(len(pubKs))); err != nil {
+		return nil, err
+	}
+	if err := c.markBuilding(wTx); err != nil {
+		return nil, err
+	}
+
+	if err := wTx.Commit(); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// pendingPublicKeys returns the PublicKeys stored by bufferPublicKeysChunk
+// that have not been added to the MerkleTree yet, sorted by their compressed
+// bytes
+func (c *Census) pendingPublicKeys() ([]babyjub.PublicKey, []*big.Int, error) {
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	var decompressErr error
+	err := c.db.Iterate(dbPrefixPendingKey, func(k, v []byte) bool {
+		var pubKComp babyjub.PublicKeyComp
+		copy(pubKComp[:], k)
+		pubK, err := pubKComp.Decompress()
+		if err != nil {
+			decompressErr = err
+			return false
+		}
+		pubKs = append(pubKs, *pubK)
+		weights = append(weights, arbo.BytesToBigInt(v))
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if decompressErr != nil {
+		return nil, nil, decompressErr
+	}
+	return pubKs, weights, nil
+}
+
+// PendingPublicKeys returns the PublicKeys of a Census with SortKeys that
+// have not been added to the MerkleTree yet, which is done when the Census is
+// closed
+func (c *Census) PendingPublicKeys() ([]babyjub.PublicKey, error) {
+	pubKs, _, err := c.pendingPublicKeys()
+	return pubKs, err
+}
+
+// flushPendingPublicKeys adds the pending PublicKeys to the MerkleTree,
+// assigning their indexes following the order of their compressed bytes, so
+// the resulting CensusRoot only depends on the set of PublicKeys and not on
+// the order in which they were added
+func (c *Census) flushPendingPublicKeys() error {
+	pubKs, weights, err := c.pendingPublicKeys()
+	if err != nil {
+		return err
+	}
+	for from := 0; from < len(pubKs); from += c.chunkSize {
+		to := from + c.chunkSize
+		if to > len(pubKs) {
+			to = len(pubKs)
+		}
+		if err := c.flushPendingPublicKeysChunk(pubKs[from:to],
+			weights[from:to]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Census) flushPendingPublicKeysChunk(pubKs []babyjub.PublicKey,
+	weights []*big.Int) error {
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+
+	invalids, err := c.addPublicKeysWithTx(wTx, pubKs, weights, nil)
+	if err != nil && len(invalids) != 0 {
+		return fmt.Errorf("Can not add %d PublicKeys, invalid msg for key"+
+			" %d: %s", len(invalids), invalids[0].Index, invalids[0].Error)
+	} else if err != nil {
+		return err
+	}
+	for i := 0; i < len(pubKs); i++ {
+		if err := wTx.Delete(dbKeyPendingKey(pubKs[i].Compress())); err != nil {
+			return err
+		}
+	}
+	nPending, err := c.getNPendingKeys(wTx)
+	if err != nil {
+		return err
+	}
+	if err := c.setNPendingKeys(wTx, nPending-uint64(len(pubKs))); err != nil {
+		return err
+	}
+	// the pending keys are removed in the same db.WriteTx, so the
+	// Checkpoint is stored for each flushed chunk
+	if err := c.storeCheckpoint(wTx); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
@@ -0,0 +1,122 @@
+package census
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestSortKeys(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c), ChunkSize: 3, SortKeys: true})
+	c.Assert(err, qt.IsNil)
+
+	nKeys := 10
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubK := sk.Public()
+		pubKs = append(pubKs, *pubK)
+		weights = append(weights, big.NewInt(int64(i+1)))
+	}
+
+	invalids, err := census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+
+	// expect the buffered keys to be counted, but not in the MerkleTree
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(nKeys))
+	root, err := census.IntermediateRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, make([]byte, len(root)))
+	state, err := census.State()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state, qt.Equals, StateBuilding)
+
+	// expect error when adding an already buffered key, and no key of its
+	// chunk added
+	invalids, err = census.AddPublicKeys(pubKs[:1], weights[:1])
+	c.Assert(err, qt.ErrorMatches, "Can not add 1 PublicKeys")
+	c.Assert(len(invalids), qt.Equals, 1)
+	c.Assert(invalids[0].Index, qt.Equals, 0)
+	size, err = census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(nKeys))
+
+	// explicit indexes can not be used
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{{PublicKey: pubKs[0]}})
+	c.Assert(err, qt.ErrorMatches, "can not add PublicKeys at explicit indexes.*")
+
+	// expect the buffered keys with the indexes that they will get
+	keysBefore, err := census.PublicKeys()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(keysBefore), qt.Equals, nKeys)
+
+	err = census.Close()
+	c.Assert(err, qt.IsNil)
+	size, err = census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(nKeys))
+
+	// expect the indexes assigned following the compressed keys order
+	keys, err := census.PublicKeys()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(keys), qt.Equals, nKeys)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(keys[i].Index, qt.Equals, uint64(i))
+		c.Assert(keys[i].Index, qt.Equals, keysBefore[i].Index)
+		c.Assert(keys[i].PublicKey.Compress(), qt.Equals,
+			keysBefore[i].PublicKey.Compress())
+		if i > 0 {
+			prev := keys[i-1].PublicKey.Compress()
+			curr := keys[i].PublicKey.Compress()
+			c.Assert(bytes.Compare(prev[:], curr[:]), qt.Equals, -1)
+		}
+	}
+
+	root, err = census.Root()
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < nKeys; i++ {
+		index, proof, err := census.GetProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		v, err := CheckProof(root, proof, index, &pubKs[i], weights[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+	}
+}
+
+func TestSortKeysStored(t *testing.T) {
+	c := qt.New(t)
+
+	database := newTestDB(c)
+	census, err := New(Options{DB: database, SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	sk := babyjub.NewRandPrivKey()
+	_, err = census.AddPublicKeys([]babyjub.PublicKey{*sk.Public()},
+		[]*big.Int{big.NewInt(1)})
+	c.Assert(err, qt.IsNil)
+
+	// expect the SortKeys flag to be kept when loading the Census again
+	census, err = New(Options{DB: database})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.sortKeys, qt.IsTrue)
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(1))
+
+	// expect error when enabling SortKeys in a Census with keys
+	census = newTestCensus(c)
+	_, err = census.AddPublicKeys([]babyjub.PublicKey{*sk.Public()},
+		[]*big.Int{big.NewInt(1)})
+	c.Assert(err, qt.IsNil)
+	_, err = New(Options{DB: census.db, SortKeys: true})
+	c.Assert(err, qt.ErrorMatches, "can not enable SortKeys in a Census that"+
+		" already contains PublicKeys")
+}
@@ -0,0 +1,143 @@
+package census
+
+import (
+	"errors"
+	"fmt"
+
+	"go.vocdoni.io/dvote/db"
+)
+
+// State is used to define the lifecycle state of a Census
+type State int
+
+var (
+	// StateDraft indicates that the Census has been created, but no
+	// PublicKeys have been added yet
+	StateDraft State = 0
+	// StateBuilding indicates that PublicKeys are being added to the
+	// Census
+	StateBuilding State = 1
+	// StateClosed indicates that the Census is closed, no more PublicKeys
+	// can be added, and the MerkleProofs can be generated
+	StateClosed State = 2
+	// StatePublished indicates that the CensusRoot of the closed Census
+	// has been published
+	StatePublished State = 3
+)
+
+var dbKeyState = []byte("state")
+
+// ErrInvalidStateTransition is used when trying to change the State of a
+// Census to a State that can not be reached from the current one
+var ErrInvalidStateTransition = errors.New("invalid Census state transition")
+
+// stateTransitions contains the States that can be reached from each State
+var stateTransitions = map[State][]State{
+	StateDraft:    {StateBuilding, StateClosed},
+	StateBuilding: {StateClosed},
+	StateClosed:   {StatePublished},
+}
+
+// String returns the name of the State
+func (s State) String() string {
+	switch s {
+	case StateDraft:
+		return "draft"
+	case StateBuilding:
+		return "building"
+	case StateClosed:
+		return "closed"
+	case StatePublished:
+		return "published"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// checkStateTransition returns ErrInvalidStateTransition if the given to State
+// can not be reached from the given from State
+func checkStateTransition(from, to State) error {
+	for _, s := range stateTransitions[from] {
+		if s == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s, from %s to %s", ErrInvalidStateTransition, from, to)
+}
+
+func (c *Census) setState(wTx db.WriteTx, state State) error {
+	return wTx.Set(dbKeyState, []byte{byte(state)})
+}
+
+func (c *Census) getState(rTx db.ReadTx) (State, error) {
+	b, err := rTx.Get(dbKeyState)
+	if err == nil {
+		return State(b[0]), nil
+	}
+	if err != db.ErrKeyNotFound {
+		return 0, err
+	}
+
+	// the State is not stored for the Censuses created before the State
+	// was introduced, derive it from the closed flag and the leafs
+	closed, err := rTx.Get(dbKeyCensusClosed)
+	if err != nil {
+		return 0, err
+	}
+	if closed[0] == 1 {
+		return StateClosed, nil
+	}
+	nLeafs, err := c.tree.GetNLeafsWithTx(treeRTx(rTx))
+	if err != nil {
+		return 0, err
+	}
+	if nLeafs > 0 {
+		return StateBuilding, nil
+	}
+	return StateDraft, nil
+}
+
+// markBuilding sets the State of the Census to StateBuilding if it is in
+// StateDraft
+func (c *Census) markBuilding(wTx db.WriteTx) error {
+	state, err := c.getState(wTx)
+	if err != nil {
+		return err
+	}
+	if state != StateDraft {
+		return nil
+	}
+	return c.setState(wTx, StateBuilding)
+}
+
+// State returns the current State of the Census
+func (c *Census) State() (State, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	return c.getState(rTx)
+}
+
+// SetState changes the State of the Census to the given State, returning
+// error if the given State can not be reached from the current one. Setting
+// the StateClosed is equivalent to calling Close.
+func (c *Census) SetState(state State) error {
+	if state == StateClosed {
+		return c.Close()
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	wTx := c.db.WriteTx()
+	defer wTx.Discard()
+	current, err := c.getState(wTx)
+	if err != nil {
+		return err
+	}
+	if err := checkStateTransition(current, state); err != nil {
+		return err
+	}
+	if err := c.setState(wTx, state); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
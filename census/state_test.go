@@ -0,0 +1,92 @@
+package census
+
+import (
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestStateTransitions(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	sk := babyjub.NewRandPrivKey()
+	pubK := sk.Public()
+
+	state, err := census.State()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state, qt.Equals, StateDraft)
+
+	// proofs can not be generated in StateDraft
+	_, _, err = census.GetProof(pubK)
+	c.Assert(err, qt.Equals, ErrCensusNotClosed)
+	// a Census in StateDraft can not be published
+	err = census.SetState(StatePublished)
+	c.Assert(err, qt.ErrorMatches, "invalid Census state transition, from"+
+		" draft to published")
+
+	// adding keys moves the Census to StateBuilding
+	invalids, err := census.AddPublicKeys([]babyjub.PublicKey{*pubK},
+		[]*big.Int{big.NewInt(1)})
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	state, err = census.State()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state, qt.Equals, StateBuilding)
+
+	// StateBuilding can not go back to StateDraft
+	err = census.SetState(StateDraft)
+	c.Assert(err, qt.ErrorMatches, "invalid Census state transition, from"+
+		" building to draft")
+	err = census.SetState(StateBuilding)
+	c.Assert(err, qt.ErrorMatches, "invalid Census state transition, from"+
+		" building to building")
+
+	err = census.SetState(StateClosed)
+	c.Assert(err, qt.IsNil)
+	state, err = census.State()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state, qt.Equals, StateClosed)
+	isClosed, err := census.IsClosed()
+	c.Assert(err, qt.IsNil)
+	c.Assert(isClosed, qt.IsTrue)
+
+	err = census.SetState(StatePublished)
+	c.Assert(err, qt.IsNil)
+	state, err = census.State()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state, qt.Equals, StatePublished)
+
+	// keys can not be added, but proofs can be generated in
+	// StatePublished
+	_, err = census.AddPublicKeys([]babyjub.PublicKey{*pubK},
+		[]*big.Int{big.NewInt(1)})
+	c.Assert(err, qt.Equals, ErrCensusClosed)
+	_, _, err = census.GetProof(pubK)
+	c.Assert(err, qt.IsNil)
+
+	// StatePublished can not go back to StateBuilding nor StateClosed
+	err = census.SetState(StateBuilding)
+	c.Assert(err, qt.ErrorMatches, "invalid Census state transition, from"+
+		" published to building")
+	err = census.SetState(StateClosed)
+	c.Assert(err, qt.ErrorMatches, "Census already closed")
+
+	info, err := census.Info()
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.State, qt.Equals, StatePublished)
+}
+
+func TestStateDraftToClosed(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	// an empty Census can be closed directly from StateDraft
+	err := census.Close()
+	c.Assert(err, qt.IsNil)
+	state, err := census.State()
+	c.Assert(err, qt.IsNil)
+	c.Assert(state, qt.Equals, StateClosed)
+}
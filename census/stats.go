@@ -0,0 +1,71 @@
+package census
+
+import (
+	"github.com/vocdoni/arbo"
+)
+
+// Stats contains statistics about the MerkleTree and the db of a Census
+type Stats struct {
+	// NLeafs contains the number of leafs of the MerkleTree, and
+	// NPendingKeys the number of PublicKeys buffered until the Census is
+	// closed when SortKeys is enabled
+	NLeafs       uint64 `json:"nLeafs"`
+	NPendingKeys uint64 `json:"nPendingKeys,omitempty"`
+	// Depth contains the number of levels of the MerkleTree used by its
+	// deepest leaf, which can not be greater than MaxLevels
+	Depth     int `json:"depth"`
+	MaxLevels int `json:"maxLevels"`
+	// DiskSize contains the size in bytes used on disk by the db of the
+	// Census, or 0 if the db does not report it
+	DiskSize int64 `json:"diskSize,omitempty"`
+}
+
+// diskSizer is implemented by the dbs that can report the size they use on
+// disk
+type diskSizer interface {
+	DiskSize() (int64, error)
+}
+
+// Stats returns the Stats of the Census. The Depth is computed walking the
+// whole MerkleTree, so its cost grows with the size of the Census.
+func (c *Census) Stats() (*Stats, error) {
+	// hold the writeMu, so the Stats are computed from the same leafs
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+	nLeafs, err := c.tree.GetNLeafsWithTx(treeRTx(rTx))
+	if err != nil {
+		return nil, err
+	}
+	nPending, err := c.getNPendingKeys(rTx)
+	if err != nil {
+		return nil, err
+	}
+	depth := 0
+	err = c.tree.IterateWithStopWithTx(treeRTx(rTx), nil, func(lvl int, _, v []byte) bool {
+		// the levels start at 1 for the root
+		if v[0] == arbo.PrefixValueLeaf && lvl-1 > depth {
+			depth = lvl - 1
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		NLeafs:       uint64(nLeafs),
+		NPendingKeys: nPending,
+		Depth:        depth,
+		MaxLevels:    c.maxLevels,
+	}
+	if d, ok := c.db.(diskSizer); ok {
+		stats.DiskSize, err = d.DiskSize()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
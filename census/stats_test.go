@@ -0,0 +1,36 @@
+package census
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestStats(t *testing.T) {
+	c := qt.New(t)
+	census := newTestCensus(c)
+
+	stats, err := census.Stats()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stats, qt.DeepEquals, &Stats{MaxLevels: DefaultMaxLevels})
+
+	// a single leaf is placed at the root
+	pubKs, weights := genPublicKeys(8)
+	_, err = census.AddPublicKeys(pubKs[:1], weights[:1])
+	c.Assert(err, qt.IsNil)
+	stats, err = census.Stats()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stats.NLeafs, qt.Equals, uint64(1))
+	c.Assert(stats.Depth, qt.Equals, 0)
+
+	// the indexes 0..7 use the 3 first levels of the MerkleTree
+	_, err = census.AddPublicKeys(pubKs[1:], weights[1:])
+	c.Assert(err, qt.IsNil)
+	stats, err = census.Stats()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stats.NLeafs, qt.Equals, uint64(8))
+	c.Assert(stats.Depth, qt.Equals, 3)
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, stats.NLeafs)
+}
@@ -0,0 +1,228 @@
+package census
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"go.vocdoni.io/dvote/db"
+)
+
+var (
+	// dbKeyTreeGeneration is used to store the generation of the Census
+	// MerkleTree, which is increased each time the MerkleTree is built
+	// again in a fresh arbo tree (see rebuildTree). Not stored for the
+	// Censuses whose MerkleTree has not been built again.
+	dbKeyTreeGeneration = []byte("treeGeneration")
+	// dbPrefixTree is used to store the nodes of the MerkleTree of each
+	// generation after the first one, where each entry key is
+	// dbPrefixTree | generation in big-endian | arbo key. The MerkleTree
+	// of the generation 0 is stored without prefix, together with the
+	// rest of the Census db.
+	dbPrefixTree = []byte("tree")
+)
+
+func dbPrefixTreeGeneration(generation uint64) []byte {
+	if generation == 0 {
+		return nil
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, generation)
+	return append(append([]byte{}, dbPrefixTree...), b...)
+}
+
+func getTreeGeneration(rTx db.ReadTx) (uint64, error) {
+	b, err := rTx.Get(dbKeyTreeGeneration)
+	if err == db.ErrKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func setTreeGeneration(wTx db.WriteTx, generation uint64) error {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, generation)
+	return wTx.Set(dbKeyTreeGeneration, b)
+}
+
+// treeDB is the db.Database of the Census MerkleTree, which prefixes the keys
+// of arbo with the prefix of the generation of the MerkleTree stored in each
+// transaction, so a db.WriteTx that builds the MerkleTree again only changes
+// the MerkleTree used by the Census once it is committed
+type treeDB struct {
+	db db.Database
+	// generation, when set, is used instead of the generation stored in
+	// the Census db, to build the MerkleTree of a generation that is not
+	// committed yet, as arbo creates its own transactions from the
+	// db.Database when adding leafs in batch
+	generation *uint64
+}
+
+// treeTx returns the given db.WriteTx of the Census db prefixed for the
+// MerkleTree of the generation stored in it, to be passed to the arbo methods.
+// If the generation can not be read, the returned db.WriteTx fails with its
+// error.
+func treeTx(tx db.WriteTx) db.WriteTx {
+	generation, err := getTreeGeneration(tx)
+	if err != nil {
+		return &errTx{tx: tx, err: err}
+	}
+	return treeTxOfGeneration(tx, generation)
+}
+
+func treeTxOfGeneration(tx db.WriteTx, generation uint64) db.WriteTx {
+	prefix := dbPrefixTreeGeneration(generation)
+	if prefix == nil {
+		return tx
+	}
+	return &treeWriteTx{prefix: prefix, tx: tx}
+}
+
+// treeRTx returns the given db.ReadTx of the Census db prefixed for the
+// MerkleTree of the generation stored in it, as treeTx does for a db.WriteTx
+func treeRTx(tx db.ReadTx) db.ReadTx {
+	generation, err := getTreeGeneration(tx)
+	if err != nil {
+		return &errTx{tx: tx, err: err}
+	}
+	return treeRTxOfGeneration(tx, generation)
+}
+
+func treeRTxOfGeneration(tx db.ReadTx, generation uint64) db.ReadTx {
+	prefix := dbPrefixTreeGeneration(generation)
+	if prefix == nil {
+		return tx
+	}
+	return &treeReadTx{prefix: prefix, tx: tx}
+}
+
+// ReadTx implements the db.Database.ReadTx interface method
+func (d *treeDB) ReadTx() db.ReadTx {
+	if d.generation != nil {
+		return treeRTxOfGeneration(d.db.ReadTx(), *d.generation)
+	}
+	return treeRTx(d.db.ReadTx())
+}
+
+// WriteTx implements the db.Database.WriteTx interface method
+func (d *treeDB) WriteTx() db.WriteTx {
+	if d.generation != nil {
+		return treeTxOfGeneration(d.db.WriteTx(), *d.generation)
+	}
+	return treeTx(d.db.WriteTx())
+}
+
+// Iterate implements the db.Database.Iterate interface method
+func (d *treeDB) Iterate(prefix []byte, callback func(k, v []byte) bool) error {
+	var generation uint64
+	if d.generation != nil {
+		generation = *d.generation
+	} else {
+		rTx := d.db.ReadTx()
+		var err error
+		generation, err = getTreeGeneration(rTx)
+		rTx.Discard()
+		if err != nil {
+			return err
+		}
+	}
+	return d.db.Iterate(append(dbPrefixTreeGeneration(generation), prefix...),
+		callback)
+}
+
+// Close implements the db.Database.Close interface method. The Census db is
+// not closed, as it is owned by the Census.
+func (d *treeDB) Close() error {
+	return nil
+}
+
+// treeReadTx wraps a db.ReadTx of the Census db prefixing the keys with the
+// prefix of a generation of the MerkleTree
+type treeReadTx struct {
+	prefix []byte
+	tx     db.ReadTx
+}
+
+// Get implements the db.ReadTx.Get interface method
+func (t *treeReadTx) Get(key []byte) ([]byte, error) {
+	return t.tx.Get(append(append([]byte{}, t.prefix...), key...))
+}
+
+// Discard implements the db.ReadTx.Discard interface method
+func (t *treeReadTx) Discard() {
+	t.tx.Discard()
+}
+
+// treeWriteTx wraps a db.WriteTx of the Census db prefixing the keys with the
+// prefix of a generation of the MerkleTree
+type treeWriteTx struct {
+	prefix []byte
+	tx     db.WriteTx
+}
+
+// Get implements the db.WriteTx.Get interface method
+func (t *treeWriteTx) Get(key []byte) ([]byte, error) {
+	return t.tx.Get(append(append([]byte{}, t.prefix...), key...))
+}
+
+// Set implements the db.WriteTx.Set interface method
+func (t *treeWriteTx) Set(key, value []byte) error {
+	return t.tx.Set(append(append([]byte{}, t.prefix...), key...), value)
+}
+
+// Delete implements the db.WriteTx.Delete interface method
+func (t *treeWriteTx) Delete(key []byte) error {
+	return t.tx.Delete(append(append([]byte{}, t.prefix...), key...))
+}
+
+// Apply implements the db.WriteTx.Apply interface method. As the keys are
+// prefixed when they are written, the wrapped db.WriteTx of the given
+// treeWriteTx is applied, which is the type expected by the db
+// implementations.
+func (t *treeWriteTx) Apply(other db.WriteTx) error {
+	if o, ok := other.(*treeWriteTx); ok {
+		if !bytes.Equal(o.prefix, t.prefix) {
+			return fmt.Errorf("can not apply a db.WriteTx of another" +
+				" generation of the MerkleTree")
+		}
+		other = o.tx
+	}
+	return t.tx.Apply(other)
+}
+
+// Commit implements the db.WriteTx.Commit interface method
+func (t *treeWriteTx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Discard implements the db.WriteTx.Discard interface method
+func (t *treeWriteTx) Discard() {
+	t.tx.Discard()
+}
+
+// errTx is returned by treeTx and treeRTx when the generation of the
+// MerkleTree can not be read, and returns its error in every operation
+type errTx struct {
+	tx  db.ReadTx
+	err error
+}
+
+// Get implements the db.ReadTx.Get interface method
+func (t *errTx) Get([]byte) ([]byte, error) { return nil, t.err }
+
+// Set implements the db.WriteTx.Set interface method
+func (t *errTx) Set(_, _ []byte) error { return t.err }
+
+// Delete implements the db.WriteTx.Delete interface method
+func (t *errTx) Delete([]byte) error { return t.err }
+
+// Apply implements the db.WriteTx.Apply interface method
+func (t *errTx) Apply(db.WriteTx) error { return t.err }
+
+// Commit implements the db.WriteTx.Commit interface method
+func (t *errTx) Commit() error { return t.err }
+
+// Discard implements the db.ReadTx.Discard interface method
+func (t *errTx) Discard() { t.tx.Discard() }
@@ -0,0 +1,135 @@
+package census
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+var (
+	dbKeyMaxLevels    = []byte("maxLevels")
+	dbKeyHashFunction = []byte("hashFunction")
+)
+
+var (
+	// DefaultMaxLevels defines the default number of levels of the Census
+	// MerkleTree
+	DefaultMaxLevels = types.MaxLevels
+	// DefaultHashFunction defines the default HashFunction of the Census
+	// MerkleTree
+	DefaultHashFunction arbo.HashFunction = arbo.HashFunctionPoseidon
+)
+
+// initTreeParams returns the MaxLevels and HashFunction of the Census
+// MerkleTree stored in the db. If they are not stored yet, the given ones (or
+// the defaults if not set) are stored, which for a Census created before the
+// MerkleTree parameters were stored can only be the defaults. The given
+// parameters, when set, can not differ from the stored ones.
+func initTreeParams(wTx db.WriteTx, maxLevels int, hashFunc arbo.HashFunction) (
+	int, arbo.HashFunction, error) {
+	if maxLevels < 0 || maxLevels > types.MaxLevels {
+		return 0, nil, fmt.Errorf("invalid MaxLevels: %d, must be between 1"+
+			" and %d", maxLevels, types.MaxLevels)
+	}
+	if hashFunc != nil {
+		if _, err := types.HashFunctionFromType(string(hashFunc.Type())); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	storedMaxLevels, storedHashFunc, err := getTreeParams(wTx)
+	if err == db.ErrKeyNotFound {
+		// if nextIndex is already set, the Census was created with
+		// the default parameters
+		_, err = wTx.Get(dbKeyNextIndex)
+		if err == nil {
+			storedMaxLevels, storedHashFunc = DefaultMaxLevels, DefaultHashFunction
+		} else if err != db.ErrKeyNotFound {
+			return 0, nil, err
+		}
+	} else if err != nil {
+		return 0, nil, err
+	}
+	if storedHashFunc != nil {
+		if (maxLevels != 0 && maxLevels != storedMaxLevels) || (hashFunc != nil &&
+			!bytes.Equal(hashFunc.Type(), storedHashFunc.Type())) {
+			return 0, nil, fmt.Errorf("can not change the MerkleTree"+
+				" parameters of an existing Census, MaxLevels: %d,"+
+				" HashFunction: %s", storedMaxLevels, storedHashFunc.Type())
+		}
+		maxLevels, hashFunc = storedMaxLevels, storedHashFunc
+	}
+	if maxLevels == 0 {
+		maxLevels = DefaultMaxLevels
+	}
+	if hashFunc == nil {
+		hashFunc = DefaultHashFunction
+	}
+
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(maxLevels))
+	if err := wTx.Set(dbKeyMaxLevels, b); err != nil {
+		return 0, nil, err
+	}
+	if err := wTx.Set(dbKeyHashFunction, hashFunc.Type()); err != nil {
+		return 0, nil, err
+	}
+	return maxLevels, hashFunc, nil
+}
+
+func getTreeParams(rTx db.ReadTx) (int, arbo.HashFunction, error) {
+	b, err := rTx.Get(dbKeyMaxLevels)
+	if err != nil {
+		return 0, nil, err
+	}
+	maxLevels := int(binary.LittleEndian.Uint64(b))
+	b, err = rTx.Get(dbKeyHashFunction)
+	if err != nil {
+		return 0, nil, err
+	}
+	hashFunc, err := types.HashFunctionFromType(string(b))
+	if err != nil {
+		return 0, nil, err
+	}
+	return maxLevels, hashFunc, nil
+}
+
+// ErrHashFunctionMismatch is used when the HashFunction expected by the circuit
+// that verifies the CensusProofs is not the one of the Census MerkleTree
+var ErrHashFunctionMismatch = errors.New("HashFunction mismatch")
+
+// HashFunction returns the HashFunction of the Census MerkleTree, which is
+// stored in the db when creating the Census
+func (c *Census) HashFunction() arbo.HashFunction {
+	return c.tree.HashFunction()
+}
+
+// CheckHashFunction returns ErrHashFunctionMismatch if the given HashFunction,
+// expected by the circuit that verifies the CensusProofs, is not the one of
+// the Census MerkleTree, as the CensusProofs would not be valid for it
+func (c *Census) CheckHashFunction(hashFunc arbo.HashFunction) error {
+	if !bytes.Equal(hashFunc.Type(), c.HashFunction().Type()) {
+		return fmt.Errorf("%w, the circuit expects %s and the Census uses %s",
+			ErrHashFunctionMismatch, hashFunc.Type(), c.HashFunction().Type())
+	}
+	return nil
+}
+
+// maxNLeafs returns the maximum number of leafs of the Census MerkleTree
+func (c *Census) maxNLeafs() uint64 {
+	if c.maxLevels < 64 && uint64(1)<<c.maxLevels < types.MaxNLeafs { //nolint:gomnd
+		return uint64(1) << c.maxLevels
+	}
+	return types.MaxNLeafs
+}
+
+// indexKey returns the key of the leaf of the given index in the Census
+// MerkleTree
+func (c *Census) indexKey(index uint64) []byte {
+	return types.Uint64ToIndexWithLevels(index, c.maxLevels)
+}
@@ -0,0 +1,275 @@
+package census
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+func TestTreeParams(t *testing.T) {
+	c := qt.New(t)
+
+	database := newTestDB(c)
+	maxLevels := 4
+	census, err := New(Options{DB: database, MaxLevels: maxLevels,
+		HashFunction: types.HashFunctionMiMC7})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Parameters(), qt.DeepEquals, Parameters{Arity: 2,
+		HashFunction: "mimc7", MaxLevels: maxLevels, MaxNLeafs: 16})
+
+	// the tree is full with 2^maxLevels PublicKeys
+	pubKs, weights := genPublicKeys(17)
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.ErrorMatches, ErrMaxNLeafsReached.Error()+".*")
+	invalids, err := census.AddPublicKeys(pubKs[:16], weights[:16])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	c.Assert(census.Close(), qt.IsNil)
+
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < 16; i++ {
+		index, proof, err := census.GetProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(index, qt.Equals, uint64(i))
+		v, err := CheckProofWithTreeParams(types.HashFunctionMiMC7, maxLevels,
+			root, proof, index, &pubKs[i], weights[i], nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+		// with the default HashFunction the proof is not valid
+		v, err = CheckProof(root, proof, index, &pubKs[i], weights[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsFalse)
+	}
+	v, err := census.VerifyRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.IsTrue)
+
+	// the parameters are loaded from the db
+	census, err = New(Options{DB: database})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Parameters().MaxLevels, qt.Equals, maxLevels)
+	c.Assert(census.Parameters().HashFunction, qt.Equals, "mimc7")
+	// and can not be changed
+	_, err = New(Options{DB: database, MaxLevels: 8})
+	c.Assert(err, qt.ErrorMatches, "can not change the MerkleTree parameters.*")
+	_, err = New(Options{DB: database, HashFunction: arbo.HashFunctionPoseidon})
+	c.Assert(err, qt.ErrorMatches, "can not change the MerkleTree parameters.*")
+
+	// unsupported parameters
+	_, err = New(Options{DB: newTestDB(c), MaxLevels: 65})
+	c.Assert(err, qt.ErrorMatches, "invalid MaxLevels: 65.*")
+	_, err = New(Options{DB: newTestDB(c), HashFunction: arbo.HashFunctionSha256})
+	c.Assert(err, qt.ErrorMatches, "unsupported HashFunction: sha256.*")
+
+	// by default, the Census uses the default parameters
+	census, err = New(Options{DB: newTestDB(c)})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Parameters().MaxLevels, qt.Equals, DefaultMaxLevels)
+	c.Assert(census.Parameters().HashFunction, qt.Equals, "poseidon")
+}
+
+func TestTreeParamsAtIndices(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c), MaxLevels: 4})
+	c.Assert(err, qt.IsNil)
+	pubKs, weights := genPublicKeys(2)
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{PublicKey: pubKs[0], Index: 16}})
+	c.Assert(err, qt.ErrorMatches, ErrMaxNLeafsReached.Error()+".*")
+	err = census.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{PublicKey: pubKs[0], Index: 15}})
+	c.Assert(err, qt.IsNil)
+
+	// the incremental indexes start from 0
+	invalids, err := census.AddPublicKeys(pubKs[1:], weights[1:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	c.Assert(census.Close(), qt.IsNil)
+	index, _, err := census.GetProof(&pubKs[1])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(0))
+	index, _, err = census.GetProof(&pubKs[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(15))
+}
+
+func TestCheckHashFunction(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c),
+		HashFunction: arbo.HashFunctionBlake2b})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Parameters().HashFunction, qt.Equals, "blake2b")
+	c.Assert(census.CheckHashFunction(arbo.HashFunctionBlake2b), qt.IsNil)
+	err = census.CheckHashFunction(arbo.HashFunctionPoseidon)
+	c.Assert(err, qt.ErrorMatches, ErrHashFunctionMismatch.Error()+
+		", the circuit expects poseidon and the Census uses blake2b")
+
+	// the CensusProofs of a blake2b Census are valid for blake2b
+	pubKs, weights := genPublicKeys(10)
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	cp, err := census.GetCensusProof(&pubKs[3])
+	c.Assert(err, qt.IsNil)
+	v, err := CheckCensusProofWithHashFunction(census.HashFunction(), root, cp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.IsTrue)
+}
+
+func TestCheckCensusProofWithTreeParams(t *testing.T) {
+	c := qt.New(t)
+
+	// Blake2b hashes the leaf keys as bytes, so the CensusProofs of a
+	// Blake2b Census with non-default MaxLevels are only valid with its
+	// MaxLevels
+	maxLevels := 4
+	census, err := New(Options{DB: newTestDB(c), MaxLevels: maxLevels,
+		HashFunction: arbo.HashFunctionBlake2b})
+	c.Assert(err, qt.IsNil)
+	pubKs, weights := genPublicKeys(10)
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < len(pubKs); i++ {
+		cp, err := census.GetCensusProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		v, err := CheckCensusProofWithTreeParams(census.HashFunction(),
+			maxLevels, root, cp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+		v, err = CheckCensusProofWithHashFunction(census.HashFunction(), root, cp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsFalse)
+
+		index, proof, err := census.GetProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		v, err = CheckProofWithTreeParams(census.HashFunction(), maxLevels,
+			root, proof, index, &pubKs[i], weights[i], nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+		v, err = CheckProofWithHashFunction(census.HashFunction(), root, proof,
+			index, &pubKs[i], weights[i], nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsFalse)
+	}
+}
+
+func TestMaxNLeafsNextIndex(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c), MaxLevels: 4, ChunkSize: 2})
+	c.Assert(err, qt.IsNil)
+	pubKs, weights := genPublicKeys(20)
+	_, err = census.AddPublicKeys(pubKs[:10], weights[:10])
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.RemovePublicKeys(pubKs[:2]), qt.IsNil)
+
+	// the Size is 8 but the next index is 10, so only 6 keys fit, and a
+	// multi-chunk batch that does not fit is rejected before adding any
+	// chunk
+	_, err = census.AddPublicKeys(pubKs[10:17], weights[10:17])
+	c.Assert(err, qt.ErrorMatches, ErrMaxNLeafsReached.Error()+".*")
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(8))
+	_, err = census.AddPublicKeysWithResults(pubKs[10:17], weights[10:17])
+	c.Assert(err, qt.ErrorMatches, ErrMaxNLeafsReached.Error()+".*")
+
+	// the indexes explicitly assigned are skipped, so they are counted
+	census2, err := New(Options{DB: newTestDB(c), MaxLevels: 4, ChunkSize: 2})
+	c.Assert(err, qt.IsNil)
+	err = census2.AddPublicKeysAtIndices([]IndexedPublicKey{
+		{PublicKey: pubKs[19], Index: 3}})
+	c.Assert(err, qt.IsNil)
+	_, err = census2.AddPublicKeys(pubKs[:16], weights[:16])
+	c.Assert(err, qt.ErrorMatches, ErrMaxNLeafsReached.Error()+".*")
+	size, err = census2.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(1))
+	_, err = census2.AddPublicKeys(pubKs[:15], weights[:15])
+	c.Assert(err, qt.IsNil)
+
+	_, err = census.AddPublicKeys(pubKs[10:16], weights[10:16])
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	index, _, err := census.GetProof(&pubKs[15])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(15))
+}
+
+// failingDB is a db.Database whose db.WriteTx fail to commit once the given
+// number of commits is reached
+type failingDB struct {
+	db.Database
+	commits, failAt int
+}
+
+func (d *failingDB) WriteTx() db.WriteTx {
+	return &failingWriteTx{WriteTx: d.Database.WriteTx(), db: d}
+}
+
+type failingWriteTx struct {
+	db.WriteTx
+	db *failingDB
+}
+
+func (t *failingWriteTx) Commit() error {
+	t.db.commits++
+	if t.db.commits == t.db.failAt {
+		return errors.New("commit failed")
+	}
+	return t.WriteTx.Commit()
+}
+
+func TestPartialBatchError(t *testing.T) {
+	c := qt.New(t)
+
+	database := &failingDB{Database: newTestDB(c)}
+	census, err := New(Options{DB: database, ChunkSize: 2,
+		CheckpointInterval: 100})
+	c.Assert(err, qt.IsNil)
+	pubKs, weights := genPublicKeys(8)
+
+	// the 3rd chunk fails, the first 2 chunks are kept
+	database.failAt = database.commits + 3
+	_, err = census.AddPublicKeys(pubKs, weights)
+	var partialErr *PartialBatchError
+	c.Assert(errors.As(err, &partialErr), qt.IsTrue)
+	c.Assert(partialErr.NAdded, qt.Equals, 4)
+	c.Assert(partialErr.Position, qt.Equals, 4)
+	c.Assert(err, qt.ErrorMatches, "commit failed, 4 keys of the batch added,"+
+		" stopped at position 4")
+	size, err := census.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(4))
+	// the Checkpoint and the RootHistory contain the committed chunks
+	cp, err := census.GetCheckpoint()
+	c.Assert(err, qt.IsNil)
+	c.Assert(cp.NLeafs, qt.Equals, uint64(4))
+	history, err := census.RootHistory()
+	c.Assert(err, qt.IsNil)
+	c.Assert(history, qt.HasLen, 1)
+	c.Assert(history[0].BatchSize, qt.Equals, 4)
+
+	// the rest of the batch can be added from the returned position
+	_, err = census.AddPublicKeys(pubKs[partialErr.Position:],
+		weights[partialErr.Position:])
+	c.Assert(err, qt.IsNil)
+
+	// a failure in the first chunk returns the error as is
+	more, moreWeights := genPublicKeys(4)
+	database.failAt = database.commits + 1
+	_, err = census.AddPublicKeys(more, moreWeights)
+	c.Assert(err, qt.ErrorMatches, "commit failed")
+}
@@ -0,0 +1,189 @@
+package census
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+)
+
+// errLeafsCorrupted is used internally by VerifyRoot when the leafs data is
+// not consistent
+var errLeafsCorrupted = errors.New("Census leafs corrupted")
+
+// rootLeaf contains the index and the hash of a leaf used to recompute the
+// CensusRoot
+type rootLeaf struct {
+	index uint64
+	hash  []byte
+}
+
+// VerifyRoot checks the integrity of the closed Census. It recomputes in
+// memory the CensusRoot from the PublicKeys and weights of the Census, and
+// compares it with the stored CensusRoot, and it re-walks the stored
+// MerkleTree from the CensusRoot, checking that the key of each node is the
+// hash of its content. Returns false if any of the checks fails, which means
+// that the Census db is corrupted, and an error only if the Census can not be
+// read.
+func (c *Census) VerifyRoot() (bool, error) {
+	root, err := c.Root()
+	if err != nil {
+		return false, err
+	}
+
+	leafs, err := c.rootLeafs()
+	if errors.Is(err, errLeafsCorrupted) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	computed, err := c.computeRoot(leafs, 0)
+	if errors.Is(err, errLeafsCorrupted) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(computed, root) {
+		return false, nil
+	}
+
+	return c.verifyNodes(root)
+}
+
+// rootLeafs returns the leafs of the Census computed from the Index->PublicKey
+// and the PublicKey->Index,Weight mappings (and the ones of the addresses for
+// a Census of KeyTypeEthAddress), without reading the MerkleTree
+func (c *Census) rootLeafs() ([]rootLeaf, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	hashFunc := c.tree.HashFunction()
+	var leafs []rootLeaf
+	err := c.IterateLeaves(func(index uint64, pubK babyjub.PublicKey) error {
+		pubKComp := pubK.Compress()
+		indexAndWeight, err := rTx.Get(pubKComp[:])
+		if err == db.ErrKeyNotFound {
+			return fmt.Errorf("%s, PublicKey of index %d without weight",
+				errLeafsCorrupted, index)
+		} else if err != nil {
+			return err
+		}
+		pubKIndex, weight, err := types.BytesToIndexAndWeight(indexAndWeight)
+		if err != nil {
+			return err
+		}
+		if pubKIndex != index {
+			return fmt.Errorf("%s, PublicKey of index %d mapped to index %d",
+				errLeafsCorrupted, index, pubKIndex)
+		}
+		value, _, err := leafValue(rTx, &pubK, weight)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFunc.Hash(c.indexKey(index), value,
+			[]byte{arbo.PrefixValueLeaf})
+		if err != nil {
+			return err
+		}
+		leafs = append(leafs, rootLeaf{index: index, hash: hash})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.keyType == types.KeyTypeEthAddress {
+		addrLeafs, err := c.addressRootLeafs(rTx)
+		if err != nil {
+			return nil, err
+		}
+		leafs = append(leafs, addrLeafs...)
+	}
+	return leafs, nil
+}
+
+// computeRoot computes the root of the subtree at the given level containing
+// the given leafs, splitting them by the bit of their index at each level, as
+// the MerkleTree does
+func (c *Census) computeRoot(leafs []rootLeaf, level int) ([]byte, error) {
+	hashFunc := c.tree.HashFunction()
+	switch len(leafs) {
+	case 0:
+		return make([]byte, hashFunc.Len()), nil
+	case 1:
+		return leafs[0].hash, nil
+	}
+	if level >= c.maxLevels {
+		return nil, fmt.Errorf("%s, multiple leafs with index %d",
+			errLeafsCorrupted, leafs[0].index)
+	}
+
+	var left, right []rootLeaf
+	for i := 0; i < len(leafs); i++ {
+		if (leafs[i].index>>uint(level))&1 == 0 {
+			left = append(left, leafs[i])
+		} else {
+			right = append(right, leafs[i])
+		}
+	}
+	l, err := c.computeRoot(left, level+1)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.computeRoot(right, level+1)
+	if err != nil {
+		return nil, err
+	}
+	return hashFunc.Hash(l, r)
+}
+
+// verifyNodes walks the stored MerkleTree from the given root, returning false
+// if the key of any node is not the hash of its content, or if any node is
+// missing or not well formed
+func (c *Census) verifyNodes(root []byte) (bool, error) {
+	rTx := c.db.ReadTx()
+	defer rTx.Discard()
+
+	hashFunc := c.tree.HashFunction()
+	valid := true
+	err := c.tree.IterateWithStopWithTx(treeRTx(rTx), root,
+		func(_ int, k, v []byte) bool {
+			if !valid {
+				return true
+			}
+			var h []byte
+			var err error
+			switch v[0] {
+			case arbo.PrefixValueEmpty:
+				return false
+			case arbo.PrefixValueLeaf:
+				leafK, leafV := arbo.ReadLeafValue(v)
+				h, err = hashFunc.Hash(leafK, leafV, []byte{arbo.PrefixValueLeaf})
+			case arbo.PrefixValueIntermediate:
+				if len(v) != arbo.PrefixValueLen+2*hashFunc.Len() {
+					valid = false
+					return true
+				}
+				l, r := arbo.ReadIntermediateChilds(v)
+				h, err = hashFunc.Hash(l, r)
+			}
+			// the hash fails if the content of the node is not
+			// valid for the hash function
+			if err != nil || !bytes.Equal(h, k) {
+				valid = false
+				return true
+			}
+			return false
+		})
+	if errors.Is(err, db.ErrKeyNotFound) || errors.Is(err, arbo.ErrInvalidValuePrefix) {
+		// a node referenced by its parent is missing, or its content is
+		// not a valid node
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return valid, nil
+}
@@ -0,0 +1,125 @@
+package census
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+)
+
+func TestVerifyRoot(t *testing.T) {
+	c := qt.New(t)
+
+	// empty closed Census
+	census := newTestCensus(c)
+	_, err := census.VerifyRoot()
+	c.Assert(err, qt.Equals, ErrCensusNotClosed)
+	c.Assert(census.Close(), qt.IsNil)
+	ok, err := census.VerifyRoot()
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+
+	for _, nKeys := range []int{1, 2, 17} {
+		var pubKs []babyjub.PublicKey
+		var weights []*big.Int
+		for i := 0; i < nKeys; i++ {
+			sk := babyjub.NewRandPrivKey()
+			pubKs = append(pubKs, *sk.Public())
+			weights = append(weights, big.NewInt(int64(i+1)))
+		}
+		census := newTestCensus(c)
+		_, err := census.AddPublicKeys(pubKs, weights)
+		c.Assert(err, qt.IsNil)
+		c.Assert(census.Close(), qt.IsNil)
+		ok, err := census.VerifyRoot()
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue, qt.Commentf("nKeys: %d", nKeys))
+	}
+}
+
+func TestVerifyRootCorrupted(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 8
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs = append(pubKs, *sk.Public())
+		weights = append(weights, big.NewInt(1))
+	}
+	census := newTestCensus(c)
+	_, err := census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	// get a leaf node and an intermediate node of the MerkleTree
+	var leafK, leafV, interK, interV []byte
+	err = census.tree.IterateWithStop(root, func(_ int, k, v []byte) bool {
+		if v[0] == arbo.PrefixValueLeaf && leafK == nil {
+			leafK = append([]byte{}, k...)
+			leafV = append([]byte{}, v...)
+		}
+		if v[0] == arbo.PrefixValueIntermediate && interK == nil &&
+			!bytes.Equal(k, root) {
+			interK = append([]byte{}, k...)
+			interV = append([]byte{}, v...)
+		}
+		return false
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(leafK, qt.Not(qt.IsNil))
+	c.Assert(interK, qt.Not(qt.IsNil))
+
+	setNode := func(k, v []byte) {
+		wTx := census.db.WriteTx()
+		defer wTx.Discard()
+		c.Assert(wTx.Set(k, v), qt.IsNil)
+		c.Assert(wTx.Commit(), qt.IsNil)
+	}
+	checkVerifyRoot := func(expected bool) {
+		ok, err := census.VerifyRoot()
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.Equals, expected)
+	}
+
+	// corrupt the value of the leaf, keeping its key
+	corrupted := append([]byte{}, leafV...)
+	corrupted[len(corrupted)-1] ^= 1
+	setNode(leafK, corrupted)
+	checkVerifyRoot(false)
+	setNode(leafK, leafV)
+	checkVerifyRoot(true)
+
+	// corrupt an intermediate node to point to a missing child
+	corrupted = append([]byte{}, interV...)
+	corrupted[len(corrupted)-1] ^= 1
+	setNode(interK, corrupted)
+	checkVerifyRoot(false)
+	// an intermediate node with a not valid prefix
+	setNode(interK, []byte{42})
+	checkVerifyRoot(false)
+	setNode(interK, interV)
+	checkVerifyRoot(true)
+
+	// corrupt the weight of a PublicKey, which is not part of the
+	// MerkleTree nodes
+	pubKComp := pubKs[3].Compress()
+	rTx := census.db.ReadTx()
+	indexAndWeight, err := rTx.Get(pubKComp[:])
+	rTx.Discard()
+	c.Assert(err, qt.IsNil)
+	index, _, err := types.BytesToIndexAndWeight(indexAndWeight)
+	c.Assert(err, qt.IsNil)
+	corrupted = types.IndexAndWeightToBytes(index, big.NewInt(2))
+	setNode(pubKComp[:], corrupted)
+	checkVerifyRoot(false)
+	setNode(pubKComp[:], indexAndWeight)
+	checkVerifyRoot(true)
+}
@@ -0,0 +1,188 @@
+package census
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/vocdoni/arbo"
+)
+
+// VocdoniCensusType mirrors the models.Census_Type enum of go.vocdoni.io/proto,
+// which identifies the MerkleTree implementation of a Vocdoni census
+type VocdoniCensusType int32
+
+var (
+	// VocdoniCensusArboBlake2b is the Vocdoni census type of an arbo
+	// MerkleTree using Blake2b (models.Census_ARBO_BLAKE2B)
+	VocdoniCensusArboBlake2b VocdoniCensusType = 1
+	// VocdoniCensusArboPoseidon is the Vocdoni census type of an arbo
+	// MerkleTree using Poseidon (models.Census_ARBO_POSEIDON)
+	VocdoniCensusArboPoseidon VocdoniCensusType = 2
+)
+
+// ErrInvalidVocdoniDump is used when a Vocdoni census dump can not be imported
+// in the Census
+var ErrInvalidVocdoniDump = errors.New("invalid Vocdoni census dump")
+
+// VocdoniCensusDump contains a Census MerkleTree in the format used by the
+// census service of go.vocdoni.io (census.CensusDump), where Data contains the
+// leafs of the MerkleTree encoded by arbo Dump
+type VocdoniCensusDump struct {
+	Type     VocdoniCensusType `json:"type"`
+	RootHash []byte            `json:"rootHash"`
+	Data     []byte            `json:"data"`
+}
+
+// vocdoniCensusType returns the Vocdoni census type of the given HashFunction,
+// as the Vocdoni censuses only use Poseidon and Blake2b
+func vocdoniCensusType(hashFunc arbo.HashFunction) (VocdoniCensusType, error) {
+	switch {
+	case bytes.Equal(hashFunc.Type(), arbo.TypeHashPoseidon):
+		return VocdoniCensusArboPoseidon, nil
+	case bytes.Equal(hashFunc.Type(), arbo.TypeHashBlake2b):
+		return VocdoniCensusArboBlake2b, nil
+	default:
+		return 0, fmt.Errorf("the HashFunction %s is not supported by the"+
+			" Vocdoni censuses", hashFunc.Type())
+	}
+}
+
+// ExportVocdoni returns the MerkleTree of the closed Census encoded as a JSON
+// VocdoniCensusDump, which can be imported by the go.vocdoni.io census
+// service. Only the MerkleTree is exported, so the PublicKeys of the Census
+// need to be exported separately (see PublicKeys) to import it again with
+// ImportVocdoni.
+func (c *Census) ExportVocdoni() ([]byte, error) {
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return nil, err
+	}
+	if !isClosed {
+		return nil, ErrCensusNotClosed
+	}
+	censusType, err := vocdoniCensusType(c.HashFunction())
+	if err != nil {
+		return nil, err
+	}
+	root, err := c.Root()
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.tree.Dump(root)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(VocdoniCensusDump{
+		Type:     censusType,
+		RootHash: root,
+		Data:     data,
+	})
+}
+
+// ImportVocdoni imports in the empty open Census the given JSON
+// VocdoniCensusDump, together with the given PublicKeys of its leafs, and
+// closes the Census. As the Vocdoni census dump only contains the leafs of the
+// MerkleTree, the PublicKeys can not be recovered from it, so each one of the
+// given PublicKeys is checked against the leaf at its index before adding any
+// of them, and the dump can not contain leafs without a PublicKey. Returns
+// ErrInvalidVocdoniDump if the dump does not match the Census parameters or
+// the given PublicKeys.
+func (c *Census) ImportVocdoni(b []byte, keys []IndexedPublicKey) error {
+	var dump VocdoniCensusDump
+	if err := json.Unmarshal(b, &dump); err != nil {
+		return fmt.Errorf("%s, can not decode it: %s", ErrInvalidVocdoniDump, err)
+	}
+	censusType, err := vocdoniCensusType(c.HashFunction())
+	if err != nil {
+		return err
+	}
+	if dump.Type != censusType {
+		return fmt.Errorf("%s, the dump has type %d and the Census uses %d"+
+			" (HashFunction %s)", ErrInvalidVocdoniDump, dump.Type, censusType,
+			c.HashFunction().Type())
+	}
+	size, err := c.Size()
+	if err != nil {
+		return err
+	}
+	if size != 0 {
+		return fmt.Errorf("%s, can only be imported in an empty Census,"+
+			" current size: %d", ErrInvalidVocdoniDump, size)
+	}
+
+	leafs, err := readVocdoniLeafs(dump.Data)
+	if err != nil {
+		return err
+	}
+	if len(leafs) != len(keys) {
+		return fmt.Errorf("%s, the dump contains %d leafs and %d PublicKeys"+
+			" are given", ErrInvalidVocdoniDump, len(leafs), len(keys))
+	}
+	for i := 0; i < len(keys); i++ {
+		weight := keys[i].Weight
+		if weight == nil {
+			weight = big.NewInt(1)
+		}
+		leafV, err := types.HashLeafBytes(&keys[i].PublicKey, weight, keys[i].Data)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(leafs[string(c.indexKey(keys[i].Index))], leafV) {
+			return fmt.Errorf("%s, the leaf of index %d does not match its"+
+				" PublicKey", ErrInvalidVocdoniDump, keys[i].Index)
+		}
+	}
+
+	if err := c.AddPublicKeysAtIndices(keys); err != nil {
+		return err
+	}
+	if err := c.Close(); err != nil {
+		return err
+	}
+	root, err := c.Root()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, dump.RootHash) {
+		return fmt.Errorf("%s, the imported root %x does not match the"+
+			" dump rootHash %x", ErrInvalidVocdoniDump, root, dump.RootHash)
+	}
+	return nil
+}
+
+// readVocdoniLeafs decodes the leafs encoded by arbo Dump, returning the
+// value of each leaf by its key
+func readVocdoniLeafs(data []byte) (map[string][]byte, error) {
+	leafs := make(map[string][]byte)
+	r := bytes.NewReader(data)
+	for {
+		l := make([]byte, 2) //nolint:gomnd
+		if _, err := io.ReadFull(r, l); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s, can not decode its leafs: %s",
+				ErrInvalidVocdoniDump, err)
+		}
+		k := make([]byte, l[0])
+		v := make([]byte, l[1])
+		if _, err := io.ReadFull(r, k); err != nil {
+			return nil, fmt.Errorf("%s, can not decode its leafs: %s",
+				ErrInvalidVocdoniDump, err)
+		}
+		if _, err := io.ReadFull(r, v); err != nil {
+			return nil, fmt.Errorf("%s, can not decode its leafs: %s",
+				ErrInvalidVocdoniDump, err)
+		}
+		if _, ok := leafs[string(k)]; ok {
+			return nil, fmt.Errorf("%s, the key %x is repeated",
+				ErrInvalidVocdoniDump, k)
+		}
+		leafs[string(k)] = v
+	}
+	return leafs, nil
+}
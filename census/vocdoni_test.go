@@ -0,0 +1,113 @@
+package census
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/vocdoni/arbo"
+)
+
+func TestVocdoniDump(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c)})
+	c.Assert(err, qt.IsNil)
+	pubKs, weights := genPublicKeys(100)
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+
+	// an open Census can not be exported
+	_, err = census.ExportVocdoni()
+	c.Assert(err, qt.Equals, ErrCensusNotClosed)
+	c.Assert(census.Close(), qt.IsNil)
+	b, err := census.ExportVocdoni()
+	c.Assert(err, qt.IsNil)
+	root, err := census.Root()
+	c.Assert(err, qt.IsNil)
+
+	var dump VocdoniCensusDump
+	c.Assert(json.Unmarshal(b, &dump), qt.IsNil)
+	c.Assert(dump.Type, qt.Equals, VocdoniCensusArboPoseidon)
+	c.Assert(dump.RootHash, qt.DeepEquals, root)
+
+	// the dump can be imported as is by an arbo MerkleTree, as the
+	// go.vocdoni.io census service does
+	tree, err := arbo.NewTree(arbo.Config{Database: newTestDB(c),
+		MaxLevels: 256, HashFunction: arbo.HashFunctionPoseidon})
+	c.Assert(err, qt.IsNil)
+	c.Assert(tree.ImportDump(dump.Data), qt.IsNil)
+	treeRoot, err := tree.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(treeRoot, qt.DeepEquals, root)
+
+	keys, err := census.PublicKeys()
+	c.Assert(err, qt.IsNil)
+
+	// the PublicKeys need to match the leafs of the dump
+	imported, err := New(Options{DB: newTestDB(c)})
+	c.Assert(err, qt.IsNil)
+	err = imported.ImportVocdoni(b, keys[:99])
+	c.Assert(err, qt.ErrorMatches, ErrInvalidVocdoniDump.Error()+
+		", the dump contains 100 leafs and 99 PublicKeys are given")
+	wrongKeys := append([]IndexedPublicKey{}, keys...)
+	wrongKeys[0].PublicKey, wrongKeys[1].PublicKey = keys[1].PublicKey, keys[0].PublicKey
+	err = imported.ImportVocdoni(b, wrongKeys)
+	c.Assert(err, qt.ErrorMatches, ErrInvalidVocdoniDump.Error()+
+		", the leaf of index 0 does not match its PublicKey")
+	err = imported.ImportVocdoni(b[:len(b)/2], keys)
+	c.Assert(err, qt.ErrorMatches, ErrInvalidVocdoniDump.Error()+", can not decode it.*")
+	size, err := imported.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(0))
+
+	err = imported.ImportVocdoni(b, keys)
+	c.Assert(err, qt.IsNil)
+	importedRoot, err := imported.Root()
+	c.Assert(err, qt.IsNil)
+	c.Assert(importedRoot, qt.DeepEquals, root)
+	for i := 0; i < len(pubKs); i++ {
+		index, _, err := imported.GetProof(&pubKs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(index, qt.Equals, uint64(i))
+	}
+	// the Census is closed once imported
+	err = imported.ImportVocdoni(b, keys)
+	c.Assert(err, qt.ErrorMatches, ErrInvalidVocdoniDump.Error()+
+		", can only be imported in an empty Census.*")
+}
+
+func TestVocdoniDumpHashFunction(t *testing.T) {
+	c := qt.New(t)
+
+	census, err := New(Options{DB: newTestDB(c),
+		HashFunction: arbo.HashFunctionBlake2b})
+	c.Assert(err, qt.IsNil)
+	pubKs, weights := genPublicKeys(10)
+	_, err = census.AddPublicKeys(pubKs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	b, err := census.ExportVocdoni()
+	c.Assert(err, qt.IsNil)
+	var dump VocdoniCensusDump
+	c.Assert(json.Unmarshal(b, &dump), qt.IsNil)
+	c.Assert(dump.Type, qt.Equals, VocdoniCensusArboBlake2b)
+
+	// a Blake2b dump can not be imported in a Poseidon Census
+	keys, err := census.PublicKeys()
+	c.Assert(err, qt.IsNil)
+	imported, err := New(Options{DB: newTestDB(c)})
+	c.Assert(err, qt.IsNil)
+	err = imported.ImportVocdoni(b, keys)
+	c.Assert(err, qt.ErrorMatches, ErrInvalidVocdoniDump.Error()+
+		", the dump has type 1 and the Census uses 2.*")
+
+	// the Vocdoni censuses do not use MiMC7
+	census, err = New(Options{DB: newTestDB(c),
+		HashFunction: types.HashFunctionMiMC7})
+	c.Assert(err, qt.IsNil)
+	c.Assert(census.Close(), qt.IsNil)
+	_, err = census.ExportVocdoni()
+	c.Assert(err, qt.ErrorMatches, "the HashFunction mimc7 is not supported.*")
+}
@@ -0,0 +1,44 @@
+package censusbuilder
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/log"
+)
+
+// AddAddresses adds the given Ethereum addresses to the Census for the given
+// censusID, which needs to be created with the KeyType
+// types.KeyTypeEthAddress (see census.Census.AddAddresses)
+func (cb *CensusBuilder) AddAddresses(censusID types.CensusID, addrs []common.Address,
+	weights []*big.Int) error {
+	err := cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	invalids, err := cb.getCensus(censusID).AddAddresses(addrs, weights)
+	if len(invalids) != 0 {
+		return fmt.Errorf("CensusBuilder.AddAddresses error: %s",
+			census.FormatInvalidKeys(invalids))
+	}
+	if err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] %d addresses added", censusID, len(addrs))
+	return nil
+}
+
+// GetAddressProof returns the CensusProof of the given Ethereum address in the
+// closed Census of the given censusID, of KeyType types.KeyTypeEthAddress
+func (cb *CensusBuilder) GetAddressProof(censusID types.CensusID,
+	addr common.Address) (*types.CensusProof, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).GetAddressProof(addr)
+}
@@ -0,0 +1,91 @@
+package censusbuilder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestAddAddresses(t *testing.T) {
+	c := qt.New(t)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensusWithOptions(CensusOptions{
+		KeyType: types.KeyTypeEthAddress})
+	c.Assert(err, qt.IsNil)
+
+	var addrs []common.Address
+	var weights []*big.Int
+	for i := 0; i < 5; i++ {
+		addrs = append(addrs, common.BigToAddress(big.NewInt(int64(i+1))))
+		weights = append(weights, big.NewInt(1))
+	}
+	err = cb.AddAddresses(censusID, addrs, weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.AddAddresses(censusID, addrs[:1], weights[:1])
+	c.Assert(err, qt.ErrorMatches, "CensusBuilder.AddAddresses error: 1 invalid.*")
+	c.Assert(cb.CloseCensus(censusID), qt.IsNil)
+
+	ci, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.KeyType, qt.Equals, types.KeyTypeEthAddress)
+	c.Assert(ci.Size, qt.Equals, uint64(5))
+
+	proof, err := cb.GetAddressProof(censusID, addrs[2])
+	c.Assert(err, qt.IsNil)
+	c.Assert(proof.Index, qt.Equals, uint64(2))
+	valid, err := cb.VerifyMembershipProof(censusID, *proof)
+	c.Assert(err, qt.IsNil)
+	c.Assert(valid, qt.IsTrue)
+	proof.Weight = big.NewInt(2)
+	valid, err = cb.VerifyMembershipProof(censusID, *proof)
+	c.Assert(err, qt.IsNil)
+	c.Assert(valid, qt.IsFalse)
+	c.Assert(cb.Close(), qt.IsNil)
+}
+
+func TestCopyCensusAddresses(t *testing.T) {
+	c := qt.New(t)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	sourceID, err := cb.NewCensusWithOptions(CensusOptions{
+		KeyType: types.KeyTypeEthAddress})
+	c.Assert(err, qt.IsNil)
+
+	var addrs []common.Address
+	var weights []*big.Int
+	for i := 0; i < 5; i++ {
+		addrs = append(addrs, common.BigToAddress(big.NewInt(int64(i+1))))
+		weights = append(weights, big.NewInt(int64(i+1)))
+	}
+	err = cb.AddAddresses(sourceID, addrs, weights)
+	c.Assert(err, qt.IsNil)
+	c.Assert(cb.CloseCensus(sourceID), qt.IsNil)
+	sourceRoot, err := cb.CensusRoot(sourceID)
+	c.Assert(err, qt.IsNil)
+
+	copyID, err := cb.CopyCensus(sourceID)
+	c.Assert(err, qt.IsNil)
+	ci, err := cb.CensusInfo(copyID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.KeyType, qt.Equals, types.KeyTypeEthAddress)
+	c.Assert(ci.Size, qt.Equals, uint64(5))
+	c.Assert(cb.CloseCensus(copyID), qt.IsNil)
+	copyRoot, err := cb.CensusRoot(copyID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(copyRoot, qt.DeepEquals, sourceRoot)
+
+	// the addresses keep their indexes and weights
+	proof, err := cb.GetAddressProof(copyID, addrs[3])
+	c.Assert(err, qt.IsNil)
+	c.Assert(proof.Index, qt.Equals, uint64(3))
+	valid, err := cb.VerifyMembershipProof(sourceID, *proof)
+	c.Assert(err, qt.IsNil)
+	c.Assert(valid, qt.IsTrue)
+	c.Assert(cb.Close(), qt.IsNil)
+}
@@ -0,0 +1,160 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+)
+
+var dbPrefixArchived = []byte("archived")
+
+// archivedCensus contains the data stored in the CensusBuilder db for each
+// archived Census
+type archivedCensus struct {
+	// Path is the directory where the Census sub-db has been moved
+	Path string      `json:"path"`
+	Info census.Info `json:"info"`
+}
+
+func dbKeyArchived(censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	return append(append([]byte{}, dbPrefixArchived...), b...)
+}
+
+func (cb *CensusBuilder) getArchived(rTx db.ReadTx, censusID types.CensusID) (
+	*archivedCensus, error) {
+	b, err := rTx.Get(dbKeyArchived(censusID))
+	if err != nil {
+		return nil, err
+	}
+	var a archivedCensus
+	if err := json.Unmarshal(b, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// isArchived returns true if the Census of the given censusID is archived
+func (cb *CensusBuilder) isArchived(censusID types.CensusID) (bool, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	_, err := rTx.Get(dbKeyArchived(censusID))
+	if err == db.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ArchiveCensus closes (if it is not closed yet) the Census of the given
+// censusID, and moves its sub-db into the given archivePath directory. The
+// archived Census can not be used until it is restored with RestoreCensus.
+// Returns ErrCensusInUse if the Census is being used concurrently.
+func (cb *CensusBuilder) ArchiveCensus(censusID types.CensusID, archivePath string) error {
+	cb.lifecycleMu.Lock()
+	defer cb.lifecycleMu.Unlock()
+	if cb.isEphemeral(censusID) {
+		return ErrCensusEphemeral
+	}
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	c := cb.getCensus(censusID)
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return err
+	}
+	if !isClosed {
+		if err := cb.CloseCensus(censusID); err != nil {
+			return err
+		}
+	}
+	info, err := c.Info()
+	if err != nil {
+		return err
+	}
+	info.Archived = true
+
+	if err := os.MkdirAll(archivePath, os.ModePerm); err != nil {
+		return err
+	}
+	dst := filepath.Join(archivePath, strconv.Itoa(int(censusID)))
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		return fmt.Errorf("can not archive CensusID=%d, %s already exists",
+			censusID, dst)
+	}
+
+	if err := cb.detachCensus(censusID); err != nil {
+		return err
+	}
+	defer cb.detachDone(censusID)
+	src := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(archivedCensus{Path: dst, Info: *info})
+	if err != nil {
+		return err
+	}
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	if err := wTx.Set(dbKeyArchived(censusID), b); err != nil {
+		return err
+	}
+	if err := wTx.Commit(); err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] archived at %s", censusID, dst)
+	return nil
+}
+
+// RestoreCensus moves back the sub-db of the archived Census of the given
+// censusID, so it can be used again.
+func (cb *CensusBuilder) RestoreCensus(censusID types.CensusID) error {
+	cb.lifecycleMu.Lock()
+	defer cb.lifecycleMu.Unlock()
+	rTx := cb.db.ReadTx()
+	a, err := cb.getArchived(rTx, censusID)
+	rTx.Discard()
+	if err == db.ErrKeyNotFound {
+		return fmt.Errorf("CensusID=%d is not archived", censusID)
+	} else if err != nil {
+		return err
+	}
+
+	dst := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		return fmt.Errorf("can not restore CensusID=%d, %s already exists",
+			censusID, dst)
+	}
+	if err := os.Rename(a.Path, dst); err != nil {
+		return err
+	}
+
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	if err := wTx.Delete(dbKeyArchived(censusID)); err != nil {
+		return err
+	}
+	if err := wTx.Commit(); err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] restored from %s", censusID, a.Path)
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	cb.releaseCensus(censusID)
+	return nil
+}
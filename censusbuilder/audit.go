@@ -0,0 +1,28 @@
+package censusbuilder
+
+import (
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// AuditCensus checks the integrity of the db of the Census of the given
+// censusID with census.Audit, which unlike VerifyRoot can be used on an open
+// Census, and reports each corrupted leaf. It is intended to be run by the
+// operators after a crash or a disk error.
+func (cb *CensusBuilder) AuditCensus(censusID types.CensusID) (*census.AuditReport, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	report, err := cb.getCensus(censusID).Audit()
+	if err != nil {
+		return nil, err
+	}
+	if !report.Valid() {
+		log.Warnf("[CensusID=%d] Audit failed, %d corrupted leafs and %d"+
+			" corrupted nodes", censusID, len(report.CorruptedLeafs),
+			report.CorruptedNodes)
+	}
+	return report, nil
+}
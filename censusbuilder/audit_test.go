@@ -0,0 +1,66 @@
+package censusbuilder
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/db/pebbledb"
+)
+
+func TestAuditCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	subDBsPath := c.TempDir()
+	cb, err := New(newTestDB(c), subDBsPath)
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	report, err := cb.AuditCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Valid(), qt.IsTrue)
+	c.Assert(report.NLeafs, qt.Equals, uint64(10))
+
+	// remove the Index->PublicKey mapping of a leaf in the Census sub-db
+	err = cb.getCensus(censusID).CloseDB()
+	c.Assert(err, qt.IsNil)
+	delete(cb.censuses, censusID)
+	database, err := pebbledb.New(db.Options{
+		Path: filepath.Join(subDBsPath, strconv.Itoa(int(censusID)))})
+	c.Assert(err, qt.IsNil)
+	var indexPubKKey []byte
+	err = database.Iterate([]byte("indexPubK"), func(k, _ []byte) bool {
+		indexPubKKey = append([]byte("indexPubK"), k...)
+		return false
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(indexPubKKey, qt.Not(qt.IsNil))
+	wTx := database.WriteTx()
+	c.Assert(wTx.Delete(indexPubKKey), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	c.Assert(database.Close(), qt.IsNil)
+
+	report, err = cb.AuditCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Valid(), qt.IsFalse)
+	c.Assert(report.NLeafs, qt.Equals, uint64(9))
+	c.Assert(report.CorruptedLeafs, qt.DeepEquals, []census.CorruptedLeaf{
+		{Index: 0, Reason: census.CorruptionLeafOrphan},
+	})
+
+	_, err = cb.AuditCensus(42)
+	c.Assert(err, qt.ErrorMatches, "CensusID=42 does not exist")
+
+	// close the Census sub-dbs before the TempDir is removed
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+}
@@ -0,0 +1,339 @@
+package censusbuilder
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+const (
+	// BackupVersion defines the version of the format of the archives
+	// created by Backup
+	BackupVersion = 1
+
+	// backupMetadataName is the name of the file of the archive with the
+	// backupMetadata, which is the first one
+	backupMetadataName = "census.json"
+	// backupDBDir is the directory of the archive that contains the files
+	// of the Census sub-db
+	backupDBDir = "db"
+)
+
+// ErrInvalidBackup is used when the archive given to Restore has not been
+// created by Backup
+var ErrInvalidBackup = errors.New("invalid Census backup")
+
+// backupMetadata contains the data of the Census stored in the archive
+// together with its sub-db
+type backupMetadata struct {
+	Version  int            `json:"version"`
+	CensusID types.CensusID `json:"censusID"`
+	Label    string         `json:"label,omitempty"`
+	// ExternalID and ExpiresAt contain the ExternalID and the expiration
+	// of the Census, which are stored in the CensusBuilder db
+	ExternalID string      `json:"externalID,omitempty"`
+	ExpiresAt  *time.Time  `json:"expiresAt,omitempty"`
+	Info       census.Info `json:"info"`
+}
+
+// Backup writes into the given io.Writer a tar.gz archive with the sub-db of
+// the Census of the given censusID (which contains its MerkleTree and
+// metadata) and its Label, ExternalID and expiration, which can be restored in
+// another CensusBuilder
+// with Restore. Returns ErrCensusInUse if the Census is being used
+// concurrently, as its sub-db needs to be closed while it is archived.
+func (cb *CensusBuilder) Backup(censusID types.CensusID, w io.Writer) error {
+	cb.lifecycleMu.Lock()
+	defer cb.lifecycleMu.Unlock()
+	if cb.isEphemeral(censusID) {
+		return ErrCensusEphemeral
+	}
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	info, err := cb.getCensus(censusID).Info()
+	if err != nil {
+		return err
+	}
+	label, err := cb.getLabel(censusID)
+	if err != nil {
+		return err
+	}
+	externalID, err := cb.getExternalID(censusID)
+	if err != nil {
+		return err
+	}
+	var expiresAt *time.Time
+	expiration, err := cb.getExpiration(censusID)
+	if err != nil {
+		return err
+	}
+	if !expiration.IsZero() {
+		expiresAt = &expiration
+	}
+	metadata, err := json.Marshal(backupMetadata{
+		Version:    BackupVersion,
+		CensusID:   censusID,
+		Label:      label,
+		ExternalID: externalID,
+		ExpiresAt:  expiresAt,
+		Info:       *info,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := cb.detachCensus(censusID); err != nil {
+		return err
+	}
+	defer cb.detachDone(censusID)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err = tw.WriteHeader(&tar.Header{
+		Name: backupMetadataName,
+		Mode: 0600, //nolint:gomnd
+		Size: int64(len(metadata)),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(metadata); err != nil {
+		return err
+	}
+	src := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
+	if err := tarDir(tw, src, backupDBDir); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] backup created", censusID)
+	return nil
+}
+
+// tarDir writes the files of the src directory into the given tar.Writer,
+// under the given prefix
+func tarDir(tw *tar.Writer, src, prefix string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(filepath.Clean(p))
+		if err != nil {
+			return err
+		}
+		defer f.Close() //nolint:errcheck
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// Restore creates a new Census from the given archive created by Backup,
+// returning the censusID of the new Census, which may differ from the one of
+// the archived Census. The Label and the ExternalID of the archived Census are
+// restored if they are not used by another Census of the CensusBuilder, and
+// its expiration is restored as it was. A closed Census is indexed by its
+// CensusRoot, so its VotingDeadline is checked for the votes.
+func (cb *CensusBuilder) Restore(r io.Reader) (types.CensusID, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", ErrInvalidBackup, err)
+	}
+	defer gr.Close() //nolint:errcheck
+	tr := tar.NewReader(gr)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", ErrInvalidBackup, err)
+	}
+	if hdr.Name != backupMetadataName {
+		return 0, fmt.Errorf("%s, expected %s as first file, got %s",
+			ErrInvalidBackup, backupMetadataName, hdr.Name)
+	}
+	var metadata backupMetadata
+	if err := json.NewDecoder(tr).Decode(&metadata); err != nil {
+		return 0, fmt.Errorf("%s, can not decode %s: %s", ErrInvalidBackup,
+			backupMetadataName, err)
+	}
+	if metadata.Version != BackupVersion {
+		return 0, fmt.Errorf("%s, unsupported version %d, expected %d",
+			ErrInvalidBackup, metadata.Version, BackupVersion)
+	}
+
+	cb.lifecycleMu.Lock()
+	defer cb.lifecycleMu.Unlock()
+	censusID, err := cb.newCensusID(func(censusID types.CensusID) error {
+		dst := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			return fmt.Errorf("can not restore the Census into CensusID=%d,"+
+				" %s already exists", censusID, dst)
+		}
+		err := untarDir(tr, dst, backupDBDir)
+		if err == nil {
+			err = cb.checkRestoredSubDB(dst, &metadata.Info)
+		}
+		if err != nil {
+			// the error is omitted, as the extraction error is
+			// returned
+			os.RemoveAll(dst) //nolint:errcheck
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return 0, err
+	}
+	defer cb.releaseCensus(censusID)
+	if err := cb.indexCensusKeys(censusID); err != nil {
+		return 0, err
+	}
+	if metadata.Info.Closed {
+		if err := cb.indexRoot(censusID); err != nil {
+			return 0, err
+		}
+	}
+	if metadata.ExpiresAt != nil {
+		if err := cb.setExpiration(censusID, *metadata.ExpiresAt); err != nil {
+			return 0, err
+		}
+	}
+	cb.labelsMu.Lock()
+	defer cb.labelsMu.Unlock()
+	if metadata.Label != "" {
+		if err := cb.setLabel(censusID, metadata.Label); err != nil {
+			log.Warnf("[CensusID=%d] Label %q not restored: %s", censusID,
+				metadata.Label, err)
+		}
+	}
+	if metadata.ExternalID != "" {
+		if err := cb.setExternalID(censusID, metadata.ExternalID); err != nil {
+			log.Warnf("[CensusID=%d] ExternalID %q not restored: %s",
+				censusID, metadata.ExternalID, err)
+		}
+	}
+	log.Debugf("[CensusID=%d] restored from the backup of CensusID=%d",
+		censusID, metadata.CensusID)
+	return censusID, nil
+}
+
+// checkRestoredSubDB opens the Census sub-db extracted at the given path, and
+// checks that it contains the Census described by the given Info
+func (cb *CensusBuilder) checkRestoredSubDB(path string, info *census.Info) error {
+	database, err := openSubDB(path, cb.pebbleOpts)
+	if err != nil {
+		return err
+	}
+	c, err := census.New(census.Options{DB: database, Now: cb.now})
+	if err != nil {
+		database.Close() //nolint:errcheck
+		return err
+	}
+	defer c.CloseDB() //nolint:errcheck
+	restored, err := c.Info()
+	if err != nil {
+		return err
+	}
+	if restored.Size != info.Size || restored.Closed != info.Closed ||
+		!bytes.Equal(restored.Root, info.Root) {
+		return fmt.Errorf("%s, the sub-db does not match the Census of the"+
+			" backup, size: %d, root: %x, expected size: %d, root: %x",
+			ErrInvalidBackup, restored.Size, restored.Root, info.Size, info.Root)
+	}
+	return nil
+}
+
+// untarDir extracts the files of the given tar.Reader under the given prefix
+// into the dst directory, which is created
+func untarDir(tr *tar.Reader, dst, prefix string) error {
+	if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+		return err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", ErrInvalidBackup, err)
+		}
+		// reject the paths outside the prefix directory
+		name := path.Clean(hdr.Name)
+		if name == prefix {
+			continue
+		}
+		if !strings.HasPrefix(name, prefix+"/") {
+			return fmt.Errorf("%s, unexpected file %s", ErrInvalidBackup,
+				hdr.Name)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(
+			strings.TrimPrefix(name, prefix+"/")))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+				return err
+			}
+			if err := extractFile(tr, target, hdr.FileInfo().Mode()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s, unexpected type of file %s",
+				ErrInvalidBackup, hdr.Name)
+		}
+	}
+}
+
+func extractFile(r io.Reader, dst string, mode os.FileMode) error {
+	out, err := os.OpenFile(filepath.Clean(dst), os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil { //nolint:gosec
+		out.Close() //nolint:errcheck
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,238 @@
+package censusbuilder
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestBackupRestore(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(100)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensusWithLabel("backup")
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+
+	var backup bytes.Buffer
+	err = cb.Backup(censusID, &backup)
+	c.Assert(err, qt.IsNil)
+
+	// the Census can still be used after the backup
+	_, _, err = cb.GetProof(censusID, &keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+
+	// restore the backup in another CensusBuilder, which already has a
+	// Census with the same censusID
+	cb2, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		KeyIndex:   true,
+	})
+	c.Assert(err, qt.IsNil)
+	_, err = cb2.NewCensus()
+	c.Assert(err, qt.IsNil)
+	restoredID, err := cb2.Restore(bytes.NewReader(backup.Bytes()))
+	c.Assert(err, qt.IsNil)
+	c.Assert(restoredID, qt.Equals, types.CensusID(1))
+
+	ci, err := cb2.CensusInfo(restoredID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(100))
+	c.Assert(ci.Closed, qt.IsTrue)
+	c.Assert(ci.Root, qt.DeepEquals, root)
+	labelID, err := cb2.FindCensusByLabel("backup")
+	c.Assert(err, qt.IsNil)
+	c.Assert(labelID, qt.Equals, restoredID)
+	censusIDs, err := cb2.FindCensusesForKey(keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusIDs, qt.DeepEquals, []types.CensusID{restoredID})
+	for i := 0; i < len(keys.PublicKeys); i++ {
+		cp, err := cb2.GetCensusProof(restoredID, &keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		v, err := cb2.VerifyMembershipProof(restoredID, *cp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+	}
+
+	// restoring it again creates another Census, without the Label, which
+	// is already used
+	restoredID2, err := cb2.Restore(bytes.NewReader(backup.Bytes()))
+	c.Assert(err, qt.IsNil)
+	c.Assert(restoredID2, qt.Equals, types.CensusID(2))
+	ci, err = cb2.CensusInfo(restoredID2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Root, qt.DeepEquals, root)
+	labelID, err = cb2.FindCensusByLabel("backup")
+	c.Assert(err, qt.IsNil)
+	c.Assert(labelID, qt.Equals, restoredID)
+
+	// an open Census can be restored and keeps accepting PublicKeys
+	openID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(openID, keys.PublicKeys[:10], keys.Weights[:10])
+	c.Assert(err, qt.IsNil)
+	backup.Reset()
+	err = cb.Backup(openID, &backup)
+	c.Assert(err, qt.IsNil)
+	restoredID, err = cb2.Restore(&backup)
+	c.Assert(err, qt.IsNil)
+	err = cb2.AddPublicKeys(restoredID, keys.PublicKeys[10:], keys.Weights[10:])
+	c.Assert(err, qt.IsNil)
+	err = cb2.CloseCensus(restoredID)
+	c.Assert(err, qt.IsNil)
+	restoredRoot, err := cb2.CensusRoot(restoredID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(restoredRoot, qt.DeepEquals, root)
+
+	// a Census in use can not be backed up
+	err = cb.loadCensusIfNotYet(censusID)
+	c.Assert(err, qt.IsNil)
+	err = cb.Backup(censusID, ioutil.Discard)
+	c.Assert(err, qt.ErrorMatches, ErrCensusInUse.Error()+".*")
+	cb.releaseCensus(censusID)
+
+	c.Assert(cb.Close(), qt.IsNil)
+	c.Assert(cb2.Close(), qt.IsNil)
+}
+
+func TestBackupRestoreMetadata(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	cb, err := NewWithOptions(Options{DB: newTestDB(c), SubDBsPath: c.TempDir(),
+		Now: clock})
+	c.Assert(err, qt.IsNil)
+	expiresAt := now.Add(24 * time.Hour)
+	censusID, err := cb.NewCensusWithOptions(CensusOptions{
+		VotingDeadline: now.Add(time.Hour),
+		ExternalID:     "process-1",
+		ExpiresAt:      expiresAt,
+	})
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+
+	var backup bytes.Buffer
+	err = cb.Backup(censusID, &backup)
+	c.Assert(err, qt.IsNil)
+
+	cb2, err := NewWithOptions(Options{DB: newTestDB(c), SubDBsPath: c.TempDir(),
+		Now: clock})
+	c.Assert(err, qt.IsNil)
+	restoredID, err := cb2.Restore(bytes.NewReader(backup.Bytes()))
+	c.Assert(err, qt.IsNil)
+
+	externalID, err := cb2.CensusByExternalID("process-1")
+	c.Assert(err, qt.IsNil)
+	c.Assert(externalID, qt.Equals, restoredID)
+	restoredExpiresAt, err := cb2.getExpiration(restoredID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(restoredExpiresAt.Equal(expiresAt), qt.IsTrue)
+
+	// the VotingDeadline of the restored Census is checked by its root
+	c.Assert(cb2.CheckVotingOpenByRoot(root), qt.IsNil)
+	now = now.Add(2 * time.Hour)
+	err = cb2.CheckVotingOpenByRoot(root)
+	c.Assert(err, qt.ErrorMatches, census.ErrVotingClosed.Error()+".*")
+
+	// restoring it again does not attach the ExternalID, which is already
+	// used
+	restoredID2, err := cb2.Restore(bytes.NewReader(backup.Bytes()))
+	c.Assert(err, qt.IsNil)
+	externalID, err = cb2.CensusByExternalID("process-1")
+	c.Assert(err, qt.IsNil)
+	c.Assert(externalID, qt.Equals, restoredID)
+	ci, err := cb2.CensusInfo(restoredID2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.ExternalID, qt.Equals, "")
+
+	c.Assert(cb.Close(), qt.IsNil)
+	c.Assert(cb2.Close(), qt.IsNil)
+}
+
+func TestRestoreInvalidBackup(t *testing.T) {
+	c := qt.New(t)
+
+	subDBsPath := c.TempDir()
+	cb, err := New(newTestDB(c), subDBsPath)
+	c.Assert(err, qt.IsNil)
+
+	_, err = cb.Restore(bytes.NewReader([]byte("not a backup")))
+	c.Assert(err, qt.ErrorMatches, ErrInvalidBackup.Error()+".*")
+
+	newArchive := func(files map[string]string, order []string) *bytes.Buffer {
+		var b bytes.Buffer
+		gw := gzip.NewWriter(&b)
+		tw := tar.NewWriter(gw)
+		for _, name := range order {
+			err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600,
+				Size: int64(len(files[name]))})
+			c.Assert(err, qt.IsNil)
+			_, err = tw.Write([]byte(files[name]))
+			c.Assert(err, qt.IsNil)
+		}
+		c.Assert(tw.Close(), qt.IsNil)
+		c.Assert(gw.Close(), qt.IsNil)
+		return &b
+	}
+
+	// without metadata
+	_, err = cb.Restore(newArchive(map[string]string{"db/MANIFEST": "x"},
+		[]string{"db/MANIFEST"}))
+	c.Assert(err, qt.ErrorMatches, ErrInvalidBackup.Error()+
+		", expected census.json.*")
+	// unsupported version
+	_, err = cb.Restore(newArchive(map[string]string{"census.json": `{"version":2}`},
+		[]string{"census.json"}))
+	c.Assert(err, qt.ErrorMatches, ErrInvalidBackup.Error()+
+		", unsupported version 2.*")
+	// files outside the db directory
+	_, err = cb.Restore(newArchive(map[string]string{
+		"census.json":   `{"version":1}`,
+		"db/../../evil": "x",
+	}, []string{"census.json", "db/../../evil"}))
+	c.Assert(err, qt.ErrorMatches, ErrInvalidBackup.Error()+
+		", unexpected file.*")
+	_, err = os.Stat(filepath.Join(subDBsPath, "..", "evil"))
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+	// the sub-db does not contain the Census of the metadata
+	_, err = cb.Restore(newArchive(map[string]string{
+		"census.json": `{"version":1,"info":{"size":10}}`,
+	}, []string{"census.json"}))
+	c.Assert(err, qt.ErrorMatches, ErrInvalidBackup.Error()+
+		", the sub-db does not match.*")
+
+	// the failed restores do not leave any sub-db
+	for i := 0; i < 5; i++ {
+		_, err = os.Stat(filepath.Join(subDBsPath, strconv.Itoa(i)))
+		c.Assert(os.IsNotExist(err), qt.IsTrue)
+	}
+	c.Assert(cb.Close(), qt.IsNil)
+}
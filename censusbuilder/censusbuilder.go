@@ -2,34 +2,267 @@ package censusbuilder
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/big"
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
 	"go.vocdoni.io/dvote/db"
-	"go.vocdoni.io/dvote/db/pebbledb"
 	"go.vocdoni.io/dvote/log"
 )
 
+var (
+	// ErrCensusArchived is used when trying to use a Census that has
+	// been archived, and needs to be restored first
+	ErrCensusArchived = errors.New("Census archived")
+	// ErrCensusInUse is used when trying to archive or delete a Census
+	// that is being used concurrently, and when trying to use a Census
+	// that is being archived or deleted
+	ErrCensusInUse = errors.New("Census in use")
+	// ErrCensusNotFound is used when the requested Census has not been
+	// created by the CensusBuilder
+	ErrCensusNotFound = errors.New("Census not found")
+)
+
+// censusNotFoundError is the error returned for a CensusID that does not
+// exist, which matches ErrCensusNotFound with errors.Is
+type censusNotFoundError struct {
+	censusID types.CensusID
+}
+
+// Error implements the error interface
+func (e censusNotFoundError) Error() string {
+	return fmt.Sprintf("CensusID=%d does not exist", e.censusID)
+}
+
+// Is returns true if the given target is ErrCensusNotFound
+func (e censusNotFoundError) Is(target error) bool {
+	return target == ErrCensusNotFound
+}
+
 // CensusBuilder manages multiple Census MerkleTrees
 type CensusBuilder struct {
 	subDBsPath string
 	db         db.Database
+	chunkSize  int
+	keyIndex   bool
+	// hashWorkers and thresholdNLeafs are passed to the census.Options
+	// of each Census
+	hashWorkers     int
+	thresholdNLeafs int
+	// storeProofs enables storing the CensusProofs of each Census
+	// when it is closed
+	storeProofs bool
+	// pebbleOpts are the PebbleOptions used to open the Census sub-dbs
+	pebbleOpts PebbleOptions
+	// now is the clock used by all the Censuses to check their
+	// VotingDeadline and to timestamp their creation and closing, and to
+	// track when each loaded Census was last used
+	now func() time.Time
 
 	// censuses contains the loaded census
-	censuses map[uint64]*census.Census
+	censuses   map[types.CensusID]*census.Census
+	censusesMu sync.RWMutex
+	// censusRefs contains the number of callers using each loaded
+	// Census, which can not be evicted while it is in use
+	censusRefs map[types.CensusID]int
+	// censusLastUsed contains the time at which each loaded Census was
+	// last released
+	censusLastUsed map[types.CensusID]time.Time
+	// ephemeral contains the loaded Censuses created with the Ephemeral
+	// CensusOption, which are never unloaded, as their data is in memory
+	ephemeral         map[types.CensusID]bool
+	maxLoadedCensuses int
+	idleTimeout       time.Duration
+	evictStop         chan struct{}
+	evictWg           sync.WaitGroup
+	// compactionInterval is the interval at which the maintenance
+	// goroutine compacts the closed Censuses
+	compactionInterval time.Duration
+	compactStop        chan struct{}
+	compactWg          sync.WaitGroup
+	// gcInterval is the interval at which the expired Censuses are
+	// deleted
+	gcInterval time.Duration
+	gcStop     chan struct{}
+	gcWg       sync.WaitGroup
+	// detaching contains the Censuses being archived or deleted, which
+	// can not be loaded until the operation finishes
+	detaching map[types.CensusID]bool
+	// lifecycleMu serializes ArchiveCensus, RestoreCensus, DeleteCensus,
+	// CompactCensus and the other operations that detach a Census
+	lifecycleMu sync.Mutex
+	// newCensusMu ensures that each censusID is only assigned once
+	newCensusMu sync.Mutex
+
+	// jobs is the queue of jobs enqueued with EnqueueAddPublicKeys
+	jobs        chan addPublicKeysJob
+	jobStatuses map[uint64]*JobStatus
+	nextJobID   uint64
+	closed      bool
+	jobsMu      sync.Mutex
+	workersWg   sync.WaitGroup
+
+	// queues contains the per-Census queues used by
+	// AddPublicKeysAndStoreError
+	queues          map[types.CensusID]*censusQueue
+	queuesClosed    bool
+	queuesMu        sync.Mutex
+	censusQueueSize int
+
+	// labelsMu ensures that the uniqueness of the Labels and the
+	// ExternalIDs is checked and updated atomically
+	labelsMu sync.Mutex
+
+	// OnCensusClosed, if set, is called after a Census has been closed,
+	// with its censusID and its CensusRoot. It is called synchronously
+	// from CloseCensus, and if it returns an error, the error is logged,
+	// but the Census remains closed.
+	OnCensusClosed func(censusID types.CensusID, root []byte) error
+	// CanDeleteCensus, if set, is called by DeleteCensus before deleting
+	// a Census, with its censusID and its CensusRoot. If it returns an
+	// error, the Census is not deleted. It is used to check that the
+	// processes that use the Census are finished.
+	CanDeleteCensus func(censusID types.CensusID, root []byte) error
+}
+
+// Options is used to pass the parameters to load a new CensusBuilder
+type Options struct {
+	// DB defines the database used by the CensusBuilder to store its
+	// metadata
+	DB db.Database
+	// SubDBsPath defines the directory where the databases of each Census
+	// are stored
+	SubDBsPath string
+	// ChunkSize defines the maximum number of PublicKeys added in a
+	// single db.WriteTx when adding keys to a Census. If not set,
+	// census.DefaultChunkSize is used.
+	ChunkSize int
+	// KeyIndex enables the reverse index PublicKey->CensusIDs used by
+	// FindCensusesForKey, which is updated when adding PublicKeys. It
+	// requires an additional write for each added PublicKey, but avoids
+	// checking all the Censuses when looking for a PublicKey. Only the
+	// PublicKeys added while the KeyIndex is enabled are indexed.
+	KeyIndex bool
+	// StoreProofs enables generating and storing the CensusProofs of all
+	// the PublicKeys of each Census when it is closed, so ExportAllProofs
+	// returns them without generating them again.
+	StoreProofs bool
+	// Workers defines the number of workers that process the jobs
+	// enqueued with EnqueueAddPublicKeys. If not set, DefaultWorkers is
+	// used.
+	Workers int
+	// QueueSize defines the maximum number of jobs waiting to be
+	// processed. If not set, DefaultQueueSize is used.
+	QueueSize int
+	// CensusQueueSize defines the maximum number of batches of PublicKeys
+	// waiting to be added to each Census by AddPublicKeysAndStoreError.
+	// If not set, DefaultCensusQueueSize is used.
+	CensusQueueSize int
+	// Pebble defines the tuning parameters applied to the database of
+	// each Census. If not set, the defaults of PebbleOptions are used.
+	Pebble PebbleOptions
+	// Now defines the clock used to check the VotingDeadline of the
+	// Censuses and to timestamp their creation and closing. If not set,
+	// time.Now is used.
+	Now func() time.Time
+	// MaxLoadedCensuses defines the maximum number of Censuses kept in
+	// memory with their db open. When it is exceeded, the least recently
+	// used Censuses are unloaded, and they are loaded again from disk when
+	// used. If not set, DefaultMaxLoadedCensuses is used. The total budget
+	// of the loaded Censuses is up to MaxLoadedCensuses * (CacheSize + 2 *
+	// MemTableSize) bytes of memory and MaxLoadedCensuses * MaxOpenFiles
+	// open files of the Pebble options, which with the defaults is 32 * 24
+	// MB = 768 MB and 32 * 64 = 2048 open files.
+	MaxLoadedCensuses int
+	// IdleTimeout defines the time after which a Census that has not been
+	// used is unloaded. If not set, the Censuses are only unloaded when
+	// MaxLoadedCensuses is exceeded.
+	IdleTimeout time.Duration
+	// CompactionInterval defines the interval at which the sub-dbs of the
+	// closed Censuses that have not been compacted yet are compacted in
+	// background, to reclaim the disk space left by the writes done while
+	// building them. If not set, the Censuses are only compacted with
+	// CompactCensus and CompactCensuses.
+	CompactionInterval time.Duration
+	// GCInterval defines the interval at which the Censuses whose
+	// expiration time has passed are deleted in background (see
+	// CollectExpiredCensuses). If not set, the expired Censuses are only
+	// deleted with CollectExpiredCensuses.
+	GCInterval time.Duration
+	// HashWorkers defines the number of goroutines used by each Census to
+	// hash the keys added to it (see census.Options.HashWorkers). If not
+	// set, runtime.NumCPU() is used.
+	HashWorkers int
+	// ThresholdNLeafs defines the number of leafs up to which the chunks
+	// of keys are added to the MerkleTree of each Census in memory (see
+	// census.Options.ThresholdNLeafs). If not set, the arbo default is
+	// used.
+	ThresholdNLeafs int
 }
 
 // New loads the CensusBuilder
 func New(database db.Database, subDBsPath string) (*CensusBuilder, error) {
+	return NewWithOptions(Options{DB: database, SubDBsPath: subDBsPath})
+}
+
+// NewWithOptions loads the CensusBuilder with the given Options
+func NewWithOptions(opts Options) (*CensusBuilder, error) {
+	nWorkers := opts.Workers
+	if nWorkers <= 0 {
+		nWorkers = DefaultWorkers
+	}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	censusQueueSize := opts.CensusQueueSize
+	if censusQueueSize <= 0 {
+		censusQueueSize = DefaultCensusQueueSize
+	}
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+	maxLoadedCensuses := opts.MaxLoadedCensuses
+	if maxLoadedCensuses <= 0 {
+		maxLoadedCensuses = DefaultMaxLoadedCensuses
+	}
 	cb := &CensusBuilder{
-		subDBsPath: subDBsPath,
-		db:         database,
-		censuses:   make(map[uint64]*census.Census),
+		subDBsPath:  opts.SubDBsPath,
+		db:          opts.DB,
+		chunkSize:   opts.ChunkSize,
+		keyIndex:    opts.KeyIndex,
+		hashWorkers: opts.HashWorkers,
+		storeProofs: opts.StoreProofs,
+		now:         now,
+		censuses:    make(map[types.CensusID]*census.Census),
+		censusRefs:  make(map[types.CensusID]int),
+		jobs:        make(chan addPublicKeysJob, queueSize),
+		jobStatuses: make(map[uint64]*JobStatus),
+
+		queues:          make(map[types.CensusID]*censusQueue),
+		censusQueueSize: censusQueueSize,
+		pebbleOpts:      opts.Pebble.withDefaults(),
+
+		censusLastUsed:    make(map[types.CensusID]time.Time),
+		ephemeral:         make(map[types.CensusID]bool),
+		detaching:         make(map[types.CensusID]bool),
+		maxLoadedCensuses: maxLoadedCensuses,
+		idleTimeout:       opts.IdleTimeout,
+
+		compactionInterval: opts.CompactionInterval,
+		gcInterval:         opts.GCInterval,
+		thresholdNLeafs:    opts.ThresholdNLeafs,
 	}
 
 	wTx := cb.db.WriteTx()
@@ -44,20 +277,30 @@ func New(database db.Database, subDBsPath string) (*CensusBuilder, error) {
 		}
 	}
 
-	// TODO check that nextCensusID matches the last subdb Census db in
-	// disk
-
 	// commit the db.WriteTx
 	if err := wTx.Commit(); err != nil {
 		return nil, err
 	}
+	if err := cb.deleteEphemeralCensuses(); err != nil {
+		return nil, err
+	}
 
+	cb.startWorkers(nWorkers)
+	if cb.idleTimeout > 0 {
+		cb.startIdleEviction()
+	}
+	if cb.compactionInterval > 0 {
+		cb.startCompaction()
+	}
+	if cb.gcInterval > 0 {
+		cb.startGC()
+	}
 	return cb, nil
 }
 
 var dbKeyNextCensusID = []byte("nextCensusID")
 
-func (cb *CensusBuilder) setNextCensusID(wTx db.WriteTx, nextCensusID uint64) error {
+func (cb *CensusBuilder) setNextCensusID(wTx db.WriteTx, nextCensusID types.CensusID) error {
 	b := make([]byte, 8)
 	binary.LittleEndian.PutUint64(b, uint64(nextCensusID))
 	if err := wTx.Set(dbKeyNextCensusID, b); err != nil {
@@ -66,63 +309,206 @@ func (cb *CensusBuilder) setNextCensusID(wTx db.WriteTx, nextCensusID uint64) er
 	return nil
 }
 
-func (cb *CensusBuilder) getNextCensusID(rTx db.ReadTx) (uint64, error) {
+func (cb *CensusBuilder) getNextCensusID(rTx db.ReadTx) (types.CensusID, error) {
 	b, err := rTx.Get(dbKeyNextCensusID)
 	if err != nil {
 		return 0, err
 	}
-	nextCensusID := binary.LittleEndian.Uint64(b)
+	nextCensusID := types.CensusID(binary.LittleEndian.Uint64(b))
 	return nextCensusID, nil
 }
 
-// createCensus will create the Census sub-db and point to it in memory
-func (cb *CensusBuilder) createCensus(censusID uint64) error {
-	path := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
-
-	// check if sub-db already exists for the Census
-	_, err := os.Stat(path)
-	if !os.IsNotExist(err) {
-		return fmt.Errorf("can not createCensus, err: %s", err)
+// nextFreeCensusID returns the first censusID, starting from the given one,
+// which sub-db does not exist and which is not archived. The stored
+// nextCensusID can fall behind the sub-dbs in disk (eg. after copying a sub-db
+// manually, or after a crash between the creation of a sub-db and the update
+// of the nextCensusID), in which case the used censusIDs are skipped instead
+// of reusing their sub-dbs.
+func (cb *CensusBuilder) nextFreeCensusID(censusID types.CensusID) (
+	types.CensusID, error) {
+	for ; ; censusID++ {
+		path := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
+		_, err := os.Stat(path)
+		if err == nil {
+			log.Warnf("[CensusID=%d] sub-db already exists at %s, skipping"+
+				" the censusID", censusID, path)
+			continue
+		} else if !os.IsNotExist(err) {
+			return 0, err
+		}
+		archived, err := cb.isArchived(censusID)
+		if err != nil {
+			return 0, err
+		}
+		if archived {
+			log.Warnf("[CensusID=%d] already archived, skipping the"+
+				" censusID", censusID)
+			continue
+		}
+		deleted, err := cb.isDeleted(censusID)
+		if err != nil {
+			return 0, err
+		}
+		if deleted {
+			log.Warnf("[CensusID=%d] already deleted, skipping the"+
+				" censusID", censusID)
+			continue
+		}
+		return censusID, nil
 	}
+}
 
-	optsDB := db.Options{Path: path}
-	database, err := pebbledb.New(optsDB)
-	if err != nil {
-		return err
+// createCensus will create the Census sub-db (or the in-memory db of an
+// ephemeral Census) and point to it in memory
+func (cb *CensusBuilder) createCensus(censusID types.CensusID, opts CensusOptions) error {
+	optsCensus := census.Options{ChunkSize: cb.chunkSize,
+		SortKeys: opts.SortKeys, Now: cb.now, MaxLevels: opts.MaxLevels,
+		HashFunction: opts.HashFunction, KeyType: opts.KeyType,
+		HashWorkers: cb.hashWorkers, ThresholdNLeafs: cb.thresholdNLeafs}
+	if opts.Ephemeral {
+		// marked before creating it, so it is deleted if the
+		// CensusBuilder stops before being closed
+		if err := cb.setEphemeral(censusID); err != nil {
+			return err
+		}
+		optsCensus.InMemory = true
+	} else {
+		database, err := cb.createSubDB(censusID)
+		if err != nil {
+			return err
+		}
+		optsCensus.DB = database
 	}
-	optsCensus := census.Options{DB: database}
 	c, err := census.New(optsCensus)
 	if err != nil {
 		return err
 	}
+	if !opts.VotingDeadline.IsZero() {
+		if err := c.SetVotingDeadline(opts.VotingDeadline); err != nil {
+			return err
+		}
+	}
+	if !opts.ExpiresAt.IsZero() {
+		if err := cb.setExpiration(censusID, opts.ExpiresAt); err != nil {
+			return err
+		}
+	}
+	cb.censusesMu.Lock()
 	cb.censuses[censusID] = c
+	cb.censusLastUsed[censusID] = cb.now()
+	cb.evictCensuses()
+	cb.censusesMu.Unlock()
 	return nil
 }
 
-// loadCensusIfNotYet will load the Census in memory if it is not loaded yet
-func (cb *CensusBuilder) loadCensusIfNotYet(censusID uint64) error {
+// createSubDB creates and opens the sub-db of the Census of the given censusID
+func (cb *CensusBuilder) createSubDB(censusID types.CensusID) (db.Database, error) {
 	path := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
 
+	// check if sub-db already exists for the Census
+	_, err := os.Stat(path)
+	if err == nil {
+		return nil, fmt.Errorf("can not createCensus, CensusID=%d sub-db"+
+			" already exists at %s", censusID, path)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("can not createCensus, err: %s", err)
+	}
+	return openSubDB(path, cb.pebbleOpts)
+}
+
+// detachCensus closes the db of the loaded Census of the given censusID and
+// removes it from memory, so its sub-db can be moved or removed. The caller
+// needs to be the only one using the Census, otherwise ErrCensusInUse is
+// returned. The Census can not be loaded again until detachDone is called.
+func (cb *CensusBuilder) detachCensus(censusID types.CensusID) error {
+	cb.censusesMu.Lock()
+	defer cb.censusesMu.Unlock()
+	if cb.censusRefs[censusID] > 1 {
+		return fmt.Errorf("%w, CensusID=%d", ErrCensusInUse, censusID)
+	}
+	if c, ok := cb.censuses[censusID]; ok {
+		if err := c.CloseDB(); err != nil {
+			return err
+		}
+	}
+	delete(cb.censuses, censusID)
+	delete(cb.censusLastUsed, censusID)
+	// the data of an ephemeral Census is lost once its db is closed
+	delete(cb.ephemeral, censusID)
+	cb.detaching[censusID] = true
+	return nil
+}
+
+// detachDone allows loading again the Census of the given censusID, detached
+// with detachCensus
+func (cb *CensusBuilder) detachDone(censusID types.CensusID) {
+	cb.censusesMu.Lock()
+	defer cb.censusesMu.Unlock()
+	delete(cb.detaching, censusID)
+}
+
+// getCensus returns the loaded Census of the given censusID, which needs to be
+// loaded before with loadCensusIfNotYet
+func (cb *CensusBuilder) getCensus(censusID types.CensusID) *census.Census {
+	cb.censusesMu.RLock()
+	defer cb.censusesMu.RUnlock()
+	return cb.censuses[censusID]
+}
+
+// loadCensusIfNotYet will load the Census in memory if it is not loaded yet.
+// If no error is returned, the Census is marked as in use, so it is not
+// evicted, until releaseCensus is called.
+func (cb *CensusBuilder) loadCensusIfNotYet(censusID types.CensusID) error {
+	path := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
+
+	cb.censusesMu.Lock()
+	defer cb.censusesMu.Unlock()
 	if _, ok := cb.censuses[censusID]; !ok {
+		if cb.detaching[censusID] {
+			return fmt.Errorf("%w, CensusID=%d is being archived or deleted",
+				ErrCensusInUse, censusID)
+		}
+		// check that the Census is not archived, to avoid creating an
+		// empty sub-db in its place
+		archived, err := cb.isArchived(censusID)
+		if err != nil {
+			return err
+		}
+		if archived {
+			return ErrCensusArchived
+		}
+		deleted, err := cb.isDeleted(censusID)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			return ErrCensusDeleted
+		}
+
 		// check if sub-db exists for the Census
-		_, err := os.Stat(path)
+		_, err = os.Stat(path)
 		if os.IsNotExist(err) {
-			return fmt.Errorf("CensusID=%d does not exist", censusID)
+			return censusNotFoundError{censusID: censusID}
 		}
 
 		// census not loaded, load it
-		optsDB := db.Options{Path: path}
-		database, err := pebbledb.New(optsDB)
+		database, err := openSubDB(path, cb.pebbleOpts)
 		if err != nil {
 			return err
 		}
-		optsCensus := census.Options{DB: database}
+		optsCensus := census.Options{DB: database, ChunkSize: cb.chunkSize,
+			Now: cb.now, HashWorkers: cb.hashWorkers,
+			ThresholdNLeafs: cb.thresholdNLeafs}
 		c, err := census.New(optsCensus)
 		if err != nil {
 			return err
 		}
 		cb.censuses[censusID] = c
+		cb.censusLastUsed[censusID] = cb.now()
+		log.Debugf("[CensusID=%d] loaded", censusID)
 	}
+	cb.censusRefs[censusID]++
+	cb.evictCensuses()
 	return nil
 }
 
@@ -130,20 +516,87 @@ func (cb *CensusBuilder) loadCensusIfNotYet(censusID uint64) error {
 // will be required, to ensure that all these actions are performed by the same
 // key. Probably the authentication will be at the API level.
 
+// CensusOptions is used to pass the parameters to create a new Census
+type CensusOptions struct {
+	// SortKeys defines if the indexes of the PublicKeys are assigned when
+	// closing the Census, sorting the PublicKeys, so the CensusRoot does
+	// not depend on the order in which the PublicKeys are added
+	SortKeys bool
+	// VotingDeadline defines the time after which the votes for the
+	// Census are not accepted. If not set, the Census has no deadline.
+	VotingDeadline time.Time
+	// MaxLevels defines the number of levels of the Census MerkleTree,
+	// which needs to match the census size of the circuit. If not set,
+	// census.DefaultMaxLevels is used.
+	MaxLevels int
+	// HashFunction defines the hash function of the Census MerkleTree
+	// (arbo.HashFunctionPoseidon, types.HashFunctionMiMC7 or
+	// arbo.HashFunctionBlake2b). If not set,
+	// census.DefaultHashFunction is used.
+	HashFunction arbo.HashFunction
+	// ExternalID defines an identifier supplied by the integrator (such
+	// as a processID or a UUID) attached to the Census, which can be used
+	// to find it with CensusByExternalID. The ExternalIDs are unique, and
+	// can not be changed once the Census is created.
+	ExternalID string
+	// ExpiresAt defines the time after which the Census is deleted by
+	// CollectExpiredCensuses. If not set, the Census does not expire.
+	ExpiresAt time.Time
+	// KeyType defines the type of the keys of the Census leafs, the
+	// babyjub PublicKeys (types.KeyTypeBabyJubJub, the default) or the
+	// Ethereum addresses added with AddAddresses (types.KeyTypeEthAddress)
+	KeyType types.KeyType
+	// Ephemeral defines if the Census is kept in memory, without a sub-db
+	// in disk (see census.Options.InMemory), for short-lived Censuses.
+	// Ephemeral Censuses are not unloaded, can not be archived, backed
+	// up, cloned nor compacted, and are deleted when the CensusBuilder is
+	// closed.
+	Ephemeral bool
+}
+
 // NewCensus will create a new Census, if the Census already exists, will load it
-func (cb *CensusBuilder) NewCensus() (uint64, error) {
+func (cb *CensusBuilder) NewCensus() (types.CensusID, error) {
+	return cb.NewCensusWithOptions(CensusOptions{})
+}
+
+// NewCensusWithOptions will create a new Census with the given CensusOptions
+func (cb *CensusBuilder) NewCensusWithOptions(opts CensusOptions) (types.CensusID, error) {
+	if opts.ExternalID != "" {
+		return cb.newCensusWithExternalID(opts)
+	}
+	censusID, err := cb.newCensusID(func(censusID types.CensusID) error {
+		return cb.createCensus(censusID, opts)
+	})
+	if err != nil {
+		return 0, err
+	}
+	log.Debugf("[CensusID=%d] New census created", censusID)
+	return censusID, nil
+}
+
+// newCensusID calls the given function with the next free censusID, which
+// needs to create the sub-db of the new Census, and once it succeeds, the
+// censusID is marked as used
+func (cb *CensusBuilder) newCensusID(create func(censusID types.CensusID) error) (
+	types.CensusID, error) {
+	cb.newCensusMu.Lock()
+	defer cb.newCensusMu.Unlock()
+
 	rTx := cb.db.ReadTx()
-	defer rTx.Discard()
 	nextCensusID, err := cb.getNextCensusID(rTx)
+	rTx.Discard()
 	if err != nil {
 		return 0, err
 	}
-
-	err = cb.createCensus(nextCensusID)
+	nextCensusID, err = cb.nextFreeCensusID(nextCensusID)
 	if err != nil {
 		return 0, err
 	}
 
+	if err := create(nextCensusID); err != nil {
+		return 0, err
+	}
+
 	// store nextCensusID+1 in the CensusBuilder.db
 	wTx := cb.db.WriteTx()
 	defer wTx.Discard()
@@ -154,87 +607,730 @@ func (cb *CensusBuilder) NewCensus() (uint64, error) {
 	if err := wTx.Commit(); err != nil {
 		return 0, err
 	}
-	log.Debugf("[CensusID=%d] New census created", nextCensusID)
-
 	return nextCensusID, nil
 }
 
-// CloseCensus closes the Census of the given censusID.
-func (cb *CensusBuilder) CloseCensus(censusID uint64) error {
+// CloseCensus closes the Census of the given censusID. Closing an already
+// closed Census is a no-op, so the requests can be retried, and its CensusRoot
+// remains the same. The OnCensusClosed hook is only called on the first close.
+func (cb *CensusBuilder) CloseCensus(censusID types.CensusID) error {
 	// TODO to close the Census, the sender will need to be authorized to
 	// ensure that is the same that created the Census
 	err := cb.loadCensusIfNotYet(censusID)
 	if err != nil {
 		return err
 	}
-	return cb.censuses[censusID].Close()
+	defer cb.releaseCensus(censusID)
+	err = cb.getCensus(censusID).Close()
+	if err == census.ErrCensusAlreadyClosed {
+		log.Debugf("[CensusID=%d] already closed", censusID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := cb.indexRoot(censusID); err != nil {
+		return err
+	}
+	if cb.storeProofs {
+		// the Census is already closed, and the CensusProofs are
+		// generated by ExportAllProofs if they could not be stored
+		if err := cb.getCensus(censusID).StoreAllProofs(); err != nil {
+			log.Errorf("[CensusID=%d] can not store the CensusProofs: %s",
+				censusID, err)
+		}
+	}
+
+	if cb.OnCensusClosed != nil {
+		root, err := cb.getCensus(censusID).Root()
+		if err != nil {
+			log.Errorf("[CensusID=%d] can not get the CensusRoot for the"+
+				" OnCensusClosed hook: %s", censusID, err)
+			return nil
+		}
+		if err := cb.OnCensusClosed(censusID, root); err != nil {
+			log.Errorf("[CensusID=%d] OnCensusClosed hook error: %s",
+				censusID, err)
+		}
+	}
+	return nil
+}
+
+// IsClosed returns true if the Census of the given censusID is closed
+func (cb *CensusBuilder) IsClosed(censusID types.CensusID) (bool, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return false, err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).IsClosed()
+}
+
+// Seal seals the Census of the given censusID, returning the hash of its
+// current set of PublicKeys, so it can be announced before closing the Census
+// with CloseCensus. Once sealed, adding PublicKeys to the Census returns
+// census.ErrCensusSealed.
+func (cb *CensusBuilder) Seal(censusID types.CensusID) ([]byte, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	hash, err := cb.getCensus(censusID).Seal()
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("[CensusID=%d] sealed, KeySetHash: %x", censusID, hash)
+	return hash, nil
+}
+
+// RecoverCensus restores the Census of the given censusID to its last
+// census.Checkpoint, discarding the PublicKeys added after it, which is
+// needed after a crash during an AddPublicKeys. The discarded PublicKeys are
+// also removed from the KeyIndex, and can be added again.
+func (cb *CensusBuilder) RecoverCensus(censusID types.CensusID) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	discarded, err := cb.getCensus(censusID).Recover()
+	if err != nil {
+		return err
+	}
+	if cb.keyIndex && len(discarded) != 0 {
+		wTx := cb.db.WriteTx()
+		defer wTx.Discard()
+		for i := 0; i < len(discarded); i++ {
+			if err := wTx.Delete(dbKeyKeyIndex(discarded[i].Compress(),
+				censusID)); err != nil {
+				return err
+			}
+		}
+		if err := wTx.Commit(); err != nil {
+			return err
+		}
+	}
+	log.Debugf("[CensusID=%d] recovered, %d PublicKeys discarded", censusID,
+		len(discarded))
+	return nil
+}
+
+// SetState changes the State of the Census for the given censusID, returning
+// error if the given State can not be reached from the current one. Setting
+// the census.StateClosed is equivalent to calling CloseCensus.
+func (cb *CensusBuilder) SetState(censusID types.CensusID, state census.State) error {
+	if state == census.StateClosed {
+		return cb.CloseCensus(censusID)
+	}
+	err := cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	if err := cb.getCensus(censusID).SetState(state); err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] state set to %s", censusID, state)
+	return nil
+}
+
+// SetAnchor stores the transaction hash and the chainID where the CensusRoot
+// of the closed Census of the given censusID has been published, which is
+// returned in the CensusInfo. If the Census already has an anchor,
+// census.ErrAnchorExists is returned, unless overwrite is set.
+func (cb *CensusBuilder) SetAnchor(censusID types.CensusID, txHash []byte, chainID uint64,
+	overwrite bool) error {
+	err := cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	anchor := census.Anchor{TxHash: txHash, ChainID: chainID}
+	if err := cb.getCensus(censusID).SetAnchor(anchor, overwrite); err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] anchored at tx %x (ChainID=%d)", censusID,
+		txHash, chainID)
+	return nil
 }
 
 // CensusRoot returns the Root of the Census if the Census is closed.
-func (cb *CensusBuilder) CensusRoot(censusID uint64) ([]byte, error) {
+func (cb *CensusBuilder) CensusRoot(censusID types.CensusID) ([]byte, error) {
 	err := cb.loadCensusIfNotYet(censusID)
 	if err != nil {
 		return nil, err
 	}
-	root, err := cb.censuses[censusID].Root()
+	defer cb.releaseCensus(censusID)
+	root, err := cb.getCensus(censusID).Root()
 	if err != nil {
-		return nil, fmt.Errorf("Can not get the CensusRoot, %s", err)
+		return nil, fmt.Errorf("Can not get the CensusRoot, %w", err)
 	}
 	return root, nil
 }
 
-// CensusInfo returns metadata about the Census for the given CensusID
-func (cb *CensusBuilder) CensusInfo(censusID uint64) (*census.Info, error) {
+// VerifyRoot recomputes the CensusRoot of the closed Census of the given
+// censusID from its leafs and compares it with the stored CensusRoot, also
+// checking the stored MerkleTree nodes, to detect a corrupted Census db.
+// Returns false if the Census is corrupted, and an error only if the Census
+// can not be read.
+func (cb *CensusBuilder) VerifyRoot(censusID types.CensusID) (bool, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return false, err
+	}
+	defer cb.releaseCensus(censusID)
+	ok, err := cb.getCensus(censusID).VerifyRoot()
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		log.Warnf("[CensusID=%d] VerifyRoot failed, the Census db is"+
+			" corrupted", censusID)
+	}
+	return ok, nil
+}
+
+// CensusRootTyped returns the Root of the Census if the Census is closed, as a
+// types.Root, returning error if the stored CensusRoot does not have the
+// expected length.
+func (cb *CensusBuilder) CensusRootTyped(censusID types.CensusID) (types.Root, error) {
+	root, err := cb.CensusRoot(censusID)
+	if err != nil {
+		return types.Root{}, err
+	}
+	r, err := types.NewRoot(root)
+	if err != nil {
+		return types.Root{}, fmt.Errorf("CensusID=%d: %s", censusID, err)
+	}
+	return r, nil
+}
+
+// Digest returns the Digest of the Census for the given censusID, which can
+// be used to compare the content of Censuses built by different nodes. The
+// Census needs to be closed.
+func (cb *CensusBuilder) Digest(censusID types.CensusID) ([]byte, error) {
 	err := cb.loadCensusIfNotYet(censusID)
 	if err != nil {
 		return nil, err
 	}
+	defer cb.releaseCensus(censusID)
+	digest, err := cb.getCensus(censusID).Digest()
+	if err != nil {
+		return nil, fmt.Errorf("Can not get the Census Digest, %s", err)
+	}
+	return digest, nil
+}
 
-	return cb.censuses[censusID].Info()
+// Parameters returns the parameters of the Census MerkleTree for the given
+// censusID, which can be used by the clients to build the MerkleProofs. Works
+// for both open and closed Censuses.
+func (cb *CensusBuilder) Parameters(censusID types.CensusID) (census.Parameters, error) {
+	err := cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return census.Parameters{}, err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).Parameters(), nil
+}
+
+// CensusInfo returns metadata about the Census for the given CensusID,
+// including its census.Stats when the Census is not archived
+func (cb *CensusBuilder) CensusInfo(censusID types.CensusID) (*census.Info, error) {
+	label, err := cb.getLabel(censusID)
+	if err != nil {
+		return nil, err
+	}
+	externalID, err := cb.getExternalID(censusID)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := cb.getExpiration(censusID)
+	if err != nil {
+		return nil, err
+	}
+
+	rTx := cb.db.ReadTx()
+	a, err := cb.getArchived(rTx, censusID)
+	rTx.Discard()
+	if err == nil {
+		a.Info.Label = label
+		a.Info.ExternalID = externalID
+		if !expiresAt.IsZero() {
+			a.Info.ExpiresAt = &expiresAt
+		}
+		return &a.Info, nil
+	} else if err != db.ErrKeyNotFound {
+		return nil, err
+	}
+
+	err = cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+
+	info, err := cb.getCensus(censusID).Info()
+	if err != nil {
+		return nil, err
+	}
+	info.Stats, err = cb.getCensus(censusID).Stats()
+	if err != nil {
+		return nil, err
+	}
+	info.Label = label
+	info.ExternalID = externalID
+	if !expiresAt.IsZero() {
+		info.ExpiresAt = &expiresAt
+	}
+	return info, nil
+}
+
+// CensusInfo contains the census.Info of a Census together with its censusID
+type CensusInfo struct {
+	ID types.CensusID `json:"id"`
+	census.Info
+	// Error contains the error obtained when trying to get the census.Info
+	// of the Census, if any
+	Error string `json:"error,omitempty"`
+}
+
+// RecentCensuses returns the CensusInfo of the last created Censuses, up to
+// the given limit, sorted by censusID in descending order. If the census.Info
+// of a Census can not be obtained, the error is placed in its CensusInfo.Error
+// and the rest of Censuses keep being processed.
+func (cb *CensusBuilder) RecentCensuses(limit int) ([]CensusInfo, error) {
+	rTx := cb.db.ReadTx()
+	nextCensusID, err := cb.getNextCensusID(rTx)
+	rTx.Discard()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []CensusInfo
+	for censusID := nextCensusID; censusID > 0 && len(infos) < limit; censusID-- {
+		ci := CensusInfo{ID: censusID - 1}
+		info, err := cb.CensusInfo(ci.ID)
+		if err != nil {
+			ci.Error = err.Error()
+		} else {
+			ci.Info = *info
+		}
+		infos = append(infos, ci)
+	}
+	return infos, nil
 }
 
 // AddPublicKeys adds the batch of given PublicKeys to the Census for the given
-// censusID.
-func (cb *CensusBuilder) AddPublicKeys(censusID uint64, pubKs []babyjub.PublicKey,
+// censusID. If any PublicKey is invalid, no PublicKey is added, use
+// AddPublicKeysWithResults to add the valid ones and get the result of each
+// PublicKey.
+func (cb *CensusBuilder) AddPublicKeys(censusID types.CensusID, pubKs []babyjub.PublicKey,
 	weights []*big.Int) error {
-	err := cb.loadCensusIfNotYet(censusID)
+	invalids, err := cb.addPublicKeys(censusID, pubKs, weights)
+	if len(invalids) != 0 {
+		return fmt.Errorf("CensusBuilder.AddPublicKeys error: %s",
+			census.FormatInvalidKeys(invalids))
+	}
 	if err != nil {
 		return err
 	}
-	invalids, err := cb.censuses[censusID].AddPublicKeys(pubKs, weights)
+	log.Debugf("[CensusID=%d] %d PublicKeys added", censusID, len(pubKs))
+	return nil
+}
+
+// AddPublicKeysWithResults adds the given PublicKeys to the Census for the
+// given censusID, returning a census.KeyResult for each one of them, so the
+// callers can retry only the PublicKeys that have not been added. The valid
+// PublicKeys are added atomically, even if other PublicKeys of the batch are
+// invalid or already in the Census (see
+// census.Census.AddPublicKeysWithResults).
+func (cb *CensusBuilder) AddPublicKeysWithResults(censusID types.CensusID,
+	pubKs []babyjub.PublicKey, weights []*big.Int) ([]census.KeyResult, error) {
+	err := cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	results, err := cb.getCensus(censusID).AddPublicKeysWithResults(pubKs, weights)
+	var added []babyjub.PublicKey
+	for i := 0; i < len(results); i++ {
+		if results[i].Status == census.KeyAdded {
+			added = append(added, pubKs[results[i].Index])
+		}
+	}
+	if cb.keyIndex && len(added) != 0 {
+		if err2 := cb.indexKeys(censusID, added, false); err2 != nil {
+			log.Errorf("[CensusID=%d] can not update the KeyIndex: %s",
+				censusID, err2)
+		}
+	}
+	if err != nil {
+		return results, err
+	}
+	log.Debugf("[CensusID=%d] %d of %d PublicKeys added", censusID,
+		len(added), len(pubKs))
+	return results, nil
+}
+
+// addPublicKeys adds the given PublicKeys to the Census for the given censusID,
+// updating the KeyIndex, and returns the census.InvalidKeys that could not be
+// added
+func (cb *CensusBuilder) addPublicKeys(censusID types.CensusID, pubKs []babyjub.PublicKey,
+	weights []*big.Int) ([]census.InvalidKey, error) {
+	err := cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	invalids, err := cb.getCensus(censusID).AddPublicKeys(pubKs, weights)
+	if cb.keyIndex {
+		// on error, some of the chunks may have been added
+		if err2 := cb.indexKeys(censusID, pubKs, err != nil); err2 != nil {
+			log.Errorf("[CensusID=%d] can not update the KeyIndex: %s",
+				censusID, err2)
+		}
+	}
+	return invalids, err
+}
+
+// AddMembers adds the given Members to the Census for the given censusID,
+// committing the leaf Data of each Member in its leaf value (see
+// census.Census.AddMembers). The CensusProofs of the Members need their Data
+// to be verified.
+func (cb *CensusBuilder) AddMembers(censusID types.CensusID,
+	members []census.Member) error {
+	err := cb.loadCensusIfNotYet(censusID)
 	if err != nil {
 		return err
 	}
+	defer cb.releaseCensus(censusID)
+	invalids, err := cb.getCensus(censusID).AddMembers(members)
+	if cb.keyIndex {
+		pubKs := make([]babyjub.PublicKey, len(members))
+		for i := 0; i < len(members); i++ {
+			pubKs[i] = members[i].PublicKey
+		}
+		// on error, some of the chunks may have been added
+		if err2 := cb.indexKeys(censusID, pubKs, err != nil); err2 != nil {
+			log.Errorf("[CensusID=%d] can not update the KeyIndex: %s",
+				censusID, err2)
+		}
+	}
 	if len(invalids) != 0 {
-		return fmt.Errorf("CensusBuilder.AddPublicKeys error: %d invalid"+
-			" keys, invalid msg for key %d: %s", len(invalids),
-			invalids[0].Index, invalids[0].Error)
+		return fmt.Errorf("CensusBuilder.AddMembers error: %s",
+			census.FormatInvalidKeys(invalids))
 	}
-	log.Debugf("[CensusID=%d] %d PublicKeys added", censusID, len(pubKs))
+	if err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] %d Members added", censusID, len(members))
 	return nil
 }
 
-// AddPublicKeysAndStoreError will call the AddPublicKeys and if there is an
-// error, it will store it into the DB. This method is designed to be called
-// from a goroutine.
-func (cb *CensusBuilder) AddPublicKeysAndStoreError(censusID uint64,
-	pubKs []babyjub.PublicKey, weights []*big.Int) {
-	if err := cb.AddPublicKeys(censusID, pubKs, weights); err != nil {
-		log.Debugf("[CensusID=%d] error: %s", err)
-		if err2 := cb.SetErrMsg(censusID, err.Error()); err2 != nil {
-			log.Errorf("Error while trying to store CensusID:%d status: %s. Error: %s",
-				censusID, err, err2)
+// AddPublicKeysCompressed adds the batch of given compressed PublicKeys to the
+// Census for the given censusID. The PublicKeys are decompressed to compute
+// the Census leafs, returning error if any of them is not valid.
+func (cb *CensusBuilder) AddPublicKeysCompressed(censusID types.CensusID,
+	pubKsComp []babyjub.PublicKeyComp, weights []*big.Int) error {
+	pubKs, err := types.DecompressPublicKeys(pubKsComp)
+	if err != nil {
+		return err
+	}
+	return cb.AddPublicKeys(censusID, pubKs, weights)
+}
+
+// AddPublicKeysAtIndices adds the given PublicKeys at their specified indexes
+// to the Census for the given censusID. Returns error if any of the indexes is
+// already used, in which case none of the given PublicKeys is added.
+func (cb *CensusBuilder) AddPublicKeysAtIndices(censusID types.CensusID,
+	keys []census.IndexedPublicKey) error {
+	err := cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	if err := cb.getCensus(censusID).AddPublicKeysAtIndices(keys); err != nil {
+		return err
+	}
+	if cb.keyIndex {
+		pubKs := make([]babyjub.PublicKey, len(keys))
+		for i := 0; i < len(keys); i++ {
+			pubKs[i] = keys[i].PublicKey
+		}
+		if err := cb.indexKeys(censusID, pubKs, false); err != nil {
+			log.Errorf("[CensusID=%d] can not update the KeyIndex: %s",
+				censusID, err)
+		}
+	}
+	log.Debugf("[CensusID=%d] %d PublicKeys added at explicit indexes",
+		censusID, len(keys))
+	return nil
+}
+
+// MergeCensuses adds the PublicKeys of the Censuses of the given sourceIDs into
+// the Census of the given targetID, which get new indexes in the target
+// Census. The target and the source Censuses need to not be closed, and the
+// source Censuses are not modified. PublicKeys that appear more than once
+// (in the target or in the sources) with the same weight are only added once,
+// and the collisions are logged. If a PublicKey appears more than once with a
+// different weight, an error is returned and no PublicKey is added.
+func (cb *CensusBuilder) MergeCensuses(targetID types.CensusID, sourceIDs []types.CensusID) error {
+	if err := cb.loadOpenCensus(targetID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(targetID)
+	targetKeys, err := cb.getCensus(targetID).PublicKeys()
+	if err != nil {
+		return err
+	}
+	// weights contains the weight of each PublicKey already in the target
+	// Census or that will be added to it
+	weights := make(map[babyjub.PublicKeyComp]*big.Int)
+	for i := 0; i < len(targetKeys); i++ {
+		weights[targetKeys[i].PublicKey.Compress()] = targetKeys[i].Weight
+	}
+
+	var pubKs []babyjub.PublicKey
+	var pubKsWeights []*big.Int
+	nCollisions := 0
+	merged := make(map[types.CensusID]bool)
+	for _, sourceID := range sourceIDs {
+		if sourceID == targetID {
+			return fmt.Errorf("can not merge CensusID=%d into itself", targetID)
+		}
+		if merged[sourceID] {
+			continue
+		}
+		merged[sourceID] = true
+		if err := cb.loadOpenCensus(sourceID); err != nil {
+			return err
+		}
+		defer cb.releaseCensus(sourceID)
+		sourceKeys, err := cb.getCensus(sourceID).PublicKeys()
+		if err != nil {
+			return err
 		}
+		for i := 0; i < len(sourceKeys); i++ {
+			pubKComp := sourceKeys[i].PublicKey.Compress()
+			if len(sourceKeys[i].Data) != 0 {
+				return fmt.Errorf("PublicKey %x of CensusID=%d has leaf"+
+					" data, which is not supported by the merge",
+					pubKComp[:], sourceID)
+			}
+			weight, ok := weights[pubKComp]
+			if ok && weight.Cmp(sourceKeys[i].Weight) != 0 {
+				return fmt.Errorf("PublicKey %x of CensusID=%d has weight"+
+					" %s, but it is already in the merge with weight %s",
+					pubKComp[:], sourceID, sourceKeys[i].Weight, weight)
+			}
+			if ok {
+				log.Debugf("[CensusID=%d] PublicKey %x of CensusID=%d"+
+					" already in the merge, skipping it", targetID,
+					pubKComp[:], sourceID)
+				nCollisions++
+				continue
+			}
+			weights[pubKComp] = sourceKeys[i].Weight
+			pubKs = append(pubKs, sourceKeys[i].PublicKey)
+			pubKsWeights = append(pubKsWeights, sourceKeys[i].Weight)
+		}
+	}
+	if nCollisions != 0 {
+		log.Infof("[CensusID=%d] merge of Censuses %v: %d duplicated"+
+			" PublicKeys skipped", targetID, sourceIDs, nCollisions)
+	}
+
+	if len(pubKs) == 0 {
+		return nil
+	}
+	if err := cb.AddPublicKeys(targetID, pubKs, pubKsWeights); err != nil {
+		return err
 	}
+	log.Debugf("[CensusID=%d] merged Censuses %v", targetID, sourceIDs)
+	return nil
+}
+
+// CopyCensus creates a new open Census, with its own sub-db, containing all
+// the PublicKeys of the Census of the given sourceID, which can be open or
+// closed, and returns the censusID of the new Census. The PublicKeys keep
+// their indexes, so closing the new Census without adding more PublicKeys
+// results in the same CensusRoot than the source Census. The new Census has
+// the MerkleTree parameters (MaxLevels and HashFunction) and the KeyType of
+// the source Census, and if the source Census is open and has SortKeys, the
+// new Census also has SortKeys. For a Census of KeyType
+// types.KeyTypeEthAddress, the Ethereum addresses are copied instead.
+func (cb *CensusBuilder) CopyCensus(sourceID types.CensusID) (types.CensusID, error) {
+	if err := cb.loadCensusIfNotYet(sourceID); err != nil {
+		return 0, err
+	}
+	defer cb.releaseCensus(sourceID)
+	source := cb.getCensus(sourceID)
+	if source.KeyType() == types.KeyTypeEthAddress {
+		return cb.copyAddresses(sourceID, source)
+	}
+	keys, err := source.PublicKeys()
+	if err != nil {
+		return 0, err
+	}
+	isClosed, err := source.IsClosed()
+	if err != nil {
+		return 0, err
+	}
+	// the PublicKeys of an open Census with SortKeys do not have their
+	// indexes assigned yet
+	sortKeys := source.SortKeys() && !isClosed
+
+	censusID, err := cb.NewCensusWithOptions(CensusOptions{
+		SortKeys:     sortKeys,
+		MaxLevels:    source.Parameters().MaxLevels,
+		HashFunction: source.HashFunction(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	chunkSize := cb.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = census.DefaultChunkSize
+	}
+	for from := 0; from < len(keys); from += chunkSize {
+		to := from + chunkSize
+		if to > len(keys) {
+			to = len(keys)
+		}
+		if sortKeys {
+			pubKs := make([]babyjub.PublicKey, to-from)
+			weights := make([]*big.Int, to-from)
+			for i := from; i < to; i++ {
+				pubKs[i-from] = keys[i].PublicKey
+				weights[i-from] = keys[i].Weight
+			}
+			err = cb.AddPublicKeys(censusID, pubKs, weights)
+		} else {
+			err = cb.AddPublicKeysAtIndices(censusID, keys[from:to])
+		}
+		if err != nil {
+			return 0, fmt.Errorf("can not copy CensusID=%d into CensusID=%d: %s",
+				sourceID, censusID, err)
+		}
+	}
+	log.Debugf("[CensusID=%d] copied from CensusID=%d, %d PublicKeys",
+		censusID, sourceID, len(keys))
+	return censusID, nil
+}
+
+// copyAddresses implements CopyCensus for a source Census of KeyType
+// types.KeyTypeEthAddress. The addresses can only be added with incremental
+// indexes, so they are added in index order, which keeps their indexes as long
+// as the indexes of the source Census are contiguous.
+func (cb *CensusBuilder) copyAddresses(sourceID types.CensusID,
+	source *census.Census) (types.CensusID, error) {
+	addrs, err := source.Addresses()
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(addrs); i++ {
+		if addrs[i].Index != uint64(i) {
+			return 0, fmt.Errorf("can not copy CensusID=%d, the address %s"+
+				" has index %d, expected %d", sourceID, addrs[i].Address.Hex(),
+				addrs[i].Index, i)
+		}
+	}
+
+	censusID, err := cb.NewCensusWithOptions(CensusOptions{
+		MaxLevels:    source.Parameters().MaxLevels,
+		HashFunction: source.HashFunction(),
+		KeyType:      types.KeyTypeEthAddress,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	chunkSize := cb.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = census.DefaultChunkSize
+	}
+	for from := 0; from < len(addrs); from += chunkSize {
+		to := from + chunkSize
+		if to > len(addrs) {
+			to = len(addrs)
+		}
+		chunkAddrs := make([]common.Address, to-from)
+		weights := make([]*big.Int, to-from)
+		for i := from; i < to; i++ {
+			chunkAddrs[i-from] = addrs[i].Address
+			weights[i-from] = addrs[i].Weight
+		}
+		if err := cb.AddAddresses(censusID, chunkAddrs, weights); err != nil {
+			return 0, fmt.Errorf("can not copy CensusID=%d into CensusID=%d: %s",
+				sourceID, censusID, err)
+		}
+	}
+	log.Debugf("[CensusID=%d] copied from CensusID=%d, %d addresses",
+		censusID, sourceID, len(addrs))
+	return censusID, nil
+}
+
+// loadOpenCensus loads the Census of the given censusID, returning
+// census.ErrCensusClosed if it is already closed. As with loadCensusIfNotYet,
+// if no error is returned, releaseCensus needs to be called once the Census
+// is not used anymore.
+func (cb *CensusBuilder) loadOpenCensus(censusID types.CensusID) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	isClosed, err := cb.getCensus(censusID).IsClosed()
+	if err != nil {
+		cb.releaseCensus(censusID)
+		return err
+	}
+	if isClosed {
+		cb.releaseCensus(censusID)
+		return fmt.Errorf("CensusID=%d: %w", censusID, census.ErrCensusClosed)
+	}
+	return nil
+}
+
+// IterateLeaves calls the given function for each PublicKey of the Census of
+// the given censusID, with its index, in index order, without loading all the
+// PublicKeys in memory. If the given function returns an error, the iteration
+// stops and the error is returned.
+func (cb *CensusBuilder) IterateLeaves(censusID types.CensusID,
+	fn func(index uint64, pubK babyjub.PublicKey) error) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).IterateLeaves(fn)
+}
+
+// IterateLeafKeys calls the given function for the key of each leaf of the
+// Census of the given censusID, with its index, in index order, until the
+// given function returns false (see census.Census.IterateLeafKeys)
+func (cb *CensusBuilder) IterateLeafKeys(censusID types.CensusID,
+	fn func(index uint64, key []byte) bool) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).IterateLeafKeys(fn)
+}
+
+// RootHistory returns the census.RootRecords of the Census of the given
+// censusID, one for each batch of keys added to it (see
+// census.Census.RootHistory)
+func (cb *CensusBuilder) RootHistory(censusID types.CensusID) ([]census.RootRecord, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).RootHistory()
 }
 
 // SetErrMsg stores the given error message into the CensusID db
-func (cb *CensusBuilder) SetErrMsg(censusID uint64, status string) error {
+func (cb *CensusBuilder) SetErrMsg(censusID types.CensusID, status string) error {
 	err := cb.loadCensusIfNotYet(censusID)
 	if err != nil {
 		return err
 	}
-	err = cb.censuses[censusID].SetErrMsg(status)
+	defer cb.releaseCensus(censusID)
+	err = cb.getCensus(censusID).SetErrMsg(status)
 	if err != nil {
 		return err
 	}
@@ -243,7 +1339,7 @@ func (cb *CensusBuilder) SetErrMsg(censusID uint64, status string) error {
 
 // GetProof returns the leaf Value and the MerkleProof compressed for the given
 // PublicKey in the given CensusID
-func (cb *CensusBuilder) GetProof(censusID uint64, pubK *babyjub.PublicKey) (
+func (cb *CensusBuilder) GetProof(censusID types.CensusID, pubK *babyjub.PublicKey) (
 	uint64, []byte, error) {
 	// TODO maybe add auth for this method, requiring a signature by the
 	// privK of the given PubK
@@ -251,9 +1347,143 @@ func (cb *CensusBuilder) GetProof(censusID uint64, pubK *babyjub.PublicKey) (
 	if err := cb.loadCensusIfNotYet(censusID); err != nil {
 		return 0, nil, err
 	}
-	index, proof, err := cb.censuses[censusID].GetProof(pubK)
+	defer cb.releaseCensus(censusID)
+	index, proof, err := cb.getCensus(censusID).GetProof(pubK)
 	if err != nil {
 		return 0, nil, err
 	}
 	return index, proof, nil
 }
+
+// GetCensusProof returns the CensusProof of the given PublicKey in the closed
+// Census of the given censusID, which contains the weight of the PublicKey
+// needed to verify it
+func (cb *CensusBuilder) GetCensusProof(censusID types.CensusID,
+	pubK *babyjub.PublicKey) (*types.CensusProof, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).GetCensusProof(pubK)
+}
+
+// GetCensusProofForHashFunction returns the CensusProof of the given PublicKey
+// as GetCensusProof, for a circuit that expects the given HashFunction.
+// Returns census.ErrHashFunctionMismatch if the MerkleTree of the Census uses
+// another HashFunction, as the CensusProof would not be valid for the
+// circuit.
+func (cb *CensusBuilder) GetCensusProofForHashFunction(censusID types.CensusID,
+	pubK *babyjub.PublicKey, hashFunc arbo.HashFunction) (*types.CensusProof, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	c := cb.getCensus(censusID)
+	if err := c.CheckHashFunction(hashFunc); err != nil {
+		return nil, fmt.Errorf("CensusID=%d: %w", censusID, err)
+	}
+	return c.GetCensusProof(pubK)
+}
+
+// ProofsPage returns a page of up to limit CensusProofs of the closed Census of
+// the given censusID, for the PublicKeys with an index equal or greater than
+// the given startIndex, so the CensusProofs of big Censuses can be
+// downloaded in chunks. Returns the startIndex of the next page, which is 0
+// when there are no more PublicKeys.
+func (cb *CensusBuilder) ProofsPage(censusID types.CensusID, startIndex uint64,
+	limit int) ([]types.CensusProof, uint64, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, 0, err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).ProofsPage(startIndex, limit)
+}
+
+// NextLeafIndex returns the index that the next PublicKey added to the open
+// Census of the given censusID with AddPublicKeys would get, taking into
+// account the indexes explicitly assigned with AddPublicKeysAtIndices
+func (cb *CensusBuilder) NextLeafIndex(censusID types.CensusID) (uint64, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return 0, err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).NextLeafIndex()
+}
+
+// HasPublicKey returns true if the given PublicKey is in the Census of the
+// given censusID
+func (cb *CensusBuilder) HasPublicKey(censusID types.CensusID, pubK *babyjub.PublicKey) (
+	bool, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return false, err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).HasPublicKey(pubK)
+}
+
+// GenerateProvisionalProof returns the CensusProof of the given PublicKey in
+// the Census of the given censusID, together with the CensusRoot against which
+// it has been computed, even if the Census is not closed yet. While the
+// Census is open, the returned CensusRoot is not final, and the CensusProof
+// is invalidated by any subsequent addition of PublicKeys. Once the Census is
+// closed, GetProof should be used to get the CensusProof against the final
+// CensusRoot.
+func (cb *CensusBuilder) GenerateProvisionalProof(censusID types.CensusID,
+	pubK babyjub.PublicKey) (types.CensusProof, []byte, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return types.CensusProof{}, nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	proof, root, err := cb.getCensus(censusID).GetProvisionalProof(&pubK)
+	if err != nil {
+		return types.CensusProof{}, nil, err
+	}
+	return *proof, root, nil
+}
+
+// VerifyMembershipProof checks the given CensusProof against the CensusRoot of
+// the Census for the given censusID, which needs to be closed. The Census
+// leafs are not used, only the data of the given CensusProof is verified.
+// Returns false if the proof is not valid, and an error only if the Census
+// can not be loaded.
+func (cb *CensusBuilder) VerifyMembershipProof(censusID types.CensusID,
+	proof types.CensusProof) (bool, error) {
+	root, err := cb.CensusRoot(censusID)
+	if err != nil {
+		return false, err
+	}
+	hashFunc, maxLevels, err := cb.treeParams(censusID)
+	if err != nil {
+		return false, err
+	}
+	return checkMembershipProof(censusID, hashFunc, maxLevels, root, &proof), nil
+}
+
+// treeParams returns the HashFunction and the MaxLevels of the MerkleTree of
+// the Census for the given censusID
+func (cb *CensusBuilder) treeParams(censusID types.CensusID) (arbo.HashFunction,
+	int, error) {
+	params, err := cb.Parameters(censusID)
+	if err != nil {
+		return nil, 0, err
+	}
+	hashFunc, err := types.HashFunctionFromType(params.HashFunction)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hashFunc, params.MaxLevels, nil
+}
+
+// checkMembershipProof returns true if the given CensusProof is valid for the
+// given CensusRoot of a MerkleTree with the given HashFunction and MaxLevels
+func checkMembershipProof(censusID types.CensusID, hashFunc arbo.HashFunction,
+	maxLevels int, root []byte, proof *types.CensusProof) bool {
+	v, err := census.CheckCensusProofWithTreeParams(hashFunc, maxLevels, root, proof)
+	if err != nil {
+		// the proof is not well formed
+		log.Debugf("[CensusID=%d] VerifyMembershipProof error: %s",
+			censusID, err)
+		return false
+	}
+	return v
+}
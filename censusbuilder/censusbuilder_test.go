@@ -1,11 +1,21 @@
 package censusbuilder
 
 import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aragon/ovote-node/census"
 	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
 	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
 	"github.com/vocdoni/arbo"
 	"go.vocdoni.io/dvote/db"
 	"go.vocdoni.io/dvote/db/pebbledb"
@@ -33,14 +43,14 @@ func TestNewCensus(t *testing.T) {
 	err = cb.CloseCensus(censusID1)
 	c.Assert(err, qt.IsNil)
 
-	c.Assert(censusID1, qt.Equals, uint64(0))
+	c.Assert(censusID1, qt.Equals, types.CensusID(0))
 
 	_, err = cb.CensusRoot(censusID1)
 	c.Assert(err, qt.IsNil)
 
 	censusID2, err := cb.NewCensus()
 	c.Assert(err, qt.IsNil)
-	c.Assert(censusID1, qt.Equals, uint64(0))
+	c.Assert(censusID1, qt.Equals, types.CensusID(0))
 
 	_, err = cb.CensusRoot(censusID2)
 	c.Assert(err.Error(), qt.Equals, "Can not get the CensusRoot, Census not closed yet")
@@ -87,6 +97,12 @@ func TestAddPublicKeys(t *testing.T) {
 	// check that both roots are equal
 	c.Assert(root2, qt.DeepEquals, root1)
 
+	// adding keys already in the Census reports them as already present
+	err = cb.AddPublicKeys(censusID2, keys.PublicKeys[:2], keys.Weights[:2])
+	c.Assert(err, qt.ErrorMatches, "CensusBuilder.AddPublicKeys error: 2 invalid"+
+		" keys \\(2 already present\\), invalid msg for key 0 \\(already"+
+		" present\\): PublicKey already added")
+
 	// create new pubKs
 	keys2 := test.GenUserKeys(nKeys)
 	err = cb.AddPublicKeys(censusID2, keys2.PublicKeys, keys2.Weights)
@@ -159,6 +175,12 @@ func TestCensusInfo(t *testing.T) {
 	c.Assert(ci.Size, qt.Equals, uint64(100))
 	c.Assert(ci.Closed, qt.IsFalse)
 	c.Assert(ci.Root, qt.DeepEquals, emptyRoot)
+	c.Assert(ci.State, qt.Equals, census.StateBuilding)
+	c.Assert(ci.Stats.NLeafs, qt.Equals, uint64(100))
+	// the indexes 0..99 use the 7 first levels of the MerkleTree
+	c.Assert(ci.Stats.Depth, qt.Equals, 7)
+	c.Assert(ci.Stats.MaxLevels, qt.Equals, census.DefaultMaxLevels)
+	c.Assert(ci.Stats.DiskSize > 0, qt.IsTrue)
 
 	err = cb.CloseCensus(censusID)
 	c.Assert(err, qt.IsNil)
@@ -173,4 +195,1375 @@ func TestCensusInfo(t *testing.T) {
 	c.Assert(ci.Size, qt.Equals, uint64(100))
 	c.Assert(ci.Closed, qt.IsTrue)
 	c.Assert(ci.Root, qt.DeepEquals, root)
+	c.Assert(ci.State, qt.Equals, census.StateClosed)
+
+	typedRoot, err := cb.CensusRootTyped(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(typedRoot.Bytes(), qt.DeepEquals, root)
+
+	err = cb.SetState(censusID, census.StatePublished)
+	c.Assert(err, qt.IsNil)
+	ci, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.State, qt.Equals, census.StatePublished)
+	err = cb.SetState(censusID, census.StateBuilding)
+	c.Assert(err, qt.ErrorMatches, "invalid Census state transition.*")
+}
+
+func TestDigest(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 100
+	// generate the publicKeys
+	keys := test.GenUserKeys(nKeys)
+
+	// create two CensusBuilders, emulating two different nodes
+	cb1, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	cb2, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID1, err := cb1.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb1.AddPublicKeys(censusID1, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	// expect error when the census is not closed yet
+	_, err = cb1.Digest(censusID1)
+	c.Assert(err.Error(), qt.Equals, "Can not get the Census Digest, Census not closed yet")
+
+	err = cb1.CloseCensus(censusID1)
+	c.Assert(err, qt.IsNil)
+	digest1, err := cb1.Digest(censusID1)
+	c.Assert(err, qt.IsNil)
+
+	// add the same keys in two batches in the 2nd node
+	censusID2, err := cb2.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb2.AddPublicKeys(censusID2, keys.PublicKeys[:nKeys/2], keys.Weights[:nKeys/2])
+	c.Assert(err, qt.IsNil)
+	err = cb2.AddPublicKeys(censusID2, keys.PublicKeys[nKeys/2:], keys.Weights[nKeys/2:])
+	c.Assert(err, qt.IsNil)
+	err = cb2.CloseCensus(censusID2)
+	c.Assert(err, qt.IsNil)
+	digest2, err := cb2.Digest(censusID2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(digest2, qt.DeepEquals, digest1)
+
+	// expect the cached digest to be returned
+	digest2, err = cb2.Digest(censusID2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(digest2, qt.DeepEquals, digest1)
+
+	// a census with different keys, expect a different digest
+	keys2 := test.GenUserKeys(nKeys)
+	censusID3, err := cb2.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb2.AddPublicKeys(censusID3, keys2.PublicKeys, keys2.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb2.CloseCensus(censusID3)
+	c.Assert(err, qt.IsNil)
+	digest3, err := cb2.Digest(censusID3)
+	c.Assert(err, qt.IsNil)
+	c.Assert(digest3, qt.Not(qt.DeepEquals), digest1)
+}
+
+func TestChunkSizeOption(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 25
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		ChunkSize:  10,
+	})
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	ci, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(nKeys))
+}
+
+func TestVerifyMembershipProof(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	// expect error when the census is not closed
+	_, err = cb.VerifyMembershipProof(censusID, types.CensusProof{})
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	// expect error when the census does not exist
+	_, err = cb.VerifyMembershipProof(censusID+1, types.CensusProof{})
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	for i := 0; i < nKeys; i++ {
+		index, merkleProof, err := cb.GetProof(censusID, &keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		proof := types.CensusProof{
+			Index:       index,
+			PublicKey:   &keys.PublicKeys[i],
+			Weight:      keys.Weights[i],
+			MerkleProof: merkleProof,
+		}
+		v, err := cb.VerifyMembershipProof(censusID, proof)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+
+		// use a wrong index
+		proof.Index = index + 1
+		v, err = cb.VerifyMembershipProof(censusID, proof)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsFalse)
+
+		// use a malformed MerkleProof
+		proof.Index = index
+		proof.MerkleProof = []byte{1, 2, 3}
+		v, err = cb.VerifyMembershipProof(censusID, proof)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsFalse)
+	}
+}
+
+func TestNewCensusWithTreeParams(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensusWithOptions(CensusOptions{MaxLevels: 4,
+		HashFunction: types.HashFunctionMiMC7})
+	c.Assert(err, qt.IsNil)
+	params, err := cb.Parameters(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(params.MaxLevels, qt.Equals, 4)
+	c.Assert(params.MaxNLeafs, qt.Equals, uint64(16))
+	c.Assert(params.HashFunction, qt.Equals, "mimc7")
+
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:7], keys.Weights[:7])
+	c.Assert(err, qt.ErrorMatches, census.ErrMaxNLeafsReached.Error()+".*")
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	var proofs []types.CensusProof
+	for i := 0; i < nKeys; i++ {
+		index, merkleProof, err := cb.GetProof(censusID, &keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		proofs = append(proofs, types.CensusProof{
+			Index:       index,
+			PublicKey:   &keys.PublicKeys[i],
+			Weight:      keys.Weights[i],
+			MerkleProof: merkleProof,
+		})
+	}
+	valid, err := cb.VerifyMembershipProofs(censusID, proofs)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(valid[i], qt.IsTrue)
+	}
+
+	// the parameters of the Census are kept when loading it again
+	c.Assert(cb.Close(), qt.IsNil)
+	cb, err = New(cb.db, cb.subDBsPath)
+	c.Assert(err, qt.IsNil)
+	v, err := cb.VerifyMembershipProof(censusID, proofs[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.IsTrue)
+
+	// the CensusProofs are refused for a circuit with another HashFunction
+	cp, err := cb.GetCensusProofForHashFunction(censusID, &keys.PublicKeys[0],
+		types.HashFunctionMiMC7)
+	c.Assert(err, qt.IsNil)
+	c.Assert(cp.MerkleProof, qt.DeepEquals, proofs[0].MerkleProof)
+	_, err = cb.GetCensusProofForHashFunction(censusID, &keys.PublicKeys[0],
+		arbo.HashFunctionPoseidon)
+	c.Assert(err, qt.ErrorMatches, ".*"+census.ErrHashFunctionMismatch.Error()+".*")
+
+	_, err = cb.NewCensusWithOptions(CensusOptions{MaxLevels: 65})
+	c.Assert(err, qt.ErrorMatches, "invalid MaxLevels.*")
+	c.Assert(cb.Close(), qt.IsNil)
+}
+
+func TestArchiveCensus(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	// archive the census without closing it first
+	archivePath := c.TempDir()
+	err = cb.ArchiveCensus(censusID, archivePath)
+	c.Assert(err, qt.IsNil)
+
+	ci, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Archived, qt.IsTrue)
+	c.Assert(ci.Closed, qt.IsTrue)
+	c.Assert(ci.Size, qt.Equals, uint64(nKeys))
+	root := ci.Root
+
+	// expect the archived census to not be usable
+	_, err = cb.CensusRoot(censusID)
+	c.Assert(err, qt.Equals, ErrCensusArchived)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.Equals, ErrCensusArchived)
+
+	// restore the census
+	err = cb.RestoreCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	err = cb.RestoreCensus(censusID)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	ci, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Archived, qt.IsFalse)
+	c.Assert(ci.Closed, qt.IsTrue)
+	c.Assert(ci.Size, qt.Equals, uint64(nKeys))
+	c.Assert(ci.Root, qt.DeepEquals, root)
+
+	index, proof, err := cb.GetProof(censusID, &keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	v, err := census.CheckProof(root, proof, index, &keys.PublicKeys[0], keys.Weights[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.IsTrue)
+}
+
+func TestArchiveCensusInUse(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(4)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	// while another caller is using the Census, it can not be archived
+	// nor deleted
+	err = cb.loadCensusIfNotYet(censusID)
+	c.Assert(err, qt.IsNil)
+	err = cb.ArchiveCensus(censusID, c.TempDir())
+	c.Assert(err, qt.ErrorMatches, ErrCensusInUse.Error()+".*")
+	err = cb.DeleteCensus(censusID)
+	c.Assert(err, qt.ErrorMatches, ErrCensusInUse.Error()+".*")
+	cb.releaseCensus(censusID)
+
+	// while the Census is being archived, it can not be loaded
+	err = cb.detachCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	_, err = cb.IsClosed(censusID)
+	c.Assert(err, qt.ErrorMatches, ErrCensusInUse.Error()+".*")
+	cb.detachDone(censusID)
+	// the Census has been closed by the ArchiveCensus that failed
+	isClosed, err := cb.IsClosed(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(isClosed, qt.IsTrue)
+
+	err = cb.ArchiveCensus(censusID, c.TempDir())
+	c.Assert(err, qt.IsNil)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	c := qt.New(t)
+
+	nCensuses := 8
+	nKeys := 10
+	keys := test.GenUserKeys(nCensuses * nKeys)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	var wg sync.WaitGroup
+	censusIDs := make([]types.CensusID, nCensuses)
+	errs := make(chan error, nCensuses)
+	for i := 0; i < nCensuses; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			censusID, err := cb.NewCensus()
+			if err != nil {
+				errs <- err
+				return
+			}
+			censusIDs[i] = censusID
+			from, to := i*nKeys, (i+1)*nKeys
+			// add the keys in two batches, and close the Census
+			// twice, concurrently
+			var wgCensus sync.WaitGroup
+			for _, batch := range [][2]int{{from, from + nKeys/2}, {from + nKeys/2, to}} {
+				wgCensus.Add(1)
+				go func(b [2]int) {
+					defer wgCensus.Done()
+					if err := cb.AddPublicKeys(censusID, keys.PublicKeys[b[0]:b[1]],
+						keys.Weights[b[0]:b[1]]); err != nil {
+						errs <- err
+					}
+				}(batch)
+			}
+			wgCensus.Wait()
+			for j := 0; j < 2; j++ {
+				wgCensus.Add(1)
+				go func() {
+					defer wgCensus.Done()
+					if err := cb.CloseCensus(censusID); err != nil {
+						errs <- err
+					}
+				}()
+			}
+			wgCensus.Wait()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		c.Assert(err, qt.IsNil)
+	}
+
+	// each Census got a different censusID, and contains its keys
+	seen := make(map[types.CensusID]bool)
+	for i := 0; i < nCensuses; i++ {
+		c.Assert(seen[censusIDs[i]], qt.IsFalse)
+		seen[censusIDs[i]] = true
+		ci, err := cb.CensusInfo(censusIDs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(ci.Size, qt.Equals, uint64(nKeys))
+		c.Assert(ci.Closed, qt.IsTrue)
+		ok, err := cb.HasPublicKey(censusIDs[i], &keys.PublicKeys[i*nKeys])
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+	}
+	rTx := cb.db.ReadTx()
+	nextCensusID, err := cb.getNextCensusID(rTx)
+	rTx.Discard()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextCensusID, qt.Equals, types.CensusID(nCensuses))
+}
+
+func TestParameters(t *testing.T) {
+	c := qt.New(t)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+
+	expected := census.Parameters{
+		Arity:        2,
+		HashFunction: "poseidon",
+		MaxLevels:    types.MaxLevels,
+		MaxNLeafs:    types.MaxNLeafs,
+	}
+	params, err := cb.Parameters(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(params, qt.DeepEquals, expected)
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	params, err = cb.Parameters(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(params, qt.DeepEquals, expected)
+
+	_, err = cb.Parameters(censusID + 1)
+	c.Assert(err, qt.Not(qt.IsNil))
+}
+
+func TestRecentCensuses(t *testing.T) {
+	c := qt.New(t)
+
+	subDBsPath := c.TempDir()
+	cb, err := New(newTestDB(c), subDBsPath)
+	c.Assert(err, qt.IsNil)
+
+	infos, err := cb.RecentCensuses(10)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(infos), qt.Equals, 0)
+
+	nCensuses := 5
+	for i := 0; i < nCensuses; i++ {
+		censusID, err := cb.NewCensus()
+		c.Assert(err, qt.IsNil)
+		keys := test.GenUserKeys(i + 1)
+		err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+		c.Assert(err, qt.IsNil)
+	}
+	err = cb.CloseCensus(3)
+	c.Assert(err, qt.IsNil)
+
+	infos, err = cb.RecentCensuses(3)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(infos), qt.Equals, 3)
+	for i := 0; i < len(infos); i++ {
+		c.Assert(infos[i].ID, qt.Equals, types.CensusID(nCensuses-1-i))
+		c.Assert(infos[i].Size, qt.Equals, uint64(nCensuses-i))
+		c.Assert(infos[i].Error, qt.Equals, "")
+	}
+	c.Assert(infos[0].Closed, qt.IsFalse)
+	c.Assert(infos[1].Closed, qt.IsTrue)
+
+	// remove the sub-db of a Census from disk (simulating a Census that can
+	// not be loaded), expect the error in its entry
+	delete(cb.censuses, 0)
+	err = os.RemoveAll(filepath.Join(subDBsPath, "0"))
+	c.Assert(err, qt.IsNil)
+	infos, err = cb.RecentCensuses(10)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(infos), qt.Equals, nCensuses)
+	c.Assert(infos[4].ID, qt.Equals, types.CensusID(0))
+	c.Assert(infos[4].Error, qt.Equals, "CensusID=0 does not exist")
+	c.Assert(infos[3].Error, qt.Equals, "")
+}
+
+func TestOnCensusClosed(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	var closedIDs []types.CensusID
+	var closedRoots [][]byte
+	cb.OnCensusClosed = func(censusID types.CensusID, root []byte) error {
+		closedIDs = append(closedIDs, censusID)
+		closedRoots = append(closedRoots, root)
+		return fmt.Errorf("hook error")
+	}
+
+	_, err = cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	// expect the hook error to not fail the close
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(closedIDs, qt.DeepEquals, []types.CensusID{censusID})
+	c.Assert(closedRoots, qt.DeepEquals, [][]byte{root})
+
+	// expect the hook to not be called when the close fails
+	err = cb.CloseCensus(censusID + 1)
+	c.Assert(err, qt.Not(qt.IsNil))
+	c.Assert(len(closedIDs), qt.Equals, 1)
+
+	// nor when the Census was already closed
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(closedIDs), qt.Equals, 1)
+}
+
+func TestMergeCensuses(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(30)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	newCensusWithKeys := func(from, to int) types.CensusID {
+		censusID, err := cb.NewCensus()
+		c.Assert(err, qt.IsNil)
+		err = cb.AddPublicKeys(censusID, keys.PublicKeys[from:to],
+			keys.Weights[from:to])
+		c.Assert(err, qt.IsNil)
+		return censusID
+	}
+
+	// the sources contain duplicated keys between them and with the target
+	targetID := newCensusWithKeys(0, 10)
+	source1 := newCensusWithKeys(5, 20)
+	source2 := newCensusWithKeys(15, 30)
+
+	err = cb.MergeCensuses(targetID, []types.CensusID{source1, source2})
+	c.Assert(err, qt.IsNil)
+
+	// expect each key to be only once in the target Census
+	mergedKeys, err := cb.censuses[targetID].PublicKeys()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(mergedKeys), qt.Equals, 30)
+	seen := make(map[babyjub.PublicKeyComp]bool)
+	for i := 0; i < len(mergedKeys); i++ {
+		c.Assert(mergedKeys[i].Index, qt.Equals, uint64(i))
+		seen[mergedKeys[i].PublicKey.Compress()] = true
+	}
+	c.Assert(len(seen), qt.Equals, 30)
+
+	// expect the sources to remain unchanged
+	size, err := cb.censuses[source1].Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(15))
+
+	// expect the merged keys to have a valid proof once closed
+	err = cb.CloseCensus(targetID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(targetID)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < len(keys.PublicKeys); i++ {
+		index, proof, err := cb.GetProof(targetID, &keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		v, err := census.CheckProof(root, proof, index, &keys.PublicKeys[i],
+			keys.Weights[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+	}
+
+	// expect error when the target or any source is closed
+	openID := newCensusWithKeys(0, 1)
+	err = cb.MergeCensuses(targetID, []types.CensusID{openID})
+	c.Assert(err, qt.ErrorMatches, "CensusID=0: Census closed.*")
+	err = cb.MergeCensuses(openID, []types.CensusID{source1, targetID})
+	c.Assert(err, qt.ErrorMatches, "CensusID=0: Census closed.*")
+	err = cb.MergeCensuses(openID, []types.CensusID{openID})
+	c.Assert(err, qt.ErrorMatches, "can not merge CensusID=3 into itself")
+
+	// expect error when the same key has different weights, and no key
+	// added
+	conflictID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(conflictID, keys.PublicKeys[:2],
+		[]*big.Int{big.NewInt(2), big.NewInt(3)})
+	c.Assert(err, qt.IsNil)
+	err = cb.MergeCensuses(openID, []types.CensusID{source2, conflictID})
+	c.Assert(err, qt.ErrorMatches, "PublicKey .* of CensusID=4 has weight 2,"+
+		" but it is already in the merge with weight 1")
+	size, err = cb.censuses[openID].Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(1))
+}
+
+func TestSortKeysOption(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 50
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	// add the same keys in different orders
+	var reversedPubKs []babyjub.PublicKey
+	for i := nKeys - 1; i >= 0; i-- {
+		reversedPubKs = append(reversedPubKs, keys.PublicKeys[i])
+	}
+	newCensusRoot := func(opts CensusOptions, pubKs []babyjub.PublicKey) []byte {
+		censusID, err := cb.NewCensusWithOptions(opts)
+		c.Assert(err, qt.IsNil)
+		// add the keys in two batches
+		err = cb.AddPublicKeys(censusID, pubKs[:nKeys/2], keys.Weights[:nKeys/2])
+		c.Assert(err, qt.IsNil)
+		err = cb.AddPublicKeys(censusID, pubKs[nKeys/2:], keys.Weights[nKeys/2:])
+		c.Assert(err, qt.IsNil)
+		err = cb.CloseCensus(censusID)
+		c.Assert(err, qt.IsNil)
+		root, err := cb.CensusRoot(censusID)
+		c.Assert(err, qt.IsNil)
+		return root
+	}
+
+	sortedOpts := CensusOptions{SortKeys: true}
+	c.Assert(newCensusRoot(sortedOpts, keys.PublicKeys), qt.DeepEquals,
+		newCensusRoot(sortedOpts, reversedPubKs))
+
+	// without the option, the roots depend on the order
+	c.Assert(newCensusRoot(CensusOptions{}, keys.PublicKeys), qt.Not(qt.DeepEquals),
+		newCensusRoot(CensusOptions{}, reversedPubKs))
+}
+
+func TestIterateLeaves(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 100
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	sum := uint64(0)
+	err = cb.IterateLeaves(censusID, func(index uint64, pubK babyjub.PublicKey) error {
+		c.Assert(pubK.Compress(), qt.Equals, keys.PublicKeys[index].Compress())
+		sum += index
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(sum, qt.Equals, uint64(nKeys*(nKeys-1)/2))
+
+	err = cb.IterateLeaves(1000, func(uint64, babyjub.PublicKey) error { return nil })
+	c.Assert(err, qt.ErrorMatches, "CensusID=1000 does not exist")
+
+	n := 0
+	err = cb.IterateLeafKeys(censusID, func(index uint64, key []byte) bool {
+		pubKComp := keys.PublicKeys[index].Compress()
+		c.Assert(key, qt.DeepEquals, pubKComp[:])
+		n++
+		return true
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, nKeys)
+}
+
+func TestRootHistory(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(20)
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:5], keys.Weights[:5])
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[5:], keys.Weights[5:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(cb.CloseCensus(censusID), qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+
+	history, err := cb.RootHistory(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(history, qt.HasLen, 2)
+	c.Assert(history[0].BatchSize, qt.Equals, 5)
+	c.Assert(history[1].BatchSize, qt.Equals, 15)
+	c.Assert(history[1].Size, qt.Equals, uint64(20))
+	// the last recorded root is the CensusRoot of the closed Census
+	c.Assert(history[1].Root, qt.DeepEquals, root)
+	c.Assert(cb.Close(), qt.IsNil)
+}
+
+func TestSetAnchor(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	database := newTestDB(c)
+	cb, err := New(database, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	txHash := []byte("0x1234")
+	// anchoring a not closed Census is not allowed
+	err = cb.SetAnchor(censusID, txHash, 1, false)
+	c.Assert(err, qt.Equals, census.ErrCensusNotClosed)
+
+	ci, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Anchor, qt.IsNil)
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	err = cb.SetAnchor(censusID, txHash, 1, false)
+	c.Assert(err, qt.IsNil)
+	ci, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Anchor, qt.DeepEquals, &census.Anchor{TxHash: txHash, ChainID: 1})
+
+	// a second anchor is rejected unless overwrite is set
+	txHash2 := []byte("0x5678")
+	err = cb.SetAnchor(censusID, txHash2, 5, false)
+	c.Assert(err, qt.Equals, census.ErrAnchorExists)
+	err = cb.SetAnchor(censusID, txHash2, 5, true)
+	c.Assert(err, qt.IsNil)
+
+	// reload the CensusBuilder, and check that the anchor is still there
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+	cb, err = New(database, cb.subDBsPath)
+	c.Assert(err, qt.IsNil)
+	ci, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Anchor, qt.DeepEquals, &census.Anchor{TxHash: txHash2, ChainID: 5})
+}
+
+func TestVotingDeadline(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	cb, err := NewWithOptions(Options{DB: newTestDB(c), SubDBsPath: c.TempDir(),
+		Now: func() time.Time { return now }})
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensusWithOptions(CensusOptions{
+		VotingDeadline: now.Add(time.Hour),
+	})
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+
+	ci, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.VotingDeadline.Equal(now.Add(time.Hour)), qt.IsTrue)
+	c.Assert(ci.VotingOpen, qt.IsTrue)
+	c.Assert(cb.CheckVotingOpenByRoot(root), qt.IsNil)
+	// unknown CensusRoots have no deadline
+	c.Assert(cb.CheckVotingOpenByRoot([]byte("unknown")), qt.IsNil)
+
+	now = now.Add(2 * time.Hour)
+	ci, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.VotingOpen, qt.IsFalse)
+	err = cb.CheckVotingOpenByRoot(root)
+	c.Assert(err, qt.ErrorMatches, census.ErrVotingClosed.Error()+".*")
+
+	// extend the deadline
+	err = cb.SetVotingDeadline(censusID, now.Add(time.Hour))
+	c.Assert(err, qt.IsNil)
+	c.Assert(cb.CheckVotingOpenByRoot(root), qt.IsNil)
+	c.Assert(cb.CheckVotingOpen(censusID), qt.IsNil)
+}
+
+func TestCopyCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(100)
+
+	database := newTestDB(c)
+	cb, err := New(database, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	sourceID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(sourceID, keys.PublicKeys[:50], keys.Weights[:50])
+	c.Assert(err, qt.IsNil)
+
+	// copy the open source Census
+	copyID, err := cb.CopyCensus(sourceID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(copyID, qt.Not(qt.Equals), sourceID)
+	ci, err := cb.CensusInfo(copyID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(50))
+	c.Assert(ci.Closed, qt.IsFalse)
+
+	// adding keys to the copy does not affect the source
+	err = cb.AddPublicKeys(copyID, keys.PublicKeys[50:], keys.Weights[50:])
+	c.Assert(err, qt.IsNil)
+	ci, err = cb.CensusInfo(sourceID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(50))
+	ci, err = cb.CensusInfo(copyID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(100))
+
+	// copy the closed source Census
+	err = cb.AddPublicKeys(sourceID, keys.PublicKeys[50:], keys.Weights[50:])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(sourceID)
+	c.Assert(err, qt.IsNil)
+	sourceRoot, err := cb.CensusRoot(sourceID)
+	c.Assert(err, qt.IsNil)
+
+	copyID, err = cb.CopyCensus(sourceID)
+	c.Assert(err, qt.IsNil)
+	ci, err = cb.CensusInfo(copyID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(100))
+	c.Assert(ci.Closed, qt.IsFalse)
+
+	// once closed without changes, the copy has the same root
+	err = cb.CloseCensus(copyID)
+	c.Assert(err, qt.IsNil)
+	copyRoot, err := cb.CensusRoot(copyID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(copyRoot, qt.DeepEquals, sourceRoot)
+
+	// copy an open Census with SortKeys
+	sortedID, err := cb.NewCensusWithOptions(CensusOptions{SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(sortedID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	copyID, err = cb.CopyCensus(sortedID)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(sortedID)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(copyID)
+	c.Assert(err, qt.IsNil)
+	sortedRoot, err := cb.CensusRoot(sortedID)
+	c.Assert(err, qt.IsNil)
+	copyRoot, err = cb.CensusRoot(copyID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(copyRoot, qt.DeepEquals, sortedRoot)
+}
+
+func TestCopyCensusTreeParams(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	for _, hashFunc := range []arbo.HashFunction{arbo.HashFunctionBlake2b,
+		types.HashFunctionMiMC7} {
+		sourceID, err := cb.NewCensusWithOptions(CensusOptions{MaxLevels: 4,
+			HashFunction: hashFunc})
+		c.Assert(err, qt.IsNil)
+		err = cb.AddPublicKeys(sourceID, keys.PublicKeys, keys.Weights)
+		c.Assert(err, qt.IsNil)
+		err = cb.CloseCensus(sourceID)
+		c.Assert(err, qt.IsNil)
+		sourceRoot, err := cb.CensusRoot(sourceID)
+		c.Assert(err, qt.IsNil)
+
+		copyID, err := cb.CopyCensus(sourceID)
+		c.Assert(err, qt.IsNil)
+		params, err := cb.Parameters(copyID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(params.MaxLevels, qt.Equals, 4)
+		c.Assert(params.HashFunction, qt.Equals, string(hashFunc.Type()))
+		err = cb.CloseCensus(copyID)
+		c.Assert(err, qt.IsNil)
+		copyRoot, err := cb.CensusRoot(copyID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(copyRoot, qt.DeepEquals, sourceRoot)
+
+		// the CensusProofs of the copy are valid for the circuit, and
+		// for the MerkleTree, of the source Census
+		cp, err := cb.GetCensusProofForHashFunction(copyID, &keys.PublicKeys[0],
+			hashFunc)
+		c.Assert(err, qt.IsNil)
+		v, err := cb.VerifyMembershipProof(sourceID, *cp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue, qt.Commentf("%s", hashFunc.Type()))
+	}
+	c.Assert(cb.Close(), qt.IsNil)
+}
+
+func TestGenerateProvisionalProof(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(20)
+
+	database := newTestDB(c)
+	cb, err := New(database, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:10], keys.Weights[:10])
+	c.Assert(err, qt.IsNil)
+
+	// the final proof can not be generated while the Census is open
+	_, _, err = cb.GetProof(censusID, &keys.PublicKeys[3])
+	c.Assert(err, qt.Equals, census.ErrCensusNotClosed)
+
+	proof, root, err := cb.GenerateProvisionalProof(censusID, keys.PublicKeys[3])
+	c.Assert(err, qt.IsNil)
+	c.Assert(proof.Index, qt.Equals, uint64(3))
+	c.Assert(proof.Weight.Cmp(keys.Weights[3]), qt.Equals, 0)
+	c.Assert(proof.Verify(root), qt.IsNil)
+
+	// a PublicKey not in the Census has no proof
+	_, _, err = cb.GenerateProvisionalProof(censusID, keys.PublicKeys[15])
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	// after adding more keys, the proof does not verify against the new root
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[10:], keys.Weights[10:])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	finalRoot, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(finalRoot, qt.Not(qt.DeepEquals), root)
+	c.Assert(proof.Verify(finalRoot), qt.Not(qt.IsNil))
+
+	// once closed, the provisional proof matches the final one
+	proof, root, err = cb.GenerateProvisionalProof(censusID, keys.PublicKeys[3])
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, finalRoot)
+	_, finalProof, err := cb.GetProof(censusID, &keys.PublicKeys[3])
+	c.Assert(err, qt.IsNil)
+	c.Assert([]byte(proof.MerkleProof), qt.DeepEquals, finalProof)
+}
+
+func TestNextLeafIndex(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	database := newTestDB(c)
+	cb, err := New(database, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	// dense layout
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	nextIndex, err := cb.NextLeafIndex(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextIndex, qt.Equals, uint64(0))
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:5], keys.Weights[:5])
+	c.Assert(err, qt.IsNil)
+	nextIndex, err = cb.NextLeafIndex(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextIndex, qt.Equals, uint64(5))
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	_, err = cb.NextLeafIndex(censusID)
+	c.Assert(err, qt.Equals, census.ErrCensusClosed)
+
+	// sparse layout, with the indexes 0, 1 and 3 explicitly assigned
+	censusID, err = cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeysAtIndices(censusID, []census.IndexedPublicKey{
+		{Index: 0, PublicKey: keys.PublicKeys[0]},
+		{Index: 1, PublicKey: keys.PublicKeys[1]},
+		{Index: 3, PublicKey: keys.PublicKeys[2]},
+	})
+	c.Assert(err, qt.IsNil)
+	nextIndex, err = cb.NextLeafIndex(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextIndex, qt.Equals, uint64(2))
+
+	// the next auto-inserted keys get the predicted indexes
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[3:4], keys.Weights[3:4])
+	c.Assert(err, qt.IsNil)
+	nextIndex, err = cb.NextLeafIndex(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextIndex, qt.Equals, uint64(4))
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[4:5], keys.Weights[4:5])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	index, _, err := cb.GetProof(censusID, &keys.PublicKeys[3])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(2))
+	index, _, err = cb.GetProof(censusID, &keys.PublicKeys[4])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(4))
+}
+
+func TestSeal(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	database := newTestDB(c)
+	cb, err := New(database, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:5], keys.Weights[:5])
+	c.Assert(err, qt.IsNil)
+
+	hash, err := cb.Seal(censusID)
+	c.Assert(err, qt.IsNil)
+
+	// additions after the Seal are rejected
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[5:], keys.Weights[5:])
+	c.Assert(err, qt.Equals, census.ErrCensusSealed)
+	info, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Size, qt.Equals, uint64(5))
+	c.Assert(info.KeySetHash, qt.DeepEquals, hash)
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	info, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Closed, qt.IsTrue)
+	c.Assert(info.KeySetHash, qt.DeepEquals, hash)
+	_, _, err = cb.GetProof(censusID, &keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+}
+
+func TestRecoverCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(8)
+
+	subDBsPath := c.TempDir()
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: subDBsPath,
+		KeyIndex:   true,
+	})
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:5], keys.Weights[:5])
+	c.Assert(err, qt.IsNil)
+	cp, err := cb.getCensus(censusID).GetCheckpoint()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[5:], keys.Weights[5:])
+	c.Assert(err, qt.IsNil)
+
+	// simulate a crash after the last keys have been committed, but
+	// before their Checkpoint, by restoring the previous Checkpoint
+	err = cb.getCensus(censusID).CloseDB()
+	c.Assert(err, qt.IsNil)
+	delete(cb.censuses, censusID)
+	database, err := pebbledb.New(db.Options{
+		Path: filepath.Join(subDBsPath, strconv.Itoa(int(censusID)))})
+	c.Assert(err, qt.IsNil)
+	b, err := json.Marshal(cp)
+	c.Assert(err, qt.IsNil)
+	wTx := database.WriteTx()
+	c.Assert(wTx.Set([]byte("checkpoint"), b), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	c.Assert(database.Close(), qt.IsNil)
+
+	err = cb.RecoverCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	info, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Size, qt.Equals, uint64(5))
+	censusIDs, err := cb.FindCensusesForKey(keys.PublicKeys[4])
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusIDs, qt.DeepEquals, []types.CensusID{censusID})
+	censusIDs, err = cb.FindCensusesForKey(keys.PublicKeys[5])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(censusIDs), qt.Equals, 0)
+
+	// the discarded keys can be added again
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[5:], keys.Weights[5:])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	info, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Size, qt.Equals, uint64(8))
+}
+
+func TestVerifyRoot(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	subDBsPath := c.TempDir()
+	cb, err := New(newTestDB(c), subDBsPath)
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	_, err = cb.VerifyRoot(censusID)
+	c.Assert(err, qt.ErrorMatches, "Census not closed yet")
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	ok, err := cb.VerifyRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+
+	// corrupt a leaf node of the MerkleTree in the Census sub-db
+	err = cb.getCensus(censusID).CloseDB()
+	c.Assert(err, qt.IsNil)
+	delete(cb.censuses, censusID)
+	database, err := pebbledb.New(db.Options{
+		Path: filepath.Join(subDBsPath, strconv.Itoa(int(censusID)))})
+	c.Assert(err, qt.IsNil)
+	tree, err := arbo.NewTree(arbo.Config{Database: database,
+		MaxLevels: types.MaxLevels, HashFunction: arbo.HashFunctionPoseidon})
+	c.Assert(err, qt.IsNil)
+	var leafK, leafV []byte
+	err = tree.IterateWithStop(nil, func(_ int, k, v []byte) bool {
+		if v[0] == arbo.PrefixValueLeaf && leafK == nil {
+			leafK = append([]byte{}, k...)
+			leafV = append([]byte{}, v...)
+		}
+		return false
+	})
+	c.Assert(err, qt.IsNil)
+	leafV[len(leafV)-1] ^= 1
+	wTx := database.WriteTx()
+	c.Assert(wTx.Set(leafK, leafV), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	c.Assert(database.Close(), qt.IsNil)
+
+	ok, err = cb.VerifyRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+
+	_, err = cb.VerifyRoot(42)
+	c.Assert(err, qt.ErrorMatches, "CensusID=42 does not exist")
+
+	// close the Census sub-dbs before the TempDir is removed
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+}
+
+func TestNewCensusSkipsExistingSubDB(t *testing.T) {
+	c := qt.New(t)
+
+	subDBsPath := c.TempDir()
+	cb, err := New(newTestDB(c), subDBsPath)
+	c.Assert(err, qt.IsNil)
+
+	// simulate a sub-db at the nextCensusID, which is not counted by the
+	// stored nextCensusID
+	existing := filepath.Join(subDBsPath, "0")
+	c.Assert(os.MkdirAll(existing, os.ModePerm), qt.IsNil)
+	marker := filepath.Join(existing, "marker")
+	c.Assert(os.WriteFile(marker, []byte("data"), 0600), qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusID, qt.Equals, types.CensusID(1))
+
+	// the existing sub-db has not been overwritten
+	b, err := os.ReadFile(marker)
+	c.Assert(err, qt.IsNil)
+	c.Assert(b, qt.DeepEquals, []byte("data"))
+
+	// the counter continues after the skipped censusID
+	censusID, err = cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusID, qt.Equals, types.CensusID(2))
+
+	// an archived census is not reused either, even if the counter is
+	// moved back
+	c.Assert(cb.ArchiveCensus(2, c.TempDir()), qt.IsNil)
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	c.Assert(cb.setNextCensusID(wTx, 2), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	censusID, err = cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusID, qt.Equals, types.CensusID(3))
+}
+
+func TestAddMembers(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 6
+	keys := test.GenUserKeys(nKeys)
+	members := make([]census.Member, nKeys)
+	for i := 0; i < nKeys; i++ {
+		members[i] = census.Member{PublicKey: keys.PublicKeys[i],
+			Weight: keys.Weights[i], Data: []byte{byte(i + 1)}}
+	}
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddMembers(censusID, members)
+	c.Assert(err, qt.IsNil)
+
+	// the Members with leaf data can not be merged
+	mergeID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.MergeCensuses(mergeID, []types.CensusID{censusID})
+	c.Assert(err, qt.ErrorMatches, ".*leaf data.*")
+
+	// the copy keeps the leaf data
+	copyID, err := cb.CopyCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	for _, id := range []types.CensusID{censusID, copyID} {
+		err = cb.CloseCensus(id)
+		c.Assert(err, qt.IsNil)
+		for i := 0; i < nKeys; i++ {
+			proof, _, err := cb.GenerateProvisionalProof(id,
+				keys.PublicKeys[i])
+			c.Assert(err, qt.IsNil)
+			c.Assert([]byte(proof.Data), qt.DeepEquals, members[i].Data)
+			v, err := cb.VerifyMembershipProof(id, proof)
+			c.Assert(err, qt.IsNil)
+			c.Assert(v, qt.IsTrue)
+
+			proof.Data = nil
+			v, err = cb.VerifyMembershipProof(id, proof)
+			c.Assert(err, qt.IsNil)
+			c.Assert(v, qt.IsFalse)
+		}
+	}
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	copyRoot, err := cb.CensusRoot(copyID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(copyRoot, qt.DeepEquals, root)
+}
+
+func TestCloseCensusIdempotent(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+	database := newTestDB(c)
+	subDBsPath := c.TempDir()
+	cb, err := New(database, subDBsPath)
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	closed, err := cb.IsClosed(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(closed, qt.IsFalse)
+	// a Census that does not exist
+	_, err = cb.IsClosed(censusID + 1)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	closed, err = cb.IsClosed(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(closed, qt.IsTrue)
+
+	// a second close is a no-op, keeping the same CensusRoot
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root2, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(root2, qt.DeepEquals, root)
+
+	// the closed flag is persisted in the Census db
+	c.Assert(cb.Close(), qt.IsNil)
+	cb, err = New(database, subDBsPath)
+	c.Assert(err, qt.IsNil)
+	closed, err = cb.IsClosed(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(closed, qt.IsTrue)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root2, err = cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(root2, qt.DeepEquals, root)
+}
+
+func TestProofsPage(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	// expect error when the census does not exist
+	_, _, err = cb.ProofsPage(0, 0, 5)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	var proofs []types.CensusProof
+	cursor := uint64(0)
+	for {
+		page, next, err := cb.ProofsPage(censusID, cursor, 3)
+		c.Assert(err, qt.IsNil)
+		proofs = append(proofs, page...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	c.Assert(len(proofs), qt.Equals, nKeys)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(proofs[i].Index, qt.Equals, uint64(i))
+		v, err := cb.VerifyMembershipProof(censusID, proofs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+	}
+}
+
+func TestAddPublicKeysWithResults(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(20)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		KeyIndex:   true,
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:5], keys.Weights[:5])
+	c.Assert(err, qt.IsNil)
+
+	// AddPublicKeys does not add any key of a batch with duplicates
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:10], keys.Weights[:10])
+	c.Assert(err, qt.Not(qt.IsNil))
+	ci, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(5))
+
+	results, err := cb.AddPublicKeysWithResults(censusID, keys.PublicKeys[:10],
+		keys.Weights[:10])
+	c.Assert(err, qt.IsNil)
+	c.Assert(results, qt.HasLen, 10)
+	for i := 0; i < 10; i++ {
+		if i < 5 {
+			c.Assert(results[i].Status, qt.Equals, census.KeyDuplicate)
+			c.Assert(results[i].Reason, qt.Equals, census.InvalidAlreadyPresent)
+		} else {
+			c.Assert(results[i].Status, qt.Equals, census.KeyAdded)
+		}
+	}
+	ci, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(10))
+
+	// the added keys are indexed in the KeyIndex
+	censusIDs, err := cb.FindCensusesForKey(keys.PublicKeys[9])
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusIDs, qt.DeepEquals, []types.CensusID{censusID})
+	c.Assert(cb.Close(), qt.IsNil)
 }
@@ -0,0 +1,166 @@
+package censusbuilder
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/log"
+)
+
+// DefaultCensusQueueSize defines the default maximum number of batches of
+// PublicKeys waiting in the queue of each Census used by
+// AddPublicKeysAndStoreError
+const DefaultCensusQueueSize = 8
+
+// censusQueue contains the batches of PublicKeys waiting to be added to a
+// Census by AddPublicKeysAndStoreError, which are processed in order by a
+// single goroutine
+type censusQueue struct {
+	batches chan addPublicKeysJob
+	// pending is the number of batches enqueued or being sent to the
+	// queue that have not been processed yet, guarded by
+	// CensusBuilder.queuesMu
+	pending int
+	// drained is signaled when pending reaches 0
+	drained *sync.Cond
+}
+
+// AddPublicKeysAndStoreError adds the given PublicKeys to the Census of the
+// given censusID and, if there is an error, stores it as the Census ErrMsg.
+// This method is designed to be called from goroutines: the batches of each
+// Census are enqueued in a per-Census queue and added in order by a single
+// goroutine, so concurrent calls for the same Census do not contend, while
+// the batches of different Censuses are added in parallel. When the queue of
+// the Census is full (see Options.CensusQueueSize), the call blocks until
+// there is room, bounding the memory used by the waiting batches.
+// WaitForCensus can be used to wait until all the batches have been added, and
+// the census.Progress returned in the CensusInfo can be polled to follow the
+// import. EnqueueAddPublicKeys can be used instead to get the status of each
+// batch.
+func (cb *CensusBuilder) AddPublicKeysAndStoreError(censusID types.CensusID,
+	pubKs []babyjub.PublicKey, weights []*big.Int) {
+	cb.queuesMu.Lock()
+	if cb.queuesClosed {
+		cb.queuesMu.Unlock()
+		log.Errorf("[CensusID=%d] can not add %d PublicKeys: %s", censusID,
+			len(pubKs), ErrCensusBuilderClosed)
+		return
+	}
+	q, ok := cb.queues[censusID]
+	if !ok {
+		q = &censusQueue{
+			batches: make(chan addPublicKeysJob, cb.censusQueueSize),
+			drained: sync.NewCond(&cb.queuesMu),
+		}
+		cb.queues[censusID] = q
+		go cb.processCensusQueue(censusID, q)
+	}
+	q.pending++
+	cb.queuesMu.Unlock()
+
+	// the Progress is updated before sending the batch, so the batch is
+	// counted as pending before it is processed
+	cb.updateProgress(censusID, func(p *census.Progress) {
+		if p.Pending == 0 {
+			// a new import starts once the previous one has
+			// finished
+			*p = census.Progress{}
+		}
+		p.Pending += uint64(len(pubKs))
+	})
+
+	// the queue is not closed while there are pending batches, so it is
+	// safe to send without holding the lock
+	q.batches <- addPublicKeysJob{censusID: censusID, pubKs: pubKs, weights: weights}
+}
+
+// processCensusQueue adds the batches of the given queue in order. Once all
+// the batches have been processed, the queue is removed and the goroutine
+// ends, the next call to AddPublicKeysAndStoreError creates a new queue.
+func (cb *CensusBuilder) processCensusQueue(censusID types.CensusID, q *censusQueue) {
+	for batch := range q.batches {
+		cb.addPublicKeysAndStoreError(batch.censusID, batch.pubKs, batch.weights)
+
+		cb.queuesMu.Lock()
+		q.pending--
+		if q.pending == 0 {
+			delete(cb.queues, censusID)
+			close(q.batches)
+			q.drained.Broadcast()
+			cb.queuesMu.Unlock()
+			return
+		}
+		cb.queuesMu.Unlock()
+	}
+}
+
+func (cb *CensusBuilder) addPublicKeysAndStoreError(censusID types.CensusID,
+	pubKs []babyjub.PublicKey, weights []*big.Int) {
+	err := cb.AddPublicKeys(censusID, pubKs, weights)
+	if err != nil {
+		log.Debugf("[CensusID=%d] error: %s", censusID, err)
+		if err2 := cb.SetErrMsg(censusID, err.Error()); err2 != nil {
+			log.Errorf("Error while trying to store CensusID:%d status: %s. Error: %s",
+				censusID, err, err2)
+		}
+	}
+	n := uint64(len(pubKs))
+	cb.updateProgress(censusID, func(p *census.Progress) {
+		if p.Pending >= n {
+			p.Pending -= n
+		} else {
+			p.Pending = 0
+		}
+		if err != nil {
+			p.Failed += n
+			p.LastError = err.Error()
+			return
+		}
+		p.Added += n
+	})
+}
+
+// updateProgress updates the census.Progress of the Census of the given
+// censusID with the given function. As the Progress is informative, the
+// errors are logged and not returned.
+func (cb *CensusBuilder) updateProgress(censusID types.CensusID, fn func(p *census.Progress)) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		log.Warnf("[CensusID=%d] can not update the Progress: %s", censusID, err)
+		return
+	}
+	defer cb.releaseCensus(censusID)
+	if err := cb.getCensus(censusID).UpdateProgress(fn); err != nil {
+		log.Warnf("[CensusID=%d] can not update the Progress: %s", censusID, err)
+	}
+}
+
+// WaitForCensus blocks until all the batches of PublicKeys enqueued with
+// AddPublicKeysAndStoreError for the Census of the given censusID have been
+// processed
+func (cb *CensusBuilder) WaitForCensus(censusID types.CensusID) {
+	cb.queuesMu.Lock()
+	defer cb.queuesMu.Unlock()
+	q, ok := cb.queues[censusID]
+	if !ok {
+		return
+	}
+	for q.pending > 0 {
+		q.drained.Wait()
+	}
+}
+
+// closeCensusQueues stops accepting batches in AddPublicKeysAndStoreError,
+// and waits until the enqueued ones have been processed
+func (cb *CensusBuilder) closeCensusQueues() {
+	cb.queuesMu.Lock()
+	defer cb.queuesMu.Unlock()
+	cb.queuesClosed = true
+	for _, q := range cb.queues {
+		for q.pending > 0 {
+			q.drained.Wait()
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package censusbuilder
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestAddPublicKeysAndStoreErrorConcurrent(t *testing.T) {
+	c := qt.New(t)
+
+	nCensuses := 3
+	nCalls := 20
+	batchSize := 2
+	keys := test.GenUserKeys(nCalls * batchSize)
+
+	cb, err := NewWithOptions(Options{
+		DB:              newTestDB(c),
+		SubDBsPath:      c.TempDir(),
+		CensusQueueSize: 2,
+	})
+	c.Assert(err, qt.IsNil)
+
+	var censusIDs []types.CensusID
+	for i := 0; i < nCensuses; i++ {
+		censusID, err := cb.NewCensus()
+		c.Assert(err, qt.IsNil)
+		censusIDs = append(censusIDs, censusID)
+	}
+
+	// concurrent calls for all the Censuses, each Census gets all the keys
+	var wg sync.WaitGroup
+	for _, censusID := range censusIDs {
+		for j := 0; j < nCalls; j++ {
+			wg.Add(1)
+			go func(censusID types.CensusID, from int) {
+				defer wg.Done()
+				to := from + batchSize
+				cb.AddPublicKeysAndStoreError(censusID,
+					keys.PublicKeys[from:to], keys.Weights[from:to])
+			}(censusID, j*batchSize)
+		}
+	}
+	wg.Wait()
+
+	for _, censusID := range censusIDs {
+		cb.WaitForCensus(censusID)
+		info, err := cb.CensusInfo(censusID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(info.Size, qt.Equals, uint64(nCalls*batchSize))
+		c.Assert(info.ErrMsg, qt.Equals, "")
+	}
+	// a Census without queue returns directly
+	cb.WaitForCensus(42)
+
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+}
+
+func TestAddPublicKeysAndStoreErrorOrder(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := NewWithOptions(Options{
+		DB:              newTestDB(c),
+		SubDBsPath:      c.TempDir(),
+		CensusQueueSize: 1,
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+
+	// the batches of the same caller are added in order, so the keys get
+	// the indexes in the order of the calls
+	for i := 0; i < nKeys; i++ {
+		cb.AddPublicKeysAndStoreError(censusID, keys.PublicKeys[i:i+1],
+			keys.Weights[i:i+1])
+	}
+	// a duplicated key, which error is stored
+	cb.AddPublicKeysAndStoreError(censusID, keys.PublicKeys[:1], keys.Weights[:1])
+	cb.WaitForCensus(censusID)
+
+	err = cb.IterateLeaves(censusID, func(index uint64, pubK babyjub.PublicKey) error {
+		c.Assert(pubK.Compress(), qt.Equals,
+			keys.PublicKeys[index].Compress())
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+	info, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Size, qt.Equals, uint64(nKeys))
+	c.Assert(info.ErrMsg, qt.Not(qt.Equals), "")
+
+	// after Close, the batches are not added
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+	cb.AddPublicKeysAndStoreError(censusID, keys.PublicKeys[:1], keys.Weights[:1])
+	cb.WaitForCensus(censusID)
+}
+
+func TestAddPublicKeysAndStoreErrorProgress(t *testing.T) {
+	c := qt.New(t)
+
+	nBatches := 5
+	batchSize := 3
+	keys := test.GenUserKeys(nBatches * batchSize)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+
+	for i := 0; i < nBatches; i++ {
+		from, to := i*batchSize, (i+1)*batchSize
+		cb.AddPublicKeysAndStoreError(censusID, keys.PublicKeys[from:to],
+			keys.Weights[from:to])
+	}
+	cb.WaitForCensus(censusID)
+	info, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Progress, qt.Not(qt.IsNil))
+	c.Assert(info.Progress.Added, qt.Equals, uint64(nBatches*batchSize))
+	c.Assert(info.Progress.Pending, qt.Equals, uint64(0))
+	c.Assert(info.Progress.Failed, qt.Equals, uint64(0))
+	c.Assert(info.Progress.Percentage, qt.Equals, float64(100))
+
+	// a new import resets the Progress, and the failed batch is counted
+	cb.AddPublicKeysAndStoreError(censusID, keys.PublicKeys[:batchSize],
+		keys.Weights[:batchSize])
+	cb.WaitForCensus(censusID)
+	info, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Progress.Added, qt.Equals, uint64(0))
+	c.Assert(info.Progress.Pending, qt.Equals, uint64(0))
+	c.Assert(info.Progress.Failed, qt.Equals, uint64(batchSize))
+	c.Assert(info.Progress.LastError, qt.Equals, info.ErrMsg)
+	c.Assert(info.Progress.Percentage, qt.Equals, float64(100))
+
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+}
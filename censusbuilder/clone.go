@@ -0,0 +1,99 @@
+package censusbuilder
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// CloneCensus creates a new Census with the same PublicKeys and MerkleTree
+// than the Census of the given sourceID, copying its sub-db instead of adding
+// again the PublicKeys as CopyCensus does, and returns the censusID of the new
+// Census. The new Census is open or closed as the source Census, and its data
+// of the process where the source Census was used is reset (see
+// census.Census.ResetForClone). The label is not cloned. Returns
+// ErrCensusInUse if the source Census is being used concurrently, as its
+// sub-db needs to be closed while it is copied.
+func (cb *CensusBuilder) CloneCensus(sourceID types.CensusID) (types.CensusID, error) {
+	cb.lifecycleMu.Lock()
+	defer cb.lifecycleMu.Unlock()
+	if cb.isEphemeral(sourceID) {
+		return 0, ErrCensusEphemeral
+	}
+	if err := cb.loadCensusIfNotYet(sourceID); err != nil {
+		return 0, err
+	}
+	defer cb.releaseCensus(sourceID)
+
+	if err := cb.detachCensus(sourceID); err != nil {
+		return 0, err
+	}
+	src := filepath.Join(cb.subDBsPath, strconv.Itoa(int(sourceID)))
+	censusID, err := cb.newCensusID(func(censusID types.CensusID) error {
+		dst := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
+		if err := copyDir(src, dst); err != nil {
+			// the error is omitted, as the copy error is returned
+			os.RemoveAll(dst) //nolint:errcheck
+			return err
+		}
+		return nil
+	})
+	cb.detachDone(sourceID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return 0, err
+	}
+	defer cb.releaseCensus(censusID)
+	c := cb.getCensus(censusID)
+	if err := c.ResetForClone(); err != nil {
+		return 0, err
+	}
+	if err := cb.indexCensusKeys(censusID); err != nil {
+		return 0, err
+	}
+	log.Debugf("[CensusID=%d] cloned from CensusID=%d", censusID, sourceID)
+	return censusID, nil
+}
+
+// copyDir copies the files of the src directory into the dst directory, which
+// is created
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+		return copyFile(path, target, info.Mode())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck
+	out, err := os.OpenFile(filepath.Clean(dst), os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close() //nolint:errcheck
+		return err
+	}
+	return out.Close()
+}
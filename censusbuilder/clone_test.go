@@ -0,0 +1,100 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCloneCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(100)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		KeyIndex:   true,
+	})
+	c.Assert(err, qt.IsNil)
+
+	sourceID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(sourceID, keys.PublicKeys[:50], keys.Weights[:50])
+	c.Assert(err, qt.IsNil)
+
+	// clone the open source Census
+	cloneID, err := cb.CloneCensus(sourceID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(cloneID, qt.Not(qt.Equals), sourceID)
+	ci, err := cb.CensusInfo(cloneID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(50))
+	c.Assert(ci.Closed, qt.IsFalse)
+	censusIDs, err := cb.FindCensusesForKey(keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusIDs, qt.DeepEquals, []types.CensusID{sourceID, cloneID})
+
+	// adding keys to the clone does not affect the source
+	err = cb.AddPublicKeys(cloneID, keys.PublicKeys[50:], keys.Weights[50:])
+	c.Assert(err, qt.IsNil)
+	ci, err = cb.CensusInfo(sourceID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(50))
+
+	// clone the closed and published source Census
+	err = cb.AddPublicKeys(sourceID, keys.PublicKeys[50:], keys.Weights[50:])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(sourceID)
+	c.Assert(err, qt.IsNil)
+	err = cb.SetState(sourceID, census.StatePublished)
+	c.Assert(err, qt.IsNil)
+	sourceRoot, err := cb.CensusRoot(sourceID)
+	c.Assert(err, qt.IsNil)
+
+	cloneID, err = cb.CloneCensus(sourceID)
+	c.Assert(err, qt.IsNil)
+	ci, err = cb.CensusInfo(cloneID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(100))
+	c.Assert(ci.Closed, qt.IsTrue)
+	c.Assert(ci.State, qt.Equals, census.StateClosed)
+	cloneRoot, err := cb.CensusRoot(cloneID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(cloneRoot, qt.DeepEquals, sourceRoot)
+	_, _, err = cb.GetProof(cloneID, &keys.PublicKeys[99])
+	c.Assert(err, qt.IsNil)
+
+	// clone an open Census with SortKeys, its pending keys are cloned
+	sortedID, err := cb.NewCensusWithOptions(CensusOptions{SortKeys: true})
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(sortedID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	cloneID, err = cb.CloneCensus(sortedID)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(sortedID)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(cloneID)
+	c.Assert(err, qt.IsNil)
+	sortedRoot, err := cb.CensusRoot(sortedID)
+	c.Assert(err, qt.IsNil)
+	cloneRoot, err = cb.CensusRoot(cloneID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(cloneRoot, qt.DeepEquals, sortedRoot)
+
+	// a Census in use can not be cloned
+	err = cb.loadCensusIfNotYet(sourceID)
+	c.Assert(err, qt.IsNil)
+	_, err = cb.CloneCensus(sourceID)
+	c.Assert(err, qt.ErrorMatches, ErrCensusInUse.Error()+".*")
+	cb.releaseCensus(sourceID)
+
+	_, err = cb.CloneCensus(42)
+	c.Assert(err, qt.ErrorMatches, "CensusID=42 does not exist")
+
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+}
@@ -0,0 +1,252 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+)
+
+// dbPrefixCompacted is used to store the time at which each Census sub-db was
+// compacted, so the maintenance goroutine does not compact it again
+var dbPrefixCompacted = []byte("compacted")
+
+func dbKeyCompacted(censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	return append(append([]byte{}, dbPrefixCompacted...), b...)
+}
+
+// compacter is implemented by the Census sub-dbs that can be compacted
+type compacter interface {
+	Compact() error
+}
+
+// CompactionReport contains the result of compacting the sub-db of a Census
+type CompactionReport struct {
+	CensusID types.CensusID `json:"censusID"`
+	// SizeBefore and SizeAfter contain the size in bytes of the files of
+	// the sub-db before and after the compaction
+	SizeBefore int64 `json:"sizeBefore"`
+	SizeAfter  int64 `json:"sizeAfter"`
+	// Reclaimed contains the bytes freed by the compaction, which can be
+	// negative if the compaction did not reduce the size of the sub-db
+	Reclaimed int64         `json:"reclaimed"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// CompactCensus compacts the sub-db of the closed Census of the given
+// censusID, removing from disk the entries overwritten and deleted while the
+// Census was built, which pebble keeps until they are compacted. The Census
+// is compacted even if it was already compacted. Returns ErrCensusInUse if
+// the Census is being used concurrently, as its sub-db needs to be closed
+// while it is compacted.
+func (cb *CensusBuilder) CompactCensus(censusID types.CensusID) (*CompactionReport, error) {
+	cb.lifecycleMu.Lock()
+	defer cb.lifecycleMu.Unlock()
+	if cb.isEphemeral(censusID) {
+		return nil, ErrCensusEphemeral
+	}
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	isClosed, err := cb.getCensus(censusID).IsClosed()
+	if err != nil {
+		return nil, err
+	}
+	if !isClosed {
+		return nil, fmt.Errorf("%s, CensusID=%d", census.ErrCensusNotClosed, censusID)
+	}
+
+	start := time.Now()
+	sizeBefore, sizeAfter, err := cb.compactSubDB(censusID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the Census is marked as compacted once it can be loaded again
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(cb.now().Unix()))
+	if err := wTx.Set(dbKeyCompacted(censusID), b); err != nil {
+		return nil, err
+	}
+	if err := wTx.Commit(); err != nil {
+		return nil, err
+	}
+
+	report := &CompactionReport{
+		CensusID:   censusID,
+		SizeBefore: sizeBefore,
+		SizeAfter:  sizeAfter,
+		Reclaimed:  sizeBefore - sizeAfter,
+		Duration:   time.Since(start),
+	}
+	log.Infof("[CensusID=%d] sub-db compacted in %s, %d bytes reclaimed"+
+		" (%d -> %d)", censusID, report.Duration, report.Reclaimed,
+		sizeBefore, sizeAfter)
+	return report, nil
+}
+
+// compactSubDB detaches the Census of the given censusID and compacts its
+// sub-db, returning the size in bytes of the sub-db before and after the
+// compaction
+func (cb *CensusBuilder) compactSubDB(censusID types.CensusID) (int64, int64, error) {
+	if err := cb.detachCensus(censusID); err != nil {
+		return 0, 0, err
+	}
+	defer cb.detachDone(censusID)
+
+	path := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
+	sizeBefore, err := dirSize(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	subDB, err := openSubDB(path, cb.pebbleOpts)
+	if err != nil {
+		return 0, 0, err
+	}
+	c, ok := subDB.(compacter)
+	if !ok {
+		subDB.Close() //nolint:errcheck
+		return 0, 0, fmt.Errorf("the sub-db of CensusID=%d can not be"+
+			" compacted", censusID)
+	}
+	if err := c.Compact(); err != nil {
+		subDB.Close() //nolint:errcheck
+		return 0, 0, err
+	}
+	// the obsolete files are removed when closing the db
+	if err := subDB.Close(); err != nil {
+		return 0, 0, err
+	}
+	sizeAfter, err := dirSize(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	return sizeBefore, sizeAfter, nil
+}
+
+// CompactCensuses compacts the sub-dbs of the closed Censuses that have not
+// been compacted yet (see CompactCensus), returning a CompactionReport for
+// each compacted Census. The archived and ephemeral Censuses and the ones
+// being used concurrently are skipped, the later are compacted in a next
+// call. It is called periodically when the CompactionInterval option is set.
+func (cb *CensusBuilder) CompactCensuses() ([]CompactionReport, error) {
+	summaries, err := cb.ListCensuses(CensusStatusClosed)
+	if err != nil {
+		return nil, err
+	}
+	var reports []CompactionReport
+	for _, summary := range summaries {
+		if summary.Archived || cb.isEphemeral(summary.ID) {
+			continue
+		}
+		compacted, err := cb.isCompacted(summary.ID)
+		if err != nil {
+			return reports, err
+		}
+		if compacted {
+			continue
+		}
+		report, err := cb.CompactCensus(summary.ID)
+		if errors.Is(err, ErrCensusInUse) {
+			log.Debugf("[CensusID=%d] in use, not compacted", summary.ID)
+			continue
+		} else if err == ErrCensusArchived || err == ErrCensusDeleted {
+			// archived or deleted after being listed
+			continue
+		} else if err != nil {
+			return reports, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+// isCompacted returns true if the sub-db of the Census of the given censusID
+// has been compacted with CompactCensus
+func (cb *CensusBuilder) isCompacted(censusID types.CensusID) (bool, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	_, err := rTx.Get(dbKeyCompacted(censusID))
+	if err == db.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// compactCensuses compacts the pending Censuses from the maintenance
+// goroutine, logging the reclaimed space
+func (cb *CensusBuilder) compactCensuses() {
+	reports, err := cb.CompactCensuses()
+	if err != nil {
+		log.Errorf("error compacting the Census sub-dbs: %s", err)
+	}
+	if len(reports) == 0 {
+		return
+	}
+	var reclaimed int64
+	for i := 0; i < len(reports); i++ {
+		reclaimed += reports[i].Reclaimed
+	}
+	log.Infof("%d Census sub-dbs compacted, %d bytes reclaimed", len(reports),
+		reclaimed)
+}
+
+// startCompaction starts the maintenance goroutine that periodically compacts
+// the closed Censuses, until stopCompaction is called
+func (cb *CensusBuilder) startCompaction() {
+	cb.compactStop = make(chan struct{})
+	cb.compactWg.Add(1)
+	go func() {
+		defer cb.compactWg.Done()
+		ticker := time.NewTicker(cb.compactionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				cb.compactCensuses()
+			case <-cb.compactStop:
+				return
+			}
+		}
+	}()
+}
+
+// stopCompaction stops the goroutine started by startCompaction, if any, and
+// waits until it has finished
+func (cb *CensusBuilder) stopCompaction() {
+	if cb.compactStop == nil {
+		return
+	}
+	close(cb.compactStop)
+	cb.compactWg.Wait()
+}
+
+// dirSize returns the size in bytes of the files of the given directory
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
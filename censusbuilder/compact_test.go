@@ -0,0 +1,118 @@
+package censusbuilder
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCompactCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(100)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		ChunkSize:  10,
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	openID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	// an open Census can not be compacted
+	_, err = cb.CompactCensus(censusID)
+	c.Assert(err, qt.ErrorMatches, census.ErrCensusNotClosed.Error()+".*")
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+
+	// a Census in use can not be compacted
+	err = cb.loadCensusIfNotYet(censusID)
+	c.Assert(err, qt.IsNil)
+	_, err = cb.CompactCensus(censusID)
+	c.Assert(err, qt.ErrorMatches, ErrCensusInUse.Error()+".*")
+	c.Assert(errors.Is(err, ErrCensusInUse), qt.IsTrue)
+	reports, err := cb.CompactCensuses()
+	c.Assert(err, qt.IsNil)
+	c.Assert(reports, qt.HasLen, 0)
+	cb.releaseCensus(censusID)
+
+	// only the closed Census is compacted, and only once
+	reports, err = cb.CompactCensuses()
+	c.Assert(err, qt.IsNil)
+	c.Assert(reports, qt.HasLen, 1)
+	c.Assert(reports[0].CensusID, qt.Equals, censusID)
+	c.Assert(reports[0].SizeAfter > 0, qt.IsTrue)
+	c.Assert(reports[0].Reclaimed, qt.Equals,
+		reports[0].SizeBefore-reports[0].SizeAfter)
+	reports, err = cb.CompactCensuses()
+	c.Assert(err, qt.IsNil)
+	c.Assert(reports, qt.HasLen, 0)
+	compacted, err := cb.isCompacted(openID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(compacted, qt.IsFalse)
+
+	// the compacted Census keeps its CensusRoot and CensusProofs
+	root2, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(root2, qt.DeepEquals, root)
+	for i := 0; i < len(keys.PublicKeys); i++ {
+		cp, err := cb.GetCensusProof(censusID, &keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		v, err := cb.VerifyMembershipProof(censusID, *cp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+	}
+
+	// it can be compacted again explicitly
+	report, err := cb.CompactCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.CensusID, qt.Equals, censusID)
+	c.Assert(cb.Close(), qt.IsNil)
+}
+
+func TestCompactionInterval(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(20)
+
+	cb, err := NewWithOptions(Options{
+		DB:                 newTestDB(c),
+		SubDBsPath:         c.TempDir(),
+		CompactionInterval: 10 * time.Millisecond,
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	// the maintenance goroutine compacts the closed Census
+	c.Assert(func() bool {
+		for i := 0; i < 100; i++ {
+			compacted, err := cb.isCompacted(censusID)
+			c.Assert(err, qt.IsNil)
+			if compacted {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}(), qt.IsTrue)
+	_, _, err = cb.GetProof(censusID, &keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(cb.Close(), qt.IsNil)
+}
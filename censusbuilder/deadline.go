@@ -0,0 +1,81 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+)
+
+// dbPrefixRootCensusID is used to store the censusID of each CensusRoot, so
+// the VotingDeadline can be checked for the votes, which only reference the
+// CensusRoot
+var dbPrefixRootCensusID = []byte("rootCensusID")
+
+func dbKeyRootCensusID(root []byte) []byte {
+	return append(append([]byte{}, dbPrefixRootCensusID...), root...)
+}
+
+// indexRoot stores the censusID of the CensusRoot of the closed Census of the
+// given censusID. If there are multiple Censuses with the same CensusRoot,
+// the last closed one is used.
+func (cb *CensusBuilder) indexRoot(censusID types.CensusID) error {
+	root, err := cb.getCensus(censusID).Root()
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	if err := wTx.Set(dbKeyRootCensusID(root), b); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
+
+// SetVotingDeadline sets the VotingDeadline of the Census of the given
+// censusID, after which the votes are rejected with census.ErrVotingClosed. A
+// zero time removes the VotingDeadline.
+func (cb *CensusBuilder) SetVotingDeadline(censusID types.CensusID, deadline time.Time) error {
+	err := cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	if err := cb.getCensus(censusID).SetVotingDeadline(deadline); err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] VotingDeadline set to %s", censusID, deadline)
+	return nil
+}
+
+// CheckVotingOpen returns census.ErrVotingClosed if the VotingDeadline of the
+// Census of the given censusID has passed
+func (cb *CensusBuilder) CheckVotingOpen(censusID types.CensusID) error {
+	err := cb.loadCensusIfNotYet(censusID)
+	if err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).CheckVotingOpen()
+}
+
+// CheckVotingOpenByRoot returns census.ErrVotingClosed if the VotingDeadline
+// of the closed Census with the given CensusRoot has passed. If the
+// CensusRoot does not belong to any Census of the CensusBuilder, there is no
+// VotingDeadline to check, and nil is returned.
+func (cb *CensusBuilder) CheckVotingOpenByRoot(root []byte) error {
+	rTx := cb.db.ReadTx()
+	b, err := rTx.Get(dbKeyRootCensusID(root))
+	rTx.Discard()
+	if err == db.ErrKeyNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return cb.CheckVotingOpen(types.CensusID(binary.LittleEndian.Uint64(b)))
+}
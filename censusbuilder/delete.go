@@ -0,0 +1,163 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+)
+
+// ErrCensusDeleted is used when trying to use a Census that has been deleted
+// with DeleteCensus
+var ErrCensusDeleted = errors.New("Census deleted")
+
+// dbPrefixDeleted is used to mark the deleted Censuses, so their censusIDs
+// are not reused nor loaded
+var dbPrefixDeleted = []byte("deleted")
+
+func dbKeyDeleted(censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	return append(append([]byte{}, dbPrefixDeleted...), b...)
+}
+
+// isDeleted returns true if the Census of the given censusID has been deleted
+func (cb *CensusBuilder) isDeleted(censusID types.CensusID) (bool, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	_, err := rTx.Get(dbKeyDeleted(censusID))
+	if err == db.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteCensus closes (if it is not closed yet) the Census of the given
+// censusID and deletes its sub-db from disk, to reclaim the disk space once
+// the processes that use the Census are finished. If the CanDeleteCensus hook
+// is set, it is called before deleting anything, and the Census is not
+// deleted if it returns an error. The Label, the ExternalID, the expiration,
+// the CensusRoot index and the KeyIndex entries of the Census are removed, and
+// the censusID is not reused.
+// Archived Censuses need to be restored before being deleted. Returns
+// ErrCensusInUse if the Census is being used concurrently.
+func (cb *CensusBuilder) DeleteCensus(censusID types.CensusID) error {
+	cb.lifecycleMu.Lock()
+	defer cb.lifecycleMu.Unlock()
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	c := cb.getCensus(censusID)
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return err
+	}
+	if !isClosed {
+		if err := cb.CloseCensus(censusID); err != nil {
+			return err
+		}
+	}
+	root, err := c.Root()
+	if err != nil {
+		return err
+	}
+	if cb.CanDeleteCensus != nil {
+		if err := cb.CanDeleteCensus(censusID, root); err != nil {
+			return fmt.Errorf("can not delete CensusID=%d: %w", censusID, err)
+		}
+	}
+
+	// the PublicKeys are read before closing the sub-db, to remove them
+	// from the KeyIndex
+	var pubKs []babyjub.PublicKey
+	if cb.keyIndex {
+		err := c.IterateLeaves(func(_ uint64, pubK babyjub.PublicKey) error {
+			pubKs = append(pubKs, pubK)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := cb.detachCensus(censusID); err != nil {
+		return err
+	}
+	defer cb.detachDone(censusID)
+	cb.labelsMu.Lock()
+	err = cb.markDeleted(censusID, root, pubKs)
+	cb.labelsMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(cb.subDBsPath, strconv.Itoa(int(censusID)))
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("CensusID=%d marked as deleted, but its sub-db"+
+			" at %s can not be removed: %s", censusID, path, err)
+	}
+	log.Debugf("[CensusID=%d] deleted", censusID)
+	return nil
+}
+
+// markDeleted marks the Census of the given censusID as deleted, removing its
+// entries from the CensusBuilder db in a single db.WriteTx. It must be called
+// with the labelsMu locked.
+func (cb *CensusBuilder) markDeleted(censusID types.CensusID, root []byte,
+	pubKs []babyjub.PublicKey) error {
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+
+	label, err := wTx.Get(dbKeyCensusLabel(censusID))
+	if err == nil {
+		if err := wTx.Delete(dbKeyLabel(string(label))); err != nil {
+			return err
+		}
+		if err := wTx.Delete(dbKeyCensusLabel(censusID)); err != nil {
+			return err
+		}
+	} else if err != db.ErrKeyNotFound {
+		return err
+	}
+	if err := deleteExternalID(wTx, censusID); err != nil {
+		return err
+	}
+	if err := wTx.Delete(dbKeyExpiration(censusID)); err != nil {
+		return err
+	}
+	if err := wTx.Delete(dbKeyEphemeral(censusID)); err != nil {
+		return err
+	}
+
+	// the CensusRoot index may point to another Census with the same
+	// CensusRoot
+	b, err := wTx.Get(dbKeyRootCensusID(root))
+	if err == nil && types.CensusID(binary.LittleEndian.Uint64(b)) == censusID {
+		if err := wTx.Delete(dbKeyRootCensusID(root)); err != nil {
+			return err
+		}
+	} else if err != nil && err != db.ErrKeyNotFound {
+		return err
+	}
+
+	for i := 0; i < len(pubKs); i++ {
+		if err := wTx.Delete(dbKeyKeyIndex(pubKs[i].Compress(), censusID)); err != nil {
+			return err
+		}
+	}
+
+	if err := wTx.Set(dbKeyDeleted(censusID), []byte{1}); err != nil {
+		return err
+	}
+	return wTx.Commit()
+}
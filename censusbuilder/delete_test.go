@@ -0,0 +1,111 @@
+package censusbuilder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestDeleteCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	subDBsPath := c.TempDir()
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: subDBsPath,
+		KeyIndex:   true,
+	})
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensusWithLabel("toDelete")
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:5], keys.Weights[:5])
+	c.Assert(err, qt.IsNil)
+	otherID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(otherID, keys.PublicKeys[3:], keys.Weights[3:])
+	c.Assert(err, qt.IsNil)
+
+	// the CanDeleteCensus hook prevents the deletion
+	var hookRoot []byte
+	cb.CanDeleteCensus = func(_ types.CensusID, root []byte) error {
+		hookRoot = root
+		return fmt.Errorf("process not finished")
+	}
+	err = cb.DeleteCensus(censusID)
+	c.Assert(err, qt.ErrorMatches, "can not delete CensusID=0: process not finished")
+	// the Census has been closed, but not deleted
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(hookRoot, qt.DeepEquals, root)
+	path := filepath.Join(subDBsPath, strconv.Itoa(int(censusID)))
+	_, err = os.Stat(path)
+	c.Assert(err, qt.IsNil)
+
+	cb.CanDeleteCensus = func(_ types.CensusID, _ []byte) error {
+		return nil
+	}
+	err = cb.DeleteCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	_, err = os.Stat(path)
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+	_, err = cb.CensusRoot(censusID)
+	c.Assert(err, qt.Equals, ErrCensusDeleted)
+	err = cb.DeleteCensus(censusID)
+	c.Assert(err, qt.Equals, ErrCensusDeleted)
+
+	// the Label, the CensusRoot and the KeyIndex entries are removed
+	_, err = cb.FindCensusByLabel("toDelete")
+	c.Assert(err, qt.ErrorMatches, ErrLabelNotFound.Error()+".*")
+	// with the CensusRoot removed, there is no VotingDeadline to check
+	err = cb.CheckVotingOpenByRoot(root)
+	c.Assert(err, qt.IsNil)
+	censusIDs, err := cb.FindCensusesForKey(keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(censusIDs), qt.Equals, 0)
+	censusIDs, err = cb.FindCensusesForKey(keys.PublicKeys[4])
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusIDs, qt.DeepEquals, []types.CensusID{otherID})
+
+	// the Label can be reused, and the censusID is not reused
+	newID, err := cb.NewCensusWithLabel("toDelete")
+	c.Assert(err, qt.IsNil)
+	c.Assert(newID, qt.Equals, otherID+1)
+
+	stats, err := cb.Stats()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stats.Censuses, qt.Equals, uint64(3))
+	c.Assert(stats.DeletedCensuses, qt.Equals, uint64(1))
+	summaries, err := cb.ListCensuses(CensusStatusAny)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(summaries), qt.Equals, 2)
+	c.Assert(summaries[0].ID, qt.Equals, otherID)
+}
+
+func TestDeleteArchivedCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(2)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.ArchiveCensus(censusID, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	err = cb.DeleteCensus(censusID)
+	c.Assert(err, qt.Equals, ErrCensusArchived)
+}
@@ -0,0 +1,84 @@
+package censusbuilder
+
+import (
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// Diff compares the PublicKeys of the Censuses of the given aID and bID,
+// returning the PublicKeys of b that are not in a (added), and the PublicKeys
+// of a that are not in b (removed), each one sorted by their index in its
+// Census. The Censuses can be open or closed, for open Censuses with SortKeys
+// the buffered PublicKeys are also compared. The PublicKeys of each Census are
+// iterated and checked against the other Census, without loading all the
+// PublicKeys of both Censuses in memory.
+func (cb *CensusBuilder) Diff(aID, bID types.CensusID) (added []babyjub.PublicKey,
+	removed []babyjub.PublicKey, err error) {
+	if aID == bID {
+		if err := cb.loadCensusIfNotYet(aID); err != nil {
+			return nil, nil, err
+		}
+		cb.releaseCensus(aID)
+		return nil, nil, nil
+	}
+	if err := cb.loadCensusIfNotYet(aID); err != nil {
+		return nil, nil, err
+	}
+	defer cb.releaseCensus(aID)
+	if err := cb.loadCensusIfNotYet(bID); err != nil {
+		return nil, nil, err
+	}
+	defer cb.releaseCensus(bID)
+
+	added, err = cb.missingPublicKeys(bID, aID)
+	if err != nil {
+		return nil, nil, err
+	}
+	removed, err = cb.missingPublicKeys(aID, bID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return added, removed, nil
+}
+
+// missingPublicKeys returns the PublicKeys of the Census of the given fromID
+// that are not in the Census of the given inID. Both Censuses must be loaded.
+func (cb *CensusBuilder) missingPublicKeys(fromID, inID types.CensusID) (
+	[]babyjub.PublicKey, error) {
+	from := cb.getCensus(fromID)
+	in := cb.getCensus(inID)
+
+	var missing []babyjub.PublicKey
+	check := func(pubK babyjub.PublicKey) error {
+		ok, err := in.HasPublicKey(&pubK)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			missing = append(missing, pubK)
+		}
+		return nil
+	}
+
+	if from.SortKeys() {
+		// the buffered PublicKeys are not in the MerkleTree, so they
+		// are not iterated by IterateLeaves
+		keys, err := from.PublicKeys()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(keys); i++ {
+			if err := check(keys[i].PublicKey); err != nil {
+				return nil, err
+			}
+		}
+		return missing, nil
+	}
+	err := from.IterateLeaves(func(_ uint64, pubK babyjub.PublicKey) error {
+		return check(pubK)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
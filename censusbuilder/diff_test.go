@@ -0,0 +1,72 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestDiff(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	newCensus := func(from, to int, opts CensusOptions) types.CensusID {
+		censusID, err := cb.NewCensusWithOptions(opts)
+		c.Assert(err, qt.IsNil)
+		err = cb.AddPublicKeys(censusID, keys.PublicKeys[from:to],
+			keys.Weights[from:to])
+		c.Assert(err, qt.IsNil)
+		return censusID
+	}
+	compressed := func(pubKs []babyjub.PublicKey) []babyjub.PublicKeyComp {
+		var r []babyjub.PublicKeyComp
+		for i := 0; i < len(pubKs); i++ {
+			r = append(r, pubKs[i].Compress())
+		}
+		return r
+	}
+
+	// overlapping key sets, with a closed and an open Census
+	aID := newCensus(0, 6, CensusOptions{})
+	err = cb.CloseCensus(aID)
+	c.Assert(err, qt.IsNil)
+	bID := newCensus(3, 9, CensusOptions{})
+	added, removed, err := cb.Diff(aID, bID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(compressed(added), qt.DeepEquals, compressed(keys.PublicKeys[6:9]))
+	c.Assert(compressed(removed), qt.DeepEquals, compressed(keys.PublicKeys[0:3]))
+
+	// the reverse Diff swaps the added and removed keys
+	added, removed, err = cb.Diff(bID, aID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(compressed(added), qt.DeepEquals, compressed(keys.PublicKeys[0:3]))
+	c.Assert(compressed(removed), qt.DeepEquals, compressed(keys.PublicKeys[6:9]))
+
+	// disjoint key sets, with an open Census with SortKeys
+	cID := newCensus(9, 10, CensusOptions{SortKeys: true})
+	added, removed, err = cb.Diff(aID, cID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(compressed(added), qt.DeepEquals, compressed(keys.PublicKeys[9:10]))
+	c.Assert(compressed(removed), qt.DeepEquals, compressed(keys.PublicKeys[0:6]))
+
+	// same key sets
+	dID := newCensus(0, 6, CensusOptions{})
+	added, removed, err = cb.Diff(aID, dID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(added), qt.Equals, 0)
+	c.Assert(len(removed), qt.Equals, 0)
+	added, removed, err = cb.Diff(aID, aID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(added), qt.Equals, 0)
+	c.Assert(len(removed), qt.Equals, 0)
+
+	_, _, err = cb.Diff(aID, 42)
+	c.Assert(err, qt.ErrorMatches, "CensusID=42 does not exist")
+}
@@ -0,0 +1,112 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/log"
+)
+
+// ErrCensusEphemeral is used when trying to archive, back up, clone or compact
+// an ephemeral Census, which has no sub-db in disk
+var ErrCensusEphemeral = errors.New("Census is ephemeral")
+
+// dbPrefixEphemeral is used to mark the ephemeral Censuses, so the ones left
+// by a CensusBuilder that was not closed are deleted when it is loaded again
+var dbPrefixEphemeral = []byte("ephemeral")
+
+func dbKeyEphemeral(censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	return append(append([]byte{}, dbPrefixEphemeral...), b...)
+}
+
+// isEphemeral returns true if the Census of the given censusID was created
+// with the Ephemeral CensusOption and has not been deleted yet
+func (cb *CensusBuilder) isEphemeral(censusID types.CensusID) bool {
+	cb.censusesMu.RLock()
+	defer cb.censusesMu.RUnlock()
+	return cb.ephemeral[censusID]
+}
+
+// setEphemeral marks the Census of the given censusID as ephemeral
+func (cb *CensusBuilder) setEphemeral(censusID types.CensusID) error {
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	if err := wTx.Set(dbKeyEphemeral(censusID), []byte{1}); err != nil {
+		return err
+	}
+	if err := wTx.Commit(); err != nil {
+		return err
+	}
+	cb.censusesMu.Lock()
+	cb.ephemeral[censusID] = true
+	cb.censusesMu.Unlock()
+	return nil
+}
+
+// deleteEphemeralCensuses marks as deleted the ephemeral Censuses, whose data
+// is lost once the CensusBuilder is closed. It is called by Close, and when
+// loading the CensusBuilder, for the Censuses left by a CensusBuilder that was
+// not closed.
+func (cb *CensusBuilder) deleteEphemeralCensuses() error {
+	var censusIDs []types.CensusID
+	err := cb.db.Iterate(dbPrefixEphemeral, func(k, _ []byte) bool {
+		censusIDs = append(censusIDs, types.CensusID(binary.LittleEndian.Uint64(k)))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, censusID := range censusIDs {
+		var root []byte
+		var pubKs []babyjub.PublicKey
+		if c := cb.getCensus(censusID); c != nil {
+			if root, pubKs, err = cb.ephemeralEntries(c); err != nil {
+				return err
+			}
+		}
+		cb.labelsMu.Lock()
+		err = cb.markDeleted(censusID, root, pubKs)
+		cb.labelsMu.Unlock()
+		if err != nil {
+			return err
+		}
+		cb.censusesMu.Lock()
+		delete(cb.ephemeral, censusID)
+		cb.censusesMu.Unlock()
+		log.Debugf("[CensusID=%d] ephemeral, deleted", censusID)
+	}
+	return nil
+}
+
+// ephemeralEntries returns the CensusRoot (only indexed once the Census is
+// closed) and the PublicKeys of the KeyIndex of the given loaded Census, to
+// remove their entries when it is deleted
+func (cb *CensusBuilder) ephemeralEntries(c *census.Census) ([]byte,
+	[]babyjub.PublicKey, error) {
+	var root []byte
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return nil, nil, err
+	}
+	if isClosed {
+		if root, err = c.Root(); err != nil {
+			return nil, nil, err
+		}
+	}
+	var pubKs []babyjub.PublicKey
+	if cb.keyIndex {
+		err := c.IterateLeaves(func(_ uint64, pubK babyjub.PublicKey) error {
+			pubKs = append(pubKs, pubK)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return root, pubKs, nil
+}
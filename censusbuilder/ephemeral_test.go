@@ -0,0 +1,88 @@
+package censusbuilder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestEphemeralCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(20)
+
+	database := newTestDB(c)
+	subDBsPath := c.TempDir()
+	cb, err := NewWithOptions(Options{DB: database, SubDBsPath: subDBsPath,
+		MaxLoadedCensuses: 1})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensusWithOptions(CensusOptions{Ephemeral: true})
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:10], keys.Weights[:10])
+	c.Assert(err, qt.IsNil)
+
+	// the ephemeral Census has no sub-db in disk
+	_, err = os.Stat(filepath.Join(subDBsPath, strconv.Itoa(int(censusID))))
+	c.Assert(os.IsNotExist(err), qt.IsTrue)
+
+	// the ephemeral Census is not evicted, as its data would be lost
+	otherID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(otherID, keys.PublicKeys[10:], keys.Weights[10:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(cb.censuses[censusID], qt.Not(qt.IsNil))
+	c.Assert(cb.censuses[otherID], qt.IsNil)
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	index, _, err := cb.GetProof(censusID, &keys.PublicKeys[3])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(3))
+
+	err = cb.ArchiveCensus(censusID, c.TempDir())
+	c.Assert(err, qt.Equals, ErrCensusEphemeral)
+	err = cb.Backup(censusID, &bytes.Buffer{})
+	c.Assert(err, qt.Equals, ErrCensusEphemeral)
+	_, err = cb.CloneCensus(censusID)
+	c.Assert(err, qt.Equals, ErrCensusEphemeral)
+	_, err = cb.ReopenCensus(censusID)
+	c.Assert(err, qt.Equals, ErrCensusEphemeral)
+	_, err = cb.CompactCensus(censusID)
+	c.Assert(err, qt.Equals, ErrCensusEphemeral)
+	summaries, err := cb.ListCensuses(CensusStatusAny)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(summaries), qt.Equals, 2)
+
+	// an ephemeral Census can be deleted
+	deletedID, err := cb.NewCensusWithOptions(CensusOptions{Ephemeral: true})
+	c.Assert(err, qt.IsNil)
+	err = cb.DeleteCensus(deletedID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(cb.isEphemeral(deletedID), qt.IsFalse)
+	_, err = cb.CensusInfo(deletedID)
+	c.Assert(err, qt.Equals, ErrCensusDeleted)
+
+	// the ephemeral Censuses left by a CensusBuilder that was not closed
+	// are deleted when loading it again
+	cb2, err := NewWithOptions(Options{DB: database, SubDBsPath: subDBsPath})
+	c.Assert(err, qt.IsNil)
+	_, err = cb2.CensusInfo(censusID)
+	c.Assert(err, qt.Equals, ErrCensusDeleted)
+	summaries, err = cb2.ListCensuses(CensusStatusAny)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(summaries), qt.Equals, 1)
+	c.Assert(summaries[0].ID, qt.Equals, otherID)
+
+	// the ephemeral Censuses are deleted when closing the CensusBuilder
+	censusID, err = cb2.NewCensusWithOptions(CensusOptions{Ephemeral: true})
+	c.Assert(err, qt.IsNil)
+	c.Assert(cb2.Close(), qt.IsNil)
+	deleted, err := cb2.isDeleted(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(deleted, qt.IsTrue)
+}
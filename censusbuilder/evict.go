@@ -0,0 +1,115 @@
+package censusbuilder
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// DefaultMaxLoadedCensuses defines the default maximum number of Censuses
+// loaded in memory at the same time (see Options.MaxLoadedCensuses for the
+// resulting memory and open files budget)
+const DefaultMaxLoadedCensuses = 32
+
+// releaseCensus marks the Census of the given censusID, loaded with
+// loadCensusIfNotYet, as not used anymore by the caller, so it can be evicted
+// once no other caller is using it
+func (cb *CensusBuilder) releaseCensus(censusID types.CensusID) {
+	cb.censusesMu.Lock()
+	defer cb.censusesMu.Unlock()
+	if cb.censusRefs[censusID] > 1 {
+		cb.censusRefs[censusID]--
+	} else {
+		delete(cb.censusRefs, censusID)
+	}
+	if _, ok := cb.censuses[censusID]; ok {
+		cb.censusLastUsed[censusID] = cb.now()
+	}
+	cb.evictCensuses()
+}
+
+// evictCensuses unloads the least recently used Censuses that are not in use,
+// until there are at most maxLoadedCensuses loaded Censuses. If all the loaded
+// Censuses are in use, the limit is exceeded until they are released. The
+// ephemeral Censuses are not unloaded. It must be called with the censusesMu
+// locked.
+func (cb *CensusBuilder) evictCensuses() {
+	if len(cb.censuses) <= cb.maxLoadedCensuses {
+		return
+	}
+	var candidates []types.CensusID
+	for censusID := range cb.censuses {
+		if cb.censusRefs[censusID] == 0 && !cb.ephemeral[censusID] {
+			candidates = append(candidates, censusID)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return cb.censusLastUsed[candidates[i]].Before(
+			cb.censusLastUsed[candidates[j]])
+	})
+	for i := 0; i < len(candidates) && len(cb.censuses) > cb.maxLoadedCensuses; i++ {
+		cb.unloadCensus(candidates[i])
+	}
+}
+
+// evictIdleCensuses unloads the Censuses that are not in use and that have not
+// been used during the idleTimeout
+func (cb *CensusBuilder) evictIdleCensuses() {
+	cb.censusesMu.Lock()
+	defer cb.censusesMu.Unlock()
+	now := cb.now()
+	for censusID := range cb.censuses {
+		if cb.censusRefs[censusID] != 0 || cb.ephemeral[censusID] {
+			continue
+		}
+		if now.Sub(cb.censusLastUsed[censusID]) >= cb.idleTimeout {
+			cb.unloadCensus(censusID)
+		}
+	}
+}
+
+// unloadCensus closes the db of the loaded Census of the given censusID and
+// removes it from memory, it will be loaded again when used. It must be called
+// with the censusesMu locked.
+func (cb *CensusBuilder) unloadCensus(censusID types.CensusID) {
+	if err := cb.censuses[censusID].CloseDB(); err != nil {
+		log.Warnf("[CensusID=%d] can not close the db to unload it: %s",
+			censusID, err)
+		return
+	}
+	delete(cb.censuses, censusID)
+	delete(cb.censusLastUsed, censusID)
+	log.Debugf("[CensusID=%d] unloaded", censusID)
+}
+
+// startIdleEviction starts the goroutine that periodically unloads the idle
+// Censuses, until stopIdleEviction is called
+func (cb *CensusBuilder) startIdleEviction() {
+	cb.evictStop = make(chan struct{})
+	cb.evictWg.Add(1)
+	go func() {
+		defer cb.evictWg.Done()
+		ticker := time.NewTicker(cb.idleTimeout)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				cb.evictIdleCensuses()
+			case <-cb.evictStop:
+				return
+			}
+		}
+	}()
+}
+
+// stopIdleEviction stops the goroutine started by startIdleEviction, if any,
+// and waits until it has finished
+func (cb *CensusBuilder) stopIdleEviction() {
+	if cb.evictStop == nil {
+		return
+	}
+	close(cb.evictStop)
+	cb.evictWg.Wait()
+}
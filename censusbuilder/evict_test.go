@@ -0,0 +1,120 @@
+package censusbuilder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(6)
+
+	now := time.Unix(1000, 0)
+	cb, err := NewWithOptions(Options{
+		DB:                newTestDB(c),
+		SubDBsPath:        c.TempDir(),
+		MaxLoadedCensuses: 2,
+		Now: func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		},
+	})
+	c.Assert(err, qt.IsNil)
+
+	var censusIDs []types.CensusID
+	for i := 0; i < 3; i++ {
+		censusID, err := cb.NewCensus()
+		c.Assert(err, qt.IsNil)
+		err = cb.AddPublicKeys(censusID, keys.PublicKeys[2*i:2*i+2],
+			keys.Weights[2*i:2*i+2])
+		c.Assert(err, qt.IsNil)
+		censusIDs = append(censusIDs, censusID)
+	}
+	// the first Census has been evicted when the third one was created
+	c.Assert(len(cb.censuses), qt.Equals, 2)
+	c.Assert(cb.censuses[censusIDs[0]], qt.IsNil)
+
+	// using the first Census loads it again, evicting the second one,
+	// which is the least recently used
+	view, err := cb.View(censusIDs[0])
+	c.Assert(err, qt.IsNil)
+	n, err := view.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, uint64(2))
+	c.Assert(len(cb.censuses), qt.Equals, 2)
+	c.Assert(cb.censuses[censusIDs[1]], qt.IsNil)
+	c.Assert(cb.censuses[censusIDs[2]], qt.Not(qt.IsNil))
+
+	// the evicted Censuses keep their data
+	err = cb.CloseCensus(censusIDs[1])
+	c.Assert(err, qt.IsNil)
+	ok, err := cb.HasPublicKey(censusIDs[1], &keys.PublicKeys[3])
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+
+	// the Censuses in use are not evicted
+	cb.censusesMu.Lock()
+	for censusID := range cb.censuses {
+		cb.censusRefs[censusID]++
+	}
+	cb.censusesMu.Unlock()
+	err = cb.loadCensusIfNotYet(censusIDs[2])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(cb.censuses), qt.Equals, 3)
+	cb.releaseCensus(censusIDs[2])
+	c.Assert(len(cb.censuses), qt.Equals, 2)
+	c.Assert(cb.censuses[censusIDs[2]], qt.IsNil)
+
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+}
+
+func TestEvictIdleCensuses(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(4)
+
+	now := time.Unix(1000, 0)
+	cb, err := NewWithOptions(Options{
+		DB:          newTestDB(c),
+		SubDBsPath:  c.TempDir(),
+		IdleTimeout: time.Hour,
+		Now:         func() time.Time { return now },
+	})
+	c.Assert(err, qt.IsNil)
+
+	idleID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(idleID, keys.PublicKeys[:2], keys.Weights[:2])
+	c.Assert(err, qt.IsNil)
+
+	now = now.Add(30 * time.Minute)
+	usedID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(usedID, keys.PublicKeys[2:], keys.Weights[2:])
+	c.Assert(err, qt.IsNil)
+
+	now = now.Add(30 * time.Minute)
+	cb.evictIdleCensuses()
+	c.Assert(len(cb.censuses), qt.Equals, 1)
+	c.Assert(cb.censuses[idleID], qt.IsNil)
+
+	// the unloaded Census is loaded again when used
+	view, err := cb.View(idleID)
+	c.Assert(err, qt.IsNil)
+	n, err := view.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, uint64(2))
+
+	now = now.Add(time.Hour)
+	cb.evictIdleCensuses()
+	c.Assert(len(cb.censuses), qt.Equals, 0)
+
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+}
@@ -0,0 +1,147 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+)
+
+// dbPrefixExpiration is used to store the expiration time of each Census,
+// after which it is deleted by CollectExpiredCensuses
+var dbPrefixExpiration = []byte("expiration")
+
+func dbKeyExpiration(censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	return append(append([]byte{}, dbPrefixExpiration...), b...)
+}
+
+// SetExpiration sets the expiration time of the Census of the given censusID,
+// after which it is deleted by CollectExpiredCensuses. A zero time removes
+// the expiration, so the Census is kept until it is explicitly deleted.
+func (cb *CensusBuilder) SetExpiration(censusID types.CensusID, expiresAt time.Time) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.setExpiration(censusID, expiresAt)
+}
+
+func (cb *CensusBuilder) setExpiration(censusID types.CensusID, expiresAt time.Time) error {
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	if expiresAt.IsZero() {
+		if err := wTx.Delete(dbKeyExpiration(censusID)); err != nil {
+			return err
+		}
+		return wTx.Commit()
+	}
+	b, err := expiresAt.UTC().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := wTx.Set(dbKeyExpiration(censusID), b); err != nil {
+		return err
+	}
+	if err := wTx.Commit(); err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] expiration set to %s", censusID, expiresAt)
+	return nil
+}
+
+// getExpiration returns the expiration time of the Census of the given
+// censusID, or a zero time if it has no expiration
+func (cb *CensusBuilder) getExpiration(censusID types.CensusID) (time.Time, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	var expiresAt time.Time
+	b, err := rTx.Get(dbKeyExpiration(censusID))
+	if err == db.ErrKeyNotFound {
+		return expiresAt, nil
+	} else if err != nil {
+		return expiresAt, err
+	}
+	if err := expiresAt.UnmarshalBinary(b); err != nil {
+		return expiresAt, err
+	}
+	return expiresAt, nil
+}
+
+// CollectExpiredCensuses deletes with DeleteCensus the Censuses whose
+// expiration time has passed, closing them first if they are still open, and
+// returns the censusIDs of the deleted ones. The expired Censuses that can not
+// be deleted yet, because the CanDeleteCensus hook rejects it (as the
+// processes that use them are not finished), they are in use or they are
+// archived, are kept, and are collected by a next call. It is called
+// periodically when the GCInterval option is set.
+func (cb *CensusBuilder) CollectExpiredCensuses() ([]types.CensusID, error) {
+	now := cb.now()
+	var expired []types.CensusID
+	var decodeErr error
+	err := cb.db.Iterate(dbPrefixExpiration, func(k, v []byte) bool {
+		var expiresAt time.Time
+		if err := expiresAt.UnmarshalBinary(v); err != nil {
+			decodeErr = err
+			return false
+		}
+		if now.After(expiresAt) {
+			expired = append(expired,
+				types.CensusID(binary.LittleEndian.Uint64(k)))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	var deleted []types.CensusID
+	for _, censusID := range expired {
+		if err := cb.DeleteCensus(censusID); err != nil {
+			log.Infof("[CensusID=%d] expired, but can not be deleted yet: %s",
+				censusID, err)
+			continue
+		}
+		log.Infof("[CensusID=%d] expired and deleted", censusID)
+		deleted = append(deleted, censusID)
+	}
+	return deleted, nil
+}
+
+// startGC starts the goroutine that periodically deletes the expired
+// Censuses, until stopGC is called
+func (cb *CensusBuilder) startGC() {
+	cb.gcStop = make(chan struct{})
+	cb.gcWg.Add(1)
+	go func() {
+		defer cb.gcWg.Done()
+		ticker := time.NewTicker(cb.gcInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := cb.CollectExpiredCensuses(); err != nil {
+					log.Errorf("error deleting the expired Censuses: %s", err)
+				}
+			case <-cb.gcStop:
+				return
+			}
+		}
+	}()
+}
+
+// stopGC stops the goroutine started by startGC, if any, and waits until it
+// has finished
+func (cb *CensusBuilder) stopGC() {
+	if cb.gcStop == nil {
+		return
+	}
+	close(cb.gcStop)
+	cb.gcWg.Wait()
+}
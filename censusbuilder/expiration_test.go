@@ -0,0 +1,115 @@
+package censusbuilder
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCollectExpiredCensuses(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	now := time.Unix(1000, 0)
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		Now:        func() time.Time { return now },
+	})
+	c.Assert(err, qt.IsNil)
+
+	expiresAt := now.Add(time.Hour)
+	openID, err := cb.NewCensusWithOptions(CensusOptions{ExpiresAt: expiresAt})
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(openID, keys.PublicKeys[:5], keys.Weights[:5])
+	c.Assert(err, qt.IsNil)
+	closedID, err := cb.NewCensusWithOptions(CensusOptions{ExpiresAt: expiresAt})
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(closedID, keys.PublicKeys[5:], keys.Weights[5:])
+	c.Assert(err, qt.IsNil)
+	c.Assert(cb.CloseCensus(closedID), qt.IsNil)
+	// a Census without expiration, and one whose expiration is removed
+	keptID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	removedID, err := cb.NewCensusWithOptions(CensusOptions{ExpiresAt: expiresAt})
+	c.Assert(err, qt.IsNil)
+	c.Assert(cb.SetExpiration(removedID, time.Time{}), qt.IsNil)
+
+	ci, err := cb.CensusInfo(openID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.ExpiresAt, qt.Not(qt.IsNil))
+	c.Assert(ci.ExpiresAt.Equal(expiresAt), qt.IsTrue)
+	ci, err = cb.CensusInfo(removedID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.ExpiresAt, qt.IsNil)
+
+	// before the expiration nothing is deleted
+	deleted, err := cb.CollectExpiredCensuses()
+	c.Assert(err, qt.IsNil)
+	c.Assert(deleted, qt.HasLen, 0)
+
+	// the Censuses used by active processes are kept
+	closedRoot, err := cb.CensusRoot(closedID)
+	c.Assert(err, qt.IsNil)
+	active := true
+	cb.CanDeleteCensus = func(_ types.CensusID, root []byte) error {
+		if active && string(root) == string(closedRoot) {
+			return fmt.Errorf("process not finished")
+		}
+		return nil
+	}
+	now = expiresAt.Add(time.Second)
+	deleted, err = cb.CollectExpiredCensuses()
+	c.Assert(err, qt.IsNil)
+	c.Assert(deleted, qt.DeepEquals, []types.CensusID{openID})
+	_, err = cb.CensusInfo(openID)
+	c.Assert(err, qt.ErrorMatches, ErrCensusDeleted.Error()+".*")
+
+	// once the process is finished, the Census is deleted
+	active = false
+	deleted, err = cb.CollectExpiredCensuses()
+	c.Assert(err, qt.IsNil)
+	c.Assert(deleted, qt.DeepEquals, []types.CensusID{closedID})
+	deleted, err = cb.CollectExpiredCensuses()
+	c.Assert(err, qt.IsNil)
+	c.Assert(deleted, qt.HasLen, 0)
+
+	for _, censusID := range []types.CensusID{keptID, removedID} {
+		_, err = cb.CensusInfo(censusID)
+		c.Assert(err, qt.IsNil)
+	}
+	c.Assert(cb.Close(), qt.IsNil)
+}
+
+func TestGCInterval(t *testing.T) {
+	c := qt.New(t)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		GCInterval: 10 * time.Millisecond,
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensusWithOptions(CensusOptions{
+		ExpiresAt: time.Now().Add(-time.Second)})
+	c.Assert(err, qt.IsNil)
+
+	// the background GC deletes the expired Census
+	c.Assert(func() bool {
+		for i := 0; i < 100; i++ {
+			deleted, err := cb.isDeleted(censusID)
+			c.Assert(err, qt.IsNil)
+			if deleted {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}(), qt.IsTrue)
+	c.Assert(cb.Close(), qt.IsNil)
+}
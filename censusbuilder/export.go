@@ -0,0 +1,111 @@
+package censusbuilder
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// exportCSVHeader is the first row of the CSV files written by ExportKeys
+var exportCSVHeader = []string{"index", "publicKey", "weight", "data"}
+
+// jsonExportEntry is the format of each element of the JSON array written by
+// ExportKeys
+type jsonExportEntry struct {
+	Index     uint64 `json:"index"`
+	PublicKey string `json:"publicKey"`
+	Weight    string `json:"weight"`
+	Data      string `json:"data,omitempty"`
+}
+
+// ExportKeys writes into the given writer all the PublicKeys of the Census of
+// the given censusID in index order, with their index, weight and leaf data,
+// in the given ImportFormat, so the Census MerkleTree can be rebuilt to verify
+// its CensusRoot. The PublicKeys and the leaf data are encoded in hex, and the
+// weights in decimal. The PublicKeys are streamed from the Census db, without
+// loading all of them in memory. The PublicKeys buffered until closing a
+// Census with SortKeys are not exported.
+func (cb *CensusBuilder) ExportKeys(censusID types.CensusID, w io.Writer,
+	format ImportFormat) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	c := cb.getCensus(censusID)
+
+	var n int
+	var err error
+	switch format {
+	case ImportCSV:
+		n, err = exportCSV(c, w)
+	case ImportJSON:
+		n, err = exportJSON(c, w)
+	default:
+		return fmt.Errorf("unknown ImportFormat %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] %d PublicKeys exported as %s", censusID, n, format)
+	return nil
+}
+
+func exportCSV(c *census.Census, w io.Writer) (int, error) {
+	csvW := csv.NewWriter(w)
+	if err := csvW.Write(exportCSVHeader); err != nil {
+		return 0, err
+	}
+	n := 0
+	err := c.IteratePublicKeys(func(key census.IndexedPublicKey) error {
+		pubKComp := key.PublicKey.Compress()
+		n++
+		return csvW.Write([]string{
+			strconv.FormatUint(key.Index, 10), //nolint:gomnd
+			hex.EncodeToString(pubKComp[:]),
+			key.Weight.String(),
+			hex.EncodeToString(key.Data),
+		})
+	})
+	if err != nil {
+		return n, err
+	}
+	csvW.Flush()
+	return n, csvW.Error()
+}
+
+func exportJSON(c *census.Census, w io.Writer) (int, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+	n := 0
+	err := c.IteratePublicKeys(func(key census.IndexedPublicKey) error {
+		pubKComp := key.PublicKey.Compress()
+		b, err := json.Marshal(jsonExportEntry{
+			Index:     key.Index,
+			PublicKey: hex.EncodeToString(pubKComp[:]),
+			Weight:    key.Weight.String(),
+			Data:      hex.EncodeToString(key.Data),
+		})
+		if err != nil {
+			return err
+		}
+		if n != 0 {
+			b = append([]byte(","), b...)
+		}
+		n++
+		_, err = w.Write(append(b, '\n'))
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+	_, err = io.WriteString(w, "]\n")
+	return n, err
+}
@@ -0,0 +1,92 @@
+package censusbuilder
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestExportKeysCSV(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(5)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	members := make([]census.Member, len(keys.PublicKeys))
+	for i := 0; i < len(members); i++ {
+		members[i] = census.Member{PublicKey: keys.PublicKeys[i],
+			Weight: keys.Weights[i]}
+	}
+	members[1].Data = []byte{0x01, 0x02}
+	err = cb.AddMembers(censusID, members)
+	c.Assert(err, qt.IsNil)
+
+	var buf bytes.Buffer
+	err = cb.ExportKeys(censusID, &buf, ImportCSV)
+	c.Assert(err, qt.IsNil)
+	records, err := csv.NewReader(&buf).ReadAll()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(records), qt.Equals, len(members)+1)
+	c.Assert(records[0], qt.DeepEquals, exportCSVHeader)
+	for i := 0; i < len(members); i++ {
+		c.Assert(records[i+1], qt.DeepEquals, []string{
+			strconv.Itoa(i),
+			pubKHex(members[i].PublicKey),
+			members[i].Weight.String(),
+			hex.EncodeToString(members[i].Data),
+		})
+	}
+
+	err = cb.ExportKeys(censusID+1, &buf, ImportCSV)
+	c.Assert(err, qt.ErrorMatches, "CensusID=1 does not exist")
+}
+
+func TestExportKeysJSON(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(20)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+
+	var buf bytes.Buffer
+	err = cb.ExportKeys(censusID, &buf, ImportJSON)
+	c.Assert(err, qt.IsNil)
+	var entries []jsonExportEntry
+	err = json.Unmarshal(buf.Bytes(), &entries)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(entries), qt.Equals, len(keys.PublicKeys))
+	c.Assert(entries[3].Index, qt.Equals, uint64(3))
+	c.Assert(entries[3].PublicKey, qt.Equals, pubKHex(keys.PublicKeys[3]))
+
+	// rebuild the Census from the exported PublicKeys, obtaining the same
+	// CensusRoot
+	rebuiltID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	report, err := cb.ImportKeys(rebuiltID, &buf, ImportJSON)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Added, qt.Equals, uint64(len(keys.PublicKeys)))
+	err = cb.CloseCensus(rebuiltID)
+	c.Assert(err, qt.IsNil)
+	rebuiltRoot, err := cb.CensusRoot(rebuiltID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(rebuiltRoot, qt.DeepEquals, root)
+}
@@ -0,0 +1,58 @@
+package censusbuilder
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// StoreAllProofs generates and stores the CensusProofs of all the PublicKeys
+// of the closed Census of the given censusID, as it is done when closing the
+// Census if the StoreProofs option is enabled, so the Censuses closed before
+// enabling it can also be exported without generating their CensusProofs
+// again
+func (cb *CensusBuilder) StoreAllProofs(censusID types.CensusID) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).StoreAllProofs()
+}
+
+// ExportAllProofs returns the CensusProofs of all the PublicKeys of the closed
+// Census of the given censusID, by compressed PublicKey. The stored
+// CensusProofs are used if available (see StoreAllProofs), otherwise they are
+// generated in a single pass. For big Censuses use WriteAllProofs, which does
+// not keep all the CensusProofs in memory.
+func (cb *CensusBuilder) ExportAllProofs(censusID types.CensusID) (
+	map[babyjub.PublicKeyComp]types.CensusProof, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	proofs := make(map[babyjub.PublicKeyComp]types.CensusProof)
+	err := cb.getCensus(censusID).IterateProofs(func(cp *types.CensusProof) error {
+		proofs[cp.PublicKey.Compress()] = *cp
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proofs, nil
+}
+
+// WriteAllProofs writes into the given io.Writer the CensusProofs of all the
+// PublicKeys of the closed Census of the given censusID, in index order, as
+// newline-delimited JSON, one CensusProof per line
+func (cb *CensusBuilder) WriteAllProofs(censusID types.CensusID, w io.Writer) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	enc := json.NewEncoder(w)
+	return cb.getCensus(censusID).IterateProofs(func(cp *types.CensusProof) error {
+		return enc.Encode(cp)
+	})
+}
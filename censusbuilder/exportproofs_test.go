@@ -0,0 +1,74 @@
+package censusbuilder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestExportAllProofs(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(50)
+
+	cb, err := NewWithOptions(Options{
+		DB:          newTestDB(c),
+		SubDBsPath:  c.TempDir(),
+		StoreProofs: true,
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	// the CensusProofs of an open Census can not be exported
+	_, err = cb.ExportAllProofs(censusID)
+	c.Assert(err, qt.ErrorMatches, census.ErrCensusNotClosed.Error()+".*")
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	err = cb.loadCensusIfNotYet(censusID)
+	c.Assert(err, qt.IsNil)
+	stored, err := cb.getCensus(censusID).ProofsStored()
+	c.Assert(err, qt.IsNil)
+	c.Assert(stored, qt.IsTrue)
+	cb.releaseCensus(censusID)
+
+	proofs, err := cb.ExportAllProofs(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(proofs, qt.HasLen, len(keys.PublicKeys))
+	for i := 0; i < len(keys.PublicKeys); i++ {
+		cp, ok := proofs[keys.PublicKeys[i].Compress()]
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(cp.Index, qt.Equals, uint64(i))
+		v, err := cb.VerifyMembershipProof(censusID, cp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+	}
+
+	var b bytes.Buffer
+	err = cb.WriteAllProofs(censusID, &b)
+	c.Assert(err, qt.IsNil)
+	scanner := bufio.NewScanner(&b)
+	scanner.Buffer(nil, 1024*1024)
+	var index uint64
+	for ; scanner.Scan(); index++ {
+		var cp types.CensusProof
+		err := json.Unmarshal(scanner.Bytes(), &cp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(cp.Index, qt.Equals, index)
+		expected, err := json.Marshal(proofs[cp.PublicKey.Compress()])
+		c.Assert(err, qt.IsNil)
+		c.Assert(scanner.Bytes(), qt.DeepEquals, expected)
+	}
+	c.Assert(scanner.Err(), qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(len(keys.PublicKeys)))
+	c.Assert(cb.Close(), qt.IsNil)
+}
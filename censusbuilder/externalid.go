@@ -0,0 +1,146 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+)
+
+var (
+	// ErrExternalIDExists is used when trying to create a Census with an
+	// ExternalID that is already attached to another Census
+	ErrExternalIDExists = errors.New("ExternalID already used by another Census")
+	// ErrExternalIDNotFound is used when there is no Census with the given
+	// ExternalID
+	ErrExternalIDNotFound = errors.New("ExternalID not found")
+)
+
+var (
+	// dbPrefixExternalID is used to store the mapping ExternalID->CensusID,
+	// which ensures that the ExternalIDs are unique
+	dbPrefixExternalID = []byte("externalID")
+	// dbPrefixCensusExternalID is used to store the mapping
+	// CensusID->ExternalID
+	dbPrefixCensusExternalID = []byte("censusExternalID")
+)
+
+func dbKeyExternalID(externalID string) []byte {
+	return append(append([]byte{}, dbPrefixExternalID...), []byte(externalID)...)
+}
+
+func dbKeyCensusExternalID(censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	return append(append([]byte{}, dbPrefixCensusExternalID...), b...)
+}
+
+// newCensusWithExternalID creates a new Census with the given CensusOptions,
+// attaching to it the ExternalID of the CensusOptions, returning
+// ErrExternalIDExists if it is already attached to another Census, in which
+// case the Census is not created
+func (cb *CensusBuilder) newCensusWithExternalID(opts CensusOptions) (
+	types.CensusID, error) {
+	cb.labelsMu.Lock()
+	defer cb.labelsMu.Unlock()
+
+	rTx := cb.db.ReadTx()
+	b, err := rTx.Get(dbKeyExternalID(opts.ExternalID))
+	rTx.Discard()
+	if err == nil {
+		return 0, fmt.Errorf("%s, ExternalID: %q, CensusID=%d",
+			ErrExternalIDExists, opts.ExternalID,
+			types.CensusID(binary.LittleEndian.Uint64(b)))
+	} else if err != db.ErrKeyNotFound {
+		return 0, err
+	}
+
+	censusID, err := cb.newCensusID(func(censusID types.CensusID) error {
+		return cb.createCensus(censusID, opts)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := cb.setExternalID(censusID, opts.ExternalID); err != nil {
+		return 0, err
+	}
+	return censusID, nil
+}
+
+// setExternalID attaches the given ExternalID to the Census of the given
+// censusID, returning ErrExternalIDExists if it is already attached to another
+// Census. It must be called with the labelsMu locked.
+func (cb *CensusBuilder) setExternalID(censusID types.CensusID, externalID string) error {
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	b, err := wTx.Get(dbKeyExternalID(externalID))
+	if err == nil {
+		return fmt.Errorf("%s, ExternalID: %q, CensusID=%d",
+			ErrExternalIDExists, externalID,
+			types.CensusID(binary.LittleEndian.Uint64(b)))
+	} else if err != db.ErrKeyNotFound {
+		return err
+	}
+	b = make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	if err := wTx.Set(dbKeyExternalID(externalID), b); err != nil {
+		return err
+	}
+	if err := wTx.Set(dbKeyCensusExternalID(censusID), []byte(externalID)); err != nil {
+		return err
+	}
+	if err := wTx.Commit(); err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] ExternalID set to %q", censusID, externalID)
+	return nil
+}
+
+// deleteExternalID removes both mappings of the ExternalID of the Census of
+// the given censusID, if it has one. It must be called with the labelsMu
+// locked.
+func deleteExternalID(wTx db.WriteTx, censusID types.CensusID) error {
+	externalID, err := wTx.Get(dbKeyCensusExternalID(censusID))
+	if err == db.ErrKeyNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := wTx.Delete(dbKeyExternalID(string(externalID))); err != nil {
+		return err
+	}
+	return wTx.Delete(dbKeyCensusExternalID(censusID))
+}
+
+// getExternalID returns the ExternalID of the Census of the given censusID,
+// or an empty string if it has no ExternalID
+func (cb *CensusBuilder) getExternalID(censusID types.CensusID) (string, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	b, err := rTx.Get(dbKeyCensusExternalID(censusID))
+	if err == db.ErrKeyNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// CensusByExternalID returns the censusID of the Census to which the given
+// ExternalID was attached when creating it, or ErrExternalIDNotFound if there
+// is no Census with that ExternalID
+func (cb *CensusBuilder) CensusByExternalID(externalID string) (types.CensusID, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	b, err := rTx.Get(dbKeyExternalID(externalID))
+	if err == db.ErrKeyNotFound {
+		return 0, fmt.Errorf("%s, ExternalID: %q", ErrExternalIDNotFound,
+			externalID)
+	} else if err != nil {
+		return 0, err
+	}
+	return types.CensusID(binary.LittleEndian.Uint64(b)), nil
+}
@@ -0,0 +1,53 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCensusByExternalID(t *testing.T) {
+	c := qt.New(t)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensusWithOptions(CensusOptions{
+		SortKeys:   true,
+		ExternalID: "8d5f2c4e-process",
+	})
+	c.Assert(err, qt.IsNil)
+	otherID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+
+	foundID, err := cb.CensusByExternalID("8d5f2c4e-process")
+	c.Assert(err, qt.IsNil)
+	c.Assert(foundID, qt.Equals, censusID)
+	_, err = cb.CensusByExternalID("unknown")
+	c.Assert(err, qt.ErrorMatches, ErrExternalIDNotFound.Error()+".*")
+
+	ci, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.ExternalID, qt.Equals, "8d5f2c4e-process")
+	ci, err = cb.CensusInfo(otherID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.ExternalID, qt.Equals, "")
+
+	// the ExternalIDs are unique, and the Census is not created
+	_, err = cb.NewCensusWithOptions(CensusOptions{ExternalID: "8d5f2c4e-process"})
+	c.Assert(err, qt.ErrorMatches, ErrExternalIDExists.Error()+".*")
+	nextID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	c.Assert(nextID, qt.Equals, otherID+1)
+
+	// deleting the Census frees its ExternalID
+	c.Assert(cb.DeleteCensus(censusID), qt.IsNil)
+	_, err = cb.CensusByExternalID("8d5f2c4e-process")
+	c.Assert(err, qt.ErrorMatches, ErrExternalIDNotFound.Error()+".*")
+	newID, err := cb.NewCensusWithOptions(CensusOptions{ExternalID: "8d5f2c4e-process"})
+	c.Assert(err, qt.IsNil)
+	foundID, err = cb.CensusByExternalID("8d5f2c4e-process")
+	c.Assert(err, qt.IsNil)
+	c.Assert(foundID, qt.Equals, newID)
+	c.Assert(cb.Close(), qt.IsNil)
+}
@@ -0,0 +1,299 @@
+package censusbuilder
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/log"
+)
+
+// ImportFormat is used to define the format of the files imported with
+// ImportFromFile, and of the PublicKeys exported with ExportKeys
+type ImportFormat int
+
+var (
+	// ImportCSV indicates a CSV file, where each row contains a
+	// PublicKey and, optionally, its weight. The first row can be a
+	// header starting with "publicKey".
+	ImportCSV ImportFormat = 0
+	// ImportJSON indicates a JSON file containing an array of objects
+	// with the fields "publicKey" and, optionally, "weight"
+	ImportJSON ImportFormat = 1
+)
+
+// String returns the name of the ImportFormat
+func (f ImportFormat) String() string {
+	switch f {
+	case ImportCSV:
+		return "csv"
+	case ImportJSON:
+		return "json"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(f))
+	}
+}
+
+// ImportFormatFromPath returns the ImportFormat of the given file path, based
+// on its extension
+func ImportFormatFromPath(path string) (ImportFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ImportCSV, nil
+	case ".json":
+		return ImportJSON, nil
+	default:
+		return 0, fmt.Errorf("unknown import format for file %s, expected"+
+			" a .csv or a .json file", path)
+	}
+}
+
+// InvalidRow contains a row of an imported file that could not be added to the
+// Census, and the reason
+type InvalidRow struct {
+	// Row is the line number of the row for the CSV files, and the
+	// position (starting at 1) of the element in the array for the JSON
+	// files
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportReport contains the result of an ImportFromFile
+type ImportReport struct {
+	// Added is the number of PublicKeys added to the Census
+	Added   uint64       `json:"added"`
+	Invalid []InvalidRow `json:"invalid,omitempty"`
+}
+
+// importEntry contains a PublicKey of an imported file, together with the row
+// where it is placed
+type importEntry struct {
+	row    int
+	pubK   babyjub.PublicKey
+	weight *big.Int
+}
+
+// jsonImportEntry is the format of each element of the imported JSON files.
+// The weight can be a JSON number or a string.
+type jsonImportEntry struct {
+	PublicKey string      `json:"publicKey"`
+	Weight    json.Number `json:"weight"`
+}
+
+// ImportFromFile adds the PublicKeys of the CSV or JSON file at the given path
+// to the Census of the given censusID, see ImportKeys. The format is obtained
+// from the file extension.
+func (cb *CensusBuilder) ImportFromFile(censusID types.CensusID, path string) (
+	*ImportReport, error) {
+	format, err := ImportFormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	return cb.ImportKeys(censusID, f, format)
+}
+
+// ImportKeys reads the PublicKeys (as compressed hex) and their weights from
+// the given reader in the given ImportFormat, and adds them to the Census of
+// the given censusID in batches, without loading the whole file in memory.
+// The PublicKeys without weight get a weight of 1. The rows that are not
+// valid, or that can not be added to the Census (eg. duplicated PublicKeys),
+// are placed in the ImportReport and the import continues. An error is only
+// returned if the file can not be read or the Census can not be updated, in
+// which case the PublicKeys of the previous batches remain added.
+func (cb *CensusBuilder) ImportKeys(censusID types.CensusID, r io.Reader,
+	format ImportFormat) (*ImportReport, error) {
+	report := &ImportReport{}
+	batchSize := cb.chunkSize
+	if batchSize <= 0 {
+		batchSize = census.DefaultChunkSize
+	}
+	var batch []importEntry
+	add := func(e importEntry) error {
+		batch = append(batch, e)
+		if len(batch) < batchSize {
+			return nil
+		}
+		err := cb.importBatch(censusID, batch, report)
+		batch = batch[:0]
+		return err
+	}
+	invalid := func(row int, err error) {
+		report.Invalid = append(report.Invalid, InvalidRow{Row: row, Error: err.Error()})
+	}
+
+	var err error
+	switch format {
+	case ImportCSV:
+		err = readCSVEntries(r, add, invalid)
+	case ImportJSON:
+		err = readJSONEntries(r, add, invalid)
+	default:
+		err = fmt.Errorf("unknown ImportFormat %s", format)
+	}
+	if err != nil {
+		return report, err
+	}
+	if len(batch) != 0 {
+		if err := cb.importBatch(censusID, batch, report); err != nil {
+			return report, err
+		}
+	}
+	log.Debugf("[CensusID=%d] %d PublicKeys imported, %d invalid rows",
+		censusID, report.Added, len(report.Invalid))
+	return report, nil
+}
+
+// importBatch adds the PublicKeys of the given batch to the Census. The
+// PublicKeys that can not be added are placed in the ImportReport, and the
+// rest of the batch is added again without them.
+func (cb *CensusBuilder) importBatch(censusID types.CensusID, batch []importEntry,
+	report *ImportReport) error {
+	for len(batch) != 0 {
+		pubKs := make([]babyjub.PublicKey, len(batch))
+		weights := make([]*big.Int, len(batch))
+		for i := 0; i < len(batch); i++ {
+			pubKs[i] = batch[i].pubK
+			weights[i] = batch[i].weight
+		}
+		invalids, err := cb.addPublicKeys(censusID, pubKs, weights)
+		if err == nil {
+			report.Added += uint64(len(batch))
+			return nil
+		}
+		if len(invalids) == 0 {
+			return err
+		}
+		// the batch fits in a single chunk, so none of its PublicKeys
+		// has been added
+		isInvalid := make(map[int]bool, len(invalids))
+		for i := 0; i < len(invalids); i++ {
+			isInvalid[invalids[i].Index] = true
+			report.Invalid = append(report.Invalid, InvalidRow{
+				Row:   batch[invalids[i].Index].row,
+				Error: fmt.Sprintf("%s: %s", invalids[i].Reason, invalids[i].Error),
+			})
+		}
+		var valid []importEntry
+		for i := 0; i < len(batch); i++ {
+			if !isInvalid[i] {
+				valid = append(valid, batch[i])
+			}
+		}
+		batch = valid
+	}
+	return nil
+}
+
+// parseImportEntry parses the given hex PublicKey and decimal weight of the
+// given row. An empty weight is parsed as 1.
+func parseImportEntry(row int, pubKHex, weightStr string) (importEntry, error) {
+	pubK, err := types.HexToPublicKey(strings.TrimPrefix(strings.TrimSpace(pubKHex), "0x"))
+	if err != nil {
+		return importEntry{}, fmt.Errorf("invalid PublicKey: %s", err)
+	}
+	weight := big.NewInt(1)
+	if weightStr = strings.TrimSpace(weightStr); weightStr != "" {
+		if _, ok := weight.SetString(weightStr, 10); !ok { //nolint:gomnd
+			return importEntry{}, fmt.Errorf("invalid weight %q", weightStr)
+		}
+		if weight.Sign() < 0 {
+			return importEntry{}, fmt.Errorf("negative weight %s", weight)
+		}
+	}
+	return importEntry{row: row, pubK: *pubK, weight: weight}, nil
+}
+
+// readCSVEntries reads the rows of the given CSV, calling add for each valid
+// row, and invalid for each row that can not be parsed
+func readCSVEntries(r io.Reader, add func(importEntry) error,
+	invalid func(int, error)) error {
+	csvR := csv.NewReader(r)
+	csvR.FieldsPerRecord = -1
+	csvR.TrimLeadingSpace = true
+	csvR.Comment = '#'
+	first := true
+	for {
+		record, err := csvR.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			invalid(parseErr.StartLine, parseErr.Err)
+			continue
+		} else if err != nil {
+			return err
+		}
+		row, _ := csvR.FieldPos(0)
+		isHeader := first && strings.EqualFold(strings.TrimSpace(record[0]), "publicKey")
+		first = false
+		if isHeader {
+			continue
+		}
+		if len(record) > 2 { //nolint:gomnd
+			invalid(row, fmt.Errorf("expected at most 2 fields, got %d", len(record)))
+			continue
+		}
+		weight := ""
+		if len(record) == 2 { //nolint:gomnd
+			weight = record[1]
+		}
+		e, err := parseImportEntry(row, record[0], weight)
+		if err != nil {
+			invalid(row, err)
+			continue
+		}
+		if err := add(e); err != nil {
+			return err
+		}
+	}
+}
+
+// readJSONEntries reads the elements of the given JSON array, calling add for
+// each valid element, and invalid for each element that can not be parsed
+func readJSONEntries(r io.Reader, add func(importEntry) error,
+	invalid func(int, error)) error {
+	dec := json.NewDecoder(r)
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '[' {
+		return fmt.Errorf("expected a JSON array of PublicKeys")
+	}
+	for row := 1; dec.More(); row++ {
+		var je jsonImportEntry
+		err := dec.Decode(&je)
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			invalid(row, err)
+			continue
+		} else if err != nil {
+			return err
+		}
+		e, err := parseImportEntry(row, je.PublicKey, je.Weight.String())
+		if err != nil {
+			invalid(row, err)
+			continue
+		}
+		if err := add(e); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
@@ -0,0 +1,126 @@
+package censusbuilder
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func pubKHex(pubK babyjub.PublicKey) string {
+	pubKComp := pubK.Compress()
+	return hex.EncodeToString(pubKComp[:])
+}
+
+func TestImportFormatFromPath(t *testing.T) {
+	c := qt.New(t)
+
+	format, err := ImportFormatFromPath("keys.CSV")
+	c.Assert(err, qt.IsNil)
+	c.Assert(format, qt.Equals, ImportCSV)
+	format, err = ImportFormatFromPath("/tmp/keys.json")
+	c.Assert(err, qt.IsNil)
+	c.Assert(format, qt.Equals, ImportJSON)
+	_, err = ImportFormatFromPath("keys.txt")
+	c.Assert(err, qt.ErrorMatches, "unknown import format.*")
+}
+
+func TestImportCSV(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(5)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		ChunkSize:  2,
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+
+	rows := []string{
+		"publicKey,weight",
+		pubKHex(keys.PublicKeys[0]) + ",10",
+		"0x" + pubKHex(keys.PublicKeys[1]),
+		"zz,1",                              // row 4, invalid hex
+		pubKHex(keys.PublicKeys[2]) + ",-1", // row 5, negative weight
+		"# comment",
+		pubKHex(keys.PublicKeys[3]) + ", 20",
+		pubKHex(keys.PublicKeys[0]) + ",10",      // row 8, duplicated
+		pubKHex(keys.PublicKeys[4]) + ",1,extra", // row 9, too many fields
+		pubKHex(keys.PublicKeys[2]) + ",3",
+	}
+	path := filepath.Join(c.TempDir(), "keys.csv")
+	err = os.WriteFile(path, []byte(strings.Join(rows, "\n")), 0600)
+	c.Assert(err, qt.IsNil)
+
+	report, err := cb.ImportFromFile(censusID, path)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Added, qt.Equals, uint64(4))
+	c.Assert(len(report.Invalid), qt.Equals, 4)
+	var invalidRows []int
+	for _, r := range report.Invalid {
+		invalidRows = append(invalidRows, r.Row)
+	}
+	c.Assert(invalidRows, qt.DeepEquals, []int{4, 5, 8, 9})
+	c.Assert(report.Invalid[2].Error, qt.Matches, "already present.*")
+
+	err = cb.loadCensusIfNotYet(censusID)
+	c.Assert(err, qt.IsNil)
+	added, err := cb.getCensus(censusID).PublicKeys()
+	c.Assert(err, qt.IsNil)
+	cb.releaseCensus(censusID)
+	c.Assert(len(added), qt.Equals, 4)
+	weights := make(map[babyjub.PublicKeyComp]*big.Int)
+	for i := 0; i < len(added); i++ {
+		weights[added[i].PublicKey.Compress()] = added[i].Weight
+	}
+	for i, weight := range []int64{10, 1, 3, 20} {
+		w, ok := weights[keys.PublicKeys[i].Compress()]
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(w.Cmp(big.NewInt(weight)), qt.Equals, 0)
+	}
+}
+
+func TestImportJSON(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(3)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+
+	j := fmt.Sprintf(`[
+		{"publicKey": %q, "weight": 5},
+		{"publicKey": %q, "weight": "7"},
+		{"publicKey": %q, "weight": true},
+		{"publicKey": "0102"},
+		{"publicKey": %q}
+	]`, pubKHex(keys.PublicKeys[0]), pubKHex(keys.PublicKeys[1]),
+		pubKHex(keys.PublicKeys[2]), pubKHex(keys.PublicKeys[2]))
+	report, err := cb.ImportKeys(censusID, strings.NewReader(j), ImportJSON)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Added, qt.Equals, uint64(3))
+	c.Assert(len(report.Invalid), qt.Equals, 2)
+	c.Assert(report.Invalid[0].Row, qt.Equals, 3)
+	c.Assert(report.Invalid[1].Row, qt.Equals, 4)
+	c.Assert(report.Invalid[1].Error, qt.Matches, "invalid PublicKey.*")
+
+	// a malformed JSON aborts the import
+	report, err = cb.ImportKeys(censusID, strings.NewReader(`[{"publicKey": `),
+		ImportJSON)
+	c.Assert(err, qt.Not(qt.IsNil))
+	c.Assert(report.Added, qt.Equals, uint64(0))
+	_, err = cb.ImportKeys(censusID, strings.NewReader(`{}`), ImportJSON)
+	c.Assert(err, qt.ErrorMatches, "expected a JSON array of PublicKeys")
+}
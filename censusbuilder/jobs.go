@@ -0,0 +1,182 @@
+package censusbuilder
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/log"
+)
+
+const (
+	// DefaultWorkers defines the default number of workers that process
+	// the jobs enqueued with EnqueueAddPublicKeys
+	DefaultWorkers = 4
+	// DefaultQueueSize defines the default maximum number of jobs waiting
+	// to be processed
+	DefaultQueueSize = 100
+)
+
+var (
+	// ErrJobQueueFull is used when trying to enqueue a job and the queue
+	// has reached its maximum size
+	ErrJobQueueFull = errors.New("Job queue full, try again later")
+	// ErrCensusBuilderClosed is used when trying to enqueue a job after
+	// the CensusBuilder has been closed
+	ErrCensusBuilderClosed = errors.New("CensusBuilder closed")
+)
+
+// JobState is used to define the state of a job
+type JobState int
+
+var (
+	// JobPending indicates that the job is in the queue, waiting to be
+	// processed
+	JobPending JobState = 0
+	// JobRunning indicates that the job is being processed
+	JobRunning JobState = 1
+	// JobDone indicates that the job has been successfully processed
+	JobDone JobState = 2
+	// JobError indicates that the job has been processed with an error
+	JobError JobState = 3
+)
+
+// JobStatus contains the status of a job enqueued with EnqueueAddPublicKeys
+type JobStatus struct {
+	ID       uint64         `json:"id"`
+	CensusID types.CensusID `json:"censusID"`
+	State    JobState       `json:"state"`
+	// Error contains the error message of the job when State is JobError
+	Error string `json:"error,omitempty"`
+}
+
+// addPublicKeysJob contains the data of a job enqueued with
+// EnqueueAddPublicKeys
+type addPublicKeysJob struct {
+	id       uint64
+	censusID types.CensusID
+	pubKs    []babyjub.PublicKey
+	weights  []*big.Int
+}
+
+// startWorkers starts the given number of workers that process the jobs of
+// the queue until it is closed
+func (cb *CensusBuilder) startWorkers(nWorkers int) {
+	for i := 0; i < nWorkers; i++ {
+		cb.workersWg.Add(1)
+		go func() {
+			defer cb.workersWg.Done()
+			for job := range cb.jobs {
+				cb.runAddPublicKeysJob(job)
+			}
+		}()
+	}
+}
+
+func (cb *CensusBuilder) setJobStatus(jobID uint64, state JobState, err error) {
+	cb.jobsMu.Lock()
+	defer cb.jobsMu.Unlock()
+	status := cb.jobStatuses[jobID]
+	status.State = state
+	if err != nil {
+		status.Error = err.Error()
+	}
+}
+
+func (cb *CensusBuilder) runAddPublicKeysJob(job addPublicKeysJob) {
+	cb.setJobStatus(job.id, JobRunning, nil)
+	err := cb.AddPublicKeys(job.censusID, job.pubKs, job.weights)
+	if err != nil {
+		log.Debugf("[CensusID=%d] job %d error: %s", job.censusID, job.id, err)
+		if err2 := cb.SetErrMsg(job.censusID, err.Error()); err2 != nil {
+			log.Errorf("Error while trying to store CensusID:%d status: %s. Error: %s",
+				job.censusID, err, err2)
+		}
+		cb.setJobStatus(job.id, JobError, err)
+		return
+	}
+	cb.setJobStatus(job.id, JobDone, nil)
+}
+
+// EnqueueAddPublicKeys enqueues a job that adds the given PublicKeys to the
+// Census of the given censusID, which is processed by the CensusBuilder
+// workers. Returns the ID of the job, which can be used to get its status
+// with JobStatus. If the queue is full, ErrJobQueueFull is returned and the
+// job is not enqueued. As with AddPublicKeysAndStoreError, if the job fails,
+// the error is also stored as the Census ErrMsg.
+func (cb *CensusBuilder) EnqueueAddPublicKeys(censusID types.CensusID,
+	pubKs []babyjub.PublicKey, weights []*big.Int) (uint64, error) {
+	cb.jobsMu.Lock()
+	defer cb.jobsMu.Unlock()
+	if cb.closed {
+		return 0, ErrCensusBuilderClosed
+	}
+
+	job := addPublicKeysJob{
+		id:       cb.nextJobID,
+		censusID: censusID,
+		pubKs:    pubKs,
+		weights:  weights,
+	}
+	select {
+	case cb.jobs <- job:
+	default:
+		return 0, ErrJobQueueFull
+	}
+	cb.jobStatuses[job.id] = &JobStatus{
+		ID:       job.id,
+		CensusID: censusID,
+		State:    JobPending,
+	}
+	cb.nextJobID++
+	log.Debugf("[CensusID=%d] job %d enqueued, adding %d PublicKeys",
+		censusID, job.id, len(pubKs))
+	return job.id, nil
+}
+
+// JobStatus returns the status of the job of the given jobID
+func (cb *CensusBuilder) JobStatus(jobID uint64) (*JobStatus, error) {
+	cb.jobsMu.Lock()
+	defer cb.jobsMu.Unlock()
+	status, ok := cb.jobStatuses[jobID]
+	if !ok {
+		return nil, fmt.Errorf("job %d does not exist", jobID)
+	}
+	s := *status
+	return &s, nil
+}
+
+// Close stops accepting new jobs and batches of AddPublicKeysAndStoreError,
+// waits until all the enqueued ones have been processed, deletes the ephemeral
+// Censuses and closes the databases of the loaded Censuses. The CensusBuilder can not be used after
+// calling this method.
+func (cb *CensusBuilder) Close() error {
+	cb.jobsMu.Lock()
+	if cb.closed {
+		cb.jobsMu.Unlock()
+		return ErrCensusBuilderClosed
+	}
+	cb.closed = true
+	close(cb.jobs)
+	cb.jobsMu.Unlock()
+	cb.workersWg.Wait()
+	cb.closeCensusQueues()
+	cb.stopIdleEviction()
+	cb.stopCompaction()
+	cb.stopGC()
+	if err := cb.deleteEphemeralCensuses(); err != nil {
+		return err
+	}
+
+	cb.censusesMu.Lock()
+	defer cb.censusesMu.Unlock()
+	for censusID, c := range cb.censuses {
+		if err := c.CloseDB(); err != nil {
+			return fmt.Errorf("can not close CensusID=%d db: %s", censusID, err)
+		}
+		delete(cb.censuses, censusID)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package censusbuilder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestEnqueueAddPublicKeys(t *testing.T) {
+	c := qt.New(t)
+
+	nCensuses := 5
+	nKeys := 100
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		Workers:    2,
+		QueueSize:  nCensuses * 2,
+	})
+	c.Assert(err, qt.IsNil)
+
+	// enqueue two jobs for each Census
+	var censusIDs []types.CensusID
+	var jobIDs []uint64
+	for i := 0; i < nCensuses; i++ {
+		censusID, err := cb.NewCensus()
+		c.Assert(err, qt.IsNil)
+		censusIDs = append(censusIDs, censusID)
+		for j := 0; j < 2; j++ {
+			from, to := j*nKeys/2, (j+1)*nKeys/2
+			jobID, err := cb.EnqueueAddPublicKeys(censusID,
+				keys.PublicKeys[from:to], keys.Weights[from:to])
+			c.Assert(err, qt.IsNil)
+			jobIDs = append(jobIDs, jobID)
+		}
+	}
+	// a job for a Census that does not exist
+	errJobID, err := cb.EnqueueAddPublicKeys(1000, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	_, err = cb.JobStatus(errJobID + 1)
+	c.Assert(err, qt.ErrorMatches, "job .* does not exist")
+
+	// expect the queue to be drained on Close
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+	_, err = cb.EnqueueAddPublicKeys(censusIDs[0], keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.Equals, ErrCensusBuilderClosed)
+
+	for i := 0; i < len(jobIDs); i++ {
+		status, err := cb.JobStatus(jobIDs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(status.ID, qt.Equals, jobIDs[i])
+		c.Assert(status.CensusID, qt.Equals, censusIDs[i/2])
+		c.Assert(status.State, qt.Equals, JobDone)
+		c.Assert(status.Error, qt.Equals, "")
+	}
+	status, err := cb.JobStatus(errJobID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(status.State, qt.Equals, JobError)
+	c.Assert(status.Error, qt.Equals, "CensusID=1000 does not exist")
+
+	// load again the CensusBuilder, and expect all the keys added
+	cb, err = New(cb.db, cb.subDBsPath)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < nCensuses; i++ {
+		ci, err := cb.CensusInfo(censusIDs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(ci.Size, qt.Equals, uint64(nKeys))
+		c.Assert(ci.State, qt.Equals, census.StateBuilding)
+	}
+}
+
+func TestEnqueueQueueFull(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		Workers:    1,
+		QueueSize:  1,
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+
+	// block the worker with a job that waits to load the Census, so the
+	// next enqueued jobs are not processed
+	cb.censusesMu.Lock()
+	_, err = cb.EnqueueAddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	for len(cb.jobs) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// the next job fills the queue
+	_, err = cb.EnqueueAddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	_, err = cb.EnqueueAddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.Equals, ErrJobQueueFull)
+	cb.censusesMu.Unlock()
+
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+}
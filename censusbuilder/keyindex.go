@@ -0,0 +1,133 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/log"
+)
+
+// dbPrefixKeyIndex is used to store the reverse index PublicKey->CensusIDs,
+// where each entry key is dbPrefixKeyIndex | compressed PublicKey | CensusID,
+// with the CensusID in big-endian so the entries are sorted by CensusID
+var dbPrefixKeyIndex = []byte("keyIndex")
+
+func dbPrefixKeyIndexPubK(pubKComp babyjub.PublicKeyComp) []byte {
+	return append(append([]byte{}, dbPrefixKeyIndex...), pubKComp[:]...)
+}
+
+func dbKeyKeyIndex(pubKComp babyjub.PublicKeyComp, censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(censusID))
+	return append(dbPrefixKeyIndexPubK(pubKComp), b...)
+}
+
+// indexKeys adds the given PublicKeys of the Census of the given censusID to
+// the KeyIndex. If check is set, only the PublicKeys that are in the Census
+// are indexed.
+func (cb *CensusBuilder) indexKeys(censusID types.CensusID, pubKs []babyjub.PublicKey,
+	check bool) error {
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	for i := 0; i < len(pubKs); i++ {
+		if check {
+			ok, err := cb.getCensus(censusID).HasPublicKey(&pubKs[i])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		if err := wTx.Set(dbKeyKeyIndex(pubKs[i].Compress(), censusID),
+			[]byte{1}); err != nil {
+			return err
+		}
+	}
+	return wTx.Commit()
+}
+
+// indexCensusKeys adds all the PublicKeys of the loaded Census of the given
+// censusID to the KeyIndex, if it is enabled, which is used for the Censuses
+// whose sub-db is copied instead of built through AddPublicKeys
+func (cb *CensusBuilder) indexCensusKeys(censusID types.CensusID) error {
+	if !cb.keyIndex {
+		return nil
+	}
+	c := cb.getCensus(censusID)
+	var pubKs []babyjub.PublicKey
+	err := c.IterateLeaves(func(_ uint64, pubK babyjub.PublicKey) error {
+		pubKs = append(pubKs, pubK)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	pending, err := c.PendingPublicKeys()
+	if err != nil {
+		return err
+	}
+	return cb.indexKeys(censusID, append(pubKs, pending...), false)
+}
+
+// unindexKeys removes the given PublicKeys of the Census of the given censusID
+// from the KeyIndex
+func (cb *CensusBuilder) unindexKeys(censusID types.CensusID, pubKs []babyjub.PublicKey) error {
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	for i := 0; i < len(pubKs); i++ {
+		if err := wTx.Delete(dbKeyKeyIndex(pubKs[i].Compress(), censusID)); err != nil {
+			return err
+		}
+	}
+	return wTx.Commit()
+}
+
+// FindCensusesForKey returns the censusIDs of the Censuses that contain the
+// given PublicKey, sorted by censusID. If the KeyIndex is enabled, the
+// censusIDs are read from it, otherwise each Census is loaded and checked,
+// which is slower the more Censuses there are. Archived Censuses are not
+// checked when the KeyIndex is not enabled, and deleted Censuses are never
+// returned.
+func (cb *CensusBuilder) FindCensusesForKey(pubK babyjub.PublicKey) ([]types.CensusID, error) {
+	if cb.keyIndex {
+		var censusIDs []types.CensusID
+		err := cb.db.Iterate(dbPrefixKeyIndexPubK(pubK.Compress()),
+			func(k, _ []byte) bool {
+				censusIDs = append(censusIDs,
+					types.CensusID(binary.BigEndian.Uint64(k)))
+				return true
+			})
+		if err != nil {
+			return nil, err
+		}
+		return censusIDs, nil
+	}
+
+	rTx := cb.db.ReadTx()
+	nextCensusID, err := cb.getNextCensusID(rTx)
+	rTx.Discard()
+	if err != nil {
+		return nil, err
+	}
+	var censusIDs []types.CensusID
+	for censusID := types.CensusID(0); censusID < nextCensusID; censusID++ {
+		if err := cb.loadCensusIfNotYet(censusID); err == ErrCensusArchived ||
+			err == ErrCensusDeleted {
+			continue
+		} else if err != nil {
+			log.Warnf("[CensusID=%d] can not be loaded: %s", censusID, err)
+			continue
+		}
+		ok, err := cb.getCensus(censusID).HasPublicKey(&pubK)
+		cb.releaseCensus(censusID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			censusIDs = append(censusIDs, censusID)
+		}
+	}
+	return censusIDs, nil
+}
@@ -0,0 +1,61 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestFindCensusesForKey(t *testing.T) {
+	for _, keyIndex := range []bool{false, true} {
+		c := qt.New(t)
+
+		keys := test.GenUserKeys(10)
+
+		cb, err := NewWithOptions(Options{
+			DB:         newTestDB(c),
+			SubDBsPath: c.TempDir(),
+			KeyIndex:   keyIndex,
+		})
+		c.Assert(err, qt.IsNil)
+
+		// census 0 contains keys [0,5), census 1 contains keys [3,8),
+		// and census 2 contains the key 9 at an explicit index
+		censusID0, err := cb.NewCensus()
+		c.Assert(err, qt.IsNil)
+		err = cb.AddPublicKeys(censusID0, keys.PublicKeys[:5], keys.Weights[:5])
+		c.Assert(err, qt.IsNil)
+		censusID1, err := cb.NewCensus()
+		c.Assert(err, qt.IsNil)
+		err = cb.AddPublicKeys(censusID1, keys.PublicKeys[3:8], keys.Weights[3:8])
+		c.Assert(err, qt.IsNil)
+		censusID2, err := cb.NewCensus()
+		c.Assert(err, qt.IsNil)
+		err = cb.AddPublicKeysAtIndices(censusID2, []census.IndexedPublicKey{
+			{Index: 42, PublicKey: keys.PublicKeys[9]},
+		})
+		c.Assert(err, qt.IsNil)
+
+		find := func(i int) []types.CensusID {
+			censusIDs, err := cb.FindCensusesForKey(keys.PublicKeys[i])
+			c.Assert(err, qt.IsNil)
+			return censusIDs
+		}
+		c.Assert(find(0), qt.DeepEquals, []types.CensusID{censusID0})
+		c.Assert(find(4), qt.DeepEquals, []types.CensusID{censusID0, censusID1})
+		c.Assert(find(7), qt.DeepEquals, []types.CensusID{censusID1})
+		c.Assert(len(find(8)), qt.Equals, 0)
+		c.Assert(find(9), qt.DeepEquals, []types.CensusID{censusID2})
+
+		// a failed AddPublicKeys does not index the keys that have not
+		// been added
+		err = cb.CloseCensus(censusID2)
+		c.Assert(err, qt.IsNil)
+		err = cb.AddPublicKeys(censusID2, keys.PublicKeys[8:9], keys.Weights[8:9])
+		c.Assert(err, qt.Not(qt.IsNil))
+		c.Assert(len(find(8)), qt.Equals, 0)
+	}
+}
@@ -0,0 +1,160 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+)
+
+var (
+	// ErrLabelExists is used when trying to set a Label that is already
+	// used by another Census
+	ErrLabelExists = errors.New("Label already used by another Census")
+	// ErrLabelNotFound is used when there is no Census with the given
+	// Label
+	ErrLabelNotFound = errors.New("Label not found")
+)
+
+var (
+	// dbPrefixLabel is used to store the mapping Label->CensusID, which
+	// ensures that the Labels are unique
+	dbPrefixLabel = []byte("label")
+	// dbPrefixCensusLabel is used to store the mapping CensusID->Label
+	dbPrefixCensusLabel = []byte("censusLabel")
+)
+
+func dbKeyLabel(label string) []byte {
+	return append(append([]byte{}, dbPrefixLabel...), []byte(label)...)
+}
+
+func dbKeyCensusLabel(censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	return append(append([]byte{}, dbPrefixCensusLabel...), b...)
+}
+
+// NewCensusWithLabel creates a new Census with the given Label, returning
+// ErrLabelExists if the Label is already used, in which case the Census is
+// not created
+func (cb *CensusBuilder) NewCensusWithLabel(label string) (types.CensusID, error) {
+	if label == "" {
+		return 0, fmt.Errorf("can not create a Census with an empty Label")
+	}
+	cb.labelsMu.Lock()
+	defer cb.labelsMu.Unlock()
+
+	rTx := cb.db.ReadTx()
+	_, err := rTx.Get(dbKeyLabel(label))
+	rTx.Discard()
+	if err == nil {
+		return 0, fmt.Errorf("%s, Label: %q", ErrLabelExists, label)
+	} else if err != db.ErrKeyNotFound {
+		return 0, err
+	}
+
+	censusID, err := cb.NewCensus()
+	if err != nil {
+		return 0, err
+	}
+	if err := cb.setLabel(censusID, label); err != nil {
+		return 0, err
+	}
+	return censusID, nil
+}
+
+// SetLabel sets the given Label to the Census of the given censusID,
+// replacing its previous Label. The Labels are unique, if the Label is
+// already used by another Census, ErrLabelExists is returned. An empty Label
+// removes the Label of the Census.
+func (cb *CensusBuilder) SetLabel(censusID types.CensusID, label string) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	cb.labelsMu.Lock()
+	defer cb.labelsMu.Unlock()
+	return cb.setLabel(censusID, label)
+}
+
+// setLabel sets the given Label to the Census of the given censusID, both
+// mappings are updated in a single db.WriteTx. It must be called with the
+// labelsMu locked.
+func (cb *CensusBuilder) setLabel(censusID types.CensusID, label string) error {
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+
+	if label != "" {
+		b, err := wTx.Get(dbKeyLabel(label))
+		if err == nil {
+			labelCensusID := types.CensusID(binary.LittleEndian.Uint64(b))
+			if labelCensusID == censusID {
+				return nil
+			}
+			return fmt.Errorf("%s, Label: %q, CensusID=%d", ErrLabelExists,
+				label, labelCensusID)
+		} else if err != db.ErrKeyNotFound {
+			return err
+		}
+	}
+
+	// remove the previous Label of the Census
+	prev, err := wTx.Get(dbKeyCensusLabel(censusID))
+	if err == nil {
+		if err := wTx.Delete(dbKeyLabel(string(prev))); err != nil {
+			return err
+		}
+		if err := wTx.Delete(dbKeyCensusLabel(censusID)); err != nil {
+			return err
+		}
+	} else if err != db.ErrKeyNotFound {
+		return err
+	}
+
+	if label != "" {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, uint64(censusID))
+		if err := wTx.Set(dbKeyLabel(label), b); err != nil {
+			return err
+		}
+		if err := wTx.Set(dbKeyCensusLabel(censusID), []byte(label)); err != nil {
+			return err
+		}
+	}
+	if err := wTx.Commit(); err != nil {
+		return err
+	}
+	log.Debugf("[CensusID=%d] Label set to %q", censusID, label)
+	return nil
+}
+
+// getLabel returns the Label of the Census of the given censusID, or an
+// empty string if it has no Label
+func (cb *CensusBuilder) getLabel(censusID types.CensusID) (string, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	b, err := rTx.Get(dbKeyCensusLabel(censusID))
+	if err == db.ErrKeyNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// FindCensusByLabel returns the censusID of the Census with the given Label,
+// or ErrLabelNotFound if there is no Census with that Label
+func (cb *CensusBuilder) FindCensusByLabel(label string) (types.CensusID, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	b, err := rTx.Get(dbKeyLabel(label))
+	if err == db.ErrKeyNotFound {
+		return 0, fmt.Errorf("%s, Label: %q", ErrLabelNotFound, label)
+	} else if err != nil {
+		return 0, err
+	}
+	return types.CensusID(binary.LittleEndian.Uint64(b)), nil
+}
@@ -0,0 +1,87 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestLabel(t *testing.T) {
+	c := qt.New(t)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID0, err := cb.NewCensusWithLabel("2024-board-election")
+	c.Assert(err, qt.IsNil)
+	censusID1, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+
+	info, err := cb.CensusInfo(censusID0)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Label, qt.Equals, "2024-board-election")
+	info, err = cb.CensusInfo(censusID1)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Label, qt.Equals, "")
+
+	// lookup
+	censusID, err := cb.FindCensusByLabel("2024-board-election")
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusID, qt.Equals, censusID0)
+	_, err = cb.FindCensusByLabel("2025-board-election")
+	c.Assert(err, qt.ErrorMatches, ErrLabelNotFound.Error()+".*")
+
+	// duplicate rejection
+	_, err = cb.NewCensusWithLabel("2024-board-election")
+	c.Assert(err, qt.ErrorMatches, ErrLabelExists.Error()+".*")
+	err = cb.SetLabel(censusID1, "2024-board-election")
+	c.Assert(err, qt.ErrorMatches, ErrLabelExists.Error()+".*")
+	// no Census has been created by the rejected NewCensusWithLabel
+	infos, err := cb.RecentCensuses(10)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(infos), qt.Equals, 2)
+	// setting the same Label again to the same Census is allowed
+	err = cb.SetLabel(censusID0, "2024-board-election")
+	c.Assert(err, qt.IsNil)
+
+	// set and replace
+	err = cb.SetLabel(censusID1, "treasury")
+	c.Assert(err, qt.IsNil)
+	err = cb.SetLabel(censusID0, "2024-board-election-final")
+	c.Assert(err, qt.IsNil)
+	info, err = cb.CensusInfo(censusID0)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Label, qt.Equals, "2024-board-election-final")
+	censusID, err = cb.FindCensusByLabel("2024-board-election-final")
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusID, qt.Equals, censusID0)
+	// the previous Label is released
+	_, err = cb.FindCensusByLabel("2024-board-election")
+	c.Assert(err, qt.ErrorMatches, ErrLabelNotFound.Error()+".*")
+	err = cb.SetLabel(censusID1, "2024-board-election")
+	c.Assert(err, qt.IsNil)
+	_, err = cb.FindCensusByLabel("treasury")
+	c.Assert(err, qt.ErrorMatches, ErrLabelNotFound.Error()+".*")
+
+	// an empty Label removes the Label
+	err = cb.SetLabel(censusID1, "")
+	c.Assert(err, qt.IsNil)
+	info, err = cb.CensusInfo(censusID1)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Label, qt.Equals, "")
+	_, err = cb.FindCensusByLabel("2024-board-election")
+	c.Assert(err, qt.ErrorMatches, ErrLabelNotFound.Error()+".*")
+	_, err = cb.NewCensusWithLabel("")
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	// the Label is kept for archived Censuses
+	err = cb.ArchiveCensus(censusID0, c.TempDir())
+	c.Assert(err, qt.IsNil)
+	info, err = cb.CensusInfo(censusID0)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Label, qt.Equals, "2024-board-election-final")
+
+	err = cb.SetLabel(types.CensusID(42), "unknown")
+	c.Assert(err, qt.ErrorMatches, "CensusID=42 does not exist")
+}
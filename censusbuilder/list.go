@@ -0,0 +1,122 @@
+package censusbuilder
+
+import (
+	"fmt"
+
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/db"
+)
+
+// CensusStatus is used to filter the Censuses returned by ListCensuses
+type CensusStatus int
+
+var (
+	// CensusStatusAny matches all the Censuses
+	CensusStatusAny CensusStatus = 0
+	// CensusStatusOpen matches the Censuses that are not closed yet
+	CensusStatusOpen CensusStatus = 1
+	// CensusStatusClosed matches the closed Censuses, including the
+	// archived ones
+	CensusStatusClosed CensusStatus = 2
+)
+
+// String returns the name of the CensusStatus
+func (s CensusStatus) String() string {
+	switch s {
+	case CensusStatusAny:
+		return "any"
+	case CensusStatusOpen:
+		return "open"
+	case CensusStatusClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// ParseCensusStatus returns the CensusStatus for the given name, where an
+// empty name is CensusStatusAny
+func ParseCensusStatus(s string) (CensusStatus, error) {
+	switch s {
+	case "", "any":
+		return CensusStatusAny, nil
+	case "open":
+		return CensusStatusOpen, nil
+	case "closed":
+		return CensusStatusClosed, nil
+	default:
+		return 0, fmt.Errorf("unknown CensusStatus %q", s)
+	}
+}
+
+func (s CensusStatus) matches(closed bool) bool {
+	switch s {
+	case CensusStatusOpen:
+		return !closed
+	case CensusStatusClosed:
+		return closed
+	default:
+		return true
+	}
+}
+
+// CensusSummary contains the summary of a Census returned by ListCensuses
+type CensusSummary struct {
+	ID     types.CensusID `json:"id"`
+	Closed bool           `json:"closed"`
+	Size   uint64         `json:"size"`
+	// Root contains the CensusRoot, only when the Census is closed
+	Root     types.ByteArray `json:"root,omitempty"`
+	Archived bool            `json:"archived,omitempty"`
+}
+
+// ListCensuses returns the CensusSummary of the Censuses of the CensusBuilder
+// that match the given CensusStatus, sorted by censusID. As in Stats, the
+// archived Censuses are read from their stored census.Info without restoring
+// them. The deleted Censuses are not returned.
+func (cb *CensusBuilder) ListCensuses(status CensusStatus) ([]CensusSummary, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	nextCensusID, err := cb.getNextCensusID(rTx)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []CensusSummary
+	for censusID := types.CensusID(0); censusID < nextCensusID; censusID++ {
+		a, err := cb.getArchived(rTx, censusID)
+		if err == nil {
+			if status.matches(true) {
+				summaries = append(summaries, CensusSummary{
+					ID:       censusID,
+					Closed:   true,
+					Size:     a.Info.Size,
+					Root:     a.Info.Root,
+					Archived: true,
+				})
+			}
+			continue
+		} else if err != db.ErrKeyNotFound {
+			return nil, err
+		}
+
+		size, isClosed, err := cb.sizeAndClosed(censusID)
+		if err == ErrCensusDeleted {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !status.matches(isClosed) {
+			continue
+		}
+		summary := CensusSummary{ID: censusID, Closed: isClosed, Size: size}
+		if isClosed {
+			summary.Root, err = cb.CensusRoot(censusID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,85 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParseCensusStatus(t *testing.T) {
+	c := qt.New(t)
+
+	for _, s := range []CensusStatus{CensusStatusAny, CensusStatusOpen,
+		CensusStatusClosed} {
+		parsed, err := ParseCensusStatus(s.String())
+		c.Assert(err, qt.IsNil)
+		c.Assert(parsed, qt.Equals, s)
+	}
+	status, err := ParseCensusStatus("")
+	c.Assert(err, qt.IsNil)
+	c.Assert(status, qt.Equals, CensusStatusAny)
+	_, err = ParseCensusStatus("published")
+	c.Assert(err, qt.ErrorMatches, "unknown CensusStatus \"published\"")
+}
+
+func TestListCensuses(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	summaries, err := cb.ListCensuses(CensusStatusAny)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(summaries), qt.Equals, 0)
+
+	// an open Census with 3 keys
+	openID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(openID, keys.PublicKeys[:3], keys.Weights[:3])
+	c.Assert(err, qt.IsNil)
+
+	// a closed Census with 5 keys
+	closedID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(closedID, keys.PublicKeys[3:8], keys.Weights[3:8])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(closedID)
+	c.Assert(err, qt.IsNil)
+	closedRoot, err := cb.CensusRoot(closedID)
+	c.Assert(err, qt.IsNil)
+
+	// an archived Census with 2 keys
+	archivedID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(archivedID, keys.PublicKeys[8:], keys.Weights[8:])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(archivedID)
+	c.Assert(err, qt.IsNil)
+	archivedRoot, err := cb.CensusRoot(archivedID)
+	c.Assert(err, qt.IsNil)
+	err = cb.ArchiveCensus(archivedID, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	summaries, err = cb.ListCensuses(CensusStatusAny)
+	c.Assert(err, qt.IsNil)
+	c.Assert(summaries, qt.DeepEquals, []CensusSummary{
+		{ID: openID, Closed: false, Size: 3},
+		{ID: closedID, Closed: true, Size: 5, Root: closedRoot},
+		{ID: archivedID, Closed: true, Size: 2, Root: archivedRoot,
+			Archived: true},
+	})
+
+	summaries, err = cb.ListCensuses(CensusStatusOpen)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(summaries), qt.Equals, 1)
+	c.Assert(summaries[0].ID, qt.Equals, openID)
+
+	summaries, err = cb.ListCensuses(CensusStatusClosed)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(summaries), qt.Equals, 2)
+	c.Assert(summaries[0].ID, qt.Equals, closedID)
+	c.Assert(summaries[1].ID, qt.Equals, archivedID)
+}
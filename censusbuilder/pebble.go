@@ -0,0 +1,214 @@
+package censusbuilder
+
+import (
+	"errors"
+	"os"
+
+	"github.com/cockroachdb/pebble"
+	"go.vocdoni.io/dvote/db"
+)
+
+const (
+	// DefaultPebbleCacheSize defines the default size in bytes of the
+	// block cache of each Census sub-db
+	DefaultPebbleCacheSize int64 = 8 << 20 // 8 MB
+	// DefaultPebbleMemTableSize defines the default size in bytes of each
+	// MemTable (write buffer) of each Census sub-db. It is bigger than the
+	// pebble default, to flush less often while importing PublicKeys.
+	DefaultPebbleMemTableSize = 8 << 20 // 8 MB
+	// DefaultPebbleMaxOpenFiles defines the default maximum number of
+	// files opened by each Census sub-db. It is much smaller than the
+	// pebble default, as up to MaxLoadedCensuses sub-dbs are open at the
+	// same time.
+	DefaultPebbleMaxOpenFiles = 64
+)
+
+// PebbleOptions contains the tuning parameters applied to each Census sub-db.
+// The memory is reserved for each open Census, so a CensusBuilder with N
+// loaded Censuses uses up to N * (CacheSize + 2 * MemTableSize) bytes, as
+// pebble keeps up to 2 MemTables (the one being written and the one being
+// flushed) before stalling the writes.
+type PebbleOptions struct {
+	// CacheSize defines the size in bytes of the block cache. If not
+	// set, DefaultPebbleCacheSize is used.
+	CacheSize int64
+	// MemTableSize defines the size in bytes of each MemTable. If not
+	// set, DefaultPebbleMemTableSize is used.
+	MemTableSize int
+	// MaxOpenFiles defines the maximum number of open files. If not set,
+	// DefaultPebbleMaxOpenFiles is used.
+	MaxOpenFiles int
+}
+
+// withDefaults returns the PebbleOptions with the default values set for the
+// parameters that are not set
+func (o PebbleOptions) withDefaults() PebbleOptions {
+	if o.CacheSize <= 0 {
+		o.CacheSize = DefaultPebbleCacheSize
+	}
+	if o.MemTableSize <= 0 {
+		o.MemTableSize = DefaultPebbleMemTableSize
+	}
+	if o.MaxOpenFiles <= 0 {
+		o.MaxOpenFiles = DefaultPebbleMaxOpenFiles
+	}
+	return o
+}
+
+// openSubDB is used to open the Census sub-dbs, it is a variable so the tests
+// can check the PebbleOptions used
+var openSubDB = newPebbleDB
+
+// pebbleDB implements the db.Database interface as the
+// go.vocdoni.io/dvote/db/pebbledb package does, but allowing to tune the
+// pebble.Options, which pebbledb.New does not expose
+type pebbleDB struct {
+	db *pebble.DB
+}
+
+// check that pebbleDB implements the db.Database interface
+var _ db.Database = (*pebbleDB)(nil)
+
+// newPebbleDB opens the pebble db at the given path with the given
+// PebbleOptions
+func newPebbleDB(path string, opts PebbleOptions) (db.Database, error) {
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+	cache := pebble.NewCache(opts.CacheSize)
+	// pebble.Open takes its own reference of the cache
+	defer cache.Unref()
+	o := &pebble.Options{
+		Cache:        cache,
+		MemTableSize: opts.MemTableSize,
+		MaxOpenFiles: opts.MaxOpenFiles,
+	}
+	pdb, err := pebble.Open(path, o)
+	if err != nil {
+		return nil, err
+	}
+	return &pebbleDB{db: pdb}, nil
+}
+
+// ReadTx implements the db.Database.ReadTx interface method
+func (d *pebbleDB) ReadTx() db.ReadTx {
+	return pebbleWriteTx{batch: d.db.NewIndexedBatch()}
+}
+
+// WriteTx implements the db.Database.WriteTx interface method
+func (d *pebbleDB) WriteTx() db.WriteTx {
+	return pebbleWriteTx{batch: d.db.NewIndexedBatch()}
+}
+
+// Close implements the db.Database.Close interface method
+func (d *pebbleDB) Close() error {
+	return d.db.Close()
+}
+
+// Compact compacts the whole key range of the db, removing the overwritten
+// and deleted entries from disk
+func (d *pebbleDB) Compact() (err error) {
+	iter := d.db.NewIter(nil)
+	if !iter.First() {
+		// the db is empty
+		return iter.Close()
+	}
+	start := append([]byte{}, iter.Key()...)
+	iter.Last()
+	// the end of the range is exclusive
+	end := append(append([]byte{}, iter.Key()...), 0)
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	return d.db.Compact(start, end)
+}
+
+// DiskSize returns the size in bytes used on disk by the db, including its
+// WAL and the obsolete files not removed yet
+func (d *pebbleDB) DiskSize() (int64, error) {
+	return int64(d.db.Metrics().DiskSpaceUsage()), nil
+}
+
+func keyUpperBound(b []byte) []byte {
+	end := make([]byte, len(b))
+	copy(end, b)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil // no upper-bound
+}
+
+// Iterate implements the db.Database.Iterate interface method
+func (d *pebbleDB) Iterate(prefix []byte, callback func(k, v []byte) bool) (err error) {
+	iter := d.db.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: keyUpperBound(prefix),
+	})
+	defer func() {
+		errC := iter.Close()
+		if err != nil {
+			return
+		}
+		err = errC
+	}()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		if cont := callback(iter.Key()[len(prefix):], iter.Value()); !cont {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+// pebbleWriteTx implements the db.ReadTx and db.WriteTx interfaces
+type pebbleWriteTx struct {
+	batch *pebble.Batch
+}
+
+// Get implements the db.ReadTx.Get interface method
+func (tx pebbleWriteTx) Get(k []byte) ([]byte, error) {
+	v, closer, err := tx.batch.Get(k)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, db.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the returned value is only valid until the closer is closed
+	v2 := make([]byte, len(v))
+	copy(v2, v)
+	if err := closer.Close(); err != nil {
+		return nil, err
+	}
+	return v2, nil
+}
+
+// Set implements the db.WriteTx.Set interface method
+func (tx pebbleWriteTx) Set(k, v []byte) error {
+	return tx.batch.Set(k, v, nil)
+}
+
+// Delete implements the db.WriteTx.Delete interface method
+func (tx pebbleWriteTx) Delete(k []byte) error {
+	return tx.batch.Delete(k, nil)
+}
+
+// Apply implements the db.WriteTx.Apply interface method
+func (tx pebbleWriteTx) Apply(other db.WriteTx) error {
+	return tx.batch.Apply(other.(pebbleWriteTx).batch, nil)
+}
+
+// Commit implements the db.WriteTx.Commit interface method
+func (tx pebbleWriteTx) Commit() error {
+	return tx.batch.Commit(nil)
+}
+
+// Discard implements the db.ReadTx.Discard interface method
+func (tx pebbleWriteTx) Discard() {
+	// the error is omitted in the Discard context
+	tx.batch.Close() //nolint:errcheck
+}
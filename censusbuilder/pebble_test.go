@@ -0,0 +1,91 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.vocdoni.io/dvote/db"
+)
+
+func TestPebbleOptions(t *testing.T) {
+	c := qt.New(t)
+
+	var used []PebbleOptions
+	openSubDB = func(path string, opts PebbleOptions) (db.Database, error) {
+		used = append(used, opts)
+		return newPebbleDB(path, opts)
+	}
+	defer func() { openSubDB = newPebbleDB }()
+
+	// the parameters not set use the defaults
+	pebbleOpts := PebbleOptions{CacheSize: 1 << 20, MemTableSize: 2 << 20}
+	cb, err := NewWithOptions(Options{DB: newTestDB(c),
+		SubDBsPath: c.TempDir(), Pebble: pebbleOpts})
+	c.Assert(err, qt.IsNil)
+	expected := PebbleOptions{CacheSize: 1 << 20, MemTableSize: 2 << 20,
+		MaxOpenFiles: DefaultPebbleMaxOpenFiles}
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	c.Assert(used, qt.DeepEquals, []PebbleOptions{expected})
+
+	// reloading the Census opens its sub-db with the same options
+	c.Assert(cb.getCensus(censusID).CloseDB(), qt.IsNil)
+	cb.censusesMu.Lock()
+	delete(cb.censuses, censusID)
+	cb.censusesMu.Unlock()
+	_, err = cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(used, qt.DeepEquals, []PebbleOptions{expected, expected})
+	c.Assert(cb.Close(), qt.IsNil)
+
+	c.Assert(PebbleOptions{}.withDefaults(), qt.DeepEquals, PebbleOptions{
+		CacheSize:    DefaultPebbleCacheSize,
+		MemTableSize: DefaultPebbleMemTableSize,
+		MaxOpenFiles: DefaultPebbleMaxOpenFiles,
+	})
+}
+
+func TestPebbleDB(t *testing.T) {
+	c := qt.New(t)
+
+	database, err := newPebbleDB(c.TempDir(), PebbleOptions{})
+	c.Assert(err, qt.IsNil)
+	defer database.Close() //nolint:errcheck
+
+	wTx := database.WriteTx()
+	defer wTx.Discard()
+	c.Assert(wTx.Set([]byte("a1"), []byte{1}), qt.IsNil)
+	c.Assert(wTx.Set([]byte("a2"), []byte{2}), qt.IsNil)
+	c.Assert(wTx.Set([]byte("b1"), []byte{3}), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+
+	rTx := database.ReadTx()
+	v, err := rTx.Get([]byte("a2"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(v, qt.DeepEquals, []byte{2})
+	_, err = rTx.Get([]byte("c"))
+	c.Assert(err, qt.Equals, db.ErrKeyNotFound)
+	rTx.Discard()
+
+	var keys []string
+	err = database.Iterate([]byte("a"), func(k, v []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(keys, qt.DeepEquals, []string{"1", "2"})
+
+	// Apply copies the key-values of another WriteTx
+	wTx = database.WriteTx()
+	defer wTx.Discard()
+	other := database.WriteTx()
+	defer other.Discard()
+	c.Assert(other.Delete([]byte("a1")), qt.IsNil)
+	c.Assert(wTx.Apply(other), qt.IsNil)
+	c.Assert(wTx.Commit(), qt.IsNil)
+	rTx = database.ReadTx()
+	defer rTx.Discard()
+	_, err = rTx.Get([]byte("a1"))
+	c.Assert(err, qt.Equals, db.ErrKeyNotFound)
+}
@@ -0,0 +1,61 @@
+package censusbuilder
+
+import (
+	"math/big"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/log"
+)
+
+// RemovePublicKeys removes the given PublicKeys from the open Census of the
+// given censusID, updating its MerkleTree and the KeyIndex. If any of the
+// PublicKeys is not in the Census, none of them is removed (see
+// census.Census.RemovePublicKeys). Returns error if the Census is closed.
+func (cb *CensusBuilder) RemovePublicKeys(censusID types.CensusID,
+	pubKs []babyjub.PublicKey) error {
+	if err := cb.loadOpenCensus(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	if err := cb.getCensus(censusID).RemovePublicKeys(pubKs); err != nil {
+		return err
+	}
+	if cb.keyIndex {
+		if err := cb.unindexKeys(censusID, pubKs); err != nil {
+			log.Errorf("[CensusID=%d] can not update the KeyIndex: %s",
+				censusID, err)
+		}
+	}
+	log.Debugf("[CensusID=%d] %d PublicKeys removed", censusID, len(pubKs))
+	return nil
+}
+
+// ReplacePublicKey replaces the given oldPubK by the given newPubK in the open
+// Census of the given censusID, keeping its index, and with the given weight,
+// or the weight of the oldPubK if it is nil. Returns error if the Census is
+// closed.
+func (cb *CensusBuilder) ReplacePublicKey(censusID types.CensusID, oldPubK,
+	newPubK babyjub.PublicKey, weight *big.Int) error {
+	if err := cb.loadOpenCensus(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	if err := cb.getCensus(censusID).ReplacePublicKey(oldPubK, newPubK,
+		weight); err != nil {
+		return err
+	}
+	if cb.keyIndex {
+		if err := cb.unindexKeys(censusID, []babyjub.PublicKey{oldPubK}); err != nil {
+			log.Errorf("[CensusID=%d] can not update the KeyIndex: %s",
+				censusID, err)
+		}
+		if err := cb.indexKeys(censusID, []babyjub.PublicKey{newPubK},
+			false); err != nil {
+			log.Errorf("[CensusID=%d] can not update the KeyIndex: %s",
+				censusID, err)
+		}
+	}
+	log.Debugf("[CensusID=%d] PublicKey replaced", censusID)
+	return nil
+}
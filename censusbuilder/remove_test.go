@@ -0,0 +1,67 @@
+package censusbuilder
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRemoveAndReplacePublicKeys(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(12)
+
+	cb, err := NewWithOptions(Options{
+		DB:         newTestDB(c),
+		SubDBsPath: c.TempDir(),
+		KeyIndex:   true,
+	})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:10], keys.Weights[:10])
+	c.Assert(err, qt.IsNil)
+
+	err = cb.RemovePublicKeys(censusID, keys.PublicKeys[10:11])
+	c.Assert(err, qt.ErrorMatches, census.ErrPublicKeyNotFound.Error()+".*")
+	err = cb.RemovePublicKeys(censusID, keys.PublicKeys[:2])
+	c.Assert(err, qt.IsNil)
+	err = cb.ReplacePublicKey(censusID, keys.PublicKeys[2], keys.PublicKeys[11],
+		big.NewInt(5))
+	c.Assert(err, qt.IsNil)
+
+	info, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(info.Size, qt.Equals, uint64(8))
+	censusIDs, err := cb.FindCensusesForKey(keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(censusIDs), qt.Equals, 0)
+	censusIDs, err = cb.FindCensusesForKey(keys.PublicKeys[2])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(censusIDs), qt.Equals, 0)
+	censusIDs, err = cb.FindCensusesForKey(keys.PublicKeys[11])
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusIDs, qt.DeepEquals, []types.CensusID{censusID})
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	index, _, err := cb.GetProof(censusID, &keys.PublicKeys[11])
+	c.Assert(err, qt.IsNil)
+	c.Assert(index, qt.Equals, uint64(2))
+	ok, err := cb.VerifyRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+
+	// once closed, the PublicKeys can not be modified
+	err = cb.RemovePublicKeys(censusID, keys.PublicKeys[3:4])
+	c.Assert(err, qt.ErrorMatches, ".*"+census.ErrCensusClosed.Error())
+	err = cb.ReplacePublicKey(censusID, keys.PublicKeys[3], keys.PublicKeys[0], nil)
+	c.Assert(err, qt.ErrorMatches, ".*"+census.ErrCensusClosed.Error())
+
+	err = cb.Close()
+	c.Assert(err, qt.IsNil)
+}
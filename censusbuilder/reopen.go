@@ -0,0 +1,156 @@
+package censusbuilder
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+)
+
+var (
+	// dbPrefixParent is used to store the mapping CensusID->parent
+	// CensusID of the Censuses created with ReopenCensus
+	dbPrefixParent = []byte("censusParent")
+	// dbPrefixVersion is used to store the versions of each Census, where
+	// each entry key is dbPrefixVersion | parent CensusID | CensusID, with
+	// the censusIDs in big-endian so the entries are sorted by CensusID
+	dbPrefixVersion = []byte("censusVersion")
+)
+
+func dbKeyParent(censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	return append(append([]byte{}, dbPrefixParent...), b...)
+}
+
+func dbPrefixVersionParent(parentID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(parentID))
+	return append(append([]byte{}, dbPrefixVersion...), b...)
+}
+
+func dbKeyVersion(parentID, censusID types.CensusID) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(censusID))
+	return append(dbPrefixVersionParent(parentID), b...)
+}
+
+// Lineage contains the relation of a Census with the Censuses from which it
+// has been reopened, and the ones reopened from it, with ReopenCensus
+type Lineage struct {
+	// Version is 1 for a Census that has not been reopened from another
+	// one, and the Version of its Parent plus 1 otherwise
+	Version int `json:"version"`
+	// Parent is the censusID of the Census from which the Census has been
+	// reopened, nil if the Census is the first version
+	Parent *types.CensusID `json:"parent,omitempty"`
+	// Children are the censusIDs of the Censuses reopened from the Census,
+	// sorted by censusID
+	Children []types.CensusID `json:"children,omitempty"`
+}
+
+// ReopenCensus creates a new version of the closed Census of the given
+// censusID, to which more PublicKeys can be added: the new Census is a clone
+// of the closed one (see CloneCensus) that is opened again, keeping the
+// indexes of its PublicKeys. The closed Census and its CensusRoot are not
+// modified, so the processes that use it are not affected. The relation
+// between both Censuses is stored, and can be obtained with CensusLineage.
+// Returns the censusID of the new version.
+func (cb *CensusBuilder) ReopenCensus(censusID types.CensusID) (types.CensusID, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return 0, err
+	}
+	isClosed, err := cb.getCensus(censusID).IsClosed()
+	cb.releaseCensus(censusID)
+	if err != nil {
+		return 0, err
+	}
+	if !isClosed {
+		return 0, fmt.Errorf("%s, CensusID=%d", census.ErrCensusNotClosed, censusID)
+	}
+
+	versionID, err := cb.CloneCensus(censusID)
+	if err != nil {
+		return 0, err
+	}
+	if err := cb.loadCensusIfNotYet(versionID); err != nil {
+		return 0, err
+	}
+	defer cb.releaseCensus(versionID)
+	if err := cb.getCensus(versionID).ReopenForVersion(); err != nil {
+		return 0, err
+	}
+
+	wTx := cb.db.WriteTx()
+	defer wTx.Discard()
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(censusID))
+	if err := wTx.Set(dbKeyParent(versionID), b); err != nil {
+		return 0, err
+	}
+	if err := wTx.Set(dbKeyVersion(censusID, versionID), []byte{1}); err != nil {
+		return 0, err
+	}
+	if err := wTx.Commit(); err != nil {
+		return 0, err
+	}
+	log.Debugf("[CensusID=%d] reopened as a new version of CensusID=%d",
+		versionID, censusID)
+	return versionID, nil
+}
+
+// getParent returns the censusID of the Census from which the Census of the
+// given censusID has been reopened, or db.ErrKeyNotFound if it has not been
+// reopened from another Census
+func (cb *CensusBuilder) getParent(rTx db.ReadTx, censusID types.CensusID) (
+	types.CensusID, error) {
+	b, err := rTx.Get(dbKeyParent(censusID))
+	if err != nil {
+		return 0, err
+	}
+	return types.CensusID(binary.LittleEndian.Uint64(b)), nil
+}
+
+// CensusLineage returns the Lineage of the Census of the given censusID. The
+// Lineage is kept when the Censuses are deleted, as their censusIDs are not
+// reused.
+func (cb *CensusBuilder) CensusLineage(censusID types.CensusID) (*Lineage, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	nextCensusID, err := cb.getNextCensusID(rTx)
+	if err != nil {
+		return nil, err
+	}
+	if censusID >= nextCensusID {
+		return nil, censusNotFoundError{censusID: censusID}
+	}
+	lineage := &Lineage{Version: 1}
+	parentID, err := cb.getParent(rTx, censusID)
+	if err == nil {
+		lineage.Parent = &parentID
+	} else if err != db.ErrKeyNotFound {
+		return nil, err
+	}
+	// each ancestor increases the Version
+	for id := censusID; ; lineage.Version++ {
+		id, err = cb.getParent(rTx, id)
+		if err == db.ErrKeyNotFound {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	err = cb.db.Iterate(dbPrefixVersionParent(censusID), func(k, _ []byte) bool {
+		lineage.Children = append(lineage.Children,
+			types.CensusID(binary.BigEndian.Uint64(k)))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lineage, nil
+}
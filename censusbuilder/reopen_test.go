@@ -0,0 +1,84 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReopenCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(30)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:10], keys.Weights[:10])
+	c.Assert(err, qt.IsNil)
+
+	// an open Census can not be reopened
+	_, err = cb.ReopenCensus(censusID)
+	c.Assert(err, qt.ErrorMatches, census.ErrCensusNotClosed.Error()+".*")
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+
+	v2, err := cb.ReopenCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(v2, qt.Not(qt.Equals), censusID)
+	err = cb.AddPublicKeys(v2, keys.PublicKeys[10:20], keys.Weights[10:20])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(v2)
+	c.Assert(err, qt.IsNil)
+
+	// the first version is not modified
+	root1, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(root1, qt.DeepEquals, root)
+	ci, err := cb.CensusInfo(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ci.Size, qt.Equals, uint64(10))
+	_, _, err = cb.GetProof(censusID, &keys.PublicKeys[10])
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	// the new version keeps the indexes of the first one
+	root2, err := cb.CensusRoot(v2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(root2, qt.Not(qt.DeepEquals), root)
+	for i := 0; i < 20; i++ {
+		index, _, err := cb.GetProof(v2, &keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(index, qt.Equals, uint64(i))
+	}
+
+	// a second version of the first one, and a version of the second one
+	v2b, err := cb.ReopenCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	v3, err := cb.ReopenCensus(v2)
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(v3, keys.PublicKeys[20:], keys.Weights[20:])
+	c.Assert(err, qt.IsNil)
+
+	lineage, err := cb.CensusLineage(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(lineage, qt.DeepEquals, &Lineage{Version: 1,
+		Children: []types.CensusID{v2, v2b}})
+	lineage, err = cb.CensusLineage(v2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(lineage, qt.DeepEquals, &Lineage{Version: 2, Parent: &censusID,
+		Children: []types.CensusID{v3}})
+	lineage, err = cb.CensusLineage(v3)
+	c.Assert(err, qt.IsNil)
+	c.Assert(lineage, qt.DeepEquals, &Lineage{Version: 3, Parent: &v2})
+
+	_, err = cb.CensusLineage(v3 + 1)
+	c.Assert(err, qt.ErrorMatches, "CensusID=.* does not exist")
+	c.Assert(cb.Close(), qt.IsNil)
+}
@@ -0,0 +1,81 @@
+package censusbuilder
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+)
+
+// Report contains the data of a closed Census that identifies its content, to
+// be archived when the Census is published and compared across nodes
+type Report struct {
+	CensusID types.CensusID  `json:"censusID"`
+	Root     types.ByteArray `json:"root"`
+	Size     uint64          `json:"size"`
+	// Digest contains the Digest of the Census, which commits to all its
+	// leafs
+	Digest types.ByteArray `json:"digest"`
+	// ClosedAt contains the time when the Census was closed, nil if the
+	// Census was closed before the closing time was stored
+	ClosedAt *time.Time `json:"closedAt,omitempty"`
+	Label    string     `json:"label,omitempty"`
+}
+
+// FinalizationReport returns the Report of the Census of the given censusID,
+// which needs to be closed
+func (cb *CensusBuilder) FinalizationReport(censusID types.CensusID) (*Report, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	c := cb.getCensus(censusID)
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return nil, err
+	}
+	if !isClosed {
+		return nil, fmt.Errorf("%s, can not generate the report of CensusID=%d",
+			census.ErrCensusNotClosed, censusID)
+	}
+
+	root, err := c.Root()
+	if err != nil {
+		return nil, err
+	}
+	size, err := c.Size()
+	if err != nil {
+		return nil, err
+	}
+	digest, err := c.Digest()
+	if err != nil {
+		return nil, err
+	}
+	closedAt, err := c.ClosedAt()
+	if err != nil {
+		return nil, err
+	}
+	label, err := cb.getLabel(censusID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{
+		CensusID: censusID,
+		Root:     root,
+		Size:     size,
+		Digest:   digest,
+		Label:    label,
+	}
+	if !closedAt.IsZero() {
+		r.ClosedAt = &closedAt
+	}
+	return r, nil
+}
+
+// Marshal returns the json representation of the Report
+func (r *Report) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
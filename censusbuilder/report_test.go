@@ -0,0 +1,74 @@
+package censusbuilder
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestFinalizationReport(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	cb, err := NewWithOptions(Options{DB: newTestDB(c), SubDBsPath: c.TempDir(),
+		Now: func() time.Time { return now }})
+	c.Assert(err, qt.IsNil)
+
+	// expect error when the census does not exist
+	_, err = cb.FinalizationReport(0)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	censusID, err := cb.NewCensusWithLabel("dao-vote")
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	// expect error when the census is not closed
+	_, err = cb.FinalizationReport(censusID)
+	c.Assert(err, qt.ErrorMatches, census.ErrCensusNotClosed.Error()+".*")
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	report, err := cb.FinalizationReport(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	digest, err := cb.Digest(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.CensusID, qt.Equals, censusID)
+	c.Assert([]byte(report.Root), qt.DeepEquals, root)
+	c.Assert(report.Size, qt.Equals, uint64(nKeys))
+	c.Assert([]byte(report.Digest), qt.DeepEquals, digest)
+	c.Assert(report.ClosedAt.Equal(now), qt.IsTrue)
+	c.Assert(report.Label, qt.Equals, "dao-vote")
+
+	b, err := report.Marshal()
+	c.Assert(err, qt.IsNil)
+	var report2 Report
+	err = json.Unmarshal(b, &report2)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report2.CensusID, qt.Equals, report.CensusID)
+	c.Assert(report2.Root, qt.DeepEquals, report.Root)
+	c.Assert(report2.Digest, qt.DeepEquals, report.Digest)
+	c.Assert(report2.ClosedAt.Equal(now), qt.IsTrue)
+
+	// a Census without label
+	censusID, err = cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	report, err = cb.FinalizationReport(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Size, qt.Equals, uint64(0))
+	c.Assert([]byte(report.Root), qt.DeepEquals, types.EmptyRoot)
+	c.Assert(report.Label, qt.Equals, "")
+}
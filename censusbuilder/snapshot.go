@@ -0,0 +1,69 @@
+package censusbuilder
+
+import (
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// SnapshotCensus stores the current state of the open Census of the given
+// censusID under the given name, to which it can be rolled back with
+// RollbackCensus (see census.Census.Snapshot)
+func (cb *CensusBuilder) SnapshotCensus(censusID types.CensusID, name string) (
+	*census.Snapshot, error) {
+	if err := cb.loadOpenCensus(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	snapshot, err := cb.getCensus(censusID).Snapshot(name)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("[CensusID=%d] Snapshot %q stored, %d leafs", censusID, name,
+		snapshot.NLeafs)
+	return snapshot, nil
+}
+
+// CensusSnapshots returns the Snapshots of the Census of the given censusID,
+// sorted by name
+func (cb *CensusBuilder) CensusSnapshots(censusID types.CensusID) (
+	[]census.Snapshot, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).Snapshots()
+}
+
+// DeleteCensusSnapshot removes the Snapshot of the given name of the Census of
+// the given censusID
+func (cb *CensusBuilder) DeleteCensusSnapshot(censusID types.CensusID, name string) error {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	return cb.getCensus(censusID).DeleteSnapshot(name)
+}
+
+// RollbackCensus restores the open Census of the given censusID to its
+// Snapshot of the given name, discarding the PublicKeys added after it (see
+// census.Census.RollbackToSnapshot), which are also removed from the
+// KeyIndex, and can be added again.
+func (cb *CensusBuilder) RollbackCensus(censusID types.CensusID, name string) error {
+	if err := cb.loadOpenCensus(censusID); err != nil {
+		return err
+	}
+	defer cb.releaseCensus(censusID)
+	discarded, err := cb.getCensus(censusID).RollbackToSnapshot(name)
+	if err != nil {
+		return err
+	}
+	if cb.keyIndex && len(discarded) != 0 {
+		if err := cb.unindexKeys(censusID, discarded); err != nil {
+			return err
+		}
+	}
+	log.Debugf("[CensusID=%d] rolled back to the Snapshot %q, %d PublicKeys"+
+		" discarded", censusID, name, len(discarded))
+	return nil
+}
@@ -0,0 +1,55 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/test"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRollbackCensus(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(20)
+
+	cb, err := NewWithOptions(Options{DB: newTestDB(c), SubDBsPath: c.TempDir(),
+		KeyIndex: true})
+	c.Assert(err, qt.IsNil)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:10], keys.Weights[:10])
+	c.Assert(err, qt.IsNil)
+	snapshot, err := cb.SnapshotCensus(censusID, "before-import")
+	c.Assert(err, qt.IsNil)
+	c.Assert(snapshot.NLeafs, qt.Equals, uint64(10))
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[10:], keys.Weights[10:])
+	c.Assert(err, qt.IsNil)
+
+	err = cb.RollbackCensus(censusID, "before-import")
+	c.Assert(err, qt.IsNil)
+	ok, err := cb.HasPublicKey(censusID, &keys.PublicKeys[15])
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+	// the discarded PublicKeys are removed from the KeyIndex
+	censusIDs, err := cb.FindCensusesForKey(keys.PublicKeys[15])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(censusIDs), qt.Equals, 0)
+	censusIDs, err = cb.FindCensusesForKey(keys.PublicKeys[5])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(censusIDs), qt.Equals, 1)
+
+	snapshots, err := cb.CensusSnapshots(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(snapshots), qt.Equals, 1)
+	err = cb.DeleteCensusSnapshot(censusID, "before-import")
+	c.Assert(err, qt.IsNil)
+	err = cb.RollbackCensus(censusID, "before-import")
+	c.Assert(err, qt.ErrorMatches, census.ErrSnapshotNotFound.Error()+".*")
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	_, err = cb.SnapshotCensus(censusID, "closed")
+	c.Assert(err, qt.ErrorMatches, ".*"+census.ErrCensusClosed.Error())
+	c.Assert(cb.Close(), qt.IsNil)
+}
@@ -0,0 +1,82 @@
+package censusbuilder
+
+import (
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/db"
+)
+
+// Stats contains aggregated metrics of the Censuses of the CensusBuilder
+type Stats struct {
+	// Censuses is the number of Censuses created, including the archived
+	// and the deleted ones
+	Censuses uint64 `json:"censuses"`
+	// ArchivedCensuses is the number of archived Censuses
+	ArchivedCensuses uint64 `json:"archivedCensuses"`
+	// DeletedCensuses is the number of Censuses deleted with
+	// DeleteCensus
+	DeletedCensuses uint64 `json:"deletedCensuses"`
+	// ClosedCensuses is the number of closed Censuses, including the
+	// archived ones
+	ClosedCensuses uint64 `json:"closedCensuses"`
+	// PublicKeys is the total number of PublicKeys across all the
+	// Censuses
+	PublicKeys uint64 `json:"publicKeys"`
+}
+
+// Stats returns the aggregated Stats of all the Censuses. The number of
+// Censuses is read from the CensusBuilder db, the archived Censuses are
+// counted from their stored census.Info without restoring them, and for the
+// rest of Censuses only their size and closed flag are read.
+func (cb *CensusBuilder) Stats() (*Stats, error) {
+	rTx := cb.db.ReadTx()
+	defer rTx.Discard()
+	nextCensusID, err := cb.getNextCensusID(rTx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{Censuses: uint64(nextCensusID)}
+	for censusID := types.CensusID(0); censusID < nextCensusID; censusID++ {
+		a, err := cb.getArchived(rTx, censusID)
+		if err == nil {
+			stats.ArchivedCensuses++
+			stats.ClosedCensuses++
+			stats.PublicKeys += a.Info.Size
+			continue
+		} else if err != db.ErrKeyNotFound {
+			return nil, err
+		}
+
+		size, isClosed, err := cb.sizeAndClosed(censusID)
+		if err == ErrCensusDeleted {
+			stats.DeletedCensuses++
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if isClosed {
+			stats.ClosedCensuses++
+		}
+		stats.PublicKeys += size
+	}
+	return stats, nil
+}
+
+// sizeAndClosed returns the number of PublicKeys of the Census of the given
+// censusID and if it is closed, loading the Census if it is not loaded yet
+func (cb *CensusBuilder) sizeAndClosed(censusID types.CensusID) (uint64, bool, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return 0, false, err
+	}
+	defer cb.releaseCensus(censusID)
+	c := cb.getCensus(censusID)
+	size, err := c.Size()
+	if err != nil {
+		return 0, false, err
+	}
+	isClosed, err := c.IsClosed()
+	if err != nil {
+		return 0, false, err
+	}
+	return size, isClosed, nil
+}
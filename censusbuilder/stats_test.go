@@ -0,0 +1,52 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestStats(t *testing.T) {
+	c := qt.New(t)
+
+	keys := test.GenUserKeys(10)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	stats, err := cb.Stats()
+	c.Assert(err, qt.IsNil)
+	c.Assert(*stats, qt.Equals, Stats{})
+
+	// an open Census with 3 keys
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:3], keys.Weights[:3])
+	c.Assert(err, qt.IsNil)
+
+	// a closed Census with 5 keys
+	censusID, err = cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[3:8], keys.Weights[3:8])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	// an archived Census with 2 keys
+	censusID, err = cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[8:], keys.Weights[8:])
+	c.Assert(err, qt.IsNil)
+	err = cb.ArchiveCensus(censusID, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	stats, err = cb.Stats()
+	c.Assert(err, qt.IsNil)
+	c.Assert(*stats, qt.Equals, Stats{
+		Censuses:         3,
+		ArchivedCensuses: 1,
+		ClosedCensuses:   2,
+		PublicKeys:       10,
+	})
+}
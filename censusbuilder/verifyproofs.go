@@ -0,0 +1,52 @@
+package censusbuilder
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/aragon/ovote-node/types"
+)
+
+// VerifyMembershipProofs checks each one of the given CensusProofs against the
+// CensusRoot of the Census for the given censusID as VerifyMembershipProof
+// does, returning a slice where the position i is true if the CensusProof at
+// the position i is valid. The CensusRoot is read once for the whole batch,
+// and the CensusProofs are verified in parallel by a pool of workers. Returns
+// error only if the Census can not be loaded or is not closed.
+func (cb *CensusBuilder) VerifyMembershipProofs(censusID types.CensusID,
+	proofs []types.CensusProof) ([]bool, error) {
+	root, err := cb.CensusRoot(censusID)
+	if err != nil {
+		return nil, err
+	}
+	hashFunc, maxLevels, err := cb.treeParams(censusID)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := make([]bool, len(proofs))
+	nWorkers := runtime.NumCPU()
+	if nWorkers > len(proofs) {
+		nWorkers = len(proofs)
+	}
+	indexes := make(chan int, nWorkers)
+	var wg sync.WaitGroup
+	for w := 0; w < nWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				// each worker writes only the positions that it
+				// receives, so no lock is needed
+				valid[i] = checkMembershipProof(censusID, hashFunc,
+					maxLevels, root, &proofs[i])
+			}
+		}()
+	}
+	for i := 0; i < len(proofs); i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return valid, nil
+}
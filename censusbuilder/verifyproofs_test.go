@@ -0,0 +1,99 @@
+package censusbuilder
+
+import (
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func genMembershipProofs(c *qt.C, nKeys int) (*CensusBuilder, types.CensusID,
+	[]types.CensusProof) {
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	proofs := make([]types.CensusProof, nKeys)
+	for i := 0; i < nKeys; i++ {
+		index, merkleProof, err := cb.GetProof(censusID, &keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		proofs[i] = types.CensusProof{
+			Index:       index,
+			PublicKey:   &keys.PublicKeys[i],
+			Weight:      keys.Weights[i],
+			MerkleProof: merkleProof,
+		}
+	}
+	return cb, censusID, proofs
+}
+
+func TestVerifyMembershipProofs(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 10
+	cb, censusID, proofs := genMembershipProofs(c, nKeys)
+
+	// expect error when the census does not exist
+	_, err := cb.VerifyMembershipProofs(censusID+1, proofs)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	valid, err := cb.VerifyMembershipProofs(censusID, proofs)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(valid), qt.Equals, nKeys)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(valid[i], qt.IsTrue)
+	}
+
+	// invalidate some of the proofs
+	proofs[1].Index++
+	proofs[4].MerkleProof = []byte{1, 2, 3}
+	proofs[7].PublicKey = nil
+	valid, err = cb.VerifyMembershipProofs(censusID, proofs)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(valid[i], qt.Equals, i != 1 && i != 4 && i != 7)
+		v, err := cb.VerifyMembershipProof(censusID, proofs[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.Equals, valid[i])
+	}
+
+	// an empty batch
+	valid, err = cb.VerifyMembershipProofs(censusID, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(valid), qt.Equals, 0)
+}
+
+func BenchmarkVerifyMembershipProofs(b *testing.B) {
+	c := qt.New(b)
+
+	nKeys := 1_000
+	cb, censusID, proofs := genMembershipProofs(c, nKeys)
+	b.ResetTimer()
+
+	b.Run("Loop", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i := 0; i < nKeys; i++ {
+				v, err := cb.VerifyMembershipProof(censusID, proofs[i])
+				if err != nil || !v {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("Batch", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if _, err := cb.VerifyMembershipProofs(censusID, proofs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package censusbuilder
+
+import (
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// ReadOnlyCensus is a view of a Census of the CensusBuilder that only allows
+// reading it, so it can be handed to callers that must not be able to add
+// PublicKeys to the Census nor close it. The view reads the current state of
+// the Census on each call.
+type ReadOnlyCensus struct {
+	cb       *CensusBuilder
+	censusID types.CensusID
+}
+
+// View returns a ReadOnlyCensus for the Census of the given censusID,
+// returning error if the Census does not exist
+func (cb *CensusBuilder) View(censusID types.CensusID) (*ReadOnlyCensus, error) {
+	if err := cb.loadCensusIfNotYet(censusID); err != nil {
+		return nil, err
+	}
+	cb.releaseCensus(censusID)
+	return &ReadOnlyCensus{cb: cb, censusID: censusID}, nil
+}
+
+// CensusID returns the censusID of the viewed Census
+func (v *ReadOnlyCensus) CensusID() types.CensusID {
+	return v.censusID
+}
+
+// Root returns the CensusRoot of the viewed Census, returning error if the
+// Census is not closed yet
+func (v *ReadOnlyCensus) Root() ([]byte, error) {
+	return v.cb.CensusRoot(v.censusID)
+}
+
+// Size returns the number of PublicKeys added to the viewed Census
+func (v *ReadOnlyCensus) Size() (uint64, error) {
+	if err := v.cb.loadCensusIfNotYet(v.censusID); err != nil {
+		return 0, err
+	}
+	defer v.cb.releaseCensus(v.censusID)
+	return v.cb.getCensus(v.censusID).Size()
+}
+
+// GenerateProof returns the leaf index and the MerkleProof compressed of the
+// given PublicKey in the viewed Census, which needs to be closed
+func (v *ReadOnlyCensus) GenerateProof(pubK *babyjub.PublicKey) (uint64, []byte,
+	error) {
+	return v.cb.GetProof(v.censusID, pubK)
+}
+
+// HasPublicKey returns true if the given PublicKey is in the viewed Census
+func (v *ReadOnlyCensus) HasPublicKey(pubK *babyjub.PublicKey) (bool, error) {
+	return v.cb.HasPublicKey(v.censusID, pubK)
+}
+
+// VerifyMembershipProof checks the given CensusProof against the CensusRoot
+// of the viewed Census, as CensusBuilder.VerifyMembershipProof does
+func (v *ReadOnlyCensus) VerifyMembershipProof(proof types.CensusProof) (bool,
+	error) {
+	return v.cb.VerifyMembershipProof(v.censusID, proof)
+}
@@ -0,0 +1,105 @@
+package censusbuilder
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReadOnlyCensusMethods(t *testing.T) {
+	c := qt.New(t)
+
+	// the view must only expose read methods
+	var methods []string
+	typ := reflect.TypeOf(&ReadOnlyCensus{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		methods = append(methods, typ.Method(i).Name)
+	}
+	sort.Strings(methods)
+	c.Assert(methods, qt.DeepEquals, []string{"CensusID", "GenerateProof",
+		"HasPublicKey", "Root", "Size", "VerifyMembershipProof"})
+}
+
+func TestView(t *testing.T) {
+	c := qt.New(t)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+
+	cb, err := New(newTestDB(c), c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	// expect error when the census does not exist
+	_, err = cb.View(0)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	view, err := cb.View(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(view.CensusID(), qt.Equals, censusID)
+
+	size, err := view.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(0))
+
+	// the view reflects the keys added to the underlying Census
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[:5], keys.Weights[:5])
+	c.Assert(err, qt.IsNil)
+	size, err = view.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(5))
+	has, err := view.HasPublicKey(&keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(has, qt.IsTrue)
+	has, err = view.HasPublicKey(&keys.PublicKeys[5])
+	c.Assert(err, qt.IsNil)
+	c.Assert(has, qt.IsFalse)
+
+	// expect error when the census is not closed
+	_, err = view.Root()
+	c.Assert(err, qt.Not(qt.IsNil))
+	_, _, err = view.GenerateProof(&keys.PublicKeys[0])
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys[5:], keys.Weights[5:])
+	c.Assert(err, qt.IsNil)
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	size, err = view.Size()
+	c.Assert(err, qt.IsNil)
+	c.Assert(size, qt.Equals, uint64(nKeys))
+	root, err := view.Root()
+	c.Assert(err, qt.IsNil)
+	expectedRoot, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(root, qt.DeepEquals, expectedRoot)
+
+	for i := 0; i < nKeys; i++ {
+		index, merkleProof, err := view.GenerateProof(&keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		proof := types.CensusProof{
+			Index:       index,
+			PublicKey:   &keys.PublicKeys[i],
+			Weight:      keys.Weights[i],
+			MerkleProof: merkleProof,
+		}
+		v, err := view.VerifyMembershipProof(proof)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsTrue)
+
+		// use a wrong weight
+		proof.Weight = keys.Weights[(i+1)%nKeys]
+		if proof.Weight.Cmp(keys.Weights[i]) == 0 {
+			continue
+		}
+		v, err = view.VerifyMembershipProof(proof)
+		c.Assert(err, qt.IsNil)
+		c.Assert(v, qt.IsFalse)
+	}
+}
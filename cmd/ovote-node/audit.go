@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aragon/ovote-node/censusbuilder"
+	"github.com/aragon/ovote-node/types"
+	flag "github.com/spf13/pflag"
+	kvdb "go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/db/pebbledb"
+	"go.vocdoni.io/dvote/log"
+)
+
+// auditCmd implements the audit subcommand, which audits the Censuses of the
+// CensusBuilder at the given dir, and exits with a non zero status if any of
+// them is corrupted
+func auditCmd(args []string) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	var dir, logLevel string
+	var censusID int64
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	flags.StringVarP(&dir, "dir", "d", filepath.Join(home, ".ovote-node"),
+		"storage data directory")
+	flags.StringVarP(&logLevel, "logLevel", "l", "info", "log level (info, debug, warn, error)")
+	flags.Int64Var(&censusID, "census", -1,
+		"CensusID of the Census to audit, if not set all the Censuses are audited")
+	flags.SortFlags = false
+	if err := flags.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Init(logLevel, "stdout")
+
+	opts := kvdb.Options{Path: filepath.Join(dir, "censusbuilder")}
+	database, err := pebbledb.New(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cb, err := censusbuilder.New(database, filepath.Join(dir, "subsdb"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var censusIDs []types.CensusID
+	if censusID >= 0 {
+		censusIDs = append(censusIDs, types.CensusID(censusID))
+	} else {
+		summaries, err := cb.ListCensuses(censusbuilder.CensusStatusAny)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, summary := range summaries {
+			if summary.Archived {
+				log.Infof("CensusID=%d is archived, skipped", summary.ID)
+				continue
+			}
+			censusIDs = append(censusIDs, summary.ID)
+		}
+	}
+
+	nCorrupted := 0
+	for _, id := range censusIDs {
+		report, err := cb.AuditCensus(id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if report.Valid() {
+			log.Infof("CensusID=%d is valid, %d leafs, root %x", id,
+				report.NLeafs, report.Root)
+			continue
+		}
+		nCorrupted++
+		log.Warnf("CensusID=%d is corrupted, %d of %d leafs stored, root %x,"+
+			" computed root %x", id, report.NStoredLeafs, report.NLeafs,
+			report.Root, report.ComputedRoot)
+		for _, leaf := range report.CorruptedLeafs {
+			if leaf.Detail != "" {
+				log.Warnf("CensusID=%d leaf %d: %s, %s", id, leaf.Index,
+					leaf.Reason, leaf.Detail)
+				continue
+			}
+			log.Warnf("CensusID=%d leaf %d: %s", id, leaf.Index, leaf.Reason)
+		}
+		if report.CorruptedNodes != 0 {
+			log.Warnf("CensusID=%d: %d corrupted intermediate nodes", id,
+				report.CorruptedNodes)
+		}
+		if report.Incomplete {
+			log.Warnf("CensusID=%d: the MerkleTree has missing nodes, so it"+
+				" has not been fully audited", id)
+		}
+	}
+	if err := cb.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("%d Censuses audited, %d corrupted", len(censusIDs), nCorrupted)
+	if nCorrupted != 0 {
+		os.Exit(1)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"net"
 	"os"
 	"path/filepath"
 
@@ -9,25 +10,38 @@ import (
 	"github.com/aragon/ovote-node/censusbuilder"
 	"github.com/aragon/ovote-node/db"
 	"github.com/aragon/ovote-node/eth"
+	ovotegrpc "github.com/aragon/ovote-node/grpc"
 	"github.com/aragon/ovote-node/prover"
+	"github.com/aragon/ovote-node/types"
 	"github.com/aragon/ovote-node/votesaggregator"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
 	_ "github.com/mattn/go-sqlite3"
 	flag "github.com/spf13/pflag"
 	kvdb "go.vocdoni.io/dvote/db"
 	"go.vocdoni.io/dvote/db/pebbledb"
 	"go.vocdoni.io/dvote/log"
+	"google.golang.org/grpc"
 )
 
 // Config contains the main configuration parameters of the node
 type Config struct {
-	dir, logLevel, port             string
+	dir, logLevel, port, grpcPort   string
 	startScanBlock                  uint64
 	censusBuilder, votesAggregator  bool
 	contractAddr, ethURL, proverURL string
+	importPath                      string
+	// token census parameters
+	keyRegistry, tokenSnapshot string
+	tokenAddr, tokenStandard   string
+	tokenFromBlock, tokenBlock uint64
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		auditCmd(os.Args[2:])
+		return
+	}
 	config := Config{}
 
 	home, err := os.UserHomeDir()
@@ -38,6 +52,8 @@ func main() {
 		"storage data directory")
 	flag.StringVarP(&config.logLevel, "logLevel", "l", "info", "log level (info, debug, warn, error)")
 	flag.StringVarP(&config.port, "port", "p", "8080", "network port for the HTTP API")
+	flag.StringVar(&config.grpcPort, "grpcport", "",
+		"network port for the gRPC API, which is not served if empty")
 	flag.BoolVarP(&config.censusBuilder, "censusbuilder", "c", false, "CensusBuilder active")
 	flag.BoolVarP(&config.votesAggregator, "votesaggregator", "v", false, "VotesAggregator active")
 	flag.StringVar(&config.ethURL, "eth", "", "web3 provider url")
@@ -45,6 +61,19 @@ func main() {
 	flag.Uint64Var(&config.startScanBlock, "block", 0,
 		"Start scanning block (usually the block where the OVOTE contract was deployed)")
 	flag.StringVar(&config.proverURL, "prover", "127.0.0.1:9000", "prover url")
+	flag.StringVar(&config.importPath, "import", "",
+		"import the PublicKeys of the given CSV or JSON file into a new Census, and exit")
+	flag.StringVar(&config.keyRegistry, "keyregistry", "",
+		"JSON file with the PublicKey of each Ethereum address, used to create a"+
+			" new Census with the token holders (from tokensnapshot or token), and exit")
+	flag.StringVar(&config.tokenSnapshot, "tokensnapshot", "",
+		"CSV file with the address and balance of each token holder")
+	flag.StringVar(&config.tokenAddr, "token", "",
+		"token contract address, from which the holders are read through the eth provider")
+	flag.StringVar(&config.tokenStandard, "tokenstandard", "erc20", "token standard (erc20, erc721)")
+	flag.Uint64Var(&config.tokenFromBlock, "tokenfromblock", 0,
+		"block where the token contract was deployed")
+	flag.Uint64Var(&config.tokenBlock, "tokenblock", 0, "block of the token holders snapshot")
 	// TODO add flag for configurable threshold of minimum census size (to prevent small censuses)
 
 	flag.CommandLine.SortFlags = false
@@ -56,6 +85,7 @@ func main() {
 
 	var censusBuilder *censusbuilder.CensusBuilder
 	var votesAggregator *votesaggregator.VotesAggregator
+	var sqlite *db.SQLite
 	if config.censusBuilder {
 		opts := kvdb.Options{Path: filepath.Join(config.dir, "censusbuilder")}
 		database, err := pebbledb.New(opts)
@@ -67,6 +97,19 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
+
+		if config.importPath != "" {
+			importCensus(censusBuilder, config.importPath)
+			return
+		}
+		if config.keyRegistry != "" {
+			tokenCensus(censusBuilder, config)
+			return
+		}
+	} else if config.importPath != "" {
+		log.Fatal("the import flag requires the censusbuilder flag")
+	} else if config.keyRegistry != "" {
+		log.Fatal("the keyregistry flag requires the censusbuilder flag")
 	}
 
 	if config.votesAggregator {
@@ -75,7 +118,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		sqlite := db.NewSQLite(sqlDB)
+		sqlite = db.NewSQLite(sqlDB)
 		err = sqlite.Migrate()
 		if err != nil {
 			log.Fatal(err)
@@ -124,12 +167,23 @@ func main() {
 			log.Fatal(err)
 		}
 
+		if censusBuilder != nil {
+			// only delete the Censuses whose processes are finished
+			censusBuilder.CanDeleteCensus = func(_ types.CensusID, root []byte) error {
+				return sqlite.CheckProcessesFinished(root)
+			}
+		}
+
 		err = ethC.Sync()
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
+	if config.grpcPort != "" {
+		serveGRPC(censusBuilder, votesAggregator, sqlite, config.grpcPort)
+	}
+
 	a, err := api.New(censusBuilder, votesAggregator)
 	if err != nil {
 		log.Fatal(err)
@@ -139,3 +193,119 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// serveGRPC serves the gRPC API at the given port in the background, backed by
+// the given CensusBuilder and VotesAggregator
+func serveGRPC(cb *censusbuilder.CensusBuilder, va *votesaggregator.VotesAggregator,
+	sqlite *db.SQLite, port string) {
+	// a nil *VotesAggregator needs to be passed as a nil VotesBackend
+	var votesBackend ovotegrpc.VotesBackend
+	if va != nil {
+		votesBackend = va
+	}
+	service, err := ovotegrpc.NewService(cb, votesBackend, sqlite)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := grpc.NewServer()
+	service.Register(server)
+	log.Infof("gRPC API serving at :%s", port)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
+}
+
+// importCensus creates a new Census with the PublicKeys of the file at the
+// given path, logging the rows that can not be imported
+func importCensus(cb *censusbuilder.CensusBuilder, path string) {
+	censusID, err := cb.NewCensus()
+	if err != nil {
+		log.Fatal(err)
+	}
+	report, err := cb.ImportFromFile(censusID, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, row := range report.Invalid {
+		log.Warnf("row %d not imported: %s", row.Row, row.Error)
+	}
+	log.Infof("Imported %d PublicKeys into CensusID=%d, %d invalid rows",
+		report.Added, censusID, len(report.Invalid))
+	if err := cb.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// tokenCensus creates a new Census with the PublicKeys of the token holders,
+// read from the tokensnapshot file or from the token contract logs, which are
+// registered in the keyregistry file
+func tokenCensus(cb *censusbuilder.CensusBuilder, config Config) {
+	f, err := os.Open(filepath.Clean(config.keyRegistry))
+	if err != nil {
+		log.Fatal(err)
+	}
+	registry, err := eth.ReadKeyRegistry(f)
+	f.Close() //nolint:errcheck
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var holders []eth.Holder
+	switch {
+	case config.tokenSnapshot != "":
+		f, err := os.Open(filepath.Clean(config.tokenSnapshot))
+		if err != nil {
+			log.Fatal(err)
+		}
+		holders, err = eth.ReadHoldersSnapshot(f)
+		f.Close() //nolint:errcheck
+		if err != nil {
+			log.Fatal(err)
+		}
+	case config.tokenAddr != "":
+		if !common.IsHexAddress(config.tokenAddr) {
+			log.Fatalf("invalid token address %s", config.tokenAddr)
+		}
+		if config.tokenBlock == 0 {
+			log.Fatal("the token flag requires the tokenblock flag")
+		}
+		standard, err := eth.TokenStandardFromString(config.tokenStandard)
+		if err != nil {
+			log.Fatal(err)
+		}
+		client, err := ethclient.Dial(config.ethURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		holders, err = eth.TokenHolders(client, eth.HoldersOptions{
+			Token:     common.HexToAddress(config.tokenAddr),
+			Standard:  standard,
+			FromBlock: config.tokenFromBlock,
+			BlockNum:  config.tokenBlock,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatal("the keyregistry flag requires the tokensnapshot or the token flag")
+	}
+
+	report, err := eth.NewCensusFromHolders(cb, holders, registry)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, h := range report.Skipped {
+		log.Warnf("holder %s not added: %s", h.Address.Hex(), h.Reason)
+	}
+	log.Infof("Added %d PublicKeys of %d holders into CensusID=%d",
+		report.Added, len(holders), report.CensusID)
+	if err := cb.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// execer is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Compact runs VACUUM to rebuild the database file, reclaiming the space of
+// the deleted rows (for example after DeleteVotePackagesByProcessID), and then
+// ANALYZE to refresh the statistics used by the query planner. VACUUM
+// rewrites the whole database, so it can be long-running for big databases,
+// and while it runs the rest of writes are blocked, so it should be called
+// while the node is idle. If it is run inside an open transaction,
+// ErrCompactInTx is returned.
+func (r *SQLite) Compact() error {
+	return r.compact(context.Background(), r.db)
+}
+
+func (r *SQLite) compact(ctx context.Context, e execer) error {
+	if _, err := e.ExecContext(ctx, "VACUUM"); err != nil {
+		return newDBError("Compact", err)
+	}
+	if _, err := e.ExecContext(ctx, "ANALYZE"); err != nil {
+		return newDBError("Compact", err)
+	}
+	return nil
+}
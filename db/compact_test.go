@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func pragmaInt(c *qt.C, db *sql.DB, pragma string) int {
+	row := db.QueryRow("PRAGMA " + pragma)
+	var n int
+	c.Assert(row.Scan(&n), qt.IsNil)
+	return n
+}
+
+func TestCompact(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLite(db)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, testCensusRoot("censusRoot"), 1000, 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	sk := babyjub.NewRandPrivKey()
+	sig := types.CompressSignature(sk.SignPoseidon(big.NewInt(1)))
+	for i := 0; i < 1000; i++ {
+		voterSk := babyjub.NewRandPrivKey()
+		err = sqlite.StoreVotePackage(processID, types.VotePackage{
+			Signature: sig,
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   voterSk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("merkleproof" + strconv.Itoa(i)),
+			},
+			Vote: make([]byte, DefaultMaxVoteLen),
+		})
+		c.Assert(err, qt.IsNil)
+	}
+	err = sqlite.DeleteVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+
+	// the pages of the deleted rows are kept in the freelist
+	pageCount := pragmaInt(c, db, "page_count")
+	c.Assert(pragmaInt(c, db, "freelist_count") > 0, qt.IsTrue)
+
+	err = sqlite.Compact()
+	c.Assert(err, qt.IsNil)
+	c.Assert(pragmaInt(c, db, "freelist_count"), qt.Equals, 0)
+	c.Assert(pragmaInt(c, db, "page_count") < pageCount, qt.IsTrue)
+
+	// the db keeps working after compacting it
+	counts, err := sqlite.Counts()
+	c.Assert(err, qt.IsNil)
+	c.Assert(counts.Processes, qt.Equals, uint64(1))
+	c.Assert(counts.VotePackages, qt.Equals, uint64(0))
+
+	// compacting inside an open transaction returns ErrCompactInTx
+	tx, err := db.Begin()
+	c.Assert(err, qt.IsNil)
+	err = sqlite.compact(context.Background(), tx)
+	c.Assert(errors.Is(err, ErrCompactInTx), qt.IsTrue)
+	c.Assert(tx.Rollback(), qt.IsNil)
+}
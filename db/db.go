@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 )
 
 // TODO unify naming of methods (Store/Set/Add, Get/Read/etc)
@@ -14,96 +16,156 @@ var (
 	ErrMetaNotInDB = fmt.Errorf("Meta does not exist in the db")
 )
 
+const (
+	// DefaultMaxVoteLen defines the default maximum length in bytes of the
+	// vote of the VotePackages that can be stored
+	DefaultMaxVoteLen = 1024
+	// DefaultMaxVoteSlots defines the default maximum number of
+	// VotePackages that each voter can store with StoreVotePackageSlot
+	DefaultMaxVoteSlots = 10
+)
+
 // SQLite represents the SQLite database
 type SQLite struct {
-	db *sql.DB
+	db           *sql.DB
+	maxVoteLen   int
+	maxVoteSlots int
+	appendOnly   bool
+	retry        RetryPolicy
+	// sleep is used to wait between the retries of withRetry, can be
+	// replaced in tests
+	sleep func(time.Duration)
+
+	// stmts contains the prepared statements that are reused between
+	// calls, by sql query
+	stmts   map[string]*sql.Stmt
+	stmtsMu sync.Mutex
+
+	// votesSubs contains the subscribers of SubscribeVotes
+	votesSubs voteSubscriptions
+
+	// limiter is the RateLimiter of the stored VotePackages, it can be
+	// replaced by SetRateLimiter while the VotePackages are stored
+	limiter   *RateLimiter
+	limiterMu sync.RWMutex
+}
+
+// Options is used to pass the parameters to load a new SQLite database
+type Options struct {
+	// MaxOpenConns sets the maximum number of open connections to the
+	// database. If not set, the database/sql default is used (no limit).
+	MaxOpenConns int
+	// MaxIdleConns sets the maximum number of connections in the idle
+	// connection pool. If not set, the database/sql default is used.
+	MaxIdleConns int
+	// MaxVoteLen sets the maximum length in bytes of the vote of the
+	// VotePackages that can be stored. If not set, DefaultMaxVoteLen is
+	// used.
+	MaxVoteLen int
+	// MaxVoteSlots sets the maximum number of VotePackages that each
+	// voter can store with StoreVotePackageSlot. If not set,
+	// DefaultMaxVoteSlots is used.
+	MaxVoteSlots int
+	// AppendOnly disables the methods that modify or delete the stored
+	// VotePackages, which return ErrAppendOnly, so the VotePackages can
+	// only be inserted.
+	AppendOnly bool
+	// Retry defines how the write operations are retried when they fail
+	// because the database is busy or locked. The fields that are not set
+	// use their default values.
+	Retry RetryPolicy
+	// RateLimiter limits the number of VotePackages stored per second for
+	// each CensusRoot. If not set, no limit is applied until one is set
+	// with SetRateLimiter.
+	RateLimiter *RateLimiter
+}
+
+// AppendOnly returns true if the SQLite is in AppendOnly mode, see
+// Options.AppendOnly
+func (r *SQLite) AppendOnly() bool {
+	return r.appendOnly
 }
 
 // NewSQLite returns a new *SQLite database
 func NewSQLite(db *sql.DB) *SQLite {
-	return &SQLite{
-		db: db,
-	}
+	return NewSQLiteWithOptions(db, Options{})
 }
 
-// Migrate creates the tables needed for the database
-func (r *SQLite) Migrate() error {
-	query := `
-	PRAGMA foreign_keys = ON;
-	`
-	_, err := r.db.Exec(query)
-	if err != nil {
-		return err
+// NewSQLiteWithOptions returns a new *SQLite database, tuning the connection
+// pool of the given *sql.DB with the given Options
+func NewSQLiteWithOptions(db *sql.DB, opts Options) *SQLite {
+	if opts.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
 	}
-
-	query = `
-	CREATE TABLE IF NOT EXISTS processes(
-		id INTEGER NOT NULL PRIMARY KEY UNIQUE,
-		status INTEGER NOT NULL,
-		censusRoot BLOB NOT NULL,
-		censusSize INTEGER NOT NULL,
-		ethBlockNum INTEGER NOT NULL,
-		resPubStartBlock INTEGER NOT NULL,
-		resPubWindow INTEGER NOT NULL,
-		minParticipation INTEGER NOT NULL,
-		minPositiveVotes INTEGER NOT NULL,
-		type INTEGER NOT NULL,
-		insertedDatetime DATETIME
-	);
-	`
-	_, err = r.db.Exec(query)
-	if err != nil {
-		return err
+	if opts.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
 	}
+	maxVoteLen := opts.MaxVoteLen
+	if maxVoteLen <= 0 {
+		maxVoteLen = DefaultMaxVoteLen
+	}
+	maxVoteSlots := opts.MaxVoteSlots
+	if maxVoteSlots <= 0 {
+		maxVoteSlots = DefaultMaxVoteSlots
+	}
+	return &SQLite{
+		db:           db,
+		limiter:      opts.RateLimiter,
+		maxVoteLen:   maxVoteLen,
+		maxVoteSlots: maxVoteSlots,
+		appendOnly:   opts.AppendOnly,
+		retry:        opts.Retry.withDefaults(),
+		sleep:        time.Sleep,
+		stmts:        make(map[string]*sql.Stmt),
+		votesSubs: voteSubscriptions{
+			subs: make(map[string]map[*voteSubscriber]struct{}),
+		},
+	}
+}
 
-	query = `
-	CREATE TABLE IF NOT EXISTS votepackages(
-		indx INTEGER NOT NULL PRIMARY KEY UNIQUE,
-		publicKey BLOB NOT NULL UNIQUE,
-		weight BLOB NOT NULL,
-		merkleproof BLOB NOT NULL UNIQUE,
-		signature BLOB NOT NULL,
-		vote BLOB NOT NULL,
-		insertedDatetime DATETIME,
-		processID INTEGER NOT NULL,
-		FOREIGN KEY(processID) REFERENCES processes(id)
-	);
-	`
-	_, err = r.db.Exec(query)
+// prepare returns the prepared statement for the given sql query, which is
+// prepared only the first time and reused in the next calls. The returned
+// statement must not be closed, it is closed by SQLite.Close.
+func (r *SQLite) prepare(sqlQuery string) (*sql.Stmt, error) {
+	r.stmtsMu.Lock()
+	defer r.stmtsMu.Unlock()
+	if stmt, ok := r.stmts[sqlQuery]; ok {
+		return stmt, nil
+	}
+	stmt, err := r.db.Prepare(sqlQuery)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	r.stmts[sqlQuery] = stmt
+	return stmt, nil
+}
 
-	query = `
-	CREATE TABLE IF NOT EXISTS proofs(
-		proofid INTEGER NOT NULL PRIMARY KEY UNIQUE,
-		proof BLOB NOT NULL,
-		publicInputs BLOB NOT NULL,
-		insertedDatetime DATETIME,
-		proofAddedDatetime DATETIME,
-		processID INTEGER NOT NULL,
-		FOREIGN KEY(processID) REFERENCES processes(id)
-	);
-	`
-	_, err = r.db.Exec(query)
-	if err != nil {
-		return err
+// Close closes the subscriptions of SubscribeVotes, the cached prepared
+// statements and the underlying database
+func (r *SQLite) Close() error {
+	r.closeVoteSubscriptions()
+	r.stmtsMu.Lock()
+	defer r.stmtsMu.Unlock()
+	for sqlQuery, stmt := range r.stmts {
+		if err := stmt.Close(); err != nil {
+			return err
+		}
+		delete(r.stmts, sqlQuery)
 	}
+	return r.db.Close()
+}
 
-	query = `
-	CREATE TABLE IF NOT EXISTS meta(
-		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-		chainID INTEGER NOT NULL,
-		lastSyncBlockNum INTEGER NOT NULL,
-		lastUpdate DATETIME
-	);
+// Migrate creates the tables needed for the database, applying the pending
+// migrations in order until the latest schema version
+func (r *SQLite) Migrate() error {
+	query := `
+	PRAGMA foreign_keys = ON;
 	`
-	_, err = r.db.Exec(query)
+	_, err := r.db.Exec(query)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	return r.migrate(len(migrations))
 }
 
 // InitMeta initializes the meta table with the given chainID
@@ -164,6 +226,40 @@ func (r *SQLite) GetLastSyncBlockNum() (uint64, error) {
 	return uint64(lastSyncBlockNum), nil
 }
 
+// GetChainID gets the chainID from the meta unique row
+func (r *SQLite) GetChainID() (uint64, error) {
+	row := r.db.QueryRow("SELECT chainID FROM meta WHERE id = 1")
+
+	var chainID uint64
+	err := row.Scan(&chainID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrMetaNotInDB
+		}
+		return 0, err
+	}
+	return chainID, nil
+}
+
+// Counts contains the number of rows stored in the db tables
+type Counts struct {
+	Processes    uint64
+	VotePackages uint64
+}
+
+// Counts returns the number of Processes and VotePackages stored in the db,
+// obtained in a single query
+func (r *SQLite) Counts() (*Counts, error) {
+	row := r.db.QueryRow("SELECT (SELECT COUNT(*) FROM processes)," +
+		" (SELECT COUNT(*) FROM votepackages)")
+
+	var counts Counts
+	if err := row.Scan(&counts.Processes, &counts.VotePackages); err != nil {
+		return nil, fmt.Errorf("Counts error: %s", err)
+	}
+	return &counts, nil
+}
+
 // func (r *SQLite) ReadVotePackagesByCensusRoot(processID uint64) ([]types.VotePackage, error) {
 // func (r *SQLite) ReadVoteByPublicKeyAndCensusRoot(censusRoot []byte) (
 // 	[]types.VotePackage, error) {
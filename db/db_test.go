@@ -2,13 +2,25 @@ package db
 
 import (
 	"database/sql"
+	"math/big"
 	"path/filepath"
+	"strconv"
 	"testing"
 
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
 	qt "github.com/frankban/quicktest"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// testCensusRoot returns a CensusRoot with the expected length, filled with the
+// given name
+func testCensusRoot(name string) []byte {
+	censusRoot := make([]byte, types.RootLen)
+	copy(censusRoot, name)
+	return censusRoot
+}
+
 func TestMetaTable(t *testing.T) {
 	c := qt.New(t)
 
@@ -34,3 +46,72 @@ func TestMetaTable(t *testing.T) {
 	c.Assert(err, qt.IsNil)
 	c.Assert(b, qt.Equals, uint64(1234))
 }
+
+func TestOptionsAndClose(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLiteWithOptions(db, Options{MaxOpenConns: 4, MaxIdleConns: 2})
+	c.Assert(db.Stats().MaxOpenConnections, qt.Equals, 4)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	// expect the prepared statement to be reused
+	stmt1, err := sqlite.prepare("SELECT lastSyncBlockNum FROM meta WHERE id = ?")
+	c.Assert(err, qt.IsNil)
+	stmt2, err := sqlite.prepare("SELECT lastSyncBlockNum FROM meta WHERE id = ?")
+	c.Assert(err, qt.IsNil)
+	c.Assert(stmt1 == stmt2, qt.IsTrue)
+	c.Assert(len(sqlite.stmts), qt.Equals, 1)
+
+	err = sqlite.Close()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(sqlite.stmts), qt.Equals, 0)
+	c.Assert(db.Ping(), qt.Not(qt.IsNil))
+}
+
+func TestCounts(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLite(db)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	counts, err := sqlite.Counts()
+	c.Assert(err, qt.IsNil)
+	c.Assert(*counts, qt.Equals, Counts{})
+
+	nProcesses := 3
+	nVotes := 4
+	keys := test.GenUserKeys(nProcesses * nVotes)
+	for processID := 0; processID < nProcesses; processID++ {
+		err = sqlite.StoreProcess(uint64(processID), testCensusRoot("censusRoot"),
+			100, 10, 20, 20, 60, 20, 1)
+		c.Assert(err, qt.IsNil)
+		for j := 0; j < nVotes; j++ {
+			i := processID*nVotes + j
+			vote := types.VotePackage{
+				Signature: types.CompressSignature(keys.PrivateKeys[i].SignPoseidon(big.NewInt(1))),
+				CensusProof: types.CensusProof{
+					Index:       uint64(i),
+					PublicKey:   &keys.PublicKeys[i],
+					Weight:      big.NewInt(1),
+					MerkleProof: []byte("test" + strconv.Itoa(i)),
+				},
+				Vote: []byte("test"),
+			}
+			err = sqlite.StoreVotePackage(uint64(processID), vote)
+			c.Assert(err, qt.IsNil)
+		}
+	}
+
+	counts, err = sqlite.Counts()
+	c.Assert(err, qt.IsNil)
+	c.Assert(counts.Processes, qt.Equals, uint64(nProcesses))
+	c.Assert(counts.VotePackages, qt.Equals, uint64(nProcesses*nVotes))
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrVoteAlreadyExists is used when trying to store a VotePackage with
+	// an index, PublicKey or MerkleProof already used by another stored
+	// VotePackage
+	ErrVoteAlreadyExists = errors.New("VotePackage already exists")
+	// ErrVoteNotFound is used when the requested VotePackage is not
+	// stored in the db
+	ErrVoteNotFound = errors.New("VotePackage not found")
+	// ErrNullifierUsed is used when trying to store a VotePackage with a
+	// Nullifier that has already been used by another stored VotePackage
+	ErrNullifierUsed = errors.New("Nullifier already used")
+	// ErrVoteTooLarge is used when trying to store a VotePackage with a
+	// vote longer than the maximum vote length
+	ErrVoteTooLarge = errors.New("Vote too large")
+	// ErrProcessNotFound is used when the requested Process, or the
+	// Process of a VotePackage, is not stored in the db
+	ErrProcessNotFound = errors.New("Process not found")
+	// ErrAppendOnly is used when trying to modify or delete stored
+	// VotePackages while the SQLite is in AppendOnly mode
+	ErrAppendOnly = errors.New("SQLite in AppendOnly mode")
+	// ErrMaxVoteSlots is used when trying to store a VotePackage in a slot
+	// beyond the maximum number of slots per voter
+	ErrMaxVoteSlots = errors.New("Max vote slots per voter reached")
+	// ErrCompactInTx is used when trying to compact the database while a
+	// transaction or a statement is in progress in the same connection
+	ErrCompactInTx = errors.New("Can not compact the db inside an open transaction")
+	// ErrInvalidCensusRoot is used when the given CensusRoot does not have
+	// the length of the hash used in the Census MerkleTree
+	ErrInvalidCensusRoot = errors.New("Invalid CensusRoot length")
+	// ErrProcessNotFinished is used when a Process that uses a CensusRoot
+	// has not reached the ProcessStatusProofGenerated status yet
+	ErrProcessNotFinished = errors.New("Process not finished")
+)
+
+// checkCensusRoot returns ErrInvalidCensusRoot if the given CensusRoot does not
+// have types.RootLen bytes, as such CensusRoot can not match the CensusRoot
+// of any Census
+func checkCensusRoot(censusRoot []byte) error {
+	if len(censusRoot) != types.RootLen {
+		return fmt.Errorf("%w, expected %d bytes, got %d", ErrInvalidCensusRoot,
+			types.RootLen, len(censusRoot))
+	}
+	return nil
+}
+
+// DBError is the error returned by the SQLite methods when the database
+// returns an error. It contains the underlying error of the database driver,
+// and, when the error has been identified, the sentinel error that classifies
+// it, so it can be checked with errors.Is.
+type DBError struct {
+	// Op is the name of the SQLite method that returned the error
+	Op string
+	// Kind is the sentinel error that classifies the error, nil if the
+	// error has not been identified
+	Kind error
+	// Err is the underlying error
+	Err error
+}
+
+// Error implements the error interface
+func (e *DBError) Error() string {
+	if e.Kind == nil {
+		return fmt.Sprintf("%s: %s", e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s, %s: %s", e.Kind, e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if the given target is the Kind of the DBError
+func (e *DBError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// newDBError returns a new DBError for the given SQLite method, classifying
+// the given error by its sqlite3 error code if it is a known failure
+func newDBError(op string, err error) error {
+	var kind error
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch {
+		case sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey:
+			// the only foreign keys are the processID of the
+			// votepackages and proofs
+			kind = ErrProcessNotFound
+		case sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique,
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey,
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintTrigger:
+			kind = uniqueConstraintKind(sqliteErr)
+		case sqliteErr.Code == sqlite3.ErrError && isVacuumInTxErr(sqliteErr):
+			kind = ErrCompactInTx
+		}
+	}
+	return &DBError{Op: op, Kind: kind, Err: err}
+}
+
+// uniqueConstraintKind returns the sentinel error of the given failure of an
+// UNIQUE or PRIMARY KEY constraint, or of the triggers that check the
+// nullifiers across tables, nil if it is not a constraint of the
+// VotePackages. The error code does not identify the constraint, so its
+// columns are read from the error message, which has the form
+// "UNIQUE constraint failed: table.column[, table.column]".
+func uniqueConstraintKind(sqliteErr sqlite3.Error) error {
+	i := strings.LastIndex(sqliteErr.Error(), ": ")
+	if i < 0 {
+		return nil
+	}
+	columns := sqliteErr.Error()[i+2:]
+	switch {
+	case columns == "votepackages.nullifier", columns == "voteslots.nullifier":
+		return ErrNullifierUsed
+	case strings.HasPrefix(columns, "votepackages."),
+		strings.HasPrefix(columns, "voteslots."):
+		return ErrVoteAlreadyExists
+	}
+	return nil
+}
+
+// isVacuumInTxErr returns true if the given error is returned by VACUUM
+// because a transaction or a statement is in progress, which SQLite reports
+// with the generic SQLITE_ERROR code, so it can only be identified by its
+// message
+func isVacuumInTxErr(sqliteErr sqlite3.Error) bool {
+	msg := sqliteErr.Error()
+	return msg == "cannot VACUUM from within a transaction" ||
+		msg == "cannot VACUUM - SQL statements in progress"
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestDBErrors(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLite(db)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	processID := uint64(123)
+	sk := babyjub.NewRandPrivKey()
+	vote := types.VotePackage{
+		Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+		CensusProof: types.CensusProof{
+			Index:       0,
+			PublicKey:   sk.Public(),
+			Weight:      big.NewInt(1),
+			MerkleProof: []byte("test0"),
+		},
+		Vote:      []byte("test"),
+		Nullifier: []byte("nullifier0"),
+	}
+
+	// Process not found
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(errors.Is(err, ErrProcessNotFound), qt.IsTrue)
+	_, err = sqlite.ReadProcessByID(processID)
+	c.Assert(errors.Is(err, ErrProcessNotFound), qt.IsTrue)
+	_, err = sqlite.GetProcessStatus(processID)
+	c.Assert(errors.Is(err, ErrProcessNotFound), qt.IsTrue)
+	err = sqlite.StoreProofID(processID, 42)
+	c.Assert(errors.Is(err, ErrProcessNotFound), qt.IsTrue)
+	var dbErr *DBError
+	c.Assert(errors.As(err, &dbErr), qt.IsTrue)
+	c.Assert(dbErr.Op, qt.Equals, "StoreProofID")
+
+	err = sqlite.StoreProcess(processID, testCensusRoot("root"), 10, 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+	// a repeated Process is not classified as a VotePackage error
+	err = sqlite.StoreProcess(processID, testCensusRoot("root"), 10, 10, 20, 20, 60, 20, 1)
+	c.Assert(errors.As(err, &dbErr), qt.IsTrue)
+	c.Assert(dbErr.Kind, qt.IsNil)
+
+	// VotePackage not found
+	_, err = sqlite.ReadVotePackageByPublicKey(processID, vote.CensusProof.PublicKey)
+	c.Assert(errors.Is(err, ErrVoteNotFound), qt.IsTrue)
+
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(err, qt.IsNil)
+	storedVote, err := sqlite.ReadVotePackageByPublicKey(processID,
+		vote.CensusProof.PublicKey)
+	c.Assert(err, qt.IsNil)
+	c.Assert(storedVote.CensusProof.Index, qt.Equals, vote.CensusProof.Index)
+	c.Assert(storedVote.Vote, qt.DeepEquals, vote.Vote)
+
+	// VotePackage already exists, the underlying error is kept
+	vote.Nullifier = []byte("nullifier1")
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(errors.Is(err, ErrVoteAlreadyExists), qt.IsTrue)
+	c.Assert(errors.Is(err, ErrNullifierUsed), qt.IsFalse)
+	c.Assert(errors.As(err, &dbErr), qt.IsTrue)
+	c.Assert(dbErr.Op, qt.Equals, "StoreVotePackage")
+	c.Assert(dbErr.Err.Error(), qt.Equals,
+		"UNIQUE constraint failed: votepackages.indx")
+
+	// Nullifier used
+	sk2 := babyjub.NewRandPrivKey()
+	vote.CensusProof.Index = 1
+	vote.CensusProof.PublicKey = sk2.Public()
+	vote.CensusProof.MerkleProof = []byte("test1")
+	vote.Nullifier = []byte("nullifier0")
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(errors.Is(err, ErrNullifierUsed), qt.IsTrue)
+	c.Assert(errors.Is(err, ErrVoteAlreadyExists), qt.IsFalse)
+
+	// Vote too large
+	vote.Vote = make([]byte, DefaultMaxVoteLen+1)
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(errors.Is(err, ErrVoteTooLarge), qt.IsTrue)
+}
+
+func TestInvalidCensusRoot(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLite(db)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	sk := babyjub.NewRandPrivKey()
+	vote := types.VotePackage{
+		Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+		CensusProof: types.CensusProof{
+			Index:       0,
+			PublicKey:   sk.Public(),
+			Weight:      big.NewInt(1),
+			MerkleProof: []byte("test0"),
+		},
+		Vote: []byte("test"),
+	}
+
+	// a CensusRoot with a missing byte
+	censusRoot := testCensusRoot("censusRoot")
+	wrongRoot := censusRoot[:types.RootLen-1]
+
+	err = sqlite.StoreProcess(123, wrongRoot, 10, 10, 20, 20, 60, 20, 1)
+	c.Assert(errors.Is(err, ErrInvalidCensusRoot), qt.IsTrue)
+	_, err = sqlite.ReadProcessByID(123)
+	c.Assert(errors.Is(err, ErrProcessNotFound), qt.IsTrue)
+	err = sqlite.StoreVotePackageSlot(wrongRoot, 0, vote)
+	c.Assert(errors.Is(err, ErrInvalidCensusRoot), qt.IsTrue)
+
+	_, err = sqlite.TallyByCensusRoot(wrongRoot)
+	c.Assert(errors.Is(err, ErrInvalidCensusRoot), qt.IsTrue)
+	_, _, err = sqlite.LastVoteTime(wrongRoot)
+	c.Assert(errors.Is(err, ErrInvalidCensusRoot), qt.IsTrue)
+	_, _, err = sqlite.ReadVotePackageSlots(wrongRoot, vote.CensusProof.PublicKey)
+	c.Assert(errors.Is(err, ErrInvalidCensusRoot), qt.IsTrue)
+	_, err = sqlite.ReadRejectedVotes(append(censusRoot, 0))
+	c.Assert(errors.Is(err, ErrInvalidCensusRoot), qt.IsTrue)
+	votesCh, errCh := sqlite.StreamVotePackagesByCensusRoot(context.Background(),
+		wrongRoot)
+	for range votesCh {
+		c.Fatal("no VotePackage expected")
+	}
+	c.Assert(errors.Is(<-errCh, ErrInvalidCensusRoot), qt.IsTrue)
+
+	// with the correct length
+	err = sqlite.StoreProcess(123, censusRoot, 10, 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackage(123, vote)
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackageSlot(censusRoot, 0, vote)
+	c.Assert(err, qt.IsNil)
+	tally, err := sqlite.TallyByCensusRoot(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(tally.NVotes, qt.Equals, uint64(1))
+	_, slots, err := sqlite.ReadVotePackageSlots(censusRoot, vote.CensusProof.PublicKey)
+	c.Assert(err, qt.IsNil)
+	c.Assert(slots, qt.DeepEquals, []uint64{0})
+}
@@ -0,0 +1,296 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go.vocdoni.io/dvote/log"
+)
+
+// migration defines a change of the database schema, which is applied inside
+// a db transaction. The migrations must be idempotent, so they can be applied
+// to the databases created before the schema_migrations table existed, which
+// start from version 0.
+type migration struct {
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations contains the ordered list of migrations, where the schema
+// version after applying migrations[i] is i+1. New migrations must be
+// appended at the end, and the existing ones must not be modified.
+var migrations = []migration{
+	{description: "initial schema", up: migrateInitialSchema},
+	{description: "votepackages nullifier", up: migrateVotePackagesNullifier},
+	{description: "voteslots", up: migrateVoteSlots},
+	{description: "rejected_votes", up: migrateRejectedVotes},
+	{description: "signature schemes", up: migrateSignatureSchemes},
+	{description: "voteslots nullifier", up: migrateVoteSlotsNullifier},
+}
+
+// SchemaVersion returns the version of the current schema of the database,
+// which is the number of migrations applied
+func (r *SQLite) SchemaVersion() (int, error) {
+	if err := r.createSchemaMigrations(); err != nil {
+		return 0, err
+	}
+	return schemaVersion(r.db)
+}
+
+func (r *SQLite) createSchemaMigrations() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations(
+		version INTEGER NOT NULL PRIMARY KEY UNIQUE,
+		appliedDatetime DATETIME
+	);
+	`
+	_, err := r.db.Exec(query)
+	return err
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func schemaVersion(q queryRower) (int, error) {
+	row := q.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations")
+	var version int
+	if err := row.Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// migrate applies in order the migrations that have not been applied yet,
+// until reaching the given schema version. Each migration is applied in its
+// own db transaction together with the update of its version, so a failed
+// migration does not leave the schema in an intermediate state.
+func (r *SQLite) migrate(target int) error {
+	if err := r.createSchemaMigrations(); err != nil {
+		return err
+	}
+	version, err := schemaVersion(r.db)
+	if err != nil {
+		return err
+	}
+	for ; version < target; version++ {
+		if err := r.applyMigration(version + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLite) applyMigration(version int) error {
+	m := migrations[version-1]
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("can not apply migration %d (%s): %s",
+			version, m.description, err)
+	}
+	_, err = tx.Exec(`INSERT INTO schema_migrations(version, appliedDatetime)
+	values(?, CURRENT_TIMESTAMP)`, version)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Debugf("applied db migration %d (%s)", version, m.description)
+	return nil
+}
+
+func migrateInitialSchema(tx *sql.Tx) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS processes(
+		id INTEGER NOT NULL PRIMARY KEY UNIQUE,
+		status INTEGER NOT NULL,
+		censusRoot BLOB NOT NULL,
+		censusSize INTEGER NOT NULL,
+		ethBlockNum INTEGER NOT NULL,
+		resPubStartBlock INTEGER NOT NULL,
+		resPubWindow INTEGER NOT NULL,
+		minParticipation INTEGER NOT NULL,
+		minPositiveVotes INTEGER NOT NULL,
+		type INTEGER NOT NULL,
+		insertedDatetime DATETIME
+	);
+	`
+	_, err := tx.Exec(query)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	CREATE TABLE IF NOT EXISTS votepackages(
+		indx INTEGER NOT NULL PRIMARY KEY UNIQUE,
+		publicKey BLOB NOT NULL UNIQUE,
+		weight BLOB NOT NULL,
+		merkleproof BLOB NOT NULL UNIQUE,
+		signature BLOB NOT NULL,
+		vote BLOB NOT NULL,
+		insertedDatetime DATETIME,
+		processID INTEGER NOT NULL,
+		FOREIGN KEY(processID) REFERENCES processes(id)
+	);
+	`
+	_, err = tx.Exec(query)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	CREATE TABLE IF NOT EXISTS proofs(
+		proofid INTEGER NOT NULL PRIMARY KEY UNIQUE,
+		proof BLOB NOT NULL,
+		publicInputs BLOB NOT NULL,
+		insertedDatetime DATETIME,
+		proofAddedDatetime DATETIME,
+		processID INTEGER NOT NULL,
+		FOREIGN KEY(processID) REFERENCES processes(id)
+	);
+	`
+	_, err = tx.Exec(query)
+	if err != nil {
+		return err
+	}
+
+	query = `
+	CREATE TABLE IF NOT EXISTS meta(
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		chainID INTEGER NOT NULL,
+		lastSyncBlockNum INTEGER NOT NULL,
+		lastUpdate DATETIME
+	);
+	`
+	_, err = tx.Exec(query)
+	return err
+}
+
+// migrateVotePackagesNullifier adds the nullifier column to the votepackages
+// table, unless it already exists. The existing rows get a NULL nullifier,
+// which is not affected by the uniqueness of the column.
+func migrateVotePackagesNullifier(tx *sql.Tx) error {
+	row := tx.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info('votepackages') WHERE name = 'nullifier'")
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return err
+	}
+	if n != 0 {
+		return nil
+	}
+
+	// UNIQUE can not be used in ALTER TABLE ADD COLUMN, an unique index
+	// is used instead
+	query := `
+	ALTER TABLE votepackages ADD COLUMN nullifier BLOB;
+	CREATE UNIQUE INDEX IF NOT EXISTS votepackages_nullifier ON votepackages(nullifier);
+	`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateVoteSlots creates the voteslots table, which stores multiple
+// VotePackages per voter, one for each slot
+func migrateVoteSlots(tx *sql.Tx) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS voteslots(
+		censusRoot BLOB NOT NULL,
+		publicKey BLOB NOT NULL,
+		slot INTEGER NOT NULL,
+		indx INTEGER NOT NULL,
+		weight BLOB NOT NULL,
+		merkleproof BLOB NOT NULL,
+		signature BLOB NOT NULL,
+		vote BLOB NOT NULL,
+		insertedDatetime DATETIME,
+		nullifier BLOB,
+		PRIMARY KEY(censusRoot, publicKey, slot)
+	);
+	`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateRejectedVotes creates the rejected_votes table, which stores the
+// VotePackages that failed the verification done after storing them
+func migrateRejectedVotes(tx *sql.Tx) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS rejected_votes(
+		censusRoot BLOB NOT NULL,
+		processID INTEGER NOT NULL,
+		indx INTEGER NOT NULL,
+		publicKey BLOB NOT NULL,
+		weight BLOB NOT NULL,
+		merkleproof BLOB NOT NULL,
+		signature BLOB NOT NULL,
+		vote BLOB NOT NULL,
+		insertedDatetime DATETIME,
+		nullifier BLOB,
+		reason TEXT NOT NULL,
+		rejectedDatetime DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS rejected_votes_censusRoot ON rejected_votes(censusRoot);
+	`
+	_, err := tx.Exec(query)
+	return err
+}
+
+// migrateSignatureSchemes adds the signatureScheme column to the tables that
+// store VotePackages, unless it already exists. The existing rows get the
+// scheme 0 (types.SignatureSchemeEdDSAPoseidon), which is the scheme of the
+// Signatures stored before the column existed.
+func migrateSignatureSchemes(tx *sql.Tx) error {
+	for _, table := range []string{"votepackages", "voteslots", "rejected_votes"} {
+		row := tx.QueryRow(
+			"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'signatureScheme'",
+			table)
+		var n int
+		if err := row.Scan(&n); err != nil {
+			return err
+		}
+		if n != 0 {
+			continue
+		}
+		_, err := tx.Exec("ALTER TABLE " + table + //nolint:gosec // constant table names
+			" ADD COLUMN signatureScheme INTEGER NOT NULL DEFAULT 0")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateVoteSlotsNullifier makes the nullifiers unique across the
+// votepackages and the voteslots tables: the unique index rejects the
+// nullifiers already used by another slot, and the triggers the nullifiers
+// already used in the other table. The triggers fail with the message of
+// the unique constraints, so their failures are classified as the ones of
+// the unique indexes (see uniqueConstraintKind).
+func migrateVoteSlotsNullifier(tx *sql.Tx) error {
+	query := `
+	CREATE UNIQUE INDEX IF NOT EXISTS voteslots_nullifier ON voteslots(nullifier);
+	CREATE TRIGGER IF NOT EXISTS voteslots_nullifier_used
+	BEFORE INSERT ON voteslots
+	WHEN NEW.nullifier IS NOT NULL AND EXISTS (
+		SELECT 1 FROM votepackages WHERE nullifier = NEW.nullifier)
+	BEGIN
+		SELECT RAISE(ABORT, 'UNIQUE constraint failed: voteslots.nullifier');
+	END;
+	CREATE TRIGGER IF NOT EXISTS votepackages_nullifier_used
+	BEFORE INSERT ON votepackages
+	WHEN NEW.nullifier IS NOT NULL AND EXISTS (
+		SELECT 1 FROM voteslots WHERE nullifier = NEW.nullifier)
+	BEGIN
+		SELECT RAISE(ABORT, 'UNIQUE constraint failed: votepackages.nullifier');
+	END;
+	`
+	_, err := tx.Exec(query)
+	return err
+}
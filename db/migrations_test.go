@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func columnExists(c *qt.C, db *sql.DB, table, column string) bool {
+	row := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?",
+		table, column)
+	var n int
+	c.Assert(row.Scan(&n), qt.IsNil)
+	return n != 0
+}
+
+func TestMigrateFromEmpty(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+	version, err := sqlite.SchemaVersion()
+	c.Assert(err, qt.IsNil)
+	c.Assert(version, qt.Equals, 0)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+	version, err = sqlite.SchemaVersion()
+	c.Assert(err, qt.IsNil)
+	c.Assert(version, qt.Equals, len(migrations))
+	c.Assert(columnExists(c, db, "votepackages", "nullifier"), qt.IsTrue)
+
+	// migrating again does not apply any migration
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+	var n int
+	row := db.QueryRow("SELECT COUNT(*) FROM schema_migrations")
+	c.Assert(row.Scan(&n), qt.IsNil)
+	c.Assert(n, qt.Equals, len(migrations))
+}
+
+func TestMigrateFromV1(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+	err = sqlite.migrate(1)
+	c.Assert(err, qt.IsNil)
+	version, err := sqlite.SchemaVersion()
+	c.Assert(err, qt.IsNil)
+	c.Assert(version, qt.Equals, 1)
+	c.Assert(columnExists(c, db, "votepackages", "nullifier"), qt.IsFalse)
+
+	err = sqlite.StoreProcess(123, testCensusRoot("root"), 10, 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+	_, err = db.Exec(`INSERT INTO votepackages VALUES(0, x'00', x'01', x'02',
+		x'03', x'04', CURRENT_TIMESTAMP, 123);`)
+	c.Assert(err, qt.IsNil)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+	version, err = sqlite.SchemaVersion()
+	c.Assert(err, qt.IsNil)
+	c.Assert(version, qt.Equals, len(migrations))
+	c.Assert(columnExists(c, db, "votepackages", "nullifier"), qt.IsTrue)
+	c.Assert(columnExists(c, db, "votepackages", "signatureScheme"), qt.IsTrue)
+	c.Assert(columnExists(c, db, "voteslots", "signatureScheme"), qt.IsTrue)
+	c.Assert(columnExists(c, db, "rejected_votes", "signatureScheme"), qt.IsTrue)
+
+	// the existing data is kept, the existing Signatures get the default
+	// SignatureScheme
+	var n int
+	row := db.QueryRow("SELECT COUNT(*) FROM votepackages WHERE nullifier IS NULL")
+	c.Assert(row.Scan(&n), qt.IsNil)
+	c.Assert(n, qt.Equals, 1)
+	row = db.QueryRow("SELECT COUNT(*) FROM votepackages WHERE signatureScheme = 0")
+	c.Assert(row.Scan(&n), qt.IsNil)
+	c.Assert(n, qt.Equals, 1)
+	process, err := sqlite.ReadProcessByID(123)
+	c.Assert(err, qt.IsNil)
+	c.Assert(process.CensusRoot, qt.DeepEquals, testCensusRoot("root"))
+}
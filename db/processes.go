@@ -14,6 +14,9 @@ import (
 func (r *SQLite) StoreProcess(id uint64, censusRoot []byte, censusSize,
 	ethBlockNum, resPubStartBlock, resPubWindow uint64, minParticipation,
 	minPositiveVotes, typ uint8) error {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return err
+	}
 	sqlQuery := `
 	INSERT INTO processes(
 		id,
@@ -36,11 +39,14 @@ func (r *SQLite) StoreProcess(id uint64, censusRoot []byte, censusSize,
 	}
 	defer stmt.Close() //nolint:errcheck
 
-	_, err = stmt.Exec(id, types.ProcessStatusOn, censusRoot, censusSize,
-		ethBlockNum, resPubStartBlock, resPubWindow, minParticipation,
-		minPositiveVotes, typ)
-	if err != nil {
+	err = r.withRetry("StoreProcess", func() error {
+		_, err := stmt.Exec(id, types.ProcessStatusOn, censusRoot, censusSize,
+			ethBlockNum, resPubStartBlock, resPubWindow, minParticipation,
+			minPositiveVotes, typ)
 		return err
+	})
+	if err != nil {
+		return newDBError("StoreProcess", err)
 	}
 	return nil
 }
@@ -58,9 +64,12 @@ func (r *SQLite) UpdateProcessStatus(id uint64, status types.ProcessStatus) erro
 	}
 	defer stmt.Close() //nolint:errcheck
 
-	_, err = stmt.Exec(int(status), id)
-	if err != nil {
+	err = r.withRetry("UpdateProcessStatus", func() error {
+		_, err := stmt.Exec(int(status), id)
 		return err
+	})
+	if err != nil {
+		return newDBError("UpdateProcessStatus", err)
 	}
 	return nil
 }
@@ -73,9 +82,9 @@ func (r *SQLite) GetProcessStatus(id uint64) (types.ProcessStatus, error) {
 	err := row.Scan(&status)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, fmt.Errorf("ProcessID: %d, does not exist in the db", id)
+			return 0, fmt.Errorf("%w, ProcessID: %d", ErrProcessNotFound, id)
 		}
-		return 0, err
+		return 0, newDBError("GetProcessStatus", err)
 	}
 	return types.ProcessStatus(status), nil
 }
@@ -91,13 +100,36 @@ func (r *SQLite) ReadProcessByID(id uint64) (*types.Process, error) {
 		&process.MinPositiveVotes, &process.Type, &process.InsertedDatetime)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("ProcessID: %d, does not exist in the db", id)
+			return nil, fmt.Errorf("%w, ProcessID: %d", ErrProcessNotFound, id)
 		}
-		return nil, err
+		return nil, newDBError("ReadProcessByID", err)
 	}
 	return &process, nil
 }
 
+// CheckProcessesFinished returns ErrProcessNotFinished if any of the stored
+// processes with the given censusRoot does not have the status
+// ProcessStatusProofGenerated. It is used before deleting a Census, to ensure
+// that its CensusRoot is not needed anymore.
+func (r *SQLite) CheckProcessesFinished(censusRoot []byte) error {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return err
+	}
+	sqlQuery := `
+	SELECT id FROM processes WHERE censusRoot = ? AND status != ?
+	ORDER BY id ASC LIMIT 1
+	`
+	row := r.db.QueryRow(sqlQuery, censusRoot, types.ProcessStatusProofGenerated)
+	var id uint64
+	err := row.Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w, ProcessID: %d", ErrProcessNotFinished, id)
+}
+
 // ReadProcesses reads all the stored types.Process
 func (r *SQLite) ReadProcesses() ([]types.Process, error) {
 	sqlQuery := `
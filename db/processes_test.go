@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"path/filepath"
 	"testing"
 
@@ -23,7 +24,7 @@ func TestStoreProcess(t *testing.T) {
 
 	// prepare the votes
 	processID := uint64(123)
-	censusRoot := []byte("censusRoot")
+	censusRoot := testCensusRoot("censusRoot")
 	censusSize := uint64(100)
 	ethBlockNum := uint64(10)
 	resPubStartBlock := uint64(20)
@@ -42,7 +43,8 @@ func TestStoreProcess(t *testing.T) {
 		ethBlockNum, resPubStartBlock, resPubWindow, minParticipation,
 		minPositiveVotes, typ)
 	c.Assert(err, qt.Not(qt.IsNil))
-	c.Assert(err.Error(), qt.Equals, "UNIQUE constraint failed: processes.id")
+	c.Assert(errors.Unwrap(err).Error(), qt.Equals,
+		"UNIQUE constraint failed: processes.id")
 
 	// try to store the a different processID, but the same censusRoot,
 	// expecting no error
@@ -79,7 +81,7 @@ func TestProcessStatus(t *testing.T) {
 
 	// prepare the process
 	processID := uint64(123)
-	censusRoot := []byte("censusRoot")
+	censusRoot := testCensusRoot("censusRoot")
 	censusSize := uint64(100)
 	ethBlockNum := uint64(10)
 	resPubStartBlock := uint64(20)
@@ -114,6 +116,50 @@ func TestProcessStatus(t *testing.T) {
 	c.Assert(status, qt.Equals, types.ProcessStatusProofGenerated)
 }
 
+func TestCheckProcessesFinished(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+
+	// no processes with the censusRoot
+	err = sqlite.CheckProcessesFinished(censusRoot)
+	c.Assert(err, qt.IsNil)
+
+	for i := uint64(0); i < 2; i++ {
+		err = sqlite.StoreProcess(i, censusRoot, 100, 10, 20, 20, 60, 20, 1)
+		c.Assert(err, qt.IsNil)
+	}
+	err = sqlite.StoreProcess(2, testCensusRoot("otherRoot"), 100, 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	err = sqlite.CheckProcessesFinished(censusRoot)
+	c.Assert(errors.Is(err, ErrProcessNotFinished), qt.IsTrue)
+
+	err = sqlite.UpdateProcessStatus(0, types.ProcessStatusProofGenerated)
+	c.Assert(err, qt.IsNil)
+	err = sqlite.CheckProcessesFinished(censusRoot)
+	c.Assert(errors.Is(err, ErrProcessNotFinished), qt.IsTrue)
+	c.Assert(err, qt.ErrorMatches, ".*ProcessID: 1")
+
+	// the process with another censusRoot is not checked
+	err = sqlite.UpdateProcessStatus(1, types.ProcessStatusProofGenerated)
+	c.Assert(err, qt.IsNil)
+	err = sqlite.CheckProcessesFinished(censusRoot)
+	c.Assert(err, qt.IsNil)
+
+	err = sqlite.CheckProcessesFinished([]byte("short"))
+	c.Assert(errors.Is(err, ErrInvalidCensusRoot), qt.IsTrue)
+}
+
 func TestProcessesByStatus(t *testing.T) {
 	c := qt.New(t)
 
@@ -125,7 +171,7 @@ func TestProcessesByStatus(t *testing.T) {
 	err = sqlite.Migrate()
 	c.Assert(err, qt.IsNil)
 
-	censusRoot := []byte("censusRoot")
+	censusRoot := testCensusRoot("censusRoot")
 	censusSize := uint64(100)
 	ethBlockNum := uint64(10)
 	resPubStartBlock := uint64(20)
@@ -172,7 +218,7 @@ func TestProcessByResPubStartBlock(t *testing.T) {
 	c.Assert(err, qt.IsNil)
 
 	processID := uint64(123)
-	censusRoot := []byte("censusRoot")
+	censusRoot := testCensusRoot("censusRoot")
 	censusSize := uint64(100)
 	ethBlockNum := uint64(10)
 	resPubStartBlock := uint64(20)
@@ -231,7 +277,7 @@ func TestFrozeProcessesByCurrentBlockNum(t *testing.T) {
 	c.Assert(err, qt.IsNil)
 
 	processID := uint64(123)
-	censusRoot := []byte("censusRoot")
+	censusRoot := testCensusRoot("censusRoot")
 	censusSize := uint64(100)
 	ethBlockNum := uint64(10)
 	resPubStartBlock := uint64(20)
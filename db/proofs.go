@@ -33,13 +33,13 @@ func (r *SQLite) StoreProofID(processID, proofID uint64) error {
 	defer stmt.Close() //nolint:errcheck
 
 	emptyBytes := []byte{}
-	_, err = stmt.Exec(proofID, emptyBytes, emptyBytes, time.Time{}, processID)
-	if err != nil {
-		if err.Error() == "FOREIGN KEY constraint failed" {
-			return fmt.Errorf("Can not store Proof, ProcessID=%d does not exist",
-				processID)
-		}
+	err = r.withRetry("StoreProofID", func() error {
+		_, err := stmt.Exec(proofID, emptyBytes, emptyBytes, time.Time{},
+			processID)
 		return err
+	})
+	if err != nil {
+		return newDBError("StoreProofID", err)
 	}
 	return nil
 }
@@ -61,9 +61,12 @@ func (r *SQLite) AddProofToProofID(processID, proofID uint64, proof, publicInput
 	}
 	defer stmt.Close() //nolint:errcheck
 
-	_, err = stmt.Exec(proof, publicInputs, processID, proofID)
-	if err != nil {
+	err = r.withRetry("AddProofToProofID", func() error {
+		_, err := stmt.Exec(proof, publicInputs, processID, proofID)
 		return err
+	})
+	if err != nil {
+		return newDBError("AddProofToProofID", err)
 	}
 	return nil
 }
@@ -22,7 +22,7 @@ func TestProof(t *testing.T) {
 
 	// prepare the votes
 	processID := uint64(123)
-	censusRoot := []byte("censusRoot")
+	censusRoot := testCensusRoot("censusRoot")
 	censusSize := uint64(100)
 	ethBlockNum := uint64(10)
 	resPubStartBlock := uint64(20)
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is used when a VotePackage can not be stored because the
+// rate limit for its CensusRoot has been exceeded
+var ErrRateLimited = errors.New("Rate limit exceeded, try again later")
+
+// RateLimiter implements a token bucket rate limiter for each key, where each
+// key has its own independent bucket. The buckets that are full again are
+// dropped, as they are equivalent to the bucket of a new key, so the memory
+// used is bounded by the keys seen during the last refill period.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	// lastPrune is the time of the last removal of the full buckets
+	lastPrune time.Time
+	// now is used to get the current time, can be replaced in tests
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a new RateLimiter which allows rate events per second
+// for each key, with bursts of at most burst events
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow returns true if an event for the given key is allowed, consuming a
+// token from the key bucket
+func (l *RateLimiter) Allow(key []byte) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	// the full buckets are removed once per the time needed to refill an
+	// empty bucket, so the cost of the pruning is amortized
+	if now.Sub(l.lastPrune).Seconds()*l.rate >= l.burst {
+		l.prune(now)
+	}
+	b, ok := l.buckets[string(key)]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[string(key)] = b
+	}
+	// refill the bucket with the tokens generated since the last event
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune removes the buckets that have been refilled up to the burst since
+// their last event. It must be called with the mu locked.
+func (l *RateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPrune = now
+}
+
+// SetRateLimiter sets the RateLimiter used when storing VotePackages, which
+// limits the number of VotePackages stored per second for each CensusRoot. If
+// no RateLimiter is set (or is set to nil), no limit is applied. It can be
+// called while VotePackages are being stored, the RateLimiter can also be set
+// at construction with Options.RateLimiter.
+func (r *SQLite) SetRateLimiter(l *RateLimiter) {
+	r.limiterMu.Lock()
+	defer r.limiterMu.Unlock()
+	r.limiter = l
+}
+
+// rateLimiter returns the RateLimiter set with SetRateLimiter, nil if no
+// RateLimiter is set
+func (r *SQLite) rateLimiter() *RateLimiter {
+	r.limiterMu.RLock()
+	defer r.limiterMu.RUnlock()
+	return r.limiter
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/aragon/ovote-node/types"
+)
+
+// RejectedVote contains a VotePackage that has been moved to the
+// rejected_votes table with RejectVotePackage, together with the reason of
+// its rejection
+type RejectedVote struct {
+	VotePackage types.VotePackage
+	ProcessID   uint64
+	Reason      string
+	// RejectedDatetime contains the datetime of when the VotePackage was
+	// rejected
+	RejectedDatetime time.Time
+}
+
+// RejectVotePackage moves the stored types.VotePackage of the given index for
+// the given ProcessID from the votepackages table to the rejected_votes table,
+// storing the given reason of the rejection. Both operations are done in a
+// single db transaction. If the VotePackage is not stored, ErrVoteNotFound is
+// returned, and if the SQLite is in AppendOnly mode, ErrAppendOnly is
+// returned, and nothing is moved.
+func (r *SQLite) RejectVotePackage(processID, index uint64, reason string) error {
+	if r.appendOnly {
+		return fmt.Errorf("%w, can not reject VotePackages", ErrAppendOnly)
+	}
+	// the whole db transaction is retried, as it is rolled back when any
+	// of its statements fails
+	return r.withRetry("RejectVotePackage", func() error {
+		return r.rejectVotePackage(processID, index, reason)
+	})
+}
+
+func (r *SQLite) rejectVotePackage(processID, index uint64, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return newDBError("RejectVotePackage", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	sqlQuery := `
+	INSERT INTO rejected_votes(
+		censusRoot,
+		processID,
+		indx,
+		publicKey,
+		weight,
+		merkleproof,
+		signature,
+		signatureScheme,
+		vote,
+		insertedDatetime,
+		nullifier,
+		reason,
+		rejectedDatetime
+	)
+	SELECT p.censusRoot, v.processID, v.indx, v.publicKey, v.weight,
+	v.merkleproof, v.signature, v.signatureScheme, v.vote, v.insertedDatetime,
+	v.nullifier, ?,
+	CURRENT_TIMESTAMP FROM votepackages v
+	INNER JOIN processes p ON v.processID = p.id
+	WHERE v.processID = ? AND v.indx = ?
+	`
+	res, err := tx.Exec(sqlQuery, reason, processID, index)
+	if err != nil {
+		return newDBError("RejectVotePackage", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return newDBError("RejectVotePackage", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w, ProcessID: %d, index: %d", ErrVoteNotFound,
+			processID, index)
+	}
+
+	_, err = tx.Exec("DELETE FROM votepackages WHERE processID = ? AND indx = ?",
+		processID, index)
+	if err != nil {
+		return newDBError("RejectVotePackage", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return newDBError("RejectVotePackage", err)
+	}
+	return nil
+}
+
+// ReadRejectedVotes reads all the VotePackages of the processes with the given
+// CensusRoot that have been rejected with RejectVotePackage, sorted by
+// rejection datetime, from older to newer.
+func (r *SQLite) ReadRejectedVotes(censusRoot []byte) ([]RejectedVote, error) {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return nil, err
+	}
+	sqlQuery := `
+	SELECT signature, signatureScheme, indx, publicKey, weight, merkleproof, vote,
+	insertedDatetime, nullifier, processID, reason, rejectedDatetime
+	FROM rejected_votes
+	WHERE censusRoot = ?
+	ORDER BY datetime(rejectedDatetime) ASC, indx ASC
+	`
+
+	rows, err := r.db.Query(sqlQuery, censusRoot)
+	if err != nil {
+		return nil, newDBError("ReadRejectedVotes", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var rejected []RejectedVote
+	for rows.Next() {
+		rv := RejectedVote{}
+		var sigBytes []byte
+		var weightBytes []byte
+		var nullifier []byte
+		err := rows.Scan(&sigBytes, &rv.VotePackage.SignatureScheme,
+			&rv.VotePackage.CensusProof.Index,
+			&rv.VotePackage.CensusProof.PublicKey, &weightBytes,
+			&rv.VotePackage.CensusProof.MerkleProof, &rv.VotePackage.Vote,
+			&rv.VotePackage.InsertedDatetime, &nullifier, &rv.ProcessID,
+			&rv.Reason, &rv.RejectedDatetime)
+		if err != nil {
+			return nil, newDBError("ReadRejectedVotes", err)
+		}
+		rv.VotePackage.Nullifier = nullifier
+		rv.VotePackage.CensusProof.Weight = new(big.Int).SetBytes(weightBytes)
+		rv.VotePackage.Signature = sigBytes
+		rejected = append(rejected, rv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, newDBError("ReadRejectedVotes", err)
+	}
+	return rejected, nil
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestRejectVotePackage(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLite(db)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, censusRoot, 100, 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	var votes []types.VotePackage
+	for i := 0; i < 3; i++ {
+		sk := babyjub.NewRandPrivKey()
+		vote := types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(int64(i + 1)),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: []byte("vote"),
+		}
+		err = sqlite.StoreVotePackage(processID, vote)
+		c.Assert(err, qt.IsNil)
+		votes = append(votes, vote)
+	}
+
+	rejected, err := sqlite.ReadRejectedVotes(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(rejected), qt.Equals, 0)
+
+	err = sqlite.RejectVotePackage(processID, 1, "signature verification failed")
+	c.Assert(err, qt.IsNil)
+
+	// the VotePackage is moved out of the votepackages table
+	stored, err := sqlite.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(stored), qt.Equals, 2)
+	c.Assert(stored[0].CensusProof.Index, qt.Equals, uint64(0))
+	c.Assert(stored[1].CensusProof.Index, qt.Equals, uint64(2))
+
+	rejected, err = sqlite.ReadRejectedVotes(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(rejected), qt.Equals, 1)
+	c.Assert(rejected[0].ProcessID, qt.Equals, processID)
+	c.Assert(rejected[0].Reason, qt.Equals, "signature verification failed")
+	c.Assert(rejected[0].RejectedDatetime.IsZero(), qt.IsFalse)
+	rv := rejected[0].VotePackage
+	c.Assert(rv.Signature, qt.DeepEquals, votes[1].Signature)
+	c.Assert(rv.CensusProof.Index, qt.Equals, uint64(1))
+	c.Assert(rv.CensusProof.PublicKey.Compress(), qt.Equals,
+		votes[1].CensusProof.PublicKey.Compress())
+	c.Assert(rv.CensusProof.Weight.Cmp(votes[1].CensusProof.Weight), qt.Equals, 0)
+	c.Assert(rv.CensusProof.MerkleProof, qt.DeepEquals, votes[1].CensusProof.MerkleProof)
+	c.Assert(rv.Vote, qt.DeepEquals, votes[1].Vote)
+
+	rejected, err = sqlite.ReadRejectedVotes(testCensusRoot("otherCensusRoot"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(rejected), qt.Equals, 0)
+
+	// the index of the rejected VotePackage can be used again
+	err = sqlite.StoreVotePackage(processID, votes[1])
+	c.Assert(err, qt.IsNil)
+
+	err = sqlite.RejectVotePackage(processID, 42, "not stored")
+	c.Assert(errors.Is(err, ErrVoteNotFound), qt.IsTrue)
+
+	// in AppendOnly mode the VotePackages can not be rejected
+	sqlite.appendOnly = true
+	err = sqlite.RejectVotePackage(processID, 0, "append only")
+	c.Assert(errors.Is(err, ErrAppendOnly), qt.IsTrue)
+	rejected, err = sqlite.ReadRejectedVotes(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(rejected), qt.Equals, 1)
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.vocdoni.io/dvote/log"
+)
+
+const (
+	// DefaultRetryMaxAttempts defines the default maximum number of times
+	// that a write operation is tried when it fails with a transient error
+	DefaultRetryMaxAttempts = 5
+	// DefaultRetryInitialBackoff defines the default time waited before
+	// the first retry of a write operation, which is doubled on each retry
+	DefaultRetryInitialBackoff = 10 * time.Millisecond
+	// DefaultRetryMaxBackoff defines the default maximum time waited
+	// between two tries of a write operation
+	DefaultRetryMaxBackoff = time.Second
+)
+
+// RetryPolicy defines how the write operations of the SQLite are retried when
+// they fail because the database is busy or locked by another connection
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times that a write operation
+	// is tried, including the first one. If not set,
+	// DefaultRetryMaxAttempts is used. Set it to 1 to disable the retries.
+	MaxAttempts int
+	// InitialBackoff is the time waited before the first retry, which is
+	// doubled on each retry. If not set, DefaultRetryInitialBackoff is
+	// used.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum time waited between two tries. If not
+	// set, DefaultRetryMaxBackoff is used.
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	return p
+}
+
+// isTransientErr returns true if the given error is returned by the database
+// because it is busy or locked, so the operation can succeed if it is retried
+func isTransientErr(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+// withRetry calls the given function until it succeeds, it returns a non
+// transient error, or the maximum number of attempts of the RetryPolicy is
+// reached, waiting an exponential backoff between the tries. Returns the
+// error of the last try.
+func (r *SQLite) withRetry(op string, fn func() error) error {
+	backoff := r.retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isTransientErr(err) || attempt >= r.retry.MaxAttempts {
+			return err
+		}
+		log.Debugf("%s: transient error on attempt %d, retrying in %s: %s",
+			op, attempt, backoff, err)
+		r.sleep(backoff)
+		backoff *= 2
+		if backoff > r.retry.MaxBackoff {
+			backoff = r.retry.MaxBackoff
+		}
+	}
+}
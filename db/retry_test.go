@@ -0,0 +1,159 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestWithRetry(t *testing.T) {
+	c := qt.New(t)
+
+	sqlite := NewSQLiteWithOptions(nil, Options{Retry: RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     30 * time.Millisecond,
+	}})
+	var sleeps []time.Duration
+	sqlite.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	// a transient error that persists, expect the last error after
+	// MaxAttempts tries, with an exponential backoff capped at MaxBackoff
+	nCalls := 0
+	err := sqlite.withRetry("test", func() error {
+		nCalls++
+		return &DBError{Op: "test", Err: sqlite3.Error{Code: sqlite3.ErrBusy}}
+	})
+	c.Assert(err, qt.ErrorMatches, "test: database is locked")
+	c.Assert(nCalls, qt.Equals, 5)
+	c.Assert(sleeps, qt.DeepEquals, []time.Duration{10 * time.Millisecond,
+		20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond})
+
+	// a transient error that disappears
+	sleeps = nil
+	nCalls = 0
+	err = sqlite.withRetry("test", func() error {
+		nCalls++
+		if nCalls < 3 {
+			return sqlite3.Error{Code: sqlite3.ErrLocked}
+		}
+		return nil
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(nCalls, qt.Equals, 3)
+	c.Assert(len(sleeps), qt.Equals, 2)
+
+	// a non transient error is not retried
+	sleeps = nil
+	nCalls = 0
+	err = sqlite.withRetry("test", func() error {
+		nCalls++
+		return sqlite3.Error{Code: sqlite3.ErrConstraint,
+			ExtendedCode: sqlite3.ErrConstraintUnique}
+	})
+	c.Assert(err, qt.Not(qt.IsNil))
+	c.Assert(nCalls, qt.Equals, 1)
+	c.Assert(len(sleeps), qt.Equals, 0)
+
+	// neither an error that only has the message of a transient error
+	nCalls = 0
+	err = sqlite.withRetry("test", func() error {
+		nCalls++
+		return errors.New("database is locked")
+	})
+	c.Assert(err, qt.Not(qt.IsNil))
+	c.Assert(nCalls, qt.Equals, 1)
+
+	// the default RetryPolicy is used for the fields not set
+	c.Assert(NewSQLite(nil).retry, qt.Equals, RetryPolicy{
+		MaxAttempts:    DefaultRetryMaxAttempts,
+		InitialBackoff: DefaultRetryInitialBackoff,
+		MaxBackoff:     DefaultRetryMaxBackoff,
+	})
+}
+
+func TestStoreVotePackageRetry(t *testing.T) {
+	c := qt.New(t)
+
+	// disable the busy timeout of the driver, so the lock is reported
+	// immediately
+	dbPath := filepath.Join(c.TempDir(), "testdb.sqlite3") + "?_busy_timeout=0"
+	db, err := sql.Open("sqlite3", dbPath)
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLiteWithOptions(db, Options{Retry: RetryPolicy{MaxAttempts: 3}})
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, testCensusRoot("censusRoot"), 100, 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	genVote := func(i int) types.VotePackage {
+		sk := babyjub.NewRandPrivKey()
+		return types.VotePackage{
+			Signature: make([]byte, 64),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte{byte(i)},
+			},
+			Vote: []byte("test"),
+		}
+	}
+
+	// lock the db from another connection, which holds the write lock
+	// until its transaction is committed
+	locker, err := sql.Open("sqlite3", dbPath)
+	c.Assert(err, qt.IsNil)
+	defer locker.Close() //nolint:errcheck
+	lock := func() *sql.Tx {
+		tx, err := locker.Begin()
+		c.Assert(err, qt.IsNil)
+		_, err = tx.Exec("UPDATE processes SET status = status")
+		c.Assert(err, qt.IsNil)
+		return tx
+	}
+
+	// the lock is released while waiting for the first retry, expect the
+	// VotePackage to be stored in the second try
+	tx := lock()
+	nSleeps := 0
+	sqlite.sleep = func(time.Duration) {
+		nSleeps++
+		if nSleeps == 1 {
+			c.Assert(tx.Commit(), qt.IsNil)
+		}
+	}
+	err = sqlite.StoreVotePackage(processID, genVote(0))
+	c.Assert(err, qt.IsNil)
+	c.Assert(nSleeps, qt.Equals, 1)
+
+	// the lock is not released, expect the last error after MaxAttempts
+	tx = lock()
+	nSleeps = 0
+	sqlite.sleep = func(time.Duration) { nSleeps++ }
+	err = sqlite.StoreVotePackage(processID, genVote(1))
+	c.Assert(err, qt.ErrorMatches, "StoreVotePackage: database is locked.*")
+	c.Assert(nSleeps, qt.Equals, 2)
+	c.Assert(tx.Rollback(), qt.IsNil)
+
+	// a constraint violation is not retried
+	nSleeps = 0
+	err = sqlite.StoreVotePackage(processID, genVote(0))
+	c.Assert(errors.Is(err, ErrVoteAlreadyExists), qt.IsTrue)
+	c.Assert(nSleeps, qt.Equals, 0)
+
+	votes, err := sqlite.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(votes), qt.Equals, 1)
+}
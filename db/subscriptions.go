@@ -0,0 +1,115 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// SubscriptionBuffer defines the number of VotePackages that can be waiting
+// to be received by each subscriber of SubscribeVotes. When the buffer of a
+// subscriber is full, the new VotePackages are dropped for that subscriber,
+// so a slow subscriber never blocks the storage of the VotePackages.
+const SubscriptionBuffer = 64
+
+// voteSubscriber contains the channel of a subscriber of SubscribeVotes
+type voteSubscriber struct {
+	ch chan types.VotePackage
+}
+
+// voteSubscriptions contains the subscribers of SubscribeVotes by CensusRoot
+type voteSubscriptions struct {
+	mu   sync.Mutex
+	subs map[string]map[*voteSubscriber]struct{}
+}
+
+// SubscribeVotes returns a channel through which each VotePackage stored
+// after the subscription for the processes with the given CensusRoot is
+// sent, once it has been stored, and a function to unsubscribe, which closes
+// the channel. The subscriptions are also closed by SQLite.Close. If the
+// subscriber does not keep up and its SubscriptionBuffer is full, the new
+// VotePackages are dropped for it. The sent VotePackages do not contain the
+// InsertedDatetime.
+func (r *SQLite) SubscribeVotes(censusRoot []byte) (<-chan types.VotePackage, func()) {
+	s := &voteSubscriber{ch: make(chan types.VotePackage, SubscriptionBuffer)}
+	key := string(censusRoot)
+
+	r.votesSubs.mu.Lock()
+	if _, ok := r.votesSubs.subs[key]; !ok {
+		r.votesSubs.subs[key] = make(map[*voteSubscriber]struct{})
+	}
+	r.votesSubs.subs[key][s] = struct{}{}
+	r.votesSubs.mu.Unlock()
+
+	unsubscribe := func() {
+		r.votesSubs.mu.Lock()
+		defer r.votesSubs.mu.Unlock()
+		subs, ok := r.votesSubs.subs[key]
+		if !ok {
+			return
+		}
+		if _, ok := subs[s]; !ok {
+			return
+		}
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(r.votesSubs.subs, key)
+		}
+		close(s.ch)
+	}
+	return s.ch, unsubscribe
+}
+
+// hasVoteSubscribers returns true if there is any subscriber of SubscribeVotes
+func (r *SQLite) hasVoteSubscribers() bool {
+	r.votesSubs.mu.Lock()
+	defer r.votesSubs.mu.Unlock()
+	return len(r.votesSubs.subs) != 0
+}
+
+// publishVote sends the given stored VotePackage of the given processID to
+// the subscribers of its CensusRoot, without blocking on the subscribers
+// that have their buffer full
+func (r *SQLite) publishVote(processID uint64, vote types.VotePackage) error {
+	if !r.hasVoteSubscribers() {
+		return nil
+	}
+	row := r.db.QueryRow("SELECT censusRoot FROM processes WHERE id = ?", processID)
+	var censusRoot []byte
+	if err := row.Scan(&censusRoot); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w, can not publish VotePackage, ProcessID=%d",
+				ErrProcessNotFound, processID)
+		}
+		return newDBError("publishVote", err)
+	}
+
+	r.votesSubs.mu.Lock()
+	defer r.votesSubs.mu.Unlock()
+	for s := range r.votesSubs.subs[string(censusRoot)] {
+		select {
+		case s.ch <- vote:
+		default:
+			log.Debugf("[ProcessID=%d] subscriber buffer full, VotePackage"+
+				" %d dropped", processID, vote.CensusProof.Index)
+		}
+	}
+	return nil
+}
+
+// closeVoteSubscriptions closes the channels of all the subscribers of
+// SubscribeVotes
+func (r *SQLite) closeVoteSubscriptions() {
+	r.votesSubs.mu.Lock()
+	defer r.votesSubs.mu.Unlock()
+	for key, subs := range r.votesSubs.subs {
+		for s := range subs {
+			close(s.ch)
+		}
+		delete(r.votesSubs.subs, key)
+	}
+}
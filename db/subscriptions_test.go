@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSubscribeVotes(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLite(db)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, censusRoot, 1000, 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+	otherProcessID := uint64(124)
+	err = sqlite.StoreProcess(otherProcessID, testCensusRoot("otherCensusRoot"), 1000,
+		10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	newVote := func(index int) types.VotePackage {
+		sk := babyjub.NewRandPrivKey()
+		return types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(index),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(int64(index + 1)),
+				MerkleProof: []byte("test" + strconv.Itoa(index)),
+			},
+			Vote: []byte("vote" + strconv.Itoa(index)),
+		}
+	}
+
+	votesCh, unsubscribe := sqlite.SubscribeVotes(censusRoot)
+
+	// the votes of another CensusRoot are not received
+	err = sqlite.StoreVotePackage(otherProcessID, newVote(0))
+	c.Assert(err, qt.IsNil)
+	// a failed store is not published
+	vote := newVote(1)
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	c.Assert(len(votesCh), qt.Equals, 1)
+	received := <-votesCh
+	c.Assert(received.CensusProof.Index, qt.Equals, uint64(1))
+	c.Assert(received.CensusProof.PublicKey.Compress(), qt.Equals,
+		vote.CensusProof.PublicKey.Compress())
+	c.Assert(received.Vote, qt.DeepEquals, vote.Vote)
+	c.Assert(received.Signature, qt.DeepEquals, vote.Signature)
+
+	// a slow subscriber does not block the storage, the votes beyond
+	// its buffer are dropped
+	slowCh, unsubscribeSlow := sqlite.SubscribeVotes(censusRoot)
+	for i := 2; i < SubscriptionBuffer+12; i++ {
+		err = sqlite.StoreVotePackage(processID, newVote(i))
+		c.Assert(err, qt.IsNil)
+	}
+	c.Assert(len(slowCh), qt.Equals, SubscriptionBuffer)
+	for i := 2; i < SubscriptionBuffer+2; i++ {
+		received := <-slowCh
+		c.Assert(received.CensusProof.Index, qt.Equals, uint64(i))
+	}
+	c.Assert(len(votesCh), qt.Equals, SubscriptionBuffer)
+
+	// unsubscribing closes the channel, and can be called more than once
+	unsubscribeSlow()
+	unsubscribeSlow()
+	_, ok := <-slowCh
+	c.Assert(ok, qt.IsFalse)
+
+	// Close closes the rest of subscriptions
+	err = sqlite.Close()
+	c.Assert(err, qt.IsNil)
+	n := 0
+	for range votesCh {
+		n++
+	}
+	c.Assert(n, qt.Equals, SubscriptionBuffer)
+	unsubscribe()
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/vocdoni/arbo"
+)
+
+// Tally contains the weight of the stored votes of a CensusRoot for each
+// option. As in the circuit, a vote with value 1 is counted as "yes", and any
+// other value as "no".
+type Tally struct {
+	// NVotes is the number of counted votes
+	NVotes uint64
+	// Yes is the sum of the weights of the "yes" votes
+	Yes *big.Int
+	// No is the sum of the weights of the "no" votes
+	No *big.Int
+}
+
+// QuorumResult contains the outcome of a vote checked with
+// QuorumByCensusRoot
+type QuorumResult struct {
+	// TotalWeight is the total eligible weight of the Census
+	TotalWeight *big.Int
+	// VotedWeight is the sum of the weights of the stored votes
+	VotedWeight *big.Int
+	// Tally contains the weight of each option
+	Tally Tally
+	// QuorumMet is true if the VotedWeight reaches the threshold
+	// percentage of the TotalWeight
+	QuorumMet bool
+	// YesWon is true if the "yes" weight is greater than the "no" weight,
+	// so a tie does not pass
+	YesWon bool
+	// Passed is true if QuorumMet and YesWon are true
+	Passed bool
+}
+
+// TallyByCensusRoot counts the weights of the stored votes of the processes
+// with the given CensusRoot for each option. The VotePackages are not
+// verified, use VerifyStoredVotes to check them before.
+func (r *SQLite) TallyByCensusRoot(censusRoot []byte) (*Tally, error) {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return nil, err
+	}
+	sqlQuery := `
+	SELECT v.weight, v.vote FROM votepackages v
+	INNER JOIN processes p ON v.processID = p.id
+	WHERE p.censusRoot = ?
+	`
+
+	rows, err := r.db.Query(sqlQuery, censusRoot)
+	if err != nil {
+		return nil, newDBError("TallyByCensusRoot", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	tally := &Tally{Yes: big.NewInt(0), No: big.NewInt(0)}
+	one := big.NewInt(1)
+	for rows.Next() {
+		var weightBytes, vote []byte
+		if err := rows.Scan(&weightBytes, &vote); err != nil {
+			return nil, newDBError("TallyByCensusRoot", err)
+		}
+		weight := new(big.Int).SetBytes(weightBytes)
+		if arbo.BytesToBigInt(vote).Cmp(one) == 0 {
+			tally.Yes.Add(tally.Yes, weight)
+		} else {
+			tally.No.Add(tally.No, weight)
+		}
+		tally.NVotes++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, newDBError("TallyByCensusRoot", err)
+	}
+	return tally, nil
+}
+
+// SumVoteWeightByCensusRoot returns the sum of the weights of the stored
+// votes of the processes with the given CensusRoot. The weights are stored as
+// bytes, so they are summed here instead of in the query.
+func (r *SQLite) SumVoteWeightByCensusRoot(censusRoot []byte) (*big.Int, error) {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return nil, err
+	}
+	sqlQuery := `
+	SELECT v.weight FROM votepackages v
+	INNER JOIN processes p ON v.processID = p.id
+	WHERE p.censusRoot = ?
+	`
+
+	rows, err := r.db.Query(sqlQuery, censusRoot)
+	if err != nil {
+		return nil, newDBError("SumVoteWeightByCensusRoot", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	sum := big.NewInt(0)
+	for rows.Next() {
+		var weightBytes []byte
+		if err := rows.Scan(&weightBytes); err != nil {
+			return nil, newDBError("SumVoteWeightByCensusRoot", err)
+		}
+		sum.Add(sum, new(big.Int).SetBytes(weightBytes))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, newDBError("SumVoteWeightByCensusRoot", err)
+	}
+	return sum, nil
+}
+
+// QuorumByCensusRoot checks the stored votes of the processes with the given
+// CensusRoot against the given totalWeight, which is the total eligible
+// weight of the Census (its size when all the weights are 1, or the sum of
+// its weights), and the given threshold, which is the minimum percentage
+// (from 0 to 100) of the totalWeight that needs to be voted to reach the
+// quorum. See QuorumResult for the outcome fields.
+func (r *SQLite) QuorumByCensusRoot(censusRoot []byte, totalWeight *big.Int,
+	threshold uint64) (*QuorumResult, error) {
+	if threshold > 100 { //nolint:gomnd
+		return nil, fmt.Errorf("invalid threshold: %d, must be between 0"+
+			" and 100", threshold)
+	}
+	if totalWeight == nil || totalWeight.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid totalWeight: %s", totalWeight)
+	}
+	// the VotedWeight is derived from the Tally, as each vote is counted
+	// in one of its options, so both are computed from the same read even
+	// if votes are stored meanwhile
+	tally, err := r.TallyByCensusRoot(censusRoot)
+	if err != nil {
+		return nil, err
+	}
+	votedWeight := new(big.Int).Add(tally.Yes, tally.No)
+
+	// votedWeight * 100 >= totalWeight * threshold, to avoid the
+	// rounding of the division
+	voted := new(big.Int).Mul(votedWeight, big.NewInt(100)) //nolint:gomnd
+	required := new(big.Int).Mul(totalWeight, new(big.Int).SetUint64(threshold))
+	res := &QuorumResult{
+		TotalWeight: totalWeight,
+		VotedWeight: votedWeight,
+		Tally:       *tally,
+		QuorumMet:   voted.Cmp(required) >= 0,
+		YesWon:      tally.Yes.Cmp(tally.No) > 0,
+	}
+	res.Passed = res.QuorumMet && res.YesWon
+	return res, nil
+}
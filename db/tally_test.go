@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vocdoni/arbo"
+)
+
+// storeTallyVotes stores a vote for each one of the given weights, the first
+// nYes with value 1 ("yes") and the rest with value 0 ("no")
+func storeTallyVotes(c *qt.C, sqlite *SQLite, processID uint64,
+	weights []int64, nYes int) {
+	l := arbo.HashFunctionPoseidon.Len()
+	for i := 0; i < len(weights); i++ {
+		voteBytes := make([]byte, l)
+		if i < nYes {
+			voteBytes = arbo.BigIntToBytes(l, big.NewInt(1))
+		}
+		sk := babyjub.NewRandPrivKey()
+		vote := types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(weights[i]),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: voteBytes,
+		}
+		err := sqlite.StoreVotePackage(processID, vote)
+		c.Assert(err, qt.IsNil)
+	}
+}
+
+func newTallySQLite(c *qt.C, processID uint64, censusRoot []byte) *SQLite {
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLite(db)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreProcess(processID, censusRoot, 100, 10, 20, 20, 60,
+		20, 1)
+	c.Assert(err, qt.IsNil)
+	return sqlite
+}
+
+func TestTallyByCensusRoot(t *testing.T) {
+	c := qt.New(t)
+
+	censusRoot := testCensusRoot("censusRoot")
+	processID := uint64(123)
+	sqlite := newTallySQLite(c, processID, censusRoot)
+
+	tally, err := sqlite.TallyByCensusRoot(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(tally.NVotes, qt.Equals, uint64(0))
+	c.Assert(tally.Yes.Int64(), qt.Equals, int64(0))
+	c.Assert(tally.No.Int64(), qt.Equals, int64(0))
+
+	storeTallyVotes(c, sqlite, processID, []int64{1, 2, 3, 4, 5}, 2)
+
+	tally, err = sqlite.TallyByCensusRoot(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(tally.NVotes, qt.Equals, uint64(5))
+	c.Assert(tally.Yes.Int64(), qt.Equals, int64(3))
+	c.Assert(tally.No.Int64(), qt.Equals, int64(12))
+
+	sum, err := sqlite.SumVoteWeightByCensusRoot(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(sum.Int64(), qt.Equals, int64(15))
+
+	// a CensusRoot without votes
+	sum, err = sqlite.SumVoteWeightByCensusRoot(testCensusRoot("otherCensusRoot"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(sum.Int64(), qt.Equals, int64(0))
+}
+
+func TestQuorumByCensusRoot(t *testing.T) {
+	c := qt.New(t)
+
+	censusRoot := testCensusRoot("censusRoot")
+	processID := uint64(123)
+	sqlite := newTallySQLite(c, processID, censusRoot)
+
+	// 3 "yes" votes of weight 10 and 1 "no" vote of weight 10
+	storeTallyVotes(c, sqlite, processID, []int64{10, 10, 10, 10}, 3)
+
+	// quorum met: 40 of 80 voted, threshold 50%
+	res, err := sqlite.QuorumByCensusRoot(censusRoot, big.NewInt(80), 50)
+	c.Assert(err, qt.IsNil)
+	c.Assert(res.VotedWeight.Int64(), qt.Equals, int64(40))
+	c.Assert(res.Tally.Yes.Int64(), qt.Equals, int64(30))
+	c.Assert(res.Tally.No.Int64(), qt.Equals, int64(10))
+	c.Assert(res.QuorumMet, qt.IsTrue)
+	c.Assert(res.YesWon, qt.IsTrue)
+	c.Assert(res.Passed, qt.IsTrue)
+
+	// quorum not met: 40 of 81 voted, threshold 50%
+	res, err = sqlite.QuorumByCensusRoot(censusRoot, big.NewInt(81), 50)
+	c.Assert(err, qt.IsNil)
+	c.Assert(res.QuorumMet, qt.IsFalse)
+	c.Assert(res.YesWon, qt.IsTrue)
+	c.Assert(res.Passed, qt.IsFalse)
+
+	// invalid parameters
+	_, err = sqlite.QuorumByCensusRoot(censusRoot, big.NewInt(80), 101)
+	c.Assert(err, qt.ErrorMatches, "invalid threshold.*")
+	_, err = sqlite.QuorumByCensusRoot(censusRoot, big.NewInt(0), 50)
+	c.Assert(err, qt.ErrorMatches, "invalid totalWeight.*")
+}
+
+func TestQuorumByCensusRootTie(t *testing.T) {
+	c := qt.New(t)
+
+	censusRoot := testCensusRoot("censusRoot")
+	processID := uint64(123)
+	sqlite := newTallySQLite(c, processID, censusRoot)
+
+	// 2 "yes" votes and 2 "no" votes with the same total weight
+	storeTallyVotes(c, sqlite, processID, []int64{5, 15, 10, 10}, 2)
+
+	res, err := sqlite.QuorumByCensusRoot(censusRoot, big.NewInt(40), 100)
+	c.Assert(err, qt.IsNil)
+	c.Assert(res.Tally.Yes.Cmp(res.Tally.No), qt.Equals, 0)
+	c.Assert(res.QuorumMet, qt.IsTrue)
+	c.Assert(res.YesWon, qt.IsFalse)
+	c.Assert(res.Passed, qt.IsFalse)
+}
@@ -1,82 +1,546 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/log"
 )
 
-// StoreVotePackage stores the given types.VotePackage for the given CensusRoot
+// StoreVotePackage stores the given types.VotePackage for the given CensusRoot.
+// The PublicKey is stored in its compressed form (32 bytes), which is
+// decompressed when reading it. If the VotePackage contains a Nullifier which
+// has already been used, ErrNullifierUsed is returned, if the VotePackage
+// index, PublicKey or MerkleProof have already been used,
+// ErrVoteAlreadyExists is returned, and if the vote is longer than the
+// maximum vote length, ErrVoteTooLarge is returned. The VotePackages with an
+// unsupported SignatureScheme, or with a Signature that does not have the
+// length of its SignatureScheme, are not stored.
 func (r *SQLite) StoreVotePackage(processID uint64, vote types.VotePackage) error {
+	return r.storeVotePackage("StoreVotePackage", "INSERT", processID, vote)
+}
+
+// StoreOrReplaceVotePackage stores the given types.VotePackage for the given
+// ProcessID as StoreVotePackage does, but replacing the stored VotePackages
+// that have the same index, PublicKey, MerkleProof or Nullifier. If the
+// SQLite is in AppendOnly mode, ErrAppendOnly is returned, and nothing is
+// stored.
+func (r *SQLite) StoreOrReplaceVotePackage(processID uint64, vote types.VotePackage) error {
+	if r.appendOnly {
+		return fmt.Errorf("%w, can not replace VotePackages", ErrAppendOnly)
+	}
+	return r.storeVotePackage("StoreOrReplaceVotePackage", "INSERT OR REPLACE",
+		processID, vote)
+}
+
+// DeleteVotePackagesByProcessID deletes all the stored types.VotePackage for
+// the given ProcessID. If the SQLite is in AppendOnly mode, ErrAppendOnly is
+// returned, and nothing is deleted.
+func (r *SQLite) DeleteVotePackagesByProcessID(processID uint64) error {
+	if r.appendOnly {
+		return fmt.Errorf("%w, can not delete VotePackages", ErrAppendOnly)
+	}
+	err := r.withRetry("DeleteVotePackagesByProcessID", func() error {
+		_, err := r.db.Exec("DELETE FROM votepackages WHERE processID = ?",
+			processID)
+		return err
+	})
+	if err != nil {
+		return newDBError("DeleteVotePackagesByProcessID", err)
+	}
+	return nil
+}
+
+// storeVotePackage stores the given types.VotePackage using the given sql
+// insert statement, which is "INSERT" or "INSERT OR REPLACE"
+func (r *SQLite) storeVotePackage(op, insert string, processID uint64,
+	vote types.VotePackage) error {
+	if len(vote.Vote) > r.maxVoteLen {
+		return fmt.Errorf("%w, len(vote): %d, max: %d", ErrVoteTooLarge,
+			len(vote.Vote), r.maxVoteLen)
+	}
+	if err := vote.SignatureScheme.CheckSignatureLen(vote.Signature); err != nil {
+		return err
+	}
 	// TODO check that processID exists
-	sqlQuery := `
-	INSERT INTO votepackages(
+	if limiter := r.rateLimiter(); limiter != nil {
+		if err := r.checkRateLimit(limiter, processID); err != nil {
+			return err
+		}
+	}
+
+	sqlQuery := insert + ` INTO votepackages(
 		indx,
 		publicKey,
 		weight,
 		merkleproof,
 		signature,
+		signatureScheme,
 		vote,
 		insertedDatetime,
-		processID
-	) values(?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
+		processID,
+		nullifier
+	) values(?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?)
 	`
 
-	stmt, err := r.db.Prepare(sqlQuery)
+	stmt, err := r.prepare(sqlQuery)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close() //nolint:errcheck
 
 	if vote.CensusProof.Weight == nil {
-		// no weight defined, use 0
-		vote.CensusProof.Weight = big.NewInt(0)
+		// no weight defined, use 1, which is the weight used to
+		// verify the CensusProof (see types.HashPubKBytes)
+		vote.CensusProof.Weight = big.NewInt(1)
 	}
 
-	_, err = stmt.Exec(vote.CensusProof.Index, vote.CensusProof.PublicKey,
-		vote.CensusProof.Weight.Bytes(), vote.CensusProof.MerkleProof,
-		vote.Signature[:], vote.Vote, processID)
+	// votes without nullifier are stored with a NULL nullifier, which is
+	// not affected by the uniqueness of the column
+	var nullifier []byte
+	if len(vote.Nullifier) != 0 {
+		nullifier = vote.Nullifier
+	}
+
+	err = r.withRetry(op, func() error {
+		_, err := stmt.Exec(vote.CensusProof.Index, vote.CensusProof.PublicKey,
+			vote.CensusProof.Weight.Bytes(), vote.CensusProof.MerkleProof,
+			[]byte(vote.Signature), vote.SignatureScheme, vote.Vote, processID,
+			nullifier)
+		return err
+	})
 	if err != nil {
-		if err.Error() == "FOREIGN KEY constraint failed" {
-			return fmt.Errorf("Can not store VotePackage, ProcessID=%d does not exist", processID)
+		return newDBError(op, err)
+	}
+	// the VotePackage is already stored, an error publishing it is only
+	// logged
+	if err := r.publishVote(processID, vote); err != nil {
+		log.Warnf("[ProcessID=%d] can not publish VotePackage %d: %s",
+			processID, vote.CensusProof.Index, err)
+	}
+	return nil
+}
+
+// checkRateLimit returns ErrRateLimited if the rate limit of the given
+// RateLimiter for the CensusRoot of the given processID has been exceeded
+func (r *SQLite) checkRateLimit(limiter *RateLimiter, processID uint64) error {
+	row := r.db.QueryRow("SELECT censusRoot FROM processes WHERE id = ?", processID)
+	var censusRoot []byte
+	if err := row.Scan(&censusRoot); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w, can not store VotePackage, ProcessID=%d",
+				ErrProcessNotFound, processID)
 		}
-		return err
+		return newDBError("StoreVotePackage", err)
+	}
+	if !limiter.Allow(censusRoot) {
+		return ErrRateLimited
 	}
 	return nil
 }
 
+// VotesOrder is used to define the ordering in which the VotePackages are
+// read from the db
+type VotesOrder int
+
+var (
+	// OrderByIndexAsc sorts the VotePackages by index, from smaller to
+	// bigger
+	OrderByIndexAsc VotesOrder = 0
+	// OrderByIndexDesc sorts the VotePackages by index, from bigger to
+	// smaller
+	OrderByIndexDesc VotesOrder = 1
+	// OrderByPublicKey sorts the VotePackages by the compressed
+	// PublicKey bytes
+	OrderByPublicKey VotesOrder = 2
+	// OrderByDateAsc sorts the VotePackages by insertion datetime, from
+	// older to newer
+	OrderByDateAsc VotesOrder = 3
+	// OrderByDateDesc sorts the VotePackages by insertion datetime, from
+	// newer to older
+	OrderByDateDesc VotesOrder = 4
+)
+
+// votesOrderSQL contains the whitelisted ORDER BY clauses for each VotesOrder,
+// user input is never placed into the sql query
+var votesOrderSQL = map[VotesOrder]string{
+	OrderByIndexAsc:  "indx ASC",
+	OrderByIndexDesc: "indx DESC",
+	OrderByPublicKey: "publicKey ASC",
+	OrderByDateAsc:   "datetime(insertedDatetime) ASC, indx ASC",
+	OrderByDateDesc:  "datetime(insertedDatetime) DESC, indx ASC",
+}
+
 // ReadVotePackagesByProcessID reads all the stored types.VotePackage for the
 // given ProcessID. VotePackages returned are sorted by index parameter, from
 // smaller to bigger.
 func (r *SQLite) ReadVotePackagesByProcessID(processID uint64) ([]types.VotePackage, error) {
+	return r.ReadVotePackagesByProcessIDOrdered(processID, OrderByIndexAsc)
+}
+
+// ReadVotePackagesByProcessIDOrdered reads all the stored types.VotePackage
+// for the given ProcessID, sorted by the given VotesOrder.
+func (r *SQLite) ReadVotePackagesByProcessIDOrdered(processID uint64,
+	order VotesOrder) ([]types.VotePackage, error) {
+	orderSQL, ok := votesOrderSQL[order]
+	if !ok {
+		return nil, fmt.Errorf("invalid VotesOrder: %d", order)
+	}
 	// TODO add pagination
 	sqlQuery := `
-	SELECT signature, indx, publicKey, weight, merkleproof, vote FROM votepackages
+	SELECT signature, signatureScheme, indx, publicKey, weight, merkleproof, vote,
+	insertedDatetime, nullifier FROM votepackages
 	WHERE processID = ?
-	ORDER BY indx ASC
-	`
+	ORDER BY ` + orderSQL //nolint:gosec // orderSQL comes from the whitelist
 
 	rows, err := r.db.Query(sqlQuery, processID)
 	if err != nil {
+		return nil, newDBError("ReadVotePackagesByProcessIDOrdered", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	return scanVotePackages(rows)
+}
+
+// ReadVotePackageByPublicKey reads the stored types.VotePackage of the given
+// PublicKey for the given ProcessID. If there is no VotePackage of the
+// PublicKey, ErrVoteNotFound is returned.
+func (r *SQLite) ReadVotePackageByPublicKey(processID uint64,
+	pubK *babyjub.PublicKey) (*types.VotePackage, error) {
+	sqlQuery := `
+	SELECT signature, signatureScheme, indx, publicKey, weight, merkleproof, vote,
+	insertedDatetime, nullifier FROM votepackages
+	WHERE processID = ? AND publicKey = ?
+	`
+
+	rows, err := r.db.Query(sqlQuery, processID, pubK)
+	if err != nil {
+		return nil, newDBError("ReadVotePackageByPublicKey", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	votes, err := scanVotePackages(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(votes) == 0 {
+		return nil, fmt.Errorf("%w, ProcessID: %d, PublicKey: %s",
+			ErrVoteNotFound, processID, pubK)
+	}
+	return &votes[0], nil
+}
+
+// sqlTimeFormat is the format used by CURRENT_TIMESTAMP to store the datetimes
+const sqlTimeFormat = "2006-01-02 15:04:05"
+
+// ReadVotePackagesByTimeRange reads all the stored types.VotePackage of the
+// processes with the given CensusRoot that were inserted between the given
+// from and to times (both included). A zero from or to time means that the
+// range is not bounded on that side. VotePackages returned are sorted by
+// insertion datetime, from older to newer.
+func (r *SQLite) ReadVotePackagesByTimeRange(censusRoot []byte,
+	from, to time.Time) ([]types.VotePackage, error) {
+	if err := checkCensusRoot(censusRoot); err != nil {
 		return nil, err
 	}
+	fromStr := "0000-01-01 00:00:00"
+	if !from.IsZero() {
+		fromStr = from.UTC().Format(sqlTimeFormat)
+	}
+	toStr := "9999-12-31 23:59:59"
+	if !to.IsZero() {
+		toStr = to.UTC().Format(sqlTimeFormat)
+	}
+
+	sqlQuery := `
+	SELECT v.signature, v.signatureScheme, v.indx, v.publicKey, v.weight, v.merkleproof, v.vote,
+	v.insertedDatetime, v.nullifier FROM votepackages v
+	INNER JOIN processes p ON v.processID = p.id
+	WHERE p.censusRoot = ?
+	AND datetime(v.insertedDatetime) BETWEEN datetime(?) AND datetime(?)
+	ORDER BY datetime(v.insertedDatetime) ASC, v.indx ASC
+	`
+
+	rows, err := r.db.Query(sqlQuery, censusRoot, fromStr, toStr)
+	if err != nil {
+		return nil, newDBError("ReadVotePackagesByTimeRange", err)
+	}
 	defer rows.Close() //nolint:errcheck
 
+	return scanVotePackages(rows)
+}
+
+// LastVoteTime returns the insertion datetime of the most recent stored
+// VotePackage of the processes with the given CensusRoot. The returned bool is
+// false if there are no VotePackages for the CensusRoot, in which case the
+// returned time is zero.
+func (r *SQLite) LastVoteTime(censusRoot []byte) (time.Time, bool, error) {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return time.Time{}, false, err
+	}
+	sqlQuery := `
+	SELECT MAX(datetime(v.insertedDatetime)) FROM votepackages v
+	INNER JOIN processes p ON v.processID = p.id
+	WHERE p.censusRoot = ?
+	`
+
+	// the aggregate has no declared type, so it is read as a string
+	var last sql.NullString
+	if err := r.db.QueryRow(sqlQuery, censusRoot).Scan(&last); err != nil {
+		return time.Time{}, false, newDBError("LastVoteTime", err)
+	}
+	if !last.Valid {
+		return time.Time{}, false, nil
+	}
+	t, err := time.ParseInLocation(sqlTimeFormat, last.String, time.UTC)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
+}
+
+// DistinctCensusRoots returns the CensusRoots of the processes that have
+// stored VotePackages, sorted by their bytes. It only uses the SQLite data, so
+// it can be used to discover the existing polls without the CensusBuilder.
+func (r *SQLite) DistinctCensusRoots() ([][]byte, error) {
+	sqlQuery := `
+	SELECT DISTINCT p.censusRoot FROM votepackages v
+	INNER JOIN processes p ON v.processID = p.id
+	ORDER BY p.censusRoot
+	`
+
+	rows, err := r.db.Query(sqlQuery)
+	if err != nil {
+		return nil, newDBError("DistinctCensusRoots", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var censusRoots [][]byte
+	for rows.Next() {
+		var censusRoot []byte
+		if err := rows.Scan(&censusRoot); err != nil {
+			return nil, newDBError("DistinctCensusRoots", err)
+		}
+		censusRoots = append(censusRoots, censusRoot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, newDBError("DistinctCensusRoots", err)
+	}
+	return censusRoots, nil
+}
+
+// scanVotePackages reads the types.VotePackage from the given rows, which
+// must contain the columns signature, signatureScheme, indx, publicKey,
+// weight, merkleproof, vote, insertedDatetime and nullifier, in that order
+func scanVotePackages(rows *sql.Rows) ([]types.VotePackage, error) {
 	var votes []types.VotePackage
+	for rows.Next() {
+		vote, err := scanVotePackage(rows)
+		if err != nil {
+			return nil, newDBError("scanVotePackages", err)
+		}
+		votes = append(votes, *vote)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, newDBError("scanVotePackages", err)
+	}
+	return votes, nil
+}
+
+// scanVotePackage reads the types.VotePackage from the current row, with the
+// columns described at scanVotePackages
+func scanVotePackage(rows *sql.Rows) (*types.VotePackage, error) {
+	vote := types.VotePackage{}
+	var sigBytes []byte
+	var weightBytes []byte
+	var nullifier []byte
+	err := rows.Scan(&sigBytes, &vote.SignatureScheme, &vote.CensusProof.Index,
+		&vote.CensusProof.PublicKey, &weightBytes,
+		&vote.CensusProof.MerkleProof, &vote.Vote,
+		&vote.InsertedDatetime, &nullifier)
+	if err != nil {
+		return nil, err
+	}
+	vote.Nullifier = nullifier
+	vote.CensusProof.Weight = new(big.Int).SetBytes(weightBytes)
+	vote.Signature = sigBytes
+	return &vote, nil
+}
+
+// StreamVotePackagesByCensusRoot reads the stored types.VotePackage of the
+// processes with the given CensusRoot, sending them through the returned
+// channel as they are read, sorted by index, so they are never all loaded in
+// memory. Both channels are closed once all the VotePackages have been sent,
+// or when an error happens, in which case the error is sent through the
+// error channel. Cancelling the given context stops the query, and
+// ctx.Err() is sent through the error channel.
+func (r *SQLite) StreamVotePackagesByCensusRoot(ctx context.Context,
+	censusRoot []byte) (<-chan types.VotePackage, <-chan error) {
+	votes := make(chan types.VotePackage)
+	errs := make(chan error, 1)
+	if err := checkCensusRoot(censusRoot); err != nil {
+		errs <- err
+		close(errs)
+		close(votes)
+		return votes, errs
+	}
+
+	go func() {
+		defer close(errs)
+		defer close(votes)
+
+		sqlQuery := `
+		SELECT v.signature, v.signatureScheme, v.indx, v.publicKey, v.weight, v.merkleproof,
+		v.vote, v.insertedDatetime, v.nullifier FROM votepackages v
+		INNER JOIN processes p ON v.processID = p.id
+		WHERE p.censusRoot = ?
+		ORDER BY v.indx ASC
+		`
+		rows, err := r.db.QueryContext(ctx, sqlQuery, censusRoot)
+		if err != nil {
+			errs <- newDBError("StreamVotePackagesByCensusRoot", err)
+			return
+		}
+		defer rows.Close() //nolint:errcheck
+
+		for rows.Next() {
+			vote, err := scanVotePackage(rows)
+			if err != nil {
+				errs <- newDBError("StreamVotePackagesByCensusRoot", err)
+				return
+			}
+			// check the context before sending, as select chooses
+			// randomly when both cases are ready
+			if err := ctx.Err(); err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case votes <- *vote:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+		// when the context is cancelled while reading, the driver may
+		// stop the rows with its own interrupt error
+		if err := ctx.Err(); err != nil {
+			errs <- err
+			return
+		}
+		if err := rows.Err(); err != nil {
+			errs <- newDBError("StreamVotePackagesByCensusRoot", err)
+		}
+	}()
+	return votes, errs
+}
+
+// VerifyStoredVotes checks the CensusProof of each stored VotePackage of the
+// processes with the given CensusRoot against that CensusRoot. Returns the
+// number of valid VotePackages, and the indexes of the VotePackages that
+// failed the verification. The VotePackages are read one by one, without
+// loading all of them in memory, and nothing is modified in the db.
+func (r *SQLite) VerifyStoredVotes(censusRoot []byte) (int, []uint64, error) {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return 0, nil, err
+	}
+	sqlQuery := `
+	SELECT v.indx, v.publicKey, v.weight, v.merkleproof FROM votepackages v
+	INNER JOIN processes p ON v.processID = p.id
+	WHERE p.censusRoot = ?
+	ORDER BY v.indx ASC
+	`
+
+	rows, err := r.db.Query(sqlQuery, censusRoot)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	valid := 0
+	var invalid []uint64
+	for rows.Next() {
+		var cp types.CensusProof
+		var weightBytes []byte
+		err = rows.Scan(&cp.Index, &cp.PublicKey, &weightBytes, &cp.MerkleProof)
+		if err != nil {
+			return 0, nil, err
+		}
+		cp.Weight = new(big.Int).SetBytes(weightBytes)
+		if err := cp.Verify(censusRoot); err != nil {
+			invalid = append(invalid, cp.Index)
+			continue
+		}
+		valid++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	return valid, invalid, nil
+}
+
+// ReadVotePackagesVerified reads all the stored types.VotePackage of the
+// processes with the given CensusRoot, and verifies the signature and the
+// CensusProof of each one against the given root, using the chainID stored in
+// the meta table. Returns the VotePackages that passed the verification, and
+// the indexes of the ones that failed it, both sorted by index. For reads
+// that do not need the verification, use the ReadVotePackagesBy* methods,
+// which are faster.
+func (r *SQLite) ReadVotePackagesVerified(censusRoot, root []byte) (
+	[]types.VotePackage, []uint64, error) {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return nil, nil, err
+	}
+	chainID, err := r.GetChainID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sqlQuery := `
+	SELECT v.signature, v.signatureScheme, v.indx, v.publicKey, v.weight, v.merkleproof, v.vote,
+	v.insertedDatetime, v.nullifier, v.processID FROM votepackages v
+	INNER JOIN processes p ON v.processID = p.id
+	WHERE p.censusRoot = ?
+	ORDER BY v.indx ASC
+	`
+
+	rows, err := r.db.Query(sqlQuery, censusRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var valid []types.VotePackage
+	var invalid []uint64
 	for rows.Next() {
 		vote := types.VotePackage{}
 		var sigBytes []byte
 		var weightBytes []byte
-		err = rows.Scan(&sigBytes, &vote.CensusProof.Index,
+		var nullifier []byte
+		var processID uint64
+		err := rows.Scan(&sigBytes, &vote.SignatureScheme, &vote.CensusProof.Index,
 			&vote.CensusProof.PublicKey, &weightBytes,
-			&vote.CensusProof.MerkleProof, &vote.Vote)
+			&vote.CensusProof.MerkleProof, &vote.Vote,
+			&vote.InsertedDatetime, &nullifier, &processID)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		vote.Nullifier = nullifier
 		vote.CensusProof.Weight = new(big.Int).SetBytes(weightBytes)
-		copy(vote.Signature[:], sigBytes)
-		votes = append(votes, vote)
+		vote.Signature = sigBytes
+		if err := vote.Verify(chainID, processID, root); err != nil {
+			invalid = append(invalid, vote.CensusProof.Index)
+			continue
+		}
+		valid = append(valid, vote)
 	}
-	return votes, nil
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return valid, invalid, nil
 }
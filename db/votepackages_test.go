@@ -1,11 +1,16 @@
 package db
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"math/big"
 	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/aragon/ovote-node/test"
 	"github.com/aragon/ovote-node/types"
@@ -32,7 +37,7 @@ func TestStoreAndReadVotes(t *testing.T) {
 	keys := test.GenUserKeys(1)
 	sig := keys.PrivateKeys[0].SignPoseidon(voteBI)
 	votePackage := types.VotePackage{
-		Signature: sig.Compress(),
+		Signature: types.CompressSignature(sig),
 		CensusProof: types.CensusProof{
 			Index:       1,
 			PublicKey:   &keys.PublicKeys[0],
@@ -44,11 +49,11 @@ func TestStoreAndReadVotes(t *testing.T) {
 	// expect error when storing the vote, as processID does not exist yet
 	err = sqlite.StoreVotePackage(uint64(123), votePackage)
 	c.Assert(err, qt.Not(qt.IsNil))
-	c.Assert(err.Error(), qt.Equals, "Can not store VotePackage, ProcessID=123 does not exist")
+	c.Assert(errors.Is(err, ErrProcessNotFound), qt.IsTrue)
 
 	// store a processID in which the votes will be related
 	processID := uint64(123)
-	censusRoot := []byte("censusRoot")
+	censusRoot := testCensusRoot("censusRoot")
 	censusSize := uint64(100)
 	ethBlockNum := uint64(10)
 	resPubStartBlock := uint64(20)
@@ -72,7 +77,7 @@ func TestStoreAndReadVotes(t *testing.T) {
 		sk := babyjub.NewRandPrivKey()
 		pubK := sk.Public()
 		sigUncomp := sk.SignPoseidon(voteBI)
-		sig := sigUncomp.Compress()
+		sig := types.CompressSignature(sigUncomp)
 		vote := types.VotePackage{
 			Signature: sig,
 			CensusProof: types.CensusProof{
@@ -92,10 +97,909 @@ func TestStoreAndReadVotes(t *testing.T) {
 	// try to store a vote with already stored index
 	err = sqlite.StoreVotePackage(processID, votesAdded[0])
 	c.Assert(err, qt.Not(qt.IsNil))
-	c.Assert(err.Error(), qt.Equals, "UNIQUE constraint failed: votepackages.indx")
+	c.Assert(errors.Is(err, ErrVoteAlreadyExists), qt.IsTrue)
+	c.Assert(errors.Unwrap(err).Error(), qt.Equals,
+		"UNIQUE constraint failed: votepackages.indx")
 
 	// read the stored votes
 	votes, err := sqlite.ReadVotePackagesByProcessID(processID)
 	c.Assert(err, qt.IsNil)
 	c.Assert(len(votes), qt.Equals, nVotes)
+
+	// a vote without weight is stored with the weight 1, which is the one
+	// used to verify its CensusProof
+	votePackage.CensusProof.Index = uint64(nVotes)
+	votePackage.CensusProof.Weight = nil
+	err = sqlite.StoreVotePackage(processID, votePackage)
+	c.Assert(err, qt.IsNil)
+	votes, err = sqlite.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(votes[nVotes].CensusProof.Weight.Int64(), qt.Equals, int64(1))
+}
+
+func TestReadVotesOrdered(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, testCensusRoot("censusRoot"), 100, 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	// store the votes with unsorted indexes
+	indexes := []uint64{3, 0, 4, 1, 2}
+	for i := 0; i < len(indexes); i++ {
+		sk := babyjub.NewRandPrivKey()
+		vote := types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       indexes[i],
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: []byte("test"),
+		}
+		err = sqlite.StoreVotePackage(processID, vote)
+		c.Assert(err, qt.IsNil)
+
+		// set different insertion datetimes, following the order in
+		// which the votes are stored
+		_, err = db.Exec("UPDATE votepackages SET insertedDatetime = ? WHERE indx = ?",
+			"2022-01-01 00:00:0"+strconv.Itoa(i), indexes[i])
+		c.Assert(err, qt.IsNil)
+	}
+
+	readIndexes := func(order VotesOrder) []uint64 {
+		votes, err := sqlite.ReadVotePackagesByProcessIDOrdered(processID, order)
+		c.Assert(err, qt.IsNil)
+		var r []uint64
+		for i := 0; i < len(votes); i++ {
+			r = append(r, votes[i].CensusProof.Index)
+		}
+		return r
+	}
+
+	c.Assert(readIndexes(OrderByIndexAsc), qt.DeepEquals, []uint64{0, 1, 2, 3, 4})
+	c.Assert(readIndexes(OrderByIndexDesc), qt.DeepEquals, []uint64{4, 3, 2, 1, 0})
+	c.Assert(readIndexes(OrderByDateAsc), qt.DeepEquals, []uint64{3, 0, 4, 1, 2})
+	c.Assert(readIndexes(OrderByDateDesc), qt.DeepEquals, []uint64{2, 1, 4, 0, 3})
+
+	// expect the votes sorted by the compressed PublicKey bytes
+	votes, err := sqlite.ReadVotePackagesByProcessIDOrdered(processID, OrderByPublicKey)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(votes), qt.Equals, len(indexes))
+	for i := 1; i < len(votes); i++ {
+		prev := votes[i-1].CensusProof.PublicKey.Compress()
+		curr := votes[i].CensusProof.PublicKey.Compress()
+		c.Assert(bytes.Compare(prev[:], curr[:]), qt.Equals, -1)
+	}
+
+	// the default method uses OrderByIndexAsc
+	votes, err = sqlite.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < len(votes); i++ {
+		c.Assert(votes[i].CensusProof.Index, qt.Equals, uint64(i))
+	}
+
+	// expect error for an unknown order
+	_, err = sqlite.ReadVotePackagesByProcessIDOrdered(processID, VotesOrder(42))
+	c.Assert(err, qt.Not(qt.IsNil))
+}
+
+func TestStoreVotesRateLimit(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	// limit to 1 vote per second, with bursts of 5 votes, using a fixed
+	// clock
+	now := time.Now()
+	limiter := NewRateLimiter(1, 5)
+	limiter.now = func() time.Time { return now }
+	sqlite.SetRateLimiter(limiter)
+
+	// store two processes with different censusRoots
+	err = sqlite.StoreProcess(1, testCensusRoot("censusRoot1"), 100, 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreProcess(2, testCensusRoot("censusRoot2"), 100, 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	i := 0
+	newVote := func() types.VotePackage {
+		i++
+		sk := babyjub.NewRandPrivKey()
+		return types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: []byte("test"),
+		}
+	}
+
+	// expect the burst to be accepted, and the next vote to be rejected
+	for j := 0; j < 5; j++ {
+		err = sqlite.StoreVotePackage(1, newVote())
+		c.Assert(err, qt.IsNil)
+	}
+	err = sqlite.StoreVotePackage(1, newVote())
+	c.Assert(err, qt.Equals, ErrRateLimited)
+
+	// expect the votes of a different censusRoot to not be limited
+	for j := 0; j < 5; j++ {
+		err = sqlite.StoreVotePackage(2, newVote())
+		c.Assert(err, qt.IsNil)
+	}
+
+	// after 2 seconds, expect 2 new votes to be accepted
+	now = now.Add(2 * time.Second)
+	err = sqlite.StoreVotePackage(1, newVote())
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackage(1, newVote())
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackage(1, newVote())
+	c.Assert(err, qt.Equals, ErrRateLimited)
+
+	// without limiter, expect no limit
+	sqlite.SetRateLimiter(nil)
+	for j := 0; j < 10; j++ {
+		err = sqlite.StoreVotePackage(1, newVote())
+		c.Assert(err, qt.IsNil)
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	c := qt.New(t)
+
+	// 1 event per second with bursts of 4 events, so an empty bucket is
+	// refilled in 4 seconds
+	now := time.Now()
+	limiter := NewRateLimiter(1, 4)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		c.Assert(limiter.Allow([]byte("a")), qt.IsTrue)
+	}
+	c.Assert(limiter.Allow([]byte("a")), qt.IsFalse)
+	c.Assert(limiter.Allow([]byte("b")), qt.IsTrue)
+	c.Assert(len(limiter.buckets), qt.Equals, 2)
+
+	// after 3 seconds the bucket of b is full again, but the buckets are
+	// only pruned once per refill period
+	now = now.Add(3 * time.Second)
+	c.Assert(limiter.Allow([]byte("a")), qt.IsTrue)
+	c.Assert(limiter.Allow([]byte("c")), qt.IsTrue)
+	c.Assert(len(limiter.buckets), qt.Equals, 3)
+
+	// after the refill period, the full buckets are dropped, and the
+	// bucket of a, which is not full yet, is kept
+	now = now.Add(time.Second + time.Second/2)
+	c.Assert(limiter.Allow([]byte("d")), qt.IsTrue)
+	c.Assert(len(limiter.buckets), qt.Equals, 2)
+	c.Assert(limiter.buckets["a"], qt.Not(qt.IsNil))
+	c.Assert(limiter.buckets["d"], qt.Not(qt.IsNil))
+	// the dropped buckets start full again
+	for i := 0; i < 4; i++ {
+		c.Assert(limiter.Allow([]byte("b")), qt.IsTrue)
+	}
+	c.Assert(limiter.Allow([]byte("b")), qt.IsFalse)
+}
+
+func TestVerifyStoredVotes(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	chainID := uint64(3)
+	processID := uint64(123)
+	nVotes := 10
+	keys := test.GenUserKeys(nVotes)
+	testCensus := test.GenCensus(c, keys)
+	err = testCensus.Census.Close()
+	c.Assert(err, qt.IsNil)
+	censusRoot, err := testCensus.Census.Root()
+	c.Assert(err, qt.IsNil)
+	votes := test.GenVotes(c, testCensus, chainID, processID, 60)
+
+	err = sqlite.StoreProcess(processID, censusRoot, uint64(nVotes), 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	// store the votes, two of them with a MerkleProof of another vote
+	votes[2].CensusProof.MerkleProof = votes[3].CensusProof.MerkleProof
+	votes[3].CensusProof.MerkleProof = votes[4].CensusProof.MerkleProof
+	votes[4].CensusProof.MerkleProof = append(votes[4].CensusProof.MerkleProof, 0)
+	for i := 0; i < len(votes); i++ {
+		err = sqlite.StoreVotePackage(processID, votes[i])
+		c.Assert(err, qt.IsNil)
+	}
+
+	valid, invalid, err := sqlite.VerifyStoredVotes(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(valid, qt.Equals, nVotes-3)
+	c.Assert(invalid, qt.DeepEquals, []uint64{2, 3, 4})
+
+	// for an unknown CensusRoot, expect no votes
+	valid, invalid, err = sqlite.VerifyStoredVotes(testCensusRoot("unknown"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(valid, qt.Equals, 0)
+	c.Assert(len(invalid), qt.Equals, 0)
+}
+
+func TestReadVotePackagesVerified(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	chainID := uint64(3)
+	processID := uint64(123)
+	nVotes := 10
+	keys := test.GenUserKeys(nVotes)
+	testCensus := test.GenCensus(c, keys)
+	err = testCensus.Census.Close()
+	c.Assert(err, qt.IsNil)
+	censusRoot, err := testCensus.Census.Root()
+	c.Assert(err, qt.IsNil)
+	votes := test.GenVotes(c, testCensus, chainID, processID, 60)
+
+	// without the meta row, the chainID is unknown
+	_, _, err = sqlite.ReadVotePackagesVerified(censusRoot, censusRoot)
+	c.Assert(err, qt.Equals, ErrMetaNotInDB)
+
+	err = sqlite.InitMeta(chainID, 0)
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreProcess(processID, censusRoot, uint64(nVotes), 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	// store the votes, one with a tampered MerkleProof, and one with a
+	// tampered vote value, which invalidates the signature
+	votes[2].CensusProof.MerkleProof = append(votes[2].CensusProof.MerkleProof, 0)
+	votes[5].Vote = votes[9].Vote
+	for i := 0; i < len(votes); i++ {
+		err = sqlite.StoreVotePackage(processID, votes[i])
+		c.Assert(err, qt.IsNil)
+	}
+
+	valid, invalid, err := sqlite.ReadVotePackagesVerified(censusRoot, censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(valid), qt.Equals, nVotes-2)
+	c.Assert(invalid, qt.DeepEquals, []uint64{2, 5})
+	for i := 0; i < len(valid); i++ {
+		c.Assert(valid[i].CensusProof.Index, qt.Not(qt.Equals), uint64(2))
+		c.Assert(valid[i].CensusProof.Index, qt.Not(qt.Equals), uint64(5))
+	}
+
+	// verifying against another root, all the votes are invalid
+	valid, invalid, err = sqlite.ReadVotePackagesVerified(censusRoot,
+		make([]byte, len(censusRoot)))
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(valid), qt.Equals, 0)
+	c.Assert(len(invalid), qt.Equals, nVotes)
+
+	// for an unknown CensusRoot, expect no votes
+	valid, invalid, err = sqlite.ReadVotePackagesVerified(testCensusRoot("unknown"),
+		censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(valid), qt.Equals, 0)
+	c.Assert(len(invalid), qt.Equals, 0)
+}
+
+func TestReadVotePackagesByTimeRange(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, censusRoot, 100, 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+	// another process with a different CensusRoot, which votes are not
+	// expected in the results
+	err = sqlite.StoreProcess(processID+1, testCensusRoot("otherCensusRoot"), 100,
+		10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	baseTime := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	nVotes := 5
+	for i := 0; i < nVotes*2; i++ {
+		pID := processID
+		if i >= nVotes {
+			pID = processID + 1
+		}
+		sk := babyjub.NewRandPrivKey()
+		vote := types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: []byte("test"),
+		}
+		err = sqlite.StoreVotePackage(pID, vote)
+		c.Assert(err, qt.IsNil)
+
+		// each vote is inserted one hour after the previous one
+		insertedTime := baseTime.Add(time.Duration(i%nVotes) * time.Hour)
+		_, err = db.Exec("UPDATE votepackages SET insertedDatetime = ? WHERE indx = ?",
+			insertedTime.Format("2006-01-02 15:04:05"), i)
+		c.Assert(err, qt.IsNil)
+	}
+
+	readIndexes := func(from, to time.Time) []uint64 {
+		votes, err := sqlite.ReadVotePackagesByTimeRange(censusRoot, from, to)
+		c.Assert(err, qt.IsNil)
+		var r []uint64
+		for i := 0; i < len(votes); i++ {
+			r = append(r, votes[i].CensusProof.Index)
+		}
+		return r
+	}
+
+	// both bounds are included
+	c.Assert(readIndexes(baseTime.Add(1*time.Hour), baseTime.Add(3*time.Hour)),
+		qt.DeepEquals, []uint64{1, 2, 3})
+	c.Assert(readIndexes(baseTime.Add(90*time.Minute), baseTime.Add(150*time.Minute)),
+		qt.DeepEquals, []uint64{2})
+	// open-ended ranges
+	c.Assert(readIndexes(time.Time{}, baseTime.Add(1*time.Hour)),
+		qt.DeepEquals, []uint64{0, 1})
+	c.Assert(readIndexes(baseTime.Add(3*time.Hour), time.Time{}),
+		qt.DeepEquals, []uint64{3, 4})
+	c.Assert(readIndexes(time.Time{}, time.Time{}),
+		qt.DeepEquals, []uint64{0, 1, 2, 3, 4})
+	// times in other timezones are converted to UTC
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	c.Assert(readIndexes(baseTime.Add(4*time.Hour).In(loc), time.Time{}),
+		qt.DeepEquals, []uint64{4})
+	// empty range
+	c.Assert(len(readIndexes(baseTime.Add(5*time.Hour), time.Time{})), qt.Equals, 0)
+
+	// expect the inserted datetime in the returned VotePackages
+	votes, err := sqlite.ReadVotePackagesByTimeRange(censusRoot, time.Time{}, time.Time{})
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < len(votes); i++ {
+		c.Assert(votes[i].InsertedDatetime.Equal(baseTime.Add(time.Duration(i)*time.Hour)),
+			qt.IsTrue)
+	}
+}
+
+func BenchmarkStoreVotePackage(b *testing.B) {
+	c := qt.New(b)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLiteWithOptions(db, Options{MaxOpenConns: 1, MaxIdleConns: 1})
+	defer sqlite.Close() //nolint:errcheck
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, testCensusRoot("censusRoot"), 100, 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	sk := babyjub.NewRandPrivKey()
+	sig := types.CompressSignature(sk.SignPoseidon(big.NewInt(1)))
+	votes := make([]types.VotePackage, b.N)
+	for i := 0; i < b.N; i++ {
+		voterSK := babyjub.NewRandPrivKey()
+		votes[i] = types.VotePackage{
+			Signature: sig,
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   voterSK.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: []byte("test"),
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := sqlite.StoreVotePackage(processID, votes[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStoreVotesNullifier(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, testCensusRoot("censusRoot"), 100, 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	newVote := func(index int, nullifier []byte) types.VotePackage {
+		sk := babyjub.NewRandPrivKey()
+		return types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(index),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(index)),
+			},
+			Vote:      []byte("test"),
+			Nullifier: nullifier,
+		}
+	}
+
+	err = sqlite.StoreVotePackage(processID, newVote(0, []byte("nullifier0")))
+	c.Assert(err, qt.IsNil)
+	// expect error when storing a vote with an already used nullifier
+	err = sqlite.StoreVotePackage(processID, newVote(1, []byte("nullifier0")))
+	c.Assert(errors.Is(err, ErrNullifierUsed), qt.IsTrue)
+	err = sqlite.StoreVotePackage(processID, newVote(1, []byte("nullifier1")))
+	c.Assert(err, qt.IsNil)
+	// votes without nullifier are not affected
+	err = sqlite.StoreVotePackage(processID, newVote(2, nil))
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackage(processID, newVote(3, nil))
+	c.Assert(err, qt.IsNil)
+
+	votes, err := sqlite.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(votes), qt.Equals, 4)
+	c.Assert([]byte(votes[0].Nullifier), qt.DeepEquals, []byte("nullifier0"))
+	c.Assert([]byte(votes[1].Nullifier), qt.DeepEquals, []byte("nullifier1"))
+	c.Assert(len(votes[2].Nullifier), qt.Equals, 0)
+	c.Assert(len(votes[3].Nullifier), qt.Equals, 0)
+}
+
+func TestMigrateNullifier(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	// create the votepackages table as it was before the nullifier column
+	_, err = db.Exec(`
+	CREATE TABLE votepackages(
+		indx INTEGER NOT NULL PRIMARY KEY UNIQUE,
+		publicKey BLOB NOT NULL UNIQUE,
+		weight BLOB NOT NULL,
+		merkleproof BLOB NOT NULL UNIQUE,
+		signature BLOB NOT NULL,
+		vote BLOB NOT NULL,
+		insertedDatetime DATETIME,
+		processID INTEGER NOT NULL,
+		FOREIGN KEY(processID) REFERENCES processes(id)
+	);
+	INSERT INTO votepackages VALUES(0, x'00', x'01', x'02', x'03', x'04',
+		CURRENT_TIMESTAMP, 123);
+	`)
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+	// migrating again does not modify the table
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	var n int
+	row := db.QueryRow("SELECT COUNT(*) FROM votepackages WHERE nullifier IS NULL")
+	c.Assert(row.Scan(&n), qt.IsNil)
+	c.Assert(n, qt.Equals, 1)
+
+	// expect the uniqueness of the nullifier in the migrated table
+	_, err = db.Exec(`UPDATE votepackages SET nullifier = x'aa' WHERE indx = 0;
+	INSERT INTO votepackages VALUES(1, x'10', x'11', x'12', x'13', x'14',
+		CURRENT_TIMESTAMP, 123, x'aa', 0);`)
+	c.Assert(err, qt.ErrorMatches, "UNIQUE constraint failed: votepackages.nullifier")
+}
+
+func TestStoreVotesMaxVoteLen(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	newVote := func(index int, voteLen int) types.VotePackage {
+		sk := babyjub.NewRandPrivKey()
+		return types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(index),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(index)),
+			},
+			Vote: make([]byte, voteLen),
+		}
+	}
+
+	for _, maxVoteLen := range []int{0, 2048} {
+		sqlite := NewSQLiteWithOptions(db, Options{MaxVoteLen: maxVoteLen})
+		err = sqlite.Migrate()
+		c.Assert(err, qt.IsNil)
+
+		processID := uint64(maxVoteLen)
+		err = sqlite.StoreProcess(processID, testCensusRoot("censusRoot"), 100, 10,
+			20, 20, 60, 20, 1)
+		c.Assert(err, qt.IsNil)
+
+		limit := maxVoteLen
+		if limit == 0 {
+			limit = DefaultMaxVoteLen
+		}
+		// a vote at the limit is accepted
+		err = sqlite.StoreVotePackage(processID, newVote(limit, limit))
+		c.Assert(err, qt.IsNil)
+		// a vote one byte over the limit is rejected
+		err = sqlite.StoreVotePackage(processID, newVote(limit+1, limit+1))
+		c.Assert(err, qt.ErrorMatches, fmt.Sprintf("Vote too large,"+
+			" len\\(vote\\): %d, max: %d", limit+1, limit))
+
+		votes, err := sqlite.ReadVotePackagesByProcessID(processID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(len(votes), qt.Equals, 1)
+		c.Assert(len(votes[0].Vote), qt.Equals, limit)
+	}
+}
+
+func TestStreamVotePackagesByCensusRoot(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, censusRoot, 100, 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	nVotes := 20
+	for i := 0; i < nVotes; i++ {
+		sk := babyjub.NewRandPrivKey()
+		vote := types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: []byte("test"),
+		}
+		err = sqlite.StoreVotePackage(processID, vote)
+		c.Assert(err, qt.IsNil)
+	}
+
+	// read all the votes
+	votesCh, errCh := sqlite.StreamVotePackagesByCensusRoot(
+		context.Background(), censusRoot)
+	var indexes []uint64
+	for vote := range votesCh {
+		indexes = append(indexes, vote.CensusProof.Index)
+	}
+	c.Assert(<-errCh, qt.IsNil)
+	c.Assert(len(indexes), qt.Equals, nVotes)
+	for i := 0; i < nVotes; i++ {
+		c.Assert(indexes[i], qt.Equals, uint64(i))
+	}
+
+	// for an unknown CensusRoot, expect no votes
+	votesCh, errCh = sqlite.StreamVotePackagesByCensusRoot(
+		context.Background(), testCensusRoot("unknown"))
+	_, ok := <-votesCh
+	c.Assert(ok, qt.IsFalse)
+	c.Assert(<-errCh, qt.IsNil)
+
+	// cancel the context after reading some votes
+	ctx, cancel := context.WithCancel(context.Background())
+	votesCh, errCh = sqlite.StreamVotePackagesByCensusRoot(ctx, censusRoot)
+	for i := 0; i < 5; i++ {
+		vote := <-votesCh
+		c.Assert(vote.CensusProof.Index, qt.Equals, uint64(i))
+	}
+	cancel()
+	// at most one more vote can be received, which was already being sent
+	n := 0
+	for range votesCh {
+		n++
+	}
+	c.Assert(n <= 1, qt.IsTrue)
+	c.Assert(errors.Is(<-errCh, context.Canceled), qt.IsTrue)
+}
+
+func TestAppendOnly(t *testing.T) {
+	c := qt.New(t)
+
+	newVote := func(index int, vote string) types.VotePackage {
+		sk := babyjub.NewRandPrivKey()
+		return types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(index),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(index)),
+			},
+			Vote: []byte(vote),
+		}
+	}
+
+	for _, appendOnly := range []bool{false, true} {
+		db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+		c.Assert(err, qt.IsNil)
+		sqlite := NewSQLiteWithOptions(db, Options{AppendOnly: appendOnly})
+		err = sqlite.Migrate()
+		c.Assert(err, qt.IsNil)
+
+		processID := uint64(123)
+		err = sqlite.StoreProcess(processID, testCensusRoot("censusRoot"), 100, 10,
+			20, 20, 60, 20, 1)
+		c.Assert(err, qt.IsNil)
+
+		// plain inserts are always allowed
+		err = sqlite.StoreVotePackage(processID, newVote(0, "a"))
+		c.Assert(err, qt.IsNil)
+		err = sqlite.StoreVotePackage(processID, newVote(1, "b"))
+		c.Assert(err, qt.IsNil)
+
+		// overwrite the vote of index 0
+		err = sqlite.StoreOrReplaceVotePackage(processID, newVote(0, "c"))
+		if appendOnly {
+			c.Assert(errors.Is(err, ErrAppendOnly), qt.IsTrue)
+		} else {
+			c.Assert(err, qt.IsNil)
+		}
+		votes, err := sqlite.ReadVotePackagesByProcessID(processID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(len(votes), qt.Equals, 2)
+		if appendOnly {
+			c.Assert(string(votes[0].Vote), qt.Equals, "a")
+		} else {
+			c.Assert(string(votes[0].Vote), qt.Equals, "c")
+		}
+
+		err = sqlite.DeleteVotePackagesByProcessID(processID)
+		votes, err2 := sqlite.ReadVotePackagesByProcessID(processID)
+		c.Assert(err2, qt.IsNil)
+		if appendOnly {
+			c.Assert(errors.Is(err, ErrAppendOnly), qt.IsTrue)
+			c.Assert(len(votes), qt.Equals, 2)
+		} else {
+			c.Assert(err, qt.IsNil)
+			c.Assert(len(votes), qt.Equals, 0)
+		}
+	}
+}
+
+func TestLastVoteTime(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, censusRoot, 100, 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	// no votes yet
+	last, ok, err := sqlite.LastVoteTime(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+	c.Assert(last.IsZero(), qt.IsTrue)
+
+	baseTime := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	// the votes are not inserted in chronological order
+	offsets := []int{2, 5, 1, 3}
+	for i := 0; i < len(offsets); i++ {
+		sk := babyjub.NewRandPrivKey()
+		vote := types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: []byte("test"),
+		}
+		err = sqlite.StoreVotePackage(processID, vote)
+		c.Assert(err, qt.IsNil)
+
+		insertedTime := baseTime.Add(time.Duration(offsets[i]) * time.Hour)
+		_, err = db.Exec("UPDATE votepackages SET insertedDatetime = ? WHERE indx = ?",
+			insertedTime.Format("2006-01-02 15:04:05"), i)
+		c.Assert(err, qt.IsNil)
+	}
+
+	last, ok, err = sqlite.LastVoteTime(censusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(last.Equal(baseTime.Add(5*time.Hour)), qt.IsTrue)
+
+	// a CensusRoot without votes
+	last, ok, err = sqlite.LastVoteTime(testCensusRoot("otherCensusRoot"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(ok, qt.IsFalse)
+	c.Assert(last.IsZero(), qt.IsTrue)
+}
+
+func TestStoreSignatureSchemes(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, censusRoot, 100, 10, 20,
+		20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	sk := babyjub.NewRandPrivKey()
+	newVote := func(sig types.ByteArray,
+		scheme types.SignatureScheme) types.VotePackage {
+		return types.VotePackage{
+			Signature:       sig,
+			SignatureScheme: scheme,
+			CensusProof: types.CensusProof{
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test"),
+			},
+			Vote: []byte("test"),
+		}
+	}
+
+	// a Signature of an unregistered scheme is rejected
+	vote := newVote(bytes.Repeat([]byte{7}, 96), 7)
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(err, qt.ErrorMatches, types.ErrUnsupportedSignatureScheme.Error()+".*")
+	err = sqlite.StoreVotePackageSlot(censusRoot, 0, vote)
+	c.Assert(err, qt.ErrorMatches, types.ErrUnsupportedSignatureScheme.Error()+".*")
+	// as a Signature that does not have the length of its scheme
+	vote = newVote(bytes.Repeat([]byte{7}, 96), types.SignatureSchemeEdDSAPoseidon)
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(err, qt.ErrorMatches, "invalid eddsa-poseidon Signature length: 96,"+
+		" expected: 64")
+	err = sqlite.StoreVotePackageSlot(censusRoot, 0, vote)
+	c.Assert(err, qt.ErrorMatches, "invalid eddsa-poseidon Signature length: 96,"+
+		" expected: 64")
+
+	sig := types.CompressSignature(sk.SignPoseidon(big.NewInt(1)))
+	vote = newVote(sig, types.SignatureSchemeEdDSAPoseidon)
+	err = sqlite.StoreVotePackage(processID, vote)
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackageSlot(censusRoot, 0, vote)
+	c.Assert(err, qt.IsNil)
+
+	votes, err := sqlite.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(votes), qt.Equals, 1)
+	c.Assert(votes[0].Signature, qt.DeepEquals, sig)
+	c.Assert(votes[0].SignatureScheme, qt.Equals, types.SignatureSchemeEdDSAPoseidon)
+	votes, _, err = sqlite.ReadVotePackageSlots(censusRoot, sk.Public())
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(votes), qt.Equals, 1)
+	c.Assert(votes[0].Signature, qt.DeepEquals, sig)
+}
+
+func TestDistinctCensusRoots(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+
+	sqlite := NewSQLite(db)
+
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoots, err := sqlite.DistinctCensusRoots()
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(censusRoots), qt.Equals, 0)
+
+	// two processes share censusRootB, and the process with censusRootC
+	// has no votes
+	processes := []struct {
+		id         uint64
+		censusRoot []byte
+		nVotes     int
+	}{
+		{1, testCensusRoot("censusRootB"), 3},
+		{2, testCensusRoot("censusRootA"), 2},
+		{3, testCensusRoot("censusRootB"), 1},
+		{4, testCensusRoot("censusRootC"), 0},
+	}
+	index := 0
+	for _, p := range processes {
+		err = sqlite.StoreProcess(p.id, p.censusRoot, 100, 10, 20,
+			20, 60, 20, 1)
+		c.Assert(err, qt.IsNil)
+		for i := 0; i < p.nVotes; i++ {
+			sk := babyjub.NewRandPrivKey()
+			vote := types.VotePackage{
+				Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+				CensusProof: types.CensusProof{
+					Index:       uint64(index),
+					PublicKey:   sk.Public(),
+					Weight:      big.NewInt(1),
+					MerkleProof: []byte("test" + strconv.Itoa(index)),
+				},
+				Vote: []byte("test"),
+			}
+			err = sqlite.StoreVotePackage(p.id, vote)
+			c.Assert(err, qt.IsNil)
+			index++
+		}
+	}
+
+	censusRoots, err = sqlite.DistinctCensusRoots()
+	c.Assert(err, qt.IsNil)
+	c.Assert(censusRoots, qt.DeepEquals, [][]byte{
+		testCensusRoot("censusRootA"),
+		testCensusRoot("censusRootB"),
+	})
 }
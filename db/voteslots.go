@@ -0,0 +1,139 @@
+package db
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// StoreVotePackageSlot stores the given types.VotePackage for the given
+// CensusRoot in the given slot, so each voter can store multiple
+// VotePackages (for example the ordered preferences of a ranked-choice
+// vote), one for each slot, identified by the CensusRoot, the PublicKey and
+// the slot. The slots go from 0 to Options.MaxVoteSlots-1, for a slot out of
+// that range ErrMaxVoteSlots is returned. If the slot is already used by the
+// voter, ErrVoteAlreadyExists is returned, if the VotePackage contains a
+// Nullifier already used by another stored VotePackage (in a slot or not),
+// ErrNullifierUsed is returned, and if the vote is longer than the maximum
+// vote length, ErrVoteTooLarge is returned. The VotePackages are rate
+// limited by their CensusRoot, and their SignatureScheme checked, as the ones
+// stored by StoreVotePackage.
+func (r *SQLite) StoreVotePackageSlot(censusRoot []byte, slot uint64,
+	vote types.VotePackage) error {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return err
+	}
+	if slot >= uint64(r.maxVoteSlots) {
+		return fmt.Errorf("%w, slot: %d, max slots: %d", ErrMaxVoteSlots,
+			slot, r.maxVoteSlots)
+	}
+	if len(vote.Vote) > r.maxVoteLen {
+		return fmt.Errorf("%w, len(vote): %d, max: %d", ErrVoteTooLarge,
+			len(vote.Vote), r.maxVoteLen)
+	}
+	if vote.CensusProof.PublicKey == nil {
+		return fmt.Errorf("VotePackage without PublicKey")
+	}
+	if err := vote.SignatureScheme.CheckSignatureLen(vote.Signature); err != nil {
+		return err
+	}
+	if limiter := r.rateLimiter(); limiter != nil && !limiter.Allow(censusRoot) {
+		return ErrRateLimited
+	}
+
+	sqlQuery := `
+	INSERT INTO voteslots(
+		censusRoot,
+		publicKey,
+		slot,
+		indx,
+		weight,
+		merkleproof,
+		signature,
+		signatureScheme,
+		vote,
+		insertedDatetime,
+		nullifier
+	) values(?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
+	`
+
+	stmt, err := r.prepare(sqlQuery)
+	if err != nil {
+		return err
+	}
+
+	if vote.CensusProof.Weight == nil {
+		// no weight defined, use 1, which is the weight used to
+		// verify the CensusProof (see types.HashPubKBytes)
+		vote.CensusProof.Weight = big.NewInt(1)
+	}
+	// votes without nullifier are stored with a NULL nullifier, as in the
+	// votepackages table
+	var nullifier []byte
+	if len(vote.Nullifier) != 0 {
+		nullifier = vote.Nullifier
+	}
+
+	err = r.withRetry("StoreVotePackageSlot", func() error {
+		_, err := stmt.Exec(censusRoot, vote.CensusProof.PublicKey, slot,
+			vote.CensusProof.Index, vote.CensusProof.Weight.Bytes(),
+			vote.CensusProof.MerkleProof, []byte(vote.Signature),
+			vote.SignatureScheme, vote.Vote, nullifier)
+		return err
+	})
+	if err != nil {
+		return newDBError("StoreVotePackageSlot", err)
+	}
+	return nil
+}
+
+// ReadVotePackageSlots reads the types.VotePackage stored with
+// StoreVotePackageSlot by the given PublicKey for the given CensusRoot,
+// sorted by slot, returning also the slot of each VotePackage.
+func (r *SQLite) ReadVotePackageSlots(censusRoot []byte,
+	pubK *babyjub.PublicKey) ([]types.VotePackage, []uint64, error) {
+	if err := checkCensusRoot(censusRoot); err != nil {
+		return nil, nil, err
+	}
+	sqlQuery := `
+	SELECT slot, signature, signatureScheme, indx, publicKey, weight, merkleproof, vote,
+	insertedDatetime, nullifier FROM voteslots
+	WHERE censusRoot = ? AND publicKey = ?
+	ORDER BY slot ASC
+	`
+
+	rows, err := r.db.Query(sqlQuery, censusRoot, pubK)
+	if err != nil {
+		return nil, nil, newDBError("ReadVotePackageSlots", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var votes []types.VotePackage
+	var slots []uint64
+	for rows.Next() {
+		vote := types.VotePackage{}
+		var slot uint64
+		var sigBytes []byte
+		var weightBytes []byte
+		var nullifier []byte
+		err := rows.Scan(&slot, &sigBytes, &vote.SignatureScheme,
+			&vote.CensusProof.Index,
+			&vote.CensusProof.PublicKey, &weightBytes,
+			&vote.CensusProof.MerkleProof, &vote.Vote,
+			&vote.InsertedDatetime, &nullifier)
+		if err != nil {
+			return nil, nil, newDBError("ReadVotePackageSlots", err)
+		}
+		vote.Nullifier = nullifier
+		vote.CensusProof.Weight = new(big.Int).SetBytes(weightBytes)
+		vote.Signature = sigBytes
+		votes = append(votes, vote)
+		slots = append(slots, slot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, newDBError("ReadVotePackageSlots", err)
+	}
+	return votes, slots, nil
+}
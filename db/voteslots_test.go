@@ -0,0 +1,176 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestVotePackageSlots(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLiteWithOptions(db, Options{MaxVoteSlots: 3})
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+	keys := test.GenUserKeys(2)
+	newVote := func(i int, choice string) types.VotePackage {
+		return types.VotePackage{
+			Signature: types.CompressSignature(keys.PrivateKeys[i].SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   &keys.PublicKeys[i],
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote: []byte(choice),
+		}
+	}
+
+	// the voter submits 3 ranked choices, not in slot order
+	choices := []string{"first", "second", "third"}
+	for _, slot := range []uint64{2, 0, 1} {
+		err = sqlite.StoreVotePackageSlot(censusRoot, slot, newVote(0, choices[slot]))
+		c.Assert(err, qt.IsNil)
+	}
+
+	// a 4th choice is over the limit
+	err = sqlite.StoreVotePackageSlot(censusRoot, 3, newVote(0, "fourth"))
+	c.Assert(errors.Is(err, ErrMaxVoteSlots), qt.IsTrue)
+	// an already used slot can not be stored again
+	err = sqlite.StoreVotePackageSlot(censusRoot, 1, newVote(0, "other"))
+	c.Assert(errors.Is(err, ErrVoteAlreadyExists), qt.IsTrue)
+
+	votes, slots, err := sqlite.ReadVotePackageSlots(censusRoot, &keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(slots, qt.DeepEquals, []uint64{0, 1, 2})
+	c.Assert(len(votes), qt.Equals, 3)
+	for i := 0; i < len(votes); i++ {
+		c.Assert(string(votes[i].Vote), qt.Equals, choices[i])
+		c.Assert(votes[i].CensusProof.PublicKey.Compress(), qt.Equals,
+			keys.PublicKeys[0].Compress())
+		c.Assert(votes[i].CensusProof.Index, qt.Equals, uint64(0))
+	}
+
+	// the slots are independent for each voter and for each CensusRoot
+	err = sqlite.StoreVotePackageSlot(censusRoot, 0, newVote(1, "first"))
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackageSlot(testCensusRoot("censusRoot2"), 0, newVote(0, "first"))
+	c.Assert(err, qt.IsNil)
+	votes, slots, err = sqlite.ReadVotePackageSlots(censusRoot, &keys.PublicKeys[1])
+	c.Assert(err, qt.IsNil)
+	c.Assert(slots, qt.DeepEquals, []uint64{0})
+	c.Assert(len(votes), qt.Equals, 1)
+	votes, _, err = sqlite.ReadVotePackageSlots(censusRoot, &keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(votes), qt.Equals, 3)
+}
+
+func TestVotePackageSlotsNullifier(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := NewSQLiteWithOptions(db, Options{MaxVoteSlots: 3})
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+	err = sqlite.StoreProcess(1, censusRoot, 100, 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+	keys := test.GenUserKeys(4)
+	newVote := func(i int, nullifier string) types.VotePackage {
+		return types.VotePackage{
+			Signature: types.CompressSignature(keys.PrivateKeys[i].SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(i),
+				PublicKey:   &keys.PublicKeys[i],
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte("test" + strconv.Itoa(i)),
+			},
+			Vote:      []byte("test"),
+			Nullifier: []byte(nullifier),
+		}
+	}
+
+	err = sqlite.StoreVotePackageSlot(censusRoot, 0, newVote(0, "nullifier0"))
+	c.Assert(err, qt.IsNil)
+	// the Nullifier can not be used in another slot
+	err = sqlite.StoreVotePackageSlot(censusRoot, 1, newVote(0, "nullifier0"))
+	c.Assert(errors.Is(err, ErrNullifierUsed), qt.IsTrue)
+	err = sqlite.StoreVotePackageSlot(censusRoot, 0, newVote(1, "nullifier0"))
+	c.Assert(errors.Is(err, ErrNullifierUsed), qt.IsTrue)
+	// nor by a VotePackage stored without slot
+	err = sqlite.StoreVotePackage(1, newVote(2, "nullifier0"))
+	c.Assert(errors.Is(err, ErrNullifierUsed), qt.IsTrue)
+	err = sqlite.StoreOrReplaceVotePackage(1, newVote(2, "nullifier0"))
+	c.Assert(errors.Is(err, ErrNullifierUsed), qt.IsTrue)
+
+	// the Nullifier of a VotePackage stored without slot can not be used
+	// in a slot
+	err = sqlite.StoreVotePackage(1, newVote(2, "nullifier2"))
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackageSlot(censusRoot, 0, newVote(3, "nullifier2"))
+	c.Assert(errors.Is(err, ErrNullifierUsed), qt.IsTrue)
+
+	// the VotePackages without Nullifier are not affected
+	err = sqlite.StoreVotePackageSlot(censusRoot, 1, newVote(0, ""))
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackageSlot(censusRoot, 2, newVote(0, ""))
+	c.Assert(err, qt.IsNil)
+	err = sqlite.StoreVotePackage(1, newVote(3, ""))
+	c.Assert(err, qt.IsNil)
+}
+
+func TestVotePackageSlotsRateLimit(t *testing.T) {
+	c := qt.New(t)
+
+	db, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	// limit to 1 vote per second, with bursts of 3 votes, using a fixed
+	// clock
+	now := time.Now()
+	limiter := NewRateLimiter(1, 3)
+	limiter.now = func() time.Time { return now }
+	sqlite := NewSQLiteWithOptions(db, Options{MaxVoteSlots: 10,
+		RateLimiter: limiter})
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	censusRoot := testCensusRoot("censusRoot")
+	keys := test.GenUserKeys(1)
+	vote := types.VotePackage{
+		Signature: types.CompressSignature(keys.PrivateKeys[0].SignPoseidon(big.NewInt(1))),
+		CensusProof: types.CensusProof{
+			PublicKey:   &keys.PublicKeys[0],
+			Weight:      big.NewInt(1),
+			MerkleProof: []byte("test"),
+		},
+		Vote: []byte("test"),
+	}
+	for slot := uint64(0); slot < 3; slot++ {
+		err = sqlite.StoreVotePackageSlot(censusRoot, slot, vote)
+		c.Assert(err, qt.IsNil)
+	}
+	err = sqlite.StoreVotePackageSlot(censusRoot, 3, vote)
+	c.Assert(err, qt.Equals, ErrRateLimited)
+	// the votes of a different CensusRoot are not limited
+	err = sqlite.StoreVotePackageSlot(testCensusRoot("censusRoot2"), 0, vote)
+	c.Assert(err, qt.IsNil)
+
+	now = now.Add(time.Second)
+	err = sqlite.StoreVotePackageSlot(censusRoot, 3, vote)
+	c.Assert(err, qt.IsNil)
+}
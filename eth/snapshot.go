@@ -0,0 +1,343 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/aragon/ovote-node/censusbuilder"
+	ovotetypes "github.com/aragon/ovote-node/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-iden3-crypto/utils"
+	"go.vocdoni.io/dvote/log"
+)
+
+// DefaultLogsBlockRange defines the default number of blocks of each query of
+// the Transfer event logs, as the providers limit the range of the queries
+const DefaultLogsBlockRange = 5000
+
+// transferTopic is the topic of the Transfer(address,address,uint256) event,
+// which is the same for the ERC-20 and the ERC-721 tokens
+var transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// TokenStandard is used to define the standard of the token contract from
+// which the holders are obtained
+type TokenStandard int
+
+var (
+	// ERC20 indicates a fungible token, where the balance of each holder
+	// is the amount of tokens it holds
+	ERC20 TokenStandard = 0
+	// ERC721 indicates a non-fungible token, where the balance of each
+	// holder is the number of tokens it holds
+	ERC721 TokenStandard = 1
+)
+
+// String returns the name of the TokenStandard
+func (s TokenStandard) String() string {
+	switch s {
+	case ERC20:
+		return "erc20"
+	case ERC721:
+		return "erc721"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// TokenStandardFromString returns the TokenStandard of the given name
+func TokenStandardFromString(s string) (TokenStandard, error) {
+	switch strings.ToLower(s) {
+	case "erc20":
+		return ERC20, nil
+	case "erc721":
+		return ERC721, nil
+	default:
+		return 0, fmt.Errorf("unknown token standard %s, expected erc20 or"+
+			" erc721", s)
+	}
+}
+
+// Holder contains the balance of a token holder
+type Holder struct {
+	Address common.Address
+	Balance *big.Int
+}
+
+// HoldersOptions is used to pass the parameters to TokenHolders
+type HoldersOptions struct {
+	// Token is the address of the token contract
+	Token    common.Address
+	Standard TokenStandard
+	// FromBlock is the block from which the Transfer event logs are
+	// scanned, usually the block where the token contract was deployed
+	FromBlock uint64
+	// BlockNum is the block of the snapshot, the holders are the ones at
+	// the end of this block
+	BlockNum uint64
+	// BlockRange is the number of blocks of each query of the event logs.
+	// If not set, DefaultLogsBlockRange is used.
+	BlockRange uint64
+}
+
+// TokenHolders returns the holders of the token with a balance greater than 0
+// at the given block, sorted by address. The balances are computed from the
+// Transfer event logs of the token contract, querying the given client
+// (usually an *ethclient.Client) in ranges of BlockRange blocks.
+func TokenHolders(client ethereum.LogFilterer, opts HoldersOptions) ([]Holder, error) {
+	if opts.FromBlock > opts.BlockNum {
+		return nil, fmt.Errorf("FromBlock (%d) can not be greater than the"+
+			" BlockNum (%d)", opts.FromBlock, opts.BlockNum)
+	}
+	blockRange := opts.BlockRange
+	if blockRange == 0 {
+		blockRange = DefaultLogsBlockRange
+	}
+
+	balances := make(map[common.Address]*big.Int)
+	for from := opts.FromBlock; from <= opts.BlockNum; from += blockRange {
+		to := from + blockRange - 1
+		if to > opts.BlockNum {
+			to = opts.BlockNum
+		}
+		query := ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{opts.Token},
+			Topics:    [][]common.Hash{{transferTopic}},
+		}
+		logs, err := client.FilterLogs(context.Background(), query)
+		if err != nil {
+			return nil, fmt.Errorf("can not get the Transfer logs of the"+
+				" blocks %d-%d: %s", from, to, err)
+		}
+		for i := 0; i < len(logs); i++ {
+			if err := applyTransfer(balances, logs[i], opts.Standard); err != nil {
+				return nil, err
+			}
+		}
+		log.Debugf("Token %s: Transfer logs of the blocks %d-%d processed,"+
+			" %d addresses", opts.Token.Hex(), from, to, len(balances))
+	}
+
+	var holders []Holder
+	for addr, balance := range balances {
+		if balance.Sign() > 0 {
+			holders = append(holders, Holder{Address: addr, Balance: balance})
+		}
+	}
+	sort.Slice(holders, func(i, j int) bool {
+		return bytes.Compare(holders[i].Address[:], holders[j].Address[:]) < 0
+	})
+	return holders, nil
+}
+
+// applyTransfer updates the given balances with the given Transfer event log
+func applyTransfer(balances map[common.Address]*big.Int, l types.Log,
+	standard TokenStandard) error {
+	var amount *big.Int
+	switch standard {
+	case ERC20:
+		// Transfer(address indexed from, address indexed to, uint256 value)
+		if len(l.Topics) != 3 || len(l.Data) != 32 { //nolint:gomnd
+			return fmt.Errorf("blocknum: %d, invalid ERC-20 Transfer log,"+
+				" %d topics, data: %x", l.BlockNumber, len(l.Topics), l.Data)
+		}
+		amount = new(big.Int).SetBytes(l.Data)
+	case ERC721:
+		// Transfer(address indexed from, address indexed to,
+		// uint256 indexed tokenId)
+		if len(l.Topics) != 4 { //nolint:gomnd
+			return fmt.Errorf("blocknum: %d, invalid ERC-721 Transfer log,"+
+				" %d topics", l.BlockNumber, len(l.Topics))
+		}
+		amount = big.NewInt(1)
+	default:
+		return fmt.Errorf("unknown TokenStandard %s", standard)
+	}
+	from := common.BytesToAddress(l.Topics[1].Bytes())
+	to := common.BytesToAddress(l.Topics[2].Bytes())
+
+	// the transfers from the zero address are mints, and the transfers
+	// to the zero address are burns
+	if from != (common.Address{}) {
+		balance, ok := balances[from]
+		if !ok {
+			balance = big.NewInt(0)
+			balances[from] = balance
+		}
+		balance.Sub(balance, amount)
+		if balance.Sign() < 0 {
+			return fmt.Errorf("blocknum: %d, negative balance of %s, the"+
+				" logs may not start at the deployment of the token",
+				l.BlockNumber, from.Hex())
+		}
+	}
+	if to != (common.Address{}) {
+		balance, ok := balances[to]
+		if !ok {
+			balance = big.NewInt(0)
+			balances[to] = balance
+		}
+		balance.Add(balance, amount)
+	}
+	return nil
+}
+
+// ReadHoldersSnapshot reads the holders of a snapshot file in CSV format,
+// where each row contains an address and its balance. The first row can be a
+// header starting with "address", and the rows starting with '#' are
+// ignored. The holders with a balance of 0 are skipped.
+func ReadHoldersSnapshot(r io.Reader) ([]Holder, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var holders []Holder
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return holders, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if first && strings.EqualFold(record[0], "address") {
+			continue
+		}
+		if len(record) != 2 { //nolint:gomnd
+			return nil, fmt.Errorf("line %d: expected address and balance,"+
+				" got %d fields", line, len(record))
+		}
+		if !common.IsHexAddress(record[0]) {
+			return nil, fmt.Errorf("line %d: invalid address %s", line, record[0])
+		}
+		balance, ok := new(big.Int).SetString(record[1], 10) //nolint:gomnd
+		if !ok || balance.Sign() < 0 {
+			return nil, fmt.Errorf("line %d: invalid balance %s", line, record[1])
+		}
+		if balance.Sign() == 0 {
+			continue
+		}
+		holders = append(holders, Holder{Address: common.HexToAddress(record[0]),
+			Balance: balance})
+	}
+}
+
+// KeyRegistry contains the babyjub PublicKeys registered for each Ethereum
+// address
+type KeyRegistry map[common.Address]babyjub.PublicKey
+
+// ReadKeyRegistry reads a KeyRegistry from a JSON object, where each key is an
+// Ethereum address and each value its compressed PublicKey in hex
+func ReadKeyRegistry(r io.Reader) (KeyRegistry, error) {
+	var m map[string]babyjub.PublicKey
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("can not decode the KeyRegistry: %s", err)
+	}
+	registry := make(KeyRegistry, len(m))
+	for addr, pubK := range m {
+		if !common.IsHexAddress(addr) {
+			return nil, fmt.Errorf("invalid address %s in the KeyRegistry", addr)
+		}
+		registry[common.HexToAddress(addr)] = pubK
+	}
+	return registry, nil
+}
+
+// ErrNoHolders is used when none of the given holders can be added to the
+// Census
+var ErrNoHolders = errors.New("no holders with a registered PublicKey")
+
+// SkippedHolder contains a holder that has not been added to the Census, and
+// the reason
+type SkippedHolder struct {
+	Address common.Address `json:"address"`
+	Reason  string         `json:"reason"`
+}
+
+// SnapshotReport contains the result of NewCensusFromHolders
+type SnapshotReport struct {
+	CensusID ovotetypes.CensusID `json:"censusID"`
+	// Added is the number of PublicKeys added to the Census
+	Added   uint64          `json:"added"`
+	Skipped []SkippedHolder `json:"skipped,omitempty"`
+}
+
+// NewCensusFromHolders creates a new Census in the given CensusBuilder with the
+// PublicKeys registered in the given KeyRegistry for the given holders, using
+// their balances as weights. The holders without a registered PublicKey, or
+// with a balance that does not fit in the field, are skipped. When the same
+// PublicKey is registered for more than one holder, the PublicKey gets the
+// sum of their balances. The Census is left open.
+func NewCensusFromHolders(cb *censusbuilder.CensusBuilder, holders []Holder,
+	registry KeyRegistry) (*SnapshotReport, error) {
+	report := &SnapshotReport{}
+	var pubKs []babyjub.PublicKey
+	var weights []*big.Int
+	// the holders of each PublicKey, by position in pubKs
+	var owners [][]common.Address
+	positions := make(map[babyjub.PublicKeyComp]int)
+	for i := 0; i < len(holders); i++ {
+		pubK, ok := registry[holders[i].Address]
+		if !ok {
+			report.Skipped = append(report.Skipped, SkippedHolder{
+				Address: holders[i].Address, Reason: "no registered PublicKey"})
+			continue
+		}
+		pubKComp := pubK.Compress()
+		if j, ok := positions[pubKComp]; ok {
+			weights[j] = new(big.Int).Add(weights[j], holders[i].Balance)
+			owners[j] = append(owners[j], holders[i].Address)
+			continue
+		}
+		positions[pubKComp] = len(pubKs)
+		pubKs = append(pubKs, pubK)
+		weights = append(weights, new(big.Int).Set(holders[i].Balance))
+		owners = append(owners, []common.Address{holders[i].Address})
+	}
+
+	var validPubKs []babyjub.PublicKey
+	var validWeights []*big.Int
+	for i := 0; i < len(pubKs); i++ {
+		if !utils.CheckBigIntInField(weights[i]) {
+			for _, addr := range owners[i] {
+				report.Skipped = append(report.Skipped, SkippedHolder{
+					Address: addr, Reason: "balance does not fit in the field"})
+			}
+			continue
+		}
+		validPubKs = append(validPubKs, pubKs[i])
+		validWeights = append(validWeights, weights[i])
+	}
+	if len(validPubKs) == 0 {
+		return report, ErrNoHolders
+	}
+
+	censusID, err := cb.NewCensus()
+	if err != nil {
+		return report, err
+	}
+	report.CensusID = censusID
+	if err := cb.AddPublicKeys(censusID, validPubKs, validWeights); err != nil {
+		return report, err
+	}
+	report.Added = uint64(len(validPubKs))
+	log.Debugf("[CensusID=%d] created from %d holders, %d PublicKeys added,"+
+		" %d holders skipped", censusID, len(holders), report.Added,
+		len(report.Skipped))
+	return report, nil
+}
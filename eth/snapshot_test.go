@@ -0,0 +1,194 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/aragon/ovote-node/censusbuilder"
+	"github.com/aragon/ovote-node/test"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	qt "github.com/frankban/quicktest"
+	kvdb "go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/db/pebbledb"
+)
+
+// testLogFilterer implements the ethereum.LogFilterer interface returning the
+// given logs
+type testLogFilterer struct {
+	logs    []types.Log
+	queries int
+}
+
+func (f *testLogFilterer) FilterLogs(_ context.Context,
+	q ethereum.FilterQuery) ([]types.Log, error) {
+	f.queries++
+	var logs []types.Log
+	for _, l := range f.logs {
+		if l.BlockNumber >= q.FromBlock.Uint64() && l.BlockNumber <= q.ToBlock.Uint64() {
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
+
+func (f *testLogFilterer) SubscribeFilterLogs(context.Context, ethereum.FilterQuery,
+	chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func transferLog(blockNum uint64, from, to common.Address, value int64,
+	standard TokenStandard) types.Log {
+	l := types.Log{
+		BlockNumber: blockNum,
+		Topics: []common.Hash{transferTopic, common.BytesToHash(from[:]),
+			common.BytesToHash(to[:])},
+	}
+	if standard == ERC721 {
+		// the value is the tokenId
+		l.Topics = append(l.Topics, common.BigToHash(big.NewInt(value)))
+	} else {
+		l.Data = common.BigToHash(big.NewInt(value)).Bytes()
+	}
+	return l
+}
+
+func TestTokenHolders(t *testing.T) {
+	c := qt.New(t)
+
+	zero := common.Address{}
+	a := common.HexToAddress("0x01")
+	b := common.HexToAddress("0x02")
+	d := common.HexToAddress("0x03")
+
+	f := &testLogFilterer{logs: []types.Log{
+		transferLog(10, zero, a, 100, ERC20),
+		transferLog(15, a, b, 30, ERC20),
+		transferLog(22, zero, d, 5, ERC20),
+		transferLog(25, d, zero, 5, ERC20),
+		// after the snapshot block
+		transferLog(40, a, d, 70, ERC20),
+	}}
+	holders, err := TokenHolders(f, HoldersOptions{Standard: ERC20,
+		FromBlock: 10, BlockNum: 30, BlockRange: 10})
+	c.Assert(err, qt.IsNil)
+	c.Assert(f.queries, qt.Equals, 3)
+	c.Assert(len(holders), qt.Equals, 2)
+	c.Assert(holders[0].Address, qt.Equals, a)
+	c.Assert(holders[0].Balance.Int64(), qt.Equals, int64(70))
+	c.Assert(holders[1].Address, qt.Equals, b)
+	c.Assert(holders[1].Balance.Int64(), qt.Equals, int64(30))
+
+	// the ERC-721 balance is the number of tokens
+	f = &testLogFilterer{logs: []types.Log{
+		transferLog(1, zero, a, 1, ERC721),
+		transferLog(1, zero, a, 2, ERC721),
+		transferLog(2, a, b, 1, ERC721),
+	}}
+	holders, err = TokenHolders(f, HoldersOptions{Standard: ERC721, BlockNum: 5})
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(holders), qt.Equals, 2)
+	c.Assert(holders[0].Balance.Int64(), qt.Equals, int64(1))
+	c.Assert(holders[1].Balance.Int64(), qt.Equals, int64(1))
+
+	// logs not starting at the deployment of the token
+	_, err = TokenHolders(f, HoldersOptions{Standard: ERC721, FromBlock: 2,
+		BlockNum: 5})
+	c.Assert(err, qt.ErrorMatches, ".*negative balance.*")
+	// an ERC-20 log read as ERC-721
+	f = &testLogFilterer{logs: []types.Log{transferLog(1, zero, a, 1, ERC20)}}
+	_, err = TokenHolders(f, HoldersOptions{Standard: ERC721, BlockNum: 5})
+	c.Assert(err, qt.ErrorMatches, ".*invalid ERC-721 Transfer log.*")
+}
+
+func TestReadHoldersSnapshot(t *testing.T) {
+	c := qt.New(t)
+
+	snapshot := "address,balance\n" +
+		"# comment\n" +
+		"0x0000000000000000000000000000000000000001,100\n" +
+		"0x0000000000000000000000000000000000000002,0\n" +
+		"0x0000000000000000000000000000000000000003, 7\n"
+	holders, err := ReadHoldersSnapshot(strings.NewReader(snapshot))
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(holders), qt.Equals, 2)
+	c.Assert(holders[0].Address, qt.Equals, common.HexToAddress("0x01"))
+	c.Assert(holders[0].Balance.Int64(), qt.Equals, int64(100))
+	c.Assert(holders[1].Balance.Int64(), qt.Equals, int64(7))
+
+	_, err = ReadHoldersSnapshot(strings.NewReader("0x01,1\n"))
+	c.Assert(err, qt.ErrorMatches, "line 1: invalid address 0x01")
+	_, err = ReadHoldersSnapshot(strings.NewReader(
+		"0x0000000000000000000000000000000000000001,-1\n"))
+	c.Assert(err, qt.ErrorMatches, "line 1: invalid balance -1")
+}
+
+func TestNewCensusFromHolders(t *testing.T) {
+	c := qt.New(t)
+
+	database, err := pebbledb.New(kvdb.Options{Path: c.TempDir()})
+	c.Assert(err, qt.IsNil)
+	cb, err := censusbuilder.New(database, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	keys := test.GenUserKeys(3)
+	var holders []Holder
+	for i := 1; i <= 5; i++ {
+		holders = append(holders, Holder{Address: common.BigToAddress(big.NewInt(int64(i))),
+			Balance: big.NewInt(int64(10 * i))})
+	}
+	var registryJSON bytes.Buffer
+	registryJSON.WriteString("{")
+	// the holders 1 and 2 share the same PublicKey, 4 has no PublicKey
+	for i, k := range []int{0, 0, 1, -1, 2} {
+		if k < 0 {
+			continue
+		}
+		pubKComp := keys.PublicKeys[k].Compress()
+		if registryJSON.Len() > 1 {
+			registryJSON.WriteString(",")
+		}
+		fmt.Fprintf(&registryJSON, "%q:%q", holders[i].Address.Hex(),
+			pubKComp.String())
+	}
+	registryJSON.WriteString("}")
+	registry, err := ReadKeyRegistry(&registryJSON)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(registry), qt.Equals, 4)
+
+	report, err := NewCensusFromHolders(cb, holders, registry)
+	c.Assert(err, qt.IsNil)
+	c.Assert(report.Added, qt.Equals, uint64(3))
+	c.Assert(report.Skipped, qt.DeepEquals, []SkippedHolder{
+		{Address: holders[3].Address, Reason: "no registered PublicKey"}})
+
+	err = cb.CloseCensus(report.CensusID)
+	c.Assert(err, qt.IsNil)
+	expectedWeights := []int64{30, 30, 50}
+	for i := 0; i < len(keys.PublicKeys); i++ {
+		cp, err := cb.GetCensusProof(report.CensusID, &keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(cp.Weight.Int64(), qt.Equals, expectedWeights[i])
+	}
+
+	// without registered PublicKeys no Census is created
+	_, err = NewCensusFromHolders(cb, holders[3:4], registry)
+	c.Assert(err, qt.Equals, ErrNoHolders)
+	// the balances that do not fit in the field are skipped
+	huge := new(big.Int).Lsh(big.NewInt(1), 255)
+	_, err = NewCensusFromHolders(cb, []Holder{{Address: holders[0].Address,
+		Balance: huge}}, registry)
+	c.Assert(err, qt.Equals, ErrNoHolders)
+
+	pubKComp := keys.PublicKeys[0].Compress()
+	_, err = ReadKeyRegistry(strings.NewReader(`{"0x01":"` +
+		pubKComp.String() + `"}`))
+	c.Assert(err, qt.ErrorMatches, "invalid address 0x01 in the KeyRegistry")
+
+	c.Assert(cb.Close(), qt.IsNil)
+}
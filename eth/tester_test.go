@@ -25,7 +25,7 @@ func TestAdvanceBlock(t *testing.T) {
 	events := make(map[uint64][]TestEvent)
 	events[1001] = []TestEvent{{
 		ID:               1,
-		CensusRoot:       []byte("root"),
+		CensusRoot:       make([]byte, types.RootLen),
 		CensusSize:       100,
 		ResPubStartBlock: 1010,
 		ResPubWindow:     300,
@@ -34,7 +34,7 @@ func TestAdvanceBlock(t *testing.T) {
 	}}
 	events[1002] = []TestEvent{{
 		ID:               2,
-		CensusRoot:       []byte("root"),
+		CensusRoot:       make([]byte, types.RootLen),
 		CensusSize:       100,
 		ResPubStartBlock: 1011,
 		ResPubWindow:     300,
@@ -3,6 +3,7 @@ module github.com/aragon/ovote-node
 go 1.17
 
 require (
+	github.com/cockroachdb/pebble v0.0.0-20211004132338-b2eb88a71826
 	github.com/ethereum/go-ethereum v1.10.8
 	github.com/frankban/quicktest v1.13.0
 	github.com/gin-gonic/gin v1.6.3
@@ -12,6 +13,8 @@ require (
 	github.com/spf13/pflag v1.0.5
 	github.com/vocdoni/arbo v0.0.0-20220204101222-688a2e814db0
 	go.vocdoni.io/dvote v1.0.4-0.20211025120558-83c64f440044
+	google.golang.org/grpc v1.45.0
+	google.golang.org/protobuf v1.27.1
 )
 
 require (
@@ -21,7 +24,6 @@ require (
 	github.com/cespare/xxhash/v2 v2.1.1 // indirect
 	github.com/cockroachdb/errors v1.8.1 // indirect
 	github.com/cockroachdb/logtags v0.0.0-20190617123548-eb05cc24525f // indirect
-	github.com/cockroachdb/pebble v0.0.0-20211004132338-b2eb88a71826 // indirect
 	github.com/cockroachdb/redact v1.0.8 // indirect
 	github.com/cockroachdb/sentry-go v0.6.1-cockroachdb.2 // indirect
 	github.com/dchest/blake512 v1.0.0 // indirect
@@ -55,9 +57,11 @@ require (
 	go.uber.org/zap v1.18.1 // indirect
 	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871 // indirect
 	golang.org/x/exp v0.0.0-20200513190911-00229845015e // indirect
+	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2 // indirect
 	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e // indirect
+	golang.org/x/text v0.3.6 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
-	google.golang.org/protobuf v1.27.1 // indirect
+	google.golang.org/genproto v0.0.0-20210602131652-f16073e35f0c // indirect
 	gopkg.in/natefinch/npipe.v2 v2.0.0-20160621034901-c1b8fa8bdcce // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
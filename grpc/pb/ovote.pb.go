@@ -0,0 +1,1282 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: ovote.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CreateCensusRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// sortKeys assigns the indexes of the PublicKeys by their compressed
+	// bytes when the Census is closed
+	SortKeys bool `protobuf:"varint,1,opt,name=sortKeys,proto3" json:"sortKeys,omitempty"`
+}
+
+func (x *CreateCensusRequest) Reset() {
+	*x = CreateCensusRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateCensusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCensusRequest) ProtoMessage() {}
+
+func (x *CreateCensusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCensusRequest.ProtoReflect.Descriptor instead.
+func (*CreateCensusRequest) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CreateCensusRequest) GetSortKeys() bool {
+	if x != nil {
+		return x.SortKeys
+	}
+	return false
+}
+
+type CreateCensusResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CensusID uint64 `protobuf:"varint,1,opt,name=censusID,proto3" json:"censusID,omitempty"`
+}
+
+func (x *CreateCensusResponse) Reset() {
+	*x = CreateCensusResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateCensusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCensusResponse) ProtoMessage() {}
+
+func (x *CreateCensusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCensusResponse.ProtoReflect.Descriptor instead.
+func (*CreateCensusResponse) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateCensusResponse) GetCensusID() uint64 {
+	if x != nil {
+		return x.CensusID
+	}
+	return 0
+}
+
+type AddKeysRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CensusID uint64 `protobuf:"varint,1,opt,name=censusID,proto3" json:"censusID,omitempty"`
+	// publicKeys are the compressed PublicKeys
+	PublicKeys [][]byte `protobuf:"bytes,2,rep,name=publicKeys,proto3" json:"publicKeys,omitempty"`
+	// weights are the big-endian weights of the publicKeys
+	Weights [][]byte `protobuf:"bytes,3,rep,name=weights,proto3" json:"weights,omitempty"`
+}
+
+func (x *AddKeysRequest) Reset() {
+	*x = AddKeysRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddKeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddKeysRequest) ProtoMessage() {}
+
+func (x *AddKeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddKeysRequest.ProtoReflect.Descriptor instead.
+func (*AddKeysRequest) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AddKeysRequest) GetCensusID() uint64 {
+	if x != nil {
+		return x.CensusID
+	}
+	return 0
+}
+
+func (x *AddKeysRequest) GetPublicKeys() [][]byte {
+	if x != nil {
+		return x.PublicKeys
+	}
+	return nil
+}
+
+func (x *AddKeysRequest) GetWeights() [][]byte {
+	if x != nil {
+		return x.Weights
+	}
+	return nil
+}
+
+type AddKeysResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	NKeys uint64 `protobuf:"varint,1,opt,name=nKeys,proto3" json:"nKeys,omitempty"`
+}
+
+func (x *AddKeysResponse) Reset() {
+	*x = AddKeysResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddKeysResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddKeysResponse) ProtoMessage() {}
+
+func (x *AddKeysResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddKeysResponse.ProtoReflect.Descriptor instead.
+func (*AddKeysResponse) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AddKeysResponse) GetNKeys() uint64 {
+	if x != nil {
+		return x.NKeys
+	}
+	return 0
+}
+
+type CloseCensusRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CensusID uint64 `protobuf:"varint,1,opt,name=censusID,proto3" json:"censusID,omitempty"`
+}
+
+func (x *CloseCensusRequest) Reset() {
+	*x = CloseCensusRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CloseCensusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloseCensusRequest) ProtoMessage() {}
+
+func (x *CloseCensusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloseCensusRequest.ProtoReflect.Descriptor instead.
+func (*CloseCensusRequest) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CloseCensusRequest) GetCensusID() uint64 {
+	if x != nil {
+		return x.CensusID
+	}
+	return 0
+}
+
+type CloseCensusResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CensusRoot []byte `protobuf:"bytes,1,opt,name=censusRoot,proto3" json:"censusRoot,omitempty"`
+}
+
+func (x *CloseCensusResponse) Reset() {
+	*x = CloseCensusResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CloseCensusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloseCensusResponse) ProtoMessage() {}
+
+func (x *CloseCensusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloseCensusResponse.ProtoReflect.Descriptor instead.
+func (*CloseCensusResponse) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CloseCensusResponse) GetCensusRoot() []byte {
+	if x != nil {
+		return x.CensusRoot
+	}
+	return nil
+}
+
+type GetProofRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CensusID  uint64 `protobuf:"varint,1,opt,name=censusID,proto3" json:"censusID,omitempty"`
+	PublicKey []byte `protobuf:"bytes,2,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+}
+
+func (x *GetProofRequest) Reset() {
+	*x = GetProofRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetProofRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProofRequest) ProtoMessage() {}
+
+func (x *GetProofRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProofRequest.ProtoReflect.Descriptor instead.
+func (*GetProofRequest) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetProofRequest) GetCensusID() uint64 {
+	if x != nil {
+		return x.CensusID
+	}
+	return 0
+}
+
+func (x *GetProofRequest) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+type GetProofsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CensusID   uint64   `protobuf:"varint,1,opt,name=censusID,proto3" json:"censusID,omitempty"`
+	PublicKeys [][]byte `protobuf:"bytes,2,rep,name=publicKeys,proto3" json:"publicKeys,omitempty"`
+}
+
+func (x *GetProofsRequest) Reset() {
+	*x = GetProofsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetProofsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProofsRequest) ProtoMessage() {}
+
+func (x *GetProofsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProofsRequest.ProtoReflect.Descriptor instead.
+func (*GetProofsRequest) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetProofsRequest) GetCensusID() uint64 {
+	if x != nil {
+		return x.CensusID
+	}
+	return 0
+}
+
+func (x *GetProofsRequest) GetPublicKeys() [][]byte {
+	if x != nil {
+		return x.PublicKeys
+	}
+	return nil
+}
+
+type CensusProof struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Index       uint64 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	PublicKey   []byte `protobuf:"bytes,2,opt,name=publicKey,proto3" json:"publicKey,omitempty"`
+	Weight      []byte `protobuf:"bytes,3,opt,name=weight,proto3" json:"weight,omitempty"`
+	MerkleProof []byte `protobuf:"bytes,4,opt,name=merkleProof,proto3" json:"merkleProof,omitempty"`
+}
+
+func (x *CensusProof) Reset() {
+	*x = CensusProof{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CensusProof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CensusProof) ProtoMessage() {}
+
+func (x *CensusProof) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CensusProof.ProtoReflect.Descriptor instead.
+func (*CensusProof) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CensusProof) GetIndex() uint64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *CensusProof) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *CensusProof) GetWeight() []byte {
+	if x != nil {
+		return x.Weight
+	}
+	return nil
+}
+
+func (x *CensusProof) GetMerkleProof() []byte {
+	if x != nil {
+		return x.MerkleProof
+	}
+	return nil
+}
+
+type VotePackage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Signature   []byte       `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"`
+	CensusProof *CensusProof `protobuf:"bytes,2,opt,name=censusProof,proto3" json:"censusProof,omitempty"`
+	Vote        []byte       `protobuf:"bytes,3,opt,name=vote,proto3" json:"vote,omitempty"`
+	Nullifier   []byte       `protobuf:"bytes,4,opt,name=nullifier,proto3" json:"nullifier,omitempty"`
+	// signatureScheme is the types.SignatureScheme of the signature
+	SignatureScheme uint32 `protobuf:"varint,5,opt,name=signatureScheme,proto3" json:"signatureScheme,omitempty"`
+}
+
+func (x *VotePackage) Reset() {
+	*x = VotePackage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *VotePackage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VotePackage) ProtoMessage() {}
+
+func (x *VotePackage) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VotePackage.ProtoReflect.Descriptor instead.
+func (*VotePackage) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *VotePackage) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *VotePackage) GetCensusProof() *CensusProof {
+	if x != nil {
+		return x.CensusProof
+	}
+	return nil
+}
+
+func (x *VotePackage) GetVote() []byte {
+	if x != nil {
+		return x.Vote
+	}
+	return nil
+}
+
+func (x *VotePackage) GetNullifier() []byte {
+	if x != nil {
+		return x.Nullifier
+	}
+	return nil
+}
+
+func (x *VotePackage) GetSignatureScheme() uint32 {
+	if x != nil {
+		return x.SignatureScheme
+	}
+	return 0
+}
+
+type SubmitVoteRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ProcessID   uint64       `protobuf:"varint,1,opt,name=processID,proto3" json:"processID,omitempty"`
+	VotePackage *VotePackage `protobuf:"bytes,2,opt,name=votePackage,proto3" json:"votePackage,omitempty"`
+}
+
+func (x *SubmitVoteRequest) Reset() {
+	*x = SubmitVoteRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SubmitVoteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitVoteRequest) ProtoMessage() {}
+
+func (x *SubmitVoteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitVoteRequest.ProtoReflect.Descriptor instead.
+func (*SubmitVoteRequest) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SubmitVoteRequest) GetProcessID() uint64 {
+	if x != nil {
+		return x.ProcessID
+	}
+	return 0
+}
+
+func (x *SubmitVoteRequest) GetVotePackage() *VotePackage {
+	if x != nil {
+		return x.VotePackage
+	}
+	return nil
+}
+
+type SubmitVoteResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *SubmitVoteResponse) Reset() {
+	*x = SubmitVoteResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SubmitVoteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitVoteResponse) ProtoMessage() {}
+
+func (x *SubmitVoteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitVoteResponse.ProtoReflect.Descriptor instead.
+func (*SubmitVoteResponse) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{11}
+}
+
+type GetProcessRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ProcessID uint64 `protobuf:"varint,1,opt,name=processID,proto3" json:"processID,omitempty"`
+}
+
+func (x *GetProcessRequest) Reset() {
+	*x = GetProcessRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetProcessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProcessRequest) ProtoMessage() {}
+
+func (x *GetProcessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProcessRequest.ProtoReflect.Descriptor instead.
+func (*GetProcessRequest) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetProcessRequest) GetProcessID() uint64 {
+	if x != nil {
+		return x.ProcessID
+	}
+	return 0
+}
+
+type Process struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id               uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	CensusRoot       []byte `protobuf:"bytes,2,opt,name=censusRoot,proto3" json:"censusRoot,omitempty"`
+	CensusSize       uint64 `protobuf:"varint,3,opt,name=censusSize,proto3" json:"censusSize,omitempty"`
+	EthBlockNum      uint64 `protobuf:"varint,4,opt,name=ethBlockNum,proto3" json:"ethBlockNum,omitempty"`
+	ResPubStartBlock uint64 `protobuf:"varint,5,opt,name=resPubStartBlock,proto3" json:"resPubStartBlock,omitempty"`
+	ResPubWindow     uint64 `protobuf:"varint,6,opt,name=resPubWindow,proto3" json:"resPubWindow,omitempty"`
+	MinParticipation uint32 `protobuf:"varint,7,opt,name=minParticipation,proto3" json:"minParticipation,omitempty"`
+	MinPositiveVotes uint32 `protobuf:"varint,8,opt,name=minPositiveVotes,proto3" json:"minPositiveVotes,omitempty"`
+	Type             uint32 `protobuf:"varint,9,opt,name=type,proto3" json:"type,omitempty"`
+	Status           uint32 `protobuf:"varint,10,opt,name=status,proto3" json:"status,omitempty"`
+}
+
+func (x *Process) Reset() {
+	*x = Process{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Process) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Process) ProtoMessage() {}
+
+func (x *Process) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Process.ProtoReflect.Descriptor instead.
+func (*Process) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Process) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Process) GetCensusRoot() []byte {
+	if x != nil {
+		return x.CensusRoot
+	}
+	return nil
+}
+
+func (x *Process) GetCensusSize() uint64 {
+	if x != nil {
+		return x.CensusSize
+	}
+	return 0
+}
+
+func (x *Process) GetEthBlockNum() uint64 {
+	if x != nil {
+		return x.EthBlockNum
+	}
+	return 0
+}
+
+func (x *Process) GetResPubStartBlock() uint64 {
+	if x != nil {
+		return x.ResPubStartBlock
+	}
+	return 0
+}
+
+func (x *Process) GetResPubWindow() uint64 {
+	if x != nil {
+		return x.ResPubWindow
+	}
+	return 0
+}
+
+func (x *Process) GetMinParticipation() uint32 {
+	if x != nil {
+		return x.MinParticipation
+	}
+	return 0
+}
+
+func (x *Process) GetMinPositiveVotes() uint32 {
+	if x != nil {
+		return x.MinPositiveVotes
+	}
+	return 0
+}
+
+func (x *Process) GetType() uint32 {
+	if x != nil {
+		return x.Type
+	}
+	return 0
+}
+
+func (x *Process) GetStatus() uint32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+type GetVotesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ProcessID uint64 `protobuf:"varint,1,opt,name=processID,proto3" json:"processID,omitempty"`
+}
+
+func (x *GetVotesRequest) Reset() {
+	*x = GetVotesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ovote_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetVotesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVotesRequest) ProtoMessage() {}
+
+func (x *GetVotesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ovote_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVotesRequest.ProtoReflect.Descriptor instead.
+func (*GetVotesRequest) Descriptor() ([]byte, []int) {
+	return file_ovote_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetVotesRequest) GetProcessID() uint64 {
+	if x != nil {
+		return x.ProcessID
+	}
+	return 0
+}
+
+var File_ovote_proto protoreflect.FileDescriptor
+
+var file_ovote_proto_rawDesc = []byte{
+	0x0a, 0x0b, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x05, 0x6f,
+	0x76, 0x6f, 0x74, 0x65, 0x22, 0x31, 0x0a, 0x13, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x65,
+	0x6e, 0x73, 0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x73,
+	0x6f, 0x72, 0x74, 0x4b, 0x65, 0x79, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x73,
+	0x6f, 0x72, 0x74, 0x4b, 0x65, 0x79, 0x73, 0x22, 0x32, 0x0a, 0x14, 0x43, 0x72, 0x65, 0x61, 0x74,
+	0x65, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x1a, 0x0a, 0x08, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x08, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x49, 0x44, 0x22, 0x66, 0x0a, 0x0e, 0x41,
+	0x64, 0x64, 0x4b, 0x65, 0x79, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a,
+	0x08, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x08, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x49, 0x44, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x75, 0x62,
+	0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x0a, 0x70,
+	0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x77, 0x65, 0x69,
+	0x67, 0x68, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x07, 0x77, 0x65, 0x69, 0x67,
+	0x68, 0x74, 0x73, 0x22, 0x27, 0x0a, 0x0f, 0x41, 0x64, 0x64, 0x4b, 0x65, 0x79, 0x73, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x4b, 0x65, 0x79, 0x73, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x6e, 0x4b, 0x65, 0x79, 0x73, 0x22, 0x30, 0x0a, 0x12,
+	0x43, 0x6c, 0x6f, 0x73, 0x65, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x49, 0x44, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x49, 0x44, 0x22, 0x35,
+	0x0a, 0x13, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x52,
+	0x6f, 0x6f, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x63, 0x65, 0x6e, 0x73, 0x75,
+	0x73, 0x52, 0x6f, 0x6f, 0x74, 0x22, 0x4b, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x50, 0x72, 0x6f, 0x6f,
+	0x66, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x65, 0x6e, 0x73,
+	0x75, 0x73, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x63, 0x65, 0x6e, 0x73,
+	0x75, 0x73, 0x49, 0x44, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65,
+	0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b,
+	0x65, 0x79, 0x22, 0x4e, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73,
+	0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73,
+	0x49, 0x44, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x73,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65,
+	0x79, 0x73, 0x22, 0x7b, 0x0a, 0x0b, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x50, 0x72, 0x6f, 0x6f,
+	0x66, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69,
+	0x63, 0x4b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x75, 0x62, 0x6c,
+	0x69, 0x63, 0x4b, 0x65, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x20, 0x0a,
+	0x0b, 0x6d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x0b, 0x6d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x22,
+	0xbd, 0x01, 0x0a, 0x0b, 0x56, 0x6f, 0x74, 0x65, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x12,
+	0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x34, 0x0a,
+	0x0b, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x43, 0x65, 0x6e, 0x73, 0x75,
+	0x73, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x52, 0x0b, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x50, 0x72,
+	0x6f, 0x6f, 0x66, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x6f, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x04, 0x76, 0x6f, 0x74, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x75, 0x6c, 0x6c, 0x69,
+	0x66, 0x69, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x6e, 0x75, 0x6c, 0x6c,
+	0x69, 0x66, 0x69, 0x65, 0x72, 0x12, 0x28, 0x0a, 0x0f, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0f,
+	0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x22,
+	0x67, 0x0a, 0x11, 0x53, 0x75, 0x62, 0x6d, 0x69, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x49,
+	0x44, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
+	0x49, 0x44, 0x12, 0x34, 0x0a, 0x0b, 0x76, 0x6f, 0x74, 0x65, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e,
+	0x56, 0x6f, 0x74, 0x65, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x52, 0x0b, 0x76, 0x6f, 0x74,
+	0x65, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x22, 0x14, 0x0a, 0x12, 0x53, 0x75, 0x62, 0x6d,
+	0x69, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x31,
+	0x0a, 0x11, 0x47, 0x65, 0x74, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x49, 0x44,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x49,
+	0x44, 0x22, 0xcf, 0x02, 0x0a, 0x07, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x12, 0x0e, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x1e, 0x0a,
+	0x0a, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x52, 0x6f, 0x6f, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x0a, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x52, 0x6f, 0x6f, 0x74, 0x12, 0x1e, 0x0a,
+	0x0a, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0a, 0x63, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x20, 0x0a,
+	0x0b, 0x65, 0x74, 0x68, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x4e, 0x75, 0x6d, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x0b, 0x65, 0x74, 0x68, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x4e, 0x75, 0x6d, 0x12,
+	0x2a, 0x0a, 0x10, 0x72, 0x65, 0x73, 0x50, 0x75, 0x62, 0x53, 0x74, 0x61, 0x72, 0x74, 0x42, 0x6c,
+	0x6f, 0x63, 0x6b, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x10, 0x72, 0x65, 0x73, 0x50, 0x75,
+	0x62, 0x53, 0x74, 0x61, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x22, 0x0a, 0x0c, 0x72,
+	0x65, 0x73, 0x50, 0x75, 0x62, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0c, 0x72, 0x65, 0x73, 0x50, 0x75, 0x62, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x12,
+	0x2a, 0x0a, 0x10, 0x6d, 0x69, 0x6e, 0x50, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x10, 0x6d, 0x69, 0x6e, 0x50, 0x61,
+	0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2a, 0x0a, 0x10, 0x6d,
+	0x69, 0x6e, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x76, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x73, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x10, 0x6d, 0x69, 0x6e, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69,
+	0x76, 0x65, 0x56, 0x6f, 0x74, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x73, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x22, 0x2f, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73,
+	0x73, 0x49, 0x44, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x63, 0x65,
+	0x73, 0x73, 0x49, 0x44, 0x32, 0xc7, 0x02, 0x0a, 0x06, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x12,
+	0x47, 0x0a, 0x0c, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x12,
+	0x1a, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x65,
+	0x6e, 0x73, 0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x6f, 0x76,
+	0x6f, 0x74, 0x65, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3a, 0x0a, 0x07, 0x41, 0x64, 0x64, 0x4b,
+	0x65, 0x79, 0x73, 0x12, 0x15, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x41, 0x64, 0x64, 0x4b,
+	0x65, 0x79, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x6f, 0x76, 0x6f,
+	0x74, 0x65, 0x2e, 0x41, 0x64, 0x64, 0x4b, 0x65, 0x79, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x28, 0x01, 0x12, 0x44, 0x0a, 0x0b, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x43, 0x65, 0x6e,
+	0x73, 0x75, 0x73, 0x12, 0x19, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x43, 0x6c, 0x6f, 0x73,
+	0x65, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a,
+	0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x43, 0x65, 0x6e, 0x73,
+	0x75, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x36, 0x0a, 0x08, 0x47, 0x65,
+	0x74, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x12, 0x16, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x47,
+	0x65, 0x74, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12,
+	0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x50, 0x72, 0x6f,
+	0x6f, 0x66, 0x12, 0x3a, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x73, 0x12,
+	0x17, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x72, 0x6f, 0x6f, 0x66,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65,
+	0x2e, 0x43, 0x65, 0x6e, 0x73, 0x75, 0x73, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x30, 0x01, 0x32, 0xbc,
+	0x01, 0x0a, 0x05, 0x56, 0x6f, 0x74, 0x65, 0x73, 0x12, 0x41, 0x0a, 0x0a, 0x53, 0x75, 0x62, 0x6d,
+	0x69, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x12, 0x18, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x53,
+	0x75, 0x62, 0x6d, 0x69, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x19, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x53, 0x75, 0x62, 0x6d, 0x69, 0x74, 0x56,
+	0x6f, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x36, 0x0a, 0x0a, 0x47,
+	0x65, 0x74, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x12, 0x18, 0x2e, 0x6f, 0x76, 0x6f, 0x74,
+	0x65, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x50, 0x72, 0x6f, 0x63,
+	0x65, 0x73, 0x73, 0x12, 0x38, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x73, 0x12,
+	0x16, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x56, 0x6f, 0x74, 0x65, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2e,
+	0x56, 0x6f, 0x74, 0x65, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x30, 0x01, 0x42, 0x26, 0x5a,
+	0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x61, 0x72, 0x61, 0x67,
+	0x6f, 0x6e, 0x2f, 0x6f, 0x76, 0x6f, 0x74, 0x65, 0x2d, 0x6e, 0x6f, 0x64, 0x65, 0x2f, 0x67, 0x72,
+	0x70, 0x63, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_ovote_proto_rawDescOnce sync.Once
+	file_ovote_proto_rawDescData = file_ovote_proto_rawDesc
+)
+
+func file_ovote_proto_rawDescGZIP() []byte {
+	file_ovote_proto_rawDescOnce.Do(func() {
+		file_ovote_proto_rawDescData = protoimpl.X.CompressGZIP(file_ovote_proto_rawDescData)
+	})
+	return file_ovote_proto_rawDescData
+}
+
+var file_ovote_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_ovote_proto_goTypes = []interface{}{
+	(*CreateCensusRequest)(nil),  // 0: ovote.CreateCensusRequest
+	(*CreateCensusResponse)(nil), // 1: ovote.CreateCensusResponse
+	(*AddKeysRequest)(nil),       // 2: ovote.AddKeysRequest
+	(*AddKeysResponse)(nil),      // 3: ovote.AddKeysResponse
+	(*CloseCensusRequest)(nil),   // 4: ovote.CloseCensusRequest
+	(*CloseCensusResponse)(nil),  // 5: ovote.CloseCensusResponse
+	(*GetProofRequest)(nil),      // 6: ovote.GetProofRequest
+	(*GetProofsRequest)(nil),     // 7: ovote.GetProofsRequest
+	(*CensusProof)(nil),          // 8: ovote.CensusProof
+	(*VotePackage)(nil),          // 9: ovote.VotePackage
+	(*SubmitVoteRequest)(nil),    // 10: ovote.SubmitVoteRequest
+	(*SubmitVoteResponse)(nil),   // 11: ovote.SubmitVoteResponse
+	(*GetProcessRequest)(nil),    // 12: ovote.GetProcessRequest
+	(*Process)(nil),              // 13: ovote.Process
+	(*GetVotesRequest)(nil),      // 14: ovote.GetVotesRequest
+}
+var file_ovote_proto_depIdxs = []int32{
+	8,  // 0: ovote.VotePackage.censusProof:type_name -> ovote.CensusProof
+	9,  // 1: ovote.SubmitVoteRequest.votePackage:type_name -> ovote.VotePackage
+	0,  // 2: ovote.Census.CreateCensus:input_type -> ovote.CreateCensusRequest
+	2,  // 3: ovote.Census.AddKeys:input_type -> ovote.AddKeysRequest
+	4,  // 4: ovote.Census.CloseCensus:input_type -> ovote.CloseCensusRequest
+	6,  // 5: ovote.Census.GetProof:input_type -> ovote.GetProofRequest
+	7,  // 6: ovote.Census.GetProofs:input_type -> ovote.GetProofsRequest
+	10, // 7: ovote.Votes.SubmitVote:input_type -> ovote.SubmitVoteRequest
+	12, // 8: ovote.Votes.GetProcess:input_type -> ovote.GetProcessRequest
+	14, // 9: ovote.Votes.GetVotes:input_type -> ovote.GetVotesRequest
+	1,  // 10: ovote.Census.CreateCensus:output_type -> ovote.CreateCensusResponse
+	3,  // 11: ovote.Census.AddKeys:output_type -> ovote.AddKeysResponse
+	5,  // 12: ovote.Census.CloseCensus:output_type -> ovote.CloseCensusResponse
+	8,  // 13: ovote.Census.GetProof:output_type -> ovote.CensusProof
+	8,  // 14: ovote.Census.GetProofs:output_type -> ovote.CensusProof
+	11, // 15: ovote.Votes.SubmitVote:output_type -> ovote.SubmitVoteResponse
+	13, // 16: ovote.Votes.GetProcess:output_type -> ovote.Process
+	9,  // 17: ovote.Votes.GetVotes:output_type -> ovote.VotePackage
+	10, // [10:18] is the sub-list for method output_type
+	2,  // [2:10] is the sub-list for method input_type
+	2,  // [2:2] is the sub-list for extension type_name
+	2,  // [2:2] is the sub-list for extension extendee
+	0,  // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_ovote_proto_init() }
+func file_ovote_proto_init() {
+	if File_ovote_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_ovote_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateCensusRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateCensusResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AddKeysRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AddKeysResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CloseCensusRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CloseCensusResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetProofRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetProofsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CensusProof); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*VotePackage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SubmitVoteRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SubmitVoteResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetProcessRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Process); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ovote_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetVotesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_ovote_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   15,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_ovote_proto_goTypes,
+		DependencyIndexes: file_ovote_proto_depIdxs,
+		MessageInfos:      file_ovote_proto_msgTypes,
+	}.Build()
+	File_ovote_proto = out.File
+	file_ovote_proto_rawDesc = nil
+	file_ovote_proto_goTypes = nil
+	file_ovote_proto_depIdxs = nil
+}
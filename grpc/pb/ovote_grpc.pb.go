@@ -0,0 +1,517 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: ovote.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// CensusClient is the client API for Census service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CensusClient interface {
+	// CreateCensus creates a new Census, returning its ID
+	CreateCensus(ctx context.Context, in *CreateCensusRequest, opts ...grpc.CallOption) (*CreateCensusResponse, error)
+	// AddKeys adds the PublicKeys received in batches to a Census. The
+	// CensusID is taken from the first batch.
+	AddKeys(ctx context.Context, opts ...grpc.CallOption) (Census_AddKeysClient, error)
+	// CloseCensus closes a Census, returning its CensusRoot
+	CloseCensus(ctx context.Context, in *CloseCensusRequest, opts ...grpc.CallOption) (*CloseCensusResponse, error)
+	// GetProof returns the CensusProof of a PublicKey
+	GetProof(ctx context.Context, in *GetProofRequest, opts ...grpc.CallOption) (*CensusProof, error)
+	// GetProofs streams the CensusProofs of a batch of PublicKeys, in the
+	// same order
+	GetProofs(ctx context.Context, in *GetProofsRequest, opts ...grpc.CallOption) (Census_GetProofsClient, error)
+}
+
+type censusClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCensusClient(cc grpc.ClientConnInterface) CensusClient {
+	return &censusClient{cc}
+}
+
+func (c *censusClient) CreateCensus(ctx context.Context, in *CreateCensusRequest, opts ...grpc.CallOption) (*CreateCensusResponse, error) {
+	out := new(CreateCensusResponse)
+	err := c.cc.Invoke(ctx, "/ovote.Census/CreateCensus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *censusClient) AddKeys(ctx context.Context, opts ...grpc.CallOption) (Census_AddKeysClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Census_ServiceDesc.Streams[0], "/ovote.Census/AddKeys", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &censusAddKeysClient{stream}
+	return x, nil
+}
+
+type Census_AddKeysClient interface {
+	Send(*AddKeysRequest) error
+	CloseAndRecv() (*AddKeysResponse, error)
+	grpc.ClientStream
+}
+
+type censusAddKeysClient struct {
+	grpc.ClientStream
+}
+
+func (x *censusAddKeysClient) Send(m *AddKeysRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *censusAddKeysClient) CloseAndRecv() (*AddKeysResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(AddKeysResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *censusClient) CloseCensus(ctx context.Context, in *CloseCensusRequest, opts ...grpc.CallOption) (*CloseCensusResponse, error) {
+	out := new(CloseCensusResponse)
+	err := c.cc.Invoke(ctx, "/ovote.Census/CloseCensus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *censusClient) GetProof(ctx context.Context, in *GetProofRequest, opts ...grpc.CallOption) (*CensusProof, error) {
+	out := new(CensusProof)
+	err := c.cc.Invoke(ctx, "/ovote.Census/GetProof", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *censusClient) GetProofs(ctx context.Context, in *GetProofsRequest, opts ...grpc.CallOption) (Census_GetProofsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Census_ServiceDesc.Streams[1], "/ovote.Census/GetProofs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &censusGetProofsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Census_GetProofsClient interface {
+	Recv() (*CensusProof, error)
+	grpc.ClientStream
+}
+
+type censusGetProofsClient struct {
+	grpc.ClientStream
+}
+
+func (x *censusGetProofsClient) Recv() (*CensusProof, error) {
+	m := new(CensusProof)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CensusServer is the server API for Census service.
+// All implementations must embed UnimplementedCensusServer
+// for forward compatibility
+type CensusServer interface {
+	// CreateCensus creates a new Census, returning its ID
+	CreateCensus(context.Context, *CreateCensusRequest) (*CreateCensusResponse, error)
+	// AddKeys adds the PublicKeys received in batches to a Census. The
+	// CensusID is taken from the first batch.
+	AddKeys(Census_AddKeysServer) error
+	// CloseCensus closes a Census, returning its CensusRoot
+	CloseCensus(context.Context, *CloseCensusRequest) (*CloseCensusResponse, error)
+	// GetProof returns the CensusProof of a PublicKey
+	GetProof(context.Context, *GetProofRequest) (*CensusProof, error)
+	// GetProofs streams the CensusProofs of a batch of PublicKeys, in the
+	// same order
+	GetProofs(*GetProofsRequest, Census_GetProofsServer) error
+	mustEmbedUnimplementedCensusServer()
+}
+
+// UnimplementedCensusServer must be embedded to have forward compatible implementations.
+type UnimplementedCensusServer struct {
+}
+
+func (UnimplementedCensusServer) CreateCensus(context.Context, *CreateCensusRequest) (*CreateCensusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCensus not implemented")
+}
+func (UnimplementedCensusServer) AddKeys(Census_AddKeysServer) error {
+	return status.Errorf(codes.Unimplemented, "method AddKeys not implemented")
+}
+func (UnimplementedCensusServer) CloseCensus(context.Context, *CloseCensusRequest) (*CloseCensusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CloseCensus not implemented")
+}
+func (UnimplementedCensusServer) GetProof(context.Context, *GetProofRequest) (*CensusProof, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProof not implemented")
+}
+func (UnimplementedCensusServer) GetProofs(*GetProofsRequest, Census_GetProofsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetProofs not implemented")
+}
+func (UnimplementedCensusServer) mustEmbedUnimplementedCensusServer() {}
+
+// UnsafeCensusServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CensusServer will
+// result in compilation errors.
+type UnsafeCensusServer interface {
+	mustEmbedUnimplementedCensusServer()
+}
+
+func RegisterCensusServer(s grpc.ServiceRegistrar, srv CensusServer) {
+	s.RegisterService(&Census_ServiceDesc, srv)
+}
+
+func _Census_CreateCensus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCensusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CensusServer).CreateCensus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ovote.Census/CreateCensus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CensusServer).CreateCensus(ctx, req.(*CreateCensusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Census_AddKeys_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CensusServer).AddKeys(&censusAddKeysServer{stream})
+}
+
+type Census_AddKeysServer interface {
+	SendAndClose(*AddKeysResponse) error
+	Recv() (*AddKeysRequest, error)
+	grpc.ServerStream
+}
+
+type censusAddKeysServer struct {
+	grpc.ServerStream
+}
+
+func (x *censusAddKeysServer) SendAndClose(m *AddKeysResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *censusAddKeysServer) Recv() (*AddKeysRequest, error) {
+	m := new(AddKeysRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Census_CloseCensus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CloseCensusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CensusServer).CloseCensus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ovote.Census/CloseCensus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CensusServer).CloseCensus(ctx, req.(*CloseCensusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Census_GetProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CensusServer).GetProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ovote.Census/GetProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CensusServer).GetProof(ctx, req.(*GetProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Census_GetProofs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetProofsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CensusServer).GetProofs(m, &censusGetProofsServer{stream})
+}
+
+type Census_GetProofsServer interface {
+	Send(*CensusProof) error
+	grpc.ServerStream
+}
+
+type censusGetProofsServer struct {
+	grpc.ServerStream
+}
+
+func (x *censusGetProofsServer) Send(m *CensusProof) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Census_ServiceDesc is the grpc.ServiceDesc for Census service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Census_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ovote.Census",
+	HandlerType: (*CensusServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateCensus",
+			Handler:    _Census_CreateCensus_Handler,
+		},
+		{
+			MethodName: "CloseCensus",
+			Handler:    _Census_CloseCensus_Handler,
+		},
+		{
+			MethodName: "GetProof",
+			Handler:    _Census_GetProof_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AddKeys",
+			Handler:       _Census_AddKeys_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetProofs",
+			Handler:       _Census_GetProofs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "ovote.proto",
+}
+
+// VotesClient is the client API for Votes service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VotesClient interface {
+	// SubmitVote stores a VotePackage for a process
+	SubmitVote(ctx context.Context, in *SubmitVoteRequest, opts ...grpc.CallOption) (*SubmitVoteResponse, error)
+	// GetProcess returns the info of a process
+	GetProcess(ctx context.Context, in *GetProcessRequest, opts ...grpc.CallOption) (*Process, error)
+	// GetVotes streams the stored VotePackages of a process
+	GetVotes(ctx context.Context, in *GetVotesRequest, opts ...grpc.CallOption) (Votes_GetVotesClient, error)
+}
+
+type votesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVotesClient(cc grpc.ClientConnInterface) VotesClient {
+	return &votesClient{cc}
+}
+
+func (c *votesClient) SubmitVote(ctx context.Context, in *SubmitVoteRequest, opts ...grpc.CallOption) (*SubmitVoteResponse, error) {
+	out := new(SubmitVoteResponse)
+	err := c.cc.Invoke(ctx, "/ovote.Votes/SubmitVote", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *votesClient) GetProcess(ctx context.Context, in *GetProcessRequest, opts ...grpc.CallOption) (*Process, error) {
+	out := new(Process)
+	err := c.cc.Invoke(ctx, "/ovote.Votes/GetProcess", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *votesClient) GetVotes(ctx context.Context, in *GetVotesRequest, opts ...grpc.CallOption) (Votes_GetVotesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Votes_ServiceDesc.Streams[0], "/ovote.Votes/GetVotes", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &votesGetVotesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Votes_GetVotesClient interface {
+	Recv() (*VotePackage, error)
+	grpc.ClientStream
+}
+
+type votesGetVotesClient struct {
+	grpc.ClientStream
+}
+
+func (x *votesGetVotesClient) Recv() (*VotePackage, error) {
+	m := new(VotePackage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// VotesServer is the server API for Votes service.
+// All implementations must embed UnimplementedVotesServer
+// for forward compatibility
+type VotesServer interface {
+	// SubmitVote stores a VotePackage for a process
+	SubmitVote(context.Context, *SubmitVoteRequest) (*SubmitVoteResponse, error)
+	// GetProcess returns the info of a process
+	GetProcess(context.Context, *GetProcessRequest) (*Process, error)
+	// GetVotes streams the stored VotePackages of a process
+	GetVotes(*GetVotesRequest, Votes_GetVotesServer) error
+	mustEmbedUnimplementedVotesServer()
+}
+
+// UnimplementedVotesServer must be embedded to have forward compatible implementations.
+type UnimplementedVotesServer struct {
+}
+
+func (UnimplementedVotesServer) SubmitVote(context.Context, *SubmitVoteRequest) (*SubmitVoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitVote not implemented")
+}
+func (UnimplementedVotesServer) GetProcess(context.Context, *GetProcessRequest) (*Process, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProcess not implemented")
+}
+func (UnimplementedVotesServer) GetVotes(*GetVotesRequest, Votes_GetVotesServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetVotes not implemented")
+}
+func (UnimplementedVotesServer) mustEmbedUnimplementedVotesServer() {}
+
+// UnsafeVotesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VotesServer will
+// result in compilation errors.
+type UnsafeVotesServer interface {
+	mustEmbedUnimplementedVotesServer()
+}
+
+func RegisterVotesServer(s grpc.ServiceRegistrar, srv VotesServer) {
+	s.RegisterService(&Votes_ServiceDesc, srv)
+}
+
+func _Votes_SubmitVote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitVoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VotesServer).SubmitVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ovote.Votes/SubmitVote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VotesServer).SubmitVote(ctx, req.(*SubmitVoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Votes_GetProcess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VotesServer).GetProcess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ovote.Votes/GetProcess",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VotesServer).GetProcess(ctx, req.(*GetProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Votes_GetVotes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetVotesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VotesServer).GetVotes(m, &votesGetVotesServer{stream})
+}
+
+type Votes_GetVotesServer interface {
+	Send(*VotePackage) error
+	grpc.ServerStream
+}
+
+type votesGetVotesServer struct {
+	grpc.ServerStream
+}
+
+func (x *votesGetVotesServer) Send(m *VotePackage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Votes_ServiceDesc is the grpc.ServiceDesc for Votes service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Votes_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ovote.Votes",
+	HandlerType: (*VotesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitVote",
+			Handler:    _Votes_SubmitVote_Handler,
+		},
+		{
+			MethodName: "GetProcess",
+			Handler:    _Votes_GetProcess_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetVotes",
+			Handler:       _Votes_GetVotes_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "ovote.proto",
+}
@@ -0,0 +1,302 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/aragon/ovote-node/census"
+	"github.com/aragon/ovote-node/censusbuilder"
+	"github.com/aragon/ovote-node/db"
+	"github.com/aragon/ovote-node/grpc/pb"
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	_ pb.CensusServer = (*Service)(nil)
+	_ pb.VotesServer  = (*Service)(nil)
+)
+
+// Register registers the Service as the Census and the Votes services of the
+// given gRPC server
+func (s *Service) Register(server grpclib.ServiceRegistrar) {
+	pb.RegisterCensusServer(server, s)
+	pb.RegisterVotesServer(server, s)
+}
+
+// statusError returns the given error of a RPC as a gRPC status error, with
+// the code that classifies it. As in the REST API, the errors that are not
+// identified are considered caused by the request, and returned as
+// codes.InvalidArgument.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	// the errors that already are status errors, also when wrapped, keep
+	// their code
+	var st interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &st) {
+		return status.Error(st.GRPCStatus().Code(), err.Error())
+	}
+	switch {
+	case errors.Is(err, censusbuilder.ErrCensusNotFound),
+		errors.Is(err, censusbuilder.ErrCensusDeleted),
+		errors.Is(err, census.ErrPublicKeyNotFound),
+		errors.Is(err, db.ErrProcessNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, db.ErrVoteAlreadyExists), errors.Is(err, db.ErrNullifierUsed):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, census.ErrCensusClosed),
+		errors.Is(err, census.ErrCensusNotClosed),
+		errors.Is(err, census.ErrCensusSealed),
+		errors.Is(err, census.ErrVotingClosed),
+		errors.Is(err, censusbuilder.ErrCensusArchived):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, db.ErrRateLimited):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, ErrServiceNotActive):
+		return status.Error(codes.Unimplemented, err.Error())
+	default:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+// decodePublicKey returns the babyjub PublicKey of the given compressed bytes
+func decodePublicKey(b []byte) (babyjub.PublicKey, error) {
+	var pubKComp babyjub.PublicKeyComp
+	if len(b) != len(pubKComp) {
+		return babyjub.PublicKey{}, status.Errorf(codes.InvalidArgument,
+			"invalid PublicKey length: %d, expected: %d", len(b), len(pubKComp))
+	}
+	copy(pubKComp[:], b)
+	pubK, err := pubKComp.Decompress()
+	if err != nil {
+		return babyjub.PublicKey{}, status.Errorf(codes.InvalidArgument,
+			"can not decompress the PublicKey: %s", err)
+	}
+	return *pubK, nil
+}
+
+func decodePublicKeys(bs [][]byte) ([]babyjub.PublicKey, error) {
+	pubKs := make([]babyjub.PublicKey, len(bs))
+	for i := 0; i < len(bs); i++ {
+		pubK, err := decodePublicKey(bs[i])
+		if err != nil {
+			return nil, fmt.Errorf("PublicKey %d: %w", i, err)
+		}
+		pubKs[i] = pubK
+	}
+	return pubKs, nil
+}
+
+func encodeCensusProof(cp *types.CensusProof) *pb.CensusProof {
+	proof := &pb.CensusProof{
+		Index:       cp.Index,
+		MerkleProof: cp.MerkleProof,
+	}
+	if cp.PublicKey != nil {
+		pubKComp := cp.PublicKey.Compress()
+		proof.PublicKey = pubKComp[:]
+	}
+	if cp.Weight != nil {
+		proof.Weight = cp.Weight.Bytes()
+	}
+	return proof
+}
+
+func decodeCensusProof(proof *pb.CensusProof) (types.CensusProof, error) {
+	if proof == nil {
+		return types.CensusProof{}, status.Error(codes.InvalidArgument,
+			"VotePackage without CensusProof")
+	}
+	pubK, err := decodePublicKey(proof.PublicKey)
+	if err != nil {
+		return types.CensusProof{}, err
+	}
+	return types.CensusProof{
+		Index:       proof.Index,
+		PublicKey:   &pubK,
+		Weight:      new(big.Int).SetBytes(proof.Weight),
+		MerkleProof: proof.MerkleProof,
+	}, nil
+}
+
+func encodeVotePackage(vote *types.VotePackage) *pb.VotePackage {
+	return &pb.VotePackage{
+		Signature:       vote.Signature,
+		SignatureScheme: uint32(vote.SignatureScheme),
+		CensusProof:     encodeCensusProof(&vote.CensusProof),
+		Vote:            vote.Vote,
+		Nullifier:       vote.Nullifier,
+	}
+}
+
+func decodeVotePackage(vote *pb.VotePackage) (types.VotePackage, error) {
+	if vote == nil {
+		return types.VotePackage{}, status.Error(codes.InvalidArgument,
+			"request without VotePackage")
+	}
+	cp, err := decodeCensusProof(vote.CensusProof)
+	if err != nil {
+		return types.VotePackage{}, err
+	}
+	return types.VotePackage{
+		Signature:       vote.Signature,
+		SignatureScheme: types.SignatureScheme(vote.SignatureScheme),
+		CensusProof:     cp,
+		Vote:            vote.Vote,
+		Nullifier:       vote.Nullifier,
+	}, nil
+}
+
+// CreateCensus implements the pb.CensusServer.CreateCensus RPC
+func (s *Service) CreateCensus(_ context.Context, req *pb.CreateCensusRequest) (
+	*pb.CreateCensusResponse, error) {
+	censusID, err := s.createCensus(req.SortKeys)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.CreateCensusResponse{CensusID: uint64(censusID)}, nil
+}
+
+// addKeysServer converts the messages of a pb.Census_AddKeysServer into
+// keysBatch
+type addKeysServer struct {
+	stream pb.Census_AddKeysServer
+}
+
+// Recv implements the keysStream.Recv interface method
+func (a *addKeysServer) Recv() (*keysBatch, error) {
+	req, err := a.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Weights) != len(req.PublicKeys) {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"%d weights for %d PublicKeys", len(req.Weights), len(req.PublicKeys))
+	}
+	pubKs, err := decodePublicKeys(req.PublicKeys)
+	if err != nil {
+		return nil, err
+	}
+	weights := make([]*big.Int, len(req.Weights))
+	for i := 0; i < len(req.Weights); i++ {
+		weights[i] = new(big.Int).SetBytes(req.Weights[i])
+	}
+	return &keysBatch{
+		CensusID:   types.CensusID(req.CensusID),
+		PublicKeys: pubKs,
+		Weights:    weights,
+	}, nil
+}
+
+// AddKeys implements the pb.CensusServer.AddKeys RPC
+func (s *Service) AddKeys(stream pb.Census_AddKeysServer) error {
+	nKeys, err := s.addKeys(&addKeysServer{stream: stream})
+	if err != nil {
+		return statusError(err)
+	}
+	return stream.SendAndClose(&pb.AddKeysResponse{NKeys: nKeys})
+}
+
+// CloseCensus implements the pb.CensusServer.CloseCensus RPC
+func (s *Service) CloseCensus(_ context.Context, req *pb.CloseCensusRequest) (
+	*pb.CloseCensusResponse, error) {
+	root, err := s.closeCensus(types.CensusID(req.CensusID))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.CloseCensusResponse{CensusRoot: root}, nil
+}
+
+// GetProof implements the pb.CensusServer.GetProof RPC
+func (s *Service) GetProof(_ context.Context, req *pb.GetProofRequest) (
+	*pb.CensusProof, error) {
+	pubK, err := decodePublicKey(req.PublicKey)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	proof, err := s.closedCensusProof(types.CensusID(req.CensusID), pubK)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return encodeCensusProof(proof), nil
+}
+
+// getProofsServer converts the CensusProofs sent through a
+// pb.Census_GetProofsServer
+type getProofsServer struct {
+	stream pb.Census_GetProofsServer
+}
+
+// Send implements the proofsStream.Send interface method
+func (g *getProofsServer) Send(proof *types.CensusProof) error {
+	return g.stream.Send(encodeCensusProof(proof))
+}
+
+// GetProofs implements the pb.CensusServer.GetProofs RPC
+func (s *Service) GetProofs(req *pb.GetProofsRequest,
+	stream pb.Census_GetProofsServer) error {
+	pubKs, err := decodePublicKeys(req.PublicKeys)
+	if err != nil {
+		return statusError(err)
+	}
+	return statusError(s.getProofs(types.CensusID(req.CensusID), pubKs,
+		&getProofsServer{stream: stream}))
+}
+
+// SubmitVote implements the pb.VotesServer.SubmitVote RPC
+func (s *Service) SubmitVote(_ context.Context, req *pb.SubmitVoteRequest) (
+	*pb.SubmitVoteResponse, error) {
+	vote, err := decodeVotePackage(req.VotePackage)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if err := s.submitVote(req.ProcessID, vote); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.SubmitVoteResponse{}, nil
+}
+
+// GetProcess implements the pb.VotesServer.GetProcess RPC
+func (s *Service) GetProcess(_ context.Context, req *pb.GetProcessRequest) (
+	*pb.Process, error) {
+	process, err := s.getProcess(req.ProcessID)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.Process{
+		Id:               process.ID,
+		CensusRoot:       process.CensusRoot,
+		CensusSize:       process.CensusSize,
+		EthBlockNum:      process.EthBlockNum,
+		ResPubStartBlock: process.ResPubStartBlock,
+		ResPubWindow:     process.ResPubWindow,
+		MinParticipation: uint32(process.MinParticipation),
+		MinPositiveVotes: uint32(process.MinPositiveVotes),
+		Type:             uint32(process.Type),
+		Status:           uint32(process.Status),
+	}, nil
+}
+
+// getVotesServer converts the VotePackages sent through a
+// pb.Votes_GetVotesServer
+type getVotesServer struct {
+	stream pb.Votes_GetVotesServer
+}
+
+// Send implements the votesStream.Send interface method
+func (g *getVotesServer) Send(vote *types.VotePackage) error {
+	return g.stream.Send(encodeVotePackage(vote))
+}
+
+// GetVotes implements the pb.VotesServer.GetVotes RPC
+func (s *Service) GetVotes(req *pb.GetVotesRequest,
+	stream pb.Votes_GetVotesServer) error {
+	return statusError(s.getVotes(req.ProcessID, &getVotesServer{stream: stream}))
+}
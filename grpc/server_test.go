@@ -0,0 +1,255 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/aragon/ovote-node/grpc/pb"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/vocdoni/arbo"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestConn serves the given Service through an in-memory listener,
+// returning a client connection to it
+func newTestConn(c *qt.C, s *Service) *grpclib.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpclib.NewServer()
+	s.Register(server)
+	go server.Serve(lis) //nolint:errcheck
+	c.Cleanup(server.Stop)
+	conn, err := grpclib.DialContext(context.Background(), "bufnet",
+		grpclib.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	c.Assert(err, qt.IsNil)
+	c.Cleanup(func() { conn.Close() }) //nolint:errcheck
+	return conn
+}
+
+func TestServer(t *testing.T) {
+	c := qt.New(t)
+
+	chainID := uint64(3)
+	processID := uint64(123)
+	s, sqlite := newTestService(c, chainID)
+	conn := newTestConn(c, s)
+	ctx := context.Background()
+	censusClient := pb.NewCensusClient(conn)
+	votesClient := pb.NewVotesClient(conn)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+	pubKsBytes := make([][]byte, nKeys)
+	weightsBytes := make([][]byte, nKeys)
+	for i := 0; i < nKeys; i++ {
+		pubKComp := keys.PublicKeys[i].Compress()
+		pubKsBytes[i] = pubKComp[:]
+		weightsBytes[i] = keys.Weights[i].Bytes()
+	}
+
+	created, err := censusClient.CreateCensus(ctx, &pb.CreateCensusRequest{})
+	c.Assert(err, qt.IsNil)
+	censusID := created.CensusID
+
+	// add the keys in two batches
+	addStream, err := censusClient.AddKeys(ctx)
+	c.Assert(err, qt.IsNil)
+	half := nKeys / 2
+	c.Assert(addStream.Send(&pb.AddKeysRequest{CensusID: censusID,
+		PublicKeys: pubKsBytes[:half], Weights: weightsBytes[:half]}), qt.IsNil)
+	c.Assert(addStream.Send(&pb.AddKeysRequest{CensusID: censusID,
+		PublicKeys: pubKsBytes[half:], Weights: weightsBytes[half:]}), qt.IsNil)
+	added, err := addStream.CloseAndRecv()
+	c.Assert(err, qt.IsNil)
+	c.Assert(added.NKeys, qt.Equals, uint64(nKeys))
+
+	// a malformed PublicKey is rejected as an invalid argument
+	_, err = censusClient.GetProof(ctx, &pb.GetProofRequest{CensusID: censusID,
+		PublicKey: []byte{1, 2, 3}})
+	c.Assert(status.Code(err), qt.Equals, codes.InvalidArgument)
+
+	closed, err := censusClient.CloseCensus(ctx,
+		&pb.CloseCensusRequest{CensusID: censusID})
+	c.Assert(err, qt.IsNil)
+	root := closed.CensusRoot
+
+	proof, err := censusClient.GetProof(ctx, &pb.GetProofRequest{
+		CensusID: censusID, PublicKey: pubKsBytes[0]})
+	c.Assert(err, qt.IsNil)
+	c.Assert(proof.PublicKey, qt.DeepEquals, pubKsBytes[0])
+	cp, err := decodeCensusProof(proof)
+	c.Assert(err, qt.IsNil)
+	c.Assert(cp.Verify(root), qt.IsNil)
+
+	proofsStream, err := censusClient.GetProofs(ctx, &pb.GetProofsRequest{
+		CensusID: censusID, PublicKeys: pubKsBytes})
+	c.Assert(err, qt.IsNil)
+	var proofs []types.CensusProof
+	for {
+		proof, err := proofsStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, qt.IsNil)
+		cp, err := decodeCensusProof(proof)
+		c.Assert(err, qt.IsNil)
+		c.Assert(cp.Weight.Cmp(keys.Weights[len(proofs)]), qt.Equals, 0)
+		c.Assert(cp.Verify(root), qt.IsNil)
+		proofs = append(proofs, cp)
+	}
+	c.Assert(len(proofs), qt.Equals, nKeys)
+
+	// simulate the SmartContract Process creation
+	err = sqlite.StoreProcess(processID, root, uint64(nKeys), 10, 20, 20,
+		20, 60, 1)
+	c.Assert(err, qt.IsNil)
+	process, err := votesClient.GetProcess(ctx,
+		&pb.GetProcessRequest{ProcessID: processID})
+	c.Assert(err, qt.IsNil)
+	c.Assert(process.Id, qt.Equals, processID)
+	c.Assert(process.CensusRoot, qt.DeepEquals, root)
+	c.Assert(process.CensusSize, qt.Equals, uint64(nKeys))
+
+	l := arbo.HashFunctionPoseidon.Len()
+	voteBytes := arbo.BigIntToBytes(l, big.NewInt(1))
+	msg, err := types.HashVote(chainID, processID, voteBytes)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < nKeys; i++ {
+		vote := types.VotePackage{
+			Signature:   types.CompressSignature(keys.PrivateKeys[i].SignPoseidon(msg)),
+			CensusProof: proofs[i],
+			Vote:        voteBytes,
+		}
+		_, err = votesClient.SubmitVote(ctx, &pb.SubmitVoteRequest{
+			ProcessID: processID, VotePackage: encodeVotePackage(&vote)})
+		c.Assert(err, qt.IsNil)
+	}
+	// a vote without CensusProof
+	_, err = votesClient.SubmitVote(ctx, &pb.SubmitVoteRequest{
+		ProcessID: processID, VotePackage: &pb.VotePackage{}})
+	c.Assert(status.Code(err), qt.Equals, codes.InvalidArgument)
+
+	votesStream, err := votesClient.GetVotes(ctx,
+		&pb.GetVotesRequest{ProcessID: processID})
+	c.Assert(err, qt.IsNil)
+	var votes []types.VotePackage
+	for {
+		vote, err := votesStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, qt.IsNil)
+		vp, err := decodeVotePackage(vote)
+		c.Assert(err, qt.IsNil)
+		votes = append(votes, vp)
+	}
+	c.Assert(len(votes), qt.Equals, nKeys)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(votes[i].CensusProof.Index, qt.Equals, proofs[i].Index)
+		c.Assert(votes[i].Verify(chainID, processID, root), qt.IsNil)
+	}
+}
+
+func TestServerErrorCodes(t *testing.T) {
+	c := qt.New(t)
+
+	chainID := uint64(3)
+	processID := uint64(123)
+	s, sqlite := newTestService(c, chainID)
+	conn := newTestConn(c, s)
+	ctx := context.Background()
+	censusClient := pb.NewCensusClient(conn)
+	votesClient := pb.NewVotesClient(conn)
+
+	keys := test.GenUserKeys(2)
+	pubKComp := keys.PublicKeys[0].Compress()
+	addKeys := func(censusID uint64) error {
+		stream, err := censusClient.AddKeys(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stream.Send(&pb.AddKeysRequest{CensusID: censusID,
+			PublicKeys: [][]byte{pubKComp[:]},
+			Weights:    [][]byte{keys.Weights[0].Bytes()}}), qt.IsNil)
+		_, err = stream.CloseAndRecv()
+		return err
+	}
+
+	// the Census does not exist
+	_, err := censusClient.CloseCensus(ctx, &pb.CloseCensusRequest{CensusID: 42})
+	c.Assert(status.Code(err), qt.Equals, codes.NotFound)
+	c.Assert(status.Code(addKeys(42)), qt.Equals, codes.NotFound)
+
+	created, err := censusClient.CreateCensus(ctx, &pb.CreateCensusRequest{})
+	c.Assert(err, qt.IsNil)
+	censusID := created.CensusID
+	c.Assert(addKeys(censusID), qt.IsNil)
+	// the Census is not closed yet
+	_, err = censusClient.GetProof(ctx, &pb.GetProofRequest{CensusID: censusID,
+		PublicKey: pubKComp[:]})
+	c.Assert(status.Code(err), qt.Equals, codes.FailedPrecondition)
+
+	closed, err := censusClient.CloseCensus(ctx,
+		&pb.CloseCensusRequest{CensusID: censusID})
+	c.Assert(err, qt.IsNil)
+	// the Census is closed
+	c.Assert(status.Code(addKeys(censusID)), qt.Equals, codes.FailedPrecondition)
+	// the PublicKey is not in the Census
+	otherPubKComp := keys.PublicKeys[1].Compress()
+	_, err = censusClient.GetProof(ctx, &pb.GetProofRequest{CensusID: censusID,
+		PublicKey: otherPubKComp[:]})
+	c.Assert(status.Code(err), qt.Equals, codes.NotFound)
+
+	// the Process does not exist
+	_, err = votesClient.GetProcess(ctx, &pb.GetProcessRequest{ProcessID: processID})
+	c.Assert(status.Code(err), qt.Equals, codes.NotFound)
+	proof, err := censusClient.GetProof(ctx, &pb.GetProofRequest{
+		CensusID: censusID, PublicKey: pubKComp[:]})
+	c.Assert(err, qt.IsNil)
+	l := arbo.HashFunctionPoseidon.Len()
+	voteBytes := arbo.BigIntToBytes(l, big.NewInt(1))
+	msg, err := types.HashVote(chainID, processID, voteBytes)
+	c.Assert(err, qt.IsNil)
+	vote := &pb.VotePackage{
+		Signature:   types.CompressSignature(keys.PrivateKeys[0].SignPoseidon(msg)),
+		CensusProof: proof,
+		Vote:        voteBytes,
+	}
+	_, err = votesClient.SubmitVote(ctx, &pb.SubmitVoteRequest{
+		ProcessID: processID, VotePackage: vote})
+	c.Assert(status.Code(err), qt.Equals, codes.NotFound)
+
+	err = sqlite.StoreProcess(processID, closed.CensusRoot, 1, 10, 20, 20,
+		20, 60, 1)
+	c.Assert(err, qt.IsNil)
+	// a VotePackage that does not pass the validation
+	invalidVote := &pb.VotePackage{
+		Signature:   types.CompressSignature(keys.PrivateKeys[1].SignPoseidon(msg)),
+		CensusProof: proof,
+		Vote:        voteBytes,
+	}
+	_, err = votesClient.SubmitVote(ctx, &pb.SubmitVoteRequest{
+		ProcessID: processID, VotePackage: invalidVote})
+	c.Assert(status.Code(err), qt.Equals, codes.InvalidArgument)
+	// the VotePackage is already stored
+	_, err = votesClient.SubmitVote(ctx, &pb.SubmitVoteRequest{
+		ProcessID: processID, VotePackage: vote})
+	c.Assert(err, qt.IsNil)
+	_, err = votesClient.SubmitVote(ctx, &pb.SubmitVoteRequest{
+		ProcessID: processID, VotePackage: vote})
+	c.Assert(status.Code(err), qt.Equals, codes.AlreadyExists)
+
+	// the service is not active
+	s.va = nil
+	_, err = votesClient.GetProcess(ctx, &pb.GetProcessRequest{ProcessID: processID})
+	c.Assert(status.Code(err), qt.Equals, codes.Unimplemented)
+}
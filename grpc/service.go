@@ -0,0 +1,244 @@
+// Package grpc contains the gRPC API, defined at ovote.proto. Service
+// implements the pb.CensusServer and pb.VotesServer interfaces of the stubs
+// generated at the pb package (see server.go), converting the protobuf
+// messages and calling the unexported methods that implement each RPC with
+// plain Go types and stream interfaces.
+package grpc
+
+//go:generate protoc --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative ovote.proto
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/aragon/ovote-node/censusbuilder"
+	"github.com/aragon/ovote-node/db"
+	"github.com/aragon/ovote-node/grpc/pb"
+	"github.com/aragon/ovote-node/types"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"go.vocdoni.io/dvote/log"
+)
+
+// ErrServiceNotActive is used when calling a method of a service which
+// backend (CensusBuilder or VotesAggregator) has not been set
+var ErrServiceNotActive = errors.New("service not active")
+
+// keysBatch is a batch of PublicKeys received by AddKeys
+type keysBatch struct {
+	CensusID   types.CensusID
+	PublicKeys []babyjub.PublicKey
+	Weights    []*big.Int
+}
+
+// keysStream is the client-streaming side of AddKeys, Recv returns io.EOF
+// when there are no more batches
+type keysStream interface {
+	Recv() (*keysBatch, error)
+}
+
+// proofsStream is the server-streaming side of GetProofs
+type proofsStream interface {
+	Send(*types.CensusProof) error
+}
+
+// votesStream is the server-streaming side of GetVotes
+type votesStream interface {
+	Send(*types.VotePackage) error
+}
+
+// VotesBackend contains the methods of the VotesAggregator used by the
+// Service
+type VotesBackend interface {
+	ProcessInfo(processID uint64) (*types.Process, error)
+	AddVote(processID uint64, votePackage types.VotePackage) error
+}
+
+// Service implements the census and vote operations of the gRPC API
+type Service struct {
+	pb.UnimplementedCensusServer
+	pb.UnimplementedVotesServer
+
+	cb     *censusbuilder.CensusBuilder
+	va     VotesBackend
+	sqlite *db.SQLite
+}
+
+// NewService returns a new Service backed by the given CensusBuilder, and by
+// the given VotesBackend and SQLite for the vote operations. As in the REST
+// API, at least one of the CensusBuilder or the VotesBackend is needed.
+func NewService(cb *censusbuilder.CensusBuilder, va VotesBackend,
+	sqlite *db.SQLite) (*Service, error) {
+	if cb == nil && va == nil {
+		return nil, fmt.Errorf("Can not create the Service. At least" +
+			" censusBuilder or votesAggregator should be active")
+	}
+	if va != nil && sqlite == nil {
+		return nil, fmt.Errorf("Can not create the Service without the" +
+			" SQLite of the votesAggregator")
+	}
+	return &Service{cb: cb, va: va, sqlite: sqlite}, nil
+}
+
+func (s *Service) checkCensus() error {
+	if s.cb == nil {
+		return fmt.Errorf("%w: census", ErrServiceNotActive)
+	}
+	return nil
+}
+
+func (s *Service) checkVotes() error {
+	if s.va == nil {
+		return fmt.Errorf("%w: votes", ErrServiceNotActive)
+	}
+	return nil
+}
+
+// createCensus creates a new Census, returning its CensusID
+func (s *Service) createCensus(sortKeys bool) (types.CensusID, error) {
+	if err := s.checkCensus(); err != nil {
+		return 0, err
+	}
+	return s.cb.NewCensusWithOptions(censusbuilder.CensusOptions{
+		SortKeys: sortKeys,
+	})
+}
+
+// addKeys adds the batches of PublicKeys received from the given keysStream
+// to the Census of the CensusID of the first batch, until the stream returns
+// io.EOF. Each batch is added as it is received, so a failing batch does not
+// undo the previous ones. Returns the number of PublicKeys added.
+func (s *Service) addKeys(stream keysStream) (uint64, error) {
+	if err := s.checkCensus(); err != nil {
+		return 0, err
+	}
+	var censusID types.CensusID
+	var nKeys uint64
+	for first := true; ; first = false {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			return nKeys, nil
+		} else if err != nil {
+			return nKeys, err
+		}
+		if first {
+			censusID = batch.CensusID
+		} else if batch.CensusID != censusID {
+			return nKeys, fmt.Errorf("batch for CensusID=%d in the stream"+
+				" of CensusID=%d", batch.CensusID, censusID)
+		}
+		if err := s.cb.AddPublicKeys(censusID, batch.PublicKeys,
+			batch.Weights); err != nil {
+			return nKeys, err
+		}
+		nKeys += uint64(len(batch.PublicKeys))
+		log.Debugf("[CensusID=%d] gRPC AddKeys, added %d PublicKeys",
+			censusID, nKeys)
+	}
+}
+
+// closeCensus closes the Census of the given CensusID, returning its
+// CensusRoot
+func (s *Service) closeCensus(censusID types.CensusID) ([]byte, error) {
+	if err := s.checkCensus(); err != nil {
+		return nil, err
+	}
+	if err := s.cb.CloseCensus(censusID); err != nil {
+		return nil, err
+	}
+	return s.cb.CensusRoot(censusID)
+}
+
+// closedCensusProof returns the CensusProof of the given PublicKey in the
+// closed Census of the given CensusID
+func (s *Service) closedCensusProof(censusID types.CensusID,
+	pubK babyjub.PublicKey) (*types.CensusProof, error) {
+	if err := s.checkCensus(); err != nil {
+		return nil, err
+	}
+	// check if census is closed
+	if _, err := s.cb.CensusRoot(censusID); err != nil {
+		return nil, err
+	}
+	return s.getProof(censusID, pubK)
+}
+
+// getProof returns the CensusProof of the given PublicKey, including its
+// Weight, in the closed Census of the given CensusID
+func (s *Service) getProof(censusID types.CensusID,
+	pubK babyjub.PublicKey) (*types.CensusProof, error) {
+	return s.cb.GetCensusProof(censusID, &pubK)
+}
+
+// getProofs sends through the given proofsStream the CensusProofs of the
+// given PublicKeys in the closed Census of the given CensusID, in the same
+// order. It stops at the first PublicKey that is not in the Census.
+func (s *Service) getProofs(censusID types.CensusID,
+	pubKs []babyjub.PublicKey, stream proofsStream) error {
+	if err := s.checkCensus(); err != nil {
+		return err
+	}
+	if _, err := s.cb.CensusRoot(censusID); err != nil {
+		return err
+	}
+	for i := 0; i < len(pubKs); i++ {
+		proof, err := s.getProof(censusID, pubKs[i])
+		if err != nil {
+			return fmt.Errorf("PublicKey %d: %w", i, err)
+		}
+		if err := stream.Send(proof); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// submitVote stores the given VotePackage for the given processID, with the
+// same checks as the REST API
+func (s *Service) submitVote(processID uint64, vote types.VotePackage) error {
+	if err := s.checkVotes(); err != nil {
+		return err
+	}
+	if s.cb != nil {
+		// reject the vote if the VotingDeadline of the Census of the
+		// process has passed
+		process, err := s.va.ProcessInfo(processID)
+		if err != nil {
+			return err
+		}
+		if err := s.cb.CheckVotingOpenByRoot(process.CensusRoot); err != nil {
+			return err
+		}
+	}
+	return s.va.AddVote(processID, vote)
+}
+
+// getProcess returns the info of the process of the given processID
+func (s *Service) getProcess(processID uint64) (*types.Process, error) {
+	if err := s.checkVotes(); err != nil {
+		return nil, err
+	}
+	return s.va.ProcessInfo(processID)
+}
+
+// getVotes sends through the given votesStream the stored VotePackages of the
+// process of the given processID, sorted by index
+func (s *Service) getVotes(processID uint64, stream votesStream) error {
+	if err := s.checkVotes(); err != nil {
+		return err
+	}
+	if _, err := s.va.ProcessInfo(processID); err != nil {
+		return err
+	}
+	votes, err := s.sqlite.ReadVotePackagesByProcessID(processID)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(votes); i++ {
+		if err := stream.Send(&votes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package grpc
+
+import (
+	"database/sql"
+	"io"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/aragon/ovote-node/censusbuilder"
+	"github.com/aragon/ovote-node/db"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	"github.com/aragon/ovote-node/votesaggregator"
+	qt "github.com/frankban/quicktest"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vocdoni/arbo"
+	kvdb "go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/db/pebbledb"
+)
+
+type testKeysStream struct {
+	batches []keysBatch
+}
+
+func (s *testKeysStream) Recv() (*keysBatch, error) {
+	if len(s.batches) == 0 {
+		return nil, io.EOF
+	}
+	b := s.batches[0]
+	s.batches = s.batches[1:]
+	return &b, nil
+}
+
+type testProofsStream struct {
+	proofs []types.CensusProof
+}
+
+func (s *testProofsStream) Send(p *types.CensusProof) error {
+	s.proofs = append(s.proofs, *p)
+	return nil
+}
+
+type testVotesStream struct {
+	votes []types.VotePackage
+}
+
+func (s *testVotesStream) Send(v *types.VotePackage) error {
+	s.votes = append(s.votes, *v)
+	return nil
+}
+
+func newTestService(c *qt.C, chainID uint64) (*Service, *db.SQLite) {
+	database, err := pebbledb.New(kvdb.Options{Path: c.TempDir()})
+	c.Assert(err, qt.IsNil)
+	cb, err := censusbuilder.New(database, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := db.NewSQLite(sqlDB)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+	va, err := votesaggregator.New(sqlite, chainID, nil)
+	c.Assert(err, qt.IsNil)
+
+	s, err := NewService(cb, va, sqlite)
+	c.Assert(err, qt.IsNil)
+	return s, sqlite
+}
+
+func TestNewService(t *testing.T) {
+	c := qt.New(t)
+
+	_, err := NewService(nil, nil, nil)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	s, _ := newTestService(c, 3)
+	s.va = nil
+	_, err = s.getProcess(1)
+	c.Assert(err, qt.ErrorMatches, ErrServiceNotActive.Error()+".*")
+	s.cb = nil
+	_, err = s.createCensus(false)
+	c.Assert(err, qt.ErrorMatches, ErrServiceNotActive.Error()+".*")
+}
+
+func TestService(t *testing.T) {
+	c := qt.New(t)
+
+	chainID := uint64(3)
+	processID := uint64(123)
+	s, sqlite := newTestService(c, chainID)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+
+	censusID, err := s.createCensus(false)
+	c.Assert(err, qt.IsNil)
+
+	// import the keys in two batches
+	half := nKeys / 2
+	n, err := s.addKeys(&testKeysStream{batches: []keysBatch{
+		{censusID, keys.PublicKeys[:half], keys.Weights[:half]},
+		{censusID, keys.PublicKeys[half:], keys.Weights[half:]},
+	}})
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, uint64(nKeys))
+
+	// a batch for a different Census in the same stream
+	n, err = s.addKeys(&testKeysStream{batches: []keysBatch{
+		{censusID, nil, nil},
+		{censusID + 1, nil, nil},
+	}})
+	c.Assert(err, qt.ErrorMatches, "batch for CensusID=1.*")
+	c.Assert(n, qt.Equals, uint64(0))
+
+	// the proofs can not be generated until the Census is closed
+	_, err = s.closedCensusProof(censusID, keys.PublicKeys[0])
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	root, err := s.closeCensus(censusID)
+	c.Assert(err, qt.IsNil)
+
+	proof, err := s.closedCensusProof(censusID, keys.PublicKeys[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(proof.Verify(root), qt.IsNil)
+
+	ps := &testProofsStream{}
+	err = s.getProofs(censusID, keys.PublicKeys, ps)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(ps.proofs), qt.Equals, nKeys)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(ps.proofs[i].PublicKey.Compress(), qt.Equals,
+			keys.PublicKeys[i].Compress())
+		c.Assert(ps.proofs[i].Weight.Cmp(keys.Weights[i]), qt.Equals, 0)
+		c.Assert(ps.proofs[i].Verify(root), qt.IsNil)
+	}
+
+	// simulate the SmartContract Process creation
+	err = sqlite.StoreProcess(processID, root, uint64(nKeys), 10, 20, 20,
+		20, 60, 1)
+	c.Assert(err, qt.IsNil)
+	process, err := s.getProcess(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(process.CensusRoot, qt.DeepEquals, root)
+
+	l := arbo.HashFunctionPoseidon.Len()
+	voteBytes := arbo.BigIntToBytes(l, big.NewInt(1))
+	msg, err := types.HashVote(chainID, processID, voteBytes)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < nKeys; i++ {
+		vote := types.VotePackage{
+			Signature:   types.CompressSignature(keys.PrivateKeys[i].SignPoseidon(msg)),
+			CensusProof: ps.proofs[i],
+			Vote:        voteBytes,
+		}
+		err = s.submitVote(processID, vote)
+		c.Assert(err, qt.IsNil)
+	}
+	// the same vote again
+	vote := types.VotePackage{
+		Signature:   types.CompressSignature(keys.PrivateKeys[0].SignPoseidon(msg)),
+		CensusProof: ps.proofs[0],
+		Vote:        voteBytes,
+	}
+	c.Assert(s.submitVote(processID, vote), qt.Not(qt.IsNil))
+
+	vs := &testVotesStream{}
+	err = s.getVotes(processID, vs)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(vs.votes), qt.Equals, nKeys)
+	for i := 0; i < nKeys; i++ {
+		c.Assert(vs.votes[i].CensusProof.Index, qt.Equals,
+			ps.proofs[i].Index)
+	}
+
+	// a process that does not exist
+	err = s.getVotes(processID+1, &testVotesStream{})
+	c.Assert(err, qt.Not(qt.IsNil))
+}
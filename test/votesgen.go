@@ -71,7 +71,7 @@ func GenVotes(c *qt.C, cens *Census, chainID, processID uint64, ratio int) []typ
 		msgToSign, err := types.HashVote(chainID, processID, voteBytes)
 		c.Assert(err, qt.IsNil)
 		sigUncomp := cens.Keys.PrivateKeys[i].SignPoseidon(msgToSign)
-		sig := sigUncomp.Compress()
+		sig := types.CompressSignature(sigUncomp)
 
 		// get merkleproof
 		index, proof, err := cens.Census.GetProof(&cens.Keys.PublicKeys[i])
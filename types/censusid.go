@@ -0,0 +1,40 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrInvalidCensusID is used when a CensusID can not be parsed
+var ErrInvalidCensusID = errors.New("invalid CensusID")
+
+// CensusID identifies a Census of the CensusBuilder. It is a distinct type
+// from the uint64 used for the indexes and weights, so they can not be mixed
+// up. In the db it is still encoded as an uint64.
+type CensusID uint64
+
+// ParseCensusID parses the given decimal string into a CensusID, returning
+// ErrInvalidCensusID if it is empty, contains non-digit characters (including
+// a sign), or does not fit in an uint64
+func ParseCensusID(s string) (CensusID, error) {
+	if s == "" {
+		return 0, fmt.Errorf("%s, empty string", ErrInvalidCensusID)
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, fmt.Errorf("%s, %q is not a decimal number",
+				ErrInvalidCensusID, s)
+		}
+	}
+	id, err := strconv.ParseUint(s, 10, 64) //nolint:gomnd
+	if err != nil {
+		return 0, fmt.Errorf("%s, %q: %s", ErrInvalidCensusID, s, err)
+	}
+	return CensusID(id), nil
+}
+
+// String returns the decimal representation of the CensusID
+func (id CensusID) String() string {
+	return strconv.FormatUint(uint64(id), 10) //nolint:gomnd
+}
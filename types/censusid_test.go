@@ -0,0 +1,31 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParseCensusID(t *testing.T) {
+	c := qt.New(t)
+
+	id, err := ParseCensusID("0")
+	c.Assert(err, qt.IsNil)
+	c.Assert(id, qt.Equals, CensusID(0))
+	id, err = ParseCensusID("42")
+	c.Assert(err, qt.IsNil)
+	c.Assert(id, qt.Equals, CensusID(42))
+	c.Assert(id.String(), qt.Equals, "42")
+	id, err = ParseCensusID("18446744073709551615")
+	c.Assert(err, qt.IsNil)
+	c.Assert(id, qt.Equals, CensusID(18446744073709551615))
+
+	for _, s := range []string{"", "-1", "+1", "1.5", "0x10", " 1", "abc",
+		"18446744073709551616"} {
+		_, err = ParseCensusID(s)
+		c.Assert(err, qt.Not(qt.IsNil), qt.Commentf("%q", s))
+		c.Assert(strings.HasPrefix(err.Error(), ErrInvalidCensusID.Error()),
+			qt.IsTrue, qt.Commentf("%q", s))
+	}
+}
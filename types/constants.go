@@ -16,6 +16,9 @@ var (
 	// MaxKeyLen indicates the maximum key (index) length in the Census
 	// MerkleTree
 	MaxKeyLen int = int(math.Ceil(float64(MaxLevels) / float64(8))) //nolint:gomnd
+	// MaxLeafDataLen indicates the maximum length of the leaf data of a
+	// PublicKey, which needs to fit in a field element
+	MaxLeafDataLen int = arbo.HashFunctionPoseidon.Len() - 1
 	// EmptyRoot is a byte array of 0s, with the length of the hash
 	// function output length used in the Census MerkleTree
 	EmptyRoot = make([]byte, arbo.HashFunctionPoseidon.Len())
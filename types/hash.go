@@ -0,0 +1,79 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/mimc7"
+	"github.com/vocdoni/arbo"
+)
+
+var (
+	// TypeHashMiMC7 represents the label for the HashFunction of MiMC7
+	TypeHashMiMC7 = []byte("mimc7")
+
+	// HashFunctionMiMC7 contains the HashMiMC7 struct which implements the
+	// arbo.HashFunction interface
+	HashFunctionMiMC7 HashMiMC7
+)
+
+// HashMiMC7 implements the arbo.HashFunction interface for the MiMC7 hash,
+// so it can be used in the Census MerkleTree as an alternative to Poseidon
+type HashMiMC7 struct{}
+
+// Type returns the type of HashFunction for the HashMiMC7
+func (f HashMiMC7) Type() []byte {
+	return TypeHashMiMC7
+}
+
+// Len returns the length of the Hash output
+func (f HashMiMC7) Len() int {
+	return 32 //nolint:gomnd
+}
+
+// Hash implements the hash method for the HashFunction HashMiMC7. As
+// arbo.HashPoseidon, it expects the byte arrays to be little-endian
+// representations of big.Int values.
+func (f HashMiMC7) Hash(b ...[]byte) ([]byte, error) {
+	toHash := make([]*big.Int, len(b))
+	for i := 0; i < len(b); i++ {
+		toHash[i] = arbo.BytesToBigInt(b[i])
+	}
+	h, err := mimc7.Hash(toHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	return arbo.BigIntToBytes(f.Len(), h), nil
+}
+
+// HashFunctionFromType returns the HashFunction of the given type, which needs
+// to be one of the hash functions supported by the Census MerkleTree
+// (poseidon, mimc7, blake2b)
+func HashFunctionFromType(t string) (arbo.HashFunction, error) {
+	switch t {
+	case string(arbo.TypeHashPoseidon):
+		return arbo.HashFunctionPoseidon, nil
+	case string(TypeHashMiMC7):
+		return HashFunctionMiMC7, nil
+	case string(arbo.TypeHashBlake2b):
+		return arbo.HashFunctionBlake2b, nil
+	default:
+		return nil, fmt.Errorf("unsupported HashFunction: %s, expected %s, %s"+
+			" or %s", t, arbo.TypeHashPoseidon, TypeHashMiMC7,
+			arbo.TypeHashBlake2b)
+	}
+}
+
+// IndexLen returns the length of the leaf indexes of a MerkleTree of the
+// given maxLevels, as arbo does not accept keys with more bits than levels
+func IndexLen(maxLevels int) int {
+	return int(math.Ceil(float64(maxLevels) / float64(8))) //nolint:gomnd
+}
+
+// Uint64ToIndexWithLevels returns the bytes representation of the given uint64
+// that will be used as a leaf index in a MerkleTree of the given maxLevels.
+// The given uint64 needs to be lower than 2^maxLevels.
+func Uint64ToIndexWithLevels(u uint64, maxLevels int) []byte {
+	return Uint64ToIndex(u)[:IndexLen(maxLevels)]
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/db/pebbledb"
+)
+
+func TestHashFunctionFromType(t *testing.T) {
+	c := qt.New(t)
+
+	hashFunc, err := HashFunctionFromType("poseidon")
+	c.Assert(err, qt.IsNil)
+	c.Assert(hashFunc, qt.Equals, arbo.HashFunction(arbo.HashFunctionPoseidon))
+	hashFunc, err = HashFunctionFromType("mimc7")
+	c.Assert(err, qt.IsNil)
+	c.Assert(hashFunc, qt.Equals, arbo.HashFunction(HashFunctionMiMC7))
+	hashFunc, err = HashFunctionFromType("blake2b")
+	c.Assert(err, qt.IsNil)
+	c.Assert(hashFunc, qt.Equals, arbo.HashFunction(arbo.HashFunctionBlake2b))
+	_, err = HashFunctionFromType("sha256")
+	c.Assert(err, qt.ErrorMatches, "unsupported HashFunction: sha256.*")
+}
+
+func TestUint64ToIndexWithLevels(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(IndexLen(1), qt.Equals, 1)
+	c.Assert(IndexLen(8), qt.Equals, 1)
+	c.Assert(IndexLen(9), qt.Equals, 2)
+	c.Assert(IndexLen(MaxLevels), qt.Equals, MaxKeyLen)
+
+	c.Assert(Uint64ToIndexWithLevels(300, 16), qt.DeepEquals, []byte{44, 1})
+	c.Assert(Uint64ToIndexWithLevels(300, MaxLevels), qt.DeepEquals,
+		Uint64ToIndex(300))
+}
+
+func TestHashMiMC7Tree(t *testing.T) {
+	c := qt.New(t)
+
+	maxLevels := 10
+	database, err := pebbledb.New(db.Options{Path: c.TempDir()})
+	c.Assert(err, qt.IsNil)
+	tree, err := arbo.NewTree(arbo.Config{
+		Database:     database,
+		MaxLevels:    maxLevels,
+		HashFunction: HashFunctionMiMC7,
+	})
+	c.Assert(err, qt.IsNil)
+
+	nKeys := 20
+	weight := big.NewInt(3)
+	pubKs := make([]*babyjub.PublicKey, nKeys)
+	keys := make([][]byte, nKeys)
+	values := make([][]byte, nKeys)
+	for i := 0; i < nKeys; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs[i] = sk.Public()
+		keys[i] = Uint64ToIndexWithLevels(uint64(i), maxLevels)
+		values[i], err = HashPubKBytes(pubKs[i], weight)
+		c.Assert(err, qt.IsNil)
+	}
+	invalids, err := tree.AddBatch(keys, values)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	root, err := tree.Root()
+	c.Assert(err, qt.IsNil)
+
+	// the full length index can not be used in the tree
+	_, _, err = tree.Get(Uint64ToIndex(0))
+	c.Assert(err, qt.ErrorMatches, "len\\(k\\) can not be bigger.*")
+
+	for i := 0; i < nKeys; i++ {
+		_, _, proof, existence, err := tree.GenProof(keys[i])
+		c.Assert(err, qt.IsNil)
+		c.Assert(existence, qt.IsTrue)
+		cp := CensusProof{Index: uint64(i), PublicKey: pubKs[i],
+			Weight: weight, MerkleProof: proof}
+		c.Assert(cp.VerifyWithHashFunction(root, HashFunctionMiMC7), qt.IsNil)
+		// the proof is not valid with Poseidon
+		c.Assert(cp.Verify(root), qt.ErrorMatches,
+			"merkleproof verification failed")
+	}
+}
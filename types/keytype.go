@@ -0,0 +1,79 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-crypto/poseidon"
+	"github.com/vocdoni/arbo"
+)
+
+// KeyType is used to define the type of the key of a Census leaf
+type KeyType int
+
+var (
+	// KeyTypeBabyJubJub indicates a leaf of a babyjub PublicKey, whose
+	// votes are signed with SignatureSchemeEdDSAPoseidon
+	KeyTypeBabyJubJub KeyType = 0
+	// KeyTypeEthAddress indicates a leaf of an Ethereum address, whose
+	// votes are ECDSA signed, to be verified by a companion circuit
+	KeyTypeEthAddress KeyType = 1
+)
+
+// ErrUnsupportedKeyType is used when the KeyType is not supported
+var ErrUnsupportedKeyType = errors.New("unsupported KeyType")
+
+// String returns the name of the KeyType
+func (t KeyType) String() string {
+	switch t {
+	case KeyTypeBabyJubJub:
+		return "babyjubjub"
+	case KeyTypeEthAddress:
+		return "eth-address"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(t))
+	}
+}
+
+// addressHashLen is the number of bytes of the keccak256 hash of an Ethereum
+// address used in its leaf, so the value always fits in a field element
+const addressHashLen = 31
+
+// HashAddressBytes returns the bytes representation of the leaf value of the
+// given Ethereum address with its weight: the Poseidon hash of the first 31
+// bytes of the keccak256 hash of the address (as a big-endian integer) and
+// the weight
+func HashAddressBytes(addr common.Address, weight *big.Int) ([]byte, error) {
+	if weight == nil {
+		weight = big.NewInt(1)
+	}
+	addrHash := crypto.Keccak256(addr.Bytes())[:addressHashLen]
+	leafHash, err := poseidon.Hash([]*big.Int{new(big.Int).SetBytes(addrHash),
+		weight})
+	if err != nil {
+		return nil, err
+	}
+	return arbo.BigIntToBytes(hashLen, leafHash), nil
+}
+
+// LeafValue returns the leaf value of the key of the CensusProof, depending
+// on its KeyType
+func (cp *CensusProof) LeafValue() ([]byte, error) {
+	switch cp.KeyType {
+	case KeyTypeBabyJubJub:
+		if cp.PublicKey == nil {
+			return nil, fmt.Errorf("CensusProof without PublicKey")
+		}
+		return HashLeafBytes(cp.PublicKey, cp.Weight, cp.Data)
+	case KeyTypeEthAddress:
+		if cp.Address == nil {
+			return nil, fmt.Errorf("CensusProof without Address")
+		}
+		return HashAddressBytes(*cp.Address, cp.Weight)
+	default:
+		return nil, fmt.Errorf("%s: %s", ErrUnsupportedKeyType, cp.KeyType)
+	}
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	qt "github.com/frankban/quicktest"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/db/pebbledb"
+)
+
+func TestVerifyAddressCensusProof(t *testing.T) {
+	c := qt.New(t)
+
+	database, err := pebbledb.New(db.Options{Path: c.TempDir()})
+	c.Assert(err, qt.IsNil)
+	tree, err := arbo.NewTree(arbo.Config{Database: database,
+		MaxLevels: MaxLevels, HashFunction: arbo.HashFunctionPoseidon})
+	c.Assert(err, qt.IsNil)
+
+	addr := common.HexToAddress("0x71C7656EC7ab88b098defB751B7401B5f6d8976F")
+	weight := big.NewInt(5)
+	leafValue, err := HashAddressBytes(addr, weight)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(leafValue), qt.Equals, hashLen)
+	err = tree.Add(Uint64ToIndex(3), leafValue)
+	c.Assert(err, qt.IsNil)
+	_, _, merkleProof, existence, err := tree.GenProof(Uint64ToIndex(3))
+	c.Assert(err, qt.IsNil)
+	c.Assert(existence, qt.IsTrue)
+	root, err := tree.Root()
+	c.Assert(err, qt.IsNil)
+
+	cp := CensusProof{Index: 3, KeyType: KeyTypeEthAddress, Address: &addr,
+		Weight: weight, MerkleProof: merkleProof}
+	c.Assert(cp.Verify(root), qt.IsNil)
+
+	// the KeyType and the Address are kept in the JSON encoding
+	b, err := json.Marshal(cp)
+	c.Assert(err, qt.IsNil)
+	var decoded CensusProof
+	c.Assert(json.Unmarshal(b, &decoded), qt.IsNil)
+	c.Assert(decoded.KeyType, qt.Equals, KeyTypeEthAddress)
+	c.Assert(*decoded.Address, qt.Equals, addr)
+	c.Assert(decoded.Verify(root), qt.IsNil)
+
+	// a different weight or address does not verify
+	cp.Weight = big.NewInt(6)
+	c.Assert(cp.Verify(root), qt.Not(qt.IsNil))
+	cp.Weight = weight
+	otherAddr := common.HexToAddress("0x01")
+	cp.Address = &otherAddr
+	c.Assert(cp.Verify(root), qt.Not(qt.IsNil))
+
+	// an address proof needs the Address
+	cp.Address = nil
+	c.Assert(cp.Verify(root), qt.ErrorMatches, "CensusProof without Address")
+
+	cp.KeyType = KeyType(9)
+	c.Assert(cp.Verify(root), qt.ErrorMatches,
+		ErrUnsupportedKeyType.Error()+".*")
+	c.Assert(KeyTypeEthAddress.String(), qt.Equals, "eth-address")
+}
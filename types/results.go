@@ -0,0 +1,117 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/vocdoni/arbo"
+)
+
+// ResultBundle contains all the data needed to independently verify the result
+// of a Process: the CensusRoot, the tally and all the VotePackages with their
+// CensusProofs
+type ResultBundle struct {
+	ChainID    uint64    `json:"chainID"`
+	ProcessID  uint64    `json:"processID"`
+	CensusRoot ByteArray `json:"censusRoot"`
+	// Tally contains the sum of the weights of the votes for each option,
+	// where the key is the decimal representation of the vote value
+	Tally map[string]*big.Int `json:"tally"`
+	// TotalWeight contains the sum of the weights of all the votes
+	TotalWeight *big.Int      `json:"totalWeight"`
+	Votes       []VotePackage `json:"votes"`
+}
+
+// NewResultBundle returns a ResultBundle for the given votes, computing the
+// tally from them. Each vote is verified against the given CensusRoot.
+func NewResultBundle(chainID, processID uint64, censusRoot []byte,
+	votes []VotePackage) (*ResultBundle, error) {
+	for i := 0; i < len(votes); i++ {
+		if err := votes[i].Verify(chainID, processID, censusRoot); err != nil {
+			return nil, fmt.Errorf("vote %d (index %d): %s", i,
+				votes[i].CensusProof.Index, err)
+		}
+	}
+	tally, totalWeight := computeTally(votes)
+	return &ResultBundle{
+		ChainID:     chainID,
+		ProcessID:   processID,
+		CensusRoot:  censusRoot,
+		Tally:       tally,
+		TotalWeight: totalWeight,
+		Votes:       votes,
+	}, nil
+}
+
+// computeTally returns the sum of weights for each vote option, and the sum of
+// the weights of all the votes
+func computeTally(votes []VotePackage) (map[string]*big.Int, *big.Int) {
+	tally := make(map[string]*big.Int)
+	totalWeight := big.NewInt(0)
+	for i := 0; i < len(votes); i++ {
+		weight := votes[i].CensusProof.Weight
+		if weight == nil {
+			weight = big.NewInt(1)
+		}
+		option := arbo.BytesToBigInt(votes[i].Vote).String()
+		if _, ok := tally[option]; !ok {
+			tally[option] = big.NewInt(0)
+		}
+		tally[option] = new(big.Int).Add(tally[option], weight)
+		totalWeight = new(big.Int).Add(totalWeight, weight)
+	}
+	return tally, totalWeight
+}
+
+// Verify recomputes the tally from the contained votes, checking that it
+// matches the ResultBundle tally, and verifies each vote signature and
+// CensusProof against the ResultBundle CensusRoot
+func (rb *ResultBundle) Verify() error {
+	seen := make(map[uint64]bool)
+	for i := 0; i < len(rb.Votes); i++ {
+		index := rb.Votes[i].CensusProof.Index
+		if seen[index] {
+			return fmt.Errorf("duplicated vote for index %d", index)
+		}
+		seen[index] = true
+		if err := rb.Votes[i].Verify(rb.ChainID, rb.ProcessID,
+			rb.CensusRoot); err != nil {
+			return fmt.Errorf("vote %d (index %d): %s", i, index, err)
+		}
+	}
+
+	tally, totalWeight := computeTally(rb.Votes)
+	if rb.TotalWeight == nil || totalWeight.Cmp(rb.TotalWeight) != 0 {
+		return fmt.Errorf("totalWeight mismatch, expected: %s, bundle: %s",
+			totalWeight, rb.TotalWeight)
+	}
+	if len(tally) != len(rb.Tally) {
+		return fmt.Errorf("tally mismatch, expected %d options, bundle: %d",
+			len(tally), len(rb.Tally))
+	}
+	for option, weight := range tally {
+		bundleWeight, ok := rb.Tally[option]
+		if !ok || bundleWeight == nil || weight.Cmp(bundleWeight) != 0 {
+			return fmt.Errorf("tally mismatch for option %s, expected: %s,"+
+				" bundle: %s", option, weight, bundleWeight)
+		}
+	}
+	return nil
+}
+
+// Marshal returns the json representation of the ResultBundle
+func (rb *ResultBundle) Marshal() ([]byte, error) {
+	return json.Marshal(rb)
+}
+
+// UnmarshalResultBundle parses the given json representation of a
+// ResultBundle. The returned ResultBundle is not verified, ResultBundle.Verify
+// needs to be called.
+func UnmarshalResultBundle(b []byte) (*ResultBundle, error) {
+	var rb ResultBundle
+	if err := json.Unmarshal(b, &rb); err != nil {
+		return nil, err
+	}
+	return &rb, nil
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RootLen is the length in bytes of a CensusRoot, which is the output length
+// of the Poseidon hash used in the Census MerkleTree
+const RootLen = 32
+
+// ErrInvalidRootLen is used when trying to build a Root from a byte array that
+// does not have RootLen bytes
+var ErrInvalidRootLen = errors.New("invalid CensusRoot length")
+
+// Root represents a CensusRoot as a fixed-length value
+type Root [RootLen]byte
+
+// NewRoot returns the Root for the given bytes, returning error if their
+// length is not RootLen
+func NewRoot(b []byte) (Root, error) {
+	var r Root
+	if len(b) != RootLen {
+		return r, fmt.Errorf("%s, expected %d bytes, got %d", ErrInvalidRootLen,
+			RootLen, len(b))
+	}
+	copy(r[:], b)
+	return r, nil
+}
+
+// ParseRoot returns the Root for the given hex representation, which can be
+// prefixed by "0x"
+func ParseRoot(s string) (Root, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return Root{}, err
+	}
+	return NewRoot(b)
+}
+
+// Bytes returns the byte array representation of the Root
+func (r Root) Bytes() []byte {
+	return append([]byte{}, r[:]...)
+}
+
+// Hex returns the hex representation of the Root, without "0x" prefix
+func (r Root) Hex() string {
+	return hex.EncodeToString(r[:])
+}
+
+// MarshalJSON implements the Marshaler interface for Root types
+func (r Root) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Hex())
+}
+
+// UnmarshalJSON implements the Unmarshaler interface for Root types
+func (r *Root) UnmarshalJSON(j []byte) error {
+	var s string
+	if err := json.Unmarshal(j, &s); err != nil {
+		return err
+	}
+	root, err := ParseRoot(s)
+	if err != nil {
+		return err
+	}
+	*r = root
+	return nil
+}
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// SignatureScheme is used to define the scheme of the Signature of a
+// VotePackage, which determines its length
+type SignatureScheme int
+
+var (
+	// SignatureSchemeEdDSAPoseidon indicates a babyjub EdDSA signature of
+	// the Poseidon hash of the message, in its compressed form. It is the
+	// scheme verified by the circuit.
+	SignatureSchemeEdDSAPoseidon SignatureScheme = 0
+)
+
+// ErrUnsupportedSignatureScheme is used when the SignatureScheme is not known
+var ErrUnsupportedSignatureScheme = errors.New("unsupported SignatureScheme")
+
+// signatureLens contains the length of the Signature of each SignatureScheme
+var signatureLens = map[SignatureScheme]int{
+	SignatureSchemeEdDSAPoseidon: len(babyjub.SignatureComp{}),
+}
+
+// String returns the name of the SignatureScheme
+func (s SignatureScheme) String() string {
+	switch s {
+	case SignatureSchemeEdDSAPoseidon:
+		return "eddsa-poseidon"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// SignatureLen returns the length of the Signatures of the SignatureScheme
+func (s SignatureScheme) SignatureLen() (int, error) {
+	l, ok := signatureLens[s]
+	if !ok {
+		return 0, fmt.Errorf("%s: %s", ErrUnsupportedSignatureScheme, s)
+	}
+	return l, nil
+}
+
+// CheckSignatureLen returns error if the length of the given Signature does
+// not match the length of the SignatureScheme
+func (s SignatureScheme) CheckSignatureLen(sig []byte) error {
+	l, err := s.SignatureLen()
+	if err != nil {
+		return err
+	}
+	if len(sig) != l {
+		return fmt.Errorf("invalid %s Signature length: %d, expected: %d",
+			s, len(sig), l)
+	}
+	return nil
+}
+
+// CompressSignature returns the compressed bytes of the given babyjub
+// Signature, to be used as the Signature of a VotePackage
+func CompressSignature(sig *babyjub.Signature) ByteArray {
+	sigComp := sig.Compress()
+	return sigComp[:]
+}
+
+// DecompressSignature returns the babyjub Signature of the VotePackage, after
+// checking its length for its SignatureScheme, which needs to be
+// SignatureSchemeEdDSAPoseidon
+func (vp *VotePackage) DecompressSignature() (*babyjub.Signature, error) {
+	if err := vp.SignatureScheme.CheckSignatureLen(vp.Signature); err != nil {
+		return nil, err
+	}
+	if vp.SignatureScheme != SignatureSchemeEdDSAPoseidon {
+		return nil, fmt.Errorf("%s: can not decompress a %s Signature as"+
+			" babyjub", ErrUnsupportedSignatureScheme, vp.SignatureScheme)
+	}
+	var sigComp babyjub.SignatureComp
+	copy(sigComp[:], vp.Signature)
+	return sigComp.Decompress()
+}
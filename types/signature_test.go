@@ -0,0 +1,103 @@
+package types
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestSignatureScheme(t *testing.T) {
+	c := qt.New(t)
+
+	l, err := SignatureSchemeEdDSAPoseidon.SignatureLen()
+	c.Assert(err, qt.IsNil)
+	c.Assert(l, qt.Equals, 64)
+	c.Assert(SignatureSchemeEdDSAPoseidon.String(), qt.Equals, "eddsa-poseidon")
+
+	_, err = SignatureScheme(100).SignatureLen()
+	c.Assert(err, qt.ErrorMatches, ErrUnsupportedSignatureScheme.Error()+".*")
+	c.Assert(SignatureScheme(100).CheckSignatureLen(nil), qt.ErrorMatches,
+		ErrUnsupportedSignatureScheme.Error()+".*")
+
+	c.Assert(SignatureSchemeEdDSAPoseidon.CheckSignatureLen(make([]byte, 64)),
+		qt.IsNil)
+	c.Assert(SignatureSchemeEdDSAPoseidon.CheckSignatureLen(make([]byte, 65)),
+		qt.ErrorMatches, "invalid eddsa-poseidon Signature length: 65.*")
+}
+
+func TestDecompressSignature(t *testing.T) {
+	c := qt.New(t)
+
+	sk := babyjub.NewRandPrivKey()
+	sig := sk.SignPoseidon(big.NewInt(1))
+	vp := VotePackage{Signature: CompressSignature(sig)}
+	c.Assert(len(vp.Signature), qt.Equals, 64)
+
+	sig2, err := vp.DecompressSignature()
+	c.Assert(err, qt.IsNil)
+	c.Assert(sig2.Compress(), qt.Equals, sig.Compress())
+
+	// a Signature with a length of another scheme is rejected before
+	// decompressing it
+	vp.Signature = append(vp.Signature, 0)
+	_, err = vp.DecompressSignature()
+	c.Assert(err, qt.ErrorMatches, "invalid eddsa-poseidon Signature length.*")
+	vp.Signature = vp.Signature[:32]
+	_, err = vp.DecompressSignature()
+	c.Assert(err, qt.ErrorMatches, "invalid eddsa-poseidon Signature length.*")
+}
+
+func TestSignatureSchemeJSON(t *testing.T) {
+	c := qt.New(t)
+
+	sk := babyjub.NewRandPrivKey()
+	sig := CompressSignature(sk.SignPoseidon(big.NewInt(1)))
+
+	// the default SignatureScheme is not encoded
+	b, err := json.Marshal(VotePackage{Signature: sig})
+	c.Assert(err, qt.IsNil)
+	c.Assert(strings.Contains(string(b), "signatureScheme"), qt.IsFalse)
+	b, err = json.Marshal(VotePackage{Signature: sig, SignatureScheme: 7})
+	c.Assert(err, qt.IsNil)
+	var vp VotePackage
+	c.Assert(json.Unmarshal(b, &vp), qt.IsNil)
+	c.Assert(vp.SignatureScheme, qt.Equals, SignatureScheme(7))
+	c.Assert(vp.Signature, qt.DeepEquals, sig)
+
+	// the Signatures encoded with the "0x" prefix are accepted
+	vp = VotePackage{}
+	j := `{"signature":"0x` + hex.EncodeToString(sig) + `"}`
+	c.Assert(json.Unmarshal([]byte(j), &vp), qt.IsNil)
+	c.Assert(vp.Signature, qt.DeepEquals, sig)
+	c.Assert(vp.SignatureScheme, qt.Equals, SignatureSchemeEdDSAPoseidon)
+}
+
+func TestVerifySignatureScheme(t *testing.T) {
+	c := qt.New(t)
+
+	sk := babyjub.NewRandPrivKey()
+	vote := []byte("test")
+	msg, err := HashVote(1, 2, vote)
+	c.Assert(err, qt.IsNil)
+	vp := VotePackage{
+		Signature:   CompressSignature(sk.SignPoseidon(msg)),
+		CensusProof: CensusProof{PublicKey: sk.Public()},
+		Vote:        vote,
+	}
+	c.Assert(vp.verifySignature(1, 2), qt.IsNil)
+
+	// the length of the Signature is checked against the declared
+	// SignatureScheme
+	vp.SignatureScheme = 7
+	c.Assert(vp.verifySignature(1, 2), qt.ErrorMatches,
+		ErrUnsupportedSignatureScheme.Error()+".*")
+	vp.SignatureScheme = SignatureSchemeEdDSAPoseidon
+	vp.Signature = append(vp.Signature, 0)
+	err = vp.Verify(1, 2, nil)
+	c.Assert(err, qt.ErrorMatches, "invalid eddsa-poseidon Signature length: 65.*")
+}
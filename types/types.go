@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-iden3-crypto/constants"
 	"github.com/iden3/go-iden3-crypto/poseidon"
 	"github.com/vocdoni/arbo"
 )
@@ -50,7 +53,8 @@ func (b *ByteArray) UnmarshalJSON(j []byte) error {
 	if err != nil {
 		return err
 	}
-	b2, err := hex.DecodeString(s)
+	// accept the "0x" prefix, as the babyjub types decoded from hex do
+	b2, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
 	if err != nil {
 		return err
 	}
@@ -60,17 +64,37 @@ func (b *ByteArray) UnmarshalJSON(j []byte) error {
 
 // CensusProof contains the proof of a PublicKey in the Census Tree
 type CensusProof struct {
-	Index       uint64             `json:"index"`
-	PublicKey   *babyjub.PublicKey `json:"publicKey"`
+	Index uint64 `json:"index"`
+	// KeyType defines the type of the key of the leaf, which is the
+	// PublicKey for KeyTypeBabyJubJub and the Address for
+	// KeyTypeEthAddress
+	KeyType     KeyType            `json:"keyType,omitempty"`
+	PublicKey   *babyjub.PublicKey `json:"publicKey,omitempty"`
+	Address     *common.Address    `json:"address,omitempty"`
 	Weight      *big.Int           `json:"weight"`
 	MerkleProof ByteArray          `json:"merkleProof"`
+	// Data is the optional leaf data of the PublicKey, committed
+	// together with the PublicKey in the leaf value (see HashLeafBytes)
+	Data ByteArray `json:"data,omitempty"`
 }
 
 // VotePackage represents the vote sent by the User
 type VotePackage struct {
-	Signature   babyjub.SignatureComp `json:"signature"`
-	CensusProof CensusProof           `json:"censusProof"`
-	Vote        ByteArray             `json:"vote"`
+	// Signature contains the Signature of the vote, its length depends
+	// on the SignatureScheme
+	Signature ByteArray `json:"signature"`
+	// SignatureScheme defines the scheme of the Signature, which is
+	// SignatureSchemeEdDSAPoseidon when it is not set
+	SignatureScheme SignatureScheme `json:"signatureScheme,omitempty"`
+	CensusProof     CensusProof     `json:"censusProof"`
+	Vote            ByteArray       `json:"vote"`
+	// Nullifier is an optional value unique for each vote, used to
+	// prevent double voting when the identity of the voter is hidden
+	Nullifier ByteArray `json:"nullifier,omitempty"`
+	// InsertedDatetime contains the datetime of when the VotePackage was
+	// inserted in the db. It is set when reading the VotePackage from
+	// the db, and it is not part of the json representation.
+	InsertedDatetime time.Time `json:"-"`
 }
 
 // Process represents a voting process
@@ -127,13 +151,78 @@ func HashVote(chainID, processID uint64, vote []byte) (*big.Int, error) {
 	return signedMsg, nil
 }
 
+// HashVoteCensusBound computes the vote hash bound to the CensusRoot and to
+// the voter index, so a signature of the message can not be replayed in a
+// different Census. The message is computed as:
+// Poseidon(censusRoot, vote, index)
+// where censusRoot and vote are parsed as little-endian field elements (as
+// done by arbo.BytesToBigInt), and index is the voter leaf index as a field
+// element. The censusRoot is reduced modulo the field order, as the roots of
+// the trees of non field HashFunctions (eg. Blake2b) may not fit in the field.
+// Note that this is not the message verified by the circuit, which uses
+// HashVote.
+func HashVoteCensusBound(censusRoot, vote []byte, index uint64) (*big.Int, error) {
+	return hashVoteCensusBound(arbo.BytesToBigInt(censusRoot), vote, index)
+}
+
+// hashVoteCensusBound computes HashVoteCensusBound for an already parsed
+// censusRoot, so it can be reused across the votes of a batch
+func hashVoteCensusBound(censusRoot *big.Int, vote []byte, index uint64) (*big.Int, error) {
+	signedMsg, err := poseidon.Hash([]*big.Int{
+		new(big.Int).Mod(censusRoot, constants.Q),
+		arbo.BytesToBigInt(vote),
+		new(big.Int).SetUint64(index),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signedMsg, nil
+}
+
+// VerifyVotePackage checks the MerkleProof of the given VotePackage against
+// the given CensusRoot, and its signature over the message computed by
+// HashVoteCensusBound, so a VotePackage signed for a CensusRoot is rejected
+// for any other CensusRoot.
+func VerifyVotePackage(vp *VotePackage, censusRoot []byte) error {
+	return verifyVotePackage(vp, censusRoot, arbo.BytesToBigInt(censusRoot),
+		arbo.HashFunctionPoseidon, MaxLevels)
+}
+
+// verifyVotePackage implements VerifyVotePackage, where censusRootBigInt is
+// the censusRoot parsed as a field element, and hashFunc and maxLevels the
+// HashFunction and the MaxLevels of the Census MerkleTree
+func verifyVotePackage(vp *VotePackage, censusRoot []byte,
+	censusRootBigInt *big.Int, hashFunc arbo.HashFunction, maxLevels int) error {
+	if vp.CensusProof.KeyType != KeyTypeBabyJubJub {
+		// the ECDSA signed votes are verified by a companion circuit
+		return fmt.Errorf("%s for the votes: %s", ErrUnsupportedKeyType,
+			vp.CensusProof.KeyType)
+	}
+	if vp.CensusProof.PublicKey == nil {
+		return fmt.Errorf("VotePackage without PublicKey")
+	}
+	msgToSign, err := hashVoteCensusBound(censusRootBigInt, vp.Vote,
+		vp.CensusProof.Index)
+	if err != nil {
+		return err
+	}
+	sigUncompressed, err := vp.DecompressSignature()
+	if err != nil {
+		return err
+	}
+	if !vp.CensusProof.PublicKey.VerifyPoseidon(msgToSign, sigUncompressed) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return vp.CensusProof.verify(censusRoot, hashFunc, maxLevels)
+}
+
 func (vp *VotePackage) verifySignature(chainID, processID uint64) error {
 	msgToSign, err := HashVote(chainID, processID, vp.Vote)
 	if err != nil {
 		return err
 	}
 
-	sigUncompressed, err := vp.Signature.Decompress()
+	sigUncompressed, err := vp.DecompressSignature()
 	if err != nil {
 		return err
 	}
@@ -145,14 +234,38 @@ func (vp *VotePackage) verifySignature(chainID, processID uint64) error {
 	return nil
 }
 func (vp *VotePackage) verifyMerkleProof(root []byte) error {
-	indexBytes := Uint64ToIndex(vp.CensusProof.Index)
-	pubKHashBytes, err := HashPubKBytes(vp.CensusProof.PublicKey,
-		vp.CensusProof.Weight)
+	return vp.CensusProof.Verify(root)
+}
+
+// Verify checks the MerkleProof of the CensusProof against the given
+// CensusRoot
+func (cp *CensusProof) Verify(root []byte) error {
+	return cp.verify(root, arbo.HashFunctionPoseidon, MaxLevels)
+}
+
+// VerifyWithHashFunction checks the MerkleProof of the CensusProof against the
+// given CensusRoot of a MerkleTree that uses the given HashFunction
+func (cp *CensusProof) VerifyWithHashFunction(root []byte,
+	hashFunc arbo.HashFunction) error {
+	return cp.verify(root, hashFunc, MaxLevels)
+}
+
+// verify checks the MerkleProof against the given CensusRoot of a MerkleTree
+// with the given HashFunction and MaxLevels, which determines the length of the
+// leaf key hashed into the leaf (relevant for the HashFunctions that hash
+// bytes, as Blake2b, and not field elements)
+func (cp *CensusProof) verify(root []byte, hashFunc arbo.HashFunction,
+	maxLevels int) error {
+	leafValue, err := cp.LeafValue()
 	if err != nil {
 		return err
 	}
-	v, err := arbo.CheckProof(arbo.HashFunctionPoseidon, indexBytes,
-		pubKHashBytes, root, vp.CensusProof.MerkleProof)
+	if err := CheckMerkleProofFormat(cp.MerkleProof); err != nil {
+		return err
+	}
+	indexBytes := Uint64ToIndexWithLevels(cp.Index, maxLevels)
+	v, err := arbo.CheckProof(hashFunc, indexBytes, leafValue, root,
+		cp.MerkleProof)
 	if err != nil {
 		return err
 	}
@@ -162,7 +275,53 @@ func (vp *VotePackage) verifyMerkleProof(root []byte) error {
 	return nil
 }
 
-// Verify checks the signature and merkleproof of the VotePackage
+// Siblings returns the unpacked siblings of the MerkleProof in the order
+// consumed by the circuit: from the leaf level to the root level, so
+// Siblings()[0] is the sibling of the leaf. Each sibling is a 32 byte
+// little-endian encoded field element (as arbo.BigIntToBytes), and empty
+// siblings are returned as 32 zero bytes. Only the levels down to the leaf
+// are returned, the circuit inputs must be padded with zeros up to its
+// nLevels.
+func (cp *CensusProof) Siblings() ([][]byte, error) {
+	if err := CheckMerkleProofFormat(cp.MerkleProof); err != nil {
+		return nil, err
+	}
+	// arbo unpacks the siblings from the root level to the leaf level
+	s, err := arbo.UnpackSiblings(arbo.HashFunctionPoseidon, cp.MerkleProof)
+	if err != nil {
+		return nil, err
+	}
+	siblings := make([][]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		siblings[i] = s[len(s)-1-i]
+	}
+	return siblings, nil
+}
+
+// PathBits returns the path-index bits of the CensusProof, in the same
+// leaf-to-root order than Siblings, so PathBits()[i] corresponds to
+// Siblings()[i]. A true bit means that at that level the node is the right
+// child, so the parent is computed as Hash(sibling, node), while a false bit
+// means Hash(node, sibling). The bit of the level l (counting from the root)
+// is the bit l of the Index, where the Index is encoded in little-endian as
+// in Uint64ToIndex, so the bit of the root level is the least significant
+// bit of the Index.
+func (cp *CensusProof) PathBits() ([]bool, error) {
+	siblings, err := cp.Siblings()
+	if err != nil {
+		return nil, err
+	}
+	nLevels := len(siblings)
+	bits := make([]bool, nLevels)
+	for i := 0; i < nLevels; i++ {
+		level := nLevels - 1 - i
+		bits[i] = (cp.Index>>uint(level))&1 == 1
+	}
+	return bits, nil
+}
+
+// Verify checks the signature and merkleproof of the VotePackage, rejecting the
+// Signatures whose length does not match the declared SignatureScheme
 func (vp *VotePackage) Verify(chainID, processID uint64, root []byte) error {
 	if err := vp.verifySignature(chainID, processID); err != nil {
 		return err
@@ -174,6 +333,27 @@ func (vp *VotePackage) Verify(chainID, processID uint64, root []byte) error {
 	return nil
 }
 
+// CheckMerkleProofFormat checks that the given packed MerkleProof siblings
+// follow the arbo.PackSiblings format, so they can be unpacked without errors:
+// [ 2 byte | 2 byte | L bytes | S * N bytes ]
+// [ full length | bitmap length (L) | bitmap | N non-zero siblings ]
+func CheckMerkleProofFormat(p []byte) error {
+	if len(p) < 4 { //nolint:gomnd
+		return fmt.Errorf("invalid merkleproof length: %d", len(p))
+	}
+	fullLen := int(binary.LittleEndian.Uint16(p[0:2]))
+	bitmapLen := int(binary.LittleEndian.Uint16(p[2:4]))
+	if fullLen != len(p) {
+		return fmt.Errorf("invalid merkleproof, expected len: %d, current len: %d",
+			fullLen, len(p))
+	}
+	if 4+bitmapLen > len(p) || (len(p)-4-bitmapLen)%hashLen != 0 {
+		return fmt.Errorf("invalid merkleproof, bitmap length: %d, len: %d",
+			bitmapLen, len(p))
+	}
+	return nil
+}
+
 // Uint64ToIndex returns the bytes representation of the given uint64 that will
 // be used as a leaf index in the MerkleTree
 func Uint64ToIndex(u uint64) []byte {
@@ -195,6 +375,32 @@ func HashPubKBytes(pubK *babyjub.PublicKey, weight *big.Int) ([]byte, error) {
 	return arbo.BigIntToBytes(hashLen, pubKHash), nil
 }
 
+// HashLeafBytes returns the bytes representation of the leaf value of the
+// given PublicKey with its weight and its optional leaf data, which is parsed
+// as a little-endian field element, so it can not be longer than
+// MaxLeafDataLen. Without leaf data, the value is the one of HashPubKBytes,
+// otherwise it is the Poseidon hash of the PublicKey, the weight and the leaf
+// data.
+func HashLeafBytes(pubK *babyjub.PublicKey, weight *big.Int, data []byte) (
+	[]byte, error) {
+	if len(data) == 0 {
+		return HashPubKBytes(pubK, weight)
+	}
+	if len(data) > MaxLeafDataLen {
+		return nil, fmt.Errorf("leaf data too long: %d, max: %d",
+			len(data), MaxLeafDataLen)
+	}
+	if weight == nil {
+		weight = big.NewInt(1)
+	}
+	leafHash, err := poseidon.Hash([]*big.Int{pubK.X, pubK.Y, weight,
+		arbo.BytesToBigInt(data)})
+	if err != nil {
+		return nil, err
+	}
+	return arbo.BigIntToBytes(hashLen, leafHash), nil
+}
+
 //
 // // SignatureCompressedSize sets the size of the compressed Signature byte array
 // const SignatureCompressedSize = 64
@@ -230,6 +436,32 @@ func HexToPublicKey(h string) (*babyjub.PublicKey, error) {
 	return pubK, nil
 }
 
+// CompressPublicKeys returns the compressed representation (32 bytes) of each
+// given PublicKey
+func CompressPublicKeys(pubKs []babyjub.PublicKey) []babyjub.PublicKeyComp {
+	pubKsComp := make([]babyjub.PublicKeyComp, len(pubKs))
+	for i := 0; i < len(pubKs); i++ {
+		pubKsComp[i] = pubKs[i].Compress()
+	}
+	return pubKsComp
+}
+
+// DecompressPublicKeys returns the PublicKeys of the given compressed
+// PublicKeys. Returns error if any of the compressed PublicKeys is not a valid
+// point of the curve.
+func DecompressPublicKeys(pubKsComp []babyjub.PublicKeyComp) ([]babyjub.PublicKey, error) {
+	pubKs := make([]babyjub.PublicKey, len(pubKsComp))
+	for i := 0; i < len(pubKsComp); i++ {
+		pubK, err := pubKsComp[i].Decompress()
+		if err != nil {
+			return nil, fmt.Errorf("can not decompress PublicKey %d (%x): %s",
+				i, pubKsComp[i][:], err)
+		}
+		pubKs[i] = *pubK
+	}
+	return pubKs, nil
+}
+
 // IndexAndWeightToBytes returns a byte array containing the given index and
 // weight, encoded as:
 // [   8   |   32   ]
@@ -52,7 +52,7 @@ func TestVerifyCensusProof(t *testing.T) {
 	sig := sk.SignPoseidon(msgToSign)
 
 	vp := VotePackage{
-		Signature: sig.Compress(),
+		Signature: CompressSignature(sig),
 		CensusProof: CensusProof{
 			Index:       index,
 			PublicKey:   pubK,
@@ -75,6 +75,33 @@ func TestVerifyCensusProof(t *testing.T) {
 	c.Assert(vp.Verify(chainID, processID, root), qt.Not(qt.IsNil))
 }
 
+func TestHashLeafBytes(t *testing.T) {
+	c := qt.New(t)
+
+	sk := babyjub.NewRandPrivKey()
+	pubK := sk.Public()
+	weight := big.NewInt(3)
+	hashPubK, err := HashPubKBytes(pubK, weight)
+	c.Assert(err, qt.IsNil)
+
+	// without leaf data the leaf value does not change
+	leaf, err := HashLeafBytes(pubK, weight, nil)
+	c.Assert(err, qt.IsNil)
+	c.Assert(leaf, qt.DeepEquals, hashPubK)
+
+	leaf, err = HashLeafBytes(pubK, weight, []byte{1})
+	c.Assert(err, qt.IsNil)
+	c.Assert(leaf, qt.Not(qt.DeepEquals), hashPubK)
+	leaf2, err := HashLeafBytes(pubK, weight, []byte{2})
+	c.Assert(err, qt.IsNil)
+	c.Assert(leaf2, qt.Not(qt.DeepEquals), leaf)
+
+	_, err = HashLeafBytes(pubK, weight, make([]byte, MaxLeafDataLen))
+	c.Assert(err, qt.IsNil)
+	_, err = HashLeafBytes(pubK, weight, make([]byte, MaxLeafDataLen+1))
+	c.Assert(err, qt.ErrorMatches, "leaf data too long.*")
+}
+
 func TestByteArrayJSON(t *testing.T) {
 	c := qt.New(t)
 	var b, b2 ByteArray
@@ -146,7 +173,7 @@ func TestVotePackageJSON(t *testing.T) {
 	sig := sk.SignPoseidon(voteBI)
 
 	vp := VotePackage{
-		Signature: sig.Compress(),
+		Signature: CompressSignature(sig),
 		CensusProof: CensusProof{
 			Index:       index,
 			PublicKey:   pubK,
@@ -183,7 +210,7 @@ func TestVotePackageJSON(t *testing.T) {
 	var vp2 VotePackage
 	err = json.Unmarshal(j, &vp2)
 	c.Assert(err, qt.IsNil)
-	c.Assert(vp2.Signature, qt.Equals, vp.Signature)
+	c.Assert(vp2.Signature, qt.DeepEquals, vp.Signature)
 	c.Assert(vp2.CensusProof.Index, qt.Equals, vp.CensusProof.Index)
 	c.Assert(vp2.CensusProof.PublicKey.String(), qt.Equals, vp.CensusProof.PublicKey.String())
 	c.Assert(vp2.CensusProof.Weight.String(), qt.Equals, vp.CensusProof.Weight.String())
@@ -203,3 +230,237 @@ func TestIndexAndWeightParser(t *testing.T) {
 	c.Assert(i2, qt.Equals, index)
 	c.Assert(weight.String(), qt.Equals, w2.String())
 }
+
+func TestVerifyVotePackageCensusBound(t *testing.T) {
+	c := qt.New(t)
+
+	// build two censuses containing the same key at the same index
+	sk := babyjub.NewRandPrivKey()
+	pubK := sk.Public()
+	weight := big.NewInt(1)
+	index := uint64(0)
+	var roots, proofs [][]byte
+	for i := 0; i < 2; i++ {
+		database, err := pebbledb.New(db.Options{Path: c.TempDir()})
+		c.Assert(err, qt.IsNil)
+		tree, err := arbo.NewTree(arbo.Config{
+			Database:     database,
+			MaxLevels:    MaxLevels,
+			HashFunction: arbo.HashFunctionPoseidon,
+		})
+		c.Assert(err, qt.IsNil)
+
+		value, err := HashPubKBytes(pubK, weight)
+		c.Assert(err, qt.IsNil)
+		err = tree.Add(Uint64ToIndex(index), value)
+		c.Assert(err, qt.IsNil)
+		// the second census contains an extra key
+		if i == 1 {
+			sk2 := babyjub.NewRandPrivKey()
+			value, err := HashPubKBytes(sk2.Public(), weight)
+			c.Assert(err, qt.IsNil)
+			err = tree.Add(Uint64ToIndex(1), value)
+			c.Assert(err, qt.IsNil)
+		}
+		_, _, proof, _, err := tree.GenProof(Uint64ToIndex(index))
+		c.Assert(err, qt.IsNil)
+		root, err := tree.Root()
+		c.Assert(err, qt.IsNil)
+		roots = append(roots, root)
+		proofs = append(proofs, proof)
+	}
+
+	vote := []byte("votetest")
+	msgToSign, err := HashVoteCensusBound(roots[0], vote, index)
+	c.Assert(err, qt.IsNil)
+	vp := VotePackage{
+		Signature: CompressSignature(sk.SignPoseidon(msgToSign)),
+		CensusProof: CensusProof{
+			Index:       index,
+			PublicKey:   pubK,
+			Weight:      weight,
+			MerkleProof: proofs[0],
+		},
+		Vote: vote,
+	}
+	c.Assert(VerifyVotePackage(&vp, roots[0]), qt.IsNil)
+
+	// replay the signature in the second census, with a valid MerkleProof
+	// for it, expect the signature to be rejected
+	vp.CensusProof.MerkleProof = proofs[1]
+	c.Assert(vp.verifyMerkleProof(roots[1]), qt.IsNil)
+	c.Assert(VerifyVotePackage(&vp, roots[1]), qt.ErrorMatches,
+		"signature verification failed")
+
+	// a signature bound to a different index is rejected
+	vp.CensusProof.MerkleProof = proofs[0]
+	msgToSign, err = HashVoteCensusBound(roots[0], vote, index+1)
+	c.Assert(err, qt.IsNil)
+	vp.Signature = CompressSignature(sk.SignPoseidon(msgToSign))
+	c.Assert(VerifyVotePackage(&vp, roots[0]), qt.ErrorMatches,
+		"signature verification failed")
+}
+
+func TestCompressPublicKeys(t *testing.T) {
+	c := qt.New(t)
+
+	var pubKs []babyjub.PublicKey
+	for i := 0; i < 10; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs = append(pubKs, *sk.Public())
+	}
+
+	pubKsComp := CompressPublicKeys(pubKs)
+	pubKs2, err := DecompressPublicKeys(pubKsComp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(pubKs2), qt.Equals, len(pubKs))
+	for i := 0; i < len(pubKs); i++ {
+		c.Assert(pubKs2[i].X.String(), qt.Equals, pubKs[i].X.String())
+		c.Assert(pubKs2[i].Y.String(), qt.Equals, pubKs[i].Y.String())
+	}
+	c.Assert(CompressPublicKeys(pubKs2), qt.DeepEquals, pubKsComp)
+
+	// expect error for a compressed PublicKey that is not in the curve
+	for i := 0; i < len(pubKsComp[3]); i++ {
+		pubKsComp[3][i] = 0xff
+	}
+	_, err = DecompressPublicKeys(pubKsComp)
+	c.Assert(err, qt.ErrorMatches, "can not decompress PublicKey 3 .*")
+}
+
+func TestRoot(t *testing.T) {
+	c := qt.New(t)
+
+	b := make([]byte, RootLen)
+	for i := 0; i < len(b); i++ {
+		b[i] = byte(i)
+	}
+	r, err := NewRoot(b)
+	c.Assert(err, qt.IsNil)
+	c.Assert(r.Bytes(), qt.DeepEquals, b)
+	c.Assert(r.Hex(), qt.Equals,
+		"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+
+	r2, err := ParseRoot(r.Hex())
+	c.Assert(err, qt.IsNil)
+	c.Assert(r2, qt.Equals, r)
+	r2, err = ParseRoot("0x" + r.Hex())
+	c.Assert(err, qt.IsNil)
+	c.Assert(r2, qt.Equals, r)
+
+	// expect error for short and long roots
+	_, err = NewRoot(b[:31])
+	c.Assert(err, qt.ErrorMatches, "invalid CensusRoot length, expected 32"+
+		" bytes, got 31")
+	_, err = NewRoot(append(b, 0))
+	c.Assert(err, qt.ErrorMatches, "invalid CensusRoot length, expected 32"+
+		" bytes, got 33")
+	_, err = ParseRoot("0x0102")
+	c.Assert(err, qt.ErrorMatches, "invalid CensusRoot length.*")
+	_, err = ParseRoot("zz")
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	// json
+	j, err := json.Marshal(r)
+	c.Assert(err, qt.IsNil)
+	c.Assert(string(j), qt.Equals, `"`+r.Hex()+`"`)
+	var r3 Root
+	err = json.Unmarshal(j, &r3)
+	c.Assert(err, qt.IsNil)
+	c.Assert(r3, qt.Equals, r)
+	err = json.Unmarshal([]byte(`"0102"`), &r3)
+	c.Assert(err, qt.ErrorMatches, "invalid CensusRoot length.*")
+
+	// the RootLen matches the hash length used in the Census
+	c.Assert(RootLen, qt.Equals, arbo.HashFunctionPoseidon.Len())
+}
+
+func TestCensusProofSiblingsAndPathBits(t *testing.T) {
+	c := qt.New(t)
+
+	database, err := pebbledb.New(db.Options{Path: c.TempDir()})
+	c.Assert(err, qt.IsNil)
+	tree, err := arbo.NewTree(arbo.Config{
+		Database:     database,
+		MaxLevels:    MaxLevels,
+		HashFunction: arbo.HashFunctionPoseidon,
+	})
+	c.Assert(err, qt.IsNil)
+
+	// tree with the indexes 0, 1, 2, 3, where at the root level the keys
+	// are split by the bit 0 of the index, and at the next level by the
+	// bit 1:
+	//            root
+	//        /          \
+	//    n{0,2}        n{1,3}
+	//    /    \        /    \
+	//  l0      l2    l1      l3
+	nLeafs := 4
+	pubKs := make([]*babyjub.PublicKey, nLeafs)
+	leafs := make([][]byte, nLeafs)
+	weight := big.NewInt(1)
+	for i := 0; i < nLeafs; i++ {
+		sk := babyjub.NewRandPrivKey()
+		pubKs[i] = sk.Public()
+		value, err := HashPubKBytes(pubKs[i], weight)
+		c.Assert(err, qt.IsNil)
+		c.Assert(tree.Add(Uint64ToIndex(uint64(i)), value), qt.IsNil)
+		leafs[i], err = arbo.HashFunctionPoseidon.Hash(
+			Uint64ToIndex(uint64(i)), value, []byte{arbo.PrefixValueLeaf})
+		c.Assert(err, qt.IsNil)
+	}
+	root, err := tree.Root()
+	c.Assert(err, qt.IsNil)
+
+	n02, err := arbo.HashFunctionPoseidon.Hash(leafs[0], leafs[2])
+	c.Assert(err, qt.IsNil)
+	n13, err := arbo.HashFunctionPoseidon.Hash(leafs[1], leafs[3])
+	c.Assert(err, qt.IsNil)
+	expectedSiblings := [][][]byte{
+		{leafs[2], n13},
+		{leafs[3], n02},
+		{leafs[0], n13},
+		{leafs[1], n02},
+	}
+	expectedPathBits := [][]bool{
+		{false, false},
+		{false, true},
+		{true, false},
+		{true, true},
+	}
+
+	for i := 0; i < nLeafs; i++ {
+		_, _, proof, _, err := tree.GenProof(Uint64ToIndex(uint64(i)))
+		c.Assert(err, qt.IsNil)
+		cp := CensusProof{
+			Index:       uint64(i),
+			PublicKey:   pubKs[i],
+			Weight:      weight,
+			MerkleProof: proof,
+		}
+		siblings, err := cp.Siblings()
+		c.Assert(err, qt.IsNil)
+		c.Assert(siblings, qt.DeepEquals, expectedSiblings[i])
+		bits, err := cp.PathBits()
+		c.Assert(err, qt.IsNil)
+		c.Assert(bits, qt.DeepEquals, expectedPathBits[i])
+
+		// compute the root from the leaf as the circuit does
+		node := leafs[i]
+		for j := 0; j < len(siblings); j++ {
+			if bits[j] {
+				node, err = arbo.HashFunctionPoseidon.Hash(siblings[j], node)
+			} else {
+				node, err = arbo.HashFunctionPoseidon.Hash(node, siblings[j])
+			}
+			c.Assert(err, qt.IsNil)
+		}
+		c.Assert(node, qt.DeepEquals, root)
+	}
+
+	cp := CensusProof{MerkleProof: []byte{1}}
+	_, err = cp.Siblings()
+	c.Assert(err, qt.Not(qt.IsNil))
+	_, err = cp.PathBits()
+	c.Assert(err, qt.Not(qt.IsNil))
+}
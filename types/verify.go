@@ -0,0 +1,133 @@
+package types
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"github.com/vocdoni/arbo"
+)
+
+// CensusParameters contains the parameters of the Census MerkleTree, needed by
+// the clients to build and verify the MerkleProofs
+type CensusParameters struct {
+	// Arity is the number of childs of each intermediate node of the tree
+	Arity int `json:"arity"`
+	// HashFunction is the identifier of the hash function used in the tree
+	HashFunction string `json:"hashFunction"`
+	// MaxLevels is the maximum depth of the tree
+	MaxLevels int `json:"maxLevels"`
+	// MaxNLeafs is the maximum number of leafs of the tree
+	MaxNLeafs uint64 `json:"maxNLeafs"`
+}
+
+// check returns error if the CensusParameters are not supported by the
+// verification of the MerkleProofs, and the HashFunction of the tree otherwise
+func (p CensusParameters) check() (arbo.HashFunction, error) {
+	if p.Arity != 2 { //nolint:gomnd
+		return nil, fmt.Errorf("unsupported CensusParameters Arity: %d", p.Arity)
+	}
+	hashFunc, err := HashFunctionFromType(p.HashFunction)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported CensusParameters HashFunction: %s",
+			p.HashFunction)
+	}
+	if p.MaxLevels <= 0 || p.MaxLevels > MaxLevels {
+		return nil, fmt.Errorf("invalid CensusParameters MaxLevels: %d, must be"+
+			" between 1 and %d", p.MaxLevels, MaxLevels)
+	}
+	return hashFunc, nil
+}
+
+// VerifyAgainstRoot checks the VotePackage against the given CensusRoot,
+// obtained externally (eg. from the SmartContract), and the given
+// CensusParameters of the tree under that CensusRoot, without needing the
+// Census data. Besides the checks done by VerifyVotePackage, checks that the
+// Index of the CensusProof fits in the tree and that the MerkleProof does not
+// have more levels than the tree.
+func (vp *VotePackage) VerifyAgainstRoot(root []byte, params CensusParameters) error {
+	hashFunc, err := params.check()
+	if err != nil {
+		return err
+	}
+	r, err := NewRoot(root)
+	if err != nil {
+		return err
+	}
+	rootBytes := r.Bytes()
+	return vp.verifyAgainstRoot(rootBytes, arbo.BytesToBigInt(rootBytes),
+		hashFunc, params)
+}
+
+// verifyAgainstRoot implements VerifyAgainstRoot for the already checked
+// CensusParameters and parsed CensusRoot, so the checks and the parsing can
+// be done once for a batch of VotePackages
+func (vp *VotePackage) verifyAgainstRoot(rootBytes []byte, rootBigInt *big.Int,
+	hashFunc arbo.HashFunction, params CensusParameters) error {
+	index := vp.CensusProof.Index
+	if params.MaxNLeafs != 0 && index >= params.MaxNLeafs {
+		return fmt.Errorf("Index %d out of the Census MaxNLeafs: %d", index,
+			params.MaxNLeafs)
+	}
+	if params.MaxLevels < 64 && index >= uint64(1)<<params.MaxLevels { //nolint:gomnd
+		return fmt.Errorf("Index %d out of the Census MaxLevels: %d", index,
+			params.MaxLevels)
+	}
+	siblings, err := vp.CensusProof.Siblings()
+	if err != nil {
+		return err
+	}
+	if len(siblings) > params.MaxLevels {
+		return fmt.Errorf("MerkleProof with %d levels, Census MaxLevels: %d",
+			len(siblings), params.MaxLevels)
+	}
+	return verifyVotePackage(vp, rootBytes, rootBigInt, hashFunc, params.MaxLevels)
+}
+
+// VerifyVotePackages checks each one of the given VotePackages against the
+// given CensusRoot and CensusParameters as VerifyAgainstRoot does, returning
+// a slice where the position i is true if the VotePackage at the position i
+// is valid. The CensusParameters and the CensusRoot are checked and parsed
+// once for the whole batch, and the VotePackages are verified in parallel by
+// a pool of workers. Returns error only if the given CensusRoot or
+// CensusParameters are not valid.
+func VerifyVotePackages(root []byte, vps []VotePackage,
+	params CensusParameters) ([]bool, error) {
+	hashFunc, err := params.check()
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	rootBytes := r.Bytes()
+	rootBigInt := arbo.BytesToBigInt(rootBytes)
+
+	valid := make([]bool, len(vps))
+	nWorkers := runtime.NumCPU()
+	if nWorkers > len(vps) {
+		nWorkers = len(vps)
+	}
+	indexes := make(chan int, nWorkers)
+	var wg sync.WaitGroup
+	for w := 0; w < nWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				// each worker writes only the positions that it
+				// receives, so no lock is needed
+				valid[i] = vps[i].verifyAgainstRoot(rootBytes,
+					rootBigInt, hashFunc, params) == nil
+			}
+		}()
+	}
+	for i := 0; i < len(vps); i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return valid, nil
+}
@@ -0,0 +1,266 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-iden3-crypto/constants"
+	"github.com/vocdoni/arbo"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/db/pebbledb"
+)
+
+// genVotePackages builds a tree with nVotes keys, returning its root and a
+// valid census bound VotePackage for each key
+func genVotePackages(tb testing.TB, nVotes int) ([]byte, []VotePackage) {
+	return genVotePackagesWithHashFunction(tb, nVotes, arbo.HashFunctionPoseidon)
+}
+
+// genVotePackagesWithHashFunction is like genVotePackages, building the tree
+// with the given HashFunction
+func genVotePackagesWithHashFunction(tb testing.TB, nVotes int,
+	hashFunc arbo.HashFunction) ([]byte, []VotePackage) {
+	c := qt.New(tb)
+	database, err := pebbledb.New(db.Options{Path: c.TempDir()})
+	c.Assert(err, qt.IsNil)
+	tree, err := arbo.NewTree(arbo.Config{
+		Database:     database,
+		MaxLevels:    MaxLevels,
+		HashFunction: hashFunc,
+	})
+	c.Assert(err, qt.IsNil)
+
+	weight := big.NewInt(1)
+	sks := make([]babyjub.PrivateKey, nVotes)
+	keys := make([][]byte, nVotes)
+	values := make([][]byte, nVotes)
+	for i := 0; i < nVotes; i++ {
+		sks[i] = babyjub.NewRandPrivKey()
+		keys[i] = Uint64ToIndex(uint64(i))
+		values[i], err = HashPubKBytes(sks[i].Public(), weight)
+		c.Assert(err, qt.IsNil)
+	}
+	invalids, err := tree.AddBatch(keys, values)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(invalids), qt.Equals, 0)
+	root, err := tree.Root()
+	c.Assert(err, qt.IsNil)
+
+	vote := []byte("votetest")
+	vps := make([]VotePackage, nVotes)
+	for i := 0; i < nVotes; i++ {
+		_, _, proof, _, err := tree.GenProof(keys[i])
+		c.Assert(err, qt.IsNil)
+		msgToSign, err := HashVoteCensusBound(root, vote, uint64(i))
+		c.Assert(err, qt.IsNil)
+		vps[i] = VotePackage{
+			Signature: CompressSignature(sks[i].SignPoseidon(msgToSign)),
+			CensusProof: CensusProof{
+				Index:       uint64(i),
+				PublicKey:   sks[i].Public(),
+				Weight:      weight,
+				MerkleProof: proof,
+			},
+			Vote: vote,
+		}
+	}
+	return root, vps
+}
+
+func TestVerifyVotePackages(t *testing.T) {
+	c := qt.New(t)
+
+	root, vps := genVotePackages(t, 20)
+	// make some of the VotePackages invalid
+	vps[3].CensusProof.Index++
+	vps[7].Vote = []byte("othervote")
+	vps[11].CensusProof.PublicKey = nil
+	vps[15].CensusProof.MerkleProof = []byte{1}
+
+	params := CensusParameters{
+		Arity:        2,
+		HashFunction: "poseidon",
+		MaxLevels:    MaxLevels,
+		MaxNLeafs:    MaxNLeafs,
+	}
+	valid, err := VerifyVotePackages(root, vps, params)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(valid), qt.Equals, len(vps))
+	for i := 0; i < len(vps); i++ {
+		expected := vps[i].VerifyAgainstRoot(root, params) == nil
+		c.Assert(valid[i], qt.Equals, expected, qt.Commentf("vote %d", i))
+	}
+	c.Assert(valid[0], qt.IsTrue)
+	c.Assert(valid[3], qt.IsFalse)
+	c.Assert(valid[7], qt.IsFalse)
+	c.Assert(valid[11], qt.IsFalse)
+	c.Assert(valid[15], qt.IsFalse)
+
+	// the votes are not valid for another root
+	otherRoot := append([]byte{}, root...)
+	otherRoot[0]++
+	valid, err = VerifyVotePackages(otherRoot, vps, params)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < len(valid); i++ {
+		c.Assert(valid[i], qt.IsFalse)
+	}
+
+	valid, err = VerifyVotePackages(root, nil, params)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(valid), qt.Equals, 0)
+
+	_, err = VerifyVotePackages(root[:10], vps, params)
+	c.Assert(err, qt.ErrorMatches, "invalid CensusRoot length.*")
+	p := params
+	p.HashFunction = "sha256"
+	_, err = VerifyVotePackages(root, vps, p)
+	c.Assert(err, qt.ErrorMatches,
+		"unsupported CensusParameters HashFunction: sha256")
+
+	// the Index is checked against the given MaxNLeafs
+	p = params
+	p.MaxNLeafs = 10
+	valid, err = VerifyVotePackages(root, vps, p)
+	c.Assert(err, qt.IsNil)
+	c.Assert(valid[0], qt.IsTrue)
+	c.Assert(valid[10], qt.IsFalse)
+
+	// the VotePackages of a Census with another HashFunction
+	root, vps = genVotePackagesWithHashFunction(t, 5, arbo.HashFunctionBlake2b)
+	valid, err = VerifyVotePackages(root, vps, params)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < len(valid); i++ {
+		c.Assert(valid[i], qt.IsFalse)
+	}
+	p = params
+	p.HashFunction = string(arbo.TypeHashBlake2b)
+	valid, err = VerifyVotePackages(root, vps, p)
+	c.Assert(err, qt.IsNil)
+	for i := 0; i < len(valid); i++ {
+		c.Assert(valid[i], qt.IsTrue)
+	}
+}
+
+func TestVerifyAgainstRoot(t *testing.T) {
+	c := qt.New(t)
+
+	// the root and proofs are generated by an arbo tree independent of
+	// any Census
+	root, vps := genVotePackages(t, 10)
+	params := CensusParameters{
+		Arity:        2,
+		HashFunction: "poseidon",
+		MaxLevels:    MaxLevels,
+		MaxNLeafs:    MaxNLeafs,
+	}
+	for i := 0; i < len(vps); i++ {
+		c.Assert(vps[i].VerifyAgainstRoot(root, params), qt.IsNil)
+	}
+	// the 10 leafs tree has 4 levels, which fit in a tree of 8 levels
+	maxLevels := 8
+	params.MaxLevels = maxLevels
+	params.MaxNLeafs = uint64(1) << maxLevels
+	for i := 0; i < len(vps); i++ {
+		c.Assert(vps[i].VerifyAgainstRoot(root, params), qt.IsNil)
+	}
+
+	// unsupported or invalid parameters
+	p := params
+	p.Arity = 4
+	c.Assert(vps[0].VerifyAgainstRoot(root, p), qt.ErrorMatches,
+		"unsupported CensusParameters Arity: 4")
+	p = params
+	p.HashFunction = "sha256"
+	c.Assert(vps[0].VerifyAgainstRoot(root, p), qt.ErrorMatches,
+		"unsupported CensusParameters HashFunction: sha256")
+	p = params
+	p.MaxLevels = 0
+	c.Assert(vps[0].VerifyAgainstRoot(root, p), qt.ErrorMatches,
+		"invalid CensusParameters MaxLevels: 0.*")
+
+	// the MerkleProofs have more levels than the given MaxLevels
+	p = params
+	p.MaxLevels = 2
+	p.MaxNLeafs = 0
+	c.Assert(vps[0].VerifyAgainstRoot(root, p), qt.ErrorMatches,
+		"MerkleProof with 4 levels, Census MaxLevels: 2")
+
+	// the Index does not fit in the tree
+	p = params
+	p.MaxNLeafs = 5
+	c.Assert(vps[5].VerifyAgainstRoot(root, p), qt.ErrorMatches,
+		"Index 5 out of the Census MaxNLeafs: 5")
+	vp := vps[0]
+	vp.CensusProof.Index = 1 << maxLevels
+	c.Assert(vp.VerifyAgainstRoot(root, params), qt.ErrorMatches,
+		"Index 256 out of the Census MaxNLeafs: 256")
+
+	// the VotePackage is not valid for another root
+	otherRoot := append([]byte{}, root...)
+	otherRoot[0]++
+	c.Assert(vps[0].VerifyAgainstRoot(otherRoot, params), qt.Not(qt.IsNil))
+	c.Assert(vps[0].VerifyAgainstRoot(root[:10], params), qt.ErrorMatches,
+		"invalid CensusRoot length.*")
+
+	// a tampered VotePackage is rejected
+	vp = vps[1]
+	vp.Vote = []byte("othervote")
+	c.Assert(vp.VerifyAgainstRoot(root, params), qt.ErrorMatches,
+		"signature verification failed")
+	vp = vps[1]
+	vp.CensusProof.Weight = big.NewInt(2)
+	c.Assert(vp.VerifyAgainstRoot(root, params), qt.ErrorMatches,
+		"merkleproof verification failed")
+
+	// the roots of a Blake2b tree may not fit in the field, generate trees
+	// until one of them has a root out of the field
+	var blake2bVPs []VotePackage
+	for {
+		root, blake2bVPs = genVotePackagesWithHashFunction(t, 10,
+			arbo.HashFunctionBlake2b)
+		if arbo.BytesToBigInt(root).Cmp(constants.Q) >= 0 {
+			break
+		}
+	}
+	params = CensusParameters{
+		Arity:        2,
+		HashFunction: string(arbo.TypeHashBlake2b),
+		MaxLevels:    MaxLevels,
+		MaxNLeafs:    MaxNLeafs,
+	}
+	for i := 0; i < len(blake2bVPs); i++ {
+		c.Assert(blake2bVPs[i].VerifyAgainstRoot(root, params), qt.IsNil)
+	}
+	c.Assert(blake2bVPs[0].VerifyAgainstRoot(otherRoot, params),
+		qt.Not(qt.IsNil))
+}
+
+func BenchmarkVerifyVotePackages(b *testing.B) {
+	nVotes := 10_000
+	root, vps := genVotePackages(b, nVotes)
+	params := CensusParameters{
+		Arity:        2,
+		HashFunction: "poseidon",
+		MaxLevels:    MaxLevels,
+		MaxNLeafs:    MaxNLeafs,
+	}
+
+	b.Run("Loop", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i := 0; i < nVotes; i++ {
+				if err := VerifyVotePackage(&vps[i], root); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("Batch", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if _, err := VerifyVotePackages(root, vps, params); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package votesaggregator
+
+import (
+	"errors"
+
+	"github.com/aragon/ovote-node/db"
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// DefaultVerifyQueueSize defines the default maximum number of stored votes
+// waiting to be verified by the background verifier
+const DefaultVerifyQueueSize = 1024
+
+// ErrVotesAggregatorClosed is used when trying to add a vote in AsyncVerify
+// mode after the VotesAggregator has been closed
+var ErrVotesAggregatorClosed = errors.New("VotesAggregator closed")
+
+// Options is used to pass the parameters to create a new VotesAggregator
+type Options struct {
+	// AsyncVerify enables the asynchronous verification of the votes:
+	// AddVote stores the vote without verifying its signature and
+	// MerkleProof, which are verified by a background verifier, which
+	// moves the votes that fail the verification to the rejected votes,
+	// that can be read with ReadRejectedVotes. If the verification queue
+	// is full, the vote is verified synchronously after storing it.
+	// GenerateProof waits until the votes of its process have been
+	// verified. It can not be used with a SQLite in AppendOnly mode. By
+	// default the votes are verified before storing them.
+	AsyncVerify bool
+	// VerifyQueueSize sets the maximum number of stored votes waiting to
+	// be verified in AsyncVerify mode. If not set,
+	// DefaultVerifyQueueSize is used.
+	VerifyQueueSize int
+}
+
+// verifyJob contains a stored vote waiting to be verified
+type verifyJob struct {
+	processID  uint64
+	censusRoot []byte
+	vote       types.VotePackage
+}
+
+// startVerifier starts the background verifier, which verifies the votes of
+// the queue until it is closed
+func (va *VotesAggregator) startVerifier() {
+	va.verifyWg.Add(1)
+	go func() {
+		defer va.verifyWg.Done()
+		for job := range va.verifyQueue {
+			_ = va.verifyStoredVote(job)
+			va.verifyMu.Lock()
+			va.pending[job.processID]--
+			if va.pending[job.processID] == 0 {
+				delete(va.pending, job.processID)
+			}
+			va.pendingCond.Broadcast()
+			va.verifyMu.Unlock()
+		}
+	}()
+}
+
+// storeAndVerifyAsync stores the given vote, and enqueues it to be verified by
+// the background verifier. If the queue is full, the vote is verified before
+// returning, returning the verification error.
+func (va *VotesAggregator) storeAndVerifyAsync(processID uint64, censusRoot []byte,
+	vote types.VotePackage) error {
+	va.verifyMu.Lock()
+	closed := va.closed
+	va.verifyMu.Unlock()
+	if closed {
+		return ErrVotesAggregatorClosed
+	}
+
+	if err := va.db.StoreVotePackage(processID, vote); err != nil {
+		return err
+	}
+
+	job := verifyJob{processID: processID, censusRoot: censusRoot, vote: vote}
+	va.verifyMu.Lock()
+	enqueued := false
+	if !va.closed {
+		select {
+		case va.verifyQueue <- job:
+			enqueued = true
+			va.pending[processID]++
+		default:
+		}
+	}
+	va.verifyMu.Unlock()
+	if enqueued {
+		return nil
+	}
+	return va.verifyStoredVote(job)
+}
+
+// verifyStoredVote checks the signature and the MerkleProof of the given
+// stored vote, and if the verification fails, moves the vote to the rejected
+// votes with the verification error as the reason, returning that error
+func (va *VotesAggregator) verifyStoredVote(job verifyJob) error {
+	err := job.vote.Verify(va.chainID, job.processID, job.censusRoot)
+	if err == nil {
+		return nil
+	}
+	log.Debugf("[ProcessID=%d] vote %d rejected: %s", job.processID,
+		job.vote.CensusProof.Index, err)
+	if err2 := va.db.RejectVotePackage(job.processID,
+		job.vote.CensusProof.Index, err.Error()); err2 != nil {
+		log.Errorf("Error while trying to reject vote %d of ProcessID:%d: %s."+
+			" Error: %s", job.vote.CensusProof.Index, job.processID, err, err2)
+	}
+	return err
+}
+
+// waitVerified waits until all the stored votes of the given processID that
+// are waiting in the queue of AsyncVerify mode have been verified. In the
+// default mode it returns directly.
+func (va *VotesAggregator) waitVerified(processID uint64) {
+	if !va.asyncVerify {
+		return
+	}
+	va.verifyMu.Lock()
+	defer va.verifyMu.Unlock()
+	for va.pending[processID] > 0 {
+		va.pendingCond.Wait()
+	}
+}
+
+// ReadRejectedVotes returns the votes of the processes with the given
+// CensusRoot that have failed the verification in AsyncVerify mode
+func (va *VotesAggregator) ReadRejectedVotes(censusRoot []byte) ([]db.RejectedVote, error) {
+	return va.db.ReadRejectedVotes(censusRoot)
+}
+
+// Close stops accepting new votes in AsyncVerify mode, and waits until all the
+// stored votes of the queue have been verified. In the default mode it does
+// nothing.
+func (va *VotesAggregator) Close() error {
+	if !va.asyncVerify {
+		return nil
+	}
+	va.verifyMu.Lock()
+	if va.closed {
+		va.verifyMu.Unlock()
+		return ErrVotesAggregatorClosed
+	}
+	va.closed = true
+	close(va.verifyQueue)
+	va.verifyMu.Unlock()
+	va.verifyWg.Wait()
+	return nil
+}
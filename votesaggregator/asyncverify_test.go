@@ -0,0 +1,133 @@
+package votesaggregator
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aragon/ovote-node/db"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func TestAddVoteAsyncVerify(t *testing.T) {
+	c := qt.New(t)
+
+	nVotes := 10
+	chainID := uint64(3)
+	processID := uint64(123)
+	va, votes := baseTestVotesAggregatorWithOptions(c, Options{AsyncVerify: true},
+		chainID, processID, nVotes, 60)
+
+	// forge the signature of the vote of index 3 with another key, and
+	// change the vote content of the vote of index 5 after signing it
+	sk := babyjub.NewRandPrivKey()
+	voteBI, err := types.HashVote(chainID, processID, votes[3].Vote)
+	c.Assert(err, qt.IsNil)
+	votes[3].Signature = types.CompressSignature(sk.SignPoseidon(voteBI))
+	votes[5].Vote = []byte("invalidvotecontent")
+
+	// the forged votes are stored without error
+	for i := 0; i < len(votes); i++ {
+		err = va.AddVote(processID, votes[i])
+		c.Assert(err, qt.IsNil)
+	}
+	// wait until all the votes have been verified
+	err = va.Close()
+	c.Assert(err, qt.IsNil)
+
+	stored, err := va.db.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(stored), qt.Equals, nVotes-2)
+	for i := 0; i < len(stored); i++ {
+		c.Assert(stored[i].CensusProof.Index, qt.Not(qt.Equals), uint64(3))
+		c.Assert(stored[i].CensusProof.Index, qt.Not(qt.Equals), uint64(5))
+	}
+
+	process, err := va.db.ReadProcessByID(processID)
+	c.Assert(err, qt.IsNil)
+	rejected, err := va.ReadRejectedVotes(process.CensusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(rejected), qt.Equals, 2)
+	indexes := map[uint64]bool{}
+	for i := 0; i < len(rejected); i++ {
+		indexes[rejected[i].VotePackage.CensusProof.Index] = true
+		c.Assert(rejected[i].ProcessID, qt.Equals, processID)
+		c.Assert(rejected[i].Reason, qt.Equals, "signature verification failed")
+	}
+	c.Assert(indexes, qt.DeepEquals, map[uint64]bool{3: true, 5: true})
+
+	// once closed, no more votes are accepted
+	err = va.AddVote(processID, votes[0])
+	c.Assert(errors.Is(err, ErrVotesAggregatorClosed), qt.IsTrue)
+	err = va.Close()
+	c.Assert(errors.Is(err, ErrVotesAggregatorClosed), qt.IsTrue)
+}
+
+func TestAddVoteSyncVerifyByDefault(t *testing.T) {
+	c := qt.New(t)
+
+	chainID := uint64(3)
+	processID := uint64(123)
+	va, votes := baseTestVotesAggregator(c, chainID, processID, 3, 60)
+
+	votes[0].Vote = []byte("invalidvotecontent")
+	err := va.AddVote(processID, votes[0])
+	c.Assert(err, qt.ErrorMatches, "signature verification failed")
+
+	// nothing is stored nor rejected
+	stored, err := va.db.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(stored), qt.Equals, 0)
+	process, err := va.db.ReadProcessByID(processID)
+	c.Assert(err, qt.IsNil)
+	rejected, err := va.ReadRejectedVotes(process.CensusRoot)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(rejected), qt.Equals, 0)
+
+	c.Assert(va.Close(), qt.IsNil)
+}
+
+func TestAsyncVerifyWaitVerified(t *testing.T) {
+	c := qt.New(t)
+
+	nVotes := 10
+	chainID := uint64(3)
+	processID := uint64(123)
+	va, votes := baseTestVotesAggregatorWithOptions(c, Options{AsyncVerify: true},
+		chainID, processID, nVotes, 60)
+
+	votes[2].Vote = []byte("invalidvotecontent")
+	for i := 0; i < len(votes); i++ {
+		err := va.AddVote(processID, votes[i])
+		c.Assert(err, qt.IsNil)
+	}
+	// without closing the VotesAggregator, the votes of the process are
+	// verified before generating its proof
+	va.waitVerified(processID)
+	va.verifyMu.Lock()
+	c.Assert(va.pending, qt.HasLen, 0)
+	va.verifyMu.Unlock()
+	stored, err := va.db.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(stored), qt.Equals, nVotes-1)
+	c.Assert(va.Close(), qt.IsNil)
+}
+
+func TestAsyncVerifyAppendOnly(t *testing.T) {
+	c := qt.New(t)
+
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := db.NewSQLiteWithOptions(sqlDB, db.Options{AppendOnly: true})
+	c.Assert(sqlite.Migrate(), qt.IsNil)
+
+	// the votes that fail the verification could not be rejected
+	_, err = NewWithOptions(sqlite, 3, nil, Options{AsyncVerify: true})
+	c.Assert(errors.Is(err, db.ErrAppendOnly), qt.IsTrue)
+	va, err := NewWithOptions(sqlite, 3, nil, Options{})
+	c.Assert(err, qt.IsNil)
+	c.Assert(va.Close(), qt.IsNil)
+}
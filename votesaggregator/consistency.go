@@ -0,0 +1,52 @@
+package votesaggregator
+
+import (
+	"context"
+
+	"github.com/aragon/ovote-node/censusbuilder"
+	"github.com/aragon/ovote-node/db"
+	"github.com/aragon/ovote-node/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+// FindOrphanVotes checks that the PublicKey of each VotePackage stored in the
+// given SQLite for the CensusRoot of the closed Census of the given censusID
+// is a member of that Census, and returns the VotePackages whose PublicKey is
+// not in the Census, which means that they have been stored against the
+// wrong Census or with a forged PublicKey. The VotePackages are streamed from
+// the db, and nothing is modified neither in the db nor in the Census.
+func FindOrphanVotes(ctx context.Context, cb *censusbuilder.CensusBuilder,
+	sqlite *db.SQLite, censusID types.CensusID) ([]types.VotePackage, error) {
+	root, err := cb.CensusRoot(censusID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	votes, errs := sqlite.StreamVotePackagesByCensusRoot(ctx, root)
+
+	var orphans []types.VotePackage
+	nVotes := 0
+	for vote := range votes {
+		nVotes++
+		if vote.CensusProof.PublicKey == nil {
+			orphans = append(orphans, vote)
+			continue
+		}
+		ok, err := cb.HasPublicKey(censusID, vote.CensusProof.PublicKey)
+		if err != nil {
+			// the deferred cancel stops the stream of votes
+			return nil, err
+		}
+		if !ok {
+			orphans = append(orphans, vote)
+		}
+	}
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	log.Debugf("[CensusID=%d] checked %d stored votes, %d orphans", censusID,
+		nVotes, len(orphans))
+	return orphans, nil
+}
@@ -0,0 +1,102 @@
+package votesaggregator
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/aragon/ovote-node/censusbuilder"
+	"github.com/aragon/ovote-node/db"
+	"github.com/aragon/ovote-node/test"
+	"github.com/aragon/ovote-node/types"
+	qt "github.com/frankban/quicktest"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	dvotedb "go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/db/pebbledb"
+)
+
+func TestFindOrphanVotes(t *testing.T) {
+	c := qt.New(t)
+
+	database, err := pebbledb.New(dvotedb.Options{Path: c.TempDir()})
+	c.Assert(err, qt.IsNil)
+	cb, err := censusbuilder.New(database, c.TempDir())
+	c.Assert(err, qt.IsNil)
+
+	nKeys := 10
+	keys := test.GenUserKeys(nKeys)
+	censusID, err := cb.NewCensus()
+	c.Assert(err, qt.IsNil)
+	err = cb.AddPublicKeys(censusID, keys.PublicKeys, keys.Weights)
+	c.Assert(err, qt.IsNil)
+
+	sqlDB, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
+	c.Assert(err, qt.IsNil)
+	sqlite := db.NewSQLite(sqlDB)
+	err = sqlite.Migrate()
+	c.Assert(err, qt.IsNil)
+
+	// the Census needs to be closed
+	_, err = FindOrphanVotes(context.Background(), cb, sqlite, censusID)
+	c.Assert(err, qt.Not(qt.IsNil))
+
+	err = cb.CloseCensus(censusID)
+	c.Assert(err, qt.IsNil)
+	root, err := cb.CensusRoot(censusID)
+	c.Assert(err, qt.IsNil)
+	processID := uint64(123)
+	err = sqlite.StoreProcess(processID, root, uint64(nKeys), 10, 20, 20, 60, 20, 1)
+	c.Assert(err, qt.IsNil)
+
+	// without votes, there are no orphans
+	orphans, err := FindOrphanVotes(context.Background(), cb, sqlite, censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(orphans), qt.Equals, 0)
+
+	for i := 0; i < nKeys; i++ {
+		index, proof, err := cb.GetProof(censusID, &keys.PublicKeys[i])
+		c.Assert(err, qt.IsNil)
+		vote := types.VotePackage{
+			Signature: types.CompressSignature(keys.PrivateKeys[i].SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       index,
+				PublicKey:   &keys.PublicKeys[i],
+				Weight:      keys.Weights[i],
+				MerkleProof: proof,
+			},
+			Vote: []byte("test"),
+		}
+		err = sqlite.StoreVotePackage(processID, vote)
+		c.Assert(err, qt.IsNil)
+	}
+
+	// store two votes with PublicKeys that are not in the Census
+	for i := 0; i < 2; i++ {
+		sk := babyjub.NewRandPrivKey()
+		vote := types.VotePackage{
+			Signature: types.CompressSignature(sk.SignPoseidon(big.NewInt(1))),
+			CensusProof: types.CensusProof{
+				Index:       uint64(nKeys + i),
+				PublicKey:   sk.Public(),
+				Weight:      big.NewInt(1),
+				MerkleProof: []byte{byte(i)},
+			},
+			Vote: []byte("test"),
+		}
+		err = sqlite.StoreVotePackage(processID, vote)
+		c.Assert(err, qt.IsNil)
+	}
+
+	orphans, err = FindOrphanVotes(context.Background(), cb, sqlite, censusID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(orphans), qt.Equals, 2)
+	c.Assert(orphans[0].CensusProof.Index, qt.Equals, uint64(nKeys))
+	c.Assert(orphans[1].CensusProof.Index, qt.Equals, uint64(nKeys+1))
+
+	// the check does not modify the stored votes
+	votes, err := sqlite.ReadVotePackagesByProcessID(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(votes), qt.Equals, nKeys+2)
+}
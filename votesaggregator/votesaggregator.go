@@ -6,6 +6,7 @@ import (
 	"math"
 	"math/big"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aragon/ovote-node/db"
@@ -22,11 +23,52 @@ type VotesAggregator struct {
 	db      *db.SQLite
 	chainID uint64 // determined by config
 	prover  *prover.Client
+
+	asyncVerify bool
+	verifyQueue chan verifyJob
+	verifyWg    sync.WaitGroup
+	verifyMu    sync.Mutex
+	closed      bool
+	// pending contains the number of votes of each process waiting to be
+	// verified in AsyncVerify mode, guarded by verifyMu, and
+	// pendingCond is signaled each time a vote is verified
+	pending     map[uint64]int
+	pendingCond *sync.Cond
 }
 
 // New returns a VotesAggregator with the given SQLite db
 func New(sqlite *db.SQLite, chainID uint64, p *prover.Client) (*VotesAggregator, error) {
-	return &VotesAggregator{db: sqlite, chainID: chainID, prover: p}, nil
+	return NewWithOptions(sqlite, chainID, p, Options{})
+}
+
+// NewWithOptions returns a VotesAggregator with the given SQLite db, using the
+// given Options. If Options.AsyncVerify is set, the background verifier is
+// started, which is stopped by Close. As the votes that fail the asynchronous
+// verification are removed from the stored votes, AsyncVerify can not be used
+// with a SQLite in AppendOnly mode.
+func NewWithOptions(sqlite *db.SQLite, chainID uint64, p *prover.Client,
+	opts Options) (*VotesAggregator, error) {
+	if opts.AsyncVerify && sqlite.AppendOnly() {
+		return nil, fmt.Errorf("%w, AsyncVerify can not reject the stored votes",
+			db.ErrAppendOnly)
+	}
+	va := &VotesAggregator{
+		db:          sqlite,
+		chainID:     chainID,
+		prover:      p,
+		asyncVerify: opts.AsyncVerify,
+	}
+	if va.asyncVerify {
+		queueSize := opts.VerifyQueueSize
+		if queueSize <= 0 {
+			queueSize = DefaultVerifyQueueSize
+		}
+		va.verifyQueue = make(chan verifyJob, queueSize)
+		va.pending = make(map[uint64]int)
+		va.pendingCond = sync.NewCond(&va.verifyMu)
+		va.startVerifier()
+	}
+	return va, nil
 }
 
 // SyncProcesses actively checks if there are any processes closed, to trigger
@@ -60,8 +102,16 @@ func (va *VotesAggregator) ProcessInfo(processID uint64) (*types.Process, error)
 	return va.db.ReadProcessByID(processID)
 }
 
+// Counts returns the number of Processes and VotePackages stored in the
+// VotesAggregator's db
+func (va *VotesAggregator) Counts() (*db.Counts, error) {
+	return va.db.Counts()
+}
+
 // AddVote adds to the VotesAggregator's db the given vote for the given
-// CensusRoot
+// CensusRoot. By default the signature and the MerkleProof of the vote are
+// verified before storing it, if Options.AsyncVerify is set, the vote is
+// stored without waiting for its verification, see Options.AsyncVerify.
 func (va *VotesAggregator) AddVote(processID uint64, votePackage types.VotePackage) error {
 	// for this initial version, only vote values with 0 or 1 are supported
 	// TODO check vote value inside range
@@ -77,6 +127,10 @@ func (va *VotesAggregator) AddVote(processID uint64, votePackage types.VotePacka
 			" votes can not be added", process.ResPubStartBlock)
 	}
 
+	if va.asyncVerify {
+		return va.storeAndVerifyAsync(processID, process.CensusRoot, votePackage)
+	}
+
 	// check signature (babyjubjub) and MerkleProof
 	if err := votePackage.Verify(va.chainID, processID, process.CensusRoot); err != nil {
 		return err
@@ -86,6 +140,20 @@ func (va *VotesAggregator) AddVote(processID uint64, votePackage types.VotePacka
 	return va.db.StoreVotePackage(processID, votePackage)
 }
 
+// ResultBundle returns a types.ResultBundle for the given processID, which
+// contains the CensusRoot, the tally and all the stored votes of the process
+func (va *VotesAggregator) ResultBundle(processID uint64) (*types.ResultBundle, error) {
+	process, err := va.db.ReadProcessByID(processID)
+	if err != nil {
+		return nil, err
+	}
+	votes, err := va.db.ReadVotePackagesByProcessID(processID)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewResultBundle(va.chainID, processID, process.CensusRoot, votes)
+}
+
 // generateZKInputs will generate the zkInputs for the given processID
 func (va *VotesAggregator) generateZKInputs(processID uint64, nMaxVotes,
 	nLevels /* tmp */ int) (*types.ZKInputs, error) {
@@ -123,7 +191,7 @@ func (va *VotesAggregator) generateZKInputs(processID uint64, nMaxVotes,
 		z.PkX[i] = votes[i].CensusProof.PublicKey.X
 		z.PkY[i] = votes[i].CensusProof.PublicKey.Y
 		z.Weight[i] = votes[i].CensusProof.Weight
-		sig, err := votes[i].Signature.Decompress()
+		sig, err := votes[i].DecompressSignature()
 		if err != nil {
 			// TODO, probably instead of stopping the process, skip
 			// that vote due wrong signature (having in mind, that
@@ -204,6 +272,10 @@ func (va *VotesAggregator) GenerateProof(processID uint64) error {
 
 	// if this line is reached, means that the proof needs to be generated
 
+	// the stored votes that are still waiting to be verified can be
+	// rejected, so they are verified before using them
+	va.waitVerified(processID)
+
 	// TODO WIP initially support only for census of 100 voters
 	zki, err := va.generateZKInputs(processID, 128, 7)
 	if err != nil {
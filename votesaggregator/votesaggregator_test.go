@@ -3,8 +3,10 @@ package votesaggregator
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"path/filepath"
 	"testing"
 
@@ -17,6 +19,12 @@ import (
 
 func baseTestVotesAggregator(c *qt.C, chainID, processID uint64, nVotes, ratio int) (
 	*VotesAggregator, []types.VotePackage) {
+	return baseTestVotesAggregatorWithOptions(c, Options{}, chainID, processID,
+		nVotes, ratio)
+}
+
+func baseTestVotesAggregatorWithOptions(c *qt.C, opts Options, chainID,
+	processID uint64, nVotes, ratio int) (*VotesAggregator, []types.VotePackage) {
 	sqlDB, err := sql.Open("sqlite3", filepath.Join(c.TempDir(), "testdb.sqlite3"))
 	c.Assert(err, qt.IsNil)
 
@@ -24,7 +32,7 @@ func baseTestVotesAggregator(c *qt.C, chainID, processID uint64, nVotes, ratio i
 	err = sqlite.Migrate()
 	c.Assert(err, qt.IsNil)
 
-	va, err := New(sqlite, chainID, nil)
+	va, err := NewWithOptions(sqlite, chainID, nil, opts)
 	c.Assert(err, qt.IsNil)
 
 	// prepare the census
@@ -70,7 +78,9 @@ func TestStoreAndReadVotes(t *testing.T) {
 	// try to store a vote with already stored index
 	err = va.AddVote(processID, votes[0])
 	c.Assert(err, qt.Not(qt.IsNil))
-	c.Assert(err.Error(), qt.Equals, "UNIQUE constraint failed: votepackages.indx")
+	c.Assert(errors.Is(err, db.ErrVoteAlreadyExists), qt.IsTrue)
+	c.Assert(errors.Unwrap(err).Error(), qt.Equals,
+		"UNIQUE constraint failed: votepackages.indx")
 
 	// try to store invalid merkleproofs
 	votes[0].CensusProof.Index = 11
@@ -116,3 +126,44 @@ func testGenerateZKInputs(c *qt.C, nMaxVotes, nLevels, nVotes, ratio int) {
 	err = ioutil.WriteFile(filename, s, 0600)
 	c.Assert(err, qt.IsNil)
 }
+
+func TestResultBundle(t *testing.T) {
+	c := qt.New(t)
+
+	nVotes := 10
+	chainID := uint64(3)
+	processID := uint64(123)
+	va, votes := baseTestVotesAggregator(c, chainID, processID, nVotes, 60)
+
+	var err error
+	for i := 0; i < len(votes); i++ {
+		err = va.AddVote(processID, votes[i])
+		c.Assert(err, qt.IsNil)
+	}
+
+	rb, err := va.ResultBundle(processID)
+	c.Assert(err, qt.IsNil)
+	c.Assert(len(rb.Votes), qt.Equals, nVotes)
+	c.Assert(rb.TotalWeight.Int64(), qt.Equals, int64(nVotes))
+	c.Assert(rb.Tally["1"].Int64(), qt.Equals, int64(6))
+	c.Assert(rb.Tally["0"].Int64(), qt.Equals, int64(4))
+	c.Assert(rb.Verify(), qt.IsNil)
+
+	// marshal and unmarshal the bundle, and verify it again
+	b, err := rb.Marshal()
+	c.Assert(err, qt.IsNil)
+	rb2, err := types.UnmarshalResultBundle(b)
+	c.Assert(err, qt.IsNil)
+	c.Assert(rb2.Verify(), qt.IsNil)
+	c.Assert(rb2.CensusRoot, qt.DeepEquals, rb.CensusRoot)
+
+	// modify the tally, expect verification error
+	rb2.Tally["1"] = big.NewInt(7)
+	c.Assert(rb2.Verify(), qt.ErrorMatches, "tally mismatch for option 1.*")
+
+	// modify a vote, expect verification error
+	rb2, err = types.UnmarshalResultBundle(b)
+	c.Assert(err, qt.IsNil)
+	rb2.Votes[0].CensusProof.Index++
+	c.Assert(rb2.Verify(), qt.Not(qt.IsNil))
+}